@@ -0,0 +1,328 @@
+// Package address canonicalizes US street addresses with USPS-style rules
+// (Publication 28 abbreviations) so differently typed versions of the same
+// address compare equal
+package address
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Street suffixes and their standard abbreviations
+var suffixes = map[string]string{
+	"ALLEY": "ALY", "AVENUE": "AVE", "AV": "AVE", "BOULEVARD": "BLVD", "CIRCLE": "CIR",
+	"COURT": "CT", "CRESCENT": "CRES", "DRIVE": "DR", "EXPRESSWAY": "EXPY", "FREEWAY": "FWY",
+	"HIGHWAY": "HWY", "LANE": "LN", "LOOP": "LOOP", "PARKWAY": "PKWY", "PLACE": "PL",
+	"PLAZA": "PLZ", "ROAD": "RD", "SQUARE": "SQ", "STREET": "ST", "STR": "ST",
+	"TERRACE": "TER", "TRAIL": "TRL", "TURNPIKE": "TPKE", "WAY": "WAY",
+}
+
+// Compass directions and their abbreviations
+var directions = map[string]string{
+	"NORTH": "N", "SOUTH": "S", "EAST": "E", "WEST": "W",
+	"NORTHEAST": "NE", "NORTHWEST": "NW", "SOUTHEAST": "SE", "SOUTHWEST": "SW",
+	"N": "N", "S": "S", "E": "E", "W": "W", "NE": "NE", "NW": "NW", "SE": "SE", "SW": "SW",
+}
+
+// Unit designators. Apartment, unit, and "#" all mean the same thing for
+// matching, so they share one designator.
+var units = map[string]string{
+	"APARTMENT": "APT", "APT": "APT", "UNIT": "APT", "#": "APT",
+	"SUITE": "STE", "STE": "STE", "FLOOR": "FL", "FL": "FL", "ROOM": "RM", "RM": "RM",
+	"BUILDING": "BLDG", "BLDG": "BLDG",
+}
+
+// Spelled-out ordinal street names
+var ordinals = map[string]string{
+	"FIRST": "1ST", "SECOND": "2ND", "THIRD": "3RD", "FOURTH": "4TH", "FIFTH": "5TH",
+	"SIXTH": "6TH", "SEVENTH": "7TH", "EIGHTH": "8TH", "NINTH": "9TH", "TENTH": "10TH",
+}
+
+// State names and their postal codes
+var states = map[string]string{
+	"ALABAMA": "AL", "ALASKA": "AK", "ARIZONA": "AZ", "ARKANSAS": "AR", "CALIFORNIA": "CA",
+	"COLORADO": "CO", "CONNECTICUT": "CT", "DELAWARE": "DE", "DISTRICT OF COLUMBIA": "DC",
+	"FLORIDA": "FL", "GEORGIA": "GA", "HAWAII": "HI", "IDAHO": "ID", "ILLINOIS": "IL",
+	"INDIANA": "IN", "IOWA": "IA", "KANSAS": "KS", "KENTUCKY": "KY", "LOUISIANA": "LA",
+	"MAINE": "ME", "MARYLAND": "MD", "MASSACHUSETTS": "MA", "MICHIGAN": "MI", "MINNESOTA": "MN",
+	"MISSISSIPPI": "MS", "MISSOURI": "MO", "MONTANA": "MT", "NEBRASKA": "NE", "NEVADA": "NV",
+	"NEW HAMPSHIRE": "NH", "NEW JERSEY": "NJ", "NEW MEXICO": "NM", "NEW YORK": "NY",
+	"NORTH CAROLINA": "NC", "NORTH DAKOTA": "ND", "OHIO": "OH", "OKLAHOMA": "OK", "OREGON": "OR",
+	"PENNSYLVANIA": "PA", "RHODE ISLAND": "RI", "SOUTH CAROLINA": "SC", "SOUTH DAKOTA": "SD",
+	"TENNESSEE": "TN", "TEXAS": "TX", "UTAH": "UT", "VERMONT": "VT", "VIRGINIA": "VA",
+	"WASHINGTON": "WA", "WEST VIRGINIA": "WV", "WISCONSIN": "WI", "WYOMING": "WY",
+}
+
+// stateCodes is the set of postal codes in states
+var stateCodes = func() map[string]bool {
+	codes := make(map[string]bool, len(states))
+	for _, code := range states {
+		codes[code] = true
+	}
+	return codes
+}()
+
+// suffixWords is the set of street suffixes, spelled out or abbreviated
+var suffixWords = func() map[string]bool {
+	words := make(map[string]bool, 2*len(suffixes))
+	for name, abbr := range suffixes {
+		words[name], words[abbr] = true, true
+	}
+	return words
+}()
+
+var (
+	// punctuation is dropped, except for the characters that carry meaning
+	// in unit numbers and fractions
+	punctuation = regexp.MustCompile(`[^A-Z0-9#/\-, ]+`)
+
+	// unitNumber splits "#4B" into its designator and number
+	unitNumber = regexp.MustCompile(`^#(\S+)$`)
+
+	// stateZip matches a trailing "STATE ZIP" part
+	stateZip = regexp.MustCompile(`^(.*?)\s*(\d{5})(?:-\d{4})?$`)
+
+	// zipOnly matches a part holding just a ZIP code
+	zipOnly = regexp.MustCompile(`^\d{5}(?:-\d{4})?$`)
+
+	// countries are dropped from the end since everything here is domestic
+	countries = map[string]bool{"USA": true, "US": true, "UNITED STATES": true, "UNITED STATES OF AMERICA": true}
+)
+
+// Normalize returns the canonical form of a street address: upper case,
+// standard abbreviations for suffixes, directions, unit designators, and
+// states, five-digit ZIP codes, and normalized spacing and commas. For
+// example "123 north Main Street, Apartment 4b, Springfield, Illinois
+// 62704-1234" becomes "123 N MAIN ST APT 4B, SPRINGFIELD, IL 62704".
+// Input that doesn't look like an address is still cleaned up.
+func Normalize(raw string) string {
+	s := strings.ToUpper(raw)
+	s = strings.ReplaceAll(s, ".", "")
+	s = punctuation.ReplaceAllString(s, " ")
+
+	var parts []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.Join(strings.Fields(p), " "); p != "" {
+			parts = append(parts, p)
+		}
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	if countries[parts[len(parts)-1]] {
+		parts = parts[:len(parts)-1]
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+
+	// A unit on its own line belongs with the street
+	street := parts[0]
+	rest := parts[1:]
+	for len(rest) > 0 && isUnit(strings.Fields(rest[0])) {
+		street += " " + rest[0]
+		rest = rest[1:]
+	}
+
+	// A ZIP code on its own belongs with the state
+	if n := len(rest); n > 1 && zipOnly.MatchString(rest[n-1]) {
+		rest = append(rest[:n-2], rest[n-2]+" "+rest[n-1])
+	}
+
+	// Only the last part is the state; earlier ones are the city or
+	// neighborhood, even when named like a state ("New York, NY")
+	out := []string{normalizeStreet(street)}
+	for i, p := range rest {
+		if i == len(rest)-1 {
+			p = normalizeStateZip(p)
+		}
+		out = append(out, p)
+	}
+	return strings.Join(out, ", ")
+}
+
+// isUnit reports whether words start with a unit designator
+func isUnit(words []string) bool {
+	if len(words) == 0 {
+		return false
+	}
+	_, ok := units[words[0]]
+	return ok || unitNumber.MatchString(words[0])
+}
+
+// normalizeStreet abbreviates the words of a street line
+func normalizeStreet(line string) string {
+	words := strings.Fields(line)
+
+	// Split off the unit so the suffix rule sees where the street ends
+	var unit []string
+	for i := 1; i < len(words); i++ {
+		if isUnit(words[i:]) {
+			words, unit = words[:i], words[i:]
+			break
+		}
+	}
+
+	for i, w := range words {
+		if o, ok := ordinals[w]; ok {
+			words[i] = o
+		}
+	}
+
+	// Directions are abbreviated before the street name or at the end;
+	// "North" in the middle may be part of the name
+	last := len(words) - 1
+	if len(words) > 2 {
+		if d, ok := directions[words[1]]; ok {
+			words[1] = d
+		}
+	}
+	if last > 1 {
+		if d, ok := directions[words[last]]; ok {
+			words[last] = d
+			last--
+		}
+	}
+
+	// Only the last word of the name is a suffix: "Park Avenue" but not
+	// "Avenue of the Americas"
+	if last > 0 {
+		if s, ok := suffixes[words[last]]; ok {
+			words[last] = s
+		}
+	}
+
+	if len(unit) > 0 {
+		if m := unitNumber.FindStringSubmatch(unit[0]); m != nil {
+			unit = append([]string{"APT", m[1]}, unit[1:]...)
+		} else {
+			unit[0] = units[unit[0]]
+			if len(unit) > 1 && unit[1] == "#" {
+				unit = append(unit[:1], unit[2:]...)
+			}
+		}
+		words = append(words, unit...)
+	}
+	return strings.Join(words, " ")
+}
+
+// normalizeStateZip abbreviates the state and trims the ZIP+4 extension
+// of the last part of an address
+func normalizeStateZip(part string) string {
+	state, zip := part, ""
+	if m := stateZip.FindStringSubmatch(part); m != nil {
+		state, zip = m[1], m[2]
+	}
+	if code, ok := states[state]; ok {
+		state = code
+	} else if city, code, ok := splitCityState(state); ok {
+		state = city + ", " + code
+	}
+	return strings.TrimSpace(state + " " + zip)
+}
+
+// splitCityState separates a city and state typed without a comma between
+// them, as in "Springfield IL" or "Kansas City Missouri"
+func splitCityState(part string) (city, code string, ok bool) {
+	words := strings.Fields(part)
+	if n := len(words); n > 1 && stateCodes[words[n-1]] {
+		return strings.Join(words[:n-1], " "), words[n-1], true
+	}
+	// State names run up to three words ("District of Columbia")
+	for size := 3; size >= 1; size-- {
+		if len(words) <= size {
+			continue
+		}
+		name := strings.Join(words[len(words)-size:], " ")
+		if code, ok := states[name]; ok {
+			return strings.Join(words[:len(words)-size], " "), code, true
+		}
+	}
+	return "", "", false
+}
+
+// SplitUnit separates the unit from an address typed with one, as in "123
+// Main St #4B, Springfield, IL", returning the address without it and the
+// unit number ("4B"). An address without a unit is returned as is.
+func SplitUnit(raw string) (street, unit string) {
+	parts := strings.Split(raw, ",")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+
+	// The unit follows the street, or is on a line of its own after it
+	words := strings.Fields(parts[0])
+	for i := 1; i < len(words); i++ {
+		if isUnitDesignator(words[i:]) {
+			if unit = UnitNumber(strings.Join(words[i:], " ")); unit != "" {
+				parts[0] = strings.Join(words[:i], " ")
+			}
+			break
+		}
+	}
+	if unit == "" && len(parts) > 1 && isUnitDesignator(strings.Fields(parts[1])) {
+		if unit = UnitNumber(parts[1]); unit != "" {
+			parts = append(parts[:1], parts[2:]...)
+		}
+	}
+	if unit == "" {
+		return raw, ""
+	}
+	return strings.Join(parts, ", "), unit
+}
+
+// UnitNumber returns the number of a unit as typed, dropping apartment and
+// unit designators: "Apt. 4B" and "#4B" are "4B". Other designators, as in
+// "Suite 200", are kept since they say what kind of unit it is.
+func UnitNumber(unit string) string {
+	words := strings.Fields(unit)
+	if len(words) == 0 {
+		return ""
+	}
+	if key := unitKey(words[0]); units[key] == "APT" && key != "#" {
+		words = words[1:]
+	}
+	if len(words) > 0 && words[0] == "#" {
+		words = words[1:]
+	}
+	if len(words) > 0 {
+		words[0] = strings.TrimPrefix(words[0], "#")
+	}
+	return strings.Join(words, " ")
+}
+
+// JoinUnit puts a unit back into an address after the street: as "#4B",
+// or as it is when it has its own designator, like "Suite 200"
+func JoinUnit(street, unit string) string {
+	unit = strings.TrimSpace(unit)
+	if unit == "" {
+		return street
+	}
+	if !isUnit([]string{unitKey(strings.Fields(unit)[0])}) {
+		unit = "#" + unit
+	}
+	line, rest, found := strings.Cut(street, ",")
+	line = strings.TrimSpace(line) + " " + unit
+	if found {
+		return line + "," + rest
+	}
+	return line
+}
+
+// isUnitDesignator reports whether words, as typed, start with a unit
+// designator followed by something other than a street suffix, so "Floor
+// St" stays a street name
+func isUnitDesignator(words []string) bool {
+	if len(words) == 0 || !isUnit([]string{unitKey(words[0])}) {
+		return false
+	}
+	if len(words) > 1 {
+		return !suffixWords[unitKey(words[1])]
+	}
+	return true
+}
+
+// unitKey is a typed word as it's looked up in units
+func unitKey(word string) string {
+	return strings.Trim(strings.ToUpper(word), ".")
+}
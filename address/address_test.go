@@ -0,0 +1,72 @@
+package address
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"123 north Main Street, Apartment 4b, Springfield, Illinois 62704-1234", "123 N MAIN ST APT 4B, SPRINGFIELD, IL 62704"},
+		{"123 N. Main St. #4B, Springfield, IL 62704", "123 N MAIN ST APT 4B, SPRINGFIELD, IL 62704"},
+		{"123 N Main St, Unit 4B, Springfield IL 62704, USA", "123 N MAIN ST APT 4B, SPRINGFIELD, IL 62704"},
+		{"  350   Fifth Avenue ,New York, NY ", "350 5TH AVE, NEW YORK, NY"},
+		{"1 Court Street", "1 COURT ST"},
+		{"8 Oak Ln, Springfield, Illinois, 62704", "8 OAK LN, SPRINGFIELD, IL 62704"},
+		{"77 Grand Blvd, Kansas City Missouri", "77 GRAND BLVD, KANSAS CITY, MO"},
+		{"1211 Avenue of the Americas", "1211 AVENUE OF THE AMERICAS"},
+		{"500 Park Avenue South", "500 PARK AVE S"},
+		{"42 West Way Suite 200", "42 W WAY STE 200"},
+		{"9 Elm Rd Apt # 3", "9 ELM RD APT 3"},
+		{"Untitled listing", "UNTITLED LISTING"},
+		{"", ""},
+		{", USA", ""},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, Normalize(tt.in), tt.in)
+	}
+}
+
+func TestNormalizeMatchesVariants(t *testing.T) {
+	a := Normalize("77 Massachusetts Avenue, Apt 2, Cambridge, Massachusetts 02139")
+	b := Normalize("77 Massachusetts Ave #2, Cambridge, MA 02139")
+	assert.Equal(t, a, b)
+}
+
+func TestSplitUnit(t *testing.T) {
+	tests := []struct {
+		in, street, unit string
+	}{
+		{"123 Main St #4B, Springfield, IL 62704", "123 Main St, Springfield, IL 62704", "4B"},
+		{"123 Main St Apt. 4B", "123 Main St", "4B"},
+		{"9 Elm Rd Apt # 3", "9 Elm Rd", "3"},
+		{"123 N Main St, Unit 12, Springfield", "123 N Main St, Springfield", "12"},
+		{"42 West Way Suite 200", "42 West Way", "Suite 200"},
+		{"123 Floor St, Springfield", "123 Floor St, Springfield", ""},
+		{"123 Main St Apt", "123 Main St Apt", ""},
+		{"350 Fifth Avenue, New York", "350 Fifth Avenue, New York", ""},
+		{"", "", ""},
+	}
+	for _, tt := range tests {
+		street, unit := SplitUnit(tt.in)
+		assert.Equal(t, tt.street, street, tt.in)
+		assert.Equal(t, tt.unit, unit, tt.in)
+	}
+}
+
+func TestJoinUnit(t *testing.T) {
+	assert.Equal(t, "123 Main St #4B, Springfield, IL", JoinUnit("123 Main St, Springfield, IL", "4B"))
+	assert.Equal(t, "42 West Way Suite 200", JoinUnit("42 West Way", "Suite 200"))
+	assert.Equal(t, "123 Main St", JoinUnit("123 Main St", ""))
+	assert.Equal(t, "4B", UnitNumber("Apt. 4B"))
+	assert.Equal(t, "4B", UnitNumber("#4B"))
+
+	// Units stay part of the canonical address, so units of a building
+	// aren't mistaken for duplicates
+	street, unit := SplitUnit("77 Massachusetts Ave #2, Cambridge, MA 02139")
+	assert.Equal(t, Normalize("77 Massachusetts Avenue, Apt 2, Cambridge, MA"+" 02139"), Normalize(JoinUnit(street, unit)))
+	assert.NotEqual(t, Normalize(JoinUnit(street, "1")), Normalize(JoinUnit(street, "2")))
+}
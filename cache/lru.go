@@ -0,0 +1,85 @@
+// Package cache provides a small, thread-safe LRU cache.
+package cache
+
+import (
+	"container/list"
+	"sync"
+)
+
+// LRU is a fixed-size least-recently-used cache
+type LRU[K comparable, V any] struct {
+	mu      sync.Mutex
+	size    int
+	ll      *list.List
+	items   map[K]*list.Element
+	onEvict func()
+}
+
+type entry[K comparable, V any] struct {
+	key   K
+	value V
+}
+
+// New creates an LRU holding at most size entries. onEvict, if non-nil, is
+// called whenever an entry is pushed out to make room.
+func New[K comparable, V any](size int, onEvict func()) *LRU[K, V] {
+	return &LRU[K, V]{
+		size:    size,
+		ll:      list.New(),
+		items:   make(map[K]*list.Element),
+		onEvict: onEvict,
+	}
+}
+
+// Get returns the cached value for key and marks it recently used
+func (c *LRU[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.ll.MoveToFront(el)
+		return el.Value.(*entry[K, V]).value, true
+	}
+	var zero V
+	return zero, false
+}
+
+// Add stores value under key, evicting the least recently used entry if
+// the cache is full
+func (c *LRU[K, V]) Add(key K, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.ll.MoveToFront(el)
+		el.Value.(*entry[K, V]).value = value
+		return
+	}
+
+	c.items[key] = c.ll.PushFront(&entry[K, V]{key: key, value: value})
+	if c.ll.Len() > c.size {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*entry[K, V]).key)
+		if c.onEvict != nil {
+			c.onEvict()
+		}
+	}
+}
+
+// Purge removes every entry
+func (c *LRU[K, V]) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.ll.Init()
+	clear(c.items)
+}
+
+// Len returns the number of cached entries
+func (c *LRU[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.ll.Len()
+}
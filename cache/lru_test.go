@@ -0,0 +1,35 @@
+package cache
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLRU(t *testing.T) {
+	evictions := 0
+	c := New[string, int](2, func() { evictions++ })
+
+	c.Add("a", 1)
+	c.Add("b", 2)
+
+	// Touch "a" so "b" becomes the eviction candidate
+	v, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+
+	c.Add("c", 3)
+	_, ok = c.Get("b")
+	assert.False(t, ok, "Least recently used entry should be evicted")
+	assert.Equal(t, 1, evictions)
+	assert.Equal(t, 2, c.Len())
+
+	c.Add("a", 10)
+	v, _ = c.Get("a")
+	assert.Equal(t, 10, v, "Add should replace an existing value")
+
+	c.Purge()
+	assert.Equal(t, 0, c.Len())
+	_, ok = c.Get("c")
+	assert.False(t, ok)
+}
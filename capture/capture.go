@@ -0,0 +1,112 @@
+// Package capture pulls apartment details out of listing text, such as a
+// forwarded listing email, so it can be saved as a draft
+package capture
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Listing holds whatever could be found in the text; fields that weren't
+// found are empty
+type Listing struct {
+	Address string
+	Price   *float64 // Monthly rent
+	URL     string
+}
+
+var (
+	// addressPattern matches US-style street addresses: a house number, a
+	// street name ending in a common suffix, and optionally a unit, city,
+	// state, and ZIP code
+	addressPattern = regexp.MustCompile(`(?i)\b\d{1,6}\s+(?:[NSEW]\.?\s+)?(?:[A-Za-z0-9'.-]+\s+){0,4}?` +
+		`(?:St|Street|Ave|Avenue|Rd|Road|Blvd|Boulevard|Dr|Drive|Ln|Lane|Way|Ct|Court|Pl|Place|Ter|Terrace|` +
+		`Pkwy|Parkway|Cir|Circle|Hwy|Highway|Sq|Square|Loop|Trl|Trail)\b\.?` +
+		`(?:,?\s*(?:Apt\.?|Apartment|Unit|Suite|Ste\.?|#)\s*[A-Za-z0-9-]+)?` +
+		`(?:,\s*[A-Za-z][A-Za-z .'-]{1,30}?,\s*[A-Z]{2}(?:\s+\d{5}(?:-\d{4})?)?\b)?`)
+
+	// pricePattern matches dollar amounts, capturing the number and any
+	// monthly marker after it
+	pricePattern = regexp.MustCompile(`(?i)\$\s?(\d{1,3}(?:,\d{3})+|\d+)(?:\.\d{2})?(\s*(?:/\s*mo(?:nth)?\b|per\s+month|a\s+month|monthly))?`)
+
+	urlPattern   = regexp.MustCompile(`https?://[^\s<>"')\]]+`)
+	hrefPattern  = regexp.MustCompile(`(?i)href\s*=\s*["']([^"']+)["']`)
+	tagPattern   = regexp.MustCompile(`(?s)<(?:style|script)[^>]*>.*?</(?:style|script)>|<[^>]+>`)
+	spacePattern = regexp.MustCompile(`[ \t\r\f\v]+`)
+)
+
+// Rents outside this range are more likely deposits, fees, or sale prices
+const (
+	minRent = 200
+	maxRent = 50000
+)
+
+// HTMLText reduces an HTML document to its visible text
+func HTMLText(doc string) string {
+	text := tagPattern.ReplaceAllString(doc, "\n")
+	text = html.UnescapeString(text)
+	return spacePattern.ReplaceAllString(text, " ")
+}
+
+// Parse extracts listing details from a subject line (or page title) and
+// body. bodyHTML is optional; it's searched for links and used for text
+// when body is empty.
+func Parse(subject, body, bodyHTML string) Listing {
+	if body == "" {
+		body = HTMLText(bodyHTML)
+	}
+	text := subject + "\n" + body
+
+	var l Listing
+	if m := addressPattern.FindString(text); m != "" {
+		l.Address = strings.TrimRight(strings.Join(strings.Fields(m), " "), ".,")
+	}
+	l.Price = findRent(text)
+	l.URL = findURL(body, bodyHTML)
+	return l
+}
+
+// findRent prefers amounts marked as monthly, falling back to the first
+// amount in a plausible range for rent
+func findRent(text string) *float64 {
+	var fallback *float64
+	for _, m := range pricePattern.FindAllStringSubmatch(text, -1) {
+		v, err := strconv.ParseFloat(strings.ReplaceAll(m[1], ",", ""), 64)
+		if err != nil || v < minRent || v > maxRent {
+			continue
+		}
+		if m[2] != "" {
+			return &v
+		}
+		if fallback == nil {
+			fallback = &v
+		}
+	}
+	return fallback
+}
+
+// findURL returns the first link that isn't email housekeeping such as an
+// unsubscribe or tracking-pixel link
+func findURL(body, bodyHTML string) string {
+	var candidates []string
+	for _, m := range hrefPattern.FindAllStringSubmatch(bodyHTML, -1) {
+		candidates = append(candidates, html.UnescapeString(m[1]))
+	}
+	candidates = append(candidates, urlPattern.FindAllString(body, -1)...)
+
+	for _, u := range candidates {
+		u = strings.TrimRight(u, ".,;:!?")
+		lower := strings.ToLower(u)
+		if !strings.HasPrefix(lower, "http://") && !strings.HasPrefix(lower, "https://") {
+			continue
+		}
+		if strings.Contains(lower, "unsubscribe") || strings.Contains(lower, "preferences") ||
+			strings.HasSuffix(lower, ".png") || strings.HasSuffix(lower, ".gif") {
+			continue
+		}
+		return u
+	}
+	return ""
+}
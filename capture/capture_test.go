@@ -0,0 +1,57 @@
+package capture
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	body := `---------- Forwarded message ---------
+From: Listings <alerts@example.com>
+
+New listing matching your search!
+
+2BR with balcony at 742 Evergreen Terrace, Apt 3B, Springfield, IL 62704
+Application fee $50. Rent: $1,850/mo, deposit $1,850.
+
+View it here: https://listings.example.com/l/98765.
+Unsubscribe: https://example.com/unsubscribe?u=1`
+
+	l := Parse("Fwd: New listing", body, "")
+	assert.Equal(t, "742 Evergreen Terrace, Apt 3B, Springfield, IL 62704", l.Address)
+	if assert.NotNil(t, l.Price) {
+		assert.Equal(t, 1850.0, *l.Price)
+	}
+	assert.Equal(t, "https://listings.example.com/l/98765", l.URL)
+}
+
+func TestParseHTML(t *testing.T) {
+	doc := `<html><style>p { color: red }</style><body>
+<a href="https://example.com/unsubscribe">Unsubscribe</a>
+<p>Spacious studio &amp; more at <b>12 N. Main St.</b></p>
+<p>Only $975 a month</p>
+<a href="https://rent.example.com/listing?id=1&amp;ref=mail">Details</a>
+</body></html>`
+
+	l := Parse("", "", doc)
+	assert.Equal(t, "12 N. Main St", l.Address)
+	if assert.NotNil(t, l.Price) {
+		assert.Equal(t, 975.0, *l.Price)
+	}
+	assert.Equal(t, "https://rent.example.com/listing?id=1&ref=mail", l.URL)
+}
+
+func TestParseNothing(t *testing.T) {
+	l := Parse("Hello", "Are you still looking? Call me.", "")
+	assert.Empty(t, l.Address)
+	assert.Nil(t, l.Price)
+	assert.Empty(t, l.URL)
+}
+
+func TestFindRentFallback(t *testing.T) {
+	// No monthly marker: skip the fee and take the first plausible rent
+	if p := findRent("Fee $40, price $2,300, parking $150"); assert.NotNil(t, p) {
+		assert.Equal(t, 2300.0, *p)
+	}
+}
@@ -0,0 +1,104 @@
+package costs
+
+import (
+	"math"
+	"sort"
+
+	"github.com/mojotx/apt-eval/models"
+)
+
+// Rental is a rental candidate in a buy-vs-rent comparison, with its
+// move-in costs
+type Rental struct {
+	Apartment *models.Apartment
+	Costs     models.MoveInCosts
+}
+
+// BuyVsRent itemizes the cost of buying home under terms and compares its
+// monthly cost with each rental's, cheapest rental first. Both sides
+// count parking and the utilities not included, so only the housing
+// costs differ.
+func BuyVsRent(home *models.Apartment, terms models.MortgageTerms, rentals []Rental) models.BuyVsRent {
+	var price float64
+	if home.Sale.PurchasePrice != nil {
+		price = *home.Sale.PurchasePrice
+	}
+	down := price * terms.DownPaymentPct / 100
+	loan := price - down
+	b := models.BuyVsRent{
+		ApartmentID:   home.ID,
+		Terms:         terms,
+		PurchasePrice: round(price),
+		LoanAmount:    round(loan),
+		Rentals:       []models.RentComparison{},
+	}
+
+	b.Upfront = appendItem(b.Upfront, "Down payment", down, false)
+	b.Upfront = appendItem(b.Upfront, "Closing costs", price*terms.ClosingCostPct/100, false)
+	b.UpfrontTotal = total(b.Upfront)
+
+	payment := MonthlyPayment(loan, terms.RatePct, terms.Years)
+	tax := price * terms.PropertyTaxPct / 100
+	if home.Sale.PropertyTax != nil {
+		tax = *home.Sale.PropertyTax
+	}
+	b.Monthly = appendItem(b.Monthly, "Mortgage (principal and interest)", payment, false)
+	b.Monthly = appendItem(b.Monthly, "Property tax", tax/12, false)
+	b.Monthly = appendItem(b.Monthly, "Insurance", price*terms.InsurancePct/100/12, false)
+	if home.Sale.HOAFee != nil {
+		b.Monthly = appendItem(b.Monthly, "HOA fee", *home.Sale.HOAFee, false)
+	}
+	b.Monthly = appendItem(b.Monthly, "Maintenance", price*terms.MaintenancePct/100/12, false)
+	for _, u := range home.Utilities.List() {
+		if u.Estimate != nil && !u.Included {
+			b.Monthly = appendItem(b.Monthly, u.Name, *u.Estimate, false)
+		}
+	}
+	if home.Parking.MonthlyCost != nil {
+		b.Monthly = appendItem(b.Monthly, "Parking", *home.Parking.MonthlyCost, false)
+	}
+	b.MonthlyTotal = total(b.Monthly)
+	b.EquityFirstYear = round(principalPaid(loan, terms.RatePct, payment, 12))
+
+	for _, r := range rentals {
+		c := Calculate(r.Apartment, r.Costs)
+		b.Rentals = append(b.Rentals, models.RentComparison{
+			ApartmentID:  r.Apartment.ID,
+			Address:      r.Apartment.Address,
+			Unit:         r.Apartment.Unit,
+			Status:       r.Apartment.Status,
+			MonthlyTotal: c.MonthlyTotal,
+			MoveInTotal:  c.MoveInTotal,
+			Difference:   round(b.MonthlyTotal - c.MonthlyTotal),
+		})
+	}
+	sort.SliceStable(b.Rentals, func(i, j int) bool {
+		return b.Rentals[i].MonthlyTotal < b.Rentals[j].MonthlyTotal
+	})
+	return b
+}
+
+// MonthlyPayment returns the fixed monthly payment paying off loan over
+// years at the yearly rate ratePct
+func MonthlyPayment(loan, ratePct float64, years int) float64 {
+	n := float64(years * 12)
+	if loan <= 0 || n <= 0 {
+		return 0
+	}
+	r := ratePct / 100 / 12
+	if r == 0 {
+		return round(loan / n)
+	}
+	return round(loan * r / (1 - math.Pow(1+r, -n)))
+}
+
+// principalPaid returns how much of loan the first months of payments pay
+// off
+func principalPaid(loan, ratePct, payment float64, months int) float64 {
+	r := ratePct / 100 / 12
+	balance := loan
+	for i := 0; i < months && balance > 0; i++ {
+		balance -= payment - balance*r
+	}
+	return loan - max(balance, 0)
+}
@@ -0,0 +1,86 @@
+// Package costs works out what an apartment really costs: the cash needed
+// to move in and the total cost of occupancy over the first year
+package costs
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/mojotx/apt-eval/models"
+)
+
+// Calculate itemizes the move-in and monthly costs of apt, counting
+// parking and the utilities the rent doesn't include as monthly costs. The first
+// month's rent is prorated by days when the move-in date isn't the 1st;
+// the first-year total counts twelve full months either way, so
+// apartments compare evenly.
+func Calculate(apt *models.Apartment, in models.MoveInCosts) models.CostBreakdown {
+	b := models.CostBreakdown{ApartmentID: apt.ID, Inputs: in}
+
+	// Unknown rent is left out, like any other unset cost
+	var rent float64
+	if apt.Price != nil {
+		rent = *apt.Price
+	}
+	b.Monthly = appendItem(b.Monthly, "Rent", rent, false)
+	for _, u := range apt.Utilities.List() {
+		if u.Estimate != nil && !u.Included {
+			b.Monthly = appendItem(b.Monthly, u.Name, *u.Estimate, false)
+		}
+	}
+	if apt.Parking.MonthlyCost != nil {
+		b.Monthly = appendItem(b.Monthly, "Parking", *apt.Parking.MonthlyCost, false)
+	}
+	b.Monthly = appendItem(b.Monthly, "Recurring fees", in.MonthlyFees, false)
+	b.MonthlyTotal = total(b.Monthly)
+
+	first, label := rent, "First month's rent"
+	if in.MoveInDate != nil && in.MoveInDate.Day() > 1 {
+		first = Prorate(rent, in.MoveInDate.Time)
+		label = fmt.Sprintf("First month's rent (prorated from %s)", in.MoveInDate.Format("Jan 2"))
+	}
+	b.MoveIn = appendItem(b.MoveIn, label, first, false)
+	b.MoveIn = appendItem(b.MoveIn, "Recurring fees", in.MonthlyFees, false)
+	b.MoveIn = appendItem(b.MoveIn, "Security deposit", in.SecurityDeposit, true)
+	b.MoveIn = appendItem(b.MoveIn, "Pet deposit", in.PetDeposit, true)
+	b.MoveIn = appendItem(b.MoveIn, "Application fee", in.ApplicationFee, false)
+	b.MoveIn = appendItem(b.MoveIn, "Admin fee", in.AdminFee, false)
+	b.MoveIn = appendItem(b.MoveIn, "Broker fee", in.BrokerFee, false)
+	b.MoveIn = appendItem(b.MoveIn, "Other fees", in.OtherFees, false)
+	b.MoveIn = appendItem(b.MoveIn, "Movers", in.MoverEstimate, false)
+	b.MoveInTotal = total(b.MoveIn)
+
+	oneTime := in.ApplicationFee + in.AdminFee + in.BrokerFee + in.OtherFees + in.MoverEstimate
+	b.FirstYearTotal = round(12*b.MonthlyTotal + oneTime)
+	return b
+}
+
+// Prorate returns the rent due for the rest of the month from moveIn,
+// counting the move-in day
+func Prorate(rent float64, moveIn time.Time) float64 {
+	days := time.Date(moveIn.Year(), moveIn.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	return round(rent * float64(days-moveIn.Day()+1) / float64(days))
+}
+
+// appendItem adds a line for a cost, leaving out costs that don't apply
+func appendItem(items []models.CostItem, label string, amount float64, refundable bool) []models.CostItem {
+	if amount == 0 {
+		return items
+	}
+	return append(items, models.CostItem{Label: label, Amount: round(amount), Refundable: refundable})
+}
+
+// total adds up items
+func total(items []models.CostItem) float64 {
+	var sum float64
+	for _, item := range items {
+		sum += item.Amount
+	}
+	return round(sum)
+}
+
+// round rounds to whole cents
+func round(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,148 @@
+package costs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mojotx/apt-eval/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProrate(t *testing.T) {
+	day := func(s string) time.Time {
+		d, _ := time.Parse("2006-01-02", s)
+		return d
+	}
+	assert.Equal(t, 1500.0, Prorate(1500, day("2026-06-01")))
+	assert.Equal(t, 800.0, Prorate(1500, day("2026-06-15"))) // 16 of 30 days
+	assert.Equal(t, 700.0, Prorate(1400, day("2026-02-15"))) // 14 of 28 days
+	assert.Equal(t, 48.39, Prorate(1500, day("2026-01-31"))) // 1 of 31 days
+	assert.Equal(t, 51.72, Prorate(1500, day("2028-02-29"))) // leap year, 1 of 29 days
+}
+
+func TestCalculate(t *testing.T) {
+	moveIn := time.Date(2026, 6, 15, 0, 0, 0, 0, time.UTC)
+	rent, electricity, water, parking := 1500.0, 60.0, 40.0, 75.0
+	apt := &models.Apartment{ID: 7, Price: &rent, Utilities: models.Utilities{
+		Electricity:   &electricity,
+		Water:         &water,
+		WaterIncluded: true,
+	}, Parking: models.Parking{Type: models.ParkingAssigned, MonthlyCost: &parking}}
+	b := Calculate(apt, models.MoveInCosts{
+		SecurityDeposit: 1500,
+		ApplicationFee:  50,
+		AdminFee:        200,
+		MoverEstimate:   900,
+		MonthlyFees:     25,
+		MoveInDate:      &models.CustomTime{Time: moveIn},
+	})
+
+	assert.Equal(t, int64(7), b.ApartmentID)
+	assert.Equal(t, []models.CostItem{
+		{Label: "Rent", Amount: 1500},
+		{Label: "Electricity", Amount: 60},
+		{Label: "Parking", Amount: 75},
+		{Label: "Recurring fees", Amount: 25},
+	}, b.Monthly, "included utilities are left out")
+	assert.Equal(t, 1660.0, b.MonthlyTotal)
+
+	assert.Equal(t, "First month's rent (prorated from Jun 15)", b.MoveIn[0].Label)
+	assert.Equal(t, 800.0, b.MoveIn[0].Amount)
+	assert.Len(t, b.MoveIn, 6, "zero costs are left out")
+	assert.True(t, b.MoveIn[2].Refundable)
+	assert.Equal(t, 800+25+1500+50+200+900.0, b.MoveInTotal)
+
+	// Twelve months plus the fees and movers; the deposit comes back
+	assert.Equal(t, 12*1660+50+200+900.0, b.FirstYearTotal)
+
+	rent = 1200
+	b = Calculate(&models.Apartment{Price: &rent}, models.MoveInCosts{})
+	assert.Equal(t, []models.CostItem{{Label: "First month's rent", Amount: 1200}}, b.MoveIn)
+	assert.Equal(t, 14400.0, b.FirstYearTotal)
+}
+
+func TestSplit(t *testing.T) {
+	f := func(v float64) *float64 { return &v }
+	people := []Sharer{
+		{Roommate: models.Roommate{ID: 1, Name: "Ana", Percent: f(50)}, RoomID: 10, RoomArea: f(16)},
+		{Roommate: models.Roommate{ID: 2, Name: "Ben", Percent: f(30)}, RoomID: 11, RoomArea: f(12)},
+		{Roommate: models.Roommate{ID: 3, Name: "Cy", Percent: f(20)}, RoomID: 11, RoomArea: f(12)},
+	}
+	costs := func(s *models.RentSplit) []float64 {
+		var out []float64
+		for _, share := range s.Shares {
+			out = append(out, share.MonthlyCost)
+		}
+		return out
+	}
+
+	s := Split(2000, models.SplitEqual, people)
+	assert.Equal(t, models.SplitEqual, s.Rule)
+	assert.Equal(t, []float64{666.67, 666.67, 666.66}, costs(s), "the last share absorbs rounding")
+	assert.Equal(t, 33.33, s.Shares[0].Percent)
+
+	// Ben and Cy share a 12 m² room, so each counts 6 m² against Ana's 16
+	s = Split(2800, models.SplitRoomSize, people)
+	assert.Equal(t, models.SplitRoomSize, s.Rule)
+	assert.Equal(t, []float64{1600, 600, 600}, costs(s))
+
+	s = Split(2000, models.SplitCustom, people)
+	assert.Equal(t, []float64{1000, 600, 400}, costs(s))
+	assert.Equal(t, 50.0, s.Shares[0].Percent)
+
+	// Percentages are scaled when they don't add up to 100
+	people[2].Percent = f(70)
+	s = Split(3000, models.SplitCustom, people)
+	assert.Equal(t, []float64{1000, 600, 1400}, costs(s))
+
+	// A missing room area falls back to equal shares
+	people[0].RoomArea = nil
+	s = Split(3000, models.SplitRoomSize, people)
+	assert.Equal(t, models.SplitEqual, s.Rule)
+	assert.Equal(t, []float64{1000, 1000, 1000}, costs(s))
+
+	s = Split(1000, models.SplitEqual, nil)
+	assert.Empty(t, s.Shares)
+}
+
+func TestMonthlyPayment(t *testing.T) {
+	assert.Equal(t, 2022.62, MonthlyPayment(320000, 6.5, 30))
+	assert.Equal(t, 1000.0, MonthlyPayment(360000, 0, 30))
+	assert.Equal(t, 0.0, MonthlyPayment(0, 6.5, 30), "paid in cash")
+}
+
+func TestBuyVsRent(t *testing.T) {
+	price, hoa := 400000.0, 250.0
+	home := &models.Apartment{ID: 3, ListingType: models.ListingSale, Sale: models.Sale{PurchasePrice: &price, HOAFee: &hoa}}
+	rent, cheaper := 2500.0, 1800.0
+	rentals := []Rental{
+		{Apartment: &models.Apartment{ID: 1, Address: "1 A St", Price: &rent}},
+		{Apartment: &models.Apartment{ID: 2, Address: "2 B St", Price: &cheaper}, Costs: models.MoveInCosts{SecurityDeposit: 1800}},
+	}
+
+	b := BuyVsRent(home, models.DefaultMortgageTerms, rentals)
+	assert.Equal(t, 320000.0, b.LoanAmount)
+	assert.Equal(t, 92000.0, b.UpfrontTotal, "20% down and 3% closing costs")
+	assert.Equal(t, []models.CostItem{
+		{Label: "Mortgage (principal and interest)", Amount: 2022.62},
+		{Label: "Property tax", Amount: 366.67},
+		{Label: "Insurance", Amount: 116.67},
+		{Label: "HOA fee", Amount: 250},
+		{Label: "Maintenance", Amount: 333.33},
+	}, b.Monthly)
+	assert.Equal(t, 3089.29, b.MonthlyTotal)
+	assert.InDelta(t, 3576, b.EquityFirstYear, 1)
+
+	if assert.Len(t, b.Rentals, 2) {
+		assert.Equal(t, int64(2), b.Rentals[0].ApartmentID, "cheapest first")
+		assert.Equal(t, 1289.29, b.Rentals[0].Difference)
+		assert.Equal(t, 3600.0, b.Rentals[0].MoveInTotal)
+		assert.Equal(t, 589.29, b.Rentals[1].Difference)
+	}
+
+	tax := 6000.0
+	home.Sale.PropertyTax = &tax
+	b = BuyVsRent(home, models.DefaultMortgageTerms, nil)
+	assert.Contains(t, b.Monthly, models.CostItem{Label: "Property tax", Amount: 500})
+	assert.Empty(t, b.Rentals)
+}
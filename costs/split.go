@@ -0,0 +1,78 @@
+package costs
+
+import "github.com/mojotx/apt-eval/models"
+
+// Sharer is a roommate taking part in a split, with the room they'd take
+// when splitting by room size
+type Sharer struct {
+	models.Roommate
+	RoomID   int64
+	RoomArea *float64
+}
+
+// Split divides total between sharers under rule. Roommates sharing a
+// room share its area, and custom percentages are scaled to add up to 100.
+// When a roommate has no room area or percent, the split falls back to
+// equal shares. Shares are rounded to cents, with any rounding difference
+// going to the last roommate so they add up to total.
+func Split(total float64, rule string, sharers []Sharer) *models.RentSplit {
+	weights := splitWeights(rule, sharers)
+	if weights == nil {
+		rule = models.SplitEqual
+		weights = make([]float64, len(sharers))
+		for i := range weights {
+			weights[i] = 1
+		}
+	}
+
+	var sum float64
+	for _, w := range weights {
+		sum += w
+	}
+
+	split := &models.RentSplit{Rule: rule, Total: round(total), Shares: []models.RoommateShare{}}
+	var allocated float64
+	for i, s := range sharers {
+		share := models.RoommateShare{
+			RoommateID:  s.ID,
+			Name:        s.Name,
+			Percent:     round(100 * weights[i] / sum),
+			MonthlyCost: round(total * weights[i] / sum),
+		}
+		if i == len(sharers)-1 {
+			share.MonthlyCost = round(split.Total - allocated)
+		}
+		allocated += share.MonthlyCost
+		split.Shares = append(split.Shares, share)
+	}
+	return split
+}
+
+// splitWeights returns each sharer's relative share under rule, or nil if
+// the rule can't be applied
+func splitWeights(rule string, sharers []Sharer) []float64 {
+	weights := make([]float64, len(sharers))
+	switch rule {
+	case models.SplitRoomSize:
+		occupants := map[int64]int{}
+		for _, s := range sharers {
+			occupants[s.RoomID]++
+		}
+		for i, s := range sharers {
+			if s.RoomArea == nil || *s.RoomArea <= 0 {
+				return nil
+			}
+			weights[i] = *s.RoomArea / float64(occupants[s.RoomID])
+		}
+	case models.SplitCustom:
+		for i, s := range sharers {
+			if s.Percent == nil || *s.Percent <= 0 {
+				return nil
+			}
+			weights[i] = *s.Percent
+		}
+	default:
+		return nil
+	}
+	return weights
+}
@@ -0,0 +1,134 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mojotx/apt-eval/models"
+)
+
+// RecordActivity adds an entry to the activity feed about an apartment,
+// done by userID, or 0 for no one in particular. A change that can be
+// undone passes the apartment before it, and after it unless it deleted
+// the apartment; the entry must be recorded while the apartment exists.
+func (db *DB) RecordActivity(userID int64, action string, apartmentID int64, detail string, before, after *ApartmentSnapshot) error {
+	return recordUndoable(db, userID, action, apartmentID, detail, before, after)
+}
+
+func recordUndoable(q queryer, userID int64, action string, apartmentID int64, detail string, before, after *ApartmentSnapshot) error {
+	var undo *string
+	if before != nil {
+		data, err := json.Marshal(activityUndo{Before: before, After: after})
+		if err != nil {
+			return fmt.Errorf("failed to encode undo data: %w", err)
+		}
+		s := string(data)
+		undo = &s
+	}
+	return recordActivity(q, userID, action, apartmentID, detail, undo)
+}
+
+func recordActivity(q queryer, userID int64, action string, apartmentID int64, detail string, undo *string) error {
+	_, err := q.Exec(`
+		INSERT INTO activity (user_id, action, apartment_id, address, detail, private_to, undo_data)
+		SELECT ?, ?, id, address, ?, CASE WHEN visibility = ? THEN owner_id END, ?
+		FROM apartments WHERE id = ?`,
+		activityUser(userID), action, detail, models.VisibilityPrivate, undo, apartmentID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record activity: %w", err)
+	}
+	return nil
+}
+
+// activityUser is the user_id of an activity done by userID
+func activityUser(userID int64) *int64 {
+	if userID == 0 {
+		return nil
+	}
+	return &userID
+}
+
+// ActivityOptions selects a page of the activity feed
+type ActivityOptions struct {
+	Viewer int64 // Entries about apartments private to others are left out
+	Before int64 // Only entries older than this ID, when set
+	Since  int64 // Only entries newer than this ID, when set
+	User   int64 // Only entries by this user, when set
+	Limit  int   // -1 for no limit
+
+	// Only entries with one of these actions, when set
+	Actions []string
+
+	// For the admin audit trail
+	All         bool      // Include entries private to others, ignoring Viewer
+	ApartmentID int64     // Only entries about this apartment, when set
+	Action      string    // Only entries with this action, when set
+	From, To    time.Time // Only entries in [From, To), when set
+}
+
+// ListActivity returns activity feed entries, newest first
+func (db *DB) ListActivity(opts ActivityOptions) ([]models.Activity, error) {
+	query := `
+		SELECT act.id, act.user_id, COALESCE(u.name, ''), act.action, act.apartment_id, act.address, act.detail, act.created_at
+		FROM activity act
+		LEFT JOIN users u ON u.id = act.user_id
+		LEFT JOIN apartments ON apartments.id = act.apartment_id
+		WHERE (? OR ((act.private_to IS NULL OR act.private_to = ?)
+		  AND (apartments.id IS NULL OR ` + visibleTo + `)))`
+	args := []any{opts.All, opts.Viewer, opts.Viewer}
+	if opts.Before > 0 {
+		query += " AND act.id < ?"
+		args = append(args, opts.Before)
+	}
+	if opts.Since > 0 {
+		query += " AND act.id > ?"
+		args = append(args, opts.Since)
+	}
+	if opts.User > 0 {
+		query += " AND act.user_id = ?"
+		args = append(args, opts.User)
+	}
+	if len(opts.Actions) > 0 {
+		query += " AND act.action IN (" + strings.TrimSuffix(strings.Repeat("?,", len(opts.Actions)), ",") + ")"
+		for _, action := range opts.Actions {
+			args = append(args, action)
+		}
+	}
+	if opts.ApartmentID > 0 {
+		query += " AND act.apartment_id = ?"
+		args = append(args, opts.ApartmentID)
+	}
+	if opts.Action != "" {
+		query += " AND act.action = ?"
+		args = append(args, opts.Action)
+	}
+	if !opts.From.IsZero() {
+		query += " AND act.created_at >= ?"
+		args = append(args, opts.From.UTC().Format(cursorTimeFormat))
+	}
+	if !opts.To.IsZero() {
+		query += " AND act.created_at < ?"
+		args = append(args, opts.To.UTC().Format(cursorTimeFormat))
+	}
+	query += " ORDER BY act.id DESC LIMIT ?"
+	args = append(args, opts.Limit)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list activity: %w", err)
+	}
+	defer rows.Close()
+
+	activity := []models.Activity{}
+	for rows.Next() {
+		var a models.Activity
+		if err := rows.Scan(&a.ID, &a.UserID, &a.User, &a.Action, &a.ApartmentID, &a.Address, &a.Detail, &a.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan activity row: %w", err)
+		}
+		activity = append(activity, a)
+	}
+	return activity, rows.Err()
+}
@@ -0,0 +1,202 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/mojotx/apt-eval/address"
+	"github.com/mojotx/apt-eval/models"
+)
+
+// DuplicateAddressError is returned when unique addresses are required and
+// an apartment would have the canonical address of another
+type DuplicateAddressError struct {
+	Address string // The canonical address
+	ID      int64  // The apartment already there
+}
+
+func (e *DuplicateAddressError) Error() string {
+	return fmt.Sprintf("apartment %d is already at %s", e.ID, e.Address)
+}
+
+// RequireUniqueAddresses adds or drops a unique index on the canonical
+// address, the street address and unit normalized, so no two apartments
+// can be entered at the same one. Apartments without an address are
+// exempt. It fails if some are already entered more than once.
+func (db *DB) RequireUniqueAddresses(require bool) error {
+	if !require {
+		if _, err := db.Exec("DROP INDEX IF EXISTS idx_apartments_unique_address"); err != nil {
+			return fmt.Errorf("failed to drop unique address index: %w", err)
+		}
+		return nil
+	}
+
+	_, err := db.Exec(`
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_apartments_unique_address
+		ON apartments (canonical_address) WHERE canonical_address != ''`)
+	if isUniqueViolation(err) {
+		return errors.New("can't require unique addresses: some apartments share one; see GET /api/apartments/duplicates")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create unique address index: %w", err)
+	}
+	return nil
+}
+
+// isUniqueViolation reports whether err is a UNIQUE constraint failing
+func isUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique
+}
+
+// duplicateAddress returns the *DuplicateAddressError for apartment id
+// taking a canonical address another apartment has
+func duplicateAddress(q queryer, id int64, canonical string) error {
+	var existing int64
+	err := q.QueryRow("SELECT id FROM apartments WHERE canonical_address = ? AND id != ?", canonical, id).Scan(&existing)
+	if err != nil {
+		return fmt.Errorf("failed to find apartment with duplicate address: %w", err)
+	}
+	return &DuplicateAddressError{Address: canonical, ID: existing}
+}
+
+// backfillCanonicalAddresses fills in the canonical addresses of rows
+// saved before they were stored, splitting off the unit of addresses typed
+// with one
+func backfillCanonicalAddresses(db *sql.DB) error {
+	rows, err := db.Query(`
+		SELECT id, address, unit FROM apartments
+		WHERE (canonical_address = '' OR building_address = '') AND address != ''`)
+	if err != nil {
+		return fmt.Errorf("failed to list apartments without canonical address: %w", err)
+	}
+	type split struct{ street, unit string }
+	addresses := map[int64]split{}
+	for rows.Next() {
+		var id int64
+		var raw, unit string
+		if err := rows.Scan(&id, &raw, &unit); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan apartment address: %w", err)
+		}
+		street, embedded := address.SplitUnit(raw)
+		if unit == "" {
+			unit = embedded
+		}
+		addresses[id] = split{street, unit}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error during row iteration: %w", err)
+	}
+
+	for id, a := range addresses {
+		_, err := db.Exec(`
+			UPDATE apartments SET address = ?, unit = ?, canonical_address = ?, building_address = ?
+			WHERE id = ?`,
+			a.street, a.unit, address.Normalize(address.JoinUnit(a.street, a.unit)), address.Normalize(a.street), id)
+		if err != nil {
+			return fmt.Errorf("failed to set canonical address: %w", err)
+		}
+		if err := indexApartment(db, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitUnit returns the street address a request sets and its unit: the
+// unit it sets, or one typed into the address. The unit is nil when the
+// request has neither, leaving the current unit unchanged.
+func splitUnit(req *models.ApartmentRequest) (string, *string) {
+	street, unit := address.SplitUnit(req.Address)
+	if unit == "" {
+		if req.Unit == nil {
+			return street, nil
+		}
+		unit = address.UnitNumber(*req.Unit)
+	} else if req.Unit != nil && *req.Unit != "" {
+		unit = address.UnitNumber(*req.Unit)
+	}
+	return street, &unit
+}
+
+// setCanonicalAddresses stores the canonical forms of an apartment's
+// address with and without its unit, failing with a
+// *DuplicateAddressError when unique addresses are required and another
+// apartment has it
+func setCanonicalAddresses(tx *sql.Tx, id int64) error {
+	var street, unit string
+	if err := tx.QueryRow("SELECT address, unit FROM apartments WHERE id = ?", id).Scan(&street, &unit); err != nil {
+		return fmt.Errorf("failed to read apartment address: %w", err)
+	}
+	canonical := address.Normalize(address.JoinUnit(street, unit))
+	_, err := tx.Exec("UPDATE apartments SET canonical_address = ?, building_address = ? WHERE id = ?",
+		canonical, address.Normalize(street), id)
+	if isUniqueViolation(err) {
+		return duplicateAddress(tx, id, canonical)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to set canonical address: %w", err)
+	}
+	return nil
+}
+
+// CanonicalAddress returns the canonical address an apartment saved from
+// req would have, counting the unit it sets or none
+func CanonicalAddress(req *models.ApartmentRequest) string {
+	street, unit := splitUnit(req)
+	if unit == nil {
+		return address.Normalize(street)
+	}
+	return address.Normalize(address.JoinUnit(street, *unit))
+}
+
+// FindApartmentByAddress returns the oldest apartment viewer may see with
+// a canonical address, or nil if there's none
+func (db *DB) FindApartmentByAddress(canonical string, viewer int64) (*models.Apartment, error) {
+	apartment, err := db.scanApartment(db.QueryRow(selectApartmentsQuery+`
+		WHERE canonical_address = ? AND `+visibleTo+`
+		ORDER BY created_at, id
+		LIMIT 1`, canonical, viewer))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find apartment by address: %w", err)
+	}
+	return apartment, nil
+}
+
+// ListDuplicateApartments groups the apartments viewer may see that share
+// a canonical address, ordered by address and then oldest first.
+// Apartments with a unique address are left out.
+func (db *DB) ListDuplicateApartments(viewer int64) ([]models.DuplicateGroup, error) {
+	rows, err := db.Query(selectApartmentsQuery+`
+		WHERE `+visibleTo+` AND canonical_address IN (
+			SELECT canonical_address FROM apartments
+			WHERE canonical_address != '' AND `+visibleTo+`
+			GROUP BY canonical_address HAVING COUNT(*) > 1
+		)
+		ORDER BY canonical_address, created_at, id`, viewer, viewer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list duplicate apartments: %w", err)
+	}
+	defer rows.Close()
+
+	groups := []models.DuplicateGroup{}
+	for rows.Next() {
+		apt, err := db.scanApartment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan apartment row: %w", err)
+		}
+		if n := len(groups); n == 0 || groups[n-1].CanonicalAddress != apt.CanonicalAddress {
+			groups = append(groups, models.DuplicateGroup{CanonicalAddress: apt.CanonicalAddress})
+		}
+		g := &groups[len(groups)-1]
+		g.Apartments = append(g.Apartments, *apt)
+	}
+	return groups, rows.Err()
+}
@@ -0,0 +1,114 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mojotx/apt-eval/filter"
+	"github.com/mojotx/apt-eval/models"
+)
+
+// PriceBracket groups apartments into price ranges of AggregateOptions'
+// BracketWidth, keyed by the bottom of each range
+const PriceBracket = "price_bracket"
+
+// AggregateOptions controls what AggregateApartments computes
+type AggregateOptions struct {
+	// Filter restricts the apartments aggregated; nil includes them all
+	Filter filter.Node
+
+	// GroupBy names the non-date fields in ApartmentFields, or
+	// PriceBracket, to group by. No fields aggregates every apartment
+	// into one group.
+	GroupBy []string
+
+	// Field is the number field in ApartmentFields whose statistics are
+	// computed
+	Field string
+
+	// BracketWidth is the size of each PriceBracket range
+	BracketWidth float64
+
+	// Viewer is the household member asking, whose view leaves out other
+	// members' private apartments
+	Viewer int64
+}
+
+// AggregateApartments counts the apartments in each group and computes the
+// average, minimum, and maximum of a field, ignoring apartments where it's
+// unset. Groups are ordered by their values.
+func (db *DB) AggregateApartments(opts AggregateOptions) ([]models.Aggregate, error) {
+	value := ApartmentFields[opts.Field].Column
+
+	var columns, groups []string
+	var types []filter.Type
+	var args []any
+	for i, name := range opts.GroupBy {
+		if name == PriceBracket {
+			columns = append(columns, fmt.Sprintf("CAST(price / ? AS INTEGER) * ? AS g%d", i))
+			args = append(args, opts.BracketWidth, opts.BracketWidth)
+			types = append(types, filter.Number)
+		} else {
+			f := ApartmentFields[name]
+			columns = append(columns, fmt.Sprintf("%s AS g%d", f.Column, i))
+			types = append(types, f.Type)
+		}
+		groups = append(groups, fmt.Sprintf("g%d", i))
+	}
+
+	query := "SELECT " + strings.Join(append(columns,
+		"COUNT(*)",
+		fmt.Sprintf("AVG(%s)", value),
+		fmt.Sprintf("MIN(%s)", value),
+		fmt.Sprintf("MAX(%s)", value),
+	), ", ") + " FROM apartments WHERE " + visibleTo
+	args = append(args, opts.Viewer)
+	if opts.Filter != nil {
+		clause, clauseArgs := opts.Filter.SQL()
+		query += " AND " + clause
+		args = append(args, clauseArgs...)
+	}
+	if len(groups) > 0 {
+		query += " GROUP BY " + strings.Join(groups, ", ") + " ORDER BY " + strings.Join(groups, ", ")
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate apartments: %w", err)
+	}
+	defer rows.Close()
+
+	aggregates := []models.Aggregate{}
+	for rows.Next() {
+		var a models.Aggregate
+		keys := make([]any, len(opts.GroupBy))
+		dest := make([]any, 0, len(keys)+4)
+		for i := range keys {
+			dest = append(dest, &keys[i])
+		}
+		if err := rows.Scan(append(dest, &a.Count, &a.Avg, &a.Min, &a.Max)...); err != nil {
+			return nil, fmt.Errorf("failed to scan aggregate row: %w", err)
+		}
+
+		a.Group = make(map[string]any, len(keys))
+		for i, key := range keys {
+			a.Group[opts.GroupBy[i]] = groupValue(key, types[i])
+		}
+		aggregates = append(aggregates, a)
+	}
+	return aggregates, rows.Err()
+}
+
+// groupValue converts a grouped column as scanned from SQLite to the
+// field's JSON type
+func groupValue(v any, t filter.Type) any {
+	switch v := v.(type) {
+	case []byte:
+		return string(v)
+	case int64:
+		if t == filter.Bool {
+			return v != 0
+		}
+	}
+	return v
+}
@@ -0,0 +1,63 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/mojotx/apt-eval/models"
+)
+
+// GetAISummary returns an apartment's stored summary and the hash of the
+// input it was generated from, or nil if there is none
+func (db *DB) GetAISummary(apartmentID int64) (*models.AISummary, string, error) {
+	s := models.AISummary{ApartmentID: apartmentID}
+	var hash, pros, cons string
+	err := db.QueryRow(`
+		SELECT input_hash, provider, model, summary, pros, cons, generated_at
+		FROM ai_summaries WHERE apartment_id = ?`, apartmentID,
+	).Scan(&hash, &s.Provider, &s.Model, &s.Summary, &pros, &cons, &s.GeneratedAt)
+	if err == sql.ErrNoRows {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get summary: %w", err)
+	}
+	if err := json.Unmarshal([]byte(pros), &s.Pros); err != nil {
+		return nil, "", fmt.Errorf("failed to decode summary pros: %w", err)
+	}
+	if err := json.Unmarshal([]byte(cons), &s.Cons); err != nil {
+		return nil, "", fmt.Errorf("failed to decode summary cons: %w", err)
+	}
+	return &s, hash, nil
+}
+
+// SaveAISummary stores an apartment's summary, replacing any earlier one
+func (db *DB) SaveAISummary(s *models.AISummary, inputHash string) error {
+	pros, err := json.Marshal(s.Pros)
+	if err != nil {
+		return fmt.Errorf("failed to encode summary pros: %w", err)
+	}
+	cons, err := json.Marshal(s.Cons)
+	if err != nil {
+		return fmt.Errorf("failed to encode summary cons: %w", err)
+	}
+
+	_, err = db.Exec(`
+		INSERT INTO ai_summaries (apartment_id, input_hash, provider, model, summary, pros, cons, generated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (apartment_id) DO UPDATE SET
+		    input_hash = excluded.input_hash,
+		    provider = excluded.provider,
+		    model = excluded.model,
+		    summary = excluded.summary,
+		    pros = excluded.pros,
+		    cons = excluded.cons,
+		    generated_at = excluded.generated_at`,
+		s.ApartmentID, inputHash, s.Provider, s.Model, s.Summary, string(pros), string(cons), s.GeneratedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save summary: %w", err)
+	}
+	return nil
+}
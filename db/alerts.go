@@ -0,0 +1,232 @@
+package db
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/mojotx/apt-eval/filter"
+	"github.com/mojotx/apt-eval/models"
+)
+
+// alertTTL is how long an alert stays worth sending
+const alertTTL = 24 * time.Hour
+
+const selectAlertRulesQuery = `
+	SELECT id, user_id, name, kind, price, filter, last_apartment_id, last_fired_at, created_at
+	FROM alert_rules`
+
+func scanAlertRule(row scanner) (*models.AlertRule, error) {
+	var r models.AlertRule
+	err := row.Scan(&r.ID, &r.UserID, &r.Name, &r.Kind, &r.Price, &r.Filter, &r.LastApartmentID, &r.LastFiredAt, &r.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// ListAlertRules returns a user's alert rules, or everyone's for userID 0
+func (db *DB) ListAlertRules(userID int64) ([]models.AlertRule, error) {
+	rows, err := db.Query(selectAlertRulesQuery+" WHERE ? = 0 OR user_id = ? ORDER BY id", userID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list alert rules: %w", err)
+	}
+	defer rows.Close()
+
+	rules := []models.AlertRule{}
+	for rows.Next() {
+		r, err := scanAlertRule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan alert rule row: %w", err)
+		}
+		rules = append(rules, *r)
+	}
+	return rules, rows.Err()
+}
+
+// CreateAlertRule saves a new alert rule for a user. It only goes off for
+// what happens from now on: apartments already below a price alert's
+// threshold, or already matching a new-match rule, are taken as seen.
+func (db *DB) CreateAlertRule(userID int64, req *models.AlertRuleRequest) (*models.AlertRule, error) {
+	rule := &models.AlertRule{UserID: userID, Name: req.Name, Kind: req.Kind, Filter: req.Filter}
+	if req.Kind == models.AlertPriceBelow {
+		rule.Price = req.Price
+	}
+	seen, newest, err := db.AlertMatches(rule)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rule, err = scanAlertRule(tx.QueryRow(`
+		INSERT INTO alert_rules (user_id, name, kind, price, filter, last_apartment_id)
+		VALUES (?, ?, ?, ?, ?, ?)
+		RETURNING id, user_id, name, kind, price, filter, last_apartment_id, last_fired_at, created_at`,
+		userID, rule.Name, rule.Kind, rule.Price, rule.Filter, newest,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create alert rule: %w", err)
+	}
+	if err := recordAlertHits(tx, rule, seen); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit alert rule: %w", err)
+	}
+	return rule, nil
+}
+
+// DeleteAlertRule removes one of a user's alert rules, returning
+// ErrNotFound if they have no such rule
+func (db *DB) DeleteAlertRule(userID, id int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("DELETE FROM alert_rules WHERE id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete alert rule: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	if _, err := tx.Exec("DELETE FROM alert_hits WHERE rule_id = ?", id); err != nil {
+		return fmt.Errorf("failed to clear alert hits: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit alert rule deletion: %w", err)
+	}
+	return nil
+}
+
+// AlertMatches returns the apartments that set off a rule, out of those
+// its user can see: for a price alert, those below its threshold that
+// haven't set it off at that price or lower; for a new-match rule, those
+// added since it last looked. newest is the newest apartment now, for
+// QueueAlert to remember.
+func (db *DB) AlertMatches(rule *models.AlertRule) (matches []models.Apartment, newest int64, err error) {
+	if err := db.QueryRow("SELECT COALESCE(MAX(id), 0) FROM apartments").Scan(&newest); err != nil {
+		return nil, 0, fmt.Errorf("failed to get the newest apartment: %w", err)
+	}
+
+	var condition string
+	switch rule.Kind {
+	case models.AlertPriceBelow:
+		if rule.Price == nil {
+			return nil, newest, nil
+		}
+		condition = "price < " + strconv.FormatFloat(*rule.Price, 'f', -1, 64)
+	case models.AlertNewMatch:
+		if newest <= rule.LastApartmentID {
+			return nil, newest, nil
+		}
+		condition = fmt.Sprintf("id > %d AND id <= %d", rule.LastApartmentID, newest)
+	default:
+		return nil, newest, fmt.Errorf("unknown alert rule kind %q", rule.Kind)
+	}
+	q := condition
+	if rule.Filter != "" {
+		q = "(" + rule.Filter + ") AND " + condition
+	}
+	node, err := filter.Parse(q, ApartmentFields)
+	if err != nil {
+		return nil, newest, fmt.Errorf("alert rule %d: invalid filter: %w", rule.ID, err)
+	}
+
+	candidates, err := db.ListApartments(ListOptions{Filter: node, Viewer: rule.UserID})
+	if err != nil {
+		return nil, newest, err
+	}
+	if rule.Kind != models.AlertPriceBelow {
+		return candidates, newest, nil
+	}
+
+	// Price alerts go off again only when the price drops further
+	hits := map[int64]float64{}
+	rows, err := db.Query("SELECT apartment_id, price FROM alert_hits WHERE rule_id = ?", rule.ID)
+	if err != nil {
+		return nil, newest, fmt.Errorf("failed to list alert hits: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		var price float64
+		if err := rows.Scan(&id, &price); err != nil {
+			return nil, newest, fmt.Errorf("failed to scan alert hit row: %w", err)
+		}
+		hits[id] = price
+	}
+	if err := rows.Err(); err != nil {
+		return nil, newest, err
+	}
+	for _, a := range candidates {
+		if hit, ok := hits[a.ID]; !ok || *a.Price < hit {
+			matches = append(matches, a)
+		}
+	}
+	return matches, newest, nil
+}
+
+// QueueAlert queues body for the rule's user, unless it's empty, and
+// remembers what set the rule off and the newest apartment it has looked
+// at, so the same apartments don't set it off again
+func (db *DB) QueueAlert(rule *models.AlertRule, matches []models.Apartment, newest int64, body string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if body != "" {
+		_, err := tx.Exec(`
+			INSERT INTO notifications (user_id, event, body, expires_at)
+			SELECT id, ?, ?, ? FROM users WHERE id = ? AND phone != ''`,
+			models.EventAlert, body, time.Now().UTC().Add(alertTTL), rule.UserID,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to enqueue alert: %w", err)
+		}
+		_, err = tx.Exec("UPDATE alert_rules SET last_fired_at = ? WHERE id = ?", time.Now().UTC(), rule.ID)
+		if err != nil {
+			return fmt.Errorf("failed to mark alert rule fired: %w", err)
+		}
+	}
+	if err := recordAlertHits(tx, rule, matches); err != nil {
+		return err
+	}
+	if newest > rule.LastApartmentID {
+		if _, err := tx.Exec("UPDATE alert_rules SET last_apartment_id = ? WHERE id = ?", newest, rule.ID); err != nil {
+			return fmt.Errorf("failed to update alert rule: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit alert: %w", err)
+	}
+	return nil
+}
+
+// recordAlertHits remembers the price each apartment set off a price
+// alert at
+func recordAlertHits(q queryer, rule *models.AlertRule, matches []models.Apartment) error {
+	if rule.Kind != models.AlertPriceBelow {
+		return nil
+	}
+	for _, a := range matches {
+		_, err := q.Exec(`
+			INSERT INTO alert_hits (rule_id, apartment_id, price) VALUES (?, ?, ?)
+			ON CONFLICT (rule_id, apartment_id) DO UPDATE SET price = excluded.price, created_at = CURRENT_TIMESTAMP`,
+			rule.ID, a.ID, *a.Price,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to record alert hit: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/mojotx/apt-eval/models"
+)
+
+// UnknownAmenityError is returned when a request names an amenity that
+// isn't in the taxonomy
+type UnknownAmenityError struct {
+	Key string
+}
+
+func (e *UnknownAmenityError) Error() string {
+	return fmt.Sprintf("unknown amenity %q", e.Key)
+}
+
+// hasAmenity is a SQL expression that is true when the apartment row has
+// the amenity; it matches the boolean columns in select.sql
+func hasAmenity(key string) string {
+	return `EXISTS (
+		SELECT 1 FROM unit_amenities aa JOIN amenities am ON am.id = aa.amenity_id
+		WHERE aa.apartment_id = apartments.id AND am.key = '` + key + `')`
+}
+
+// amenityList is a SQL expression listing the apartment row's amenity
+// keys, comma-separated; it matches the amenities column in select.sql
+const amenityList = `(
+	SELECT group_concat(am.key, ',' ORDER BY am.key)
+	FROM unit_amenities aa JOIN amenities am ON am.id = aa.amenity_id
+	WHERE aa.apartment_id = apartments.id)`
+
+// splitAmenities decodes the amenities column
+func splitAmenities(s sql.NullString) []string {
+	if !s.Valid || s.String == "" {
+		return []string{}
+	}
+	return strings.Split(s.String, ",")
+}
+
+// ListAmenities returns the amenity taxonomy
+func (db *DB) ListAmenities() ([]models.Amenity, error) {
+	rows, err := db.Query("SELECT id, key, name, created_at FROM amenities ORDER BY key")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list amenities: %w", err)
+	}
+	defer rows.Close()
+
+	amenities := []models.Amenity{}
+	for rows.Next() {
+		var a models.Amenity
+		if err := rows.Scan(&a.ID, &a.Key, &a.Name, &a.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan amenity row: %w", err)
+		}
+		amenities = append(amenities, a)
+	}
+	return amenities, rows.Err()
+}
+
+// CreateAmenity adds an amenity to the taxonomy. It returns nil if the key
+// is already taken.
+func (db *DB) CreateAmenity(req *models.AmenityRequest) (*models.Amenity, error) {
+	var a models.Amenity
+	err := db.QueryRow(`
+		INSERT INTO amenities (key, name) VALUES (?, ?)
+		ON CONFLICT (key) DO NOTHING
+		RETURNING id, key, name, created_at`,
+		req.Key, req.Name,
+	).Scan(&a.ID, &a.Key, &a.Name, &a.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create amenity: %w", err)
+	}
+	if err := indexTerms(db, models.SuggestAmenity, a.ID, a.Name); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// setApartmentAmenities stores the amenities from req. A nil Amenities
+// list leaves amenities without a boolean field untouched, so clients
+// that only know the boolean fields don't erase the others.
+func setApartmentAmenities(tx *sql.Tx, apartmentID int64, req *models.ApartmentRequest) error {
+	legacy := req.LegacyAmenities()
+
+	keys := map[string]bool{}
+	for _, k := range req.Amenities {
+		if k != models.AmenityGarage { // Stored as parking; see applyParking
+			keys[k] = true
+		}
+	}
+	for k, set := range legacy {
+		if set {
+			keys[k] = true
+		}
+	}
+
+	clear := "DELETE FROM apartment_amenities WHERE apartment_id = ?"
+	if req.Amenities == nil {
+		clear += ` AND amenity_id IN (SELECT id FROM amenities WHERE key IN ('` +
+			models.AmenityGated + `', '` + models.AmenityLaundry + `'))`
+	}
+	if _, err := tx.Exec(clear, apartmentID); err != nil {
+		return fmt.Errorf("failed to clear amenities: %w", err)
+	}
+
+	for k := range keys {
+		result, err := tx.Exec(`
+			INSERT OR IGNORE INTO apartment_amenities (apartment_id, amenity_id)
+			SELECT ?, id FROM amenities WHERE key = ?`,
+			apartmentID, k,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to set amenity: %w", err)
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			var exists bool
+			if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM amenities WHERE key = ?)", k).Scan(&exists); err != nil {
+				return fmt.Errorf("failed to look up amenity: %w", err)
+			}
+			if !exists {
+				return &UnknownAmenityError{Key: k}
+			}
+		}
+	}
+	return nil
+}
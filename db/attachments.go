@@ -0,0 +1,208 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/mojotx/apt-eval/models"
+)
+
+const selectAttachmentsQuery = `
+	SELECT id, apartment_id, kind, filename, caption, content_type, size, width, height, pages,
+	       scan_status, scan_signature, scanned_at, sort_order, is_cover, latitude, longitude, uploaded_at
+	FROM attachments`
+
+// UnknownAttachmentError is returned when a gallery is reordered with an
+// attachment the apartment doesn't have
+type UnknownAttachmentError struct {
+	ID int64
+}
+
+func (e *UnknownAttachmentError) Error() string {
+	return fmt.Sprintf("apartment has no attachment %d", e.ID)
+}
+
+func scanAttachment(row scanner) (*models.Attachment, error) {
+	var a models.Attachment
+	var lat, lng *float64
+	err := row.Scan(&a.ID, &a.ApartmentID, &a.Kind, &a.Filename, &a.Caption, &a.ContentType, &a.Size,
+		&a.Width, &a.Height, &a.Pages, &a.ScanStatus, &a.ScanSignature, &a.ScannedAt, &a.SortOrder, &a.IsCover, &lat, &lng, &a.UploadedAt)
+	if err != nil {
+		return nil, err
+	}
+	if lat != nil && lng != nil {
+		a.Location = &models.PhotoLocation{Latitude: *lat, Longitude: *lng}
+	}
+	return &a, nil
+}
+
+// ListAttachments returns an apartment's photos and documents in gallery
+// order
+func (db *DB) ListAttachments(apartmentID int64) ([]models.Attachment, error) {
+	return queryAttachments(db, apartmentID)
+}
+
+func queryAttachments(q queryer, apartmentID int64) ([]models.Attachment, error) {
+	rows, err := q.Query(selectAttachmentsQuery+" WHERE apartment_id = ? ORDER BY sort_order, id", apartmentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list attachments: %w", err)
+	}
+	defer rows.Close()
+
+	attachments := []models.Attachment{}
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan attachment row: %w", err)
+		}
+		attachments = append(attachments, *a)
+	}
+	return attachments, rows.Err()
+}
+
+// GetAttachment retrieves one of an apartment's attachments, or nil if it
+// doesn't exist
+func (db *DB) GetAttachment(apartmentID, id int64) (*models.Attachment, error) {
+	a, err := scanAttachment(db.QueryRow(selectAttachmentsQuery+" WHERE apartment_id = ? AND id = ?", apartmentID, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get attachment: %w", err)
+	}
+	return a, nil
+}
+
+// CountAttachments returns how many attachments an apartment has,
+// quarantined ones included
+func (db *DB) CountAttachments(apartmentID int64) (int, error) {
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM attachments WHERE apartment_id = ?", apartmentID).Scan(&n); err != nil {
+		return 0, fmt.Errorf("failed to count attachments: %w", err)
+	}
+	return n, nil
+}
+
+// CreateAttachment records an uploaded file at the end of the apartment's
+// gallery, returning it with its ID so the file can be stored under it
+func (db *DB) CreateAttachment(a *models.Attachment) (*models.Attachment, error) {
+	var lat, lng *float64
+	if a.Location != nil {
+		lat, lng = &a.Location.Latitude, &a.Location.Longitude
+	}
+
+	var id int64
+	err := db.QueryRow(`
+		INSERT INTO attachments (apartment_id, kind, filename, caption, content_type, size, width, height, pages,
+		                         scan_status, scan_signature, scanned_at, latitude, longitude, sort_order)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?,
+		        (SELECT COALESCE(MAX(sort_order), -1) + 1 FROM attachments WHERE apartment_id = ?))
+		RETURNING id`,
+		a.ApartmentID, a.Kind, a.Filename, a.Caption, a.ContentType, a.Size, a.Width, a.Height, a.Pages,
+		a.ScanStatus, a.ScanSignature, a.ScannedAt, lat, lng, a.ApartmentID,
+	).Scan(&id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create attachment: %w", err)
+	}
+
+	db.changed()
+	return db.GetAttachment(a.ApartmentID, id)
+}
+
+// UpdateAttachmentCaption sets an attachment's caption, returning nil if
+// it doesn't exist
+func (db *DB) UpdateAttachmentCaption(apartmentID, id int64, caption string) (*models.Attachment, error) {
+	result, err := db.Exec("UPDATE attachments SET caption = ? WHERE apartment_id = ? AND id = ?", caption, apartmentID, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update attachment: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, nil
+	}
+	return db.GetAttachment(apartmentID, id)
+}
+
+// DeleteAttachment removes an attachment's record
+func (db *DB) DeleteAttachment(apartmentID, id int64) error {
+	result, err := db.Exec("DELETE FROM attachments WHERE apartment_id = ? AND id = ?", apartmentID, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete attachment: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+
+	db.changed()
+	return nil
+}
+
+// ReorderAttachments places the listed attachments first in an apartment's
+// gallery, in the given order, followed by the rest in their current order
+func (db *DB) ReorderAttachments(apartmentID int64, ids []int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query("SELECT id FROM attachments WHERE apartment_id = ? ORDER BY sort_order, id", apartmentID)
+	if err != nil {
+		return fmt.Errorf("failed to list attachments: %w", err)
+	}
+	var current []int64
+	owned := map[int64]bool{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan attachment row: %w", err)
+		}
+		current = append(current, id)
+		owned[id] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error during row iteration: %w", err)
+	}
+
+	var order []int64
+	placed := map[int64]bool{}
+	for _, id := range ids {
+		if !owned[id] {
+			return &UnknownAttachmentError{ID: id}
+		}
+		if !placed[id] {
+			order = append(order, id)
+			placed[id] = true
+		}
+	}
+	for _, id := range current {
+		if !placed[id] {
+			order = append(order, id)
+		}
+	}
+
+	for i, id := range order {
+		if _, err := tx.Exec("UPDATE attachments SET sort_order = ? WHERE id = ?", i, id); err != nil {
+			return fmt.Errorf("failed to set attachment order: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit attachment order: %w", err)
+	}
+
+	db.changed()
+	return nil
+}
+
+// SetCoverPhoto makes a photo the apartment's cover, replacing the previous
+// one
+func (db *DB) SetCoverPhoto(apartmentID, id int64) error {
+	_, err := db.Exec("UPDATE attachments SET is_cover = (id = ?) WHERE apartment_id = ?", id, apartmentID)
+	if err != nil {
+		return fmt.Errorf("failed to set cover photo: %w", err)
+	}
+
+	db.changed()
+	return nil
+}
@@ -0,0 +1,65 @@
+package db
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// backupPrefix names backup files so they can be found for pruning
+const backupPrefix = "apartments-"
+
+// Backup writes a consistent copy of the database into dir and removes all
+// but the newest keep backups. It returns the path of the new backup.
+func (db *DB) Backup(dir string, keep int) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	path := filepath.Join(dir, backupPrefix+time.Now().UTC().Format("20060102-150405")+".db")
+	if _, err := db.Exec("VACUUM INTO ?", path); err != nil {
+		return "", fmt.Errorf("failed to back up database: %w", err)
+	}
+
+	if err := pruneBackups(dir, keep); err != nil {
+		return path, err
+	}
+	return path, nil
+}
+
+// pruneBackups deletes the oldest backups in dir beyond the newest keep
+func pruneBackups(dir string, keep int) error {
+	if keep <= 0 {
+		return nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read backup directory: %w", err)
+	}
+
+	var backups []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasPrefix(e.Name(), backupPrefix) && strings.HasSuffix(e.Name(), ".db") {
+			backups = append(backups, e.Name())
+		}
+	}
+	if len(backups) <= keep {
+		return nil
+	}
+
+	// Timestamped names sort chronologically
+	sort.Strings(backups)
+	for _, name := range backups[:len(backups)-keep] {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			return fmt.Errorf("failed to remove old backup: %w", err)
+		}
+		log.Info().Str("file", name).Msg("Removed old backup")
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package db
+
+import (
+	"fmt"
+
+	"github.com/mojotx/apt-eval/models"
+)
+
+// NotInColumnError is returned when a board column is reordered with an
+// apartment that isn't in it
+type NotInColumnError struct {
+	ID     int64
+	Status string
+}
+
+func (e *NotInColumnError) Error() string {
+	return fmt.Sprintf("apartment %d is not %s", e.ID, e.Status)
+}
+
+// boardOrder sorts apartments into their manual board order
+var boardOrder = []SortField{{Column: "board_position"}}
+
+// appendToBoard places an apartment at the end of its status column, as
+// when it's added or moved to another column
+func appendToBoard(q queryer, id int64) error {
+	_, err := q.Exec(`
+		UPDATE apartments
+		SET board_position = (
+			SELECT COALESCE(MAX(b.board_position), -1) + 1 FROM apartments b
+			WHERE b.status = apartments.status AND b.id != apartments.id
+		)
+		WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to place apartment on board: %w", err)
+	}
+	return nil
+}
+
+// BoardApartments returns every apartment viewer may see in board order
+// within its status
+func (db *DB) BoardApartments(viewer int64) ([]models.Apartment, error) {
+	return db.ListApartments(ListOptions{Sort: boardOrder, Viewer: viewer})
+}
+
+// ReorderBoardColumn places the listed apartments first in their status
+// column, in the given order, followed by the rest of the column in its
+// current order
+func (db *DB) ReorderBoardColumn(status string, ids []int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query("SELECT id FROM apartments WHERE status = ? ORDER BY "+orderBy(boardOrder), status)
+	if err != nil {
+		return fmt.Errorf("failed to list board column: %w", err)
+	}
+	var current []int64
+	inColumn := map[int64]bool{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan board column row: %w", err)
+		}
+		current = append(current, id)
+		inColumn[id] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error during row iteration: %w", err)
+	}
+
+	var order []int64
+	placed := map[int64]bool{}
+	for _, id := range ids {
+		if !inColumn[id] {
+			return &NotInColumnError{ID: id, Status: status}
+		}
+		if !placed[id] {
+			order = append(order, id)
+			placed[id] = true
+		}
+	}
+	for _, id := range current {
+		if !placed[id] {
+			order = append(order, id)
+		}
+	}
+
+	for i, id := range order {
+		if _, err := tx.Exec("UPDATE apartments SET board_position = ? WHERE id = ?", i, id); err != nil {
+			return fmt.Errorf("failed to set board position: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit board order: %w", err)
+	}
+
+	db.changed()
+	return nil
+}
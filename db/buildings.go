@@ -0,0 +1,255 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/mojotx/apt-eval/address"
+	"github.com/mojotx/apt-eval/models"
+)
+
+// UnknownBuildingError is returned when an apartment is linked to a
+// building that doesn't exist
+type UnknownBuildingError struct {
+	ID int64
+}
+
+func (e *UnknownBuildingError) Error() string {
+	return fmt.Sprintf("unknown building %d", e.ID)
+}
+
+const selectBuildingsQuery = `
+	SELECT id, name, address, canonical_address,
+	       management_company, management_contact, management_phone, management_email, notes,
+	       (
+	           SELECT group_concat(am.key, ',' ORDER BY am.key)
+	           FROM building_amenities ba JOIN amenities am ON am.id = ba.amenity_id
+	           WHERE ba.building_id = buildings.id
+	       ) AS amenities,
+	       (SELECT COUNT(*) FROM apartments WHERE building_id = buildings.id) AS unit_count,
+	       created_at, updated_at
+	FROM buildings`
+
+func (db *DB) scanBuilding(row scanner) (*models.Building, error) {
+	var b models.Building
+	var amenities sql.NullString
+	m := &b.Management
+	err := row.Scan(&b.ID, &b.Name, &b.Address, &b.CanonicalAddress,
+		&m.Company, &m.Contact, &m.Phone, &m.Email, &b.Notes,
+		&amenities, &b.UnitCount, &b.CreatedAt, &b.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	b.Amenities = splitAmenities(amenities)
+	if err := db.open(&m.Contact, &m.Phone, &m.Email, &b.Notes); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// ListBuildings returns the buildings ordered by address
+func (db *DB) ListBuildings() ([]models.Building, error) {
+	rows, err := db.Query(selectBuildingsQuery + " ORDER BY canonical_address, id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list buildings: %w", err)
+	}
+	defer rows.Close()
+
+	buildings := []models.Building{}
+	for rows.Next() {
+		b, err := db.scanBuilding(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan building row: %w", err)
+		}
+		buildings = append(buildings, *b)
+	}
+	return buildings, rows.Err()
+}
+
+// GetBuilding retrieves a building, or nil if it doesn't exist
+func (db *DB) GetBuilding(id int64) (*models.Building, error) {
+	b, err := db.scanBuilding(db.QueryRow(selectBuildingsQuery+" WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get building: %w", err)
+	}
+	return b, nil
+}
+
+// CreateBuilding adds a building
+func (db *DB) CreateBuilding(req *models.BuildingRequest) (*models.Building, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var id int64
+	m := req.Management
+	err = tx.QueryRow(`
+		INSERT INTO buildings (name, address, canonical_address,
+		                       management_company, management_contact, management_phone, management_email, notes)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`,
+		req.Name, req.Address, address.Normalize(req.Address),
+		m.Company, db.seal(m.Contact), db.seal(m.Phone), db.seal(m.Email), db.seal(req.Notes),
+	).Scan(&id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create building: %w", err)
+	}
+	if err := setBuildingAmenities(tx, id, req.Amenities); err != nil {
+		return nil, err
+	}
+	if err := indexTerms(tx, models.SuggestBuilding, id, req.Name); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit building: %w", err)
+	}
+	return db.GetBuilding(id)
+}
+
+// UpdateBuilding modifies a building, returning nil if it doesn't exist.
+// Its amenities are replaced, which changes those of its units.
+func (db *DB) UpdateBuilding(id int64, req *models.BuildingRequest) (*models.Building, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	m := req.Management
+	result, err := tx.Exec(`
+		UPDATE buildings
+		SET name = ?, address = ?, canonical_address = ?,
+		    management_company = ?, management_contact = ?, management_phone = ?, management_email = ?,
+		    notes = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?`,
+		req.Name, req.Address, address.Normalize(req.Address),
+		m.Company, db.seal(m.Contact), db.seal(m.Phone), db.seal(m.Email), db.seal(req.Notes), id,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update building: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, nil
+	}
+	if err := setBuildingAmenities(tx, id, req.Amenities); err != nil {
+		return nil, err
+	}
+	if err := indexTerms(tx, models.SuggestBuilding, id, req.Name); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit building: %w", err)
+	}
+
+	db.changed()
+	return db.GetBuilding(id)
+}
+
+// DeleteBuilding removes a building, leaving its units without one
+func (db *DB) DeleteBuilding(id int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("DELETE FROM buildings WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete building: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	if _, err := tx.Exec("DELETE FROM building_amenities WHERE building_id = ?", id); err != nil {
+		return fmt.Errorf("failed to clear building amenities: %w", err)
+	}
+	if _, err := tx.Exec("UPDATE apartments SET building_id = NULL WHERE building_id = ?", id); err != nil {
+		return fmt.Errorf("failed to unlink units: %w", err)
+	}
+	if err := unindexTerms(tx, models.SuggestBuilding, id); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit building deletion: %w", err)
+	}
+
+	db.changed()
+	return nil
+}
+
+// setBuildingAmenities replaces a building's amenities, dropping them from
+// its units so each is stored once
+func setBuildingAmenities(tx *sql.Tx, buildingID int64, keys []string) error {
+	if _, err := tx.Exec("DELETE FROM building_amenities WHERE building_id = ?", buildingID); err != nil {
+		return fmt.Errorf("failed to clear building amenities: %w", err)
+	}
+	for _, k := range keys {
+		result, err := tx.Exec(`
+			INSERT OR IGNORE INTO building_amenities (building_id, amenity_id)
+			SELECT ?, id FROM amenities WHERE key = ?`,
+			buildingID, k,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to set building amenity: %w", err)
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			var exists bool
+			if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM amenities WHERE key = ?)", k).Scan(&exists); err != nil {
+				return fmt.Errorf("failed to look up amenity: %w", err)
+			}
+			if !exists {
+				return &UnknownAmenityError{Key: k}
+			}
+		}
+	}
+
+	_, err := tx.Exec(`
+		DELETE FROM apartment_amenities
+		WHERE apartment_id IN (SELECT id FROM apartments WHERE building_id = ?)
+		  AND amenity_id IN (SELECT amenity_id FROM building_amenities WHERE building_id = ?)`,
+		buildingID, buildingID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to drop shared amenities: %w", err)
+	}
+	return nil
+}
+
+// applyBuilding links the apartment to the building in req, or unlinks it
+// when the ID is 0. Amenities the building provides are dropped from the
+// apartment's own, so it must run after setApartmentAmenities.
+func applyBuilding(tx *sql.Tx, apartmentID int64, req *models.ApartmentRequest) error {
+	if req.BuildingID != nil {
+		var buildingID *int64
+		if *req.BuildingID != 0 {
+			var exists bool
+			if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM buildings WHERE id = ?)", *req.BuildingID).Scan(&exists); err != nil {
+				return fmt.Errorf("failed to look up building: %w", err)
+			}
+			if !exists {
+				return &UnknownBuildingError{ID: *req.BuildingID}
+			}
+			buildingID = req.BuildingID
+		}
+		if _, err := tx.Exec("UPDATE apartments SET building_id = ? WHERE id = ?", buildingID, apartmentID); err != nil {
+			return fmt.Errorf("failed to set building: %w", err)
+		}
+	}
+
+	_, err := tx.Exec(`
+		DELETE FROM apartment_amenities
+		WHERE apartment_id = ? AND amenity_id IN (
+			SELECT ba.amenity_id FROM building_amenities ba
+			JOIN apartments a ON a.building_id = ba.building_id
+			WHERE a.id = ?)`,
+		apartmentID, apartmentID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to drop shared amenities: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/mojotx/apt-eval/metrics"
+)
+
+// A statement that finds the database locked by another connection is
+// tried again busyRetries times, waiting about busyBackoff, doubled after
+// each try, in between. With SQLite's own busy timeout on each try, a
+// write waits a couple of seconds in all before it fails.
+const (
+	busyRetries = 6
+	busyBackoff = 10 * time.Millisecond
+)
+
+// isBusy reports whether err is SQLite finding the database, or a table
+// in it, locked by another connection
+func isBusy(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && (sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked)
+}
+
+// retryBusy runs op, and runs it again with backoff for as long as it
+// finds the database locked, counting retries into metrics.DB. It gives up
+// once ctx is done, returning op's last error.
+func retryBusy(ctx context.Context, op func() error) error {
+	wait := busyBackoff
+	for attempt := 0; ; attempt++ {
+		err := op()
+		if !isBusy(err) {
+			return err
+		}
+		if attempt == busyRetries {
+			metrics.DB.Add("busy_failures", 1)
+			return err
+		}
+		metrics.DB.Add("busy_retries", 1)
+
+		// Jitter keeps two writers that collided from colliding again
+		timer := time.NewTimer(wait/2 + rand.N(wait))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			metrics.DB.Add("busy_failures", 1)
+			return err
+		case <-timer.C:
+		}
+		wait *= 2
+	}
+}
@@ -0,0 +1,112 @@
+package db
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/mojotx/apt-eval/cache"
+	"github.com/mojotx/apt-eval/metrics"
+	"github.com/mojotx/apt-eval/models"
+)
+
+// readCache keeps recently read apartments and list results in memory so
+// frequent polling by the UI doesn't hit SQLite. Any write purges it.
+type readCache struct {
+	mu         sync.Mutex
+	generation uint64
+	apartments *cache.LRU[int64, models.Apartment]
+	lists      *cache.LRU[string, []models.Apartment]
+}
+
+func newReadCache(size int) *readCache {
+	onEvict := func() { metrics.Cache.Add("evictions", 1) }
+	return &readCache{
+		apartments: cache.New[int64, models.Apartment](size, onEvict),
+		lists:      cache.New[string, []models.Apartment](size, onEvict),
+	}
+}
+
+// EnableCache turns on the in-memory read cache with room for size
+// apartments and size list results. A size of zero leaves caching off.
+func (db *DB) EnableCache(size int) {
+	if size <= 0 {
+		db.cache = nil
+		return
+	}
+	db.cache = newReadCache(size)
+}
+
+// gen returns the current cache generation. Readers capture it before
+// querying and pass it to store, so a result read concurrently with a
+// write is never cached.
+func (rc *readCache) gen() uint64 {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return rc.generation
+}
+
+// invalidate drops everything cached
+func (rc *readCache) invalidate() {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	rc.generation++
+	rc.apartments.Purge()
+	rc.lists.Purge()
+	metrics.Cache.Add("invalidations", 1)
+}
+
+func (rc *readCache) getApartment(id int64) (*models.Apartment, bool) {
+	apt, ok := rc.apartments.Get(id)
+	rc.count(ok)
+	if !ok {
+		return nil, false
+	}
+	return &apt, true
+}
+
+func (rc *readCache) storeApartment(gen uint64, apt *models.Apartment) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if gen == rc.generation {
+		rc.apartments.Add(apt.ID, *apt)
+	}
+}
+
+func (rc *readCache) getList(key string) ([]models.Apartment, bool) {
+	apartments, ok := rc.lists.Get(key)
+	rc.count(ok)
+	// Hand out a copy so callers can't modify the cached slice
+	return slices.Clone(apartments), ok
+}
+
+func (rc *readCache) storeList(gen uint64, key string, apartments []models.Apartment) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if gen == rc.generation {
+		rc.lists.Add(key, slices.Clone(apartments))
+	}
+}
+
+func (rc *readCache) count(hit bool) {
+	if hit {
+		metrics.Cache.Add("hits", 1)
+	} else {
+		metrics.Cache.Add("misses", 1)
+	}
+}
+
+// listCacheKey identifies a list query by its SQL and arguments
+func listCacheKey(query string, args []any) string {
+	return fmt.Sprintf("%s|%#v", query, args)
+}
+
+// changed records that apartment data was written, invalidating any
+// cached reads. Every method that modifies apartments or their related
+// records must call it.
+func (db *DB) changed() {
+	if db.cache != nil {
+		db.cache.invalidate()
+	}
+}
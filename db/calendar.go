@@ -0,0 +1,227 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/mojotx/apt-eval/address"
+	"github.com/mojotx/apt-eval/models"
+)
+
+// GetCalendarConnection returns the connected calendar, or nil if there is
+// none
+func (db *DB) GetCalendarConnection() (*models.CalendarConnection, error) {
+	var c models.CalendarConnection
+	err := db.QueryRow(`
+		SELECT calendar_id, refresh_token, access_token, expires_at, sync_token, connected_at
+		FROM calendar_connection WHERE id = 1`,
+	).Scan(&c.CalendarID, &c.RefreshToken, &c.AccessToken, &c.ExpiresAt, &c.SyncToken, &c.ConnectedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get calendar connection: %w", err)
+	}
+	return &c, nil
+}
+
+// ConnectCalendar saves a newly authorized calendar, replacing any other.
+// Visits are linked to the events of the old calendar, so they're unlinked
+// and go onto the new one on the next sync.
+func (db *DB) ConnectCalendar(c *models.CalendarConnection) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := unlinkCalendar(tx); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		INSERT INTO calendar_connection (id, calendar_id, refresh_token, access_token, expires_at)
+		VALUES (1, ?, ?, ?, ?)`,
+		c.CalendarID, c.RefreshToken, c.AccessToken, c.ExpiresAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save calendar connection: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit calendar connection: %w", err)
+	}
+	return nil
+}
+
+// DisconnectCalendar forgets the connected calendar. Its events stay on
+// the calendar, no longer synced.
+func (db *DB) DisconnectCalendar() error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := unlinkCalendar(tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit calendar disconnection: %w", err)
+	}
+	return nil
+}
+
+// unlinkCalendar removes the connection and every link to its events
+func unlinkCalendar(q queryer) error {
+	if _, err := q.Exec("DELETE FROM calendar_connection"); err != nil {
+		return fmt.Errorf("failed to delete calendar connection: %w", err)
+	}
+	if _, err := q.Exec("DELETE FROM calendar_deletions"); err != nil {
+		return fmt.Errorf("failed to clear calendar deletions: %w", err)
+	}
+	if _, err := q.Exec("UPDATE visits SET calendar_event_id = NULL, calendar_hash = '', calendar_start = NULL WHERE calendar_event_id IS NOT NULL"); err != nil {
+		return fmt.Errorf("failed to unlink visits: %w", err)
+	}
+	return nil
+}
+
+// SaveCalendarToken records a refreshed access token
+func (db *DB) SaveCalendarToken(accessToken string, expiresAt time.Time) error {
+	_, err := db.Exec("UPDATE calendar_connection SET access_token = ?, expires_at = ? WHERE id = 1", accessToken, expiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to save calendar token: %w", err)
+	}
+	return nil
+}
+
+// SaveCalendarSyncToken records where the last pull of changes left off
+func (db *DB) SaveCalendarSyncToken(syncToken string) error {
+	if _, err := db.Exec("UPDATE calendar_connection SET sync_token = ? WHERE id = 1", syncToken); err != nil {
+		return fmt.Errorf("failed to save calendar sync token: %w", err)
+	}
+	return nil
+}
+
+const selectCalendarVisitsQuery = `
+	SELECT v.id, v.apartment_id, a.address, a.unit, v.visited_at, v.notes, a.visibility = 'private',
+	       v.calendar_event_id, v.calendar_hash, v.calendar_start
+	FROM visits v
+	JOIN apartments a ON a.id = v.apartment_id`
+
+func (db *DB) scanCalendarVisit(row scanner) (*models.CalendarVisit, error) {
+	var v models.CalendarVisit
+	var unit string
+	err := row.Scan(&v.VisitID, &v.ApartmentID, &v.Address, &unit, &v.VisitedAt, &v.Notes, &v.Private, &v.EventID, &v.Hash, &v.EventStart)
+	if err != nil {
+		return nil, err
+	}
+	v.Address = address.JoinUnit(v.Address, unit)
+	if err := db.open(&v.Notes); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// CalendarVisits returns the visits from since on that belong on the
+// calendar, or were put there but belong there no longer
+func (db *DB) CalendarVisits(since time.Time) ([]models.CalendarVisit, error) {
+	rows, err := db.Query(selectCalendarVisitsQuery+`
+		WHERE v.visited_at >= ? AND (v.calendar_event_id IS NULL OR v.calendar_event_id != '')
+		ORDER BY v.visited_at, v.id`, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list calendar visits: %w", err)
+	}
+	defer rows.Close()
+
+	visits := []models.CalendarVisit{}
+	for rows.Next() {
+		v, err := db.scanCalendarVisit(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan calendar visit row: %w", err)
+		}
+		visits = append(visits, *v)
+	}
+	return visits, rows.Err()
+}
+
+// GetCalendarVisitByEvent returns the visit linked to a calendar event, or
+// nil if there is none
+func (db *DB) GetCalendarVisitByEvent(eventID string) (*models.CalendarVisit, error) {
+	v, err := db.scanCalendarVisit(db.QueryRow(selectCalendarVisitsQuery+" WHERE v.calendar_event_id = ?", eventID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get calendar visit: %w", err)
+	}
+	return v, nil
+}
+
+// LinkCalendarEvent records a visit's calendar event along with the hash
+// and start of the event as written. An empty ID keeps the visit off the
+// calendar for good, and nil puts it back on.
+func (db *DB) LinkCalendarEvent(visitID int64, eventID *string, hash string, start *time.Time) error {
+	_, err := db.Exec(`
+		UPDATE visits SET calendar_event_id = ?, calendar_hash = ?, calendar_start = ?
+		WHERE id = ?`,
+		eventID, hash, start, visitID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to link calendar event: %w", err)
+	}
+	return nil
+}
+
+// MoveVisit changes when a visit happens after its calendar event was
+// moved there, recording the hash of the event as the app would write it
+// so the move isn't pushed back
+func (db *DB) MoveVisit(visitID int64, visitedAt time.Time, hash string) error {
+	// As in UpdateVisit, moving a visit clears its reminder
+	_, err := db.Exec(`
+		UPDATE visits
+		SET visited_at = ?, calendar_start = ?, calendar_hash = ?,
+		    reminded_at = CASE WHEN datetime(visited_at) = datetime(?) THEN reminded_at END,
+		    updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?`,
+		visitedAt, visitedAt, hash, visitedAt, visitID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to move visit: %w", err)
+	}
+	if err := cancelVisitReminders(db, visitID); err != nil {
+		return err
+	}
+
+	db.changed()
+	return nil
+}
+
+// CalendarDeletions returns the events of deleted visits still on the
+// calendar
+func (db *DB) CalendarDeletions() ([]string, error) {
+	rows, err := db.Query("SELECT event_id FROM calendar_deletions")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list calendar deletions: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan calendar deletion row: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// ClearCalendarDeletion records that a deleted visit's event is off the
+// calendar
+func (db *DB) ClearCalendarDeletion(eventID string) error {
+	if _, err := db.Exec("DELETE FROM calendar_deletions WHERE event_id = ?", eventID); err != nil {
+		return fmt.Errorf("failed to clear calendar deletion: %w", err)
+	}
+	return nil
+}
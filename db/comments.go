@@ -0,0 +1,304 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mojotx/apt-eval/models"
+)
+
+// mentionTTL is how long a mention stays worth sending
+const mentionTTL = 24 * time.Hour
+
+// mentionSnippet is how much of a comment a mention's text message quotes
+const mentionSnippet = 80
+
+const selectCommentsQuery = `
+	SELECT c.id, c.apartment_id, c.author_id, COALESCE(u.name, ''), c.body, c.created_at, c.updated_at
+	FROM comments c
+	LEFT JOIN users u ON u.id = c.author_id`
+
+func scanComment(row scanner) (*models.Comment, error) {
+	c := models.Comment{Mentions: []int64{}}
+	err := row.Scan(&c.ID, &c.ApartmentID, &c.AuthorID, &c.Author, &c.Body, &c.CreatedAt, &c.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// mentionedUsers returns the users a comment body mentions as @ followed by
+// their name, in any case. Where names overlap, as with "Sam" and
+// "Sam Lee", the longest one that fits wins.
+func mentionedUsers(body string, users []models.User) []int64 {
+	users = append([]models.User(nil), users...)
+	sort.SliceStable(users, func(i, j int) bool { return len(users[i].Name) > len(users[j].Name) })
+
+	seen := map[int64]bool{}
+	ids := []int64{}
+	for i := 0; i < len(body); i++ {
+		if body[i] != '@' {
+			continue
+		}
+		// Skip the @ in an email address
+		if r, _ := utf8.DecodeLastRuneInString(body[:i]); i > 0 && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			continue
+		}
+		rest := body[i+1:]
+		for _, u := range users {
+			name := strings.TrimSpace(u.Name)
+			if name == "" || len(rest) < len(name) || !strings.EqualFold(rest[:len(name)], name) {
+				continue
+			}
+			if r, _ := utf8.DecodeRuneInString(rest[len(name):]); unicode.IsLetter(r) || unicode.IsDigit(r) {
+				continue
+			}
+			if !seen[u.ID] {
+				seen[u.ID] = true
+				ids = append(ids, u.ID)
+			}
+			i += len(name)
+			break
+		}
+	}
+	return ids
+}
+
+// loadMentions fills in the mentions of comments
+func loadMentions(q queryer, comments []*models.Comment) error {
+	if len(comments) == 0 {
+		return nil
+	}
+	byID := make(map[int64]*models.Comment, len(comments))
+	args := make([]any, len(comments))
+	for i, c := range comments {
+		byID[c.ID] = c
+		args[i] = c.ID
+	}
+
+	rows, err := q.Query(`
+		SELECT comment_id, user_id FROM comment_mentions
+		WHERE comment_id IN (?`+strings.Repeat(", ?", len(comments)-1)+`)
+		ORDER BY user_id`, args...)
+	if err != nil {
+		return fmt.Errorf("failed to list mentions: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var commentID, userID int64
+		if err := rows.Scan(&commentID, &userID); err != nil {
+			return fmt.Errorf("failed to scan mention row: %w", err)
+		}
+		byID[commentID].Mentions = append(byID[commentID].Mentions, userID)
+	}
+	return rows.Err()
+}
+
+// ListComments returns an apartment's discussion thread, oldest first
+func (db *DB) ListComments(apartmentID int64) ([]models.Comment, error) {
+	rows, err := db.Query(selectCommentsQuery+" WHERE c.apartment_id = ? ORDER BY c.id", apartmentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list comments: %w", err)
+	}
+	defer rows.Close()
+
+	var thread []*models.Comment
+	for rows.Next() {
+		c, err := scanComment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan comment row: %w", err)
+		}
+		thread = append(thread, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during row iteration: %w", err)
+	}
+	rows.Close()
+
+	if err := loadMentions(db, thread); err != nil {
+		return nil, err
+	}
+	comments := make([]models.Comment, len(thread))
+	for i, c := range thread {
+		comments[i] = *c
+	}
+	return comments, nil
+}
+
+// GetComment retrieves one of an apartment's comments, or nil if it doesn't
+// exist
+func (db *DB) GetComment(apartmentID, id int64) (*models.Comment, error) {
+	c, err := scanComment(db.QueryRow(selectCommentsQuery+" WHERE c.apartment_id = ? AND c.id = ?", apartmentID, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get comment: %w", err)
+	}
+	if err := loadMentions(db, []*models.Comment{c}); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// CreateComment adds a comment to an apartment's thread, notifying the
+// users it mentions
+func (db *DB) CreateComment(apartmentID int64, req *models.CommentRequest) (*models.Comment, error) {
+	users, err := db.ListUsers()
+	if err != nil {
+		return nil, err
+	}
+	mentioned := mentionedUsers(req.Body, users)
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var id int64
+	err = tx.QueryRow(
+		"INSERT INTO comments (apartment_id, author_id, body) VALUES (?, ?, ?) RETURNING id",
+		apartmentID, req.AuthorID, req.Body,
+	).Scan(&id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create comment: %w", err)
+	}
+	if err := setMentions(tx, apartmentID, id, req, mentioned); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit comment: %w", err)
+	}
+	return db.GetComment(apartmentID, id)
+}
+
+// UpdateComment rewrites a comment, returning nil if it doesn't exist.
+// Only users the new text mentions for the first time are notified.
+func (db *DB) UpdateComment(apartmentID, id int64, req *models.CommentRequest) (*models.Comment, error) {
+	users, err := db.ListUsers()
+	if err != nil {
+		return nil, err
+	}
+	mentioned := mentionedUsers(req.Body, users)
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE comments SET body = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE apartment_id = ? AND id = ?`,
+		req.Body, apartmentID, id,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update comment: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, nil
+	}
+	if err := setMentions(tx, apartmentID, id, req, mentioned); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit comment: %w", err)
+	}
+	return db.GetComment(apartmentID, id)
+}
+
+// DeleteComment removes a comment
+func (db *DB) DeleteComment(apartmentID, id int64) error {
+	result, err := db.Exec("DELETE FROM comments WHERE apartment_id = ? AND id = ?", apartmentID, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete comment: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	if _, err := db.Exec("DELETE FROM comment_mentions WHERE comment_id = ?", id); err != nil {
+		return fmt.Errorf("failed to clear mentions: %w", err)
+	}
+	return nil
+}
+
+// setMentions records the users a comment mentions and queues a message
+// for each newly mentioned one who wants them and may see the apartment.
+// Authors aren't told about mentioning themselves.
+func setMentions(tx *sql.Tx, apartmentID, commentID int64, req *models.CommentRequest, mentioned []int64) error {
+	rows, err := tx.Query("SELECT user_id FROM comment_mentions WHERE comment_id = ?", commentID)
+	if err != nil {
+		return fmt.Errorf("failed to list mentions: %w", err)
+	}
+	before := map[int64]bool{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan mention row: %w", err)
+		}
+		before[id] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error during row iteration: %w", err)
+	}
+
+	if _, err := tx.Exec("DELETE FROM comment_mentions WHERE comment_id = ?", commentID); err != nil {
+		return fmt.Errorf("failed to clear mentions: %w", err)
+	}
+	var body string
+	for _, userID := range mentioned {
+		if _, err := tx.Exec("INSERT INTO comment_mentions (comment_id, user_id) VALUES (?, ?)", commentID, userID); err != nil {
+			return fmt.Errorf("failed to add mention: %w", err)
+		}
+		if before[userID] || (req.AuthorID != nil && *req.AuthorID == userID) {
+			continue
+		}
+
+		if body == "" {
+			if body, err = mentionMessage(tx, apartmentID, req); err != nil {
+				return err
+			}
+		}
+		_, err := tx.Exec(`
+			INSERT INTO notifications (user_id, event, body, expires_at)
+			SELECT users.id, ?, ?, ? FROM users JOIN apartments ON apartments.id = ?
+			WHERE users.id = ? AND notify_mentions AND phone != '' AND `+visibleToUser("users.id"),
+			models.EventMention, body, time.Now().UTC().Add(mentionTTL), apartmentID, userID,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to enqueue mention: %w", err)
+		}
+	}
+	return nil
+}
+
+// mentionMessage is the text message telling someone they were mentioned,
+// quoting the start of the comment
+func mentionMessage(q queryer, apartmentID int64, req *models.CommentRequest) (string, error) {
+	var address string
+	if err := q.QueryRow("SELECT address FROM apartments WHERE id = ?", apartmentID).Scan(&address); err != nil {
+		return "", fmt.Errorf("failed to read apartment address: %w", err)
+	}
+	author := "Someone"
+	if req.AuthorID != nil {
+		if err := q.QueryRow("SELECT name FROM users WHERE id = ?", *req.AuthorID).Scan(&author); err != nil {
+			return "", fmt.Errorf("failed to read comment author: %w", err)
+		}
+	}
+
+	quote := strings.Join(strings.Fields(req.Body), " ")
+	if r := []rune(quote); len(r) > mentionSnippet {
+		quote = string(r[:mentionSnippet]) + "…"
+	}
+	return fmt.Sprintf("%s mentioned you on %s: %q", author, address, quote), nil
+}
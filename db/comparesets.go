@@ -0,0 +1,180 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mojotx/apt-eval/models"
+)
+
+// UnknownApartmentError is returned when a record refers to an apartment
+// that doesn't exist
+type UnknownApartmentError struct {
+	ID int64
+}
+
+func (e *UnknownApartmentError) Error() string {
+	return fmt.Sprintf("unknown apartment %d", e.ID)
+}
+
+const selectCompareSetsQuery = `
+	SELECT id, name,
+	       (
+	           SELECT group_concat(csa.apartment_id, ',' ORDER BY csa.position)
+	           FROM compare_set_apartments csa JOIN apartments a ON a.id = csa.apartment_id
+	           WHERE csa.set_id = compare_sets.id
+	       ) AS apartment_ids,
+	       created_at, updated_at
+	FROM compare_sets`
+
+func scanCompareSet(row scanner) (*models.CompareSet, error) {
+	var s models.CompareSet
+	var ids sql.NullString
+	if err := row.Scan(&s.ID, &s.Name, &ids, &s.CreatedAt, &s.UpdatedAt); err != nil {
+		return nil, err
+	}
+	s.ApartmentIDs = []int64{}
+	if ids.String != "" {
+		for _, part := range strings.Split(ids.String, ",") {
+			id, err := strconv.ParseInt(part, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid apartment id %q in comparison set: %w", part, err)
+			}
+			s.ApartmentIDs = append(s.ApartmentIDs, id)
+		}
+	}
+	return &s, nil
+}
+
+// ListCompareSets returns the comparison sets by name
+func (db *DB) ListCompareSets() ([]models.CompareSet, error) {
+	rows, err := db.Query(selectCompareSetsQuery + " ORDER BY name, id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list comparison sets: %w", err)
+	}
+	defer rows.Close()
+
+	sets := []models.CompareSet{}
+	for rows.Next() {
+		s, err := scanCompareSet(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan comparison set row: %w", err)
+		}
+		sets = append(sets, *s)
+	}
+	return sets, rows.Err()
+}
+
+// GetCompareSet retrieves a comparison set, or nil if it doesn't exist.
+// Apartments deleted since it was saved are left out.
+func (db *DB) GetCompareSet(id int64) (*models.CompareSet, error) {
+	s, err := scanCompareSet(db.QueryRow(selectCompareSetsQuery+" WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get comparison set: %w", err)
+	}
+	return s, nil
+}
+
+// CreateCompareSet saves a comparison set
+func (db *DB) CreateCompareSet(req *models.CompareSetRequest) (*models.CompareSet, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var id int64
+	if err := tx.QueryRow("INSERT INTO compare_sets (name) VALUES (?) RETURNING id", req.Name).Scan(&id); err != nil {
+		return nil, fmt.Errorf("failed to create comparison set: %w", err)
+	}
+	if err := setCompareSetApartments(tx, id, req.ApartmentIDs); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit comparison set: %w", err)
+	}
+	return db.GetCompareSet(id)
+}
+
+// UpdateCompareSet renames a comparison set and replaces its apartments,
+// returning nil if it doesn't exist
+func (db *DB) UpdateCompareSet(id int64, req *models.CompareSetRequest) (*models.CompareSet, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("UPDATE compare_sets SET name = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?", req.Name, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update comparison set: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, nil
+	}
+	if err := setCompareSetApartments(tx, id, req.ApartmentIDs); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit comparison set: %w", err)
+	}
+	return db.GetCompareSet(id)
+}
+
+// DeleteCompareSet removes a comparison set. The apartments are kept.
+func (db *DB) DeleteCompareSet(id int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("DELETE FROM compare_sets WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete comparison set: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	if _, err := tx.Exec("DELETE FROM compare_set_apartments WHERE set_id = ?", id); err != nil {
+		return fmt.Errorf("failed to clear comparison set: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit comparison set deletion: %w", err)
+	}
+	return nil
+}
+
+// setCompareSetApartments replaces the apartments of a comparison set,
+// keeping their order and dropping repeats
+func setCompareSetApartments(tx *sql.Tx, setID int64, ids []int64) error {
+	if _, err := tx.Exec("DELETE FROM compare_set_apartments WHERE set_id = ?", setID); err != nil {
+		return fmt.Errorf("failed to clear comparison set: %w", err)
+	}
+	for i, id := range ids {
+		result, err := tx.Exec(`
+			INSERT INTO compare_set_apartments (set_id, apartment_id, position)
+			SELECT ?, id, ? FROM apartments WHERE id = ?
+			ON CONFLICT DO NOTHING`,
+			setID, i, id,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to add apartment to comparison set: %w", err)
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			var exists bool
+			if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM apartments WHERE id = ?)", id).Scan(&exists); err != nil {
+				return fmt.Errorf("failed to check apartment: %w", err)
+			}
+			if !exists {
+				return &UnknownApartmentError{ID: id}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/mojotx/apt-eval/models"
+)
+
+// GetMoveInCosts returns an apartment's move-in costs, all zero if none
+// have been set
+func (db *DB) GetMoveInCosts(apartmentID int64) (*models.MoveInCosts, error) {
+	m := models.MoveInCosts{ApartmentID: apartmentID}
+	err := db.QueryRow(`
+		SELECT security_deposit, pet_deposit, application_fee, admin_fee, broker_fee, other_fees,
+		       mover_estimate, monthly_fees, move_in_date
+		FROM move_in_costs WHERE apartment_id = ?`, apartmentID,
+	).Scan(&m.SecurityDeposit, &m.PetDeposit, &m.ApplicationFee, &m.AdminFee, &m.BrokerFee, &m.OtherFees,
+		&m.MoverEstimate, &m.MonthlyFees, &m.MoveInDate)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to get move-in costs: %w", err)
+	}
+	return &m, nil
+}
+
+// SetMoveInCosts replaces an apartment's move-in costs
+func (db *DB) SetMoveInCosts(apartmentID int64, req *models.MoveInCostsRequest) (*models.MoveInCosts, error) {
+	var moveIn *time.Time
+	if req.MoveInDate != nil && !req.MoveInDate.IsZero() {
+		moveIn = &req.MoveInDate.Time
+	}
+	_, err := db.Exec(`
+		INSERT INTO move_in_costs (apartment_id, security_deposit, pet_deposit, application_fee, admin_fee,
+		                           broker_fee, other_fees, mover_estimate, monthly_fees, move_in_date)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (apartment_id) DO UPDATE SET
+		    security_deposit = excluded.security_deposit, pet_deposit = excluded.pet_deposit,
+		    application_fee = excluded.application_fee, admin_fee = excluded.admin_fee,
+		    broker_fee = excluded.broker_fee, other_fees = excluded.other_fees,
+		    mover_estimate = excluded.mover_estimate, monthly_fees = excluded.monthly_fees,
+		    move_in_date = excluded.move_in_date, updated_at = CURRENT_TIMESTAMP`,
+		apartmentID, req.SecurityDeposit, req.PetDeposit, req.ApplicationFee, req.AdminFee,
+		req.BrokerFee, req.OtherFees, req.MoverEstimate, req.MonthlyFees, moveIn,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set move-in costs: %w", err)
+	}
+	return db.GetMoveInCosts(apartmentID)
+}
@@ -0,0 +1,56 @@
+package db
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// cursorTimeFormat matches the layout SQLite uses for CURRENT_TIMESTAMP, so
+// cursor values compare correctly against stored created_at text
+const cursorTimeFormat = "2006-01-02 15:04:05"
+
+// ErrInvalidCursor is returned when a pagination cursor cannot be decoded
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor marks a position in the (created_at DESC, id DESC) ordering used
+// for keyset pagination
+type Cursor struct {
+	CreatedAt time.Time
+	ID        int64
+}
+
+type cursorPayload struct {
+	CreatedAt string `json:"c"`
+	ID        int64  `json:"i"`
+}
+
+// Encode returns the opaque string form of the cursor
+func (c Cursor) Encode() string {
+	b, _ := json.Marshal(cursorPayload{
+		CreatedAt: c.CreatedAt.UTC().Format(cursorTimeFormat),
+		ID:        c.ID,
+	})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeCursor parses a cursor previously produced by Encode
+func DecodeCursor(s string) (*Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var p cursorPayload
+	if err := json.Unmarshal(b, &p); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	t, err := time.Parse(cursorTimeFormat, p.CreatedAt)
+	if err != nil || p.ID <= 0 {
+		return nil, ErrInvalidCursor
+	}
+
+	return &Cursor{CreatedAt: t, ID: p.ID}, nil
+}
@@ -6,17 +6,26 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"time"
+	"strings"
 
+	"github.com/mojotx/apt-eval/fieldcrypt"
+	"github.com/mojotx/apt-eval/filter"
 	"github.com/mojotx/apt-eval/models"
 	"github.com/rs/zerolog/log"
-
-	_ "github.com/mattn/go-sqlite3"
 )
 
 // DB is a wrapper around sql.DB
 type DB struct {
 	*sql.DB
+
+	// cache is the optional read cache; nil when disabled
+	cache *readCache
+
+	// fields encrypts the notes and contact columns; nil when disabled
+	fields *fieldcrypt.Cipher
+
+	// connector times every statement and logs the slow ones
+	connector *instrumentedConnector
 }
 
 // New creates a new database connection
@@ -26,16 +35,15 @@ func New(dataDir string) (*DB, error) {
 		return nil, fmt.Errorf("failed to create data directory: %w", err)
 	}
 
+	// Transactions take the write lock up front, where waiting for it
+	// can't deadlock, and statements wait briefly for a lock themselves
+	// before they're retried; see retryBusy
 	dbPath := filepath.Join(dataDir, "apartments.db")
-	db, err := sql.Open("sqlite3", dbPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %w", err)
-	}
+	connector := newInstrumentedConnector(dbPath + "?_txlock=immediate&_busy_timeout=250")
+	db := sql.OpenDB(connector)
 
-	// Set connection parameters
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(25)
-	db.SetConnMaxLifetime(5 * time.Minute)
+	setPool(db, DefaultPool)
+	publishPoolStats(db)
 
 	// Initialize database schema
 	if err := initSchema(db); err != nil {
@@ -43,7 +51,7 @@ func New(dataDir string) (*DB, error) {
 		return nil, fmt.Errorf("failed to initialize schema: %w", err)
 	}
 
-	return &DB{db}, nil
+	return &DB{DB: db, connector: connector}, nil
 }
 
 //go:embed create.sql
@@ -57,71 +65,239 @@ func initSchema(db *sql.DB) error {
 		return fmt.Errorf("failed to create table: %w", err)
 	}
 
+	if err := migrate(db); err != nil {
+		return err
+	}
+	if err := buildSuggestIndex(db); err != nil {
+		return err
+	}
+	if err := backfillCanonicalAddresses(db); err != nil {
+		return err
+	}
+
 	log.Info().Msg("Database schema initialized")
 	return nil
 }
 
+// scanner is implemented by *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scanApartment reads a row produced by select.sql
+func (db *DB) scanApartment(row scanner) (*models.Apartment, error) {
+	var apt models.Apartment
+	var amenities, answers sql.NullString
+	err := row.Scan(
+		&apt.ID,
+		&apt.UUID,
+		&apt.Address,
+		&apt.Unit,
+		&apt.CanonicalAddress,
+		&apt.BuildingAddress,
+		&apt.VisitDate,
+		&apt.Notes,
+		&apt.Rating,
+		&apt.Ratings.Location,
+		&apt.Ratings.Condition,
+		&apt.Ratings.Kitchen,
+		&apt.Ratings.Noise,
+		&apt.Ratings.Value,
+		&apt.Score,
+		&apt.Price,
+		&apt.Status,
+		&apt.ListingURL,
+		&apt.Floor,
+		&apt.IsGated,
+		&apt.HasGarage,
+		&apt.HasLaundry,
+		&amenities,
+		&answers,
+		&apt.Latitude,
+		&apt.Longitude,
+		&apt.WalkScore,
+		&apt.BikeScore,
+		&apt.TransitScore,
+		&apt.WalkabilityUpdatedAt,
+		&apt.CrimeIncidents,
+		&apt.Safety,
+		&apt.CrimeUpdatedAt,
+		&apt.SchoolDistrict,
+		&apt.SchoolRating,
+		&apt.SchoolsUpdatedAt,
+		&apt.MarketRent,
+		&apt.MarketRentArea,
+		&apt.MarketRentBedrooms,
+		&apt.MarketRentDiffPct,
+		&apt.MarketRentUpdatedAt,
+		&apt.TransitStop,
+		&apt.TransitWalkMinutes,
+		&apt.TransitLines,
+		&apt.TransitUpdatedAt,
+		&apt.CommuteScore,
+		&apt.CommuteUpdatedAt,
+		&apt.Neighborhood,
+		&apt.NeighborhoodSource,
+		&apt.NeighborhoodUpdatedAt,
+		&apt.RoomRating,
+		&apt.OverallRating,
+		&apt.Utilities.Electricity,
+		&apt.Utilities.Gas,
+		&apt.Utilities.Water,
+		&apt.Utilities.Internet,
+		&apt.Utilities.ElectricityIncluded,
+		&apt.Utilities.GasIncluded,
+		&apt.Utilities.WaterIncluded,
+		&apt.Utilities.InternetIncluded,
+		&apt.TrueMonthlyCost,
+		&apt.Parking.Type,
+		&apt.Parking.MonthlyCost,
+		&apt.Parking.EVCharging,
+		&apt.Parking.DistanceM,
+		&apt.ListingType,
+		&apt.Sale.PurchasePrice,
+		&apt.Sale.HOAFee,
+		&apt.Sale.PropertyTax,
+		&apt.BestOffer,
+		&apt.ApplicationDeadline,
+		&apt.HoldExpires,
+		&apt.BuildingID,
+		&apt.CoverPhotoID,
+		&apt.Visibility,
+		&apt.OwnerID,
+		&apt.Reactions.ThumbsUp,
+		&apt.Reactions.ThumbsDown,
+		&apt.Reactions.Heart,
+		&apt.Reactions.Thinking,
+		&apt.CreatedAt,
+		&apt.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	apt.Amenities = splitAmenities(amenities)
+	if apt.Answers, err = decodeAnswers(answers); err != nil {
+		return nil, err
+	}
+	if err := db.open(&apt.Notes); err != nil {
+		return nil, err
+	}
+	return &apt, nil
+}
+
 //go:embed insert.sql
 var insertApartmentQuery string
 
 // CreateApartment inserts a new apartment record
 func (db *DB) CreateApartment(apt *models.ApartmentRequest) (*models.Apartment, error) {
-	var apartment models.Apartment
-	err := db.QueryRow(
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	id, err := db.createApartment(tx, apt)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit apartment: %w", err)
+	}
+
+	db.changed()
+	return db.GetApartment(id)
+}
+
+// createApartment inserts a new apartment and everything the request
+// sets along with it, returning its ID
+func (db *DB) createApartment(tx *sql.Tx, apt *models.ApartmentRequest) (int64, error) {
+	status := apt.Status
+	if status == "" {
+		status = models.StatusConsidering
+	}
+	var listingURL string
+	if apt.ListingURL != nil {
+		listingURL = *apt.ListingURL
+	}
+
+	street, unit := splitUnit(apt)
+	var id int64
+	err := tx.QueryRow(
 		insertApartmentQuery,
-		apt.Address,
+		newUUID(),
+		street,
+		unit,
 		apt.VisitDate.Time,
-		apt.Notes,
+		db.seal(apt.Notes),
 		apt.Rating,
 		apt.Price,
 		apt.Floor,
-		apt.IsGated,
-		apt.HasGarage,
-		apt.HasLaundry,
-	).Scan(
-		&apartment.ID,
-		&apartment.Address,
-		&apartment.VisitDate,
-		&apartment.Notes,
-		&apartment.Rating,
-		&apartment.Price,
-		&apartment.Floor,
-		&apartment.IsGated,
-		&apartment.HasGarage,
-		&apartment.HasLaundry,
-		&apartment.CreatedAt,
-		&apartment.UpdatedAt,
-	)
+		apt.Latitude,
+		apt.Longitude,
+		status,
+		listingURL,
+	).Scan(&id)
 
 	if err != nil {
-		return nil, fmt.Errorf("failed to create apartment: %w", err)
+		return 0, fmt.Errorf("failed to create apartment: %w", err)
 	}
 
-	return &apartment, nil
+	if err := setCanonicalAddresses(tx, id); err != nil {
+		return 0, err
+	}
+	if err := setApartmentAmenities(tx, id, apt); err != nil {
+		return 0, err
+	}
+	if err := applyBuilding(tx, id, apt); err != nil {
+		return 0, err
+	}
+	if err := applyRatings(tx, id, apt); err != nil {
+		return 0, err
+	}
+	if err := applyAnswers(tx, id, apt, true); err != nil {
+		return 0, err
+	}
+	if err := applyNeighborhood(tx, id, apt); err != nil {
+		return 0, err
+	}
+	if err := indexApartment(tx, id); err != nil {
+		return 0, err
+	}
+	if err := applyVisibility(tx, id, apt, true); err != nil {
+		return 0, err
+	}
+	if err := applyUtilities(tx, id, apt); err != nil {
+		return 0, err
+	}
+	if err := applyParking(tx, id, apt); err != nil {
+		return 0, err
+	}
+	if err := applySale(tx, id, apt, true); err != nil {
+		return 0, err
+	}
+	if err := applyDeadlines(tx, id, apt); err != nil {
+		return 0, err
+	}
+	if err := appendToBoard(tx, id); err != nil {
+		return 0, err
+	}
+	return id, nil
 }
 
-//go:embed get.sql
-var getApartmentQuery string
+//go:embed select.sql
+var selectApartmentsQuery string
 
 // GetApartment retrieves an apartment by ID
 func (db *DB) GetApartment(id int64) (*models.Apartment, error) {
+	var gen uint64
+	if db.cache != nil {
+		if apt, ok := db.cache.getApartment(id); ok {
+			return apt, nil
+		}
+		gen = db.cache.gen()
+	}
 
-	var apartment models.Apartment
-	err := db.QueryRow(getApartmentQuery, id).Scan(
-		&apartment.ID,
-		&apartment.Address,
-		&apartment.VisitDate,
-		&apartment.Notes,
-		&apartment.Rating,
-		&apartment.Price,
-		&apartment.Floor,
-		&apartment.IsGated,
-		&apartment.HasGarage,
-		&apartment.HasLaundry,
-		&apartment.CreatedAt,
-		&apartment.UpdatedAt,
-	)
-
+	apartment, err := db.scanApartment(db.QueryRow(selectApartmentsQuery+" WHERE id = ?", id))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -129,16 +305,166 @@ func (db *DB) GetApartment(id int64) (*models.Apartment, error) {
 		return nil, fmt.Errorf("failed to get apartment: %w", err)
 	}
 
-	return &apartment, nil
+	if db.cache != nil {
+		db.cache.storeApartment(gen, apartment)
+	}
+	return apartment, nil
+}
+
+// ApartmentFields lists the fields that may be referenced in filter
+// expressions and sort parameters
+var ApartmentFields = filter.Fields{
+	"id":                   {Column: "id", Type: filter.Number},
+	"uuid":                 {Column: "uuid", Type: filter.Text},
+	"address":              {Column: "address", Type: filter.Text},
+	"unit":                 {Column: "unit", Type: filter.Text},
+	"canonical_address":    {Column: "canonical_address", Type: filter.Text},
+	"building_address":     {Column: "building_address", Type: filter.Text},
+	"visit_date":           {Column: "visit_date", Type: filter.Date},
+	"notes":                {Column: "notes", Type: filter.Text},
+	"rating":               {Column: "rating", Type: filter.Number},
+	"rating_location":      {Column: "rating_location", Type: filter.Number},
+	"rating_condition":     {Column: "rating_condition", Type: filter.Number},
+	"rating_kitchen":       {Column: "rating_kitchen", Type: filter.Number},
+	"rating_noise":         {Column: "rating_noise", Type: filter.Number},
+	"rating_value":         {Column: "rating_value", Type: filter.Number},
+	"score":                {Column: "score", Type: filter.Number},
+	"price":                {Column: "price", Type: filter.Number},
+	"floor":                {Column: "floor", Type: filter.Number},
+	"status":               {Column: "status", Type: filter.Text},
+	"listing_url":          {Column: "listing_url", Type: filter.Text},
+	"is_gated":             {Column: hasAmenity(models.AmenityGated), Type: filter.Bool},
+	"has_garage":           {Column: "(parking_type = '" + models.ParkingGarage + "')", Type: filter.Bool},
+	"has_laundry":          {Column: hasAmenity(models.AmenityLaundry), Type: filter.Bool},
+	"amenities":            {Column: amenityList, Type: filter.Text},
+	"latitude":             {Column: "latitude", Type: filter.Number},
+	"longitude":            {Column: "longitude", Type: filter.Number},
+	"walk_score":           {Column: "walk_score", Type: filter.Number},
+	"bike_score":           {Column: "bike_score", Type: filter.Number},
+	"transit_score":        {Column: "transit_score", Type: filter.Number},
+	"crime_incidents":      {Column: "crime_incidents", Type: filter.Number},
+	"safety":               {Column: "safety", Type: filter.Text},
+	"school_district":      {Column: "school_district", Type: filter.Text},
+	"school_rating":        {Column: "school_rating", Type: filter.Number},
+	"market_rent":          {Column: "market_rent", Type: filter.Number},
+	"market_rent_diff_pct": {Column: marketRentDiff, Type: filter.Number},
+	"transit_stop":         {Column: "transit_stop", Type: filter.Text},
+	"transit_walk_minutes": {Column: "transit_walk_minutes", Type: filter.Number},
+	"transit_lines":        {Column: "transit_lines", Type: filter.Text},
+	"commute_score":        {Column: "commute_score", Type: filter.Number},
+	"commute_minutes":      {Column: longestCommute, Type: filter.Number},
+	"neighborhood":         {Column: "neighborhood", Type: filter.Text},
+	"room_rating":          {Column: "room_rating", Type: filter.Number},
+	"bedrooms":             {Column: bedroomCount, Type: filter.Number},
+	"overall_rating":       {Column: "overall_rating", Type: filter.Number},
+	"electricity_estimate": {Column: "electricity_estimate", Type: filter.Number},
+	"gas_estimate":         {Column: "gas_estimate", Type: filter.Number},
+	"water_estimate":       {Column: "water_estimate", Type: filter.Number},
+	"internet_estimate":    {Column: "internet_estimate", Type: filter.Number},
+	"electricity_included": {Column: "electricity_included", Type: filter.Bool},
+	"gas_included":         {Column: "gas_included", Type: filter.Bool},
+	"water_included":       {Column: "water_included", Type: filter.Bool},
+	"internet_included":    {Column: "internet_included", Type: filter.Bool},
+	"true_monthly_cost":    {Column: "true_monthly_cost", Type: filter.Number},
+	"parking_type":         {Column: "parking_type", Type: filter.Text},
+	"parking_cost":         {Column: "parking_cost", Type: filter.Number},
+	"parking_ev_charging":  {Column: "parking_ev_charging", Type: filter.Bool},
+	"parking_distance_m":   {Column: "parking_distance_m", Type: filter.Number},
+	"listing_type":         {Column: "listing_type", Type: filter.Text},
+	"purchase_price":       {Column: "purchase_price", Type: filter.Number},
+	"hoa_fee":              {Column: "hoa_fee", Type: filter.Number},
+	"best_offer":           {Column: "best_offer", Type: filter.Number},
+	"application_deadline": {Column: "application_deadline", Type: filter.Date},
+	"hold_expires":         {Column: "hold_expires", Type: filter.Date},
+	"building_id":          {Column: "building_id", Type: filter.Number},
+	"visibility":           {Column: "visibility", Type: filter.Text},
+	"created_at":           {Column: "created_at", Type: filter.Date},
+	"updated_at":           {Column: "updated_at", Type: filter.Date},
+}
+
+// SortField orders list results by a column
+type SortField struct {
+	Column string
+	Desc   bool
+}
+
+// ListOptions controls which apartments ListApartments returns
+type ListOptions struct {
+	// Filter restricts the result set; nil matches every apartment
+	Filter filter.Node
+
+	// Amenities restricts results to apartments having all of these
+	// amenity keys
+	Amenities []string
+
+	// BuildingID restricts results to the units of a building
+	BuildingID int64
+
+	// Search restricts results to apartments whose address, neighborhood,
+	// or building name matches every word, give or take a typo or two
+	Search string
+
+	// Viewer is the household member listing, who doesn't see other
+	// members' private apartments; 0 sees no private apartments
+	Viewer int64
+
+	// Owner restricts results to the apartments a user added
+	Owner int64
+
+	// Sort overrides the default newest-first ordering. Cursor
+	// pagination is only available with the default ordering.
+	Sort []SortField
+
+	// Limit caps the number of rows returned; zero means no limit
+	Limit int
+
+	// Offset skips rows for classic page-number pagination
+	Offset int
+
+	// After resumes keyset pagination after the given position
+	After *Cursor
 }
 
-//go:embed list.sql
-var listApartmentsQuery string
+// ListApartments retrieves the apartments matching opts
+func (db *DB) ListApartments(opts ListOptions) ([]models.Apartment, error) {
+	where, args, ok, err := db.listConditions(opts)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return []models.Apartment{}, nil
+	}
+	if opts.After != nil {
+		createdAt := opts.After.CreatedAt.UTC().Format(cursorTimeFormat)
+		where = append(where, "(created_at < ? OR (created_at = ? AND id < ?))")
+		args = append(args, createdAt, createdAt, opts.After.ID)
+	}
+
+	query := selectApartmentsQuery + " WHERE " + strings.Join(where, " AND ")
+	query += " ORDER BY " + orderBy(opts.Sort)
+	if opts.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, opts.Limit)
+	}
+	if opts.Offset > 0 {
+		if opts.Limit <= 0 {
+			// SQLite requires a LIMIT before OFFSET; -1 means unbounded
+			query += " LIMIT -1"
+		}
+		query += " OFFSET ?"
+		args = append(args, opts.Offset)
+	}
 
-// ListApartments retrieves all apartments
-func (db *DB) ListApartments() ([]models.Apartment, error) {
+	var gen uint64
+	cacheKey := listCacheKey(query, args)
+	if db.cache != nil {
+		if apartments, ok := db.cache.getList(cacheKey); ok {
+			return apartments, nil
+		}
+		gen = db.cache.gen()
+	}
 
-	rows, err := db.Query(listApartmentsQuery)
+	rows, err := db.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list apartments: %w", err)
 	}
@@ -146,71 +472,148 @@ func (db *DB) ListApartments() ([]models.Apartment, error) {
 
 	apartments := []models.Apartment{}
 	for rows.Next() {
-		var apt models.Apartment
-		if err := rows.Scan(
-			&apt.ID,
-			&apt.Address,
-			&apt.VisitDate,
-			&apt.Notes,
-			&apt.Rating,
-			&apt.Price,
-			&apt.Floor,
-			&apt.IsGated,
-			&apt.HasGarage,
-			&apt.HasLaundry,
-			&apt.CreatedAt,
-			&apt.UpdatedAt,
-		); err != nil {
+		apt, err := db.scanApartment(rows)
+		if err != nil {
 			return nil, fmt.Errorf("failed to scan apartment row: %w", err)
 		}
-		apartments = append(apartments, apt)
+		apartments = append(apartments, *apt)
 	}
 
 	if err = rows.Err(); err != nil {
 		return nil, fmt.Errorf("error during row iteration: %w", err)
 	}
 
+	if db.cache != nil {
+		db.cache.storeList(gen, cacheKey, apartments)
+	}
 	return apartments, nil
 }
 
+// listConditions returns the WHERE conditions of a list with opts and
+// their arguments, or false when nothing can match
+func (db *DB) listConditions(opts ListOptions) ([]string, []any, bool, error) {
+	where := []string{visibleTo}
+	args := []any{opts.Viewer}
+	if opts.Filter != nil {
+		clause, clauseArgs := opts.Filter.SQL()
+		where = append(where, clause)
+		args = append(args, clauseArgs...)
+	}
+	if opts.Owner > 0 {
+		where = append(where, "apartments.owner_id = ?")
+		args = append(args, opts.Owner)
+	}
+	if len(opts.Amenities) > 0 {
+		placeholders := strings.TrimSuffix(strings.Repeat("?,", len(opts.Amenities)), ",")
+		where = append(where, `(
+			SELECT COUNT(DISTINCT am.key) FROM unit_amenities aa JOIN amenities am ON am.id = aa.amenity_id
+			WHERE aa.apartment_id = apartments.id AND am.key IN (`+placeholders+`)) = ?`)
+		for _, key := range opts.Amenities {
+			args = append(args, key)
+		}
+		args = append(args, len(opts.Amenities))
+	}
+	if opts.BuildingID != 0 {
+		where = append(where, "building_id = ?")
+		args = append(args, opts.BuildingID)
+	}
+	if strings.TrimSpace(opts.Search) != "" {
+		ids, err := db.searchApartments(opts.Search)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		if len(ids) == 0 {
+			return nil, nil, false, nil
+		}
+		where = append(where, "id IN ("+strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")+")")
+		for _, id := range ids {
+			args = append(args, id)
+		}
+	}
+	return where, args, true, nil
+}
+
+// CountApartments counts the apartments a list with opts would return
+// across all its pages
+func (db *DB) CountApartments(opts ListOptions) (int, error) {
+	where, args, ok, err := db.listConditions(opts)
+	if err != nil || !ok {
+		return 0, err
+	}
+	var n int
+	err = db.QueryRow("SELECT COUNT(*) FROM apartments WHERE "+strings.Join(where, " AND "), args...).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count apartments: %w", err)
+	}
+	return n, nil
+}
+
+// ApartmentExists reports whether an apartment viewer may see exists
+func (db *DB) ApartmentExists(id, viewer int64) (bool, error) {
+	var exists bool
+	err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM apartments WHERE id = ? AND "+visibleTo+")", id, viewer).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check apartment: %w", err)
+	}
+	return exists, nil
+}
+
+// orderBy renders an ORDER BY clause. Unknown values sort last, and id
+// breaks ties so pages are stable.
+func orderBy(fields []SortField) string {
+	if len(fields) == 0 {
+		return "created_at DESC, id DESC"
+	}
+
+	var parts []string
+	for _, f := range fields {
+		dir := "ASC"
+		if f.Desc {
+			dir = "DESC"
+		}
+		parts = append(parts, f.Column+" "+dir+" NULLS LAST")
+	}
+	return strings.Join(append(parts, "id DESC"), ", ")
+}
+
+//go:embed update.sql
+var updateApartmentQuery string
+
 // UpdateApartment updates an existing apartment
 func (db *DB) UpdateApartment(id int64, apt *models.ApartmentRequest) (*models.Apartment, error) {
-	query := `
-		UPDATE apartments
-		SET address = ?, visit_date = ?, notes = ?, rating = ?, price = ?,
-		    floor = ?, is_gated = ?, has_garage = ?, has_laundry = ?,
-		    updated_at = CURRENT_TIMESTAMP
-		WHERE id = ?
-		RETURNING id, address, visit_date, notes, rating, price, floor, is_gated, has_garage, has_laundry, created_at, updated_at
-	`
-
-	var apartment models.Apartment
-	err := db.QueryRow(
-		query,
-		apt.Address,
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Read the current status so a change can be announced
+	var oldStatus string
+	err = tx.QueryRow("SELECT status FROM apartments WHERE id = ?", id).Scan(&oldStatus)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get apartment status: %w", err)
+	}
+
+	street, unit := splitUnit(apt)
+	var updatedID int64
+	err = tx.QueryRow(
+		updateApartmentQuery,
+		street,
+		unit,
 		apt.VisitDate.Time,
-		apt.Notes,
+		db.resealNotes(tx, id, apt.Notes),
 		apt.Rating,
 		apt.Price,
 		apt.Floor,
-		apt.IsGated,
-		apt.HasGarage,
-		apt.HasLaundry,
+		apt.Latitude,
+		apt.Longitude,
+		apt.Status,
+		apt.ListingURL,
 		id,
-	).Scan(
-		&apartment.ID,
-		&apartment.Address,
-		&apartment.VisitDate,
-		&apartment.Notes,
-		&apartment.Rating,
-		&apartment.Price,
-		&apartment.Floor,
-		&apartment.IsGated,
-		&apartment.HasGarage,
-		&apartment.HasLaundry,
-		&apartment.CreatedAt,
-		&apartment.UpdatedAt,
-	)
+	).Scan(&updatedID)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -219,28 +622,122 @@ func (db *DB) UpdateApartment(id int64, apt *models.ApartmentRequest) (*models.A
 		return nil, fmt.Errorf("failed to update apartment: %w", err)
 	}
 
-	return &apartment, nil
+	if err := setCanonicalAddresses(tx, updatedID); err != nil {
+		return nil, err
+	}
+	if err := setApartmentAmenities(tx, updatedID, apt); err != nil {
+		return nil, err
+	}
+	if err := applyBuilding(tx, updatedID, apt); err != nil {
+		return nil, err
+	}
+	if err := applyRatings(tx, updatedID, apt); err != nil {
+		return nil, err
+	}
+	if err := applyAnswers(tx, updatedID, apt, false); err != nil {
+		return nil, err
+	}
+	if err := applyNeighborhood(tx, updatedID, apt); err != nil {
+		return nil, err
+	}
+	if err := indexApartment(tx, updatedID); err != nil {
+		return nil, err
+	}
+	if err := applyVisibility(tx, updatedID, apt, false); err != nil {
+		return nil, err
+	}
+	if err := applyUtilities(tx, updatedID, apt); err != nil {
+		return nil, err
+	}
+	if err := applyParking(tx, updatedID, apt); err != nil {
+		return nil, err
+	}
+	if err := applySale(tx, updatedID, apt, false); err != nil {
+		return nil, err
+	}
+	if err := applyDeadlines(tx, updatedID, apt); err != nil {
+		return nil, err
+	}
+	if apt.Status != "" && apt.Status != oldStatus {
+		if err := enqueueStatusChange(tx, updatedID, apt.Address, oldStatus, apt.Status); err != nil {
+			return nil, err
+		}
+		if err := appendToBoard(tx, updatedID); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit apartment: %w", err)
+	}
+
+	db.changed()
+	return db.GetApartment(updatedID)
 }
 
 //go:embed delete.sql
 var deleteApartmentQuery string
 
-// DeleteApartment removes an apartment by ID
-func (db *DB) DeleteApartment(id int64) error {
-
-	result, err := db.Exec(deleteApartmentQuery, id)
+// DeleteApartment removes an apartment by ID, with everything kept
+// alongside it, and records in the activity feed that userID deleted it,
+// in a way POST /api/undo can reverse. Unless force is set, an apartment
+// with records of its own (see ApartmentDependents) isn't deleted, and a
+// *HasDependentsError says what they are. It returns the attachments
+// deleted with the apartment, whose files are the caller's to remove, or
+// ErrNotFound if it doesn't exist.
+func (db *DB) DeleteApartment(userID, id int64, force bool) ([]models.Attachment, error) {
+	tx, err := db.Begin()
 	if err != nil {
-		return fmt.Errorf("failed to delete apartment: %w", err)
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if !force {
+		dependents, err := apartmentDependents(tx, id)
+		if err != nil {
+			return nil, err
+		}
+		if len(dependents) > 0 {
+			return nil, &HasDependentsError{Dependents: dependents}
+		}
 	}
 
+	attachments, err := queryAttachments(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	// Recorded while the apartment's address is still there; the entry
+	// goes with the transaction if the delete fails
+	snapshot, err := snapshotApartment(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if snapshot == nil {
+		return nil, ErrNotFound
+	}
+	if err := recordUndoable(tx, userID, models.ActivityDeleted, id, "", snapshot, nil); err != nil {
+		return nil, err
+	}
+	result, err := tx.Exec(deleteApartmentQuery, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete apartment: %w", err)
+	}
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
+		return nil, fmt.Errorf("failed to get rows affected: %w", err)
 	}
-
 	if rowsAffected == 0 {
-		return fmt.Errorf("apartment with id %d not found", id)
+		return nil, ErrNotFound
+	}
+	if err := deleteApartmentRecords(tx, id); err != nil {
+		return nil, err
+	}
+	if err := unindexApartment(tx, id); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit apartment deletion: %w", err)
 	}
 
-	return nil
+	db.changed()
+	return attachments, nil
 }
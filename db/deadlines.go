@@ -0,0 +1,186 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mojotx/apt-eval/address"
+	"github.com/mojotx/apt-eval/models"
+)
+
+// deadlineKinds lists each kind of deadline with its column, the event of
+// its reminders, and the statuses past which it no longer matters
+var deadlineKinds = []struct {
+	kind, column, event string
+	closed              []string
+}{
+	{models.DeadlineApplication, "application_deadline", models.EventApplicationDeadline,
+		[]string{models.StatusApplied, models.StatusSigned, models.StatusRejected, models.StatusArchived}},
+	{models.DeadlineHold, "hold_expires", models.EventHoldExpiry,
+		[]string{models.StatusSigned, models.StatusRejected, models.StatusArchived}},
+}
+
+// openDeadline is a SQL condition matching apartments where a kind of
+// deadline is set and still matters
+func openDeadline(column string, closed []string) string {
+	return fmt.Sprintf("%s IS NOT NULL AND status NOT IN ('%s')", column, strings.Join(closed, "', '"))
+}
+
+// applyDeadlines stores the deadlines from req. Moving or clearing a
+// deadline drops its queued reminder so a new one is sent.
+func applyDeadlines(tx *sql.Tx, id int64, req *models.ApartmentRequest) error {
+	for _, d := range deadlineKinds {
+		value := req.ApplicationDeadline
+		if d.kind == models.DeadlineHold {
+			value = req.HoldExpires
+		}
+		if value == nil {
+			continue
+		}
+		var due *time.Time
+		if !value.IsZero() {
+			t := value.UTC()
+			due = &t
+		}
+
+		_, err := tx.Exec(fmt.Sprintf(`
+			UPDATE apartments
+			SET %[1]s_reminded_at = CASE WHEN %[1]s IS ? THEN %[1]s_reminded_at END, %[1]s = ?
+			WHERE id = ?`, d.column),
+			due, due, id,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to set %s deadline: %w", d.kind, err)
+		}
+		_, err = tx.Exec(fmt.Sprintf(`
+			UPDATE notifications SET status = ?
+			WHERE apartment_id = ? AND event = ? AND status = ?
+			  AND (SELECT %s_reminded_at FROM apartments WHERE id = ?) IS NULL`, d.column),
+			models.NotificationExpired, id, d.event, models.NotificationPending, id,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to cancel deadline reminders: %w", err)
+		}
+	}
+	return nil
+}
+
+// UpcomingDeadlines returns the deadlines still ahead, soonest first, for
+// apartments the viewer may see where they still matter. A positive within
+// limits them to those due by then.
+func (db *DB) UpcomingDeadlines(viewer int64, within time.Duration) ([]models.Deadline, error) {
+	var selects []string
+	var args []any
+	for _, d := range deadlineKinds {
+		query := fmt.Sprintf(`
+			SELECT id, address, unit, status, ?, %[1]s AS due FROM apartments
+			WHERE %[2]s AND datetime(%[1]s) > datetime('now') AND `+visibleTo, d.column, openDeadline(d.column, d.closed))
+		args = append(args, d.kind, viewer)
+		if within > 0 {
+			query += fmt.Sprintf(" AND datetime(%s) <= datetime('now', ?)", d.column)
+			args = append(args, fmt.Sprintf("+%d seconds", int(within.Seconds())))
+		}
+		selects = append(selects, query)
+	}
+
+	rows, err := db.Query(strings.Join(selects, " UNION ALL ")+" ORDER BY due, id", args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deadlines: %w", err)
+	}
+	defer rows.Close()
+
+	now := time.Now()
+	deadlines := []models.Deadline{}
+	for rows.Next() {
+		var d models.Deadline
+		var unit string
+		if err := rows.Scan(&d.ApartmentID, &d.Address, &unit, &d.Status, &d.Kind, &d.Due); err != nil {
+			return nil, fmt.Errorf("failed to scan deadline row: %w", err)
+		}
+		d.Address = address.JoinUnit(d.Address, unit)
+		d.DaysLeft = int(d.Due.Sub(now).Hours() / 24)
+		deadlines = append(deadlines, d)
+	}
+	return deadlines, rows.Err()
+}
+
+// DueDeadlineReminders returns deadlines falling within lead that haven't
+// been reminded about yet
+func (db *DB) DueDeadlineReminders(lead time.Duration) ([]models.DeadlineReminder, error) {
+	var reminders []models.DeadlineReminder
+	for _, d := range deadlineKinds {
+		rows, err := db.Query(fmt.Sprintf(`
+			SELECT id, address, unit, %[1]s FROM apartments
+			WHERE %[2]s AND %[1]s_reminded_at IS NULL
+			  AND datetime(%[1]s) > datetime('now')
+			  AND datetime(%[1]s) <= datetime('now', ?)
+			ORDER BY %[1]s`, d.column, openDeadline(d.column, d.closed)),
+			fmt.Sprintf("+%d seconds", int(lead.Seconds())),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list due deadline reminders: %w", err)
+		}
+		for rows.Next() {
+			r := models.DeadlineReminder{Event: d.event}
+			var unit string
+			if err := rows.Scan(&r.ApartmentID, &r.Address, &unit, &r.Due); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to scan deadline reminder row: %w", err)
+			}
+			r.Address = address.JoinUnit(r.Address, unit)
+			reminders = append(reminders, r)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("error during row iteration: %w", err)
+		}
+	}
+	return reminders, nil
+}
+
+// QueueDeadlineReminder queues a reminder message per user and marks the
+// deadline reminded, so it isn't queued again. The messages expire at the
+// deadline.
+func (db *DB) QueueDeadlineReminder(r *models.DeadlineReminder, messages map[int64]string) error {
+	column := ""
+	for _, d := range deadlineKinds {
+		if d.event == r.Event {
+			column = d.column
+		}
+	}
+	if column == "" {
+		return fmt.Errorf("unknown deadline event %q", r.Event)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for userID, body := range messages {
+		if hidden, err := hiddenFrom(tx, r.ApartmentID, userID); err != nil {
+			return err
+		} else if hidden {
+			continue
+		}
+		_, err := tx.Exec(
+			"INSERT INTO notifications (user_id, event, apartment_id, body, expires_at) VALUES (?, ?, ?, ?, ?)",
+			userID, r.Event, r.ApartmentID, body, r.Due.UTC(),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to enqueue deadline reminder: %w", err)
+		}
+	}
+	_, err = tx.Exec(fmt.Sprintf("UPDATE apartments SET %s_reminded_at = ? WHERE id = ?", column),
+		time.Now().UTC(), r.ApartmentID)
+	if err != nil {
+		return fmt.Errorf("failed to mark deadline reminded: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit deadline reminder: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+// Snapshot writes a copy of the database to path, replacing any file
+// already there, for Restore to go back to
+func (db *DB) Snapshot(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove old snapshot: %w", err)
+	}
+	if _, err := db.Exec("VACUUM INTO ?", path); err != nil {
+		return fmt.Errorf("failed to snapshot database: %w", err)
+	}
+	return nil
+}
+
+// Restore puts every table back the way it was in the snapshot at path,
+// in one transaction. Triggers are dropped while the rows are copied, so
+// restoring doesn't look like a change to sync clients, and recreated
+// after.
+func (db *DB) Restore(path string) error {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "ATTACH DATABASE ? AS snapshot", path); err != nil {
+		return fmt.Errorf("failed to attach snapshot: %w", err)
+	}
+	defer conn.ExecContext(ctx, "DETACH DATABASE snapshot")
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var tables, triggers []string
+	rows, err := tx.Query(`
+		SELECT type, name, sql FROM main.sqlite_master
+		WHERE (type = 'table' AND (name NOT LIKE 'sqlite_%' OR name = 'sqlite_sequence')) OR type = 'trigger'`)
+	if err != nil {
+		return fmt.Errorf("failed to list tables: %w", err)
+	}
+	var dropTriggers []string
+	for rows.Next() {
+		var kind, name, sql string
+		if err := rows.Scan(&kind, &name, &sql); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan table row: %w", err)
+		}
+		if kind == "trigger" {
+			dropTriggers = append(dropTriggers, name)
+			triggers = append(triggers, sql)
+			continue
+		}
+		tables = append(tables, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to list tables: %w", err)
+	}
+
+	for _, name := range dropTriggers {
+		if _, err := tx.Exec(fmt.Sprintf("DROP TRIGGER main.%q", name)); err != nil {
+			return fmt.Errorf("failed to drop trigger %s: %w", name, err)
+		}
+	}
+	for _, table := range tables {
+		if _, err := tx.Exec(fmt.Sprintf("DELETE FROM main.%q", table)); err != nil {
+			return fmt.Errorf("failed to clear %s: %w", table, err)
+		}
+		if _, err := tx.Exec(fmt.Sprintf("INSERT INTO main.%q SELECT * FROM snapshot.%q", table, table)); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", table, err)
+		}
+	}
+	for _, sql := range triggers {
+		if _, err := tx.Exec(sql); err != nil {
+			return fmt.Errorf("failed to recreate trigger: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit restore: %w", err)
+	}
+
+	db.changed()
+	return nil
+}
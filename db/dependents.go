@@ -0,0 +1,100 @@
+package db
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/mojotx/apt-eval/models"
+)
+
+// HasDependentsError is returned when deleting an apartment that has
+// records of its own without forcing it. Dependents counts them by kind.
+type HasDependentsError struct {
+	Dependents map[string]int
+}
+
+func (e *HasDependentsError) Error() string {
+	var parts []string
+	for _, d := range dependentTables {
+		if n := e.Dependents[d.name]; n > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", n, d.name))
+		}
+	}
+	return "apartment has " + strings.Join(parts, ", ")
+}
+
+// dependentTables hold what someone recorded about an apartment, which
+// deleting it would lose, by the name they're counted under
+var dependentTables = []struct{ name, table string }{
+	{"visits", "visits"},
+	{"attachments", "attachments"},
+	{"floor_plan", "floor_plans"},
+	{"rooms", "rooms"},
+	{"offers", "offers"},
+	{"comments", "comments"},
+	{"media_links", "media_links"},
+	{"move_in_costs", "move_in_costs"},
+}
+
+// ownedTables hold what only makes sense alongside an apartment, or is
+// worked out again from it, and goes with it whether forced or not. The
+// activity feed, sync's change log, and sent notifications are kept:
+// they're history. So are export records, which the next export uses to
+// delete the apartment's row.
+var ownedTables = []string{
+	"apartment_amenities", "commutes", "roommate_rooms", "ai_summaries",
+	"compare_set_apartments", "reactions", "alert_hits",
+}
+
+// ApartmentDependents counts an apartment's records of its own by kind,
+// leaving out kinds it has none of
+func (db *DB) ApartmentDependents(id int64) (map[string]int, error) {
+	return apartmentDependents(db, id)
+}
+
+func apartmentDependents(q queryer, id int64) (map[string]int, error) {
+	dependents := map[string]int{}
+	for _, d := range dependentTables {
+		var n int
+		if err := q.QueryRow("SELECT COUNT(*) FROM "+d.table+" WHERE apartment_id = ?", id).Scan(&n); err != nil {
+			return nil, fmt.Errorf("failed to count %s: %w", d.name, err)
+		}
+		if n > 0 {
+			dependents[d.name] = n
+		}
+	}
+	return dependents, nil
+}
+
+// deleteApartmentRecords deletes the rows of every table that refers to an
+// apartment, dependent or owned. Its visits' calendar events are queued
+// for removal, and reminders about it that haven't gone out are dropped.
+func deleteApartmentRecords(q queryer, id int64) error {
+	_, err := q.Exec(`
+		INSERT OR IGNORE INTO calendar_deletions (event_id)
+		SELECT calendar_event_id FROM visits WHERE apartment_id = ? AND calendar_event_id != ''`, id)
+	if err != nil {
+		return fmt.Errorf("failed to queue calendar deletions: %w", err)
+	}
+	_, err = q.Exec(`
+		UPDATE notifications SET status = ?
+		WHERE status = ? AND (apartment_id = ? OR visit_id IN (SELECT id FROM visits WHERE apartment_id = ?))`,
+		models.NotificationExpired, models.NotificationPending, id, id)
+	if err != nil {
+		return fmt.Errorf("failed to cancel reminders: %w", err)
+	}
+	if _, err := q.Exec("DELETE FROM comment_mentions WHERE comment_id IN (SELECT id FROM comments WHERE apartment_id = ?)", id); err != nil {
+		return fmt.Errorf("failed to delete mentions: %w", err)
+	}
+	tables := slices.Clone(ownedTables)
+	for _, d := range dependentTables {
+		tables = append(tables, d.table)
+	}
+	for _, table := range tables {
+		if _, err := q.Exec("DELETE FROM "+table+" WHERE apartment_id = ?", id); err != nil {
+			return fmt.Errorf("failed to delete from %s: %w", table, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/mojotx/apt-eval/models"
+)
+
+// ErrNotFound is returned when a record to modify doesn't exist
+var ErrNotFound = errors.New("not found")
+
+// longestCommute is a SQL expression giving the apartment row's longest
+// commute to any destination, in minutes
+const longestCommute = `(
+	SELECT MAX(c.minutes) FROM commutes c WHERE c.apartment_id = apartments.id)`
+
+const selectDestinationsQuery = `
+	SELECT id, name, COALESCE(address, ''), latitude, longitude, mode, weight, created_at, updated_at
+	FROM destinations`
+
+func scanDestination(row scanner) (*models.Destination, error) {
+	var d models.Destination
+	err := row.Scan(&d.ID, &d.Name, &d.Address, &d.Latitude, &d.Longitude, &d.Mode, &d.Weight, &d.CreatedAt, &d.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// destinationWeight defaults an unset weight to 1
+func destinationWeight(req *models.DestinationRequest) float64 {
+	if req.Weight == nil {
+		return 1
+	}
+	return *req.Weight
+}
+
+// ListDestinations returns all saved destinations
+func (db *DB) ListDestinations() ([]models.Destination, error) {
+	rows, err := db.Query(selectDestinationsQuery + " ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list destinations: %w", err)
+	}
+	defer rows.Close()
+
+	destinations := []models.Destination{}
+	for rows.Next() {
+		d, err := scanDestination(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan destination row: %w", err)
+		}
+		destinations = append(destinations, *d)
+	}
+	return destinations, rows.Err()
+}
+
+// GetDestination retrieves a destination by ID, or nil if it doesn't exist
+func (db *DB) GetDestination(id int64) (*models.Destination, error) {
+	d, err := scanDestination(db.QueryRow(selectDestinationsQuery+" WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get destination: %w", err)
+	}
+	return d, nil
+}
+
+// CreateDestination saves a new destination
+func (db *DB) CreateDestination(req *models.DestinationRequest) (*models.Destination, error) {
+	var id int64
+	err := db.QueryRow(`
+		INSERT INTO destinations (name, address, latitude, longitude, mode, weight)
+		VALUES (?, ?, ?, ?, ?, ?) RETURNING id`,
+		req.Name, req.Address, req.Latitude, req.Longitude, req.Mode, destinationWeight(req),
+	).Scan(&id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create destination: %w", err)
+	}
+
+	if err := db.invalidateCommutes(); err != nil {
+		return nil, err
+	}
+	return db.GetDestination(id)
+}
+
+// UpdateDestination modifies a destination, returning nil if it doesn't
+// exist
+func (db *DB) UpdateDestination(id int64, req *models.DestinationRequest) (*models.Destination, error) {
+	result, err := db.Exec(`
+		UPDATE destinations
+		SET name = ?, address = ?, latitude = ?, longitude = ?, mode = ?, weight = ?,
+		    updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?`,
+		req.Name, req.Address, req.Latitude, req.Longitude, req.Mode, destinationWeight(req), id,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update destination: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, nil
+	}
+
+	if _, err := db.Exec("DELETE FROM commutes WHERE destination_id = ?", id); err != nil {
+		return nil, fmt.Errorf("failed to clear commutes: %w", err)
+	}
+	if err := db.invalidateCommutes(); err != nil {
+		return nil, err
+	}
+	return db.GetDestination(id)
+}
+
+// DeleteDestination removes a destination and the commutes to it
+func (db *DB) DeleteDestination(id int64) error {
+	result, err := db.Exec("DELETE FROM destinations WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete destination: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+
+	if _, err := db.Exec("DELETE FROM commutes WHERE destination_id = ?", id); err != nil {
+		return fmt.Errorf("failed to clear commutes: %w", err)
+	}
+	return db.invalidateCommutes()
+}
+
+// invalidateCommutes marks every apartment's commute data stale after the
+// destinations change so the next enrichment run recomputes it
+func (db *DB) invalidateCommutes() error {
+	if _, err := db.Exec("UPDATE apartments SET commute_updated_at = NULL"); err != nil {
+		return fmt.Errorf("failed to invalidate commutes: %w", err)
+	}
+	db.changed()
+	return nil
+}
+
+// GetCommutes returns the commute matrix for an apartment
+func (db *DB) GetCommutes(apartmentID int64) (*models.CommuteMatrix, error) {
+	matrix := &models.CommuteMatrix{ApartmentID: apartmentID, Commutes: []models.Commute{}}
+	if err := db.QueryRow("SELECT commute_score FROM apartments WHERE id = ?", apartmentID).Scan(&matrix.Score); err != nil {
+		return nil, fmt.Errorf("failed to get commute score: %w", err)
+	}
+
+	rows, err := db.Query(`
+		SELECT c.destination_id, d.name, d.mode, c.minutes, c.distance_m, c.updated_at
+		FROM commutes c JOIN destinations d ON d.id = c.destination_id
+		WHERE c.apartment_id = ?
+		ORDER BY d.id`, apartmentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get commutes: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c models.Commute
+		if err := rows.Scan(&c.DestinationID, &c.Destination, &c.Mode, &c.Minutes, &c.DistanceMeters, &c.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan commute row: %w", err)
+		}
+		matrix.Commutes = append(matrix.Commutes, c)
+	}
+	return matrix, rows.Err()
+}
+
+// SetCommutes replaces an apartment's commute matrix and score
+func (db *DB) SetCommutes(apartmentID int64, commutes []models.Commute, score *float64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM commutes WHERE apartment_id = ?", apartmentID); err != nil {
+		return fmt.Errorf("failed to clear commutes: %w", err)
+	}
+	for _, c := range commutes {
+		_, err := tx.Exec(
+			"INSERT INTO commutes (apartment_id, destination_id, minutes, distance_m) VALUES (?, ?, ?, ?)",
+			apartmentID, c.DestinationID, c.Minutes, c.DistanceMeters,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to store commute: %w", err)
+		}
+	}
+	_, err = tx.Exec(`
+		UPDATE apartments
+		SET commute_score = ?,
+		    commute_updated_at = CURRENT_TIMESTAMP,
+		    updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?`,
+		score, apartmentID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to set commute score: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit commutes: %w", err)
+	}
+
+	db.changed()
+	return nil
+}
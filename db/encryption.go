@@ -0,0 +1,115 @@
+package db
+
+import (
+	"fmt"
+
+	"github.com/mojotx/apt-eval/fieldcrypt"
+)
+
+// encryptedColumns lists the columns holding free-form notes and contact
+// details, which are encrypted when a key is configured
+var encryptedColumns = []struct{ table, column string }{
+	{"apartments", "notes"},
+	{"visits", "notes"},
+	{"rooms", "notes"},
+	{"offers", "notes"},
+	{"buildings", "notes"},
+	{"buildings", "management_contact"},
+	{"buildings", "management_phone"},
+	{"buildings", "management_email"},
+}
+
+// EnableEncryption encrypts the notes and contact columns with c from now
+// on. Values already stored stay as they are until EncryptExisting.
+func (db *DB) EnableEncryption(c *fieldcrypt.Cipher) {
+	db.fields = c
+}
+
+// seal encrypts a value for an encrypted column, or returns it as it is
+// when encryption is off
+func (db *DB) seal(s string) string {
+	return db.fields.Encrypt(s)
+}
+
+// open decrypts values read from encrypted columns in place
+func (db *DB) open(values ...*string) error {
+	for _, v := range values {
+		plain, err := db.fields.Decrypt(*v)
+		if err != nil {
+			return err
+		}
+		*v = plain
+	}
+	return nil
+}
+
+// resealNotes returns the value to store as an apartment's notes: the
+// stored one if it already holds the same text, so saving an apartment
+// without touching its notes doesn't change them in the database
+func (db *DB) resealNotes(q queryer, id int64, notes string) string {
+	var stored string
+	if err := q.QueryRow("SELECT COALESCE(notes, '') FROM apartments WHERE id = ?", id).Scan(&stored); err != nil {
+		return db.seal(notes)
+	}
+	// Stored plaintext is only kept while encryption is off, when sealing
+	// leaves it as it is
+	plain, err := db.fields.Decrypt(stored)
+	if err != nil || plain != notes || stored != db.seal(stored) {
+		return db.seal(notes)
+	}
+	return stored
+}
+
+// EncryptExisting encrypts the values of the encrypted columns stored
+// before encryption was turned on, returning how many it encrypted. It
+// does nothing when encryption is off.
+func (db *DB) EncryptExisting() (int64, error) {
+	if db.fields == nil {
+		return 0, nil
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var total int64
+	for _, ec := range encryptedColumns {
+		rows, err := tx.Query(fmt.Sprintf("SELECT id, %s FROM %s WHERE %s != '' AND %s NOT LIKE ?",
+			ec.column, ec.table, ec.column, ec.column), fieldcrypt.Prefix+"%")
+		if err != nil {
+			return 0, fmt.Errorf("failed to find unencrypted %s.%s: %w", ec.table, ec.column, err)
+		}
+		plain := map[int64]string{}
+		for rows.Next() {
+			var id int64
+			var value string
+			if err := rows.Scan(&id, &value); err != nil {
+				rows.Close()
+				return 0, fmt.Errorf("failed to scan %s row: %w", ec.table, err)
+			}
+			plain[id] = value
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return 0, err
+		}
+
+		for id, value := range plain {
+			_, err := tx.Exec(fmt.Sprintf("UPDATE %s SET %s = ? WHERE id = ?", ec.table, ec.column), db.seal(value), id)
+			if err != nil {
+				return 0, fmt.Errorf("failed to encrypt %s.%s: %w", ec.table, ec.column, err)
+			}
+		}
+		total += int64(len(plain))
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit encryption: %w", err)
+	}
+	if total > 0 {
+		db.changed()
+	}
+	return total, nil
+}
@@ -0,0 +1,172 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/mojotx/apt-eval/models"
+)
+
+// ListApartmentsNeedingEnrichment returns located apartments whose
+// enrichment timestamp column is unset or older than maxAgeDays, oldest
+// first. column must be one of the *_updated_at enrichment columns.
+func (db *DB) ListApartmentsNeedingEnrichment(column string, maxAgeDays int) ([]models.Apartment, error) {
+	rows, err := db.Query(selectApartmentsQuery+fmt.Sprintf(`
+		WHERE latitude IS NOT NULL AND longitude IS NOT NULL
+		  AND (%[1]s IS NULL OR %[1]s < datetime('now', ?))
+		ORDER BY %[1]s IS NOT NULL, %[1]s`, column),
+		fmt.Sprintf("-%d days", maxAgeDays),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list apartments needing enrichment: %w", err)
+	}
+	defer rows.Close()
+
+	var apartments []models.Apartment
+	for rows.Next() {
+		apt, err := db.scanApartment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan apartment row: %w", err)
+		}
+		apartments = append(apartments, *apt)
+	}
+	return apartments, rows.Err()
+}
+
+// SetWalkability stores walkability scores for an apartment
+func (db *DB) SetWalkability(id int64, walk, bike, transit *int) error {
+	_, err := db.Exec(`
+		UPDATE apartments
+		SET walk_score = ?, bike_score = ?, transit_score = ?,
+		    walkability_updated_at = CURRENT_TIMESTAMP,
+		    updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?`,
+		walk, bike, transit, id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to set walkability: %w", err)
+	}
+
+	db.changed()
+	return nil
+}
+
+// SetCrime stores the crime incident count and safety level for an
+// apartment
+func (db *DB) SetCrime(id int64, incidents int, safety string) error {
+	_, err := db.Exec(`
+		UPDATE apartments
+		SET crime_incidents = ?, safety = ?,
+		    crime_updated_at = CURRENT_TIMESTAMP,
+		    updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?`,
+		incidents, safety, id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to set crime statistics: %w", err)
+	}
+
+	db.changed()
+	return nil
+}
+
+// SetSchools stores the school district and rating for an apartment
+func (db *DB) SetSchools(id int64, district string, rating *float64) error {
+	_, err := db.Exec(`
+		UPDATE apartments
+		SET school_district = ?, school_rating = ?,
+		    schools_updated_at = CURRENT_TIMESTAMP,
+		    updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?`,
+		district, rating, id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to set schools: %w", err)
+	}
+
+	db.changed()
+	return nil
+}
+
+// marketRentDiff is a SQL expression giving how far the apartment row's
+// price is over (positive) or under its market rent, in percent
+const marketRentDiff = `(
+	CASE WHEN price IS NOT NULL AND market_rent > 0
+		THEN ROUND((price - market_rent) * 100.0 / market_rent, 1)
+	END)`
+
+// SetMarketRent stores the going rent in an apartment's area for the
+// bedroom count it was looked up for
+func (db *DB) SetMarketRent(id int64, rent float64, area string, bedrooms int) error {
+	_, err := db.Exec(`
+		UPDATE apartments
+		SET market_rent = ?, market_rent_area = ?, market_rent_bedrooms = ?,
+		    market_rent_updated_at = CURRENT_TIMESTAMP,
+		    updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?`,
+		rent, area, bedrooms, id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to set market rent: %w", err)
+	}
+
+	db.changed()
+	return nil
+}
+
+// SetTransit stores transit access details for an apartment
+func (db *DB) SetTransit(id int64, stop string, walkMinutes int, lines string) error {
+	_, err := db.Exec(`
+		UPDATE apartments
+		SET transit_stop = ?, transit_walk_minutes = ?, transit_lines = ?,
+		    transit_updated_at = CURRENT_TIMESTAMP,
+		    updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?`,
+		stop, walkMinutes, lines, id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to set transit: %w", err)
+	}
+
+	db.changed()
+	return nil
+}
+
+// GetCachedCrimeStats returns the cached incident count for a geohash cell
+// if it was fetched from source within maxAge
+func (db *DB) GetCachedCrimeStats(geohash, source string, maxAge time.Duration) (int, bool, error) {
+	var incidents int
+	var fetchedAt time.Time
+	err := db.QueryRow(
+		"SELECT incidents, fetched_at FROM crime_stats_cache WHERE geohash = ? AND source = ?",
+		geohash, source,
+	).Scan(&incidents, &fetchedAt)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to read crime cache: %w", err)
+	}
+	if time.Since(fetchedAt) > maxAge {
+		return 0, false, nil
+	}
+	return incidents, true, nil
+}
+
+// CacheCrimeStats stores the incident count for a geohash cell
+func (db *DB) CacheCrimeStats(geohash, source string, incidents int) error {
+	_, err := db.Exec(`
+		INSERT INTO crime_stats_cache (geohash, source, incidents, fetched_at)
+		VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT (geohash) DO UPDATE SET
+		    source = excluded.source,
+		    incidents = excluded.incidents,
+		    fetched_at = excluded.fetched_at`,
+		geohash, source, incidents,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to write crime cache: %w", err)
+	}
+	return nil
+}
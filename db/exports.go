@@ -0,0 +1,52 @@
+package db
+
+import (
+	"fmt"
+
+	"github.com/mojotx/apt-eval/models"
+)
+
+// ListExportRecords returns the rows an exporter has made, keyed by
+// apartment ID
+func (db *DB) ListExportRecords(exporter string) (map[int64]models.ExportRecord, error) {
+	rows, err := db.Query(`
+		SELECT exporter, apartment_id, remote_id, hash, synced_at
+		FROM export_records WHERE exporter = ?`, exporter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list export records: %w", err)
+	}
+	defer rows.Close()
+
+	records := map[int64]models.ExportRecord{}
+	for rows.Next() {
+		var r models.ExportRecord
+		if err := rows.Scan(&r.Exporter, &r.ApartmentID, &r.RemoteID, &r.Hash, &r.SyncedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan export record row: %w", err)
+		}
+		records[r.ApartmentID] = r
+	}
+	return records, rows.Err()
+}
+
+// SaveExportRecord records the row an exporter made or updated for an
+// apartment
+func (db *DB) SaveExportRecord(exporter string, apartmentID int64, remoteID, hash string) error {
+	_, err := db.Exec(`
+		INSERT INTO export_records (exporter, apartment_id, remote_id, hash) VALUES (?, ?, ?, ?)
+		ON CONFLICT (exporter, apartment_id) DO UPDATE
+		SET remote_id = excluded.remote_id, hash = excluded.hash, synced_at = CURRENT_TIMESTAMP`,
+		exporter, apartmentID, remoteID, hash,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save export record: %w", err)
+	}
+	return nil
+}
+
+// DeleteExportRecord forgets the row an exporter made for an apartment
+func (db *DB) DeleteExportRecord(exporter string, apartmentID int64) error {
+	if _, err := db.Exec("DELETE FROM export_records WHERE exporter = ? AND apartment_id = ?", exporter, apartmentID); err != nil {
+		return fmt.Errorf("failed to delete export record: %w", err)
+	}
+	return nil
+}
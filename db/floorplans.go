@@ -0,0 +1,135 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/mojotx/apt-eval/models"
+)
+
+// GetFloorPlan returns an apartment's floor plan metadata, or nil if it
+// has none
+func (db *DB) GetFloorPlan(apartmentID int64) (*models.FloorPlan, error) {
+	var fp models.FloorPlan
+	var rooms string
+	err := db.QueryRow(`
+		SELECT apartment_id, filename, content_type, size, width, height, pages, rooms,
+		       scan_status, scanned_at, uploaded_at, updated_at
+		FROM floor_plans WHERE apartment_id = ?`, apartmentID,
+	).Scan(&fp.ApartmentID, &fp.Filename, &fp.ContentType, &fp.Size, &fp.Width, &fp.Height, &fp.Pages,
+		&rooms, &fp.ScanStatus, &fp.ScannedAt, &fp.UploadedAt, &fp.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get floor plan: %w", err)
+	}
+
+	if err := json.Unmarshal([]byte(rooms), &fp.Rooms); err != nil {
+		return nil, fmt.Errorf("failed to decode floor plan rooms: %w", err)
+	}
+	for i, r := range fp.Rooms {
+		fp.Rooms[i].AreaM2 = r.Area()
+		fp.TotalAreaM2 += fp.Rooms[i].AreaM2
+	}
+	return &fp, nil
+}
+
+// SaveFloorPlan records a newly uploaded floor plan file, replacing any
+// previous one. Room dimensions are kept unless rooms is non-nil.
+func (db *DB) SaveFloorPlan(fp *models.FloorPlan, rooms []models.RoomDimensions) (*models.FloorPlan, error) {
+	roomsJSON := "[]"
+	if rooms != nil {
+		b, err := json.Marshal(rooms)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode floor plan rooms: %w", err)
+		}
+		roomsJSON = string(b)
+	}
+
+	_, err := db.Exec(`
+		INSERT INTO floor_plans (apartment_id, filename, content_type, size, width, height, pages, rooms,
+		                         scan_status, scanned_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (apartment_id) DO UPDATE SET
+		    filename = excluded.filename,
+		    content_type = excluded.content_type,
+		    size = excluded.size,
+		    width = excluded.width,
+		    height = excluded.height,
+		    pages = excluded.pages,
+		    scan_status = excluded.scan_status,
+		    scanned_at = excluded.scanned_at,
+		    rooms = CASE WHEN ? THEN excluded.rooms ELSE floor_plans.rooms END,
+		    uploaded_at = CURRENT_TIMESTAMP,
+		    updated_at = CURRENT_TIMESTAMP`,
+		fp.ApartmentID, fp.Filename, fp.ContentType, fp.Size, fp.Width, fp.Height, fp.Pages, roomsJSON,
+		fp.ScanStatus, fp.ScannedAt, rooms != nil,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save floor plan: %w", err)
+	}
+
+	db.changed()
+	return db.GetFloorPlan(fp.ApartmentID)
+}
+
+// SetFloorPlanRooms replaces the room dimensions of an apartment's floor
+// plan, returning nil if it has no floor plan
+func (db *DB) SetFloorPlanRooms(apartmentID int64, rooms []models.RoomDimensions) (*models.FloorPlan, error) {
+	b, err := json.Marshal(rooms)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode floor plan rooms: %w", err)
+	}
+
+	result, err := db.Exec(
+		"UPDATE floor_plans SET rooms = ?, updated_at = CURRENT_TIMESTAMP WHERE apartment_id = ?",
+		string(b), apartmentID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set floor plan rooms: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, nil
+	}
+
+	db.changed()
+	return db.GetFloorPlan(apartmentID)
+}
+
+// DeleteFloorPlan removes an apartment's floor plan metadata
+func (db *DB) DeleteFloorPlan(apartmentID int64) error {
+	result, err := db.Exec("DELETE FROM floor_plans WHERE apartment_id = ?", apartmentID)
+	if err != nil {
+		return fmt.Errorf("failed to delete floor plan: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+
+	db.changed()
+	return nil
+}
+
+// FloorPlanImages returns when each apartment's floor plan was uploaded,
+// for apartments whose floor plan is an image rather than a PDF
+func (db *DB) FloorPlanImages() (map[int64]time.Time, error) {
+	rows, err := db.Query("SELECT apartment_id, uploaded_at FROM floor_plans WHERE width IS NOT NULL")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list floor plan images: %w", err)
+	}
+	defer rows.Close()
+
+	images := map[int64]time.Time{}
+	for rows.Next() {
+		var id int64
+		var uploadedAt time.Time
+		if err := rows.Scan(&id, &uploadedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan floor plan row: %w", err)
+		}
+		images[id] = uploadedAt
+	}
+	return images, rows.Err()
+}
@@ -0,0 +1,105 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/mojotx/apt-eval/models"
+)
+
+// GetCachedGeocode looks up a cached geocoding result no older than maxAge.
+// ok reports whether the cache had an answer; a cached miss is ok with a
+// nil result.
+func (db *DB) GetCachedGeocode(provider, query string, maxAge time.Duration) (result *models.Geocode, ok bool, err error) {
+	var found bool
+	var lat, lng sql.NullFloat64
+	var formatted string
+	var fetchedAt time.Time
+	err = db.QueryRow(`
+		SELECT found, latitude, longitude, formatted_address, fetched_at
+		FROM geocode_cache WHERE provider = ? AND query = ?`, provider, query,
+	).Scan(&found, &lat, &lng, &formatted, &fetchedAt)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read geocode cache: %w", err)
+	}
+	if time.Since(fetchedAt) > maxAge {
+		return nil, false, nil
+	}
+	if !found {
+		return nil, true, nil
+	}
+	return &models.Geocode{
+		Query:            query,
+		Latitude:         lat.Float64,
+		Longitude:        lng.Float64,
+		FormattedAddress: formatted,
+		Provider:         provider,
+	}, true, nil
+}
+
+// CacheGeocode stores a geocoding result; a nil result records a miss
+func (db *DB) CacheGeocode(provider, query string, result *models.Geocode) error {
+	var lat, lng *float64
+	var formatted string
+	if result != nil {
+		lat, lng, formatted = &result.Latitude, &result.Longitude, result.FormattedAddress
+	}
+	_, err := db.Exec(`
+		INSERT INTO geocode_cache (provider, query, found, latitude, longitude, formatted_address, fetched_at)
+		VALUES (?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT (provider, query) DO UPDATE SET
+		    found = excluded.found,
+		    latitude = excluded.latitude,
+		    longitude = excluded.longitude,
+		    formatted_address = excluded.formatted_address,
+		    fetched_at = excluded.fetched_at`,
+		provider, query, result != nil, lat, lng, formatted,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to write geocode cache: %w", err)
+	}
+	return nil
+}
+
+// ListApartmentsWithoutLocation returns apartments that haven't been
+// geocoded, oldest first
+func (db *DB) ListApartmentsWithoutLocation() ([]models.Apartment, error) {
+	rows, err := db.Query(selectApartmentsQuery + `
+		WHERE latitude IS NULL OR longitude IS NULL
+		ORDER BY created_at, id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list apartments without location: %w", err)
+	}
+	defer rows.Close()
+
+	var apartments []models.Apartment
+	for rows.Next() {
+		apt, err := db.scanApartment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan apartment row: %w", err)
+		}
+		apartments = append(apartments, *apt)
+	}
+	return apartments, rows.Err()
+}
+
+// SetLocation stores the coordinates of an apartment
+func (db *DB) SetLocation(id int64, latitude, longitude float64) error {
+	_, err := db.Exec(`
+		UPDATE apartments
+		SET latitude = ?, longitude = ?,
+		    updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?`,
+		latitude, longitude, id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to set location: %w", err)
+	}
+
+	db.changed()
+	return nil
+}
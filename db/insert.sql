@@ -1,18 +1,24 @@
 INSERT INTO
     apartments (
+        uuid,
         address,
+        unit,
         visit_date,
         notes,
         rating,
         price,
         floor,
-        is_gated,
-        has_garage,
-        has_laundry,
+        latitude,
+        longitude,
+        status,
+        listing_url,
         created_at,
         updated_at
     )
 VALUES (
+        ?,
+        ?,
+        COALESCE(?, ''),
         ?,
         ?,
         ?,
@@ -24,15 +30,4 @@ VALUES (
         ?,
         CURRENT_TIMESTAMP,
         CURRENT_TIMESTAMP
-    ) RETURNING id,
-    address,
-    visit_date,
-    notes,
-    rating,
-    price,
-    floor,
-    is_gated,
-    has_garage,
-    has_laundry,
-    created_at,
-    updated_at
+    ) RETURNING id
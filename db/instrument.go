@@ -0,0 +1,294 @@
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/mojotx/apt-eval/metrics"
+	"github.com/rs/zerolog/log"
+)
+
+// instrumentedConnector opens SQLite connections that time every
+// statement, count the rows it touched into metrics.DB, and log the ones
+// slower than the threshold
+type instrumentedConnector struct {
+	dsn    string
+	driver *sqlite3.SQLiteDriver
+
+	// slow is the slow-query threshold in nanoseconds; 0 logs nothing
+	slow atomic.Int64
+	// timeout is how long a statement may run in nanoseconds before it's
+	// interrupted; 0 lets it run
+	timeout atomic.Int64
+}
+
+func newInstrumentedConnector(dsn string) *instrumentedConnector {
+	return &instrumentedConnector{dsn: dsn, driver: &sqlite3.SQLiteDriver{}}
+}
+
+func (c *instrumentedConnector) Connect(context.Context) (driver.Conn, error) {
+	conn, err := c.driver.Open(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &instrumentedConn{SQLiteConn: conn.(*sqlite3.SQLiteConn), connector: c}, nil
+}
+
+func (c *instrumentedConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// LogSlowQueries logs every statement that takes longer than threshold,
+// with its parameters redacted. A threshold of zero turns it off.
+func (db *DB) LogSlowQueries(threshold time.Duration) {
+	db.connector.slow.Store(int64(max(threshold, 0)))
+}
+
+// SetStatementTimeout interrupts statements that run longer than timeout,
+// failing them with context.DeadlineExceeded, so a runaway query can't hold
+// a connection and the write lock indefinitely. A timeout of zero turns it
+// off.
+func (db *DB) SetStatementTimeout(timeout time.Duration) {
+	db.connector.timeout.Store(int64(max(timeout, 0)))
+}
+
+// bound limits ctx to the statement timeout, if there is one
+func (c *instrumentedConnector) bound(ctx context.Context) (context.Context, context.CancelFunc) {
+	timeout := time.Duration(c.timeout.Load())
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// observe records a finished statement: its duration, the rows it
+// returned or changed, and whether it failed
+func (c *instrumentedConnector) observe(query string, args []driver.NamedValue, started time.Time, rows int64, err error) {
+	elapsed := time.Since(started)
+	metrics.DB.Add("statements", 1)
+	metrics.DB.Add("rows", rows)
+	metrics.DB.Add("duration_us", elapsed.Microseconds())
+	if err != nil {
+		metrics.DB.Add("errors", 1)
+	}
+
+	slow := time.Duration(c.slow.Load())
+	if slow <= 0 || elapsed < slow {
+		return
+	}
+	metrics.DB.Add("slow", 1)
+	log.Warn().
+		Str("query", compactQuery(query)).
+		Strs("args", redactArgs(args)).
+		Dur("duration", elapsed).
+		Int64("rows", rows).
+		AnErr("query_error", err).
+		Msg("Slow query")
+}
+
+// compactQuery collapses the whitespace of a query onto one line
+func compactQuery(query string) string {
+	return strings.Join(strings.Fields(query), " ")
+}
+
+// redactArgs formats query parameters for the log. Text and blobs can hold
+// notes, contact details, and credentials, so only their length is shown.
+func redactArgs(args []driver.NamedValue) []string {
+	out := make([]string, len(args))
+	for i, a := range args {
+		switch v := a.Value.(type) {
+		case nil:
+			out[i] = "NULL"
+		case string:
+			out[i] = fmt.Sprintf("<text %d bytes>", len(v))
+		case []byte:
+			out[i] = fmt.Sprintf("<blob %d bytes>", len(v))
+		case time.Time:
+			out[i] = v.UTC().Format(time.RFC3339)
+		default:
+			out[i] = fmt.Sprint(v)
+		}
+	}
+	return out
+}
+
+// instrumentedConn times the statements run on a connection, and runs
+// again those that find the database locked. Prepared statements and
+// transactions go through it too, so everything the db package runs is
+// covered.
+type instrumentedConn struct {
+	*sqlite3.SQLiteConn
+	connector *instrumentedConnector
+}
+
+func (c *instrumentedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	ctx, cancel := c.connector.bound(ctx)
+	defer cancel()
+	started := time.Now()
+	var res driver.Result
+	err := c.retry(ctx, func() (err error) {
+		res, err = c.SQLiteConn.ExecContext(ctx, query, args)
+		return err
+	})
+	c.connector.observe(query, args, started, rowsAffected(res, err), err)
+	return res, err
+}
+
+func (c *instrumentedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	ctx, cancel := c.connector.bound(ctx)
+	started := time.Now()
+	rows, err := c.SQLiteConn.QueryContext(ctx, query, args)
+	if err != nil {
+		cancel()
+		c.connector.observe(query, args, started, 0, err)
+		return nil, err
+	}
+	return &instrumentedRows{Rows: rows, cancel: cancel, observe: func(n int64, err error) {
+		c.connector.observe(query, args, started, n, err)
+	}}, nil
+}
+
+func (c *instrumentedConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *instrumentedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	stmt, err := c.SQLiteConn.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return &instrumentedStmt{SQLiteStmt: stmt.(*sqlite3.SQLiteStmt), query: query, conn: c}, nil
+}
+
+// BeginTx starts a transaction. Transactions take the write lock as they
+// begin, so it's here that they wait for another connection's to be
+// released, rather than failing partway through.
+func (c *instrumentedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	err := retryBusy(ctx, func() error {
+		_, err := c.SQLiteConn.BeginTx(ctx, opts)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &instrumentedTx{conn: c.SQLiteConn}, nil
+}
+
+// retry runs op with retryBusy when the connection isn't in a
+// transaction. A statement in one can't be run again on its own; those
+// wait for the lock when the transaction begins.
+func (c *instrumentedConn) retry(ctx context.Context, op func() error) error {
+	if !c.SQLiteConn.AutoCommit() {
+		return op()
+	}
+	return retryBusy(ctx, op)
+}
+
+// instrumentedTx commits a transaction, trying again while readers keep
+// the database locked, and rolls it back if it still can't
+type instrumentedTx struct {
+	conn *sqlite3.SQLiteConn
+}
+
+func (tx *instrumentedTx) Commit() error {
+	ctx := context.Background()
+	err := retryBusy(ctx, func() error {
+		_, err := tx.conn.ExecContext(ctx, "COMMIT", nil)
+		return err
+	})
+	if err != nil && !tx.conn.AutoCommit() {
+		tx.conn.ExecContext(ctx, "ROLLBACK", nil)
+	}
+	return err
+}
+
+func (tx *instrumentedTx) Rollback() error {
+	_, err := tx.conn.ExecContext(context.Background(), "ROLLBACK", nil)
+	return err
+}
+
+// instrumentedStmt times the executions of a prepared statement
+type instrumentedStmt struct {
+	*sqlite3.SQLiteStmt
+	query string
+	conn  *instrumentedConn
+}
+
+func (s *instrumentedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	ctx, cancel := s.conn.connector.bound(ctx)
+	defer cancel()
+	started := time.Now()
+	var res driver.Result
+	err := s.conn.retry(ctx, func() (err error) {
+		res, err = s.SQLiteStmt.ExecContext(ctx, args)
+		return err
+	})
+	s.conn.connector.observe(s.query, args, started, rowsAffected(res, err), err)
+	return res, err
+}
+
+func (s *instrumentedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	ctx, cancel := s.conn.connector.bound(ctx)
+	started := time.Now()
+	rows, err := s.SQLiteStmt.QueryContext(ctx, args)
+	if err != nil {
+		cancel()
+		s.conn.connector.observe(s.query, args, started, 0, err)
+		return nil, err
+	}
+	return &instrumentedRows{Rows: rows, cancel: cancel, observe: func(n int64, err error) {
+		s.conn.connector.observe(s.query, args, started, n, err)
+	}}, nil
+}
+
+// instrumentedRows counts the rows read from a query. SQLite steps
+// through results as they're read, so the query is observed, and its
+// timeout released, when the rows are closed.
+type instrumentedRows struct {
+	driver.Rows
+	n       int64
+	err     error
+	cancel  context.CancelFunc
+	observe func(n int64, err error)
+}
+
+func (r *instrumentedRows) Next(dest []driver.Value) error {
+	err := r.Rows.Next(dest)
+	switch err {
+	case nil:
+		r.n++
+	case io.EOF:
+	default:
+		r.err = err
+	}
+	return err
+}
+
+func (r *instrumentedRows) Close() error {
+	err := r.Rows.Close()
+	r.cancel()
+	if r.observe != nil {
+		r.observe(r.n, r.err)
+		r.observe = nil
+	}
+	return err
+}
+
+// rowsAffected returns how many rows a statement changed, or 0 when that
+// isn't known
+func rowsAffected(res driver.Result, err error) int64 {
+	if err != nil {
+		return 0
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0
+	}
+	return n
+}
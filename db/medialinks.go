@@ -0,0 +1,99 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/mojotx/apt-eval/models"
+)
+
+const selectMediaLinksQuery = `
+	SELECT id, apartment_id, url, provider, title, author_name, thumbnail_url, embed_html,
+	       width, height, fetched_at, created_at
+	FROM media_links`
+
+func scanMediaLink(row scanner) (*models.MediaLink, error) {
+	var l models.MediaLink
+	err := row.Scan(&l.ID, &l.ApartmentID, &l.URL, &l.Provider, &l.Title, &l.AuthorName, &l.ThumbnailURL,
+		&l.EmbedHTML, &l.Width, &l.Height, &l.FetchedAt, &l.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+// ListMediaLinks returns an apartment's media links in the order they were
+// added
+func (db *DB) ListMediaLinks(apartmentID int64) ([]models.MediaLink, error) {
+	rows, err := db.Query(selectMediaLinksQuery+" WHERE apartment_id = ? ORDER BY id", apartmentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list media links: %w", err)
+	}
+	defer rows.Close()
+
+	links := []models.MediaLink{}
+	for rows.Next() {
+		l, err := scanMediaLink(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan media link row: %w", err)
+		}
+		links = append(links, *l)
+	}
+	return links, rows.Err()
+}
+
+// GetMediaLink retrieves one of an apartment's media links, or nil if it
+// doesn't exist
+func (db *DB) GetMediaLink(apartmentID, id int64) (*models.MediaLink, error) {
+	l, err := scanMediaLink(db.QueryRow(selectMediaLinksQuery+" WHERE apartment_id = ? AND id = ?", apartmentID, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get media link: %w", err)
+	}
+	return l, nil
+}
+
+// CreateMediaLink records a media link along with whatever metadata has
+// been fetched for it
+func (db *DB) CreateMediaLink(l *models.MediaLink) (*models.MediaLink, error) {
+	var id int64
+	err := db.QueryRow(`
+		INSERT INTO media_links (apartment_id, url, provider, title, author_name, thumbnail_url, embed_html,
+		                         width, height, fetched_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`,
+		l.ApartmentID, l.URL, l.Provider, l.Title, l.AuthorName, l.ThumbnailURL, l.EmbedHTML,
+		l.Width, l.Height, l.FetchedAt,
+	).Scan(&id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create media link: %w", err)
+	}
+	return db.GetMediaLink(l.ApartmentID, id)
+}
+
+// UpdateMediaLinkMetadata replaces the fetched metadata of a media link
+func (db *DB) UpdateMediaLinkMetadata(l *models.MediaLink) (*models.MediaLink, error) {
+	_, err := db.Exec(`
+		UPDATE media_links
+		SET title = ?, author_name = ?, thumbnail_url = ?, embed_html = ?, width = ?, height = ?, fetched_at = ?
+		WHERE apartment_id = ? AND id = ?`,
+		l.Title, l.AuthorName, l.ThumbnailURL, l.EmbedHTML, l.Width, l.Height, l.FetchedAt, l.ApartmentID, l.ID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update media link: %w", err)
+	}
+	return db.GetMediaLink(l.ApartmentID, l.ID)
+}
+
+// DeleteMediaLink removes a media link
+func (db *DB) DeleteMediaLink(apartmentID, id int64) error {
+	result, err := db.Exec("DELETE FROM media_links WHERE apartment_id = ? AND id = ?", apartmentID, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete media link: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
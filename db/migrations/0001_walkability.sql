@@ -0,0 +1,6 @@
+ALTER TABLE apartments ADD COLUMN latitude REAL;
+ALTER TABLE apartments ADD COLUMN longitude REAL;
+ALTER TABLE apartments ADD COLUMN walk_score INTEGER;
+ALTER TABLE apartments ADD COLUMN bike_score INTEGER;
+ALTER TABLE apartments ADD COLUMN transit_score INTEGER;
+ALTER TABLE apartments ADD COLUMN walkability_updated_at TIMESTAMP;
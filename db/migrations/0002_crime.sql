@@ -0,0 +1,12 @@
+ALTER TABLE apartments ADD COLUMN crime_incidents INTEGER;
+ALTER TABLE apartments ADD COLUMN safety TEXT;
+ALTER TABLE apartments ADD COLUMN crime_updated_at TIMESTAMP;
+
+-- Crime statistics are area-level, so they're cached per geohash and
+-- shared by every apartment in the cell
+CREATE TABLE IF NOT EXISTS crime_stats_cache (
+    geohash TEXT PRIMARY KEY,
+    source TEXT NOT NULL,
+    incidents INTEGER NOT NULL,
+    fetched_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,3 @@
+ALTER TABLE apartments ADD COLUMN school_district TEXT;
+ALTER TABLE apartments ADD COLUMN school_rating REAL;
+ALTER TABLE apartments ADD COLUMN schools_updated_at TIMESTAMP;
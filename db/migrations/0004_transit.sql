@@ -0,0 +1,4 @@
+ALTER TABLE apartments ADD COLUMN transit_stop TEXT;
+ALTER TABLE apartments ADD COLUMN transit_walk_minutes INTEGER;
+ALTER TABLE apartments ADD COLUMN transit_lines TEXT;
+ALTER TABLE apartments ADD COLUMN transit_updated_at TIMESTAMP;
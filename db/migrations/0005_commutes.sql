@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS destinations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    address TEXT,
+    latitude REAL NOT NULL,
+    longitude REAL NOT NULL,
+    mode TEXT NOT NULL,
+    weight REAL NOT NULL DEFAULT 1,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS commutes (
+    apartment_id INTEGER NOT NULL,
+    destination_id INTEGER NOT NULL,
+    minutes INTEGER NOT NULL,
+    distance_m INTEGER NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (apartment_id, destination_id)
+);
+
+ALTER TABLE apartments ADD COLUMN commute_score REAL;
+ALTER TABLE apartments ADD COLUMN commute_updated_at TIMESTAMP;
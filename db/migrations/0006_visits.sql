@@ -0,0 +1,15 @@
+-- Observations recorded on each visit to an apartment
+CREATE TABLE IF NOT EXISTS visits (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    apartment_id INTEGER NOT NULL,
+    visited_at TIMESTAMP NOT NULL,
+    noise_level INTEGER,
+    natural_light INTEGER,
+    smell_notes TEXT NOT NULL DEFAULT '',
+    facing TEXT,
+    notes TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS visits_apartment_id ON visits (apartment_id, visited_at);
@@ -0,0 +1,14 @@
+-- One floor plan per apartment; the file itself lives in storage under
+-- floorplans/<apartment_id>
+CREATE TABLE IF NOT EXISTS floor_plans (
+    apartment_id INTEGER PRIMARY KEY,
+    filename TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    size INTEGER NOT NULL,
+    width INTEGER,
+    height INTEGER,
+    pages INTEGER,
+    rooms TEXT NOT NULL DEFAULT '[]',
+    uploaded_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
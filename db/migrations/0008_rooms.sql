@@ -0,0 +1,28 @@
+CREATE TABLE IF NOT EXISTS rooms (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    apartment_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    kind TEXT NOT NULL,
+    rating INTEGER,
+    width_m REAL,
+    length_m REAL,
+    area_m2 REAL,
+    notes TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS rooms_apartment_id ON rooms (apartment_id);
+
+-- Average of the rated rooms, maintained when rooms change
+ALTER TABLE apartments ADD COLUMN room_rating REAL;
+
+-- Blends the apartment's own rating with its room ratings, using
+-- whichever is available when only one is
+ALTER TABLE apartments ADD COLUMN overall_rating REAL GENERATED ALWAYS AS (
+    CASE
+        WHEN room_rating IS NULL THEN NULLIF(rating, 0)
+        WHEN COALESCE(rating, 0) = 0 THEN room_rating
+        ELSE ROUND((rating + room_rating) / 2.0, 2)
+    END
+) VIRTUAL;
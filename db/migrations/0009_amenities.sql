@@ -0,0 +1,38 @@
+-- Amenities move from fixed boolean columns to an extensible taxonomy
+CREATE TABLE IF NOT EXISTS amenities (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    key TEXT NOT NULL UNIQUE,
+    name TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS apartment_amenities (
+    apartment_id INTEGER NOT NULL,
+    amenity_id INTEGER NOT NULL,
+    PRIMARY KEY (apartment_id, amenity_id)
+);
+
+INSERT INTO amenities (key, name) VALUES
+    ('gated', 'Gated community'),
+    ('garage', 'Garage'),
+    ('laundry', 'In-unit laundry'),
+    ('dishwasher', 'Dishwasher'),
+    ('ac', 'Air conditioning'),
+    ('balcony', 'Balcony'),
+    ('ev_charging', 'EV charging'),
+    ('bike_storage', 'Bike storage'),
+    ('elevator', 'Elevator'),
+    ('pool', 'Pool'),
+    ('gym', 'Gym'),
+    ('pets', 'Pets allowed');
+
+INSERT INTO apartment_amenities (apartment_id, amenity_id)
+SELECT id, (SELECT id FROM amenities WHERE key = 'gated') FROM apartments WHERE is_gated;
+INSERT INTO apartment_amenities (apartment_id, amenity_id)
+SELECT id, (SELECT id FROM amenities WHERE key = 'garage') FROM apartments WHERE has_garage;
+INSERT INTO apartment_amenities (apartment_id, amenity_id)
+SELECT id, (SELECT id FROM amenities WHERE key = 'laundry') FROM apartments WHERE has_laundry;
+
+ALTER TABLE apartments DROP COLUMN is_gated;
+ALTER TABLE apartments DROP COLUMN has_garage;
+ALTER TABLE apartments DROP COLUMN has_laundry;
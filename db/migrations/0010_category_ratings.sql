@@ -0,0 +1,21 @@
+ALTER TABLE apartments ADD COLUMN rating_location INTEGER;
+ALTER TABLE apartments ADD COLUMN rating_condition INTEGER;
+ALTER TABLE apartments ADD COLUMN rating_kitchen INTEGER;
+ALTER TABLE apartments ADD COLUMN rating_noise INTEGER;
+ALTER TABLE apartments ADD COLUMN rating_value INTEGER;
+
+-- Weighted average of the category ratings; rating is kept in step with
+-- it for older clients
+ALTER TABLE apartments ADD COLUMN score REAL;
+
+CREATE TABLE IF NOT EXISTS rating_weights (
+    category TEXT PRIMARY KEY,
+    weight REAL NOT NULL
+);
+
+INSERT INTO rating_weights (category, weight) VALUES
+    ('location', 1),
+    ('condition', 1),
+    ('kitchen', 1),
+    ('noise', 1),
+    ('value', 1);
@@ -0,0 +1,14 @@
+-- User-defined evaluation forms; at most one is active at a time
+CREATE TABLE IF NOT EXISTS templates (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    fields TEXT NOT NULL,
+    active INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_templates_active ON templates (active) WHERE active;
+
+-- Answers to the template's questions, as a JSON object keyed by field
+ALTER TABLE apartments ADD COLUMN answers TEXT;
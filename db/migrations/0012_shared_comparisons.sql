@@ -0,0 +1,9 @@
+-- Read-only comparison snapshots reachable by an unguessable token
+CREATE TABLE IF NOT EXISTS shared_comparisons (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    token TEXT NOT NULL UNIQUE,
+    title TEXT NOT NULL DEFAULT '',
+    snapshot TEXT NOT NULL,
+    expires_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,6 @@
+-- Where each apartment is in the search pipeline, and the listing it came
+-- from. Drafts are captured automatically and await review.
+ALTER TABLE apartments ADD COLUMN status TEXT NOT NULL DEFAULT 'considering';
+ALTER TABLE apartments ADD COLUMN listing_url TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_apartments_status ON apartments (status);
@@ -0,0 +1,13 @@
+-- Geocoding results keyed by provider and normalized query, so repeated
+-- lookups don't spend rate-limited provider requests. Misses are cached
+-- too, with found = 0.
+CREATE TABLE IF NOT EXISTS geocode_cache (
+    provider TEXT NOT NULL,
+    query TEXT NOT NULL,
+    found INTEGER NOT NULL,
+    latitude REAL,
+    longitude REAL,
+    formatted_address TEXT NOT NULL DEFAULT '',
+    fetched_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (provider, query)
+);
@@ -0,0 +1,6 @@
+-- The address in canonical form (see package address), for spotting the
+-- same apartment entered twice and for grouping. Existing rows are filled
+-- in at startup.
+ALTER TABLE apartments ADD COLUMN canonical_address TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_apartments_canonical_address ON apartments (canonical_address);
@@ -0,0 +1,8 @@
+-- The apartment's neighborhood, either entered by hand (source 'manual')
+-- or found by reverse geocoding (source is the geocoder's name). Manual
+-- values are never overwritten by the geocoder.
+ALTER TABLE apartments ADD COLUMN neighborhood TEXT NOT NULL DEFAULT '';
+ALTER TABLE apartments ADD COLUMN neighborhood_source TEXT NOT NULL DEFAULT '';
+ALTER TABLE apartments ADD COLUMN neighborhood_updated_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_apartments_neighborhood ON apartments (neighborhood);
@@ -0,0 +1,15 @@
+-- One-time and recurring costs of moving into an apartment, beyond the
+-- rent, for the move-in cost calculator
+CREATE TABLE IF NOT EXISTS move_in_costs (
+    apartment_id INTEGER PRIMARY KEY,
+    security_deposit REAL NOT NULL DEFAULT 0,
+    pet_deposit REAL NOT NULL DEFAULT 0,
+    application_fee REAL NOT NULL DEFAULT 0,
+    admin_fee REAL NOT NULL DEFAULT 0,
+    broker_fee REAL NOT NULL DEFAULT 0,
+    other_fees REAL NOT NULL DEFAULT 0,
+    mover_estimate REAL NOT NULL DEFAULT 0,
+    monthly_fees REAL NOT NULL DEFAULT 0,
+    move_in_date DATE,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,22 @@
+-- Estimated monthly utility costs, and whether the rent already includes
+-- each utility
+ALTER TABLE apartments ADD COLUMN electricity_estimate REAL;
+ALTER TABLE apartments ADD COLUMN gas_estimate REAL;
+ALTER TABLE apartments ADD COLUMN water_estimate REAL;
+ALTER TABLE apartments ADD COLUMN internet_estimate REAL;
+ALTER TABLE apartments ADD COLUMN electricity_included BOOLEAN NOT NULL DEFAULT 0;
+ALTER TABLE apartments ADD COLUMN gas_included BOOLEAN NOT NULL DEFAULT 0;
+ALTER TABLE apartments ADD COLUMN water_included BOOLEAN NOT NULL DEFAULT 0;
+ALTER TABLE apartments ADD COLUMN internet_included BOOLEAN NOT NULL DEFAULT 0;
+
+-- Rent plus the utilities it doesn't include, for comparing what
+-- apartments really cost each month. Unknown without a rent.
+ALTER TABLE apartments ADD COLUMN true_monthly_cost REAL GENERATED ALWAYS AS (
+    CASE WHEN price > 0 THEN ROUND(
+        price
+        + CASE WHEN electricity_included THEN 0 ELSE COALESCE(electricity_estimate, 0) END
+        + CASE WHEN gas_included THEN 0 ELSE COALESCE(gas_estimate, 0) END
+        + CASE WHEN water_included THEN 0 ELSE COALESCE(water_estimate, 0) END
+        + CASE WHEN internet_included THEN 0 ELSE COALESCE(internet_estimate, 0) END,
+    2) END
+) VIRTUAL;
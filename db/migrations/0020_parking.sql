@@ -0,0 +1,30 @@
+-- Structured parking details replace the garage amenity: the kind of
+-- parking (street, garage, or assigned; empty when unknown), its monthly
+-- cost, EV charging, and the distance from the spot to the unit
+ALTER TABLE apartments ADD COLUMN parking_type TEXT NOT NULL DEFAULT '';
+ALTER TABLE apartments ADD COLUMN parking_cost REAL;
+ALTER TABLE apartments ADD COLUMN parking_ev_charging BOOLEAN NOT NULL DEFAULT 0;
+ALTER TABLE apartments ADD COLUMN parking_distance_m REAL;
+
+UPDATE apartments SET parking_type = 'garage'
+WHERE id IN (
+    SELECT aa.apartment_id FROM apartment_amenities aa JOIN amenities am ON am.id = aa.amenity_id
+    WHERE am.key = 'garage'
+);
+DELETE FROM apartment_amenities WHERE amenity_id IN (SELECT id FROM amenities WHERE key = 'garage');
+DELETE FROM amenities WHERE key = 'garage';
+
+-- The true monthly cost now includes parking
+ALTER TABLE apartments DROP COLUMN true_monthly_cost;
+ALTER TABLE apartments ADD COLUMN true_monthly_cost REAL GENERATED ALWAYS AS (
+    CASE WHEN price > 0 THEN ROUND(
+        price
+        + CASE WHEN electricity_included THEN 0 ELSE COALESCE(electricity_estimate, 0) END
+        + CASE WHEN gas_included THEN 0 ELSE COALESCE(gas_estimate, 0) END
+        + CASE WHEN water_included THEN 0 ELSE COALESCE(water_estimate, 0) END
+        + CASE WHEN internet_included THEN 0 ELSE COALESCE(internet_estimate, 0) END
+        + COALESCE(parking_cost, 0),
+    2) END
+) VIRTUAL;
+
+CREATE INDEX IF NOT EXISTS idx_apartments_parking_type ON apartments (parking_type);
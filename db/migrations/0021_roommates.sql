@@ -0,0 +1,25 @@
+-- The people sharing the rent, and how it's divided between them
+CREATE TABLE IF NOT EXISTS roommates (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    percent REAL, -- Share under the custom split rule
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- The household's split rule: equal, room_size, or custom
+CREATE TABLE IF NOT EXISTS rent_split (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    rule TEXT NOT NULL
+);
+
+INSERT INTO rent_split (id, rule) VALUES (1, 'equal');
+
+-- Which room each roommate would take in an apartment, for splitting by
+-- room size
+CREATE TABLE IF NOT EXISTS roommate_rooms (
+    apartment_id INTEGER NOT NULL,
+    roommate_id INTEGER NOT NULL,
+    room_id INTEGER NOT NULL,
+    PRIMARY KEY (apartment_id, roommate_id)
+);
@@ -0,0 +1,16 @@
+-- Offers and counteroffers exchanged while negotiating the rent
+CREATE TABLE IF NOT EXISTS offers (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    apartment_id INTEGER NOT NULL,
+    offered_at TIMESTAMP NOT NULL,
+    amount REAL NOT NULL,
+    who TEXT NOT NULL, -- us or landlord
+    notes TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS offers_apartment_id ON offers (apartment_id);
+
+-- Lowest rent the landlord has offered, maintained when offers change
+ALTER TABLE apartments ADD COLUMN best_offer REAL;
@@ -0,0 +1,11 @@
+-- When an application is due and when a unit being held for us is
+-- released, each with the time its reminder was queued
+ALTER TABLE apartments ADD COLUMN application_deadline TIMESTAMP;
+ALTER TABLE apartments ADD COLUMN hold_expires TIMESTAMP;
+ALTER TABLE apartments ADD COLUMN application_deadline_reminded_at TIMESTAMP;
+ALTER TABLE apartments ADD COLUMN hold_expires_reminded_at TIMESTAMP;
+
+ALTER TABLE users ADD COLUMN notify_deadlines INTEGER NOT NULL DEFAULT 1;
+
+-- Set on deadline reminders, so they can be dropped when a deadline moves
+ALTER TABLE notifications ADD COLUMN apartment_id INTEGER;
@@ -0,0 +1,12 @@
+-- Manual ordering of apartments within their status column on the board
+ALTER TABLE apartments ADD COLUMN board_position INTEGER;
+
+-- Existing columns start out oldest first
+UPDATE apartments SET board_position = ranked.position
+FROM (
+    SELECT id, ROW_NUMBER() OVER (PARTITION BY status ORDER BY created_at, id) - 1 AS position
+    FROM apartments
+) AS ranked
+WHERE apartments.id = ranked.id;
+
+CREATE INDEX IF NOT EXISTS apartments_board_position ON apartments (status, board_position);
@@ -0,0 +1,31 @@
+-- Buildings group units in the same complex, holding what they share
+CREATE TABLE IF NOT EXISTS buildings (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL DEFAULT '',
+    address TEXT NOT NULL,
+    canonical_address TEXT NOT NULL DEFAULT '',
+    management_company TEXT NOT NULL DEFAULT '',
+    management_contact TEXT NOT NULL DEFAULT '',
+    management_phone TEXT NOT NULL DEFAULT '',
+    management_email TEXT NOT NULL DEFAULT '',
+    notes TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS building_amenities (
+    building_id INTEGER NOT NULL,
+    amenity_id INTEGER NOT NULL,
+    PRIMARY KEY (building_id, amenity_id)
+);
+
+ALTER TABLE apartments ADD COLUMN building_id INTEGER;
+
+CREATE INDEX IF NOT EXISTS apartments_building_id ON apartments (building_id);
+
+-- Each unit's amenities: its own plus those of its building
+CREATE VIEW IF NOT EXISTS unit_amenities AS
+    SELECT apartment_id, amenity_id FROM apartment_amenities
+    UNION
+    SELECT a.id, ba.amenity_id
+    FROM apartments a JOIN building_amenities ba ON ba.building_id = a.building_id;
@@ -0,0 +1,23 @@
+-- Photos and documents attached to an apartment; files live in storage
+-- under attachments/<id>, or quarantine/attachments/<id> when the virus
+-- scanner flagged them
+CREATE TABLE IF NOT EXISTS attachments (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    apartment_id INTEGER NOT NULL,
+    kind TEXT NOT NULL,
+    filename TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    size INTEGER NOT NULL,
+    width INTEGER,
+    height INTEGER,
+    pages INTEGER,
+    scan_status TEXT NOT NULL DEFAULT 'unscanned',
+    scan_signature TEXT NOT NULL DEFAULT '',
+    scanned_at TIMESTAMP,
+    uploaded_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS attachments_apartment_id ON attachments (apartment_id);
+
+ALTER TABLE floor_plans ADD COLUMN scan_status TEXT NOT NULL DEFAULT 'unscanned';
+ALTER TABLE floor_plans ADD COLUMN scanned_at TIMESTAMP;
@@ -0,0 +1,3 @@
+-- Where photos were taken, from their GPS metadata before it's stripped
+ALTER TABLE attachments ADD COLUMN latitude REAL;
+ALTER TABLE attachments ADD COLUMN longitude REAL;
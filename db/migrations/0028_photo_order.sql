@@ -0,0 +1,12 @@
+-- Manual gallery order of each apartment's attachments, and the photo
+-- chosen to represent the apartment
+ALTER TABLE attachments ADD COLUMN sort_order INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE attachments ADD COLUMN is_cover BOOLEAN NOT NULL DEFAULT 0;
+
+-- Existing attachments start out oldest first
+UPDATE attachments SET sort_order = ranked.position
+FROM (
+    SELECT id, ROW_NUMBER() OVER (PARTITION BY apartment_id ORDER BY uploaded_at, id) - 1 AS position
+    FROM attachments
+) AS ranked
+WHERE attachments.id = ranked.id;
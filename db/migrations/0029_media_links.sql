@@ -0,0 +1,19 @@
+-- Video walkthroughs and 3D tours linked to an apartment, with the oEmbed
+-- metadata fetched from their provider; fetched_at is NULL until a lookup
+-- has succeeded
+CREATE TABLE IF NOT EXISTS media_links (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    apartment_id INTEGER NOT NULL,
+    url TEXT NOT NULL,
+    provider TEXT NOT NULL,
+    title TEXT NOT NULL DEFAULT '',
+    author_name TEXT NOT NULL DEFAULT '',
+    thumbnail_url TEXT NOT NULL DEFAULT '',
+    embed_html TEXT NOT NULL DEFAULT '',
+    width INTEGER,
+    height INTEGER,
+    fetched_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS media_links_apartment_id ON media_links (apartment_id);
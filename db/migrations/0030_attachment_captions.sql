@@ -0,0 +1,3 @@
+-- A short description of what an attachment shows, e.g. "water stain
+-- under the kitchen sink"
+ALTER TABLE attachments ADD COLUMN caption TEXT NOT NULL DEFAULT '';
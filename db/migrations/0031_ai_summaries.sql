@@ -0,0 +1,13 @@
+-- Pros and cons summaries generated by a language model, one per
+-- apartment. input_hash identifies the notes, visits, captions, and model
+-- a summary was made from, so it's only regenerated when they change.
+CREATE TABLE IF NOT EXISTS ai_summaries (
+    apartment_id INTEGER PRIMARY KEY,
+    input_hash TEXT NOT NULL,
+    provider TEXT NOT NULL,
+    model TEXT NOT NULL,
+    summary TEXT NOT NULL,
+    pros TEXT NOT NULL DEFAULT '[]', -- JSON array
+    cons TEXT NOT NULL DEFAULT '[]', -- JSON array
+    generated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
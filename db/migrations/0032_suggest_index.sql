@@ -0,0 +1,24 @@
+-- The typeahead index: the addresses, neighborhoods, building names, and
+-- amenity names worth suggesting, each with its normalized text, and the
+-- trigrams of that text for matching misspellings. owner_id is the
+-- apartment, building, or amenity the term came from. Rows are rebuilt by
+-- the application whenever their owner is written.
+CREATE TABLE IF NOT EXISTS suggest_terms (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    kind TEXT NOT NULL,
+    owner_id INTEGER NOT NULL,
+    term TEXT NOT NULL,
+    norm TEXT NOT NULL,
+    trigram_count INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS suggest_terms_owner ON suggest_terms (kind, owner_id);
+CREATE INDEX IF NOT EXISTS suggest_terms_norm ON suggest_terms (norm);
+
+CREATE TABLE IF NOT EXISTS suggest_trigrams (
+    trigram TEXT NOT NULL,
+    term_id INTEGER NOT NULL,
+    PRIMARY KEY (trigram, term_id)
+) WITHOUT ROWID;
+
+CREATE INDEX IF NOT EXISTS suggest_trigrams_term_id ON suggest_trigrams (term_id);
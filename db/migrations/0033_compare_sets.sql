@@ -0,0 +1,17 @@
+-- Named groups of apartments to compare, like "finalists", kept in the
+-- order they were listed
+CREATE TABLE IF NOT EXISTS compare_sets (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS compare_set_apartments (
+    set_id INTEGER NOT NULL,
+    apartment_id INTEGER NOT NULL,
+    position INTEGER NOT NULL,
+    PRIMARY KEY (set_id, apartment_id)
+);
+
+CREATE INDEX IF NOT EXISTS compare_set_apartments_apartment_id ON compare_set_apartments (apartment_id);
@@ -0,0 +1,6 @@
+-- Who may see each apartment: only its owner (private), the household, or
+-- the household and anyone sent a share link (shared). owner_id is the
+-- user who added it, when known. Existing apartments become household
+-- apartments; sharing one takes making it shared.
+ALTER TABLE apartments ADD COLUMN visibility TEXT NOT NULL DEFAULT 'household';
+ALTER TABLE apartments ADD COLUMN owner_id INTEGER;
@@ -0,0 +1,21 @@
+-- Discussion threads on apartments. author_id is null for comments made
+-- without saying who is commenting.
+CREATE TABLE IF NOT EXISTS comments (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    apartment_id INTEGER NOT NULL,
+    author_id INTEGER,
+    body TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS comments_apartment_id ON comments (apartment_id, id);
+
+-- The users each comment @mentions
+CREATE TABLE IF NOT EXISTS comment_mentions (
+    comment_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    PRIMARY KEY (comment_id, user_id)
+);
+
+ALTER TABLE users ADD COLUMN notify_mentions INTEGER NOT NULL DEFAULT 1;
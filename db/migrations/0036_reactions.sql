@@ -0,0 +1,10 @@
+-- Quick opinions on apartments, at most one of each kind per user
+CREATE TABLE IF NOT EXISTS reactions (
+    apartment_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    reaction TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (apartment_id, reaction, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS reactions_user_id ON reactions (user_id);
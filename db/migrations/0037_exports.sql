@@ -0,0 +1,10 @@
+-- The record each exporter created for each apartment in the outside
+-- service, with a hash of the fields last sent so unchanged rows are skipped
+CREATE TABLE IF NOT EXISTS export_records (
+    exporter TEXT NOT NULL,
+    apartment_id INTEGER NOT NULL,
+    remote_id TEXT NOT NULL,
+    hash TEXT NOT NULL,
+    synced_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (exporter, apartment_id)
+);
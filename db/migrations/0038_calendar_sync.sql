@@ -0,0 +1,26 @@
+-- The Google Calendar visits are synced with, at most one
+CREATE TABLE IF NOT EXISTS calendar_connection (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    calendar_id TEXT NOT NULL,
+    refresh_token TEXT NOT NULL,
+    access_token TEXT NOT NULL DEFAULT '',
+    expires_at TIMESTAMP,
+    sync_token TEXT NOT NULL DEFAULT '', -- Where the last pull of changes left off
+    connected_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Each visit's calendar event: NULL until it's on the calendar, and empty
+-- once the event was deleted in the calendar, so it isn't put back. The
+-- hash is of the event as last written, and the start is the event's as
+-- last written or read, so a move in the calendar can be told apart from
+-- a change in the app that hasn't been pushed yet.
+ALTER TABLE visits ADD COLUMN calendar_event_id TEXT;
+ALTER TABLE visits ADD COLUMN calendar_hash TEXT NOT NULL DEFAULT '';
+ALTER TABLE visits ADD COLUMN calendar_start TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS visits_calendar_event_id ON visits (calendar_event_id);
+
+-- Events of deleted visits, waiting to be removed from the calendar
+CREATE TABLE IF NOT EXISTS calendar_deletions (
+    event_id TEXT PRIMARY KEY
+);
@@ -0,0 +1,28 @@
+-- The latest change to each apartment, for offline clients syncing since a
+-- version. Every insert, update, and delete moves the apartment's row to a
+-- new, higher seq, which is its version; rows of deleted apartments are
+-- kept as tombstones.
+CREATE TABLE IF NOT EXISTS apartment_changes (
+    seq INTEGER PRIMARY KEY AUTOINCREMENT,
+    apartment_id INTEGER NOT NULL UNIQUE,
+    deleted BOOLEAN NOT NULL DEFAULT 0,
+    changed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO apartment_changes (apartment_id)
+SELECT id FROM apartments ORDER BY updated_at, id;
+
+CREATE TRIGGER IF NOT EXISTS apartments_changes_insert AFTER INSERT ON apartments
+BEGIN
+    INSERT OR REPLACE INTO apartment_changes (apartment_id) VALUES (NEW.id);
+END;
+
+CREATE TRIGGER IF NOT EXISTS apartments_changes_update AFTER UPDATE ON apartments
+BEGIN
+    INSERT OR REPLACE INTO apartment_changes (apartment_id) VALUES (NEW.id);
+END;
+
+CREATE TRIGGER IF NOT EXISTS apartments_changes_delete AFTER DELETE ON apartments
+BEGIN
+    INSERT OR REPLACE INTO apartment_changes (apartment_id, deleted) VALUES (OLD.id, 1);
+END;
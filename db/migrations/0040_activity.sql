@@ -0,0 +1,15 @@
+-- The household's activity feed: who did what to which apartment. The
+-- address is kept as it was so entries outlive the apartment, and
+-- private_to is the owner of an apartment that was private at the time.
+CREATE TABLE IF NOT EXISTS activity (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER, -- NULL when done without X-User-ID
+    action TEXT NOT NULL,
+    apartment_id INTEGER NOT NULL,
+    address TEXT NOT NULL,
+    detail TEXT NOT NULL DEFAULT '',
+    private_to INTEGER,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS activity_apartment_id ON activity (apartment_id);
@@ -0,0 +1,4 @@
+-- What's needed to undo an activity: the apartment before and after the
+-- change, as JSON, or NULL when the activity can't be undone
+ALTER TABLE activity ADD COLUMN undo_data TEXT;
+ALTER TABLE activity ADD COLUMN undone_at TIMESTAMP;
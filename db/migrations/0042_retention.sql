@@ -0,0 +1,6 @@
+-- The version of the newest sync tombstone purged by retention; clients
+-- syncing since an older version may have missed deletions
+CREATE TABLE IF NOT EXISTS sync_horizon (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    seq INTEGER NOT NULL
+);
@@ -0,0 +1,11 @@
+-- Users who sign in through an identity provider, by the provider's
+-- stable ID for them
+CREATE TABLE IF NOT EXISTS sso_identities (
+    provider TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (provider, subject)
+);
+
+CREATE INDEX IF NOT EXISTS idx_sso_identities_user ON sso_identities(user_id);
@@ -0,0 +1,5 @@
+-- Rating, price, and floor are NULL when unknown. Older clients sent 0
+-- for "not entered", which is never a real rating, rent, or floor here.
+UPDATE apartments SET rating = NULL WHERE rating = 0;
+UPDATE apartments SET price = NULL WHERE price = 0;
+UPDATE apartments SET floor = NULL WHERE floor = 0;
@@ -0,0 +1,7 @@
+-- The unit number, kept apart from the street address, and the canonical
+-- address of the building without it, for grouping units of a building.
+-- Existing addresses with a unit in them are split at startup.
+ALTER TABLE apartments ADD COLUMN unit TEXT NOT NULL DEFAULT '';
+ALTER TABLE apartments ADD COLUMN building_address TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_apartments_building_address ON apartments (building_address);
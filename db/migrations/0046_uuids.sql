@@ -0,0 +1,34 @@
+-- A random public ID for each apartment, so links and sync clients can
+-- name one without revealing how many there are. The change log keeps it
+-- too, so a deleted apartment's tombstone can still be found by it.
+ALTER TABLE apartments ADD COLUMN uuid TEXT;
+ALTER TABLE apartment_changes ADD COLUMN apartment_uuid TEXT;
+
+DROP TRIGGER IF EXISTS apartments_changes_insert;
+DROP TRIGGER IF EXISTS apartments_changes_update;
+DROP TRIGGER IF EXISTS apartments_changes_delete;
+
+CREATE TRIGGER IF NOT EXISTS apartments_changes_insert AFTER INSERT ON apartments
+BEGIN
+    INSERT OR REPLACE INTO apartment_changes (apartment_id, apartment_uuid) VALUES (NEW.id, NEW.uuid);
+END;
+
+CREATE TRIGGER IF NOT EXISTS apartments_changes_update AFTER UPDATE ON apartments
+BEGIN
+    INSERT OR REPLACE INTO apartment_changes (apartment_id, apartment_uuid) VALUES (NEW.id, NEW.uuid);
+END;
+
+CREATE TRIGGER IF NOT EXISTS apartments_changes_delete AFTER DELETE ON apartments
+BEGIN
+    INSERT OR REPLACE INTO apartment_changes (apartment_id, apartment_uuid, deleted) VALUES (OLD.id, OLD.uuid, 1);
+END;
+
+-- Version 4 UUIDs. The update trigger copies them into the change log,
+-- moving every apartment to a new version so sync clients get them.
+UPDATE apartments SET uuid = lower(
+    hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' ||
+    substr('89ab', 1 + (abs(random()) % 4), 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6))
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_apartments_uuid ON apartments (uuid);
+CREATE INDEX IF NOT EXISTS idx_apartment_changes_apartment_uuid ON apartment_changes (apartment_uuid);
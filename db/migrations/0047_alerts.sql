@@ -0,0 +1,27 @@
+-- Rules that text a user when apartments meet a condition. New-match
+-- rules remember the newest apartment they've looked at; price alerts
+-- remember the price each apartment last set them off at, so they go
+-- off again only when it drops further.
+CREATE TABLE IF NOT EXISTS alert_rules (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    name TEXT NOT NULL DEFAULT '',
+    kind TEXT NOT NULL,
+    price REAL,
+    filter TEXT NOT NULL DEFAULT '',
+    last_apartment_id INTEGER NOT NULL DEFAULT 0,
+    last_fired_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_alert_rules_user ON alert_rules(user_id);
+
+CREATE TABLE IF NOT EXISTS alert_hits (
+    rule_id INTEGER NOT NULL REFERENCES alert_rules(id),
+    apartment_id INTEGER NOT NULL REFERENCES apartments(id),
+    price REAL NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (rule_id, apartment_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_alert_hits_apartment ON alert_hits(apartment_id);
@@ -0,0 +1,6 @@
+-- The going rent for the apartment's bedroom count in its area, from the
+-- configured rent benchmark
+ALTER TABLE apartments ADD COLUMN market_rent REAL;
+ALTER TABLE apartments ADD COLUMN market_rent_area TEXT;
+ALTER TABLE apartments ADD COLUMN market_rent_bedrooms INTEGER;
+ALTER TABLE apartments ADD COLUMN market_rent_updated_at TIMESTAMP;
@@ -0,0 +1,6 @@
+-- Homes for sale, entered alongside the rentals to weigh buying against
+-- renting
+ALTER TABLE apartments ADD COLUMN listing_type TEXT NOT NULL DEFAULT 'rent';
+ALTER TABLE apartments ADD COLUMN purchase_price REAL;
+ALTER TABLE apartments ADD COLUMN hoa_fee REAL;
+ALTER TABLE apartments ADD COLUMN property_tax REAL;
@@ -0,0 +1,117 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/mojotx/apt-eval/filter"
+	"github.com/mojotx/apt-eval/models"
+)
+
+// applyNeighborhood stores a hand-entered neighborhood from req, if any.
+// Clearing it also clears the lookup time so the next enrichment refresh
+// reverse geocodes it again.
+func applyNeighborhood(tx *sql.Tx, id int64, req *models.ApartmentRequest) error {
+	if req.Neighborhood == nil {
+		return nil
+	}
+
+	var err error
+	if *req.Neighborhood == "" {
+		_, err = tx.Exec(`
+			UPDATE apartments
+			SET neighborhood = '', neighborhood_source = '', neighborhood_updated_at = NULL
+			WHERE id = ? AND neighborhood_source = ?`,
+			id, models.NeighborhoodManual,
+		)
+	} else {
+		_, err = tx.Exec(`
+			UPDATE apartments
+			SET neighborhood = ?, neighborhood_source = ?, neighborhood_updated_at = CURRENT_TIMESTAMP
+			WHERE id = ?`,
+			*req.Neighborhood, models.NeighborhoodManual, id,
+		)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to set neighborhood: %w", err)
+	}
+	return nil
+}
+
+// SetNeighborhood stores the neighborhood found for an apartment by source.
+// A hand-entered neighborhood is kept, and only its lookup time updated.
+func (db *DB) SetNeighborhood(id int64, neighborhood, source string) error {
+	_, err := db.Exec(`
+		UPDATE apartments
+		SET neighborhood = CASE WHEN neighborhood_source = ? THEN neighborhood ELSE ? END,
+		    neighborhood_source = CASE WHEN neighborhood_source = ? THEN neighborhood_source ELSE ? END,
+		    neighborhood_updated_at = CURRENT_TIMESTAMP,
+		    updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?`,
+		models.NeighborhoodManual, neighborhood, models.NeighborhoodManual, source, id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to set neighborhood: %w", err)
+	}
+	if err := indexApartment(db, id); err != nil {
+		return err
+	}
+
+	db.changed()
+	return nil
+}
+
+// GroupApartments summarizes the apartments viewer may see by the value of
+// a text field in ApartmentFields: how many share each value, their
+// average price, and the best rated of them. Groups are ordered largest
+// first.
+func (db *DB) GroupApartments(field string, viewer int64) ([]models.ApartmentGroup, error) {
+	f, ok := ApartmentFields[field]
+	if !ok || f.Type != filter.Text {
+		return nil, fmt.Errorf("cannot group by %q", field)
+	}
+
+	// The best rated apartment ranks first by overall rating, then by
+	// category score, with the cheaper one winning ties
+	rows, err := db.Query(fmt.Sprintf(`
+		WITH grouped AS (
+			SELECT id, COALESCE(%[1]s, '') AS key,
+			       COUNT(*) OVER w AS count,
+			       AVG(NULLIF(price, 0)) OVER w AS average_price,
+			       ROW_NUMBER() OVER (w ORDER BY overall_rating DESC NULLS LAST, score DESC NULLS LAST,
+			                          NULLIF(price, 0) NULLS LAST, id) AS rank
+			FROM apartments
+			WHERE %[2]s
+			WINDOW w AS (PARTITION BY COALESCE(%[1]s, ''))
+		)
+		SELECT key, count, average_price, id FROM grouped
+		WHERE rank = 1
+		ORDER BY count DESC, key`, f.Column, visibleTo), viewer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to group apartments: %w", err)
+	}
+
+	groups := []models.ApartmentGroup{}
+	var bestIDs []int64
+	for rows.Next() {
+		var g models.ApartmentGroup
+		var bestID int64
+		if err := rows.Scan(&g.Key, &g.Count, &g.AveragePrice, &bestID); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan apartment group row: %w", err)
+		}
+		groups = append(groups, g)
+		bestIDs = append(bestIDs, bestID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during row iteration: %w", err)
+	}
+
+	for i, id := range bestIDs {
+		if groups[i].BestRated, err = db.GetApartment(id); err != nil {
+			return nil, err
+		}
+	}
+	return groups, nil
+}
@@ -0,0 +1,207 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mojotx/apt-eval/address"
+	"github.com/mojotx/apt-eval/models"
+)
+
+// statusChangeTTL is how long a status change stays worth sending; older
+// ones are dropped rather than delivered after a long outage
+const statusChangeTTL = 24 * time.Hour
+
+const selectNotificationsQuery = `
+	SELECT id, user_id, event, body, status, attempts, error, expires_at, sent_at, created_at
+	FROM notifications`
+
+func scanNotification(row scanner, extra ...any) (*models.Notification, error) {
+	var n models.Notification
+	dest := append([]any{&n.ID, &n.UserID, &n.Event, &n.Body, &n.Status, &n.Attempts, &n.Error,
+		&n.ExpiresAt, &n.SentAt, &n.CreatedAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+// enqueueStatusChange queues a status change message for every user who
+// wants them and may see the apartment
+func enqueueStatusChange(q queryer, apartmentID int64, address, from, to string) error {
+	body := fmt.Sprintf("%s moved from %s to %s", address, from, to)
+	_, err := q.Exec(`
+		INSERT INTO notifications (user_id, event, body, expires_at)
+		SELECT users.id, ?, ?, ? FROM users JOIN apartments ON apartments.id = ?
+		WHERE notify_status AND phone != '' AND `+visibleToUser("users.id"),
+		models.EventStatusChange, body, time.Now().UTC().Add(statusChangeTTL), apartmentID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue status change: %w", err)
+	}
+	return nil
+}
+
+// ListNotifications returns a user's most recent notifications, newest first
+func (db *DB) ListNotifications(userID int64, limit int) ([]models.Notification, error) {
+	rows, err := db.Query(selectNotificationsQuery+" WHERE user_id = ? ORDER BY id DESC LIMIT ?", userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list notifications: %w", err)
+	}
+	defer rows.Close()
+
+	notifications := []models.Notification{}
+	for rows.Next() {
+		n, err := scanNotification(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan notification row: %w", err)
+		}
+		notifications = append(notifications, *n)
+	}
+	return notifications, rows.Err()
+}
+
+// PendingNotifications returns up to limit unsent notifications, oldest
+// first, along with their recipients
+func (db *DB) PendingNotifications(limit int) ([]models.OutgoingNotification, error) {
+	rows, err := db.Query(`
+		SELECT n.id, n.user_id, n.event, n.body, n.status, n.attempts, n.error, n.expires_at, n.sent_at, n.created_at,
+		       u.name, u.phone, u.timezone, u.quiet_start, u.quiet_end
+		FROM notifications n
+		JOIN users u ON u.id = n.user_id
+		WHERE n.status = ?
+		ORDER BY n.id
+		LIMIT ?`, models.NotificationPending, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pending notifications: %w", err)
+	}
+	defer rows.Close()
+
+	pending := []models.OutgoingNotification{}
+	for rows.Next() {
+		var u models.User
+		n, err := scanNotification(rows, &u.Name, &u.Phone, &u.Timezone, &u.QuietStart, &u.QuietEnd)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan notification row: %w", err)
+		}
+		u.ID = n.UserID
+		pending = append(pending, models.OutgoingNotification{Notification: *n, User: u})
+	}
+	return pending, rows.Err()
+}
+
+// MarkNotificationSent records a successful delivery
+func (db *DB) MarkNotificationSent(id int64) error {
+	_, err := db.Exec(`
+		UPDATE notifications
+		SET status = ?, attempts = attempts + 1, error = '', sent_at = ?
+		WHERE id = ?`, models.NotificationSent, time.Now().UTC(), id)
+	if err != nil {
+		return fmt.Errorf("failed to mark notification sent: %w", err)
+	}
+	return nil
+}
+
+// MarkNotificationFailed records a failed delivery. The notification stays
+// pending for another attempt unless giveUp is set.
+func (db *DB) MarkNotificationFailed(id int64, sendErr error, giveUp bool) error {
+	status := models.NotificationPending
+	if giveUp {
+		status = models.NotificationFailed
+	}
+	_, err := db.Exec(`
+		UPDATE notifications
+		SET status = ?, attempts = attempts + 1, error = ?
+		WHERE id = ?`, status, sendErr.Error(), id)
+	if err != nil {
+		return fmt.Errorf("failed to mark notification failed: %w", err)
+	}
+	return nil
+}
+
+// ExpireNotification drops a notification that went unsent for too long
+func (db *DB) ExpireNotification(id int64) error {
+	_, err := db.Exec("UPDATE notifications SET status = ? WHERE id = ?", models.NotificationExpired, id)
+	if err != nil {
+		return fmt.Errorf("failed to expire notification: %w", err)
+	}
+	return nil
+}
+
+// cancelVisitReminders drops unsent reminders for a visit that was deleted,
+// or moved and so no longer marked reminded
+func cancelVisitReminders(q queryer, visitID int64) error {
+	_, err := q.Exec(`
+		UPDATE notifications SET status = ?
+		WHERE visit_id = ? AND status = ?
+		  AND (SELECT reminded_at FROM visits WHERE id = ?) IS NULL`,
+		models.NotificationExpired, visitID, models.NotificationPending, visitID)
+	if err != nil {
+		return fmt.Errorf("failed to cancel visit reminders: %w", err)
+	}
+	return nil
+}
+
+// DueVisitReminders returns visits starting within lead that haven't been
+// reminded about yet
+func (db *DB) DueVisitReminders(lead time.Duration) ([]models.VisitReminder, error) {
+	rows, err := db.Query(`
+		SELECT v.id, v.apartment_id, a.address, a.unit, v.visited_at
+		FROM visits v
+		JOIN apartments a ON a.id = v.apartment_id
+		WHERE v.reminded_at IS NULL
+		  AND datetime(v.visited_at) > datetime('now')
+		  AND datetime(v.visited_at) <= datetime('now', ?)
+		ORDER BY v.visited_at`,
+		fmt.Sprintf("+%d seconds", int(lead.Seconds())),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list due visit reminders: %w", err)
+	}
+	defer rows.Close()
+
+	reminders := []models.VisitReminder{}
+	for rows.Next() {
+		var r models.VisitReminder
+		var unit string
+		if err := rows.Scan(&r.VisitID, &r.ApartmentID, &r.Address, &unit, &r.VisitedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan visit reminder row: %w", err)
+		}
+		r.Address = address.JoinUnit(r.Address, unit)
+		reminders = append(reminders, r)
+	}
+	return reminders, rows.Err()
+}
+
+// QueueVisitReminder queues a reminder message per user and marks the
+// visit reminded, so it isn't queued again. The messages expire when the
+// visit starts.
+func (db *DB) QueueVisitReminder(r *models.VisitReminder, messages map[int64]string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for userID, body := range messages {
+		if hidden, err := hiddenFrom(tx, r.ApartmentID, userID); err != nil {
+			return err
+		} else if hidden {
+			continue
+		}
+		_, err := tx.Exec(
+			"INSERT INTO notifications (user_id, event, visit_id, body, expires_at) VALUES (?, ?, ?, ?, ?)",
+			userID, models.EventVisitReminder, r.VisitID, body, r.VisitedAt.UTC(),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to enqueue visit reminder: %w", err)
+		}
+	}
+	if _, err := tx.Exec("UPDATE visits SET reminded_at = ? WHERE id = ?", time.Now().UTC(), r.VisitID); err != nil {
+		return fmt.Errorf("failed to mark visit reminded: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit visit reminder: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/mojotx/apt-eval/models"
+)
+
+const selectOffersQuery = `
+	SELECT id, apartment_id, offered_at, amount, who, notes, created_at, updated_at
+	FROM offers`
+
+func (db *DB) scanOffer(row scanner) (*models.Offer, error) {
+	var o models.Offer
+	err := row.Scan(&o.ID, &o.ApartmentID, &o.OfferedAt, &o.Amount, &o.Who, &o.Notes, &o.CreatedAt, &o.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.open(&o.Notes); err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+// offerTime defaults an unset offer time to now
+func offerTime(req *models.OfferRequest) time.Time {
+	if req.OfferedAt.IsZero() {
+		return time.Now().UTC().Truncate(time.Second)
+	}
+	return req.OfferedAt.Time
+}
+
+// ListOffers returns an apartment's negotiation log, oldest first
+func (db *DB) ListOffers(apartmentID int64) ([]models.Offer, error) {
+	rows, err := db.Query(selectOffersQuery+" WHERE apartment_id = ? ORDER BY offered_at, id", apartmentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list offers: %w", err)
+	}
+	defer rows.Close()
+
+	offers := []models.Offer{}
+	for rows.Next() {
+		o, err := db.scanOffer(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan offer row: %w", err)
+		}
+		offers = append(offers, *o)
+	}
+	return offers, rows.Err()
+}
+
+// GetOffer retrieves one of an apartment's offers, or nil if it doesn't
+// exist
+func (db *DB) GetOffer(apartmentID, id int64) (*models.Offer, error) {
+	o, err := db.scanOffer(db.QueryRow(selectOffersQuery+" WHERE apartment_id = ? AND id = ?", apartmentID, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get offer: %w", err)
+	}
+	return o, nil
+}
+
+// CreateOffer logs an offer
+func (db *DB) CreateOffer(apartmentID int64, req *models.OfferRequest) (*models.Offer, error) {
+	var id int64
+	err := db.QueryRow(`
+		INSERT INTO offers (apartment_id, offered_at, amount, who, notes)
+		VALUES (?, ?, ?, ?, ?) RETURNING id`,
+		apartmentID, offerTime(req), req.Amount, req.Who, db.seal(req.Notes),
+	).Scan(&id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create offer: %w", err)
+	}
+
+	if err := db.updateBestOffer(apartmentID); err != nil {
+		return nil, err
+	}
+	return db.GetOffer(apartmentID, id)
+}
+
+// UpdateOffer modifies an offer, returning nil if it doesn't exist
+func (db *DB) UpdateOffer(apartmentID, id int64, req *models.OfferRequest) (*models.Offer, error) {
+	result, err := db.Exec(`
+		UPDATE offers
+		SET offered_at = ?, amount = ?, who = ?, notes = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE apartment_id = ? AND id = ?`,
+		offerTime(req), req.Amount, req.Who, db.seal(req.Notes), apartmentID, id,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update offer: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, nil
+	}
+
+	if err := db.updateBestOffer(apartmentID); err != nil {
+		return nil, err
+	}
+	return db.GetOffer(apartmentID, id)
+}
+
+// DeleteOffer removes an offer
+func (db *DB) DeleteOffer(apartmentID, id int64) error {
+	result, err := db.Exec("DELETE FROM offers WHERE apartment_id = ? AND id = ?", apartmentID, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete offer: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return db.updateBestOffer(apartmentID)
+}
+
+// updateBestOffer recomputes the lowest rent the landlord has offered for
+// an apartment
+func (db *DB) updateBestOffer(apartmentID int64) error {
+	_, err := db.Exec(`
+		UPDATE apartments
+		SET best_offer = (SELECT MIN(amount) FROM offers WHERE apartment_id = ? AND who = ?),
+		    updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?`,
+		apartmentID, models.OfferFromLandlord, apartmentID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update best offer: %w", err)
+	}
+
+	db.changed()
+	return nil
+}
@@ -0,0 +1,34 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+
+	"github.com/mojotx/apt-eval/models"
+)
+
+// applyParking stores the parking details from req. Requests without them
+// come from clients that only know has_garage (or the old garage amenity),
+// which sets or clears garage parking.
+func applyParking(tx *sql.Tx, id int64, req *models.ApartmentRequest) error {
+	var err error
+	switch p := req.Parking; {
+	case p != nil:
+		_, err = tx.Exec(`
+			UPDATE apartments
+			SET parking_type = ?, parking_cost = ?, parking_ev_charging = ?, parking_distance_m = ?
+			WHERE id = ?`,
+			p.Type, p.MonthlyCost, p.EVCharging, p.DistanceM, id,
+		)
+	case req.HasGarage || slices.Contains(req.Amenities, models.AmenityGarage):
+		_, err = tx.Exec("UPDATE apartments SET parking_type = ? WHERE id = ?", models.ParkingGarage, id)
+	default:
+		_, err = tx.Exec("UPDATE apartments SET parking_type = '' WHERE id = ? AND parking_type = ?",
+			id, models.ParkingGarage)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to set parking: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package db
+
+import (
+	"database/sql"
+	"expvar"
+	"time"
+
+	"github.com/mojotx/apt-eval/metrics"
+)
+
+// PoolOptions sizes the connection pool. SQLite lets one connection write
+// at a time, and more connections mostly wait on each other's locks.
+type PoolOptions struct {
+	MaxOpenConns    int           // 0 is unlimited
+	MaxIdleConns    int           // Kept open between statements; at most MaxOpenConns
+	ConnMaxLifetime time.Duration // 0 keeps connections open for good
+}
+
+// DefaultPool is a single connection: every statement takes its turn, so
+// writers never find the database locked
+var DefaultPool = PoolOptions{MaxOpenConns: 1, MaxIdleConns: 1}
+
+// SetPool resizes the connection pool. Statements already running finish
+// on the connections they have.
+func (db *DB) SetPool(p PoolOptions) {
+	setPool(db.DB, p)
+}
+
+func setPool(db *sql.DB, p PoolOptions) {
+	db.SetMaxOpenConns(p.MaxOpenConns)
+	db.SetMaxIdleConns(p.MaxIdleConns)
+	db.SetConnMaxLifetime(p.ConnMaxLifetime)
+}
+
+// publishPoolStats reports the pool in metrics.DBPool: its connections, and
+// how often and how long statements waited for one
+func publishPoolStats(db *sql.DB) {
+	stat := func(f func(sql.DBStats) int64) expvar.Func {
+		return func() any { return f(db.Stats()) }
+	}
+	metrics.DBPool.Set("max_open", stat(func(s sql.DBStats) int64 { return int64(s.MaxOpenConnections) }))
+	metrics.DBPool.Set("open", stat(func(s sql.DBStats) int64 { return int64(s.OpenConnections) }))
+	metrics.DBPool.Set("in_use", stat(func(s sql.DBStats) int64 { return int64(s.InUse) }))
+	metrics.DBPool.Set("idle", stat(func(s sql.DBStats) int64 { return int64(s.Idle) }))
+	metrics.DBPool.Set("wait_count", stat(func(s sql.DBStats) int64 { return s.WaitCount }))
+	metrics.DBPool.Set("wait_duration_us", stat(func(s sql.DBStats) int64 { return s.WaitDuration.Microseconds() }))
+	metrics.DBPool.Set("max_idle_closed", stat(func(s sql.DBStats) int64 { return s.MaxIdleClosed }))
+	metrics.DBPool.Set("max_lifetime_closed", stat(func(s sql.DBStats) int64 { return s.MaxLifetimeClosed }))
+}
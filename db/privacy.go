@@ -0,0 +1,193 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/mojotx/apt-eval/models"
+)
+
+// ExportUser returns everything stored about a user, or nil if they don't
+// exist
+func (db *DB) ExportUser(id int64) (*models.UserExport, error) {
+	user, err := db.GetUser(id)
+	if err != nil || user == nil {
+		return nil, err
+	}
+	export := &models.UserExport{ExportedAt: time.Now().UTC(), User: *user}
+
+	if export.Apartments, err = db.ListApartments(ListOptions{Viewer: id, Owner: id}); err != nil {
+		return nil, err
+	}
+	if export.Comments, err = db.userComments(id); err != nil {
+		return nil, err
+	}
+	if export.Reactions, err = db.userReactions(id, user.Name); err != nil {
+		return nil, err
+	}
+	// A negative limit is no limit to SQLite
+	if export.Activity, err = db.ListActivity(ActivityOptions{Viewer: id, User: id, Limit: -1}); err != nil {
+		return nil, err
+	}
+	if export.Notifications, err = db.ListNotifications(id, -1); err != nil {
+		return nil, err
+	}
+	if export.AlertRules, err = db.ListAlertRules(id); err != nil {
+		return nil, err
+	}
+	return export, nil
+}
+
+// userComments returns the comments a user wrote, oldest first
+func (db *DB) userComments(id int64) ([]models.Comment, error) {
+	rows, err := db.Query(selectCommentsQuery+" WHERE c.author_id = ? ORDER BY c.id", id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list user comments: %w", err)
+	}
+	defer rows.Close()
+
+	var written []*models.Comment
+	for rows.Next() {
+		c, err := scanComment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan comment row: %w", err)
+		}
+		written = append(written, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during row iteration: %w", err)
+	}
+	rows.Close()
+
+	if err := loadMentions(db, written); err != nil {
+		return nil, err
+	}
+	comments := make([]models.Comment, len(written))
+	for i, c := range written {
+		comments[i] = *c
+	}
+	return comments, nil
+}
+
+// userReactions returns the reactions a user left, oldest first
+func (db *DB) userReactions(id int64, name string) ([]models.Reaction, error) {
+	rows, err := db.Query(`
+		SELECT apartment_id, reaction, created_at FROM reactions
+		WHERE user_id = ?
+		ORDER BY created_at, apartment_id`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list user reactions: %w", err)
+	}
+	defer rows.Close()
+
+	reactions := []models.Reaction{}
+	for rows.Next() {
+		r := models.Reaction{UserID: id, User: name}
+		if err := rows.Scan(&r.ApartmentID, &r.Reaction, &r.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan reaction row: %w", err)
+		}
+		reactions = append(reactions, r)
+	}
+	return reactions, rows.Err()
+}
+
+// EraseUser deletes a user and scrubs what they left behind, keeping the
+// household's shared records. Their private apartments, with everything
+// recorded about them, their reactions, and notifications go; their
+// comments stay with the text replaced; the other apartments they added
+// and what they did in the activity feed stay, without their name. The
+// erasure lists the deleted apartments and their attachments, whose
+// stored files are the caller's to remove. It returns ErrNotFound if the
+// user doesn't exist.
+func (db *DB) EraseUser(id int64) (*models.UserErasure, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Deleting private apartments and the user removes the reactions, so
+	// they're counted first
+	var erasure models.UserErasure
+	if err := tx.QueryRow("SELECT COUNT(*) FROM reactions WHERE user_id = ?", id).Scan(&erasure.ReactionsRemoved); err != nil {
+		return nil, fmt.Errorf("failed to count reactions: %w", err)
+	}
+
+	private, err := privateApartments(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	for _, apartmentID := range private {
+		attachments, err := queryAttachments(tx, apartmentID)
+		if err != nil {
+			return nil, err
+		}
+		erasure.Attachments = append(erasure.Attachments, attachments...)
+		if _, err := tx.Exec(deleteApartmentQuery, apartmentID); err != nil {
+			return nil, fmt.Errorf("failed to delete private apartment: %w", err)
+		}
+		if err := deleteApartmentRecords(tx, apartmentID); err != nil {
+			return nil, err
+		}
+		if err := unindexApartment(tx, apartmentID); err != nil {
+			return nil, err
+		}
+	}
+	erasure.DeletedApartments = private
+	erasure.ApartmentsDeleted = int64(len(private))
+
+	steps := []struct {
+		count *int64
+		what  string
+		query string
+	}{
+		{&erasure.ApartmentsDisowned, "disown apartments", "UPDATE apartments SET owner_id = NULL WHERE owner_id = ?"},
+		{nil, "clear scrubbed mentions", "DELETE FROM comment_mentions WHERE comment_id IN (SELECT id FROM comments WHERE author_id = ?)"},
+		{&erasure.CommentsScrubbed, "scrub comments",
+			"UPDATE comments SET body = '" + models.ErasedCommentBody + "', updated_at = CURRENT_TIMESTAMP WHERE author_id = ?"},
+		{&erasure.ActivityRemoved, "remove activity",
+			"DELETE FROM activity WHERE private_to = ?1 OR (user_id = ?1 AND action = '" + models.ActivityReacted + "')"},
+		{nil, "clear undo data", "UPDATE activity SET undo_data = NULL WHERE user_id = ?"},
+	}
+	for _, s := range steps {
+		result, err := tx.Exec(s.query, id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to %s: %w", s.what, err)
+		}
+		if s.count != nil {
+			if *s.count, err = result.RowsAffected(); err != nil {
+				return nil, fmt.Errorf("failed to %s: %w", s.what, err)
+			}
+		}
+	}
+
+	if err := deleteUser(tx, id); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit user erasure: %w", err)
+	}
+
+	db.changed()
+	return &erasure, nil
+}
+
+// privateApartments returns the IDs of a user's private apartments
+func privateApartments(tx *sql.Tx, id int64) ([]int64, error) {
+	rows, err := tx.Query("SELECT id FROM apartments WHERE owner_id = ? AND visibility = ?", id, models.VisibilityPrivate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list private apartments: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var apartmentID int64
+		if err := rows.Scan(&apartmentID); err != nil {
+			return nil, fmt.Errorf("failed to scan apartment row: %w", err)
+		}
+		ids = append(ids, apartmentID)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,157 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/mojotx/apt-eval/models"
+	"github.com/mojotx/apt-eval/scoring"
+)
+
+// queryer is implemented by *sql.DB and *sql.Tx
+type queryer interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// RatingWeights returns the category weights used for scoring
+func (db *DB) RatingWeights() (scoring.Weights, error) {
+	return ratingWeights(db)
+}
+
+func ratingWeights(q queryer) (scoring.Weights, error) {
+	rows, err := q.Query("SELECT category, weight FROM rating_weights")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rating weights: %w", err)
+	}
+	defer rows.Close()
+
+	w := scoring.Weights{}
+	for rows.Next() {
+		var category string
+		var weight float64
+		if err := rows.Scan(&category, &weight); err != nil {
+			return nil, fmt.Errorf("failed to scan rating weight: %w", err)
+		}
+		w[category] = weight
+	}
+	return w, rows.Err()
+}
+
+// SetRatingWeights changes category weights and rescores every apartment.
+// Categories missing from w keep their current weight.
+func (db *DB) SetRatingWeights(w scoring.Weights) (scoring.Weights, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for category, weight := range w {
+		_, err := tx.Exec(`
+			INSERT INTO rating_weights (category, weight) VALUES (?, ?)
+			ON CONFLICT (category) DO UPDATE SET weight = excluded.weight`,
+			category, weight,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to set rating weight: %w", err)
+		}
+	}
+
+	weights, err := ratingWeights(tx)
+	if err != nil {
+		return nil, err
+	}
+	if err := weights.Validate(); err != nil {
+		return nil, err
+	}
+
+	var ids []int64
+	rows, err := tx.Query("SELECT id FROM apartments WHERE score IS NOT NULL OR " + anyCategoryRated)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list rated apartments: %w", err)
+	}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan apartment id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	for _, id := range ids {
+		if err := rescore(tx, id, weights); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit rating weights: %w", err)
+	}
+
+	db.changed()
+	return weights, nil
+}
+
+// anyCategoryRated is a SQL condition matching apartments with at least
+// one category rating
+const anyCategoryRated = `(rating_location IS NOT NULL OR rating_condition IS NOT NULL OR
+	rating_kitchen IS NOT NULL OR rating_noise IS NOT NULL OR rating_value IS NOT NULL)`
+
+// setCategoryRatings stores an apartment's category ratings
+func setCategoryRatings(tx *sql.Tx, id int64, r *models.CategoryRatings) error {
+	_, err := tx.Exec(`
+		UPDATE apartments
+		SET rating_location = ?, rating_condition = ?, rating_kitchen = ?, rating_noise = ?, rating_value = ?
+		WHERE id = ?`,
+		r.Location, r.Condition, r.Kitchen, r.Noise, r.Value, id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to set category ratings: %w", err)
+	}
+	return nil
+}
+
+// rescore recomputes an apartment's score from its category ratings and
+// keeps the legacy rating in step with it. Apartments without category
+// ratings keep their directly entered rating.
+func rescore(tx *sql.Tx, id int64, weights scoring.Weights) error {
+	var r models.CategoryRatings
+	err := tx.QueryRow(`
+		SELECT rating_location, rating_condition, rating_kitchen, rating_noise, rating_value
+		FROM apartments WHERE id = ?`, id,
+	).Scan(&r.Location, &r.Condition, &r.Kitchen, &r.Noise, &r.Value)
+	if err != nil {
+		return fmt.Errorf("failed to read category ratings: %w", err)
+	}
+
+	score := scoring.Score(r.ByCategory(), weights)
+	if score == nil {
+		_, err = tx.Exec("UPDATE apartments SET score = NULL WHERE id = ?", id)
+	} else {
+		_, err = tx.Exec("UPDATE apartments SET score = ?, rating = ? WHERE id = ?", *score, scoring.LegacyRating(*score), id)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update score: %w", err)
+	}
+	return nil
+}
+
+// applyRatings stores the category ratings from req, if any, and rescores
+// the apartment
+func applyRatings(tx *sql.Tx, id int64, req *models.ApartmentRequest) error {
+	if req.Ratings != nil {
+		if err := setCategoryRatings(tx, id, req.Ratings); err != nil {
+			return err
+		}
+	}
+
+	weights, err := ratingWeights(tx)
+	if err != nil {
+		return err
+	}
+	return rescore(tx, id, weights)
+}
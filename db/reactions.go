@@ -0,0 +1,74 @@
+package db
+
+import (
+	"fmt"
+
+	"github.com/mojotx/apt-eval/models"
+)
+
+// ListReactions returns who left which reaction on an apartment, oldest
+// first
+func (db *DB) ListReactions(apartmentID int64) ([]models.Reaction, error) {
+	rows, err := db.Query(`
+		SELECT r.apartment_id, r.user_id, u.name, r.reaction, r.created_at
+		FROM reactions r
+		JOIN users u ON u.id = r.user_id
+		WHERE r.apartment_id = ?
+		ORDER BY r.created_at, r.user_id`, apartmentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list reactions: %w", err)
+	}
+	defer rows.Close()
+
+	reactions := []models.Reaction{}
+	for rows.Next() {
+		var r models.Reaction
+		if err := rows.Scan(&r.ApartmentID, &r.UserID, &r.User, &r.Reaction, &r.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan reaction row: %w", err)
+		}
+		reactions = append(reactions, r)
+	}
+	return reactions, rows.Err()
+}
+
+// AddReaction records a user's reaction to an apartment, reporting whether
+// it's new. Reacting the same way twice changes nothing.
+func (db *DB) AddReaction(apartmentID, userID int64, reaction string) (bool, error) {
+	result, err := db.Exec(
+		"INSERT OR IGNORE INTO reactions (apartment_id, user_id, reaction) VALUES (?, ?, ?)",
+		apartmentID, userID, reaction,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to add reaction: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return false, nil
+	}
+	return true, db.reactionsChanged(apartmentID)
+}
+
+// RemoveReaction takes back a user's reaction to an apartment
+func (db *DB) RemoveReaction(apartmentID, userID int64, reaction string) error {
+	result, err := db.Exec(
+		"DELETE FROM reactions WHERE apartment_id = ? AND user_id = ? AND reaction = ?",
+		apartmentID, userID, reaction,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to remove reaction: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return db.reactionsChanged(apartmentID)
+}
+
+// reactionsChanged marks an apartment updated after its reaction counts
+// changed, so cached copies are refreshed
+func (db *DB) reactionsChanged(apartmentID int64) error {
+	if _, err := db.Exec("UPDATE apartments SET updated_at = CURRENT_TIMESTAMP WHERE id = ?", apartmentID); err != nil {
+		return fmt.Errorf("failed to update apartment: %w", err)
+	}
+
+	db.changed()
+	return nil
+}
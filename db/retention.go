@@ -0,0 +1,196 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/mojotx/apt-eval/models"
+)
+
+// RetentionPolicy says how long data is kept. A zero field keeps that kind
+// of data forever.
+type RetentionPolicy struct {
+	// ArchiveAfterMonths archives apartments nobody has edited or logged
+	// activity on for this many months, unless they're signed
+	ArchiveAfterMonths int
+	// PurgeDeletedAfterDays purges what's kept of deleted apartments, the
+	// sync tombstones and undo snapshots, after this many days
+	PurgeDeletedAfterDays int
+	// HistoryMaxRows caps the activity feed and the delivered or
+	// abandoned notifications, dropping the oldest past it
+	HistoryMaxRows int
+}
+
+// lastTouched is a SQL expression for when an apartment was last edited
+// or had activity logged on it
+const lastTouched = `MAX(apartments.updated_at,
+	COALESCE((SELECT MAX(created_at) FROM activity WHERE activity.apartment_id = apartments.id), apartments.updated_at))`
+
+// historyTables lists the history tables pruned to HistoryMaxRows, with
+// the condition on the rows that may be pruned
+var historyTables = []struct {
+	table, prunable string
+	count           func(*models.RetentionReport) *int64
+}{
+	{"activity", "1", func(r *models.RetentionReport) *int64 { return &r.PrunedActivity }},
+	{"notifications", "status != '" + models.NotificationPending + "'",
+		func(r *models.RetentionReport) *int64 { return &r.PrunedNotifications }},
+}
+
+// ApplyRetention archives, purges, and prunes data as the policy says, as
+// of now. A dry run only reports what it would do.
+func (db *DB) ApplyRetention(policy RetentionPolicy, now time.Time, dryRun bool) (*models.RetentionReport, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	report := &models.RetentionReport{DryRun: dryRun, Archived: []models.ArchivedApartment{}}
+	now = now.UTC()
+
+	if policy.ArchiveAfterMonths > 0 {
+		cutoff := now.AddDate(0, -policy.ArchiveAfterMonths, 0)
+		if report.Archived, err = archiveUntouched(tx, cutoff, dryRun); err != nil {
+			return nil, err
+		}
+	}
+
+	if policy.PurgeDeletedAfterDays > 0 {
+		cutoff := now.AddDate(0, 0, -policy.PurgeDeletedAfterDays).Format(cursorTimeFormat)
+		if report.PurgedTombstones, err = purgeTombstones(tx, cutoff, dryRun); err != nil {
+			return nil, err
+		}
+		if report.PurgedUndoData, err = purge(tx, dryRun,
+			"activity", "undo_data IS NOT NULL AND created_at < ?", "UPDATE activity SET undo_data = NULL", cutoff); err != nil {
+			return nil, err
+		}
+	}
+
+	if policy.HistoryMaxRows > 0 {
+		for _, h := range historyTables {
+			cond := fmt.Sprintf("%s AND id <= (SELECT id FROM %s WHERE %s ORDER BY id DESC LIMIT 1 OFFSET ?)",
+				h.prunable, h.table, h.prunable)
+			n, err := purge(tx, dryRun, h.table, cond, "DELETE FROM "+h.table, policy.HistoryMaxRows)
+			if err != nil {
+				return nil, err
+			}
+			*h.count(report) = n
+		}
+	}
+
+	if dryRun {
+		return report, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit retention: %w", err)
+	}
+	if len(report.Archived) > 0 {
+		db.changed()
+	}
+	return report, nil
+}
+
+// archiveUntouched archives the apartments last touched before cutoff
+func archiveUntouched(tx *sql.Tx, cutoff time.Time, dryRun bool) ([]models.ArchivedApartment, error) {
+	rows, err := tx.Query(`
+		SELECT id, address, status, `+lastTouched+` AS touched
+		FROM apartments
+		WHERE status NOT IN (?, ?) AND `+lastTouched+` < ?
+		ORDER BY touched, id`,
+		models.StatusSigned, models.StatusArchived, cutoff.Format(cursorTimeFormat),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find untouched apartments: %w", err)
+	}
+	defer rows.Close()
+
+	archived := []models.ArchivedApartment{}
+	for rows.Next() {
+		var a models.ArchivedApartment
+		var touched string
+		if err := rows.Scan(&a.ID, &a.Address, &a.Status, &touched); err != nil {
+			return nil, fmt.Errorf("failed to scan untouched apartment: %w", err)
+		}
+		if a.LastTouched, err = time.Parse(cursorTimeFormat, touched); err != nil {
+			return nil, fmt.Errorf("failed to parse last touched time %q: %w", touched, err)
+		}
+		archived = append(archived, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+	if dryRun {
+		return archived, nil
+	}
+
+	for _, a := range archived {
+		if err := recordActivity(tx, 0, models.ActivityStatusChanged, a.ID, a.Status+" → "+models.StatusArchived, nil); err != nil {
+			return nil, err
+		}
+		_, err := tx.Exec("UPDATE apartments SET status = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?", models.StatusArchived, a.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to archive apartment: %w", err)
+		}
+		if err := appendToBoard(tx, a.ID); err != nil {
+			return nil, err
+		}
+	}
+	return archived, nil
+}
+
+// purgeTombstones purges the sync tombstones of apartments deleted before
+// cutoff, moving the sync horizon past them
+func purgeTombstones(tx *sql.Tx, cutoff string, dryRun bool) (int64, error) {
+	var newest int64
+	err := tx.QueryRow("SELECT COALESCE(MAX(seq), 0) FROM apartment_changes WHERE deleted AND changed_at < ?", cutoff).Scan(&newest)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find sync tombstones: %w", err)
+	}
+	n, err := purge(tx, dryRun, "apartment_changes", "deleted AND changed_at < ?", "DELETE FROM apartment_changes", cutoff)
+	if err != nil || dryRun || n == 0 {
+		return n, err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO sync_horizon (id, seq) VALUES (1, ?)
+		ON CONFLICT (id) DO UPDATE SET seq = MAX(seq, excluded.seq)`, newest)
+	if err != nil {
+		return 0, fmt.Errorf("failed to move sync horizon: %w", err)
+	}
+	return n, nil
+}
+
+// purge counts the rows of table matching cond, and unless it's a dry run
+// applies stmt, an UPDATE or DELETE without a WHERE clause, to them
+func purge(tx *sql.Tx, dryRun bool, table, cond, stmt string, args ...any) (int64, error) {
+	if dryRun {
+		var n int64
+		if err := tx.QueryRow("SELECT COUNT(*) FROM "+table+" WHERE "+cond, args...).Scan(&n); err != nil {
+			return 0, fmt.Errorf("failed to count %s to purge: %w", table, err)
+		}
+		return n, nil
+	}
+
+	result, err := tx.Exec(stmt+" WHERE "+cond, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge %s: %w", table, err)
+	}
+	return result.RowsAffected()
+}
+
+// SyncHorizon returns the version before which sync tombstones may have
+// been purged, or 0 if none have
+func (db *DB) SyncHorizon() (int64, error) {
+	var seq int64
+	err := db.QueryRow("SELECT seq FROM sync_horizon WHERE id = 1").Scan(&seq)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get sync horizon: %w", err)
+	}
+	return seq, nil
+}
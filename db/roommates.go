@@ -0,0 +1,213 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/mojotx/apt-eval/costs"
+	"github.com/mojotx/apt-eval/models"
+)
+
+// InvalidAssignmentError is returned when a room assignment names a
+// roommate that doesn't exist or a room of another apartment
+type InvalidAssignmentError struct {
+	Assignment models.RoomAssignment
+}
+
+func (e *InvalidAssignmentError) Error() string {
+	return fmt.Sprintf("roommate %d can't be assigned room %d", e.Assignment.RoommateID, e.Assignment.RoomID)
+}
+
+const selectRoommatesQuery = "SELECT id, name, percent, created_at, updated_at FROM roommates"
+
+func scanRoommate(row scanner) (*models.Roommate, error) {
+	var r models.Roommate
+	if err := row.Scan(&r.ID, &r.Name, &r.Percent, &r.CreatedAt, &r.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// ListRoommates returns the roommates in the order they were added
+func (db *DB) ListRoommates() ([]models.Roommate, error) {
+	rows, err := db.Query(selectRoommatesQuery + " ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list roommates: %w", err)
+	}
+	defer rows.Close()
+
+	roommates := []models.Roommate{}
+	for rows.Next() {
+		r, err := scanRoommate(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan roommate row: %w", err)
+		}
+		roommates = append(roommates, *r)
+	}
+	return roommates, rows.Err()
+}
+
+// GetRoommate retrieves a roommate, or nil if they don't exist
+func (db *DB) GetRoommate(id int64) (*models.Roommate, error) {
+	r, err := scanRoommate(db.QueryRow(selectRoommatesQuery+" WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get roommate: %w", err)
+	}
+	return r, nil
+}
+
+// CreateRoommate adds a roommate
+func (db *DB) CreateRoommate(req *models.RoommateRequest) (*models.Roommate, error) {
+	var id int64
+	err := db.QueryRow("INSERT INTO roommates (name, percent) VALUES (?, ?) RETURNING id",
+		req.Name, req.Percent).Scan(&id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create roommate: %w", err)
+	}
+	return db.GetRoommate(id)
+}
+
+// UpdateRoommate modifies a roommate, returning nil if they don't exist
+func (db *DB) UpdateRoommate(id int64, req *models.RoommateRequest) (*models.Roommate, error) {
+	result, err := db.Exec(`
+		UPDATE roommates SET name = ?, percent = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+		req.Name, req.Percent, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update roommate: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, nil
+	}
+	return db.GetRoommate(id)
+}
+
+// DeleteRoommate removes a roommate and their room assignments
+func (db *DB) DeleteRoommate(id int64) error {
+	result, err := db.Exec("DELETE FROM roommates WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete roommate: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+
+	if _, err := db.Exec("DELETE FROM roommate_rooms WHERE roommate_id = ?", id); err != nil {
+		return fmt.Errorf("failed to clear room assignments: %w", err)
+	}
+	return nil
+}
+
+// RentSplitRule returns the household's rent split rule
+func (db *DB) RentSplitRule() (string, error) {
+	var rule string
+	if err := db.QueryRow("SELECT rule FROM rent_split WHERE id = 1").Scan(&rule); err != nil {
+		return "", fmt.Errorf("failed to get rent split rule: %w", err)
+	}
+	return rule, nil
+}
+
+// SetRentSplitRule changes the household's rent split rule
+func (db *DB) SetRentSplitRule(rule string) error {
+	if _, err := db.Exec("UPDATE rent_split SET rule = ? WHERE id = 1", rule); err != nil {
+		return fmt.Errorf("failed to set rent split rule: %w", err)
+	}
+	return nil
+}
+
+// RoomAssignments returns which room each roommate would take in an
+// apartment
+func (db *DB) RoomAssignments(apartmentID int64) ([]models.RoomAssignment, error) {
+	rows, err := db.Query(`
+		SELECT roommate_id, room_id FROM roommate_rooms WHERE apartment_id = ? ORDER BY roommate_id`,
+		apartmentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list room assignments: %w", err)
+	}
+	defer rows.Close()
+
+	assignments := []models.RoomAssignment{}
+	for rows.Next() {
+		var a models.RoomAssignment
+		if err := rows.Scan(&a.RoommateID, &a.RoomID); err != nil {
+			return nil, fmt.Errorf("failed to scan room assignment row: %w", err)
+		}
+		assignments = append(assignments, a)
+	}
+	return assignments, rows.Err()
+}
+
+// SetRoomAssignments replaces the room assignments for an apartment
+func (db *DB) SetRoomAssignments(apartmentID int64, assignments []models.RoomAssignment) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM roommate_rooms WHERE apartment_id = ?", apartmentID); err != nil {
+		return fmt.Errorf("failed to clear room assignments: %w", err)
+	}
+	for _, a := range assignments {
+		result, err := tx.Exec(`
+			INSERT OR REPLACE INTO roommate_rooms (apartment_id, roommate_id, room_id)
+			SELECT ?, ?, ?
+			WHERE EXISTS (SELECT 1 FROM roommates WHERE id = ?)
+			  AND EXISTS (SELECT 1 FROM rooms WHERE id = ? AND apartment_id = ?)`,
+			apartmentID, a.RoommateID, a.RoomID, a.RoommateID, a.RoomID, apartmentID,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to assign room: %w", err)
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return &InvalidAssignmentError{Assignment: a}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit room assignments: %w", err)
+	}
+	return nil
+}
+
+// RentSplit divides an apartment's true monthly cost between the
+// roommates under the household's rule. It returns nil when there are no
+// roommates or the apartment has no price.
+func (db *DB) RentSplit(apt *models.Apartment) (*models.RentSplit, error) {
+	if apt.TrueMonthlyCost == nil {
+		return nil, nil
+	}
+	rule, err := db.RentSplitRule()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(`
+		SELECT r.id, r.name, r.percent, r.created_at, r.updated_at, COALESCE(rm.id, 0), rm.area_m2
+		FROM roommates r
+		LEFT JOIN roommate_rooms rr ON rr.roommate_id = r.id AND rr.apartment_id = ?
+		LEFT JOIN rooms rm ON rm.id = rr.room_id AND rm.apartment_id = rr.apartment_id
+		ORDER BY r.id`, apt.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list roommates: %w", err)
+	}
+	defer rows.Close()
+
+	var sharers []costs.Sharer
+	for rows.Next() {
+		var s costs.Sharer
+		err := rows.Scan(&s.ID, &s.Name, &s.Percent, &s.CreatedAt, &s.UpdatedAt, &s.RoomID, &s.RoomArea)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan roommate row: %w", err)
+		}
+		sharers = append(sharers, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during row iteration: %w", err)
+	}
+	if len(sharers) == 0 {
+		return nil, nil
+	}
+	return costs.Split(*apt.TrueMonthlyCost, rule, sharers), nil
+}
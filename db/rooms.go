@@ -0,0 +1,133 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/mojotx/apt-eval/models"
+)
+
+const selectRoomsQuery = `
+	SELECT id, apartment_id, name, kind, rating, width_m, length_m, area_m2, notes, created_at, updated_at
+	FROM rooms`
+
+// bedroomCount is a SQL expression counting the apartment row's bedrooms
+const bedroomCount = `(
+	SELECT COUNT(*) FROM rooms r WHERE r.apartment_id = apartments.id AND r.kind = 'bedroom')`
+
+func (db *DB) scanRoom(row scanner) (*models.Room, error) {
+	var r models.Room
+	err := row.Scan(&r.ID, &r.ApartmentID, &r.Name, &r.Kind, &r.Rating, &r.WidthM, &r.LengthM,
+		&r.AreaM2, &r.Notes, &r.CreatedAt, &r.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.open(&r.Notes); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// ListRooms returns an apartment's rooms in the order they were added
+func (db *DB) ListRooms(apartmentID int64) ([]models.Room, error) {
+	rows, err := db.Query(selectRoomsQuery+" WHERE apartment_id = ? ORDER BY id", apartmentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list rooms: %w", err)
+	}
+	defer rows.Close()
+
+	rooms := []models.Room{}
+	for rows.Next() {
+		r, err := db.scanRoom(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan room row: %w", err)
+		}
+		rooms = append(rooms, *r)
+	}
+	return rooms, rows.Err()
+}
+
+// GetRoom retrieves one of an apartment's rooms, or nil if it doesn't exist
+func (db *DB) GetRoom(apartmentID, id int64) (*models.Room, error) {
+	r, err := db.scanRoom(db.QueryRow(selectRoomsQuery+" WHERE apartment_id = ? AND id = ?", apartmentID, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get room: %w", err)
+	}
+	return r, nil
+}
+
+// CreateRoom adds a room to an apartment
+func (db *DB) CreateRoom(apartmentID int64, req *models.RoomRequest) (*models.Room, error) {
+	var id int64
+	err := db.QueryRow(`
+		INSERT INTO rooms (apartment_id, name, kind, rating, width_m, length_m, area_m2, notes)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`,
+		apartmentID, req.Name, req.Kind, req.Rating, req.WidthM, req.LengthM, req.Area(), db.seal(req.Notes),
+	).Scan(&id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create room: %w", err)
+	}
+
+	if err := db.updateRoomRating(apartmentID); err != nil {
+		return nil, err
+	}
+	return db.GetRoom(apartmentID, id)
+}
+
+// UpdateRoom modifies a room, returning nil if it doesn't exist
+func (db *DB) UpdateRoom(apartmentID, id int64, req *models.RoomRequest) (*models.Room, error) {
+	result, err := db.Exec(`
+		UPDATE rooms
+		SET name = ?, kind = ?, rating = ?, width_m = ?, length_m = ?, area_m2 = ?, notes = ?,
+		    updated_at = CURRENT_TIMESTAMP
+		WHERE apartment_id = ? AND id = ?`,
+		req.Name, req.Kind, req.Rating, req.WidthM, req.LengthM, req.Area(), db.seal(req.Notes), apartmentID, id,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update room: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, nil
+	}
+
+	if err := db.updateRoomRating(apartmentID); err != nil {
+		return nil, err
+	}
+	return db.GetRoom(apartmentID, id)
+}
+
+// DeleteRoom removes a room
+func (db *DB) DeleteRoom(apartmentID, id int64) error {
+	result, err := db.Exec("DELETE FROM rooms WHERE apartment_id = ? AND id = ?", apartmentID, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete room: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return db.updateRoomRating(apartmentID)
+}
+
+// updateRoomRating recomputes an apartment's average room rating, which
+// feeds its overall rating. A market rent looked up for a different
+// bedroom count is marked stale.
+func (db *DB) updateRoomRating(apartmentID int64) error {
+	_, err := db.Exec(`
+		UPDATE apartments
+		SET room_rating = (SELECT ROUND(AVG(rating), 2) FROM rooms WHERE apartment_id = ? AND rating IS NOT NULL),
+		    market_rent_updated_at = CASE WHEN market_rent_bedrooms = `+bedroomCount+`
+		        THEN market_rent_updated_at END,
+		    updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?`,
+		apartmentID, apartmentID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update room rating: %w", err)
+	}
+
+	db.changed()
+	return nil
+}
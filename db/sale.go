@@ -0,0 +1,33 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/mojotx/apt-eval/models"
+)
+
+// applySale stores the listing type and sale details from req. A new
+// apartment is a rental unless the request says otherwise; an existing
+// one keeps its type when the request leaves it out.
+func applySale(tx *sql.Tx, id int64, req *models.ApartmentRequest, create bool) error {
+	listingType := req.ListingType
+	if listingType == "" && create {
+		listingType = models.ListingRent
+	}
+	_, err := tx.Exec("UPDATE apartments SET listing_type = COALESCE(NULLIF(?, ''), listing_type) WHERE id = ?",
+		listingType, id)
+	if err == nil && req.Sale != nil {
+		s := req.Sale
+		_, err = tx.Exec(`
+			UPDATE apartments
+			SET purchase_price = ?, hoa_fee = ?, property_tax = ?
+			WHERE id = ?`,
+			s.PurchasePrice, s.HOAFee, s.PropertyTax, id,
+		)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to set sale details: %w", err)
+	}
+	return nil
+}
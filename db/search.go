@@ -0,0 +1,99 @@
+package db
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/mojotx/apt-eval/models"
+	"github.com/mojotx/apt-eval/search"
+)
+
+// searchApartments returns the IDs of apartments whose address,
+// neighborhood, or building name matches every word of text, allowing for
+// typos. Candidate terms come from the trigram index and are then checked
+// word by word.
+func (db *DB) searchApartments(text string) ([]int64, error) {
+	words := strings.Fields(search.Normalize(text))
+	if len(words) == 0 {
+		return nil, nil
+	}
+
+	// matched counts, per apartment, the search words it has matched
+	matched := map[int64]map[int]bool{}
+	for i, word := range words {
+		trigrams := search.Trigrams(word)
+		args := []any{models.SuggestAddress, models.SuggestNeighborhood, models.SuggestBuilding}
+		for _, t := range trigrams {
+			args = append(args, t)
+		}
+		args = append(args, search.MinShared(word))
+
+		rows, err := db.Query(`
+			SELECT t.kind, t.owner_id, t.norm
+			FROM suggest_trigrams g JOIN suggest_terms t ON t.id = g.term_id
+			WHERE t.kind IN (?, ?, ?) AND g.trigram IN (?`+strings.Repeat(", ?", len(trigrams)-1)+`)
+			GROUP BY t.id
+			HAVING COUNT(*) >= ?`,
+			args...,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search terms: %w", err)
+		}
+		var buildings []int64
+		for rows.Next() {
+			var kind, norm string
+			var owner int64
+			if err := rows.Scan(&kind, &owner, &norm); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to scan search term: %w", err)
+			}
+			if !search.MatchWord(word, norm) {
+				continue
+			}
+			if kind == models.SuggestBuilding {
+				buildings = append(buildings, owner)
+				continue
+			}
+			if matched[owner] == nil {
+				matched[owner] = map[int]bool{}
+			}
+			matched[owner][i] = true
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("error during row iteration: %w", err)
+		}
+
+		for _, building := range buildings {
+			units, err := db.Query("SELECT id FROM apartments WHERE building_id = ?", building)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list building units: %w", err)
+			}
+			for units.Next() {
+				var id int64
+				if err := units.Scan(&id); err != nil {
+					units.Close()
+					return nil, fmt.Errorf("failed to scan building unit: %w", err)
+				}
+				if matched[id] == nil {
+					matched[id] = map[int]bool{}
+				}
+				matched[id][i] = true
+			}
+			units.Close()
+			if err := units.Err(); err != nil {
+				return nil, fmt.Errorf("error during row iteration: %w", err)
+			}
+		}
+	}
+
+	ids := []int64{}
+	for id, found := range matched {
+		if len(found) == len(words) {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids) // Keeps the list query, and so its cache key, stable
+	return ids, nil
+}
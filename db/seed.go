@@ -0,0 +1,42 @@
+package db
+
+import (
+	"fmt"
+
+	"github.com/mojotx/apt-eval/models"
+)
+
+// SeedApartments adds generated apartments in a single transaction, for
+// load testing. photoURLs holds a placeholder photo for each, linked as a
+// media link, or "" for none.
+func (db *DB) SeedApartments(apartments []models.ApartmentRequest, photoURLs []string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for i := range apartments {
+		id, err := db.createApartment(tx, &apartments[i])
+		if err != nil {
+			return err
+		}
+		if i >= len(photoURLs) || photoURLs[i] == "" {
+			continue
+		}
+		_, err = tx.Exec(`
+			INSERT INTO media_links (apartment_id, url, provider, thumbnail_url)
+			VALUES (?, ?, ?, ?)`,
+			id, photoURLs[i], models.MediaProviderPlaceholder, photoURLs[i],
+		)
+		if err != nil {
+			return fmt.Errorf("failed to add placeholder photo: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit seeded apartments: %w", err)
+	}
+
+	db.changed()
+	return nil
+}
@@ -0,0 +1,101 @@
+SELECT
+    id,
+    uuid,
+    address,
+    unit,
+    canonical_address,
+    building_address,
+    visit_date,
+    notes,
+    rating,
+    rating_location,
+    rating_condition,
+    rating_kitchen,
+    rating_noise,
+    rating_value,
+    score,
+    price,
+    status,
+    listing_url,
+    floor,
+    EXISTS (
+        SELECT 1 FROM unit_amenities aa JOIN amenities am ON am.id = aa.amenity_id
+        WHERE aa.apartment_id = apartments.id AND am.key = 'gated'
+    ) AS is_gated,
+    parking_type = 'garage' AS has_garage,
+    EXISTS (
+        SELECT 1 FROM unit_amenities aa JOIN amenities am ON am.id = aa.amenity_id
+        WHERE aa.apartment_id = apartments.id AND am.key = 'laundry'
+    ) AS has_laundry,
+    (
+        SELECT group_concat(am.key, ',' ORDER BY am.key)
+        FROM unit_amenities aa JOIN amenities am ON am.id = aa.amenity_id
+        WHERE aa.apartment_id = apartments.id
+    ) AS amenities,
+    answers,
+    latitude,
+    longitude,
+    walk_score,
+    bike_score,
+    transit_score,
+    walkability_updated_at,
+    crime_incidents,
+    safety,
+    crime_updated_at,
+    school_district,
+    school_rating,
+    schools_updated_at,
+    market_rent,
+    market_rent_area,
+    market_rent_bedrooms,
+    CASE WHEN price IS NOT NULL AND market_rent > 0
+        THEN ROUND((price - market_rent) * 100.0 / market_rent, 1)
+    END AS market_rent_diff_pct,
+    market_rent_updated_at,
+    transit_stop,
+    transit_walk_minutes,
+    transit_lines,
+    transit_updated_at,
+    commute_score,
+    commute_updated_at,
+    neighborhood,
+    neighborhood_source,
+    neighborhood_updated_at,
+    room_rating,
+    overall_rating,
+    electricity_estimate,
+    gas_estimate,
+    water_estimate,
+    internet_estimate,
+    electricity_included,
+    gas_included,
+    water_included,
+    internet_included,
+    true_monthly_cost,
+    parking_type,
+    parking_cost,
+    parking_ev_charging,
+    parking_distance_m,
+    listing_type,
+    purchase_price,
+    hoa_fee,
+    property_tax,
+    best_offer,
+    application_deadline,
+    hold_expires,
+    building_id,
+    (
+        SELECT p.id FROM attachments p
+        WHERE p.apartment_id = apartments.id AND p.kind = 'photo' AND p.scan_status != 'infected'
+        ORDER BY p.is_cover DESC, p.sort_order, p.id
+        LIMIT 1
+    ) AS cover_photo_id,
+    visibility,
+    owner_id,
+    (SELECT COUNT(*) FROM reactions r WHERE r.apartment_id = apartments.id AND r.reaction = 'thumbs_up') AS thumbs_up,
+    (SELECT COUNT(*) FROM reactions r WHERE r.apartment_id = apartments.id AND r.reaction = 'thumbs_down') AS thumbs_down,
+    (SELECT COUNT(*) FROM reactions r WHERE r.apartment_id = apartments.id AND r.reaction = 'heart') AS heart,
+    (SELECT COUNT(*) FROM reactions r WHERE r.apartment_id = apartments.id AND r.reaction = 'thinking') AS thinking,
+    created_at,
+    updated_at
+FROM apartments
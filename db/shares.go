@@ -0,0 +1,64 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/mojotx/apt-eval/models"
+)
+
+// CreateSharedComparison stores a comparison snapshot under token
+func (db *DB) CreateSharedComparison(share *models.SharedComparison) (*models.SharedComparison, error) {
+	snapshot, err := json.Marshal(share.Apartments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode comparison snapshot: %w", err)
+	}
+
+	_, err = db.Exec(
+		"INSERT INTO shared_comparisons (token, title, snapshot, expires_at) VALUES (?, ?, ?, ?)",
+		share.Token, share.Title, string(snapshot), share.ExpiresAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create shared comparison: %w", err)
+	}
+	return db.GetSharedComparison(share.Token)
+}
+
+// GetSharedComparison retrieves a shared comparison by token, or nil if it
+// doesn't exist or has expired
+func (db *DB) GetSharedComparison(token string) (*models.SharedComparison, error) {
+	var share models.SharedComparison
+	var snapshot string
+	err := db.QueryRow(`
+		SELECT token, title, snapshot, expires_at, created_at
+		FROM shared_comparisons WHERE token = ?`, token,
+	).Scan(&share.Token, &share.Title, &snapshot, &share.ExpiresAt, &share.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get shared comparison: %w", err)
+	}
+	if share.ExpiresAt != nil && time.Now().After(*share.ExpiresAt) {
+		return nil, nil
+	}
+
+	if err := json.Unmarshal([]byte(snapshot), &share.Apartments); err != nil {
+		return nil, fmt.Errorf("failed to decode comparison snapshot: %w", err)
+	}
+	return &share, nil
+}
+
+// DeleteSharedComparison revokes a shared comparison
+func (db *DB) DeleteSharedComparison(token string) error {
+	result, err := db.Exec("DELETE FROM shared_comparisons WHERE token = ?", token)
+	if err != nil {
+		return fmt.Errorf("failed to delete shared comparison: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
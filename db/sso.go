@@ -0,0 +1,44 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/mojotx/apt-eval/models"
+)
+
+// SSOUser returns the user who signs in as subject with the identity
+// provider, adding them with name the first time. created reports whether
+// they were added.
+func (db *DB) SSOUser(provider, subject, name string) (user *models.User, created bool, err error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var id int64
+	err = tx.QueryRow("SELECT user_id FROM sso_identities WHERE provider = ? AND subject = ?", provider, subject).Scan(&id)
+	switch {
+	case err == sql.ErrNoRows:
+		if id, err = createUser(tx, &models.UserRequest{Name: name}); err != nil {
+			return nil, false, err
+		}
+		_, err = tx.Exec("INSERT INTO sso_identities (provider, subject, user_id) VALUES (?, ?, ?)", provider, subject, id)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to link sign-in identity: %w", err)
+		}
+		created = true
+	case err != nil:
+		return nil, false, fmt.Errorf("failed to find sign-in identity: %w", err)
+	}
+
+	user, err = scanUser(tx.QueryRow(selectUsersQuery+" WHERE id = ?", id))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get user: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, false, fmt.Errorf("failed to commit sign-in: %w", err)
+	}
+	return user, created, nil
+}
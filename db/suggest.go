@@ -0,0 +1,263 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mojotx/apt-eval/models"
+	"github.com/mojotx/apt-eval/search"
+)
+
+// minSuggestScore is the share of the typed text's trigrams a term must
+// contain to be suggested without matching as a prefix
+const minSuggestScore = 0.5
+
+// indexTerms replaces the typeahead terms of one owner. Empty terms are
+// skipped.
+func indexTerms(q queryer, kind string, ownerID int64, terms ...string) error {
+	if err := unindexTerms(q, kind, ownerID); err != nil {
+		return err
+	}
+	for _, term := range terms {
+		norm := search.Normalize(term)
+		if norm == "" {
+			continue
+		}
+		trigrams := search.Trigrams(norm)
+		result, err := q.Exec(
+			"INSERT INTO suggest_terms (kind, owner_id, term, norm, trigram_count) VALUES (?, ?, ?, ?, ?)",
+			kind, ownerID, strings.TrimSpace(term), norm, len(trigrams),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to index term: %w", err)
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("failed to get term id: %w", err)
+		}
+		for _, t := range trigrams {
+			if _, err := q.Exec("INSERT INTO suggest_trigrams (trigram, term_id) VALUES (?, ?)", t, id); err != nil {
+				return fmt.Errorf("failed to index trigram: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// unindexTerms removes the typeahead terms of one owner
+func unindexTerms(q queryer, kind string, ownerID int64) error {
+	_, err := q.Exec(`
+		DELETE FROM suggest_trigrams
+		WHERE term_id IN (SELECT id FROM suggest_terms WHERE kind = ? AND owner_id = ?)`,
+		kind, ownerID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to clear trigrams: %w", err)
+	}
+	if _, err := q.Exec("DELETE FROM suggest_terms WHERE kind = ? AND owner_id = ?", kind, ownerID); err != nil {
+		return fmt.Errorf("failed to clear terms: %w", err)
+	}
+	return nil
+}
+
+// indexApartment refreshes the typeahead terms of an apartment from its
+// stored address and neighborhood
+func indexApartment(q queryer, id int64) error {
+	var addr, neighborhood string
+	err := q.QueryRow("SELECT address, neighborhood FROM apartments WHERE id = ?", id).Scan(&addr, &neighborhood)
+	if err == sql.ErrNoRows {
+		return unindexApartment(q, id)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read apartment for indexing: %w", err)
+	}
+	if err := indexTerms(q, models.SuggestAddress, id, addr); err != nil {
+		return err
+	}
+	return indexTerms(q, models.SuggestNeighborhood, id, neighborhood)
+}
+
+// unindexApartment removes the typeahead terms of a deleted apartment
+func unindexApartment(q queryer, id int64) error {
+	if err := unindexTerms(q, models.SuggestAddress, id); err != nil {
+		return err
+	}
+	return unindexTerms(q, models.SuggestNeighborhood, id)
+}
+
+// buildSuggestIndex fills the typeahead index from scratch when it's empty,
+// as it is right after the migration that added it
+func buildSuggestIndex(db *sql.DB) error {
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM suggest_terms").Scan(&n); err != nil {
+		return fmt.Errorf("failed to count suggest terms: %w", err)
+	}
+	if n > 0 {
+		return nil
+	}
+
+	type owner struct {
+		kind string
+		id   int64
+		term string
+	}
+	var owners []owner
+	for _, source := range []struct{ kind, query string }{
+		{models.SuggestAddress, "SELECT id, address FROM apartments"},
+		{models.SuggestNeighborhood, "SELECT id, neighborhood FROM apartments WHERE neighborhood != ''"},
+		{models.SuggestBuilding, "SELECT id, name FROM buildings WHERE name != ''"},
+		{models.SuggestAmenity, "SELECT id, name FROM amenities"},
+	} {
+		rows, err := db.Query(source.query)
+		if err != nil {
+			return fmt.Errorf("failed to list %s terms: %w", source.kind, err)
+		}
+		for rows.Next() {
+			o := owner{kind: source.kind}
+			if err := rows.Scan(&o.id, &o.term); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to scan %s term: %w", source.kind, err)
+			}
+			owners = append(owners, o)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("error during row iteration: %w", err)
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	for _, o := range owners {
+		if err := indexTerms(tx, o.kind, o.id, o.term); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit suggest index: %w", err)
+	}
+	return nil
+}
+
+// likeEscaper escapes the LIKE wildcards, with \ as the escape character
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// Suggest returns up to limit completions for text typed into the search
+// bar, leaving out the addresses and neighborhoods of apartments viewer
+// can't see. Terms starting with the text, or with a word starting with
+// it, come first; then terms sharing most of its trigrams, which catches
+// typos.
+func (db *DB) Suggest(text string, limit int, viewer int64) ([]models.Suggestion, error) {
+	suggestions := []models.Suggestion{}
+	norm := search.Normalize(text)
+	if norm == "" {
+		return suggestions, nil
+	}
+
+	type candidate struct {
+		models.Suggestion
+		norm string
+	}
+	candidates := map[int64]*candidate{}
+	scan := func(rows *sql.Rows, score func(norm string, shared int) float64) error {
+		defer rows.Close()
+		for rows.Next() {
+			var c candidate
+			var id, owner int64
+			var shared int
+			if err := rows.Scan(&id, &c.Kind, &owner, &c.Text, &c.norm, &c.Key, &shared); err != nil {
+				return fmt.Errorf("failed to scan suggestion: %w", err)
+			}
+			c.Score = score(c.norm, shared)
+			if c.Kind != models.SuggestNeighborhood {
+				c.ID = &owner
+			}
+			if prev, ok := candidates[id]; !ok || prev.Score < c.Score {
+				candidates[id] = &c
+			}
+		}
+		return rows.Err()
+	}
+
+	const columns = "t.id, t.kind, t.owner_id, t.term, t.norm, COALESCE(a.key, '')"
+	const amenityKey = "LEFT JOIN amenities a ON t.kind = 'amenity' AND a.id = t.owner_id"
+	visible := `(t.kind NOT IN ('address', 'neighborhood') OR EXISTS (
+		SELECT 1 FROM apartments WHERE apartments.id = t.owner_id AND ` + visibleTo + `))`
+
+	pattern := likeEscaper.Replace(norm)
+	rows, err := db.Query(`
+		SELECT `+columns+`, 0 FROM suggest_terms t `+amenityKey+`
+		WHERE (t.norm LIKE ? ESCAPE '\' OR t.norm LIKE ? ESCAPE '\') AND `+visible,
+		pattern+"%", "% "+pattern+"%", viewer,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to match prefixes: %w", err)
+	}
+	err = scan(rows, func(term string, _ int) float64 {
+		// Whole-term prefixes first, then the closest in length
+		score := 1 + float64(len(norm))/float64(len(term))
+		if strings.HasPrefix(term, norm) {
+			score++
+		}
+		return score
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	trigrams := search.Trigrams(norm)
+	args := make([]any, len(trigrams))
+	for i, t := range trigrams {
+		args[i] = t
+	}
+	rows, err = db.Query(`
+		SELECT `+columns+`, COUNT(*)
+		FROM suggest_trigrams g JOIN suggest_terms t ON t.id = g.term_id `+amenityKey+`
+		WHERE g.trigram IN (?`+strings.Repeat(", ?", len(trigrams)-1)+`) AND `+visible+`
+		GROUP BY t.id`,
+		append(args, viewer)...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to match trigrams: %w", err)
+	}
+	err = scan(rows, func(_ string, shared int) float64 {
+		// Scored by how much of the typed text the term contains, since
+		// what's typed so far is usually much shorter than the term
+		return float64(shared) / float64(len(trigrams))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Neighborhoods are indexed once per apartment; suggest each once
+	seen := map[string]bool{}
+	for _, c := range candidates {
+		if c.Score < minSuggestScore {
+			continue
+		}
+		if c.Kind == models.SuggestNeighborhood {
+			if seen[c.norm] {
+				continue
+			}
+			seen[c.norm] = true
+		}
+		c.Score = float64(int(c.Score*1000)) / 1000
+		suggestions = append(suggestions, c.Suggestion)
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		a, b := suggestions[i], suggestions[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		return a.Text < b.Text
+	})
+	if len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+	return suggestions, nil
+}
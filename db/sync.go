@@ -0,0 +1,48 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/mojotx/apt-eval/models"
+)
+
+// ApartmentChanges returns up to limit apartments changed after version
+// since, oldest change first, without the apartments themselves
+func (db *DB) ApartmentChanges(since int64, limit int) ([]models.ApartmentChange, error) {
+	rows, err := db.Query(`
+		SELECT apartment_id, COALESCE(apartment_uuid, ''), seq, deleted, changed_at
+		FROM apartment_changes
+		WHERE seq > ?
+		ORDER BY seq
+		LIMIT ?`,
+		since, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list apartment changes: %w", err)
+	}
+	defer rows.Close()
+
+	changes := []models.ApartmentChange{}
+	for rows.Next() {
+		var ch models.ApartmentChange
+		if err := rows.Scan(&ch.ApartmentID, &ch.UUID, &ch.Version, &ch.Deleted, &ch.ChangedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan apartment change row: %w", err)
+		}
+		changes = append(changes, ch)
+	}
+	return changes, rows.Err()
+}
+
+// ApartmentVersion returns an apartment's version and whether it was
+// deleted, or a zero version if it never existed
+func (db *DB) ApartmentVersion(id int64) (version int64, deleted bool, err error) {
+	err = db.QueryRow("SELECT seq, deleted FROM apartment_changes WHERE apartment_id = ?", id).Scan(&version, &deleted)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to get apartment version: %w", err)
+	}
+	return version, deleted, nil
+}
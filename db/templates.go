@@ -0,0 +1,203 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/mojotx/apt-eval/forms"
+	"github.com/mojotx/apt-eval/models"
+)
+
+const selectTemplatesQuery = `
+	SELECT id, name, fields, active, created_at, updated_at
+	FROM templates`
+
+func scanTemplate(row scanner) (*models.Template, error) {
+	var t models.Template
+	var fields string
+	if err := row.Scan(&t.ID, &t.Name, &fields, &t.Active, &t.CreatedAt, &t.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(fields), &t.Fields); err != nil {
+		return nil, fmt.Errorf("failed to decode template fields: %w", err)
+	}
+	return &t, nil
+}
+
+// ListTemplates returns all evaluation templates
+func (db *DB) ListTemplates() ([]models.Template, error) {
+	rows, err := db.Query(selectTemplatesQuery + " ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list templates: %w", err)
+	}
+	defer rows.Close()
+
+	templates := []models.Template{}
+	for rows.Next() {
+		t, err := scanTemplate(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan template row: %w", err)
+		}
+		templates = append(templates, *t)
+	}
+	return templates, rows.Err()
+}
+
+// GetTemplate retrieves a template by ID, or nil if it doesn't exist
+func (db *DB) GetTemplate(id int64) (*models.Template, error) {
+	t, err := scanTemplate(db.QueryRow(selectTemplatesQuery+" WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get template: %w", err)
+	}
+	return t, nil
+}
+
+// ActiveTemplate returns the active template, or nil if none is active
+func (db *DB) ActiveTemplate() (*models.Template, error) {
+	return activeTemplate(db)
+}
+
+func activeTemplate(q queryer) (*models.Template, error) {
+	t, err := scanTemplate(q.QueryRow(selectTemplatesQuery + " WHERE active"))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get active template: %w", err)
+	}
+	return t, nil
+}
+
+// CreateTemplate saves a new, inactive template. Invalid field definitions
+// are reported as a *forms.Error.
+func (db *DB) CreateTemplate(req *models.TemplateRequest) (*models.Template, error) {
+	if err := forms.ValidateFields(req.Fields); err != nil {
+		return nil, err
+	}
+	fields, err := json.Marshal(req.Fields)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode template fields: %w", err)
+	}
+
+	var id int64
+	err = db.QueryRow("INSERT INTO templates (name, fields) VALUES (?, ?) RETURNING id", req.Name, string(fields)).Scan(&id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create template: %w", err)
+	}
+	return db.GetTemplate(id)
+}
+
+// UpdateTemplate modifies a template, returning nil if it doesn't exist.
+// Answers already recorded are only checked against the new fields the
+// next time each apartment is saved.
+func (db *DB) UpdateTemplate(id int64, req *models.TemplateRequest) (*models.Template, error) {
+	if err := forms.ValidateFields(req.Fields); err != nil {
+		return nil, err
+	}
+	fields, err := json.Marshal(req.Fields)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode template fields: %w", err)
+	}
+
+	result, err := db.Exec(`
+		UPDATE templates SET name = ?, fields = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?`,
+		req.Name, string(fields), id,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update template: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, nil
+	}
+	return db.GetTemplate(id)
+}
+
+// DeleteTemplate removes a template
+func (db *DB) DeleteTemplate(id int64) error {
+	result, err := db.Exec("DELETE FROM templates WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete template: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ActivateTemplate makes a template the one evaluations are validated
+// against, deactivating any other. An id of 0 deactivates them all.
+func (db *DB) ActivateTemplate(id int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("UPDATE templates SET active = 0 WHERE active"); err != nil {
+		return fmt.Errorf("failed to deactivate templates: %w", err)
+	}
+	if id != 0 {
+		result, err := tx.Exec("UPDATE templates SET active = 1 WHERE id = ?", id)
+		if err != nil {
+			return fmt.Errorf("failed to activate template: %w", err)
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return ErrNotFound
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit template activation: %w", err)
+	}
+	return nil
+}
+
+// decodeAnswers decodes the answers column
+func decodeAnswers(s sql.NullString) (map[string]any, error) {
+	answers := map[string]any{}
+	if !s.Valid || s.String == "" {
+		return answers, nil
+	}
+	if err := json.Unmarshal([]byte(s.String), &answers); err != nil {
+		return nil, fmt.Errorf("failed to decode answers: %w", err)
+	}
+	return answers, nil
+}
+
+// applyAnswers checks the answers in req against the active template and
+// stores them. On update, leaving answers out keeps the recorded ones
+// without checking them again. Drafts aren't checked, since they're
+// captured before anyone has answered anything.
+func applyAnswers(tx *sql.Tx, id int64, req *models.ApartmentRequest, create bool) error {
+	if req.Answers == nil && !create {
+		return nil
+	}
+
+	template, err := activeTemplate(tx)
+	if err != nil {
+		return err
+	}
+	if template != nil && req.Status != models.StatusDraft {
+		if err := forms.Validate(template.Fields, req.Answers); err != nil {
+			return err
+		}
+	}
+
+	var answers any
+	if len(req.Answers) > 0 {
+		b, err := json.Marshal(req.Answers)
+		if err != nil {
+			return fmt.Errorf("failed to encode answers: %w", err)
+		}
+		answers = string(b)
+	}
+	if _, err := tx.Exec("UPDATE apartments SET answers = ? WHERE id = ?", answers, id); err != nil {
+		return fmt.Errorf("failed to set answers: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,279 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mojotx/apt-eval/models"
+)
+
+// ErrUndoConflict is returned when a change can't be undone because the
+// apartment was changed since in a way undoing would overwrite
+var ErrUndoConflict = errors.New("apartment changed since")
+
+// undoDerived are apartment columns that aren't compared or restored when
+// undoing an edit: they're set again afterwards
+var undoDerived = map[string]bool{"id": true, "updated_at": true, "score": true}
+
+// ApartmentSnapshot is the state of an apartment, kept so a change to it
+// can be undone. Columns hold the text SQLite converts them to, which the
+// columns' affinity turns back into the original values when restored.
+type ApartmentSnapshot struct {
+	Row       map[string]*string `json:"row"`
+	Amenities []int64            `json:"amenities"`
+}
+
+// activityUndo is the undo data of an activity
+type activityUndo struct {
+	Before *ApartmentSnapshot `json:"before"`
+	After  *ApartmentSnapshot `json:"after"` // nil when the apartment was deleted
+}
+
+// SnapshotApartment returns the state of an apartment, or nil if it
+// doesn't exist
+func (db *DB) SnapshotApartment(id int64) (*ApartmentSnapshot, error) {
+	return snapshotApartment(db, id)
+}
+
+// apartmentColumns returns the names of the apartments table's columns
+func apartmentColumns(q queryer) ([]string, error) {
+	rows, err := q.Query("SELECT name FROM pragma_table_info('apartments')")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list apartment columns: %w", err)
+	}
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to scan apartment column: %w", err)
+		}
+		columns = append(columns, name)
+	}
+	return columns, rows.Err()
+}
+
+func snapshotApartment(q queryer, id int64) (*ApartmentSnapshot, error) {
+	columns, err := apartmentColumns(q)
+	if err != nil {
+		return nil, err
+	}
+	exprs := make([]string, len(columns))
+	values := make([]sql.NullString, len(columns))
+	dest := make([]any, len(columns))
+	for i, c := range columns {
+		exprs[i] = `CAST("` + c + `" AS TEXT)`
+		dest[i] = &values[i]
+	}
+	err = q.QueryRow("SELECT "+strings.Join(exprs, ", ")+" FROM apartments WHERE id = ?", id).Scan(dest...)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to snapshot apartment: %w", err)
+	}
+
+	s := &ApartmentSnapshot{Row: make(map[string]*string, len(columns)), Amenities: []int64{}}
+	for i, c := range columns {
+		if values[i].Valid {
+			s.Row[c] = &values[i].String
+		} else {
+			s.Row[c] = nil
+		}
+	}
+
+	rows, err := q.Query("SELECT amenity_id FROM apartment_amenities WHERE apartment_id = ? ORDER BY amenity_id", id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to snapshot amenities: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var amenityID int64
+		if err := rows.Scan(&amenityID); err != nil {
+			return nil, fmt.Errorf("failed to scan amenity row: %w", err)
+		}
+		s.Amenities = append(s.Amenities, amenityID)
+	}
+	return s, rows.Err()
+}
+
+// sameValue reports whether two snapshot columns hold the same value
+func sameValue(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// Undo reverses the most recent change by userID, or by no one in
+// particular for 0, made since the given time that can be undone, and
+// returns its activity entry. It returns ErrNotFound when there's nothing
+// to undo, and ErrUndoConflict when the apartment changed since in a way
+// undoing would overwrite.
+//
+// An edit is undone field by field: each field it changed goes back to
+// its old value. A deleted apartment is put back as it was, with the same
+// ID.
+func (db *DB) Undo(userID int64, since time.Time) (*models.Activity, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var entry models.Activity
+	var data string
+	err = tx.QueryRow(`
+		SELECT id, user_id, action, apartment_id, address, detail, created_at, undo_data
+		FROM activity
+		WHERE user_id IS ? AND undo_data IS NOT NULL AND undone_at IS NULL AND created_at >= ?
+		ORDER BY id DESC
+		LIMIT 1`,
+		activityUser(userID), since.UTC().Format(cursorTimeFormat),
+	).Scan(&entry.ID, &entry.UserID, &entry.Action, &entry.ApartmentID, &entry.Address, &entry.Detail, &entry.CreatedAt, &data)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find change to undo: %w", err)
+	}
+	var undo activityUndo
+	if err := json.Unmarshal([]byte(data), &undo); err != nil || undo.Before == nil {
+		return nil, fmt.Errorf("failed to decode undo data of activity %d: %w", entry.ID, err)
+	}
+
+	id := entry.ApartmentID
+	current, err := snapshotApartment(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	columns, err := apartmentColumns(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	if undo.After == nil {
+		if current != nil {
+			return nil, ErrUndoConflict
+		}
+		if err := restoreApartment(tx, id, columns, undo.Before); err != nil {
+			return nil, err
+		}
+	} else {
+		if current == nil {
+			return nil, ErrUndoConflict
+		}
+		if err := revertApartment(tx, id, columns, undo.Before, undo.After, current); err != nil {
+			return nil, err
+		}
+	}
+
+	weights, err := ratingWeights(tx)
+	if err != nil {
+		return nil, err
+	}
+	if err := rescore(tx, id, weights); err != nil {
+		return nil, err
+	}
+	if err := indexApartment(tx, id); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec("UPDATE activity SET undone_at = CURRENT_TIMESTAMP WHERE id = ?", entry.ID); err != nil {
+		return nil, fmt.Errorf("failed to mark activity undone: %w", err)
+	}
+	if err := recordActivity(tx, userID, models.ActivityUndone, id, entry.Action, nil); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit undo: %w", err)
+	}
+
+	db.changed()
+	return &entry, nil
+}
+
+// restoreApartment puts a deleted apartment back as it was
+func restoreApartment(tx *sql.Tx, id int64, columns []string, s *ApartmentSnapshot) error {
+	var names, marks []string
+	var args []any
+	for _, c := range columns {
+		if v, ok := s.Row[c]; ok && c != "id" {
+			names = append(names, `"`+c+`"`)
+			marks = append(marks, "?")
+			args = append(args, v)
+		}
+	}
+	_, err := tx.Exec("INSERT INTO apartments (id, "+strings.Join(names, ", ")+") VALUES (?, "+strings.Join(marks, ", ")+")",
+		append([]any{id}, args...)...)
+	if err != nil {
+		return snapshotError(tx, id, s, err, "failed to restore apartment")
+	}
+	return setAmenityIDs(tx, id, s.Amenities)
+}
+
+// revertApartment sets each field an edit changed from before to after
+// back to before, failing with ErrUndoConflict when one was changed again
+// since
+func revertApartment(tx *sql.Tx, id int64, columns []string, before, after, current *ApartmentSnapshot) error {
+	var sets []string
+	var args []any
+	for _, c := range columns {
+		b, okBefore := before.Row[c]
+		a, okAfter := after.Row[c]
+		if undoDerived[c] || !okBefore || !okAfter || sameValue(b, a) {
+			continue
+		}
+		if !sameValue(current.Row[c], a) {
+			return ErrUndoConflict
+		}
+		sets = append(sets, `"`+c+`" = ?`)
+		args = append(args, b)
+	}
+
+	if len(sets) > 0 {
+		_, err := tx.Exec("UPDATE apartments SET "+strings.Join(sets, ", ")+", updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+			append(args, id)...)
+		if err != nil {
+			return snapshotError(tx, id, before, err, "failed to revert apartment")
+		}
+	}
+
+	if slices.Equal(before.Amenities, after.Amenities) {
+		return nil
+	}
+	if !slices.Equal(current.Amenities, after.Amenities) {
+		return ErrUndoConflict
+	}
+	return setAmenityIDs(tx, id, before.Amenities)
+}
+
+// snapshotError describes err, which putting back apartment id as it was
+// in s failed with: a *DuplicateAddressError when another apartment has
+// its address now
+func snapshotError(tx *sql.Tx, id int64, s *ApartmentSnapshot, err error, what string) error {
+	if canonical := s.Row["canonical_address"]; canonical != nil && isUniqueViolation(err) {
+		return duplicateAddress(tx, id, *canonical)
+	}
+	return fmt.Errorf("%s: %w", what, err)
+}
+
+// setAmenityIDs replaces an apartment's amenities
+func setAmenityIDs(tx *sql.Tx, id int64, amenities []int64) error {
+	if _, err := tx.Exec("DELETE FROM apartment_amenities WHERE apartment_id = ?", id); err != nil {
+		return fmt.Errorf("failed to clear amenities: %w", err)
+	}
+	for _, amenityID := range amenities {
+		_, err := tx.Exec("INSERT OR IGNORE INTO apartment_amenities (apartment_id, amenity_id) VALUES (?, ?)", id, amenityID)
+		if err != nil {
+			return fmt.Errorf("failed to restore amenity: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,16 @@
+UPDATE apartments
+SET
+    address = ?,
+    unit = COALESCE(?, unit),
+    visit_date = ?,
+    notes = ?,
+    rating = ?,
+    price = ?,
+    floor = ?,
+    latitude = ?,
+    longitude = ?,
+    status = COALESCE(NULLIF(?, ''), status),
+    listing_url = COALESCE(?, listing_url),
+    updated_at = CURRENT_TIMESTAMP
+WHERE
+    id = ? RETURNING id
@@ -0,0 +1,77 @@
+package db
+
+import (
+	"fmt"
+
+	"github.com/mojotx/apt-eval/models"
+)
+
+// StorageUsage totals the sizes of the uploaded photos, documents, and
+// floor plans, quarantined files included since they're kept on disk too.
+// Thumbnails are small and made again on demand, so they aren't counted.
+func (db *DB) StorageUsage() (*models.StorageUsage, error) {
+	var usage models.StorageUsage
+	rows, err := db.Query("SELECT kind, COUNT(*), COALESCE(SUM(size), 0) FROM attachments GROUP BY kind")
+	if err != nil {
+		return nil, fmt.Errorf("failed to total attachments: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var kind string
+		var files models.FileUsage
+		if err := rows.Scan(&kind, &files.Count, &files.Bytes); err != nil {
+			return nil, fmt.Errorf("failed to scan attachment totals: %w", err)
+		}
+		if kind == models.AttachmentPhoto {
+			usage.Photos = files
+		} else {
+			usage.Documents.Count += files.Count
+			usage.Documents.Bytes += files.Bytes
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to total attachments: %w", err)
+	}
+
+	err = db.QueryRow("SELECT COUNT(*), COALESCE(SUM(size), 0) FROM floor_plans").Scan(&usage.FloorPlans.Count, &usage.FloorPlans.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to total floor plans: %w", err)
+	}
+	usage.UsedBytes = usage.Photos.Bytes + usage.Documents.Bytes + usage.FloorPlans.Bytes
+	return &usage, nil
+}
+
+// FloorPlanSize returns the size of an apartment's floor plan, or 0 when
+// it has none
+func (db *DB) FloorPlanSize(apartmentID int64) (int64, error) {
+	var size int64
+	if err := db.QueryRow("SELECT COALESCE(SUM(size), 0) FROM floor_plans WHERE apartment_id = ?", apartmentID).Scan(&size); err != nil {
+		return 0, fmt.Errorf("failed to get floor plan size: %w", err)
+	}
+	return size, nil
+}
+
+// LargestAttachments lists the biggest attachments of the apartments
+// userID may see, largest first, as candidates for freeing space
+func (db *DB) LargestAttachments(userID int64, limit int) ([]models.Attachment, error) {
+	rows, err := db.Query(selectAttachmentsQuery+`
+		WHERE apartment_id IN (SELECT id FROM apartments WHERE `+visibleTo+`)
+		ORDER BY size DESC, id
+		LIMIT ?`,
+		userID, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list largest attachments: %w", err)
+	}
+	defer rows.Close()
+
+	attachments := []models.Attachment{}
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan attachment row: %w", err)
+		}
+		attachments = append(attachments, *a)
+	}
+	return attachments, rows.Err()
+}
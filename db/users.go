@@ -0,0 +1,196 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/mojotx/apt-eval/models"
+)
+
+const selectUsersQuery = `
+	SELECT id, name, phone, timezone, quiet_start, quiet_end, notify_visits, notify_status, notify_deadlines,
+	       notify_mentions, created_at, updated_at
+	FROM users`
+
+func scanUser(row scanner) (*models.User, error) {
+	var u models.User
+	err := row.Scan(&u.ID, &u.Name, &u.Phone, &u.Timezone, &u.QuietStart, &u.QuietEnd,
+		&u.NotifyVisits, &u.NotifyStatus, &u.NotifyDeadlines, &u.NotifyMentions, &u.CreatedAt, &u.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// userSettings fills in the defaults for unset user fields: UTC and every
+// notification turned on
+func userSettings(req *models.UserRequest) (timezone string, notifyVisits, notifyStatus, notifyDeadlines, notifyMentions bool) {
+	timezone, notifyVisits, notifyStatus, notifyDeadlines, notifyMentions = req.Timezone, true, true, true, true
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	if req.NotifyVisits != nil {
+		notifyVisits = *req.NotifyVisits
+	}
+	if req.NotifyStatus != nil {
+		notifyStatus = *req.NotifyStatus
+	}
+	if req.NotifyDeadlines != nil {
+		notifyDeadlines = *req.NotifyDeadlines
+	}
+	if req.NotifyMentions != nil {
+		notifyMentions = *req.NotifyMentions
+	}
+	return
+}
+
+// ListUsers returns all users
+func (db *DB) ListUsers() ([]models.User, error) {
+	rows, err := db.Query(selectUsersQuery + " ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	defer rows.Close()
+
+	users := []models.User{}
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan user row: %w", err)
+		}
+		users = append(users, *u)
+	}
+	return users, rows.Err()
+}
+
+// GetUser retrieves a user by ID, or nil if it doesn't exist
+func (db *DB) GetUser(id int64) (*models.User, error) {
+	u, err := scanUser(db.QueryRow(selectUsersQuery+" WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	return u, nil
+}
+
+// FindUserByName retrieves the user with a name, in any case, or nil if
+// there isn't one
+func (db *DB) FindUserByName(name string) (*models.User, error) {
+	u, err := scanUser(db.QueryRow(selectUsersQuery+" WHERE name = ? COLLATE NOCASE ORDER BY id LIMIT 1", name))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find user: %w", err)
+	}
+	return u, nil
+}
+
+// CreateUser saves a new user
+func (db *DB) CreateUser(req *models.UserRequest) (*models.User, error) {
+	id, err := createUser(db, req)
+	if err != nil {
+		return nil, err
+	}
+	return db.GetUser(id)
+}
+
+func createUser(q queryer, req *models.UserRequest) (int64, error) {
+	timezone, notifyVisits, notifyStatus, notifyDeadlines, notifyMentions := userSettings(req)
+	var id int64
+	err := q.QueryRow(`
+		INSERT INTO users (name, phone, timezone, quiet_start, quiet_end, notify_visits, notify_status, notify_deadlines,
+		                   notify_mentions)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`,
+		req.Name, req.Phone, timezone, req.QuietStart, req.QuietEnd, notifyVisits, notifyStatus, notifyDeadlines,
+		notifyMentions,
+	).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create user: %w", err)
+	}
+	return id, nil
+}
+
+// UpdateUser modifies a user, returning nil if it doesn't exist
+func (db *DB) UpdateUser(id int64, req *models.UserRequest) (*models.User, error) {
+	timezone, notifyVisits, notifyStatus, notifyDeadlines, notifyMentions := userSettings(req)
+	result, err := db.Exec(`
+		UPDATE users
+		SET name = ?, phone = ?, timezone = ?, quiet_start = ?, quiet_end = ?, notify_visits = ?, notify_status = ?,
+		    notify_deadlines = ?, notify_mentions = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?`,
+		req.Name, req.Phone, timezone, req.QuietStart, req.QuietEnd, notifyVisits, notifyStatus, notifyDeadlines,
+		notifyMentions, id,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update user: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, nil
+	}
+	return db.GetUser(id)
+}
+
+// DeleteUser removes a user, their queued notifications, alert rules,
+// mentions, and reactions. Their comments stay, without an author.
+func (db *DB) DeleteUser(id int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := deleteUser(tx, id); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit user deletion: %w", err)
+	}
+
+	db.changed()
+	return nil
+}
+
+func deleteUser(q queryer, id int64) error {
+	result, err := q.Exec("DELETE FROM users WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+
+	if _, err := q.Exec("DELETE FROM notifications WHERE user_id = ?", id); err != nil {
+		return fmt.Errorf("failed to clear notifications: %w", err)
+	}
+	if _, err := q.Exec("DELETE FROM alert_hits WHERE rule_id IN (SELECT id FROM alert_rules WHERE user_id = ?)", id); err != nil {
+		return fmt.Errorf("failed to clear alert hits: %w", err)
+	}
+	if _, err := q.Exec("DELETE FROM alert_rules WHERE user_id = ?", id); err != nil {
+		return fmt.Errorf("failed to clear alert rules: %w", err)
+	}
+	if _, err := q.Exec("DELETE FROM sso_identities WHERE user_id = ?", id); err != nil {
+		return fmt.Errorf("failed to clear sign-in identities: %w", err)
+	}
+	if _, err := q.Exec("DELETE FROM comment_mentions WHERE user_id = ?", id); err != nil {
+		return fmt.Errorf("failed to clear mentions: %w", err)
+	}
+	if _, err := q.Exec("UPDATE comments SET author_id = NULL WHERE author_id = ?", id); err != nil {
+		return fmt.Errorf("failed to clear comment authors: %w", err)
+	}
+	if _, err := q.Exec("UPDATE activity SET user_id = NULL WHERE user_id = ?", id); err != nil {
+		return fmt.Errorf("failed to clear activity users: %w", err)
+	}
+	_, err = q.Exec(`
+		UPDATE apartments SET updated_at = CURRENT_TIMESTAMP
+		WHERE id IN (SELECT apartment_id FROM reactions WHERE user_id = ?)`, id)
+	if err != nil {
+		return fmt.Errorf("failed to update reacted apartments: %w", err)
+	}
+	if _, err := q.Exec("DELETE FROM reactions WHERE user_id = ?", id); err != nil {
+		return fmt.Errorf("failed to clear reactions: %w", err)
+	}
+	return nil
+}
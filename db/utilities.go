@@ -0,0 +1,29 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/mojotx/apt-eval/models"
+)
+
+// applyUtilities stores the utility estimates from req, if any
+func applyUtilities(tx *sql.Tx, id int64, req *models.ApartmentRequest) error {
+	u := req.Utilities
+	if u == nil {
+		return nil
+	}
+
+	_, err := tx.Exec(`
+		UPDATE apartments
+		SET electricity_estimate = ?, gas_estimate = ?, water_estimate = ?, internet_estimate = ?,
+		    electricity_included = ?, gas_included = ?, water_included = ?, internet_included = ?
+		WHERE id = ?`,
+		u.Electricity, u.Gas, u.Water, u.Internet,
+		u.ElectricityIncluded, u.GasIncluded, u.WaterIncluded, u.InternetIncluded, id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to set utilities: %w", err)
+	}
+	return nil
+}
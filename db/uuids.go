@@ -0,0 +1,31 @@
+package db
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"fmt"
+)
+
+// newUUID returns a random (version 4) UUID
+func newUUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// ApartmentIDByUUID returns the ID of the apartment with uuid, or
+// ErrNotFound. A deleted apartment is found as long as sync keeps its
+// tombstone.
+func (db *DB) ApartmentIDByUUID(uuid string) (int64, error) {
+	var id int64
+	err := db.QueryRow("SELECT apartment_id FROM apartment_changes WHERE apartment_uuid = ?", uuid).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to look up apartment UUID: %w", err)
+	}
+	return id, nil
+}
@@ -0,0 +1,60 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/mojotx/apt-eval/models"
+)
+
+// visibleToUser is a condition on apartments matching those the user
+// identified by the SQL expression user may see, as decided by
+// models.Apartment.VisibleTo
+func visibleToUser(user string) string {
+	return "(apartments.visibility != '" + models.VisibilityPrivate + "' OR apartments.owner_id = " + user + ")"
+}
+
+// visibleTo is visibleToUser taking the user ID as a query argument
+var visibleTo = visibleToUser("?")
+
+// applyVisibility stores the visibility and owner from req. A new
+// apartment is owned by whoever created it; an existing one keeps its
+// owner, or takes the requester when it has none.
+func applyVisibility(tx *sql.Tx, id int64, req *models.ApartmentRequest, create bool) error {
+	var err error
+	if create {
+		visibility := req.Visibility
+		if visibility == "" {
+			visibility = models.DefaultVisibility
+		}
+		_, err = tx.Exec("UPDATE apartments SET visibility = ?, owner_id = ? WHERE id = ?", visibility, req.OwnerID, id)
+	} else {
+		_, err = tx.Exec(`
+			UPDATE apartments
+			SET visibility = COALESCE(NULLIF(?, ''), visibility), owner_id = COALESCE(owner_id, ?)
+			WHERE id = ?`,
+			req.Visibility, req.OwnerID, id,
+		)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to set visibility: %w", err)
+	}
+	return nil
+}
+
+// hiddenFrom reports whether an apartment is private to someone other than
+// userID, so messages about it mustn't go to them
+func hiddenFrom(q queryer, apartmentID, userID int64) (bool, error) {
+	var hidden bool
+	err := q.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM apartments
+			WHERE id = ? AND NOT `+visibleTo+`
+		)`,
+		apartmentID, userID,
+	).Scan(&hidden)
+	if err != nil {
+		return false, fmt.Errorf("failed to check apartment visibility: %w", err)
+	}
+	return hidden, nil
+}
@@ -0,0 +1,147 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/mojotx/apt-eval/models"
+)
+
+const selectVisitsQuery = `
+	SELECT id, apartment_id, visited_at, noise_level, natural_light, smell_notes, facing, notes, created_at, updated_at
+	FROM visits`
+
+func (db *DB) scanVisit(row scanner) (*models.Visit, error) {
+	var v models.Visit
+	err := row.Scan(&v.ID, &v.ApartmentID, &v.VisitedAt, &v.NoiseLevel, &v.NaturalLight,
+		&v.SmellNotes, &v.Facing, &v.Notes, &v.CreatedAt, &v.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.open(&v.Notes); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// visitTime defaults an unset visit time to now
+func visitTime(req *models.VisitRequest) time.Time {
+	if req.VisitedAt.IsZero() {
+		return time.Now().UTC().Truncate(time.Second)
+	}
+	return req.VisitedAt.Time
+}
+
+// ListVisits returns an apartment's visits, most recent first
+func (db *DB) ListVisits(apartmentID int64) ([]models.Visit, error) {
+	rows, err := db.Query(selectVisitsQuery+" WHERE apartment_id = ? ORDER BY visited_at DESC, id DESC", apartmentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list visits: %w", err)
+	}
+	defer rows.Close()
+
+	visits := []models.Visit{}
+	for rows.Next() {
+		v, err := db.scanVisit(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan visit row: %w", err)
+		}
+		visits = append(visits, *v)
+	}
+	return visits, rows.Err()
+}
+
+// GetVisit retrieves one of an apartment's visits, or nil if it doesn't
+// exist
+func (db *DB) GetVisit(apartmentID, id int64) (*models.Visit, error) {
+	v, err := db.scanVisit(db.QueryRow(selectVisitsQuery+" WHERE apartment_id = ? AND id = ?", apartmentID, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get visit: %w", err)
+	}
+	return v, nil
+}
+
+// LatestVisit returns an apartment's most recent visit, or nil if it has
+// none
+func (db *DB) LatestVisit(apartmentID int64) (*models.Visit, error) {
+	v, err := db.scanVisit(db.QueryRow(selectVisitsQuery+" WHERE apartment_id = ? ORDER BY visited_at DESC, id DESC LIMIT 1", apartmentID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest visit: %w", err)
+	}
+	return v, nil
+}
+
+// CreateVisit records a visit to an apartment
+func (db *DB) CreateVisit(apartmentID int64, req *models.VisitRequest) (*models.Visit, error) {
+	var id int64
+	err := db.QueryRow(`
+		INSERT INTO visits (apartment_id, visited_at, noise_level, natural_light, smell_notes, facing, notes)
+		VALUES (?, ?, ?, ?, ?, ?, ?) RETURNING id`,
+		apartmentID, visitTime(req), req.NoiseLevel, req.NaturalLight, req.SmellNotes, req.Facing, db.seal(req.Notes),
+	).Scan(&id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create visit: %w", err)
+	}
+
+	db.changed()
+	return db.GetVisit(apartmentID, id)
+}
+
+// UpdateVisit modifies a visit, returning nil if it doesn't exist
+func (db *DB) UpdateVisit(apartmentID, id int64, req *models.VisitRequest) (*models.Visit, error) {
+	// Moving a visit clears its reminder so one is sent for the new time
+	visitedAt := visitTime(req)
+	result, err := db.Exec(`
+		UPDATE visits
+		SET visited_at = ?, noise_level = ?, natural_light = ?, smell_notes = ?, facing = ?, notes = ?,
+		    reminded_at = CASE WHEN datetime(visited_at) = datetime(?) THEN reminded_at END,
+		    updated_at = CURRENT_TIMESTAMP
+		WHERE apartment_id = ? AND id = ?`,
+		visitedAt, req.NoiseLevel, req.NaturalLight, req.SmellNotes, req.Facing, db.seal(req.Notes), visitedAt, apartmentID, id,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update visit: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, nil
+	}
+	if err := cancelVisitReminders(db, id); err != nil {
+		return nil, err
+	}
+
+	db.changed()
+	return db.GetVisit(apartmentID, id)
+}
+
+// DeleteVisit removes a visit, and its calendar event on the next sync
+func (db *DB) DeleteVisit(apartmentID, id int64) error {
+	_, err := db.Exec(`
+		INSERT OR IGNORE INTO calendar_deletions (event_id)
+		SELECT calendar_event_id FROM visits WHERE apartment_id = ? AND id = ? AND calendar_event_id != ''`,
+		apartmentID, id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to queue calendar deletion: %w", err)
+	}
+
+	result, err := db.Exec("DELETE FROM visits WHERE apartment_id = ? AND id = ?", apartmentID, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete visit: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	if err := cancelVisitReminders(db, id); err != nil {
+		return err
+	}
+
+	db.changed()
+	return nil
+}
@@ -0,0 +1,123 @@
+// Package drain tracks the requests and connections of an HTTP server so
+// a graceful shutdown can report what it waited for and what it cut off
+package drain
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+)
+
+// Tracker counts a server's requests in flight and its open connections.
+// A nil Tracker tracks nothing.
+type Tracker struct {
+	name string
+
+	mu        sync.Mutex
+	inFlight  int
+	draining  bool
+	completed int // Requests finished since draining began
+	conns     map[net.Conn]http.ConnState
+}
+
+// New creates a tracker for the listener called name
+func New(name string) *Tracker {
+	return &Tracker{name: name, conns: make(map[net.Conn]http.ConnState)}
+}
+
+// Begin counts a request as in flight until the returned function is
+// called
+func (t *Tracker) Begin() (done func()) {
+	if t == nil {
+		return func() {}
+	}
+	t.mu.Lock()
+	t.inFlight++
+	t.mu.Unlock()
+
+	return func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		t.inFlight--
+		if t.draining {
+			t.completed++
+		}
+	}
+}
+
+// Wrap counts the requests h serves
+func (t *Tracker) Wrap(h http.Handler) http.Handler {
+	if t == nil {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer t.Begin()()
+		h.ServeHTTP(w, r)
+	})
+}
+
+// ConnState follows the server's connections; set it as the server's
+// ConnState hook
+func (t *Tracker) ConnState(c net.Conn, state http.ConnState) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch state {
+	case http.StateClosed, http.StateHijacked:
+		delete(t.conns, c)
+	default:
+		t.conns[c] = state
+	}
+}
+
+// Report says how a listener's shutdown went
+type Report struct {
+	Listener    string
+	InFlight    int  // Requests running when shutdown began
+	Completed   int  // Requests that finished while draining
+	CutOff      int  // Requests still running at the deadline
+	OpenConns   int  // Connections open when shutdown began
+	ActiveConns int  // Of those, connections busy with a request
+	ForcedConns int  // Connections closed at the deadline
+	Graceful    bool // Everything finished before the deadline
+	Err         error
+}
+
+// Shutdown shuts srv down gracefully, waiting until ctx is done for
+// requests in flight to finish, then closes whatever connections are
+// left
+func (t *Tracker) Shutdown(ctx context.Context, srv *http.Server) Report {
+	report := Report{}
+	if t != nil {
+		t.mu.Lock()
+		t.draining = true
+		report.Listener = t.name
+		report.InFlight = t.inFlight
+		report.OpenConns = len(t.conns)
+		for _, state := range t.conns {
+			if state == http.StateActive {
+				report.ActiveConns++
+			}
+		}
+		t.mu.Unlock()
+	}
+
+	report.Err = srv.Shutdown(ctx)
+	report.Graceful = report.Err == nil
+
+	if t != nil {
+		t.mu.Lock()
+		report.Completed = t.completed
+		report.CutOff = t.inFlight
+		report.ForcedConns = len(t.conns)
+		t.mu.Unlock()
+	}
+	if !report.Graceful {
+		// Drop the connections Shutdown gave up waiting on
+		srv.Close()
+	}
+	return report
+}
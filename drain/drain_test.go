@@ -0,0 +1,73 @@
+package drain
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// serve starts a server whose handler waits for release before answering,
+// signaling on started as each request arrives
+func serve(t *testing.T, tracker *Tracker, release <-chan struct{}, started chan<- struct{}) *httptest.Server {
+	srv := httptest.NewUnstartedServer(tracker.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+	})))
+	srv.Config.ConnState = tracker.ConnState
+	srv.Start()
+	return srv
+}
+
+func TestShutdownGraceful(t *testing.T) {
+	tracker := New("http")
+	release, started := make(chan struct{}), make(chan struct{})
+	srv := serve(t, tracker, release, started)
+
+	go http.Get(srv.URL)
+	<-started
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+	}()
+	report := tracker.Shutdown(context.Background(), srv.Config)
+	assert.True(t, report.Graceful)
+	assert.NoError(t, report.Err)
+	assert.Equal(t, "http", report.Listener)
+	assert.Equal(t, 1, report.InFlight)
+	assert.Equal(t, 1, report.Completed)
+	assert.Equal(t, 0, report.CutOff)
+	assert.Equal(t, 1, report.ActiveConns)
+}
+
+func TestShutdownCutOff(t *testing.T) {
+	tracker := New("https")
+	release, started := make(chan struct{}), make(chan struct{})
+	srv := serve(t, tracker, release, started)
+	defer close(release)
+
+	go http.Get(srv.URL)
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	report := tracker.Shutdown(ctx, srv.Config)
+	require.ErrorIs(t, report.Err, context.DeadlineExceeded)
+	assert.False(t, report.Graceful)
+	assert.Equal(t, 1, report.InFlight)
+	assert.Equal(t, 0, report.Completed)
+	assert.Equal(t, 1, report.CutOff)
+	assert.Equal(t, 1, report.ForcedConns)
+}
+
+func TestNilTracker(t *testing.T) {
+	var tracker *Tracker
+	tracker.Begin()()
+	h := http.NotFoundHandler()
+	assert.NotNil(t, tracker.Wrap(h))
+}
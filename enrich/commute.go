@@ -0,0 +1,218 @@
+package enrich
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"strings"
+
+	"github.com/mojotx/apt-eval/models"
+)
+
+// ErrUnsupportedMode is returned by commute providers that can't route a
+// travel mode; the estimate is used instead
+var ErrUnsupportedMode = errors.New("travel mode not supported by provider")
+
+// CommuteTime is the travel time and distance for one trip
+type CommuteTime struct {
+	Minutes        int
+	DistanceMeters int
+}
+
+// CommuteProvider computes travel times between two locations
+type CommuteProvider interface {
+	Name() string
+	Commute(ctx context.Context, from, to Location, mode string) (*CommuteTime, error)
+}
+
+// NewCommuteProvider returns the provider selected by name: "estimate" or
+// "" (straight-line heuristics), "osrm" (an OSRM server; drive and bike
+// only), "google" (the Distance Matrix API, requires apiKey), or "none"
+func NewCommuteProvider(name, osrmURL, apiKey string) (CommuteProvider, error) {
+	switch strings.ToLower(name) {
+	case "none":
+		return nil, nil
+	case "", "estimate":
+		return EstimateCommuteProvider{}, nil
+	case "osrm":
+		if osrmURL == "" {
+			osrmURL = "https://router.project-osrm.org"
+		}
+		return &OSRMCommuteProvider{BaseURL: strings.TrimSuffix(osrmURL, "/")}, nil
+	case "google":
+		if apiKey == "" {
+			return nil, fmt.Errorf("the google commute provider requires an API key")
+		}
+		return &GoogleCommuteProvider{APIKey: apiKey, BaseURL: "https://maps.googleapis.com/maps/api/distancematrix/json"}, nil
+	}
+	return nil, fmt.Errorf("unknown commute provider %q", name)
+}
+
+// EstimateCommuteProvider approximates travel times from straight-line
+// distance and typical urban speeds. It needs no network access.
+type EstimateCommuteProvider struct{}
+
+// estimateSpeeds are average door-to-door speeds in km/h plus fixed
+// overhead minutes (parking, waiting for a train) per mode
+var estimateSpeeds = map[string]struct{ kmh, overhead float64 }{
+	models.ModeDrive:   {kmh: 35, overhead: 5},
+	models.ModeTransit: {kmh: 18, overhead: 8},
+	models.ModeBike:    {kmh: 15, overhead: 2},
+}
+
+// Name implements CommuteProvider
+func (EstimateCommuteProvider) Name() string {
+	return "estimate"
+}
+
+// Commute implements CommuteProvider
+func (EstimateCommuteProvider) Commute(_ context.Context, from, to Location, mode string) (*CommuteTime, error) {
+	speed, ok := estimateSpeeds[mode]
+	if !ok {
+		return nil, ErrUnsupportedMode
+	}
+	meters := DistanceMeters(from.Latitude, from.Longitude, to.Latitude, to.Longitude) * walkDetourFactor
+	minutes := speed.overhead + meters/1000/speed.kmh*60
+	return &CommuteTime{Minutes: int(math.Round(minutes)), DistanceMeters: int(math.Round(meters))}, nil
+}
+
+// OSRMCommuteProvider routes trips with an OSRM server
+type OSRMCommuteProvider struct {
+	BaseURL string
+}
+
+// Name implements CommuteProvider
+func (p *OSRMCommuteProvider) Name() string {
+	return "osrm"
+}
+
+var osrmProfiles = map[string]string{
+	models.ModeDrive: "driving",
+	models.ModeBike:  "cycling",
+}
+
+type osrmRouteResponse struct {
+	Code   string `json:"code"`
+	Routes []struct {
+		Duration float64 `json:"duration"`
+		Distance float64 `json:"distance"`
+	} `json:"routes"`
+}
+
+// Commute implements CommuteProvider
+func (p *OSRMCommuteProvider) Commute(ctx context.Context, from, to Location, mode string) (*CommuteTime, error) {
+	profile, ok := osrmProfiles[mode]
+	if !ok {
+		return nil, ErrUnsupportedMode
+	}
+
+	u := fmt.Sprintf("%s/route/v1/%s/%f,%f;%f,%f?overview=false",
+		p.BaseURL, profile, from.Longitude, from.Latitude, to.Longitude, to.Latitude)
+	var resp osrmRouteResponse
+	if err := getJSON(ctx, u, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Code != "Ok" || len(resp.Routes) == 0 {
+		return nil, fmt.Errorf("osrm returned %q", resp.Code)
+	}
+
+	route := resp.Routes[0]
+	return &CommuteTime{
+		Minutes:        int(math.Round(route.Duration / 60)),
+		DistanceMeters: int(math.Round(route.Distance)),
+	}, nil
+}
+
+// GoogleCommuteProvider uses the Google Distance Matrix API
+type GoogleCommuteProvider struct {
+	APIKey  string
+	BaseURL string
+}
+
+// Name implements CommuteProvider
+func (p *GoogleCommuteProvider) Name() string {
+	return "google"
+}
+
+var googleModes = map[string]string{
+	models.ModeDrive:   "driving",
+	models.ModeTransit: "transit",
+	models.ModeBike:    "bicycling",
+}
+
+type distanceMatrixResponse struct {
+	Status string `json:"status"`
+	Rows   []struct {
+		Elements []struct {
+			Status   string `json:"status"`
+			Duration struct {
+				Value float64 `json:"value"`
+			} `json:"duration"`
+			Distance struct {
+				Value float64 `json:"value"`
+			} `json:"distance"`
+		} `json:"elements"`
+	} `json:"rows"`
+}
+
+// Commute implements CommuteProvider
+func (p *GoogleCommuteProvider) Commute(ctx context.Context, from, to Location, mode string) (*CommuteTime, error) {
+	gmode, ok := googleModes[mode]
+	if !ok {
+		return nil, ErrUnsupportedMode
+	}
+
+	q := url.Values{}
+	q.Set("origins", fmt.Sprintf("%f,%f", from.Latitude, from.Longitude))
+	q.Set("destinations", fmt.Sprintf("%f,%f", to.Latitude, to.Longitude))
+	q.Set("mode", gmode)
+	q.Set("key", p.APIKey)
+
+	var resp distanceMatrixResponse
+	if err := getJSON(ctx, p.BaseURL+"?"+q.Encode(), &resp); err != nil {
+		return nil, err
+	}
+	if resp.Status != "OK" || len(resp.Rows) == 0 || len(resp.Rows[0].Elements) == 0 {
+		return nil, fmt.Errorf("distance matrix API returned status %s", resp.Status)
+	}
+
+	el := resp.Rows[0].Elements[0]
+	if el.Status != "OK" {
+		return nil, fmt.Errorf("distance matrix API returned element status %s", el.Status)
+	}
+	return &CommuteTime{
+		Minutes:        int(math.Round(el.Duration.Value / 60)),
+		DistanceMeters: int(math.Round(el.Distance.Value)),
+	}, nil
+}
+
+// Commute score tuning: trips up to commuteIdealMinutes score 100, falling
+// linearly to 0 at commuteMaxMinutes
+const (
+	commuteIdealMinutes = 10
+	commuteMaxMinutes   = 90
+)
+
+// CommuteScore rates a single trip on a 0-100 scale
+func CommuteScore(minutes int) float64 {
+	score := 100 * float64(commuteMaxMinutes-minutes) / (commuteMaxMinutes - commuteIdealMinutes)
+	return math.Max(0, math.Min(100, score))
+}
+
+// WeightedCommuteScore combines per-destination commute scores using the
+// destinations' weights. It returns nil when there is nothing to weigh.
+func WeightedCommuteScore(commutes []models.Commute, weights map[int64]float64) *float64 {
+	var sum, total float64
+	for _, c := range commutes {
+		w := weights[c.DestinationID]
+		sum += w * CommuteScore(c.Minutes)
+		total += w
+	}
+	if total == 0 {
+		return nil
+	}
+	score := math.Round(sum/total*10) / 10
+	return &score
+}
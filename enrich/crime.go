@@ -0,0 +1,205 @@
+package enrich
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Safety levels derived from crime counts
+const (
+	SafetyHigh   = "high"
+	SafetyMedium = "medium"
+	SafetyLow    = "low"
+)
+
+// crimeGeohashPrecision sets the area crime statistics are cached for;
+// six characters is roughly 1.2km x 0.6km
+const crimeGeohashPrecision = 6
+
+// CrimeStats summarizes reported incidents around a location
+type CrimeStats struct {
+	Incidents int
+	Source    string
+}
+
+// CrimeProvider fetches crime statistics around a location
+type CrimeProvider interface {
+	Name() string
+	CrimeStats(ctx context.Context, loc Location) (*CrimeStats, error)
+}
+
+// CrimeSource configures one crime data source and the area it covers.
+// Sources are typically city or national open-data portals.
+type CrimeSource struct {
+	Name     string `json:"name"`
+	Provider string `json:"provider"` // "socrata" or "ukpolice"
+
+	// BBox limits the source to [min_lat, min_lng, max_lat, max_lng];
+	// an empty box matches everywhere
+	BBox []float64 `json:"bbox"`
+
+	// Socrata settings
+	URL           string `json:"url"`
+	LocationField string `json:"location_field"`
+	DateField     string `json:"date_field"`
+	AppToken      string `json:"app_token"`
+
+	RadiusMeters int `json:"radius_m"`
+	PeriodDays   int `json:"period_days"`
+
+	// Incident counts at or above these thresholds lower the safety level
+	// to medium and low respectively
+	MediumThreshold int `json:"medium_threshold"`
+	LowThreshold    int `json:"low_threshold"`
+
+	provider CrimeProvider
+}
+
+// covers reports whether the source's bounding box contains loc
+func (s *CrimeSource) covers(loc Location) bool {
+	if len(s.BBox) != 4 {
+		return true
+	}
+	return loc.Latitude >= s.BBox[0] && loc.Longitude >= s.BBox[1] &&
+		loc.Latitude <= s.BBox[2] && loc.Longitude <= s.BBox[3]
+}
+
+// Safety maps an incident count onto a safety level using the source's
+// thresholds
+func (s *CrimeSource) Safety(incidents int) string {
+	switch {
+	case incidents >= s.LowThreshold:
+		return SafetyLow
+	case incidents >= s.MediumThreshold:
+		return SafetyMedium
+	}
+	return SafetyHigh
+}
+
+// LoadCrimeSources reads crime source configuration from a JSON file
+// containing an array of CrimeSource. An empty path yields no sources.
+func LoadCrimeSources(path string) ([]*CrimeSource, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read crime sources: %w", err)
+	}
+
+	var sources []*CrimeSource
+	if err := json.Unmarshal(data, &sources); err != nil {
+		return nil, fmt.Errorf("failed to parse crime sources: %w", err)
+	}
+
+	for _, s := range sources {
+		if s.RadiusMeters <= 0 {
+			s.RadiusMeters = 800
+		}
+		if s.PeriodDays <= 0 {
+			s.PeriodDays = 90
+		}
+		if s.MediumThreshold <= 0 {
+			s.MediumThreshold = 50
+		}
+		if s.LowThreshold <= s.MediumThreshold {
+			s.LowThreshold = s.MediumThreshold * 4
+		}
+
+		switch s.Provider {
+		case "socrata":
+			if s.URL == "" || s.LocationField == "" || s.DateField == "" {
+				return nil, fmt.Errorf("crime source %s: socrata requires url, location_field, and date_field", s.Name)
+			}
+			s.provider = &SocrataCrimeProvider{source: s}
+		case "ukpolice":
+			s.provider = &UKPoliceCrimeProvider{BaseURL: "https://data.police.uk/api"}
+		default:
+			return nil, fmt.Errorf("crime source %s: unknown provider %q", s.Name, s.Provider)
+		}
+	}
+	return sources, nil
+}
+
+// crimeSourceFor returns the first source covering loc
+func crimeSourceFor(sources []*CrimeSource, loc Location) *CrimeSource {
+	for _, s := range sources {
+		if s.covers(loc) {
+			return s
+		}
+	}
+	return nil
+}
+
+// SocrataCrimeProvider counts incidents in a Socrata open-data dataset,
+// the platform behind many US city portals (Chicago, Seattle, NYC, ...)
+type SocrataCrimeProvider struct {
+	source *CrimeSource
+}
+
+// Name implements CrimeProvider
+func (p *SocrataCrimeProvider) Name() string {
+	return "socrata:" + p.source.Name
+}
+
+// CrimeStats implements CrimeProvider
+func (p *SocrataCrimeProvider) CrimeStats(ctx context.Context, loc Location) (*CrimeStats, error) {
+	since := time.Now().AddDate(0, 0, -p.source.PeriodDays).Format("2006-01-02T15:04:05")
+	q := url.Values{}
+	q.Set("$select", "count(*) AS incidents")
+	q.Set("$where", fmt.Sprintf("within_circle(%s, %f, %f, %d) AND %s > '%s'",
+		p.source.LocationField, loc.Latitude, loc.Longitude, p.source.RadiusMeters, p.source.DateField, since))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.source.URL+"?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if p.source.AppToken != "" {
+		req.Header.Set("X-App-Token", p.source.AppToken)
+	}
+
+	var rows []struct {
+		Incidents string `json:"incidents"`
+	}
+	if err := doJSON(req, &rows); err != nil {
+		return nil, err
+	}
+	if len(rows) != 1 {
+		return nil, fmt.Errorf("unexpected socrata response with %d rows", len(rows))
+	}
+
+	n, err := strconv.Atoi(rows[0].Incidents)
+	if err != nil {
+		return nil, fmt.Errorf("invalid socrata count %q", rows[0].Incidents)
+	}
+	return &CrimeStats{Incidents: n, Source: p.Name()}, nil
+}
+
+// UKPoliceCrimeProvider uses data.police.uk, which reports street-level
+// crimes within a one-mile radius for the latest published month
+type UKPoliceCrimeProvider struct {
+	BaseURL string
+}
+
+// Name implements CrimeProvider
+func (p *UKPoliceCrimeProvider) Name() string {
+	return "ukpolice"
+}
+
+// CrimeStats implements CrimeProvider
+func (p *UKPoliceCrimeProvider) CrimeStats(ctx context.Context, loc Location) (*CrimeStats, error) {
+	u := fmt.Sprintf("%s/crimes-street/all-crime?lat=%f&lng=%f", p.BaseURL, loc.Latitude, loc.Longitude)
+
+	var crimes []json.RawMessage
+	if err := getJSON(ctx, u, &crimes); err != nil {
+		return nil, err
+	}
+	return &CrimeStats{Incidents: len(crimes), Source: p.Name()}, nil
+}
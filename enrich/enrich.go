@@ -0,0 +1,55 @@
+// Package enrich augments apartments with data from external sources
+// (walkability, neighborhood statistics, and the like). Each kind of data
+// sits behind a small provider interface so sources can be swapped via
+// configuration.
+package enrich
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// userAgent identifies this application to third-party APIs, several of
+// which (e.g. OpenStreetMap services) require one
+const userAgent = "apt-eval/1.0 (+https://github.com/mojotx/apt-eval)"
+
+// Location is the geocoded position of an apartment
+type Location struct {
+	Address   string
+	Latitude  float64
+	Longitude float64
+}
+
+// httpClient is shared by providers that call remote APIs
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// getJSON performs a GET request and decodes a JSON response into v
+func getJSON(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	return doJSON(req, v)
+}
+
+// doJSON sends req and decodes a JSON response into v
+func doJSON(req *http.Request, v any) error {
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s returned %s: %s", req.URL.Host, resp.Status, body)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,297 @@
+package enrich
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mojotx/apt-eval/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGeohash(t *testing.T) {
+	// Reference values from the original geohash.org implementation
+	assert.Equal(t, "ezs42", Geohash(42.6, -5.6, 5))
+	assert.Equal(t, "u4pruydqqvj", Geohash(57.64911, 10.40744, 11))
+	assert.Equal(t, "dr5reg", Geohash(40.7128, -74.0060, 6))
+}
+
+func TestCrimeSourceSafety(t *testing.T) {
+	s := &CrimeSource{MediumThreshold: 50, LowThreshold: 200}
+	assert.Equal(t, SafetyHigh, s.Safety(10))
+	assert.Equal(t, SafetyMedium, s.Safety(50))
+	assert.Equal(t, SafetyLow, s.Safety(250))
+}
+
+func TestCrimeSourceFor(t *testing.T) {
+	chicago := &CrimeSource{Name: "chicago", BBox: []float64{41.64, -87.94, 42.02, -87.52}}
+	fallback := &CrimeSource{Name: "everywhere"}
+	sources := []*CrimeSource{chicago, fallback}
+
+	assert.Equal(t, chicago, crimeSourceFor(sources, Location{Latitude: 41.88, Longitude: -87.63}))
+	assert.Equal(t, fallback, crimeSourceFor(sources, Location{Latitude: 51.5, Longitude: -0.12}))
+	assert.Nil(t, crimeSourceFor([]*CrimeSource{chicago}, Location{Latitude: 51.5, Longitude: -0.12}))
+}
+
+func TestGreatSchoolsProvider(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "secret", r.Header.Get("x-api-key"))
+		w.Write([]byte(`{"schools":[
+			{"district-name":"Springfield Unified","rating":8},
+			{"district-name":"Shelbyville Unified","rating":5},
+			{"district-name":"Springfield Unified","rating":null},
+			{"district-name":"Springfield Unified","rating":7}
+		]}`))
+	}))
+	defer srv.Close()
+
+	p := &GreatSchoolsProvider{APIKey: "secret", BaseURL: srv.URL}
+	s, err := p.Schools(context.Background(), Location{Latitude: 39.8, Longitude: -89.6})
+	assert.NoError(t, err)
+	assert.Equal(t, "Springfield Unified", s.District)
+	if assert.NotNil(t, s.Rating) {
+		assert.Equal(t, 6.7, *s.Rating)
+	}
+}
+
+func TestMarketRentProviders(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/census":
+			if r.URL.Query().Get("y") == "0" {
+				w.Write([]byte(`{"result":{"geographies":{"Counties":[]}}}`))
+				return
+			}
+			w.Write([]byte(`{"result":{"geographies":{"Counties":[{"GEOID":"17167"}]}}}`))
+		case "/fmr/data/1716799999":
+			assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+			w.Write([]byte(`{"data":{"area_name":"Springfield, IL MSA","basicdata":{
+				"Efficiency":780,"One-Bedroom":880,"Two-Bedroom":1100,"Three-Bedroom":1450,"Four-Bedroom":1620}}}`))
+		case "/rent":
+			assert.Equal(t, "Bearer key", r.Header.Get("Authorization"))
+			if r.URL.Query().Get("zip") == "" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			assert.Equal(t, "2", r.URL.Query().Get("beds"))
+			w.Write([]byte(`{"rent":1250,"area":"62704"}`))
+		}
+	}))
+	defer srv.Close()
+
+	loc := Location{Address: "742 Evergreen Terrace, Springfield, IL 62704", Latitude: 39.78, Longitude: -89.65}
+
+	hud := &HUDRentProvider{Token: "token", BaseURL: srv.URL + "/fmr", CensusURL: srv.URL + "/census"}
+	m, err := hud.MarketRent(context.Background(), loc, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, &MarketRent{Rent: 1100, Area: "Springfield, IL MSA"}, m)
+	m, err = hud.MarketRent(context.Background(), loc, 6)
+	assert.NoError(t, err)
+	assert.Equal(t, 1620.0, m.Rent, "larger units use the four-bedroom rent")
+	_, err = hud.MarketRent(context.Background(), Location{}, 1)
+	assert.ErrorIs(t, err, ErrNoCoverage)
+
+	api := &APIRentProvider{URL: srv.URL + "/rent?zip={zip}&beds={bedrooms}", APIKey: "key"}
+	m, err = api.MarketRent(context.Background(), loc, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, &MarketRent{Rent: 1250, Area: "62704"}, m)
+	_, err = api.MarketRent(context.Background(), Location{Address: "Somewhere"}, 2)
+	assert.ErrorIs(t, err, ErrNoCoverage)
+}
+
+func TestHUDSmallAreaRents(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/census" {
+			w.Write([]byte(`{"result":{"geographies":{"Counties":[{"GEOID":"48453"}]}}}`))
+			return
+		}
+		w.Write([]byte(`{"data":{"area_name":"Austin-Round Rock, TX MSA","basicdata":[
+			{"zip_code":"MSA level","One-Bedroom":1400},
+			{"zip_code":"78701","One-Bedroom":2100},
+			{"zip_code":"78744","One-Bedroom":1300}
+		]}}`))
+	}))
+	defer srv.Close()
+
+	hud := &HUDRentProvider{Token: "token", BaseURL: srv.URL, CensusURL: srv.URL + "/census"}
+	m, err := hud.MarketRent(context.Background(), Location{Address: "200 Congress Ave, Austin, TX 78701-4000"}, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, &MarketRent{Rent: 2100, Area: "Austin-Round Rock, TX MSA 78701"}, m)
+
+	m, err = hud.MarketRent(context.Background(), Location{Address: "Austin, TX"}, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, &MarketRent{Rent: 1400, Area: "Austin-Round Rock, TX MSA"}, m, "no ZIP code uses the metro rent")
+}
+
+func TestWeightedCommuteScore(t *testing.T) {
+	assert.Equal(t, 100.0, CommuteScore(5))
+	assert.Equal(t, 50.0, CommuteScore(50))
+	assert.Equal(t, 0.0, CommuteScore(120))
+
+	commutes := []models.Commute{
+		{DestinationID: 1, Minutes: 10},
+		{DestinationID: 2, Minutes: 50},
+	}
+	score := WeightedCommuteScore(commutes, map[int64]float64{1: 3, 2: 1})
+	if assert.NotNil(t, score) {
+		assert.Equal(t, 87.5, *score)
+	}
+	assert.Nil(t, WeightedCommuteScore(commutes, map[int64]float64{}))
+}
+
+func TestGeocoders(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/search" && r.URL.Query().Get("q") == "nowhere":
+			w.Write([]byte(`[]`))
+		case r.URL.Path == "/search":
+			assert.NotEmpty(t, r.Header.Get("User-Agent"))
+			w.Write([]byte(`[{"lat":"40.7484","lon":"-73.9857","display_name":"350 5th Ave, New York"}]`))
+		case r.URL.Path == "/google" && r.URL.Query().Get("address") != "nowhere":
+			assert.Equal(t, "key", r.URL.Query().Get("key"))
+			w.Write([]byte(`{"status":"OK","results":[{"formatted_address":"350 5th Ave","geometry":{"location":{"lat":40.7484,"lng":-73.9857}}}]}`))
+		case r.URL.Path == "/mapbox/350 5th Ave.json":
+			w.Write([]byte(`{"features":[{"place_name":"350 5th Ave","center":[-73.9857,40.7484]}]}`))
+		default:
+			w.Write([]byte(`{"status":"ZERO_RESULTS","features":[]}`))
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	providers := []GeocodeProvider{
+		&NominatimGeocoder{BaseURL: srv.URL},
+		&GoogleGeocoder{APIKey: "key", BaseURL: srv.URL + "/google"},
+		&MapboxGeocoder{AccessToken: "token", BaseURL: srv.URL + "/mapbox"},
+	}
+	for _, p := range providers {
+		g, err := p.Geocode(ctx, "350 5th Ave")
+		if assert.NoError(t, err, p.Name()) {
+			assert.InDelta(t, 40.7484, g.Latitude, 1e-9, p.Name())
+			assert.InDelta(t, -73.9857, g.Longitude, 1e-9, p.Name())
+		}
+		_, err = p.Geocode(ctx, "nowhere")
+		assert.ErrorIs(t, err, ErrAddressNotFound, p.Name())
+	}
+}
+
+func TestNewGeocodeProvider(t *testing.T) {
+	p, err := NewGeocodeProvider("offline", "", "", 0)
+	assert.NoError(t, err)
+	assert.Nil(t, p)
+
+	_, err = NewGeocodeProvider("mapbox", "", "", 0)
+	assert.Error(t, err)
+
+	p, err = NewGeocodeProvider("nominatim", "", "", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "nominatim", p.Name())
+	assert.Equal(t, time.Second, p.(*rateLimitedGeocoder).limiter.interval)
+
+	p, err = NewGeocodeProvider("google", "key", "", 5)
+	assert.NoError(t, err)
+	assert.Equal(t, 200*time.Millisecond, p.(*rateLimitedGeocoder).limiter.interval)
+
+	e := NewEnricher(nil, Config{Geocoder: p})
+	e.SetGeocodeRate(10)
+	assert.Equal(t, 100*time.Millisecond, p.(*rateLimitedGeocoder).limiter.interval)
+	e.SetGeocodeRate(0)
+	assert.Equal(t, 20*time.Millisecond, p.(*rateLimitedGeocoder).limiter.interval)
+}
+
+func TestRateLimiter(t *testing.T) {
+	l := &rateLimiter{interval: 20 * time.Millisecond}
+	start := time.Now()
+	for range 3 {
+		assert.NoError(t, l.wait(context.Background()))
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	l.next = time.Now().Add(time.Hour)
+	assert.ErrorIs(t, l.wait(ctx), context.Canceled)
+}
+
+func TestSuggesters(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		switch r.URL.Path {
+		case "/api/":
+			assert.Equal(t, "3", r.URL.Query().Get("limit"))
+			w.Write([]byte(`{"features":[{"geometry":{"coordinates":[-73.9857,40.7484]},
+				"properties":{"housenumber":"350","street":"5th Avenue","city":"New York","state":"New York","postcode":"10118"}}]}`))
+		case "/google":
+			assert.Equal(t, "key", r.URL.Query().Get("key"))
+			w.Write([]byte(`{"status":"OK","predictions":[{"description":"350 5th Avenue, New York, NY, USA"},{"description":"350 5th Street, Brooklyn, NY, USA"}]}`))
+		case "/mapbox/350 5th.json":
+			assert.Equal(t, "true", r.URL.Query().Get("autocomplete"))
+			w.Write([]byte(`{"features":[{"place_name":"350 5th Avenue, New York, New York 10118","center":[-73.9857,40.7484]}]}`))
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	got, err := (&PhotonSuggester{BaseURL: srv.URL}).Suggest(ctx, "350 5th", 3)
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	assert.Equal(t, "350 5th Avenue, New York, New York 10118", got[0].Address)
+	assert.InDelta(t, 40.7484, *got[0].Latitude, 1e-9)
+
+	got, err = (&GoogleSuggester{APIKey: "key", BaseURL: srv.URL + "/google"}).Suggest(ctx, "350 5th", 1)
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	assert.Nil(t, got[0].Latitude)
+
+	cached := newCachedSuggester(&MapboxSuggester{AccessToken: "token", BaseURL: srv.URL + "/mapbox"}, 10, time.Hour)
+	calls = 0
+	for _, q := range []string{"350 5th", "350  5TH"} {
+		got, err = cached.Suggest(ctx, q, 5)
+		require.NoError(t, err)
+		require.Len(t, got, 1)
+		assert.InDelta(t, -73.9857, *got[0].Longitude, 1e-9)
+	}
+	assert.Equal(t, 1, calls)
+}
+
+func TestNeighborhoods(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/reverse":
+			assert.Equal(t, "40.7484", r.URL.Query().Get("lat"))
+			w.Write([]byte(`{"address":{"suburb":"Manhattan","neighbourhood":"Midtown South"}}`))
+		case "/google":
+			assert.Equal(t, "neighborhood", r.URL.Query().Get("result_type"))
+			w.Write([]byte(`{"status":"OK","results":[{"address_components":[
+				{"long_name":"Midtown South","types":["neighborhood","political"]},
+				{"long_name":"Manhattan","types":["sublocality","political"]}]}]}`))
+		case "/mapbox/-73.985700,40.748400.json":
+			w.Write([]byte(`{"features":[{"text":"Midtown South","place_name":"Midtown South, New York, New York, United States"}]}`))
+		default:
+			w.Write([]byte(`{"status":"ZERO_RESULTS","features":[]}`))
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	loc := Location{Latitude: 40.7484, Longitude: -73.9857}
+	providers := map[string]NeighborhoodProvider{
+		"nominatim": &NominatimGeocoder{BaseURL: srv.URL},
+		"google":    &GoogleGeocoder{APIKey: "key", BaseURL: srv.URL + "/google"},
+		"mapbox":    &MapboxGeocoder{AccessToken: "token", BaseURL: srv.URL + "/mapbox"},
+	}
+	for name, p := range providers {
+		got, err := p.Neighborhood(ctx, loc)
+		if assert.NoError(t, err, name) {
+			assert.Equal(t, "Midtown South", got, name)
+		}
+	}
+
+	got, err := (&MapboxGeocoder{AccessToken: "token", BaseURL: srv.URL + "/elsewhere"}).Neighborhood(ctx, loc)
+	assert.NoError(t, err)
+	assert.Empty(t, got)
+}
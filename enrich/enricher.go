@@ -0,0 +1,508 @@
+package enrich
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mojotx/apt-eval/address"
+	"github.com/mojotx/apt-eval/db"
+	"github.com/mojotx/apt-eval/health"
+	"github.com/mojotx/apt-eval/models"
+	"github.com/rs/zerolog/log"
+)
+
+// ErrNotConfigured is returned when an enrichment has no provider
+var ErrNotConfigured = errors.New("enrichment provider not configured")
+
+// ErrUnknownKind is returned for an enrichment kind that doesn't exist
+var ErrUnknownKind = errors.New("unknown enrichment kind")
+
+// ErrNoLocation is returned when an apartment hasn't been geocoded
+var ErrNoLocation = errors.New("apartment has no location")
+
+// ErrNoCoverage is returned when no configured source covers a location
+var ErrNoCoverage = errors.New("no data source covers this location")
+
+// ErrNoRooms is returned when an apartment's bedrooms can't be counted
+// because none of its rooms have been entered
+var ErrNoRooms = errors.New("apartment has no rooms")
+
+// Config selects and tunes the enrichment providers
+type Config struct {
+	// Geocoder locates apartments entered without coordinates
+	Geocoder GeocodeProvider
+
+	// Suggest completes addresses as they're typed
+	Suggest SuggestProvider
+
+	Walkability WalkabilityProvider
+
+	// CrimeSources are consulted in order; the first whose area covers
+	// an apartment is used
+	CrimeSources []*CrimeSource
+
+	Schools SchoolProvider
+
+	// MarketRent benchmarks prices against the going rent for the
+	// apartment's bedroom count
+	MarketRent MarketRentProvider
+
+	// Transit is built from a GTFS feed at startup
+	Transit *TransitIndex
+
+	// Commute routes trips to the saved destinations; modes it can't
+	// handle fall back to EstimateCommuteProvider
+	Commute CommuteProvider
+
+	// MaxAge is how long enrichment and geocoding results are considered
+	// fresh
+	MaxAge time.Duration
+
+	// RequestDelay spaces out provider calls during batch refreshes to
+	// respect third-party rate limits
+	RequestDelay time.Duration
+}
+
+// Enricher fetches enrichment data and stores it on apartments
+type Enricher struct {
+	db     *db.DB
+	config Config
+}
+
+// NewEnricher creates a new enricher
+func NewEnricher(database *db.DB, config Config) *Enricher {
+	return &Enricher{
+		db:     database,
+		config: config,
+	}
+}
+
+// SetGeocodeRate changes how many requests per second the geocoder may be
+// sent; 0 goes back to the provider's published limit
+func (e *Enricher) SetGeocodeRate(ratePerSecond float64) {
+	if g, ok := e.config.Geocoder.(*rateLimitedGeocoder); ok {
+		g.limiter.setInterval(geocodeInterval(g.Name(), ratePerSecond))
+	}
+}
+
+// Providers names the configured providers that call remote services, by
+// what they provide, for the readiness check to watch
+func (e *Enricher) Providers() map[string]string {
+	providers := map[string]string{}
+	if e.config.Geocoder != nil {
+		providers["geocoder"] = e.config.Geocoder.Name()
+	}
+	if e.config.Suggest != nil {
+		providers["address_suggest"] = e.config.Suggest.Name()
+	}
+	if e.config.Walkability != nil {
+		providers["walkability"] = e.config.Walkability.Name()
+	}
+	var crime []string
+	for _, source := range e.config.CrimeSources {
+		if name := source.provider.Name(); !slices.Contains(crime, name) {
+			crime = append(crime, name)
+		}
+	}
+	if len(crime) > 0 {
+		providers["crime"] = strings.Join(crime, ", ")
+	}
+	if e.config.Schools != nil {
+		providers["schools"] = e.config.Schools.Name()
+	}
+	if e.config.MarketRent != nil {
+		providers["market_rent"] = e.config.MarketRent.Name()
+	}
+	if e.config.Commute != nil && e.config.Commute.Name() != (EstimateCommuteProvider{}).Name() {
+		providers["commute"] = e.config.Commute.Name()
+	}
+	return providers
+}
+
+// recordHealth notes how a call to a provider went for the readiness
+// check. Finding nothing there is the service working.
+func recordHealth(name string, err error) {
+	if errors.Is(err, ErrAddressNotFound) || errors.Is(err, ErrNoCoverage) || errors.Is(err, ErrUnsupportedMode) {
+		err = nil
+	}
+	health.Record(name, err)
+}
+
+// enrichment describes one kind of enrichment
+type enrichment struct {
+	// column is the timestamp column recording when it last ran
+	column string
+	// configured reports whether a provider is available
+	configured bool
+	refresh    func(ctx context.Context, apt *models.Apartment, loc Location) error
+}
+
+// enrichments returns every enrichment kind keyed by name
+func (e *Enricher) enrichments() map[string]enrichment {
+	return map[string]enrichment{
+		"walkability": {
+			column:     "walkability_updated_at",
+			configured: e.config.Walkability != nil,
+			refresh:    e.refreshWalkability,
+		},
+		"crime": {
+			column:     "crime_updated_at",
+			configured: len(e.config.CrimeSources) > 0,
+			refresh:    e.refreshCrime,
+		},
+		"schools": {
+			column:     "schools_updated_at",
+			configured: e.config.Schools != nil,
+			refresh:    e.refreshSchools,
+		},
+		"market_rent": {
+			column:     "market_rent_updated_at",
+			configured: e.config.MarketRent != nil,
+			refresh:    e.refreshMarketRent,
+		},
+		"transit": {
+			column:     "transit_updated_at",
+			configured: e.config.Transit != nil && e.config.Transit.Len() > 0,
+			refresh:    e.refreshTransit,
+		},
+		"commute": {
+			column:     "commute_updated_at",
+			configured: e.config.Commute != nil,
+			refresh:    e.refreshCommute,
+		},
+		"neighborhood": {
+			column:     "neighborhood_updated_at",
+			configured: e.neighborhoods() != nil,
+			refresh:    e.refreshNeighborhood,
+		},
+	}
+}
+
+// Kinds lists the enrichment kinds that have a configured provider
+func (e *Enricher) Kinds() []string {
+	kinds := []string{}
+	for name, en := range e.enrichments() {
+		if en.configured {
+			kinds = append(kinds, name)
+		}
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// locationOf returns the apartment's location, if it has one
+func locationOf(apt *models.Apartment) (Location, error) {
+	if apt.Latitude == nil || apt.Longitude == nil {
+		return Location{}, ErrNoLocation
+	}
+	return Location{Address: apt.Address, Latitude: *apt.Latitude, Longitude: *apt.Longitude}, nil
+}
+
+// Refresh runs one kind of enrichment for apt
+func (e *Enricher) Refresh(ctx context.Context, kind string, apt *models.Apartment) error {
+	en, ok := e.enrichments()[kind]
+	if !ok {
+		return ErrUnknownKind
+	}
+	if !en.configured {
+		return ErrNotConfigured
+	}
+	loc, err := locationOf(apt)
+	if err != nil {
+		return err
+	}
+	return en.refresh(ctx, apt, loc)
+}
+
+// GeocoderName returns the configured geocoder's name, or "" if there is
+// none
+func (e *Enricher) GeocoderName() string {
+	if e.config.Geocoder == nil {
+		return ""
+	}
+	return e.config.Geocoder.Name()
+}
+
+// geocodeQuery keys the geocode cache by canonical address, so spelling
+// variants of one address share an entry
+func geocodeQuery(raw string) string {
+	return address.Normalize(raw)
+}
+
+// Geocode finds the position of an address, answering from the cache when
+// it has a result younger than MaxAge
+func (e *Enricher) Geocode(ctx context.Context, address string) (*models.Geocode, error) {
+	if e.config.Geocoder == nil {
+		return nil, ErrNotConfigured
+	}
+	provider, query := e.config.Geocoder.Name(), geocodeQuery(address)
+	if query == "" {
+		return nil, ErrAddressNotFound
+	}
+
+	result, ok, err := e.db.GetCachedGeocode(provider, query, e.config.MaxAge)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		result, err = e.config.Geocoder.Geocode(ctx, address)
+		recordHealth("geocoder", err)
+		if err != nil && !errors.Is(err, ErrAddressNotFound) {
+			return nil, err
+		}
+		if err := e.db.CacheGeocode(provider, query, result); err != nil {
+			return nil, err
+		}
+	}
+	if result == nil {
+		return nil, ErrAddressNotFound
+	}
+
+	result.Query, result.Provider = query, provider
+	return result, nil
+}
+
+// Suggest returns up to limit completions of a partly typed address
+func (e *Enricher) Suggest(ctx context.Context, query string, limit int) ([]models.AddressSuggestion, error) {
+	if e.config.Suggest == nil {
+		return nil, ErrNotConfigured
+	}
+	suggestions, err := e.config.Suggest.Suggest(ctx, query, limit)
+	recordHealth("address_suggest", err)
+	return suggestions, err
+}
+
+// Locate geocodes an apartment's address and stores its coordinates
+func (e *Enricher) Locate(ctx context.Context, apt *models.Apartment) error {
+	g, err := e.Geocode(ctx, apt.Address)
+	if err != nil {
+		return err
+	}
+	return e.db.SetLocation(apt.ID, g.Latitude, g.Longitude)
+}
+
+// locateMissing geocodes apartments entered without coordinates, returning
+// how many lookups failed. Addresses the geocoder can't find are skipped.
+func (e *Enricher) locateMissing(ctx context.Context) (int, error) {
+	if e.config.Geocoder == nil {
+		return 0, nil
+	}
+	apartments, err := e.db.ListApartmentsWithoutLocation()
+	if err != nil {
+		return 0, err
+	}
+
+	var failures int
+	for i := range apartments {
+		if err := ctx.Err(); err != nil {
+			return failures, err
+		}
+		err := e.Locate(ctx, &apartments[i])
+		if errors.Is(err, ErrAddressNotFound) {
+			log.Debug().Int64("id", apartments[i].ID).Str("address", apartments[i].Address).Msg("Address not found by geocoder")
+		} else if err != nil {
+			log.Warn().Err(err).Int64("id", apartments[i].ID).Msg("Failed to geocode apartment")
+			failures++
+		}
+	}
+	return failures, nil
+}
+
+// RefreshStale geocodes apartments without coordinates, then runs every
+// configured enrichment for located apartments whose data is missing or
+// older than MaxAge. It is meant to run as a scheduled task.
+func (e *Enricher) RefreshStale(ctx context.Context) error {
+	failures, err := e.locateMissing(ctx)
+	if err != nil {
+		return err
+	}
+	for _, kind := range e.Kinds() {
+		en := e.enrichments()[kind]
+		apartments, err := e.db.ListApartmentsNeedingEnrichment(en.column, maxAgeDays(e.config.MaxAge))
+		if err != nil {
+			return err
+		}
+
+		for i := range apartments {
+			err := e.Refresh(ctx, kind, &apartments[i])
+			if err != nil && !errors.Is(err, ErrNoCoverage) && !errors.Is(err, ErrNoRooms) {
+				log.Warn().Err(err).Str("kind", kind).Int64("id", apartments[i].ID).Msg("Failed to refresh enrichment")
+				failures++
+			}
+			if err := sleep(ctx, e.config.RequestDelay); err != nil {
+				return err
+			}
+		}
+	}
+
+	if failures > 0 {
+		return fmt.Errorf("%d enrichment or geocoding lookups failed", failures)
+	}
+	return nil
+}
+
+// refreshWalkability fetches and stores walkability scores
+func (e *Enricher) refreshWalkability(ctx context.Context, apt *models.Apartment, loc Location) error {
+	w, err := e.config.Walkability.Walkability(ctx, loc)
+	recordHealth("walkability", err)
+	if err != nil {
+		return err
+	}
+	return e.db.SetWalkability(apt.ID, w.WalkScore, w.BikeScore, w.TransitScore)
+}
+
+// refreshCrime looks up crime statistics for the apartment's geohash cell,
+// using the cache when it is fresh
+func (e *Enricher) refreshCrime(ctx context.Context, apt *models.Apartment, loc Location) error {
+	source := crimeSourceFor(e.config.CrimeSources, loc)
+	if source == nil {
+		return ErrNoCoverage
+	}
+
+	cell := Geohash(loc.Latitude, loc.Longitude, crimeGeohashPrecision)
+	incidents, ok, err := e.db.GetCachedCrimeStats(cell, source.provider.Name(), e.config.MaxAge)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		stats, err := source.provider.CrimeStats(ctx, loc)
+		recordHealth("crime", err)
+		if err != nil {
+			return err
+		}
+		incidents = stats.Incidents
+		if err := e.db.CacheCrimeStats(cell, stats.Source, incidents); err != nil {
+			return err
+		}
+	}
+
+	return e.db.SetCrime(apt.ID, incidents, source.Safety(incidents))
+}
+
+// refreshSchools fetches and stores the school district
+func (e *Enricher) refreshSchools(ctx context.Context, apt *models.Apartment, loc Location) error {
+	s, err := e.config.Schools.Schools(ctx, loc)
+	recordHealth("schools", err)
+	if err != nil {
+		return err
+	}
+	return e.db.SetSchools(apt.ID, s.District, s.Rating)
+}
+
+// refreshMarketRent looks up the going rent for the apartment's bedroom
+// count, a studio when it has rooms but no bedrooms
+func (e *Enricher) refreshMarketRent(ctx context.Context, apt *models.Apartment, loc Location) error {
+	rooms, err := e.db.ListRooms(apt.ID)
+	if err != nil {
+		return err
+	}
+	if len(rooms) == 0 {
+		return ErrNoRooms
+	}
+	var bedrooms int
+	for _, r := range rooms {
+		if r.Kind == "bedroom" {
+			bedrooms++
+		}
+	}
+
+	m, err := e.config.MarketRent.MarketRent(ctx, loc, bedrooms)
+	recordHealth("market_rent", err)
+	if err != nil {
+		return err
+	}
+	return e.db.SetMarketRent(apt.ID, m.Rent, m.Area, bedrooms)
+}
+
+// refreshTransit computes and stores transit access from the GTFS index
+func (e *Enricher) refreshTransit(ctx context.Context, apt *models.Apartment, loc Location) error {
+	t, err := e.config.Transit.Transit(loc)
+	if err != nil {
+		return err
+	}
+	return e.db.SetTransit(apt.ID, t.NearestStop, t.WalkMinutes, strings.Join(t.Lines, ", "))
+}
+
+// refreshCommute computes the apartment's commute matrix against every
+// saved destination and its weighted commute score
+func (e *Enricher) refreshCommute(ctx context.Context, apt *models.Apartment, loc Location) error {
+	destinations, err := e.db.ListDestinations()
+	if err != nil {
+		return err
+	}
+
+	commutes := make([]models.Commute, 0, len(destinations))
+	weights := make(map[int64]float64, len(destinations))
+	for _, d := range destinations {
+		to := Location{Address: d.Address, Latitude: d.Latitude, Longitude: d.Longitude}
+		t, err := e.config.Commute.Commute(ctx, loc, to, d.Mode)
+		recordHealth("commute", err)
+		if errors.Is(err, ErrUnsupportedMode) {
+			t, err = EstimateCommuteProvider{}.Commute(ctx, loc, to, d.Mode)
+		}
+		if err != nil {
+			return fmt.Errorf("commute to %s: %w", d.Name, err)
+		}
+
+		commutes = append(commutes, models.Commute{
+			DestinationID:  d.ID,
+			Destination:    d.Name,
+			Mode:           d.Mode,
+			Minutes:        t.Minutes,
+			DistanceMeters: t.DistanceMeters,
+		})
+		weights[d.ID] = d.Weight
+	}
+
+	return e.db.SetCommutes(apt.ID, commutes, WeightedCommuteScore(commutes, weights))
+}
+
+// neighborhoods returns the geocoder as a NeighborhoodProvider, or nil when
+// it can't reverse geocode
+func (e *Enricher) neighborhoods() NeighborhoodProvider {
+	p, _ := e.config.Geocoder.(NeighborhoodProvider)
+	return p
+}
+
+// refreshNeighborhood reverse geocodes the apartment's neighborhood. A
+// hand-entered neighborhood is left alone.
+func (e *Enricher) refreshNeighborhood(ctx context.Context, apt *models.Apartment, loc Location) error {
+	if apt.NeighborhoodSource == models.NeighborhoodManual {
+		return e.db.SetNeighborhood(apt.ID, apt.Neighborhood, apt.NeighborhoodSource)
+	}
+	name, err := e.neighborhoods().Neighborhood(ctx, loc)
+	recordHealth("geocoder", err)
+	if err != nil {
+		return err
+	}
+	return e.db.SetNeighborhood(apt.ID, name, e.config.Geocoder.Name())
+}
+
+// maxAgeDays converts a freshness window to whole days (at least one)
+func maxAgeDays(d time.Duration) int {
+	days := int(d / (24 * time.Hour))
+	if days < 1 {
+		return 1
+	}
+	return days
+}
+
+// sleep waits for d or until ctx is cancelled
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,364 @@
+package enrich
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mojotx/apt-eval/models"
+)
+
+// ErrAddressNotFound is returned when a geocoder finds no match for an
+// address
+var ErrAddressNotFound = errors.New("address not found")
+
+// GeocodeProvider finds the position of a street address
+type GeocodeProvider interface {
+	Name() string
+	Geocode(ctx context.Context, address string) (*models.Geocode, error)
+}
+
+// NeighborhoodProvider finds the neighborhood containing a location by
+// reverse geocoding. An empty name means the provider doesn't know one.
+type NeighborhoodProvider interface {
+	Neighborhood(ctx context.Context, loc Location) (string, error)
+}
+
+// Default request spacing for each geocoder, from its usage policy or
+// published rate limit
+var geocodeIntervals = map[string]time.Duration{
+	"nominatim": time.Second,            // 1 request/second
+	"google":    20 * time.Millisecond,  // 50 requests/second
+	"mapbox":    100 * time.Millisecond, // 600 requests/minute
+}
+
+// NewGeocodeProvider returns the geocoder selected by name: "nominatim"
+// (baseURL optionally points at a self-hosted instance), "google" or
+// "mapbox" (both require apiKey), or "" / "none" / "offline" for no
+// geocoding. Requests are spaced to the provider's rate limit unless
+// ratePerSecond overrides it.
+func NewGeocodeProvider(name, apiKey, baseURL string, ratePerSecond float64) (GeocodeProvider, error) {
+	var p GeocodeProvider
+	switch strings.ToLower(name) {
+	case "", "none", "offline":
+		return nil, nil
+	case "nominatim":
+		if baseURL == "" {
+			baseURL = "https://nominatim.openstreetmap.org"
+		}
+		p = &NominatimGeocoder{BaseURL: strings.TrimRight(baseURL, "/")}
+	case "google":
+		if apiKey == "" {
+			return nil, fmt.Errorf("the google geocoder requires an API key")
+		}
+		if baseURL == "" {
+			baseURL = "https://maps.googleapis.com/maps/api/geocode/json"
+		}
+		p = &GoogleGeocoder{APIKey: apiKey, BaseURL: baseURL}
+	case "mapbox":
+		if apiKey == "" {
+			return nil, fmt.Errorf("the mapbox geocoder requires an access token")
+		}
+		if baseURL == "" {
+			baseURL = "https://api.mapbox.com/geocoding/v5/mapbox.places"
+		}
+		p = &MapboxGeocoder{AccessToken: apiKey, BaseURL: strings.TrimRight(baseURL, "/")}
+	default:
+		return nil, fmt.Errorf("unknown geocoder %q", name)
+	}
+
+	return &rateLimitedGeocoder{GeocodeProvider: p, limiter: &rateLimiter{interval: geocodeInterval(p.Name(), ratePerSecond)}}, nil
+}
+
+// geocodeInterval returns the spacing between requests to the named
+// geocoder: ratePerSecond's when set, or the provider's own
+func geocodeInterval(name string, ratePerSecond float64) time.Duration {
+	if ratePerSecond > 0 {
+		return time.Duration(float64(time.Second) / ratePerSecond)
+	}
+	return geocodeIntervals[name]
+}
+
+// rateLimiter spaces out calls to at most one per interval
+type rateLimiter struct {
+	interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+// setInterval changes the spacing of calls from the next one on
+func (l *rateLimiter) setInterval(interval time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.interval = interval
+}
+
+// wait blocks until the caller's turn, reserving the following slot
+func (l *rateLimiter) wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	at := l.next
+	if at.Before(now) {
+		at = now
+	}
+	l.next = at.Add(l.interval)
+	l.mu.Unlock()
+
+	return sleep(ctx, time.Until(at))
+}
+
+// rateLimitedGeocoder holds requests to a provider to its rate limit
+type rateLimitedGeocoder struct {
+	GeocodeProvider
+	limiter *rateLimiter
+}
+
+// Geocode implements GeocodeProvider
+func (g *rateLimitedGeocoder) Geocode(ctx context.Context, address string) (*models.Geocode, error) {
+	if err := g.limiter.wait(ctx); err != nil {
+		return nil, err
+	}
+	return g.GeocodeProvider.Geocode(ctx, address)
+}
+
+// Neighborhood implements NeighborhoodProvider when the wrapped geocoder
+// does
+func (g *rateLimitedGeocoder) Neighborhood(ctx context.Context, loc Location) (string, error) {
+	p, ok := g.GeocodeProvider.(NeighborhoodProvider)
+	if !ok {
+		return "", ErrNotConfigured
+	}
+	if err := g.limiter.wait(ctx); err != nil {
+		return "", err
+	}
+	return p.Neighborhood(ctx, loc)
+}
+
+// NominatimGeocoder queries OpenStreetMap's Nominatim search API. The public
+// instance allows one request per second and no bulk geocoding.
+type NominatimGeocoder struct {
+	BaseURL string
+}
+
+// Name implements GeocodeProvider
+func (g *NominatimGeocoder) Name() string {
+	return "nominatim"
+}
+
+// nominatimPlace is the subset of a Nominatim search result we use
+type nominatimPlace struct {
+	Lat         string `json:"lat"`
+	Lon         string `json:"lon"`
+	DisplayName string `json:"display_name"`
+}
+
+// Geocode implements GeocodeProvider
+func (g *NominatimGeocoder) Geocode(ctx context.Context, address string) (*models.Geocode, error) {
+	q := url.Values{}
+	q.Set("q", address)
+	q.Set("format", "jsonv2")
+	q.Set("limit", "1")
+
+	var places []nominatimPlace
+	if err := getJSON(ctx, g.BaseURL+"/search?"+q.Encode(), &places); err != nil {
+		return nil, err
+	}
+	if len(places) == 0 {
+		return nil, ErrAddressNotFound
+	}
+
+	lat, err := strconv.ParseFloat(places[0].Lat, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid latitude from nominatim: %w", err)
+	}
+	lng, err := strconv.ParseFloat(places[0].Lon, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid longitude from nominatim: %w", err)
+	}
+	return &models.Geocode{Latitude: lat, Longitude: lng, FormattedAddress: places[0].DisplayName}, nil
+}
+
+// nominatimReverse is the subset of a Nominatim reverse lookup we use.
+// OpenStreetMap tags neighborhoods at several levels; the most specific
+// one present is used.
+type nominatimReverse struct {
+	Address struct {
+		Neighbourhood string `json:"neighbourhood"`
+		Quarter       string `json:"quarter"`
+		Suburb        string `json:"suburb"`
+		CityDistrict  string `json:"city_district"`
+	} `json:"address"`
+}
+
+// Neighborhood implements NeighborhoodProvider
+func (g *NominatimGeocoder) Neighborhood(ctx context.Context, loc Location) (string, error) {
+	q := url.Values{}
+	q.Set("lat", strconv.FormatFloat(loc.Latitude, 'f', -1, 64))
+	q.Set("lon", strconv.FormatFloat(loc.Longitude, 'f', -1, 64))
+	q.Set("format", "jsonv2")
+	q.Set("zoom", "16")
+
+	var place nominatimReverse
+	if err := getJSON(ctx, g.BaseURL+"/reverse?"+q.Encode(), &place); err != nil {
+		return "", err
+	}
+	a := place.Address
+	for _, name := range []string{a.Neighbourhood, a.Quarter, a.Suburb, a.CityDistrict} {
+		if name != "" {
+			return name, nil
+		}
+	}
+	return "", nil
+}
+
+// GoogleGeocoder queries the Google Maps Geocoding API
+type GoogleGeocoder struct {
+	APIKey  string
+	BaseURL string
+}
+
+// Name implements GeocodeProvider
+func (g *GoogleGeocoder) Name() string {
+	return "google"
+}
+
+// googleGeocodeResponse is the subset of the Geocoding API response we use
+type googleGeocodeResponse struct {
+	Status       string `json:"status"`
+	ErrorMessage string `json:"error_message"`
+	Results      []struct {
+		FormattedAddress  string `json:"formatted_address"`
+		AddressComponents []struct {
+			LongName string   `json:"long_name"`
+			Types    []string `json:"types"`
+		} `json:"address_components"`
+		Geometry struct {
+			Location struct {
+				Lat float64 `json:"lat"`
+				Lng float64 `json:"lng"`
+			} `json:"location"`
+		} `json:"geometry"`
+	} `json:"results"`
+}
+
+// Geocode implements GeocodeProvider
+func (g *GoogleGeocoder) Geocode(ctx context.Context, address string) (*models.Geocode, error) {
+	q := url.Values{}
+	q.Set("address", address)
+	q.Set("key", g.APIKey)
+
+	var resp googleGeocodeResponse
+	if err := getJSON(ctx, g.BaseURL+"?"+q.Encode(), &resp); err != nil {
+		return nil, err
+	}
+	switch resp.Status {
+	case "OK":
+	case "ZERO_RESULTS":
+		return nil, ErrAddressNotFound
+	default:
+		return nil, fmt.Errorf("google geocoding returned %s: %s", resp.Status, resp.ErrorMessage)
+	}
+	if len(resp.Results) == 0 {
+		return nil, ErrAddressNotFound
+	}
+
+	r := resp.Results[0]
+	return &models.Geocode{
+		Latitude:         r.Geometry.Location.Lat,
+		Longitude:        r.Geometry.Location.Lng,
+		FormattedAddress: r.FormattedAddress,
+	}, nil
+}
+
+// Neighborhood implements NeighborhoodProvider
+func (g *GoogleGeocoder) Neighborhood(ctx context.Context, loc Location) (string, error) {
+	q := url.Values{}
+	q.Set("latlng", fmt.Sprintf("%f,%f", loc.Latitude, loc.Longitude))
+	q.Set("result_type", "neighborhood")
+	q.Set("key", g.APIKey)
+
+	var resp googleGeocodeResponse
+	if err := getJSON(ctx, g.BaseURL+"?"+q.Encode(), &resp); err != nil {
+		return "", err
+	}
+	switch resp.Status {
+	case "OK":
+	case "ZERO_RESULTS":
+		return "", nil
+	default:
+		return "", fmt.Errorf("google reverse geocoding returned %s: %s", resp.Status, resp.ErrorMessage)
+	}
+	for _, r := range resp.Results {
+		for _, c := range r.AddressComponents {
+			if slices.Contains(c.Types, "neighborhood") {
+				return c.LongName, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// MapboxGeocoder queries the Mapbox Geocoding API
+type MapboxGeocoder struct {
+	AccessToken string
+	BaseURL     string
+}
+
+// Name implements GeocodeProvider
+func (g *MapboxGeocoder) Name() string {
+	return "mapbox"
+}
+
+// mapboxResponse is the subset of the Mapbox Geocoding API response we use
+type mapboxResponse struct {
+	Features []struct {
+		Text      string    `json:"text"` // The feature's own name, without its context
+		PlaceName string    `json:"place_name"`
+		Center    []float64 `json:"center"` // longitude, latitude
+	} `json:"features"`
+}
+
+// Geocode implements GeocodeProvider
+func (g *MapboxGeocoder) Geocode(ctx context.Context, address string) (*models.Geocode, error) {
+	q := url.Values{}
+	q.Set("access_token", g.AccessToken)
+	q.Set("limit", "1")
+	q.Set("types", "address")
+
+	var resp mapboxResponse
+	endpoint := fmt.Sprintf("%s/%s.json?%s", g.BaseURL, url.PathEscape(address), q.Encode())
+	if err := getJSON(ctx, endpoint, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Features) == 0 || len(resp.Features[0].Center) != 2 {
+		return nil, ErrAddressNotFound
+	}
+
+	f := resp.Features[0]
+	return &models.Geocode{Latitude: f.Center[1], Longitude: f.Center[0], FormattedAddress: f.PlaceName}, nil
+}
+
+// Neighborhood implements NeighborhoodProvider
+func (g *MapboxGeocoder) Neighborhood(ctx context.Context, loc Location) (string, error) {
+	q := url.Values{}
+	q.Set("access_token", g.AccessToken)
+	q.Set("limit", "1")
+	q.Set("types", "neighborhood")
+
+	var resp mapboxResponse
+	endpoint := fmt.Sprintf("%s/%f,%f.json?%s", g.BaseURL, loc.Longitude, loc.Latitude, q.Encode())
+	if err := getJSON(ctx, endpoint, &resp); err != nil {
+		return "", err
+	}
+	if len(resp.Features) == 0 {
+		return "", nil
+	}
+	return resp.Features[0].Text, nil
+}
@@ -0,0 +1,242 @@
+package enrich
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// walkMetersPerMinute is a typical walking pace (~4.8 km/h)
+	walkMetersPerMinute = 80.0
+	// walkDetourFactor converts straight-line distance into an estimate
+	// of distance along streets
+	walkDetourFactor = 1.3
+	// transitLineMinutes is how far to walk for a stop's lines to count
+	// as serving an apartment
+	transitLineMinutes = 10
+)
+
+// TransitStop is a GTFS stop along with the lines serving it
+type TransitStop struct {
+	Name      string
+	Latitude  float64
+	Longitude float64
+	Lines     []string
+}
+
+// Transit is the transit access from a location
+type Transit struct {
+	// NearestStop is the closest stop with scheduled service
+	NearestStop string
+	WalkMinutes int
+	// Lines serving any stop within transitLineMinutes' walk
+	Lines []string
+}
+
+// TransitIndex answers nearest-stop queries against a GTFS feed loaded
+// into memory
+type TransitIndex struct {
+	stops []TransitStop
+}
+
+// LoadGTFS reads stops and the lines serving them from a GTFS zip file.
+// An empty path yields no index.
+func LoadGTFS(path string) (*TransitIndex, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open GTFS feed: %w", err)
+	}
+	defer zr.Close()
+
+	idx, err := readGTFS(&zr.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read GTFS feed: %w", err)
+	}
+	return idx, nil
+}
+
+// readGTFS joins stops.txt, routes.txt, trips.txt and stop_times.txt
+func readGTFS(zr *zip.Reader) (*TransitIndex, error) {
+	routeNames := map[string]string{}
+	err := eachGTFSRecord(zr, "routes.txt", func(r gtfsRecord) {
+		name := r.get("route_short_name")
+		if name == "" {
+			name = r.get("route_long_name")
+		}
+		routeNames[r.get("route_id")] = name
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tripRoutes := map[string]string{}
+	err = eachGTFSRecord(zr, "trips.txt", func(r gtfsRecord) {
+		tripRoutes[r.get("trip_id")] = r.get("route_id")
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// stop_times.txt is by far the largest file, so only the stop to
+	// line relationship is kept
+	stopLines := map[string]map[string]bool{}
+	err = eachGTFSRecord(zr, "stop_times.txt", func(r gtfsRecord) {
+		name := routeNames[tripRoutes[r.get("trip_id")]]
+		if name == "" {
+			return
+		}
+		stopID := r.get("stop_id")
+		if stopLines[stopID] == nil {
+			stopLines[stopID] = map[string]bool{}
+		}
+		stopLines[stopID][name] = true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	idx := &TransitIndex{}
+	err = eachGTFSRecord(zr, "stops.txt", func(r gtfsRecord) {
+		lines := stopLines[r.get("stop_id")]
+		if len(lines) == 0 {
+			return
+		}
+		lat, err1 := strconv.ParseFloat(r.get("stop_lat"), 64)
+		lng, err2 := strconv.ParseFloat(r.get("stop_lon"), 64)
+		if err1 != nil || err2 != nil {
+			return
+		}
+		idx.stops = append(idx.stops, TransitStop{
+			Name:      r.get("stop_name"),
+			Latitude:  lat,
+			Longitude: lng,
+			Lines:     sortedKeys(lines),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+// Len returns the number of stops in the index
+func (idx *TransitIndex) Len() int {
+	return len(idx.stops)
+}
+
+// Transit finds the nearest stop to loc and the lines within walking
+// distance. It returns ErrNoCoverage when the feed has no stops.
+func (idx *TransitIndex) Transit(loc Location) (*Transit, error) {
+	var nearest *TransitStop
+	var nearestDist float64
+	lines := map[string]bool{}
+
+	for i := range idx.stops {
+		stop := &idx.stops[i]
+		dist := DistanceMeters(loc.Latitude, loc.Longitude, stop.Latitude, stop.Longitude)
+		if nearest == nil || dist < nearestDist {
+			nearest, nearestDist = stop, dist
+		}
+		if walkMinutes(dist) <= transitLineMinutes {
+			for _, l := range stop.Lines {
+				lines[l] = true
+			}
+		}
+	}
+	if nearest == nil {
+		return nil, ErrNoCoverage
+	}
+
+	return &Transit{
+		NearestStop: nearest.Name,
+		WalkMinutes: walkMinutes(nearestDist),
+		Lines:       sortedKeys(lines),
+	}, nil
+}
+
+// walkMinutes estimates the walking time for a straight-line distance
+func walkMinutes(meters float64) int {
+	return int(math.Ceil(meters * walkDetourFactor / walkMetersPerMinute))
+}
+
+// DistanceMeters returns the great-circle distance in meters between two
+// points
+func DistanceMeters(lat1, lng1, lat2, lng2 float64) float64 {
+	const earthRadius = 6371000.0
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLng := (lng2 - lng1) * rad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(a))
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// gtfsRecord is one CSV row addressed by header name
+type gtfsRecord struct {
+	header map[string]int
+	row    []string
+}
+
+func (r gtfsRecord) get(column string) string {
+	if i, ok := r.header[column]; ok && i < len(r.row) {
+		return r.row[i]
+	}
+	return ""
+}
+
+// eachGTFSRecord calls fn for each row of a file in the feed
+func eachGTFSRecord(zr *zip.Reader, name string, fn func(gtfsRecord)) error {
+	f, err := zr.Open(name)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	defer f.Close()
+
+	cr := csv.NewReader(f)
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = true
+
+	first, err := cr.Read()
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	header := map[string]int{}
+	for i, col := range first {
+		// Many feeds start with a UTF-8 byte order mark
+		if i == 0 {
+			col = strings.TrimPrefix(col, "\ufeff")
+		}
+		header[col] = i
+	}
+
+	for {
+		row, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		fn(gtfsRecord{header: header, row: row})
+	}
+}
@@ -0,0 +1,49 @@
+package enrich
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testFeed(t *testing.T, files map[string]string) *zip.Reader {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		w.Write([]byte(content))
+	}
+	require.NoError(t, zw.Close())
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	return zr
+}
+
+func TestTransitIndex(t *testing.T) {
+	zr := testFeed(t, map[string]string{
+		"routes.txt": "\ufeffroute_id,route_short_name,route_long_name\nR,Red,Red Line\nB,,Blue Line\n",
+		"trips.txt":  "route_id,service_id,trip_id\nR,wk,t1\nB,wk,t2\n",
+		"stop_times.txt": "trip_id,arrival_time,departure_time,stop_id,stop_sequence\n" +
+			"t1,08:00:00,08:00:00,near,1\nt1,08:05:00,08:05:00,far,2\nt2,08:00:00,08:00:00,mid,1\n",
+		"stops.txt": "stop_id,stop_name,stop_lat,stop_lon\n" +
+			"near,Main St,40.0000,-75.0000\nmid,Oak Ave,40.0040,-75.0000\nfar,Airport,40.1000,-75.0000\nunused,Depot,40.0001,-75.0000\n",
+	})
+
+	idx, err := readGTFS(zr)
+	require.NoError(t, err)
+	assert.Equal(t, 3, idx.Len())
+
+	tr, err := idx.Transit(Location{Latitude: 40.0010, Longitude: -75.0000})
+	require.NoError(t, err)
+	assert.Equal(t, "Main St", tr.NearestStop)
+	assert.Equal(t, 2, tr.WalkMinutes)
+	assert.Equal(t, []string{"Blue Line", "Red"}, tr.Lines)
+
+	_, err = (&TransitIndex{}).Transit(Location{})
+	assert.ErrorIs(t, err, ErrNoCoverage)
+}
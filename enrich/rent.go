@@ -0,0 +1,239 @@
+package enrich
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// MarketRent is the going rent in an area for a number of bedrooms
+type MarketRent struct {
+	Rent float64
+	Area string // The area the rent is for, as the source names it
+}
+
+// MarketRentProvider looks up the going rent around a location for a
+// number of bedrooms, 0 for a studio
+type MarketRentProvider interface {
+	Name() string
+	MarketRent(ctx context.Context, loc Location, bedrooms int) (*MarketRent, error)
+}
+
+// NewMarketRentProvider returns the provider selected by name: "hud" (HUD
+// Fair Market Rents, US only; requires apiKey, a HUD USER token), "api"
+// (any service answering at the baseURL template), or "" / "none" for no
+// provider
+func NewMarketRentProvider(name, apiKey, baseURL string) (MarketRentProvider, error) {
+	switch strings.ToLower(name) {
+	case "", "none":
+		return nil, nil
+	case "hud":
+		if apiKey == "" {
+			return nil, fmt.Errorf("the hud provider requires a HUD USER API token")
+		}
+		return &HUDRentProvider{
+			Token:     apiKey,
+			BaseURL:   "https://www.huduser.gov/hudapi/public/fmr",
+			CensusURL: "https://geocoding.geo.census.gov/geocoder/geographies/coordinates",
+		}, nil
+	case "api":
+		if baseURL == "" {
+			return nil, fmt.Errorf("the api provider requires a URL")
+		}
+		return &APIRentProvider{URL: baseURL, APIKey: apiKey}, nil
+	}
+	return nil, fmt.Errorf("unknown market rent provider %q", name)
+}
+
+// HUDRentProvider looks up HUD's Fair Market Rents: the 40th percentile of
+// rents in the county or metro area, or in the ZIP code where HUD sets
+// Small Area FMRs. The county comes from the US Census Bureau geocoder.
+type HUDRentProvider struct {
+	Token     string
+	BaseURL   string
+	CensusURL string
+}
+
+// Name implements MarketRentProvider
+func (p *HUDRentProvider) Name() string {
+	return "hud"
+}
+
+// hudBedroomFields name the FMR for each bedroom count; larger units use
+// the four-bedroom rent
+var hudBedroomFields = []string{"Efficiency", "One-Bedroom", "Two-Bedroom", "Three-Bedroom", "Four-Bedroom"}
+
+// zipPattern finds a US ZIP code at the end of an address
+var zipPattern = regexp.MustCompile(`\b(\d{5})(?:-\d{4})?\s*(?:,?\s*(?:USA?|United States))?\s*$`)
+
+type hudFMRResponse struct {
+	Data struct {
+		AreaName   string `json:"area_name"`
+		CountyName string `json:"county_name"`
+		// An object, or per ZIP code objects for Small Area FMRs
+		BasicData json.RawMessage `json:"basicdata"`
+	} `json:"data"`
+}
+
+// MarketRent implements MarketRentProvider
+func (p *HUDRentProvider) MarketRent(ctx context.Context, loc Location, bedrooms int) (*MarketRent, error) {
+	county, err := p.county(ctx, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	// County-level entities are the county's FIPS code followed by 99999
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.BaseURL+"/data/"+county+"99999", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+p.Token)
+	var resp hudFMRResponse
+	if err := doJSON(req, &resp); err != nil {
+		return nil, err
+	}
+
+	var rents []map[string]any
+	if err := json.Unmarshal(resp.Data.BasicData, &rents); err != nil {
+		var single map[string]any
+		if err := json.Unmarshal(resp.Data.BasicData, &single); err != nil {
+			return nil, fmt.Errorf("unexpected HUD FMR data: %w", err)
+		}
+		rents = []map[string]any{single}
+	}
+	if len(rents) == 0 {
+		return nil, ErrNoCoverage
+	}
+
+	// Small Area FMRs are per ZIP code, with one for the whole metro area
+	area := resp.Data.AreaName
+	if area == "" {
+		area = resp.Data.CountyName
+	}
+	chosen := rents[0]
+	zip := ""
+	if m := zipPattern.FindStringSubmatch(loc.Address); m != nil {
+		zip = m[1]
+	}
+	for _, r := range rents {
+		switch r["zip_code"] {
+		case zip:
+			chosen = r
+		case "MSA level":
+			if chosen["zip_code"] != zip {
+				chosen = r
+			}
+		}
+	}
+	if code, _ := chosen["zip_code"].(string); code != "" && code != "MSA level" {
+		area += " " + code
+	}
+
+	field := hudBedroomFields[min(max(bedrooms, 0), len(hudBedroomFields)-1)]
+	rent, ok := chosen[field].(float64)
+	if !ok || rent <= 0 {
+		return nil, ErrNoCoverage
+	}
+	return &MarketRent{Rent: rent, Area: area}, nil
+}
+
+type censusCountyResponse struct {
+	Result struct {
+		Geographies struct {
+			Counties []struct {
+				GEOID string `json:"GEOID"`
+			} `json:"Counties"`
+		} `json:"geographies"`
+	} `json:"result"`
+}
+
+// county returns the five-digit FIPS code of the county containing loc
+func (p *HUDRentProvider) county(ctx context.Context, loc Location) (string, error) {
+	q := url.Values{}
+	q.Set("x", strconv.FormatFloat(loc.Longitude, 'f', -1, 64))
+	q.Set("y", strconv.FormatFloat(loc.Latitude, 'f', -1, 64))
+	q.Set("benchmark", "Public_AR_Current")
+	q.Set("vintage", "Current_Current")
+	q.Set("layers", "Counties")
+	q.Set("format", "json")
+
+	var resp censusCountyResponse
+	if err := getJSON(ctx, p.CensusURL+"?"+q.Encode(), &resp); err != nil {
+		return "", err
+	}
+	counties := resp.Result.Geographies.Counties
+	if len(counties) == 0 || len(counties[0].GEOID) != 5 {
+		return "", ErrNoCoverage
+	}
+	return counties[0].GEOID, nil
+}
+
+// APIRentProvider asks a rent benchmark service of your choosing. URL is a
+// template: {lat}, {lon}, {bedrooms}, and {zip} are replaced with the
+// apartment's. The service answers with JSON like
+// {"rent": 1850, "area": "Springfield, IL"}, or 404 for places it doesn't
+// cover. APIKey, when set, is sent as a bearer token.
+type APIRentProvider struct {
+	URL    string
+	APIKey string
+}
+
+// Name implements MarketRentProvider
+func (p *APIRentProvider) Name() string {
+	return "api"
+}
+
+type apiRentResponse struct {
+	Rent *float64 `json:"rent"`
+	Area string   `json:"area"`
+}
+
+// MarketRent implements MarketRentProvider
+func (p *APIRentProvider) MarketRent(ctx context.Context, loc Location, bedrooms int) (*MarketRent, error) {
+	zip := ""
+	if m := zipPattern.FindStringSubmatch(loc.Address); m != nil {
+		zip = m[1]
+	}
+	u := strings.NewReplacer(
+		"{lat}", strconv.FormatFloat(loc.Latitude, 'f', -1, 64),
+		"{lon}", strconv.FormatFloat(loc.Longitude, 'f', -1, 64),
+		"{bedrooms}", strconv.Itoa(bedrooms),
+		"{zip}", zip,
+	).Replace(p.URL)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if p.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.APIKey)
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNoCoverage
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %s", req.URL.Host, resp.Status)
+	}
+
+	var body apiRentResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	if body.Rent == nil || *body.Rent <= 0 {
+		return nil, ErrNoCoverage
+	}
+	return &MarketRent{Rent: *body.Rent, Area: body.Area}, nil
+}
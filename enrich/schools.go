@@ -0,0 +1,165 @@
+package enrich
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Schools describes the schools serving a location. Rating is an average
+// on a 1-10 scale and is nil when the provider doesn't supply ratings.
+type Schools struct {
+	District string
+	Rating   *float64
+}
+
+// SchoolProvider looks up the school district for a location
+type SchoolProvider interface {
+	Name() string
+	Schools(ctx context.Context, loc Location) (*Schools, error)
+}
+
+// NewSchoolProvider returns the provider selected by name: "census" (US
+// district boundaries, no ratings), "greatschools" (requires apiKey), or ""
+// / "none" for no provider
+func NewSchoolProvider(name, apiKey string) (SchoolProvider, error) {
+	switch strings.ToLower(name) {
+	case "", "none":
+		return nil, nil
+	case "census":
+		return &CensusSchoolProvider{BaseURL: "https://geocoding.geo.census.gov/geocoder/geographies/coordinates"}, nil
+	case "greatschools":
+		if apiKey == "" {
+			return nil, fmt.Errorf("the greatschools provider requires an API key")
+		}
+		return &GreatSchoolsProvider{APIKey: apiKey, BaseURL: "https://gs-api.greatschools.org/nearby-schools"}, nil
+	}
+	return nil, fmt.Errorf("unknown school provider %q", name)
+}
+
+// CensusSchoolProvider finds the school district containing a location
+// using the US Census Bureau geocoder. It needs no API key.
+type CensusSchoolProvider struct {
+	BaseURL string
+}
+
+// Name implements SchoolProvider
+func (p *CensusSchoolProvider) Name() string {
+	return "census"
+}
+
+// censusDistrictLayers are checked in order; most of the US has unified
+// districts, the rest split elementary and secondary
+var censusDistrictLayers = []string{
+	"Unified School Districts",
+	"Elementary School Districts",
+	"Secondary School Districts",
+}
+
+type censusGeographiesResponse struct {
+	Result struct {
+		Geographies map[string][]struct {
+			Name string `json:"NAME"`
+		} `json:"geographies"`
+	} `json:"result"`
+}
+
+// Schools implements SchoolProvider
+func (p *CensusSchoolProvider) Schools(ctx context.Context, loc Location) (*Schools, error) {
+	q := url.Values{}
+	q.Set("x", strconv.FormatFloat(loc.Longitude, 'f', -1, 64))
+	q.Set("y", strconv.FormatFloat(loc.Latitude, 'f', -1, 64))
+	q.Set("benchmark", "Public_AR_Current")
+	q.Set("vintage", "Current_Current")
+	q.Set("layers", strings.Join(censusDistrictLayers, ","))
+	q.Set("format", "json")
+
+	var resp censusGeographiesResponse
+	if err := getJSON(ctx, p.BaseURL+"?"+q.Encode(), &resp); err != nil {
+		return nil, err
+	}
+	for _, layer := range censusDistrictLayers {
+		if districts := resp.Result.Geographies[layer]; len(districts) > 0 && districts[0].Name != "" {
+			return &Schools{District: districts[0].Name}, nil
+		}
+	}
+	return nil, ErrNoCoverage
+}
+
+// GreatSchoolsProvider queries the GreatSchools nearby-schools API and
+// reports the district and average rating of the closest schools
+type GreatSchoolsProvider struct {
+	APIKey  string
+	BaseURL string
+}
+
+// Name implements SchoolProvider
+func (p *GreatSchoolsProvider) Name() string {
+	return "greatschools"
+}
+
+type greatSchoolsResponse struct {
+	Schools []struct {
+		DistrictName string `json:"district-name"`
+		Rating       *int   `json:"rating"`
+	} `json:"schools"`
+}
+
+// Schools implements SchoolProvider
+func (p *GreatSchoolsProvider) Schools(ctx context.Context, loc Location) (*Schools, error) {
+	q := url.Values{}
+	q.Set("lat", strconv.FormatFloat(loc.Latitude, 'f', -1, 64))
+	q.Set("lon", strconv.FormatFloat(loc.Longitude, 'f', -1, 64))
+	q.Set("distance", "3")
+	q.Set("limit", "10")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.BaseURL+"?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("x-api-key", p.APIKey)
+
+	var resp greatSchoolsResponse
+	if err := doJSON(req, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Schools) == 0 {
+		return nil, ErrNoCoverage
+	}
+
+	// The district is the one most nearby schools belong to
+	counts := map[string]int{}
+	var sum, rated float64
+	for _, s := range resp.Schools {
+		if s.DistrictName != "" {
+			counts[s.DistrictName]++
+		}
+		if s.Rating != nil {
+			sum += float64(*s.Rating)
+			rated++
+		}
+	}
+
+	schools := &Schools{District: mostCommon(counts)}
+	if rated > 0 {
+		avg := math.Round(sum/rated*10) / 10
+		schools.Rating = &avg
+	}
+	return schools, nil
+}
+
+// mostCommon returns the key with the highest count, breaking ties
+// alphabetically so results are stable
+func mostCommon(counts map[string]int) string {
+	var best string
+	for k, n := range counts {
+		if n > counts[best] || (n == counts[best] && k < best) {
+			best = k
+		}
+	}
+	return best
+}
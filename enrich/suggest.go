@@ -0,0 +1,253 @@
+package enrich
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mojotx/apt-eval/cache"
+	"github.com/mojotx/apt-eval/models"
+)
+
+const (
+	// suggestCacheSize is how many distinct queries are kept in memory
+	suggestCacheSize = 1000
+
+	// suggestCacheTTL is how long suggestions are reused; addresses
+	// rarely change, but provider results do improve
+	suggestCacheTTL = 24 * time.Hour
+)
+
+// SuggestProvider completes partly typed addresses
+type SuggestProvider interface {
+	Name() string
+	Suggest(ctx context.Context, query string, limit int) ([]models.AddressSuggestion, error)
+}
+
+// NewSuggestProvider returns the autocomplete provider selected by name:
+// "photon" (OpenStreetMap data, no key; baseURL optionally points at a
+// self-hosted instance), "google" or "mapbox" (both require apiKey), or ""
+// / "none" for no suggestions. Results are cached in memory.
+func NewSuggestProvider(name, apiKey, baseURL string) (SuggestProvider, error) {
+	var p SuggestProvider
+	switch strings.ToLower(name) {
+	case "", "none":
+		return nil, nil
+	case "photon":
+		if baseURL == "" {
+			baseURL = "https://photon.komoot.io"
+		}
+		p = &PhotonSuggester{BaseURL: strings.TrimRight(baseURL, "/")}
+	case "google":
+		if apiKey == "" {
+			return nil, fmt.Errorf("google address suggestions require an API key")
+		}
+		if baseURL == "" {
+			baseURL = "https://maps.googleapis.com/maps/api/place/autocomplete/json"
+		}
+		p = &GoogleSuggester{APIKey: apiKey, BaseURL: baseURL}
+	case "mapbox":
+		if apiKey == "" {
+			return nil, fmt.Errorf("mapbox address suggestions require an access token")
+		}
+		if baseURL == "" {
+			baseURL = "https://api.mapbox.com/geocoding/v5/mapbox.places"
+		}
+		p = &MapboxSuggester{AccessToken: apiKey, BaseURL: strings.TrimRight(baseURL, "/")}
+	default:
+		return nil, fmt.Errorf("unknown address suggestion provider %q", name)
+	}
+	return newCachedSuggester(p, suggestCacheSize, suggestCacheTTL), nil
+}
+
+// suggestEntry is a cached set of suggestions
+type suggestEntry struct {
+	suggestions []models.AddressSuggestion
+	fetchedAt   time.Time
+}
+
+// cachedSuggester answers repeated queries from memory. Autocomplete sends
+// a request per keystroke, so the same prefixes come up again and again.
+type cachedSuggester struct {
+	SuggestProvider
+	ttl   time.Duration
+	cache *cache.LRU[string, suggestEntry]
+}
+
+func newCachedSuggester(p SuggestProvider, size int, ttl time.Duration) *cachedSuggester {
+	return &cachedSuggester{
+		SuggestProvider: p,
+		ttl:             ttl,
+		cache:           cache.New[string, suggestEntry](size, nil),
+	}
+}
+
+// Suggest implements SuggestProvider
+func (s *cachedSuggester) Suggest(ctx context.Context, query string, limit int) ([]models.AddressSuggestion, error) {
+	key := strconv.Itoa(limit) + ":" + strings.Join(strings.Fields(strings.ToLower(query)), " ")
+	if e, ok := s.cache.Get(key); ok && time.Since(e.fetchedAt) < s.ttl {
+		return e.suggestions, nil
+	}
+
+	suggestions, err := s.SuggestProvider.Suggest(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	s.cache.Add(key, suggestEntry{suggestions: suggestions, fetchedAt: time.Now()})
+	return suggestions, nil
+}
+
+// PhotonSuggester queries a Photon search-as-you-type server, which is
+// built on OpenStreetMap data. Unlike Nominatim, the public instance
+// permits autocomplete.
+type PhotonSuggester struct {
+	BaseURL string
+}
+
+// Name implements SuggestProvider
+func (s *PhotonSuggester) Name() string {
+	return "photon"
+}
+
+// photonResponse is the subset of a Photon GeoJSON response we use
+type photonResponse struct {
+	Features []struct {
+		Geometry struct {
+			Coordinates []float64 `json:"coordinates"` // longitude, latitude
+		} `json:"geometry"`
+		Properties struct {
+			Name        string `json:"name"`
+			HouseNumber string `json:"housenumber"`
+			Street      string `json:"street"`
+			City        string `json:"city"`
+			State       string `json:"state"`
+			Postcode    string `json:"postcode"`
+		} `json:"properties"`
+	} `json:"features"`
+}
+
+// Suggest implements SuggestProvider
+func (s *PhotonSuggester) Suggest(ctx context.Context, query string, limit int) ([]models.AddressSuggestion, error) {
+	q := url.Values{}
+	q.Set("q", query)
+	q.Set("limit", strconv.Itoa(limit))
+	q.Set("lang", "en")
+	q.Add("layer", "house")
+	q.Add("layer", "street")
+
+	var resp photonResponse
+	if err := getJSON(ctx, s.BaseURL+"/api/?"+q.Encode(), &resp); err != nil {
+		return nil, err
+	}
+
+	suggestions := []models.AddressSuggestion{}
+	for _, f := range resp.Features {
+		p := f.Properties
+		street := strings.TrimSpace(p.HouseNumber + " " + p.Street)
+		if street == "" {
+			street = p.Name
+		}
+		var parts []string
+		for _, part := range []string{street, p.City, strings.TrimSpace(p.State + " " + p.Postcode)} {
+			if part != "" {
+				parts = append(parts, part)
+			}
+		}
+		if len(parts) == 0 {
+			continue
+		}
+
+		suggestion := models.AddressSuggestion{Address: strings.Join(parts, ", ")}
+		if c := f.Geometry.Coordinates; len(c) == 2 {
+			suggestion.Latitude, suggestion.Longitude = &c[1], &c[0]
+		}
+		suggestions = append(suggestions, suggestion)
+	}
+	return suggestions, nil
+}
+
+// GoogleSuggester queries the Google Places Autocomplete API, restricted to
+// street addresses. It returns no coordinates.
+type GoogleSuggester struct {
+	APIKey  string
+	BaseURL string
+}
+
+// Name implements SuggestProvider
+func (s *GoogleSuggester) Name() string {
+	return "google"
+}
+
+// googleAutocompleteResponse is the subset of the Autocomplete API response
+// we use
+type googleAutocompleteResponse struct {
+	Status       string `json:"status"`
+	ErrorMessage string `json:"error_message"`
+	Predictions  []struct {
+		Description string `json:"description"`
+	} `json:"predictions"`
+}
+
+// Suggest implements SuggestProvider
+func (s *GoogleSuggester) Suggest(ctx context.Context, query string, limit int) ([]models.AddressSuggestion, error) {
+	q := url.Values{}
+	q.Set("input", query)
+	q.Set("types", "address")
+	q.Set("key", s.APIKey)
+
+	var resp googleAutocompleteResponse
+	if err := getJSON(ctx, s.BaseURL+"?"+q.Encode(), &resp); err != nil {
+		return nil, err
+	}
+	if resp.Status != "OK" && resp.Status != "ZERO_RESULTS" {
+		return nil, fmt.Errorf("google autocomplete returned %s: %s", resp.Status, resp.ErrorMessage)
+	}
+
+	suggestions := []models.AddressSuggestion{}
+	for _, p := range resp.Predictions {
+		if len(suggestions) == limit {
+			break
+		}
+		suggestions = append(suggestions, models.AddressSuggestion{Address: p.Description})
+	}
+	return suggestions, nil
+}
+
+// MapboxSuggester queries the Mapbox Geocoding API in autocomplete mode
+type MapboxSuggester struct {
+	AccessToken string
+	BaseURL     string
+}
+
+// Name implements SuggestProvider
+func (s *MapboxSuggester) Name() string {
+	return "mapbox"
+}
+
+// Suggest implements SuggestProvider
+func (s *MapboxSuggester) Suggest(ctx context.Context, query string, limit int) ([]models.AddressSuggestion, error) {
+	q := url.Values{}
+	q.Set("access_token", s.AccessToken)
+	q.Set("autocomplete", "true")
+	q.Set("limit", strconv.Itoa(limit))
+	q.Set("types", "address")
+
+	var resp mapboxResponse
+	endpoint := fmt.Sprintf("%s/%s.json?%s", s.BaseURL, url.PathEscape(query), q.Encode())
+	if err := getJSON(ctx, endpoint, &resp); err != nil {
+		return nil, err
+	}
+
+	suggestions := []models.AddressSuggestion{}
+	for _, f := range resp.Features {
+		suggestion := models.AddressSuggestion{Address: f.PlaceName}
+		if c := f.Center; len(c) == 2 {
+			suggestion.Latitude, suggestion.Longitude = &c[1], &c[0]
+		}
+		suggestions = append(suggestions, suggestion)
+	}
+	return suggestions, nil
+}
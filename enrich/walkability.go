@@ -0,0 +1,169 @@
+package enrich
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Walkability holds 0-100 scores for getting around without a car. Scores
+// a provider can't supply are nil.
+type Walkability struct {
+	WalkScore    *int
+	BikeScore    *int
+	TransitScore *int
+}
+
+// WalkabilityProvider looks up walkability scores for a location
+type WalkabilityProvider interface {
+	Name() string
+	Walkability(ctx context.Context, loc Location) (*Walkability, error)
+}
+
+// NewWalkabilityProvider returns the provider selected by name: "walkscore"
+// (requires apiKey), "osm", or "" / "none" for no provider
+func NewWalkabilityProvider(name, apiKey, overpassURL string) (WalkabilityProvider, error) {
+	switch strings.ToLower(name) {
+	case "", "none":
+		return nil, nil
+	case "walkscore":
+		if apiKey == "" {
+			return nil, fmt.Errorf("the walkscore provider requires an API key")
+		}
+		return &WalkScoreProvider{APIKey: apiKey, BaseURL: "https://api.walkscore.com/score"}, nil
+	case "osm":
+		if overpassURL == "" {
+			overpassURL = "https://overpass-api.de/api/interpreter"
+		}
+		return &OSMWalkabilityProvider{OverpassURL: overpassURL}, nil
+	}
+	return nil, fmt.Errorf("unknown walkability provider %q", name)
+}
+
+// WalkScoreProvider queries the Walk Score API
+type WalkScoreProvider struct {
+	APIKey  string
+	BaseURL string
+}
+
+// Name implements WalkabilityProvider
+func (p *WalkScoreProvider) Name() string {
+	return "walkscore"
+}
+
+// walkScoreResponse is the subset of the Walk Score API response we use
+type walkScoreResponse struct {
+	Status    int  `json:"status"`
+	WalkScore *int `json:"walkscore"`
+	Transit   *struct {
+		Score *int `json:"score"`
+	} `json:"transit"`
+	Bike *struct {
+		Score *int `json:"score"`
+	} `json:"bike"`
+}
+
+// Walkability implements WalkabilityProvider
+func (p *WalkScoreProvider) Walkability(ctx context.Context, loc Location) (*Walkability, error) {
+	q := url.Values{}
+	q.Set("format", "json")
+	q.Set("address", loc.Address)
+	q.Set("lat", strconv.FormatFloat(loc.Latitude, 'f', -1, 64))
+	q.Set("lon", strconv.FormatFloat(loc.Longitude, 'f', -1, 64))
+	q.Set("transit", "1")
+	q.Set("bike", "1")
+	q.Set("wsapikey", p.APIKey)
+
+	var resp walkScoreResponse
+	if err := getJSON(ctx, p.BaseURL+"?"+q.Encode(), &resp); err != nil {
+		return nil, err
+	}
+	// Status 1 is success; 2 means the score is still being calculated
+	if resp.Status != 1 {
+		return nil, fmt.Errorf("walk score API returned status %d", resp.Status)
+	}
+
+	w := &Walkability{WalkScore: resp.WalkScore}
+	if resp.Transit != nil {
+		w.TransitScore = resp.Transit.Score
+	}
+	if resp.Bike != nil {
+		w.BikeScore = resp.Bike.Score
+	}
+	return w, nil
+}
+
+// OSMWalkabilityProvider estimates scores from OpenStreetMap data via the
+// Overpass API by counting nearby destinations, cycle infrastructure, and
+// transit stops. It needs no API key but is only a heuristic.
+type OSMWalkabilityProvider struct {
+	OverpassURL string
+}
+
+// Name implements WalkabilityProvider
+func (p *OSMWalkabilityProvider) Name() string {
+	return "osm"
+}
+
+// overpassQuery counts, in order: everyday destinations within 800m, cycle
+// ways within 1km, and transit stops within 800m
+const overpassQuery = `[out:json][timeout:25];
+(node(around:800,%[1]s,%[2]s)[shop];node(around:800,%[1]s,%[2]s)[amenity~"^(restaurant|cafe|pharmacy|bank|school|library|marketplace|clinic|post_office)$"];);out count;
+way(around:1000,%[1]s,%[2]s)[highway=cycleway];out count;
+(node(around:800,%[1]s,%[2]s)[public_transport=platform];node(around:800,%[1]s,%[2]s)[highway=bus_stop];node(around:800,%[1]s,%[2]s)[railway=station];);out count;`
+
+type overpassCountResponse struct {
+	Elements []struct {
+		Tags struct {
+			Total string `json:"total"`
+		} `json:"tags"`
+	} `json:"elements"`
+}
+
+// Walkability implements WalkabilityProvider
+func (p *OSMWalkabilityProvider) Walkability(ctx context.Context, loc Location) (*Walkability, error) {
+	lat := strconv.FormatFloat(loc.Latitude, 'f', 6, 64)
+	lon := strconv.FormatFloat(loc.Longitude, 'f', 6, 64)
+	body := url.Values{"data": {fmt.Sprintf(overpassQuery, lat, lon)}}.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.OverpassURL, strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var resp overpassCountResponse
+	if err := doJSON(req, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Elements) != 3 {
+		return nil, fmt.Errorf("unexpected overpass response with %d elements", len(resp.Elements))
+	}
+
+	counts := make([]float64, 3)
+	for i, el := range resp.Elements {
+		n, err := strconv.Atoi(el.Tags.Total)
+		if err != nil {
+			return nil, fmt.Errorf("invalid overpass count %q", el.Tags.Total)
+		}
+		counts[i] = float64(n)
+	}
+
+	// Saturating curves: the scale constants are roughly the counts at
+	// which an area feels "very walkable" (score ~86)
+	return &Walkability{
+		WalkScore:    saturate(counts[0], 40),
+		BikeScore:    saturate(counts[1], 8),
+		TransitScore: saturate(counts[2], 6),
+	}, nil
+}
+
+// saturate maps a count onto 0-100 with diminishing returns
+func saturate(count, scale float64) *int {
+	score := int(math.Round(100 * (1 - math.Exp(-count/(scale/2)))))
+	return &score
+}
@@ -0,0 +1,63 @@
+package export
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// Airtable keeps one record per apartment in a table of an Airtable base,
+// using a personal access token with the data.records:write scope. The
+// table needs a field named after each column; select options are created
+// as needed.
+type Airtable struct {
+	Token   string
+	BaseID  string
+	Table   string
+	BaseURL string
+}
+
+// Name implements Exporter
+func (a *Airtable) Name() string {
+	return "airtable"
+}
+
+// airtableRecord is the part of a record response the exporter reads
+type airtableRecord struct {
+	ID string `json:"id"`
+}
+
+func (a *Airtable) tableURL() string {
+	return fmt.Sprintf("%s/v0/%s/%s", a.BaseURL, url.PathEscape(a.BaseID), url.PathEscape(a.Table))
+}
+
+// Create implements Exporter
+func (a *Airtable) Create(ctx context.Context, fields map[string]any) (string, error) {
+	var record airtableRecord
+	err := doJSON(ctx, http.MethodPost, a.tableURL(), a.Token, nil, map[string]any{
+		"fields":   fields,
+		"typecast": true,
+	}, &record)
+	if errors.Is(err, ErrRemoteGone) {
+		return "", fmt.Errorf("airtable table %q not found in base %s", a.Table, a.BaseID)
+	}
+	if err != nil {
+		return "", err
+	}
+	return record.ID, nil
+}
+
+// Update implements Exporter
+func (a *Airtable) Update(ctx context.Context, remoteID string, fields map[string]any) error {
+	return doJSON(ctx, http.MethodPatch, a.tableURL()+"/"+url.PathEscape(remoteID), a.Token, nil, map[string]any{
+		"fields":   fields,
+		"typecast": true,
+	}, nil)
+}
+
+// Delete implements Exporter
+func (a *Airtable) Delete(ctx context.Context, remoteID string) error {
+	return doJSON(ctx, http.MethodDelete, a.tableURL()+"/"+url.PathEscape(remoteID), a.Token, nil, nil, nil)
+}
@@ -0,0 +1,187 @@
+// Package export keeps a copy of the apartment table in an outside service,
+// a Notion database or an Airtable base, for people who plan in those
+// tools. Each exporter creates, updates, and removes one row per apartment
+// through the service's API; a Syncer works out which rows need which
+// change. Only apartments whose visibility is shared leave the app.
+package export
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/mojotx/apt-eval/models"
+)
+
+// ErrRemoteGone is returned when the row to change no longer exists in the
+// outside service, for example because someone deleted it there
+var ErrRemoteGone = errors.New("remote record no longer exists")
+
+// Exporter writes apartment rows to an outside service. Fields are keyed
+// by column name; see Columns.
+type Exporter interface {
+	Name() string
+	Create(ctx context.Context, fields map[string]any) (remoteID string, err error)
+	Update(ctx context.Context, remoteID string, fields map[string]any) error
+	Delete(ctx context.Context, remoteID string) error
+}
+
+// Column types, which decide how values are sent to services that need to
+// know, like Notion
+const (
+	Title  = "title"
+	Text   = "text"
+	Number = "number"
+	Select = "select"
+	Date   = "date"
+	URL    = "url"
+)
+
+// Column is one exported field of an apartment. Value returns nil for an
+// unset field.
+type Column struct {
+	Name  string
+	Type  string
+	Value func(a *models.Apartment) any
+}
+
+// Columns are the exported fields, named as the columns or properties the
+// table in the outside service must have
+var Columns = []Column{
+	{"Address", Title, func(a *models.Apartment) any { return a.Address }},
+	{"Unit", Text, func(a *models.Apartment) any { return a.Unit }},
+	{"Status", Select, func(a *models.Apartment) any { return a.Status }},
+	{"Neighborhood", Text, func(a *models.Apartment) any { return a.Neighborhood }},
+	{"Rent", Number, func(a *models.Apartment) any { return deref(a.Price) }},
+	{"Monthly Cost", Number, func(a *models.Apartment) any { return deref(a.TrueMonthlyCost) }},
+	{"Rating", Number, func(a *models.Apartment) any {
+		if a.Rating == nil {
+			return nil
+		}
+		return float64(*a.Rating)
+	}},
+	{"Score", Number, func(a *models.Apartment) any { return deref(a.Score) }},
+	{"Visit Date", Date, func(a *models.Apartment) any {
+		if a.VisitDate.IsZero() {
+			return nil
+		}
+		return a.VisitDate.Format(time.DateOnly)
+	}},
+	{"Listing", URL, func(a *models.Apartment) any {
+		if a.ListingURL == "" {
+			return nil
+		}
+		return a.ListingURL
+	}},
+	{"Notes", Text, func(a *models.Apartment) any { return a.Notes }},
+	{"App ID", Number, func(a *models.Apartment) any { return float64(a.ID) }},
+}
+
+// deref returns the value of an optional number, or nil
+func deref(v *float64) any {
+	if v == nil {
+		return nil
+	}
+	return *v
+}
+
+// Fields returns the exported fields of an apartment
+func Fields(a *models.Apartment) map[string]any {
+	fields := make(map[string]any, len(Columns))
+	for _, c := range Columns {
+		fields[c.Name] = c.Value(a)
+	}
+	return fields
+}
+
+// Config holds the credentials of each service; a service without a token
+// isn't exported to
+type Config struct {
+	NotionToken      string
+	NotionDatabaseID string
+	AirtableToken    string
+	AirtableBaseID   string
+	AirtableTable    string // Table name or ID, defaulting to "Apartments"
+}
+
+// New returns an exporter for each service with a token
+func New(config Config) ([]Exporter, error) {
+	var exporters []Exporter
+	if config.NotionToken != "" {
+		if config.NotionDatabaseID == "" {
+			return nil, fmt.Errorf("the notion exporter requires a database ID")
+		}
+		exporters = append(exporters, &Notion{
+			Token:      config.NotionToken,
+			DatabaseID: config.NotionDatabaseID,
+			BaseURL:    "https://api.notion.com",
+		})
+	}
+	if config.AirtableToken != "" {
+		if config.AirtableBaseID == "" {
+			return nil, fmt.Errorf("the airtable exporter requires a base ID")
+		}
+		table := config.AirtableTable
+		if table == "" {
+			table = "Apartments"
+		}
+		exporters = append(exporters, &Airtable{
+			Token:   config.AirtableToken,
+			BaseID:  config.AirtableBaseID,
+			Table:   table,
+			BaseURL: "https://api.airtable.com",
+		})
+	}
+	return exporters, nil
+}
+
+// httpClient is used for calls to the services
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// doJSON sends body, if any, to url with a bearer token and decodes the
+// JSON response into v, if any. A 404 is reported as ErrRemoteGone.
+func doJSON(ctx context.Context, method, url, token string, header http.Header, body, v any) error {
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, r)
+	if err != nil {
+		return err
+	}
+	for k, vs := range header {
+		req.Header[k] = vs
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrRemoteGone
+	}
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s returned %s: %s", req.URL.Host, resp.Status, bytes.TrimSpace(msg))
+	}
+	if v == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,208 @@
+package export
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mojotx/apt-eval/db"
+	"github.com/mojotx/apt-eval/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	exporters, err := New(Config{})
+	require.NoError(t, err)
+	assert.Empty(t, exporters)
+
+	_, err = New(Config{NotionToken: "secret"})
+	assert.Error(t, err)
+	_, err = New(Config{AirtableToken: "secret"})
+	assert.Error(t, err)
+
+	exporters, err = New(Config{NotionToken: "a", NotionDatabaseID: "db", AirtableToken: "b", AirtableBaseID: "app1"})
+	require.NoError(t, err)
+	require.Len(t, exporters, 2)
+	assert.Equal(t, "notion", exporters[0].Name())
+	assert.Equal(t, "airtable", exporters[1].Name())
+	assert.Equal(t, "Apartments", exporters[1].(*Airtable).Table)
+}
+
+func TestNotionProperties(t *testing.T) {
+	rent := 1500.0
+	props := notionProperties(Fields(&models.Apartment{
+		ID:      7,
+		Address: "12 Elm St",
+		Status:  models.StatusVisited,
+		Price:   &rent,
+		Notes:   strings.Repeat("a", notionTextLimit+1),
+	}))
+
+	data, err := json.Marshal(props)
+	require.NoError(t, err)
+	var got map[string]any
+	require.NoError(t, json.Unmarshal(data, &got))
+
+	assert.Equal(t, map[string]any{"title": []any{map[string]any{"text": map[string]any{"content": "12 Elm St"}}}}, got["Address"])
+	assert.Equal(t, map[string]any{"select": map[string]any{"name": "visited"}}, got["Status"])
+	assert.Equal(t, map[string]any{"number": 1500.0}, got["Rent"])
+	assert.Equal(t, map[string]any{"number": nil}, got["Monthly Cost"])
+	assert.Equal(t, map[string]any{"number": 7.0}, got["App ID"])
+	assert.Equal(t, map[string]any{"date": nil}, got["Visit Date"])
+	assert.Equal(t, map[string]any{"url": nil}, got["Listing"])
+	assert.Equal(t, map[string]any{"rich_text": []any{}}, got["Neighborhood"])
+	assert.Len(t, got["Notes"].(map[string]any)["rich_text"], 2)
+}
+
+func TestNotion(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, r.Method+" "+r.URL.Path+" "+r.Header.Get("Notion-Version")+" "+string(body))
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		if r.URL.Path == "/v1/pages/gone" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"id": "page-1"}`))
+	}))
+	defer server.Close()
+
+	n := &Notion{Token: "secret", DatabaseID: "db1", BaseURL: server.URL}
+	id, err := n.Create(context.Background(), map[string]any{"Address": "12 Elm St"})
+	require.NoError(t, err)
+	assert.Equal(t, "page-1", id)
+	require.NoError(t, n.Delete(context.Background(), "page-1"))
+	assert.ErrorIs(t, n.Update(context.Background(), "gone", map[string]any{}), ErrRemoteGone)
+
+	require.Len(t, requests, 3)
+	assert.True(t, strings.HasPrefix(requests[0], "POST /v1/pages "+notionVersion+` {"parent":{"database_id":"db1"}`), requests[0])
+	assert.Equal(t, "PATCH /v1/pages/page-1 "+notionVersion+` {"archived":true}`, requests[1])
+}
+
+func TestAirtable(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, r.Method+" "+r.URL.EscapedPath()+" "+string(body))
+		if r.Method == http.MethodPost {
+			w.Write([]byte(`{"id": "rec1", "fields": {}}`))
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	a := &Airtable{Token: "secret", BaseID: "app1", Table: "Our Places", BaseURL: server.URL}
+	id, err := a.Create(context.Background(), map[string]any{"Rent": 1500})
+	require.NoError(t, err)
+	assert.Equal(t, "rec1", id)
+	require.NoError(t, a.Update(context.Background(), "rec1", map[string]any{"Rent": 1400}))
+	require.NoError(t, a.Delete(context.Background(), "rec1"))
+
+	assert.Equal(t, []string{
+		`POST /v0/app1/Our%20Places {"fields":{"Rent":1500},"typecast":true}`,
+		`PATCH /v0/app1/Our%20Places/rec1 {"fields":{"Rent":1400},"typecast":true}`,
+		`DELETE /v0/app1/Our%20Places/rec1 `,
+	}, requests)
+}
+
+// fakeExporter keeps rows in memory
+type fakeExporter struct {
+	rows   map[string]map[string]any
+	calls  []string
+	nextID int
+	fail   bool
+}
+
+func (f *fakeExporter) Name() string { return "fake" }
+
+func (f *fakeExporter) Create(_ context.Context, fields map[string]any) (string, error) {
+	if f.fail {
+		return "", errors.New("down")
+	}
+	f.nextID++
+	id := fmt.Sprint(f.nextID)
+	f.rows[id] = fields
+	f.calls = append(f.calls, "create "+id)
+	return id, nil
+}
+
+func (f *fakeExporter) Update(_ context.Context, id string, fields map[string]any) error {
+	f.calls = append(f.calls, "update "+id)
+	if _, ok := f.rows[id]; !ok {
+		return ErrRemoteGone
+	}
+	f.rows[id] = fields
+	return nil
+}
+
+func (f *fakeExporter) Delete(_ context.Context, id string) error {
+	f.calls = append(f.calls, "delete "+id)
+	delete(f.rows, id)
+	return nil
+}
+
+func TestSync(t *testing.T) {
+	database, err := db.New(t.TempDir())
+	require.NoError(t, err)
+	defer database.Close()
+
+	owner := int64(1)
+	create := func(req models.ApartmentRequest) *models.Apartment {
+		a, err := database.CreateApartment(&req)
+		require.NoError(t, err)
+		return a
+	}
+	rent, lower := 1500.0, 1450.0
+	elm := create(models.ApartmentRequest{Address: "12 Elm St", Price: &rent, Visibility: models.VisibilityShared})
+	oak := create(models.ApartmentRequest{Address: "3 Oak Ave", Visibility: models.VisibilityShared})
+	create(models.ApartmentRequest{Address: "9 Pine Rd"}) // Household, by default
+	create(models.ApartmentRequest{Address: "1 Secret Ln", Visibility: models.VisibilityPrivate, OwnerID: &owner})
+
+	fake := &fakeExporter{rows: map[string]map[string]any{}}
+	s := NewSyncer(database, fake)
+	s.Delay = 0
+	sync := func() *Result {
+		fake.calls = nil
+		result, err := s.Sync(context.Background())
+		require.NoError(t, err)
+		return result
+	}
+
+	assert.Equal(t, &Result{Created: 2}, sync())
+	assert.Len(t, fake.rows, 2)
+	assert.Equal(t, &Result{Unchanged: 2}, sync())
+	assert.Empty(t, fake.calls)
+
+	_, err = database.UpdateApartment(elm.ID, &models.ApartmentRequest{Address: "12 Elm St", Price: &lower})
+	require.NoError(t, err)
+	delete(fake.rows, "2") // Deleted by hand in the service
+	_, err = database.UpdateApartment(oak.ID, &models.ApartmentRequest{Address: "3 Oak Ave", Notes: "Quiet"})
+	require.NoError(t, err)
+	assert.Equal(t, &Result{Created: 1, Updated: 1}, sync())
+	assert.Equal(t, []string{"update 1", "update 2", "create 3"}, fake.calls)
+	assert.Equal(t, 1450.0, fake.rows["1"]["Rent"])
+
+	_, err = database.DeleteApartment(0, elm.ID, false)
+	require.NoError(t, err)
+	_, err = database.UpdateApartment(oak.ID, &models.ApartmentRequest{Address: "3 Oak Ave", Notes: "Quiet", Visibility: models.VisibilityHousehold})
+	require.NoError(t, err)
+	result := sync()
+	assert.Equal(t, &Result{Deleted: 2}, result)
+	assert.Empty(t, fake.rows)
+
+	create(models.ApartmentRequest{Address: "5 Birch Ct", Visibility: models.VisibilityShared})
+	fake.fail = true
+	result, err = s.Sync(context.Background())
+	assert.Error(t, err)
+	assert.Equal(t, &Result{Failed: 1}, result)
+	assert.Equal(t, err.Error(), s.Status().LastError)
+}
@@ -0,0 +1,114 @@
+package export
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// notionVersion is the Notion API version the requests are written for
+const notionVersion = "2022-06-28"
+
+// notionTextLimit is the most characters one Notion text object holds
+const notionTextLimit = 2000
+
+// Notion keeps one page per apartment in a Notion database shared with the
+// integration owning the token. The database needs a property of the
+// matching type for each column: the Address title, Status select, Rent
+// number, and so on.
+type Notion struct {
+	Token      string
+	DatabaseID string
+	BaseURL    string
+}
+
+// Name implements Exporter
+func (n *Notion) Name() string {
+	return "notion"
+}
+
+// notionPage is the part of a page response the exporter reads
+type notionPage struct {
+	ID string `json:"id"`
+}
+
+func (n *Notion) do(ctx context.Context, method, path string, body, v any) error {
+	header := http.Header{"Notion-Version": {notionVersion}}
+	return doJSON(ctx, method, n.BaseURL+path, n.Token, header, body, v)
+}
+
+// Create implements Exporter
+func (n *Notion) Create(ctx context.Context, fields map[string]any) (string, error) {
+	var page notionPage
+	err := n.do(ctx, http.MethodPost, "/v1/pages", map[string]any{
+		"parent":     map[string]any{"database_id": n.DatabaseID},
+		"properties": notionProperties(fields),
+	}, &page)
+	if errors.Is(err, ErrRemoteGone) {
+		return "", fmt.Errorf("notion database %s not found or not shared with the integration", n.DatabaseID)
+	}
+	if err != nil {
+		return "", err
+	}
+	return page.ID, nil
+}
+
+// Update implements Exporter
+func (n *Notion) Update(ctx context.Context, remoteID string, fields map[string]any) error {
+	return n.do(ctx, http.MethodPatch, "/v1/pages/"+url.PathEscape(remoteID), map[string]any{
+		"properties": notionProperties(fields),
+	}, nil)
+}
+
+// Delete implements Exporter by archiving the page, Notion's delete
+func (n *Notion) Delete(ctx context.Context, remoteID string) error {
+	return n.do(ctx, http.MethodPatch, "/v1/pages/"+url.PathEscape(remoteID), map[string]any{
+		"archived": true,
+	}, nil)
+}
+
+// notionProperties encodes fields as Notion page property values
+func notionProperties(fields map[string]any) map[string]any {
+	props := make(map[string]any, len(Columns))
+	for _, c := range Columns {
+		v := fields[c.Name]
+		switch c.Type {
+		case Title:
+			props[c.Name] = map[string]any{"title": notionText(v)}
+		case Text:
+			props[c.Name] = map[string]any{"rich_text": notionText(v)}
+		case Number:
+			props[c.Name] = map[string]any{"number": v}
+		case Select:
+			if s, _ := v.(string); s != "" {
+				props[c.Name] = map[string]any{"select": map[string]any{"name": s}}
+			} else {
+				props[c.Name] = map[string]any{"select": nil}
+			}
+		case Date:
+			if v != nil {
+				props[c.Name] = map[string]any{"date": map[string]any{"start": v}}
+			} else {
+				props[c.Name] = map[string]any{"date": nil}
+			}
+		case URL:
+			props[c.Name] = map[string]any{"url": v}
+		}
+	}
+	return props
+}
+
+// notionText splits text into as many text objects as Notion's length
+// limit requires
+func notionText(v any) []any {
+	s, _ := v.(string)
+	parts := []any{}
+	for r := []rune(s); len(r) > 0; {
+		n := min(len(r), notionTextLimit)
+		parts = append(parts, map[string]any{"text": map[string]any{"content": string(r[:n])}})
+		r = r[n:]
+	}
+	return parts
+}
@@ -0,0 +1,226 @@
+package export
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mojotx/apt-eval/db"
+	"github.com/mojotx/apt-eval/health"
+	"github.com/mojotx/apt-eval/models"
+	"github.com/rs/zerolog/log"
+)
+
+// ErrSyncRunning is returned when a sync is asked for while the last one
+// is still going
+var ErrSyncRunning = errors.New("a sync is already running")
+
+// Result counts the rows a sync changed
+type Result struct {
+	Created   int `json:"created"`
+	Updated   int `json:"updated"`
+	Deleted   int `json:"deleted"`
+	Unchanged int `json:"unchanged"`
+	Failed    int `json:"failed"`
+}
+
+// Status describes an exporter and how its last sync went
+type Status struct {
+	Name       string     `json:"name"`
+	Running    bool       `json:"running"`
+	LastRun    *time.Time `json:"last_run"`
+	LastResult *Result    `json:"last_result"`
+	LastError  string     `json:"last_error,omitempty"`
+}
+
+// Syncer brings an exporter's rows in line with the apartments: creating
+// rows for new apartments, updating those whose fields changed since they
+// were last sent, and removing those of apartments that were deleted or
+// are no longer shared
+type Syncer struct {
+	db       *db.DB
+	exporter Exporter
+
+	// Delay is the pause between requests, keeping under the services'
+	// rate limits
+	Delay time.Duration
+
+	running sync.Mutex
+	mu      sync.Mutex // Guards status
+	status  Status
+}
+
+// NewSyncer creates a syncer for an exporter
+func NewSyncer(database *db.DB, exporter Exporter) *Syncer {
+	return &Syncer{
+		db:       database,
+		exporter: exporter,
+		Delay:    350 * time.Millisecond,
+		status:   Status{Name: exporter.Name()},
+	}
+}
+
+// Name is the exporter's name
+func (s *Syncer) Name() string {
+	return s.exporter.Name()
+}
+
+// Status returns how the last sync went
+func (s *Syncer) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
+// Run syncs for the scheduler, which only needs to know if it failed
+func (s *Syncer) Run(ctx context.Context) error {
+	_, err := s.Sync(ctx)
+	return err
+}
+
+// Sync brings the exporter's rows in line with the apartments. Rows that
+// fail are counted and retried on the next sync; the error names the
+// first failure.
+func (s *Syncer) Sync(ctx context.Context) (*Result, error) {
+	if !s.running.TryLock() {
+		return nil, ErrSyncRunning
+	}
+	defer s.running.Unlock()
+
+	s.mu.Lock()
+	s.status.Running = true
+	s.mu.Unlock()
+
+	result, err := s.sync(ctx)
+	health.Record("export_"+s.exporter.Name(), err)
+
+	now := time.Now().UTC()
+	s.mu.Lock()
+	s.status.Running = false
+	s.status.LastRun = &now
+	s.status.LastResult = result
+	s.status.LastError = ""
+	if err != nil {
+		s.status.LastError = err.Error()
+	}
+	s.mu.Unlock()
+	return result, err
+}
+
+func (s *Syncer) sync(ctx context.Context) (*Result, error) {
+	name := s.exporter.Name()
+	apartments, err := s.db.ListApartments(db.ListOptions{Sort: []db.SortField{{Column: "id"}}})
+	if err != nil {
+		return nil, err
+	}
+	records, err := s.db.ListExportRecords(name)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{}
+	var firstErr error
+	fail := func(apartmentID int64, err error) {
+		log.Warn().Err(err).Str("exporter", name).Int64("apartment_id", apartmentID).Msg("Failed to export apartment")
+		result.Failed++
+		if firstErr == nil {
+			firstErr = fmt.Errorf("apartment %d: %w", apartmentID, err)
+		}
+	}
+	requests := 0
+	wait := func() error {
+		if requests++; requests > 1 && s.Delay > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(s.Delay):
+			}
+		}
+		return ctx.Err()
+	}
+
+	exported := map[int64]bool{}
+	for i := range apartments {
+		a := &apartments[i]
+		if a.Visibility != models.VisibilityShared {
+			continue
+		}
+		exported[a.ID] = true
+
+		fields := Fields(a)
+		hash, err := hashFields(fields)
+		if err != nil {
+			return result, err
+		}
+		record, ok := records[a.ID]
+		if ok && record.Hash == hash {
+			result.Unchanged++
+			continue
+		}
+
+		if err := wait(); err != nil {
+			return result, err
+		}
+		remoteID := record.RemoteID
+		if ok {
+			err = s.exporter.Update(ctx, remoteID, fields)
+			if errors.Is(err, ErrRemoteGone) {
+				// Deleted in the service; put it back
+				ok = false
+			}
+		}
+		if !ok {
+			remoteID, err = s.exporter.Create(ctx, fields)
+		}
+		if err != nil {
+			fail(a.ID, err)
+			continue
+		}
+		if err := s.db.SaveExportRecord(name, a.ID, remoteID, hash); err != nil {
+			return result, err
+		}
+		if ok {
+			result.Updated++
+		} else {
+			result.Created++
+		}
+	}
+
+	for apartmentID, record := range records {
+		if exported[apartmentID] {
+			continue
+		}
+		if err := wait(); err != nil {
+			return result, err
+		}
+		if err := s.exporter.Delete(ctx, record.RemoteID); err != nil && !errors.Is(err, ErrRemoteGone) {
+			fail(apartmentID, err)
+			continue
+		}
+		if err := s.db.DeleteExportRecord(name, apartmentID); err != nil {
+			return result, err
+		}
+		result.Deleted++
+	}
+
+	if firstErr != nil {
+		return result, fmt.Errorf("failed to export %d apartments, first %w", result.Failed, firstErr)
+	}
+	return result, nil
+}
+
+// hashFields fingerprints the exported fields of an apartment, so rows
+// whose fields haven't changed aren't sent again
+func hashFields(fields map[string]any) (string, error) {
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:16]), nil
+}
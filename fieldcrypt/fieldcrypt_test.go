@@ -0,0 +1,64 @@
+package fieldcrypt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoundTrip(t *testing.T) {
+	c, err := New(bytes.Repeat([]byte{7}, KeySize))
+	require.NoError(t, err)
+
+	sealed := c.Encrypt("Landlord wants cash only")
+	assert.True(t, IsEncrypted(sealed))
+	assert.NotContains(t, sealed, "cash")
+	assert.NotEqual(t, sealed, c.Encrypt("Landlord wants cash only"), "each encryption uses a new nonce")
+	assert.Equal(t, sealed, c.Encrypt(sealed), "encrypted values aren't encrypted twice")
+
+	plain, err := c.Decrypt(sealed)
+	require.NoError(t, err)
+	assert.Equal(t, "Landlord wants cash only", plain)
+
+	assert.Equal(t, "", c.Encrypt(""))
+}
+
+func TestPlaintextAndNilCipher(t *testing.T) {
+	c, err := New(bytes.Repeat([]byte{7}, KeySize))
+	require.NoError(t, err)
+
+	plain, err := c.Decrypt("written before encryption")
+	require.NoError(t, err)
+	assert.Equal(t, "written before encryption", plain)
+
+	var none *Cipher
+	assert.Equal(t, "notes", none.Encrypt("notes"))
+	_, err = none.Decrypt(c.Encrypt("notes"))
+	assert.ErrorIs(t, err, ErrNoKey)
+}
+
+func TestWrongKeyOrTampering(t *testing.T) {
+	c, err := New(bytes.Repeat([]byte{7}, KeySize))
+	require.NoError(t, err)
+	other, err := New(bytes.Repeat([]byte{8}, KeySize))
+	require.NoError(t, err)
+
+	sealed := c.Encrypt("notes")
+	_, err = other.Decrypt(sealed)
+	assert.Error(t, err)
+
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(sealed, Prefix))
+	require.NoError(t, err)
+	raw[len(raw)-1] ^= 1
+	_, err = c.Decrypt(Prefix + base64.StdEncoding.EncodeToString(raw))
+	assert.Error(t, err)
+}
+
+func TestNewRejectsShortKey(t *testing.T) {
+	_, err := New([]byte("too short"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,264 @@
+// Package filter implements the compact query language accepted by the
+// apartment list endpoint, e.g. `price<2000 AND rating>=4 AND has_laundry`.
+//
+// Expressions are parsed into a small AST and then rendered as a
+// parameterized SQL WHERE clause. Only whitelisted fields may be referenced,
+// so user input never reaches the database as raw SQL.
+package filter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Type describes the kind of value a field holds
+type Type int
+
+const (
+	// Number is an integer or floating point column
+	Number Type = iota
+	// Text is a string column
+	Text
+	// Bool is a boolean column
+	Bool
+	// Date is a timestamp column
+	Date
+)
+
+// Field describes a column that may be referenced in a filter expression
+type Field struct {
+	Column string
+	Type   Type
+}
+
+// Fields maps query field names to their database columns
+type Fields map[string]Field
+
+// Node is a parsed filter expression
+type Node interface {
+	// SQL renders the node as a parameterized SQL expression
+	SQL() (string, []any)
+}
+
+// binary is a logical AND/OR of two expressions
+type binary struct {
+	op          string
+	left, right Node
+}
+
+func (b *binary) SQL() (string, []any) {
+	ls, la := b.left.SQL()
+	rs, ra := b.right.SQL()
+	return "(" + ls + " " + b.op + " " + rs + ")", append(la, ra...)
+}
+
+// not negates an expression
+type not struct {
+	expr Node
+}
+
+func (n *not) SQL() (string, []any) {
+	s, args := n.expr.SQL()
+	return "NOT " + s, args
+}
+
+// comparison compares a field against a literal value
+type comparison struct {
+	field Field
+	op    string
+	value any
+}
+
+// likeEscaper escapes the LIKE wildcards, with \ as the escape character,
+// so ~ matches the value literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func (c *comparison) SQL() (string, []any) {
+	switch c.op {
+	case "~":
+		return c.field.Column + ` LIKE ? ESCAPE '\'`, []any{"%" + likeEscaper.Replace(c.value.(string)) + "%"}
+	case "!~":
+		return c.field.Column + ` NOT LIKE ? ESCAPE '\'`, []any{"%" + likeEscaper.Replace(c.value.(string)) + "%"}
+	}
+	return c.field.Column + " " + c.op + " ?", []any{c.value}
+}
+
+// Parse parses a filter expression, resolving field names against fields.
+// An empty expression yields a nil Node.
+func Parse(input string, fields Fields) (Node, error) {
+	tokens, err := lex(input)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+
+	p := &parser{tokens: tokens, fields: fields}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if !p.done() {
+		return nil, fmt.Errorf("unexpected %q at position %d", p.peek().text, p.peek().pos)
+	}
+	return node, nil
+}
+
+// parser is a recursive-descent parser over the token stream.
+//
+//	or         = and { "OR" and }
+//	and        = unary { ["AND"] unary }
+//	unary      = "NOT" unary | "(" or ")" | comparison
+//	comparison = ident [ op value ]
+type parser struct {
+	tokens []token
+	pos    int
+	fields Fields
+}
+
+func (p *parser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *parser) peek() token {
+	if p.done() {
+		return token{kind: tokEOF, text: "end of input"}
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.peek()
+	if !p.done() {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) parseOr() (Node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().isKeyword("OR") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &binary{op: "OR", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if t.isKeyword("AND") {
+			p.next()
+		} else if t.kind != tokIdent && t.kind != tokLParen || t.isKeyword("OR") {
+			// Adjacent terms are implicitly ANDed; anything else ends the group
+			return left, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &binary{op: "AND", left: left, right: right}
+	}
+}
+
+func (p *parser) parseUnary() (Node, error) {
+	t := p.peek()
+	switch {
+	case t.isKeyword("NOT"):
+		p.next()
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &not{expr: expr}, nil
+	case t.kind == tokLParen:
+		p.next()
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next().kind != tokRParen {
+			return nil, fmt.Errorf("missing closing parenthesis for position %d", t.pos)
+		}
+		return expr, nil
+	case t.kind == tokIdent:
+		return p.parseComparison()
+	}
+	return nil, fmt.Errorf("unexpected %q at position %d", t.text, t.pos)
+}
+
+func (p *parser) parseComparison() (Node, error) {
+	ident := p.next()
+	name := strings.ToLower(ident.text)
+	field, ok := p.fields[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown field %q", ident.text)
+	}
+
+	if p.peek().kind != tokOp {
+		// A bare field name is shorthand for "field = true"
+		if field.Type != Bool {
+			return nil, fmt.Errorf("field %q requires a comparison", name)
+		}
+		return &comparison{field: field, op: "=", value: true}, nil
+	}
+
+	op := p.next()
+	val := p.next()
+	if val.kind != tokIdent && val.kind != tokString {
+		return nil, fmt.Errorf("expected value after %q at position %d", op.text, op.pos)
+	}
+
+	value, err := convert(field, op.text, val.text)
+	if err != nil {
+		return nil, fmt.Errorf("field %q: %w", name, err)
+	}
+	return &comparison{field: field, op: op.text, value: value}, nil
+}
+
+// convert checks that op is valid for the field and parses the literal
+func convert(field Field, op, raw string) (any, error) {
+	if (op == "~" || op == "!~") && field.Type != Text {
+		return nil, fmt.Errorf("operator %q only applies to text fields", op)
+	}
+
+	switch field.Type {
+	case Number:
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", raw)
+		}
+		return v, nil
+	case Bool:
+		if op != "=" && op != "!=" {
+			return nil, fmt.Errorf("operator %q does not apply to boolean fields", op)
+		}
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid boolean %q", raw)
+		}
+		return v, nil
+	case Date:
+		for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+			if t, err := time.Parse(layout, raw); err == nil {
+				return t, nil
+			}
+		}
+		return nil, fmt.Errorf("invalid date %q", raw)
+	}
+	return raw, nil
+}
@@ -0,0 +1,69 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testFields = Fields{
+	"price":       {Column: "price", Type: Number},
+	"rating":      {Column: "rating", Type: Number},
+	"address":     {Column: "address", Type: Text},
+	"has_laundry": {Column: "has_laundry", Type: Bool},
+	"visit_date":  {Column: "visit_date", Type: Date},
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input string
+		sql   string
+		args  []any
+	}{
+		{"price<2000", "price < ?", []any{2000.0}},
+		{"has_laundry", "has_laundry = ?", []any{true}},
+		{"price<2000 AND rating>=4 AND has_laundry", "((price < ? AND rating >= ?) AND has_laundry = ?)", []any{2000.0, 4.0, true}},
+		{"price<2000 rating>=4", "(price < ? AND rating >= ?)", []any{2000.0, 4.0}},
+		{"rating=5 OR price<=1000 AND has_laundry", "(rating = ? OR (price <= ? AND has_laundry = ?))", []any{5.0, 1000.0, true}},
+		{"(rating=5 OR rating=4) and not has_laundry", "((rating = ? OR rating = ?) AND NOT has_laundry = ?)", []any{5.0, 4.0, true}},
+		{`address~"Main St"`, `address LIKE ? ESCAPE '\'`, []any{"%Main St%"}},
+		{`address!~"100%_\"`, `address NOT LIKE ? ESCAPE '\'`, []any{`%100\%\_\\%`}},
+		{"has_laundry!=false", "has_laundry != ?", []any{false}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			node, err := Parse(tt.input, testFields)
+			assert.NoError(t, err)
+			sql, args := node.SQL()
+			assert.Equal(t, tt.sql, sql)
+			assert.Equal(t, tt.args, args)
+		})
+	}
+}
+
+func TestParseEmpty(t *testing.T) {
+	node, err := Parse("   ", testFields)
+	assert.NoError(t, err)
+	assert.Nil(t, node, "Empty input should produce no filter")
+}
+
+func TestParseErrors(t *testing.T) {
+	inputs := []string{
+		"bedrooms>2",           // unknown field
+		"price",                // non-boolean without comparison
+		"price<abc",            // invalid number
+		"rating>4 AND",         // dangling operator
+		"(rating>4",            // unbalanced parenthesis
+		"address<\"x",          // unterminated string
+		"price~100",            // LIKE on a number
+		"has_laundry>true",     // ordering on a boolean
+		"price<1; DROP TABLE",  // stray characters
+		"visit_date>yesterday", // invalid date
+	}
+
+	for _, input := range inputs {
+		_, err := Parse(input, testFields)
+		assert.Error(t, err, "Expected error for %q", input)
+	}
+}
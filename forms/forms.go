@@ -0,0 +1,140 @@
+// Package forms checks evaluation templates and the answers recorded
+// against them
+package forms
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mojotx/apt-eval/models"
+)
+
+// Error reports every problem found, keyed by field
+type Error struct {
+	Problems map[string]string
+}
+
+func (e *Error) Error() string {
+	keys := make([]string, 0, len(e.Problems))
+	for k := range e.Problems {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	msgs := make([]string, len(keys))
+	for i, k := range keys {
+		msgs[i] = k + ": " + e.Problems[k]
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// errorOrNil returns nil when there are no problems, so callers never see a
+// non-nil error interface holding an empty *Error
+func errorOrNil(problems map[string]string) error {
+	if len(problems) == 0 {
+		return nil
+	}
+	return &Error{Problems: problems}
+}
+
+// ValidateFields checks a template definition: keys must be unique, choice
+// fields need options, and bounds only apply to numbers
+func ValidateFields(fields []models.TemplateField) error {
+	problems := map[string]string{}
+	seen := map[string]bool{}
+	for _, f := range fields {
+		switch {
+		case seen[f.Key]:
+			problems[f.Key] = "duplicate key"
+		case f.Type == models.FieldChoice && len(f.Options) == 0:
+			problems[f.Key] = "choice fields need options"
+		case f.Type != models.FieldChoice && len(f.Options) > 0:
+			problems[f.Key] = "only choice fields take options"
+		case f.Type != models.FieldNumber && (f.Min != nil || f.Max != nil):
+			problems[f.Key] = "only number fields take min and max"
+		case f.Min != nil && f.Max != nil && *f.Min > *f.Max:
+			problems[f.Key] = "min is greater than max"
+		}
+		seen[f.Key] = true
+	}
+	return errorOrNil(problems)
+}
+
+// Validate checks answers against a template's fields. Answers hold decoded
+// JSON values, so numbers arrive as float64.
+func Validate(fields []models.TemplateField, answers map[string]any) error {
+	problems := map[string]string{}
+	known := map[string]bool{}
+	for _, f := range fields {
+		known[f.Key] = true
+		v, ok := answers[f.Key]
+		if !ok || v == nil {
+			if f.Required {
+				problems[f.Key] = "required"
+			}
+			continue
+		}
+		if msg := checkValue(f, v); msg != "" {
+			problems[f.Key] = msg
+		}
+	}
+	for k := range answers {
+		if !known[k] {
+			problems[k] = "not in template"
+		}
+	}
+	return errorOrNil(problems)
+}
+
+// checkValue describes what's wrong with v for field f, or returns ""
+func checkValue(f models.TemplateField, v any) string {
+	switch f.Type {
+	case models.FieldText:
+		if _, ok := v.(string); !ok {
+			return "must be text"
+		}
+	case models.FieldBoolean:
+		if _, ok := v.(bool); !ok {
+			return "must be true or false"
+		}
+	case models.FieldNumber:
+		n, ok := v.(float64)
+		if !ok {
+			return "must be a number"
+		}
+		if f.Min != nil && n < *f.Min {
+			return fmt.Sprintf("must be at least %g", *f.Min)
+		}
+		if f.Max != nil && n > *f.Max {
+			return fmt.Sprintf("must be at most %g", *f.Max)
+		}
+	case models.FieldRating:
+		n, ok := v.(float64)
+		if !ok || n != math.Trunc(n) || n < 1 || n > 5 {
+			return "must be a whole number from 1 to 5"
+		}
+	case models.FieldChoice:
+		s, ok := v.(string)
+		if !ok {
+			return "must be one of " + strings.Join(f.Options, ", ")
+		}
+		for _, o := range f.Options {
+			if s == o {
+				return ""
+			}
+		}
+		return "must be one of " + strings.Join(f.Options, ", ")
+	case models.FieldDate:
+		s, ok := v.(string)
+		if !ok {
+			return "must be a date (YYYY-MM-DD)"
+		}
+		if _, err := time.Parse(time.DateOnly, s); err != nil {
+			return "must be a date (YYYY-MM-DD)"
+		}
+	}
+	return ""
+}
@@ -0,0 +1,61 @@
+package forms
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mojotx/apt-eval/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func floatp(f float64) *float64 { return &f }
+
+var fields = []models.TemplateField{
+	{Key: "pets", Label: "Pets allowed", Type: models.FieldBoolean, Required: true},
+	{Key: "deposit", Label: "Deposit", Type: models.FieldNumber, Min: floatp(0)},
+	{Key: "light", Label: "Natural light", Type: models.FieldRating},
+	{Key: "heat", Label: "Heating", Type: models.FieldChoice, Options: []string{"gas", "electric"}},
+	{Key: "available", Label: "Available from", Type: models.FieldDate},
+	{Key: "landlord", Label: "Landlord", Type: models.FieldText},
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Validate(fields, map[string]any{
+		"pets": true, "deposit": 1500.0, "light": 4.0, "heat": "gas",
+		"available": "2025-10-01", "landlord": "Acme",
+	}))
+	assert.NoError(t, Validate(fields, map[string]any{"pets": false}), "optional fields may be omitted")
+
+	err := Validate(fields, map[string]any{
+		"deposit": -1.0, "light": 4.5, "heat": "oil", "available": "soon", "landlord": 3.0, "view": "park",
+	})
+	var formErr *Error
+	if assert.True(t, errors.As(err, &formErr)) {
+		assert.Equal(t, map[string]string{
+			"pets":      "required",
+			"deposit":   "must be at least 0",
+			"light":     "must be a whole number from 1 to 5",
+			"heat":      "must be one of gas, electric",
+			"available": "must be a date (YYYY-MM-DD)",
+			"landlord":  "must be text",
+			"view":      "not in template",
+		}, formErr.Problems)
+	}
+}
+
+func TestValidateFields(t *testing.T) {
+	assert.NoError(t, ValidateFields(fields))
+
+	err := ValidateFields([]models.TemplateField{
+		{Key: "a", Type: models.FieldText},
+		{Key: "a", Type: models.FieldText},
+		{Key: "b", Type: models.FieldChoice},
+		{Key: "c", Type: models.FieldRating, Max: floatp(3)},
+		{Key: "d", Type: models.FieldNumber, Min: floatp(5), Max: floatp(1)},
+	})
+	var formErr *Error
+	if assert.True(t, errors.As(err, &formErr)) {
+		assert.Len(t, formErr.Problems, 4)
+		assert.Equal(t, "duplicate key", formErr.Problems["a"])
+	}
+}
@@ -0,0 +1,283 @@
+// Package gcal keeps visits in step with a Google Calendar: visits created
+// in the app become events on the calendar, and moving an event there moves
+// the visit back here. Access is granted once through Google's OAuth
+// consent screen; the refresh token it yields is kept in the database.
+package gcal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Scope lets the app read and write events on the user's calendars, and
+// nothing else
+const Scope = "https://www.googleapis.com/auth/calendar.events"
+
+var (
+	// ErrEventGone is returned when the event to change no longer exists on
+	// the calendar
+	ErrEventGone = errors.New("calendar event no longer exists")
+
+	// ErrSyncTokenExpired is returned when the calendar no longer knows
+	// where the last pull of changes left off, and everything must be read
+	// again
+	ErrSyncTokenExpired = errors.New("calendar sync token expired")
+)
+
+// httpClient is used for calls to Google
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// OAuth is the app's Google OAuth client
+type OAuth struct {
+	ClientID     string
+	ClientSecret string
+	AuthURL      string
+	TokenURL     string
+}
+
+// NewOAuth creates an OAuth client for Google's endpoints
+func NewOAuth(clientID, clientSecret string) *OAuth {
+	return &OAuth{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		AuthURL:      "https://accounts.google.com/o/oauth2/v2/auth",
+		TokenURL:     "https://oauth2.googleapis.com/token",
+	}
+}
+
+// Token is what Google grants in exchange for an authorization code or a
+// refresh token. RefreshToken is only set on the first exchange.
+type Token struct {
+	AccessToken  string
+	RefreshToken string
+	Expiry       time.Time
+}
+
+// AuthCodeURL returns the consent screen URL, which sends the user back to
+// redirectURL with a code and state. Offline access with forced consent
+// makes Google hand out a refresh token every time.
+func (o *OAuth) AuthCodeURL(state, redirectURL string) string {
+	v := url.Values{
+		"client_id":     {o.ClientID},
+		"redirect_uri":  {redirectURL},
+		"response_type": {"code"},
+		"scope":         {Scope},
+		"access_type":   {"offline"},
+		"prompt":        {"consent"},
+		"state":         {state},
+	}
+	return o.AuthURL + "?" + v.Encode()
+}
+
+// Exchange trades the code from the consent screen for a token.
+// redirectURL must be the one the consent screen was opened with.
+func (o *OAuth) Exchange(ctx context.Context, code, redirectURL string) (*Token, error) {
+	return o.token(ctx, url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {code},
+		"redirect_uri": {redirectURL},
+	})
+}
+
+// Refresh trades a refresh token for a new access token
+func (o *OAuth) Refresh(ctx context.Context, refreshToken string) (*Token, error) {
+	token, err := o.token(ctx, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if token.RefreshToken == "" {
+		token.RefreshToken = refreshToken
+	}
+	return token, nil
+}
+
+func (o *OAuth) token(ctx context.Context, form url.Values) (*Token, error) {
+	form.Set("client_id", o.ClientID)
+	form.Set("client_secret", o.ClientSecret)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to request token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		AccessToken      string `json:"access_token"`
+		RefreshToken     string `json:"refresh_token"`
+		ExpiresIn        int    `json:"expires_in"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err != nil {
+		return nil, fmt.Errorf("token endpoint returned %s", resp.Status)
+	}
+	if resp.StatusCode != http.StatusOK || body.AccessToken == "" {
+		return nil, fmt.Errorf("token endpoint returned %s: %s %s", resp.Status, body.Error, body.ErrorDescription)
+	}
+	return &Token{
+		AccessToken:  body.AccessToken,
+		RefreshToken: body.RefreshToken,
+		Expiry:       time.Now().Add(time.Duration(body.ExpiresIn) * time.Second).UTC(),
+	}, nil
+}
+
+// Event is a Google Calendar event, with only the fields the app uses
+type Event struct {
+	ID                 string              `json:"id,omitempty"`
+	Status             string              `json:"status,omitempty"` // "cancelled" once deleted
+	Summary            string              `json:"summary,omitempty"`
+	Location           string              `json:"location"`
+	Description        string              `json:"description"`
+	Start              *EventTime          `json:"start,omitempty"`
+	End                *EventTime          `json:"end,omitempty"`
+	ExtendedProperties *ExtendedProperties `json:"extendedProperties,omitempty"`
+}
+
+// EventTime is when an event starts or ends: a DateTime for timed events,
+// a Date for all-day ones
+type EventTime struct {
+	DateTime string `json:"dateTime,omitempty"`
+	Date     string `json:"date,omitempty"`
+}
+
+// ExtendedProperties are key-value pairs kept on an event
+type ExtendedProperties struct {
+	Private map[string]string `json:"private,omitempty"`
+}
+
+// Client calls the Calendar API for one calendar
+type Client struct {
+	BaseURL     string
+	CalendarID  string
+	AccessToken string
+}
+
+// NewClient creates a client for a calendar, "primary" for the user's own
+func NewClient(calendarID, accessToken string) *Client {
+	return &Client{
+		BaseURL:     "https://www.googleapis.com/calendar/v3",
+		CalendarID:  calendarID,
+		AccessToken: accessToken,
+	}
+}
+
+func (c *Client) eventsURL(eventID string) string {
+	u := c.BaseURL + "/calendars/" + url.PathEscape(c.CalendarID) + "/events"
+	if eventID != "" {
+		u += "/" + url.PathEscape(eventID)
+	}
+	return u
+}
+
+// Insert adds an event, returning it with its ID
+func (c *Client) Insert(ctx context.Context, event *Event) (*Event, error) {
+	var created Event
+	if err := c.do(ctx, http.MethodPost, c.eventsURL(""), event, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// Patch changes the fields set in event on an existing event
+func (c *Client) Patch(ctx context.Context, eventID string, event *Event) error {
+	return c.do(ctx, http.MethodPatch, c.eventsURL(eventID), event, nil)
+}
+
+// Delete removes an event. An event that's already gone isn't an error.
+func (c *Client) Delete(ctx context.Context, eventID string) error {
+	err := c.do(ctx, http.MethodDelete, c.eventsURL(eventID), nil, nil)
+	if errors.Is(err, ErrEventGone) {
+		return nil
+	}
+	return err
+}
+
+// Changes returns the events changed since syncToken, including deleted
+// ones, and the token to pass next time. With no token it returns every
+// event on the calendar.
+func (c *Client) Changes(ctx context.Context, syncToken string) ([]Event, string, error) {
+	var events []Event
+	pageToken := ""
+	for {
+		v := url.Values{"maxResults": {"2500"}}
+		if syncToken != "" {
+			v.Set("syncToken", syncToken)
+		}
+		if pageToken != "" {
+			v.Set("pageToken", pageToken)
+		}
+		var page struct {
+			Items         []Event `json:"items"`
+			NextPageToken string  `json:"nextPageToken"`
+			NextSyncToken string  `json:"nextSyncToken"`
+		}
+		err := c.do(ctx, http.MethodGet, c.eventsURL("")+"?"+v.Encode(), nil, &page)
+		if errors.Is(err, ErrEventGone) && syncToken != "" {
+			return nil, "", ErrSyncTokenExpired
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		events = append(events, page.Items...)
+		if page.NextPageToken == "" {
+			return events, page.NextSyncToken, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+// do sends body, if any, and decodes the JSON response into v, if any. A
+// 404 or 410 is reported as ErrEventGone.
+func (c *Client) do(ctx context.Context, method, url string, body, v any) error {
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, r)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.AccessToken)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return ErrEventGone
+	case resp.StatusCode >= 300:
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("calendar returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	case v == nil:
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
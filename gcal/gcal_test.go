@@ -0,0 +1,202 @@
+package gcal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mojotx/apt-eval/db"
+	"github.com/mojotx/apt-eval/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthCodeURL(t *testing.T) {
+	o := NewOAuth("client", "secret")
+	u, err := url.Parse(o.AuthCodeURL("state1", "http://localhost:8080/api/calendar/callback"))
+	require.NoError(t, err)
+	q := u.Query()
+	assert.Equal(t, "accounts.google.com", u.Host)
+	assert.Equal(t, "client", q.Get("client_id"))
+	assert.Equal(t, "state1", q.Get("state"))
+	assert.Equal(t, "offline", q.Get("access_type"))
+	assert.Equal(t, Scope, q.Get("scope"))
+	assert.Equal(t, "http://localhost:8080/api/calendar/callback", q.Get("redirect_uri"))
+}
+
+func TestOAuthToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "secret", r.PostForm.Get("client_secret"))
+		switch r.PostForm.Get("grant_type") {
+		case "authorization_code":
+			assert.Equal(t, "code1", r.PostForm.Get("code"))
+			w.Write([]byte(`{"access_token": "a1", "refresh_token": "r1", "expires_in": 3600}`))
+		case "refresh_token":
+			if r.PostForm.Get("refresh_token") != "r1" {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"error": "invalid_grant", "error_description": "Token has been revoked."}`))
+				return
+			}
+			w.Write([]byte(`{"access_token": "a2", "expires_in": 3600}`))
+		}
+	}))
+	defer server.Close()
+
+	o := NewOAuth("client", "secret")
+	o.TokenURL = server.URL
+	token, err := o.Exchange(context.Background(), "code1", "http://localhost/callback")
+	require.NoError(t, err)
+	assert.Equal(t, "a1", token.AccessToken)
+	assert.Equal(t, "r1", token.RefreshToken)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), token.Expiry, time.Minute)
+
+	token, err = o.Refresh(context.Background(), "r1")
+	require.NoError(t, err)
+	assert.Equal(t, "a2", token.AccessToken)
+	assert.Equal(t, "r1", token.RefreshToken, "the refresh token is kept")
+
+	_, err = o.Refresh(context.Background(), "revoked")
+	assert.ErrorContains(t, err, "invalid_grant")
+}
+
+// fakeCalendar keeps events in memory, reporting every change since the
+// last list as the Calendar API does with sync tokens
+type fakeCalendar struct {
+	events  map[string]*Event
+	changed []string
+	calls   []string
+	nextID  int
+}
+
+func (f *fakeCalendar) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/calendars/primary/events")
+	id = strings.TrimPrefix(id, "/")
+	f.calls = append(f.calls, r.Method+" "+id)
+
+	switch {
+	case r.Method == http.MethodGet:
+		if r.URL.Query().Get("syncToken") == "expired" {
+			w.WriteHeader(http.StatusGone)
+			return
+		}
+		items := []Event{}
+		for _, id := range f.changed {
+			items = append(items, *f.events[id])
+		}
+		f.changed = nil
+		json.NewEncoder(w).Encode(map[string]any{"items": items, "nextSyncToken": "next"})
+	case r.Method == http.MethodPost:
+		var e Event
+		json.NewDecoder(r.Body).Decode(&e)
+		f.nextID++
+		e.ID = fmt.Sprint("ev", f.nextID)
+		f.events[e.ID] = &e
+		f.changed = append(f.changed, e.ID)
+		json.NewEncoder(w).Encode(e)
+	case f.events[id] == nil || f.events[id].Status == "cancelled":
+		w.WriteHeader(http.StatusNotFound)
+	case r.Method == http.MethodPatch:
+		json.NewDecoder(r.Body).Decode(f.events[id])
+		f.changed = append(f.changed, id)
+		w.Write([]byte(`{}`))
+	case r.Method == http.MethodDelete:
+		f.events[id] = &Event{ID: id, Status: "cancelled"}
+		f.changed = append(f.changed, id)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// move moves an event in the calendar by d
+func (f *fakeCalendar) move(id string, d time.Duration) {
+	start, _ := time.Parse(time.RFC3339, f.events[id].Start.DateTime)
+	f.events[id].Start.DateTime = start.Add(d).Format(time.RFC3339)
+	f.changed = append(f.changed, id)
+}
+
+func TestSync(t *testing.T) {
+	database, err := db.New(t.TempDir())
+	require.NoError(t, err)
+	defer database.Close()
+
+	calendar := &fakeCalendar{events: map[string]*Event{}}
+	server := httptest.NewServer(calendar)
+	defer server.Close()
+
+	s := NewSyncer(database, NewOAuth("client", "secret"))
+	s.BaseURL = server.URL
+	sync := func() *Result {
+		calendar.calls = nil
+		result, err := s.Sync(context.Background())
+		require.NoError(t, err)
+		return result
+	}
+
+	// Nothing to do until a calendar is connected
+	result, err := s.Sync(context.Background())
+	require.NoError(t, err)
+	assert.Nil(t, result)
+
+	expires := time.Now().Add(time.Hour)
+	require.NoError(t, database.ConnectCalendar(&models.CalendarConnection{
+		CalendarID: "primary", RefreshToken: "r1", AccessToken: "a1", ExpiresAt: &expires,
+	}))
+
+	elm, err := database.CreateApartment(&models.ApartmentRequest{Address: "12 Elm St"})
+	require.NoError(t, err)
+	tour := time.Now().Add(48 * time.Hour).UTC().Truncate(time.Second)
+	visit, err := database.CreateVisit(elm.ID, &models.VisitRequest{VisitedAt: models.CustomTime{Time: tour}})
+	require.NoError(t, err)
+	_, err = database.CreateVisit(elm.ID, &models.VisitRequest{VisitedAt: models.CustomTime{Time: tour.Add(-30 * 24 * time.Hour)}})
+	require.NoError(t, err)
+
+	assert.Equal(t, &Result{Created: 1}, sync(), "past visits aren't pushed")
+	require.Contains(t, calendar.events, "ev1")
+	assert.Equal(t, "Tour: 12 Elm St", calendar.events["ev1"].Summary)
+	assert.Equal(t, tour.Format(time.RFC3339), calendar.events["ev1"].Start.DateTime)
+
+	// The app's own insert comes back as a change, and nothing is rewritten
+	assert.Equal(t, &Result{}, sync())
+	assert.Equal(t, []string{"GET "}, calendar.calls)
+
+	// Moved in the calendar
+	calendar.move("ev1", time.Hour)
+	assert.Equal(t, &Result{Moved: 1}, sync())
+	moved, err := database.GetVisit(elm.ID, visit.ID)
+	require.NoError(t, err)
+	assert.True(t, moved.VisitedAt.Equal(tour.Add(time.Hour)), moved.VisitedAt)
+	assert.Equal(t, &Result{}, sync(), "the move isn't pushed back")
+
+	// Changed in the app
+	_, err = database.UpdateVisit(elm.ID, visit.ID, &models.VisitRequest{VisitedAt: models.CustomTime{Time: tour}, Notes: "Ask about parking"})
+	require.NoError(t, err)
+	assert.Equal(t, &Result{Updated: 1}, sync())
+	assert.Equal(t, "Ask about parking", calendar.events["ev1"].Description)
+	assert.Equal(t, tour.Format(time.RFC3339), calendar.events["ev1"].Start.DateTime)
+	assert.Equal(t, &Result{}, sync(), "the app's own patch isn't taken as a move")
+
+	// Deleted in the calendar, and not put back
+	calendar.events["ev1"] = &Event{ID: "ev1", Status: "cancelled"}
+	calendar.changed = append(calendar.changed, "ev1")
+	assert.Equal(t, &Result{Unlinked: 1}, sync())
+	assert.Equal(t, &Result{}, sync())
+
+	// Deleted in the app
+	other, err := database.CreateVisit(elm.ID, &models.VisitRequest{VisitedAt: models.CustomTime{Time: tour.Add(24 * time.Hour)}})
+	require.NoError(t, err)
+	assert.Equal(t, &Result{Created: 1}, sync())
+	require.NoError(t, database.DeleteVisit(elm.ID, other.ID))
+	assert.Equal(t, &Result{Deleted: 1}, sync())
+	assert.Equal(t, "cancelled", calendar.events["ev2"].Status)
+
+	// An expired sync token starts over
+	require.NoError(t, database.SaveCalendarSyncToken("expired"))
+	sync()
+	assert.Equal(t, []string{"GET ", "GET "}, calendar.calls)
+}
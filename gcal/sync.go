@@ -0,0 +1,344 @@
+package gcal
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mojotx/apt-eval/db"
+	"github.com/mojotx/apt-eval/health"
+	"github.com/mojotx/apt-eval/models"
+	"github.com/rs/zerolog/log"
+)
+
+// visitProperty is the private event property holding the visit's ID
+const visitProperty = "aptEvalVisitId"
+
+// ErrSyncRunning is returned when a sync is asked for while the last one
+// is still going
+var ErrSyncRunning = errors.New("a sync is already running")
+
+// Result counts what a sync changed on each side
+type Result struct {
+	Created  int `json:"created"`  // Events added for visits
+	Updated  int `json:"updated"`  // Events changed to match their visits
+	Deleted  int `json:"deleted"`  // Events removed for deleted or private visits
+	Moved    int `json:"moved"`    // Visits moved to match their events
+	Unlinked int `json:"unlinked"` // Visits whose events were deleted in the calendar
+	Failed   int `json:"failed"`
+}
+
+// Status describes how the last sync went
+type Status struct {
+	Running    bool       `json:"running"`
+	LastRun    *time.Time `json:"last_run"`
+	LastResult *Result    `json:"last_result"`
+	LastError  string     `json:"last_error,omitempty"`
+}
+
+// Syncer keeps the visits and the connected calendar in step. Each sync
+// first pulls the events changed in the calendar, moving visits whose
+// events were moved, then pushes upcoming visits that are new or changed.
+// When both sides changed, the calendar wins.
+type Syncer struct {
+	db    *db.DB
+	OAuth *OAuth
+
+	// BaseURL overrides the Calendar API's, for tests
+	BaseURL string
+
+	// EventLength is how long a visit's event lasts
+	EventLength time.Duration
+
+	// Lookback is how far back visits are still pushed; older ones are
+	// left as they are
+	Lookback time.Duration
+
+	running sync.Mutex
+	mu      sync.Mutex // Guards status
+	status  Status
+}
+
+// NewSyncer creates a syncer using an OAuth client to refresh access
+func NewSyncer(database *db.DB, oauth *OAuth) *Syncer {
+	return &Syncer{
+		db:          database,
+		OAuth:       oauth,
+		EventLength: 30 * time.Minute,
+		Lookback:    24 * time.Hour,
+	}
+}
+
+// Status returns how the last sync went
+func (s *Syncer) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
+// Run syncs for the scheduler, which only needs to know if it failed
+func (s *Syncer) Run(ctx context.Context) error {
+	_, err := s.Sync(ctx)
+	return err
+}
+
+// Sync brings the visits and the calendar in step. Without a connected
+// calendar there's nothing to do. Visits that fail are counted and retried
+// on the next sync; the error names the first failure.
+func (s *Syncer) Sync(ctx context.Context) (*Result, error) {
+	if !s.running.TryLock() {
+		return nil, ErrSyncRunning
+	}
+	defer s.running.Unlock()
+
+	s.mu.Lock()
+	s.status.Running = true
+	s.mu.Unlock()
+
+	result, err := s.sync(ctx)
+	if result != nil || err != nil {
+		health.Record("calendar", err)
+	}
+
+	now := time.Now().UTC()
+	s.mu.Lock()
+	s.status.Running = false
+	s.status.LastRun = &now
+	s.status.LastResult = result
+	s.status.LastError = ""
+	if err != nil {
+		s.status.LastError = err.Error()
+	}
+	s.mu.Unlock()
+	return result, err
+}
+
+func (s *Syncer) sync(ctx context.Context) (*Result, error) {
+	conn, err := s.db.GetCalendarConnection()
+	if err != nil || conn == nil {
+		return nil, err
+	}
+	client, err := s.client(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{}
+	var firstErr error
+	fail := func(item string, err error) {
+		log.Warn().Err(err).Str("item", item).Msg("Failed to sync with calendar")
+		result.Failed++
+		if firstErr == nil {
+			firstErr = fmt.Errorf("%s: %w", item, err)
+		}
+	}
+
+	if err := s.pull(ctx, client, conn.SyncToken, result); err != nil {
+		return result, err
+	}
+	if err := s.push(ctx, client, result, fail); err != nil {
+		return result, err
+	}
+
+	if firstErr != nil {
+		return result, fmt.Errorf("failed to sync %d items, first %w", result.Failed, firstErr)
+	}
+	return result, nil
+}
+
+// client returns a Calendar API client, refreshing the access token first
+// when it's about to expire
+func (s *Syncer) client(ctx context.Context, conn *models.CalendarConnection) (*Client, error) {
+	accessToken := conn.AccessToken
+	if accessToken == "" || conn.ExpiresAt == nil || time.Until(*conn.ExpiresAt) < time.Minute {
+		token, err := s.OAuth.Refresh(ctx, conn.RefreshToken)
+		if err != nil {
+			return nil, fmt.Errorf("failed to refresh calendar access: %w", err)
+		}
+		if err := s.db.SaveCalendarToken(token.AccessToken, token.Expiry); err != nil {
+			return nil, err
+		}
+		accessToken = token.AccessToken
+	}
+
+	client := NewClient(conn.CalendarID, accessToken)
+	if s.BaseURL != "" {
+		client.BaseURL = s.BaseURL
+	}
+	return client, nil
+}
+
+// pull applies the events changed in the calendar since the last pull to
+// their visits
+func (s *Syncer) pull(ctx context.Context, client *Client, syncToken string, result *Result) error {
+	events, next, err := client.Changes(ctx, syncToken)
+	if errors.Is(err, ErrSyncTokenExpired) {
+		log.Info().Msg("Calendar sync token expired, reading all events")
+		events, next, err = client.Changes(ctx, "")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to list calendar changes: %w", err)
+	}
+
+	for i := range events {
+		e := &events[i]
+		v, err := s.db.GetCalendarVisitByEvent(e.ID)
+		if err != nil {
+			return err
+		}
+		if v == nil {
+			continue
+		}
+
+		if e.Status == "cancelled" {
+			unlinked := ""
+			if err := s.db.LinkCalendarEvent(v.VisitID, &unlinked, "", nil); err != nil {
+				return err
+			}
+			result.Unlinked++
+			continue
+		}
+
+		if e.Start == nil || e.Start.DateTime == "" {
+			continue // Made an all-day event, which has no time to move the visit to
+		}
+		start, err := time.Parse(time.RFC3339, e.Start.DateTime)
+		if err != nil {
+			log.Warn().Err(err).Str("event_id", e.ID).Msg("Unreadable calendar event start")
+			continue
+		}
+		if v.EventStart != nil && v.EventStart.Equal(start) {
+			continue // Not moved in the calendar
+		}
+
+		v.VisitedAt = start.UTC()
+		hash, err := hashEvent(s.visitEvent(v))
+		if err != nil {
+			return err
+		}
+		if err := s.db.MoveVisit(v.VisitID, v.VisitedAt, hash); err != nil {
+			return err
+		}
+		result.Moved++
+	}
+
+	return s.db.SaveCalendarSyncToken(next)
+}
+
+// push removes the events of deleted and private visits, and writes those
+// of upcoming visits that are new or changed
+func (s *Syncer) push(ctx context.Context, client *Client, result *Result, fail func(string, error)) error {
+	deletions, err := s.db.CalendarDeletions()
+	if err != nil {
+		return err
+	}
+	for _, eventID := range deletions {
+		if err := client.Delete(ctx, eventID); err != nil {
+			fail("event "+eventID, err)
+			continue
+		}
+		if err := s.db.ClearCalendarDeletion(eventID); err != nil {
+			return err
+		}
+		result.Deleted++
+	}
+
+	visits, err := s.db.CalendarVisits(time.Now().Add(-s.Lookback))
+	if err != nil {
+		return err
+	}
+	for i := range visits {
+		v := &visits[i]
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if v.Private {
+			if v.EventID == nil {
+				continue
+			}
+			// Made private since it was pushed
+			if err := client.Delete(ctx, *v.EventID); err != nil {
+				fail(fmt.Sprintf("visit %d", v.VisitID), err)
+				continue
+			}
+			if err := s.db.LinkCalendarEvent(v.VisitID, nil, "", nil); err != nil {
+				return err
+			}
+			result.Deleted++
+			continue
+		}
+
+		event := s.visitEvent(v)
+		hash, err := hashEvent(event)
+		if err != nil {
+			return err
+		}
+		if v.EventID != nil && v.Hash == hash {
+			continue
+		}
+
+		eventID, created := "", v.EventID == nil
+		if !created {
+			eventID = *v.EventID
+			err = client.Patch(ctx, eventID, event)
+			if errors.Is(err, ErrEventGone) {
+				// Gone without a cancellation seen, so put it back
+				created = true
+			}
+		}
+		if created {
+			var inserted *Event
+			if inserted, err = client.Insert(ctx, event); err == nil {
+				eventID = inserted.ID
+			}
+		}
+		if err != nil {
+			fail(fmt.Sprintf("visit %d", v.VisitID), err)
+			continue
+		}
+
+		start := v.VisitedAt.UTC().Truncate(time.Second)
+		if err := s.db.LinkCalendarEvent(v.VisitID, &eventID, hash, &start); err != nil {
+			return err
+		}
+		if created {
+			result.Created++
+		} else {
+			result.Updated++
+		}
+	}
+	return nil
+}
+
+// visitEvent returns the event a visit is shown as
+func (s *Syncer) visitEvent(v *models.CalendarVisit) *Event {
+	start := v.VisitedAt.UTC().Truncate(time.Second)
+	return &Event{
+		Summary:     "Tour: " + v.Address,
+		Location:    v.Address,
+		Description: v.Notes,
+		Start:       &EventTime{DateTime: start.Format(time.RFC3339)},
+		End:         &EventTime{DateTime: start.Add(s.EventLength).Format(time.RFC3339)},
+		ExtendedProperties: &ExtendedProperties{
+			Private: map[string]string{visitProperty: strconv.FormatInt(v.VisitID, 10)},
+		},
+	}
+}
+
+// hashEvent fingerprints an event as the app writes it, so events whose
+// visits haven't changed aren't sent again
+func hashEvent(e *Event) (string, error) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:16]), nil
+}
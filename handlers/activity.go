@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mojotx/apt-eval/db"
+	"github.com/mojotx/apt-eval/models"
+	"github.com/rs/zerolog/log"
+)
+
+// defaultActivityLimit is how many entries an activity page holds by
+// default
+const defaultActivityLimit = 50
+
+// ActivityHandler handles the household's activity feed
+type ActivityHandler struct {
+	db *db.DB
+}
+
+// NewActivityHandler creates a new activity handler
+func NewActivityHandler(db *db.DB) *ActivityHandler {
+	return &ActivityHandler{
+		db: db,
+	}
+}
+
+// List handles retrieving the activity feed, newest first. before pages
+// back through older entries; since polls for entries newer than the
+// newest one seen.
+func (h *ActivityHandler) List(c *gin.Context) {
+	opts := db.ActivityOptions{Viewer: viewerID(c), Limit: defaultActivityLimit}
+	params := []struct {
+		name string
+		dest *int64
+	}{{"before", &opts.Before}, {"since", &opts.Since}}
+	for _, p := range params {
+		if s := c.Query(p.name); s != "" {
+			v, err := strconv.ParseInt(s, 10, 64)
+			if err != nil || v < 1 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + p.name})
+				return
+			}
+			*p.dest = v
+		}
+	}
+	if s := c.Query("limit"); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil || limit < 1 || limit > maxPageSize {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", maxPageSize)})
+			return
+		}
+		opts.Limit = limit
+	}
+
+	activity, err := h.db.ListActivity(opts)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list activity")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list activity"})
+		return
+	}
+
+	// A full page means there may be older entries
+	if len(activity) == opts.Limit {
+		before := strconv.FormatInt(activity[len(activity)-1].ID, 10)
+		c.Header("X-Next-Cursor", before)
+
+		next := c.Request.URL.Query()
+		next.Set("before", before)
+		c.Header("Link", fmt.Sprintf("<%s?%s>; rel=\"next\"", c.Request.URL.Path, next.Encode()))
+	}
+	respond(c, http.StatusOK, activity, nil)
+}
+
+// recordActivity adds an entry to the activity feed. The request already
+// succeeded, so a failure is only logged.
+func recordActivity(database *db.DB, userID int64, action string, apartmentID int64, detail string) {
+	recordUndoable(database, userID, action, apartmentID, detail, nil, nil)
+}
+
+// recordUndoable adds an entry to the activity feed that POST /api/undo
+// can reverse, given the apartment before the change and after it, or
+// nil after a delete
+func recordUndoable(database *db.DB, userID int64, action string, apartmentID int64, detail string, before, after *db.ApartmentSnapshot) {
+	if err := database.RecordActivity(userID, action, apartmentID, detail, before, after); err != nil {
+		log.Error().Err(err).Int64("apartment_id", apartmentID).Str("action", action).Msg("Failed to record activity")
+	}
+}
+
+// snapshotApartment returns the state of an apartment for undoing a change
+// to it. It's nil when that can't be had, and the change then just can't
+// be undone.
+func snapshotApartment(database *db.DB, id int64) *db.ApartmentSnapshot {
+	snapshot, err := database.SnapshotApartment(id)
+	if err != nil {
+		log.Error().Err(err).Int64("apartment_id", id).Msg("Failed to snapshot apartment")
+	}
+	return snapshot
+}
+
+// recordApartmentChanges adds feed entries for what an update changed:
+// one for a status change, one for new ratings, and one listing the other
+// fields edited. The last one undoes the whole update, given the
+// apartment's snapshot from before it.
+func recordApartmentChanges(database *db.DB, userID int64, before, after *models.Apartment, snapshot *db.ApartmentSnapshot) {
+	type entry struct{ action, detail string }
+	var entries []entry
+	var edited []string
+	rated := false
+	for _, field := range changedFields(before, after) {
+		switch field {
+		case "status":
+			entries = append(entries, entry{models.ActivityStatusChanged, before.Status + " → " + after.Status})
+		case "rating", "ratings":
+			rated = true
+		default:
+			edited = append(edited, field)
+		}
+	}
+	if rated {
+		entries = append(entries, entry{models.ActivityRated, ""})
+	}
+	if len(edited) > 0 {
+		entries = append(entries, entry{models.ActivityEdited, strings.Join(edited, ", ")})
+	}
+
+	for i, e := range entries {
+		if i < len(entries)-1 || snapshot == nil {
+			recordActivity(database, userID, e.action, after.ID, e.detail)
+			continue
+		}
+		recordUndoable(database, userID, e.action, after.ID, e.detail, snapshot, snapshotApartment(database, after.ID))
+	}
+}
+
+// requestFields are the JSON names of the fields an apartment request can
+// set, in declaration order
+var requestFields = func() []string {
+	var names []string
+	t := reflect.TypeOf(models.ApartmentRequest{})
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			names = append(names, name)
+		}
+	}
+	return names
+}()
+
+// changedFields returns the names of the fields a request can set that
+// differ between two versions of an apartment
+func changedFields(before, after *models.Apartment) []string {
+	b, err := toJSONMap(before)
+	if err != nil {
+		return nil
+	}
+	a, err := toJSONMap(after)
+	if err != nil {
+		return nil
+	}
+
+	var changed []string
+	for _, name := range requestFields {
+		old, ok := b[name]
+		if !ok {
+			continue
+		}
+		if !reflect.DeepEqual(old, a[name]) {
+			changed = append(changed, name)
+		}
+	}
+	return changed
+}
+
+// toJSONMap returns v as it's encoded in JSON responses
+func toJSONMap(v any) (map[string]any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]any
+	err = json.Unmarshal(data, &m)
+	return m, err
+}
+
+// RegisterRoutes registers the activity feed route
+func (h *ActivityHandler) RegisterRoutes(router *gin.Engine) {
+	router.GET("/api/activity", h.List)
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mojotx/apt-eval/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChangedFields(t *testing.T) {
+	price, lower := 1500.0, 1450.0
+	before := &models.Apartment{ID: 1, Address: "12 Elm St", Price: &price, Status: models.StatusConsidering}
+	after := *before
+	assert.Empty(t, changedFields(before, &after))
+
+	after.Price = &lower
+	after.Status = models.StatusVisited
+	after.Score = new(float64) // Derived, not set by requests
+	after.Ratings.Location = new(int)
+	assert.Equal(t, []string{"price", "status", "ratings"}, changedFields(before, &after))
+}
+
+func TestTriggers(t *testing.T) {
+	router, h := newTestApartmentHandler(t)
+	NewTriggerHandler(h.db).RegisterRoutes(router)
+
+	var first, second models.Apartment
+	assert.Equal(t, http.StatusCreated, send(t, router, http.MethodPost, "/api/apartments", `{"address":"1 Main St"}`, &first))
+	assert.Equal(t, http.StatusCreated, send(t, router, http.MethodPost, "/api/apartments", `{"address":"2 Main St"}`, &second))
+	url := fmt.Sprintf("/api/apartments/%d", first.ID)
+	assert.Equal(t, http.StatusOK, send(t, router, http.MethodPatch, url, `{"status":"visited"}`, nil))
+	assert.Equal(t, http.StatusOK, send(t, router, http.MethodPatch, url, `{"rating":4}`, nil))
+
+	var events []models.TriggerEvent
+	assert.Equal(t, http.StatusOK, send(t, router, http.MethodGet, "/api/triggers/new_apartment", "", &events))
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, second.ID, events[0].ApartmentID, "newest first")
+		assert.Greater(t, events[0].ID, events[1].ID)
+		assert.Equal(t, models.TriggerNewApartment, events[0].Trigger)
+	}
+
+	assert.Equal(t, http.StatusOK, send(t, router, http.MethodGet, "/api/triggers/status_changed", "", &events))
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, models.StatusConsidering, events[0].PreviousStatus)
+		assert.Equal(t, models.StatusVisited, events[0].NewStatus)
+		if assert.NotNil(t, events[0].Apartment) {
+			assert.Equal(t, 4, *events[0].Apartment.Rating, "the apartment as it is now")
+		}
+	}
+	since := events[0].ID
+
+	assert.Equal(t, http.StatusOK, send(t, router, http.MethodGet, "/api/triggers/rating_changed", "", &events))
+	assert.Len(t, events, 1)
+
+	assert.Equal(t, http.StatusOK, send(t, router, http.MethodPatch, url, `{"status":"applied"}`, nil))
+	assert.Equal(t, http.StatusOK, send(t, router, http.MethodGet, fmt.Sprintf("/api/triggers/status_changed?since=%d", since), "", &events))
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, models.StatusApplied, events[0].NewStatus)
+	}
+
+	assert.Equal(t, http.StatusOK, send(t, router, http.MethodDelete, url, "", nil))
+	assert.Equal(t, http.StatusOK, send(t, router, http.MethodGet, "/api/triggers/status_changed", "", &events))
+	if assert.Len(t, events, 2) {
+		assert.Nil(t, events[0].Apartment, "deleted since")
+		assert.Equal(t, "1 Main St", events[0].Address)
+	}
+
+	assert.Equal(t, http.StatusNotFound, send(t, router, http.MethodGet, "/api/triggers/visited", "", nil))
+	assert.Equal(t, http.StatusBadRequest, send(t, router, http.MethodGet, "/api/triggers/new_apartment?limit=0", "", nil))
+
+	var schema models.ActionSchema
+	assert.Equal(t, http.StatusOK, send(t, router, http.MethodGet, "/api/actions/create_apartment", "", &schema))
+	assert.Equal(t, http.MethodPost, schema.Method)
+	assert.Equal(t, "http://example.com/api/apartments", schema.URL)
+	if assert.NotEmpty(t, schema.InputFields) {
+		assert.Equal(t, "address", schema.InputFields[0].Key)
+		assert.True(t, schema.InputFields[0].Required)
+	}
+}
+
+func TestAuditVisibility(t *testing.T) {
+	router, h := newTestApartmentHandler(t)
+	NewAuditHandler(h.db).RegisterRoutes(router)
+	admin := gin.New()
+	admin.Use(Viewer(h.db), func(c *gin.Context) { c.Set(adminKey, true) })
+	NewAuditHandler(h.db).RegisterRoutes(admin)
+
+	owner, other := createTestUser(t, h.db, "Alex"), createTestUser(t, h.db, "Sam")
+	var private models.Apartment
+	sendAs(t, router, owner, http.MethodPost, "/api/apartments", `{"address":"1 Secret Ln","visibility":"private"}`, &private)
+	sendAs(t, router, owner, http.MethodPatch, fmt.Sprintf("/api/apartments/%d", private.ID), `{"rating":4}`, nil)
+	sendAs(t, router, other, http.MethodPost, "/api/apartments", `{"address":"2 Shared St"}`, nil)
+
+	var entries []models.Activity
+	assert.Equal(t, http.StatusOK, sendAs(t, router, other, http.MethodGet, "/api/admin/audit", "", &entries))
+	if assert.Len(t, entries, 1, "entries about others' private apartments are hidden") {
+		assert.Equal(t, "2 Shared St", entries[0].Address)
+	}
+	assert.Equal(t, http.StatusOK, sendAs(t, router, other, http.MethodGet, fmt.Sprintf("/api/admin/audit?apartment_id=%d", private.ID), "", &entries))
+	assert.Empty(t, entries)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/audit.csv", nil)
+	req.Header.Set("X-User-ID", strconv.FormatInt(other, 10))
+	router.ServeHTTP(w, req)
+	assert.NotContains(t, w.Body.String(), "Secret", "nor exported")
+
+	assert.Equal(t, http.StatusOK, sendAs(t, router, owner, http.MethodGet, "/api/admin/audit", "", &entries))
+	assert.Len(t, entries, 3, "the owner sees their own")
+	assert.Equal(t, http.StatusOK, sendAs(t, admin, other, http.MethodGet, "/api/admin/audit", "", &entries))
+	assert.Len(t, entries, 3, "admins see everything")
+}
+
+func TestDeadlineVisibility(t *testing.T) {
+	router, h := newTestApartmentHandler(t)
+	NewDeadlineHandler(h.db).RegisterRoutes(router)
+
+	owner, other := createTestUser(t, h.db, "Alex"), createTestUser(t, h.db, "Sam")
+	due := time.Now().AddDate(0, 0, 3).Format(time.RFC3339)
+	sendAs(t, router, owner, http.MethodPost, "/api/apartments",
+		fmt.Sprintf(`{"address":"1 Secret Ln","visibility":"private","application_deadline":%q}`, due), nil)
+	sendAs(t, router, other, http.MethodPost, "/api/apartments",
+		fmt.Sprintf(`{"address":"2 Shared St","hold_expires":%q}`, due), nil)
+
+	var deadlines []models.Deadline
+	assert.Equal(t, http.StatusOK, sendAs(t, router, other, http.MethodGet, "/api/deadlines", "", &deadlines))
+	if assert.Len(t, deadlines, 1, "deadlines of others' private apartments are hidden") {
+		assert.Equal(t, "2 Shared St", deadlines[0].Address)
+	}
+	assert.Equal(t, http.StatusOK, sendAs(t, router, owner, http.MethodGet, "/api/deadlines?days=7", "", &deadlines))
+	assert.Len(t, deadlines, 2)
+}
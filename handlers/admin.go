@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mojotx/apt-eval/scheduler"
+)
+
+// adminPrefix is where the routes only admins may use live
+const adminPrefix = "/api/admin"
+
+// adminKey is the context key set when the directory gave the viewer the
+// admin role
+const adminKey = "admin"
+
+// isAdmin reports whether the directory verified the viewer as an admin.
+// Other sign-in methods have no roles, so no one they identify is one.
+func isAdmin(c *gin.Context) bool {
+	return c.GetBool(adminKey)
+}
+
+// AdminOnly refuses requests to the admin routes unless the viewer is an
+// admin. Only directory sign-in gives out roles, so without it the admin
+// routes are closed to everyone.
+func AdminOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if strings.HasPrefix(c.Request.URL.Path, adminPrefix) && !isAdmin(c) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Only admins may do this"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// AdminHandler handles administrative requests
+type AdminHandler struct {
+	scheduler *scheduler.Scheduler
+}
+
+// NewAdminHandler creates a new admin handler
+func NewAdminHandler(scheduler *scheduler.Scheduler) *AdminHandler {
+	return &AdminHandler{
+		scheduler: scheduler,
+	}
+}
+
+// ListTasks returns the schedule and last run status of every task
+func (h *AdminHandler) ListTasks(c *gin.Context) {
+	respond(c, http.StatusOK, h.scheduler.Status(), nil)
+}
+
+// RunTask triggers a scheduled task immediately
+func (h *AdminHandler) RunTask(c *gin.Context) {
+	name := c.Param("name")
+	if err := h.scheduler.RunNow(name); err != nil {
+		switch {
+		case errors.Is(err, scheduler.ErrUnknownTask):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		case errors.Is(err, scheduler.ErrTaskRunning):
+			c.JSON(http.StatusConflict, gin.H{"error": "Task is already running"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to run task"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"status": "started", "task": name})
+}
+
+// RegisterRoutes registers all admin routes
+func (h *AdminHandler) RegisterRoutes(router *gin.Engine) {
+	admin := router.Group("/api/admin")
+	{
+		admin.GET("/tasks", h.ListTasks)
+		admin.POST("/tasks/:name/run", h.RunTask)
+	}
+}
@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminOnly(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	serve := func(admin bool, target string) int {
+		router := gin.New()
+		router.Use(func(c *gin.Context) {
+			if admin {
+				c.Set(adminKey, true)
+			}
+		}, AdminOnly())
+		router.GET("/api/*path", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusForbidden, serve(false, "/api/admin/retention"), "no role, no admin routes")
+	assert.Equal(t, http.StatusForbidden, serve(false, "/api/admin/audit.csv"))
+	assert.Equal(t, http.StatusOK, serve(true, "/api/admin/retention"))
+	assert.Equal(t, http.StatusOK, serve(false, "/api/apartments"), "other routes are left alone")
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mojotx/apt-eval/db"
+	"github.com/mojotx/apt-eval/filter"
+	"github.com/mojotx/apt-eval/models"
+	"github.com/rs/zerolog/log"
+)
+
+// AlertHandler handles users' alert rules
+type AlertHandler struct {
+	db *db.DB
+}
+
+// NewAlertHandler creates a new alert rule handler
+func NewAlertHandler(db *db.DB) *AlertHandler {
+	return &AlertHandler{
+		db: db,
+	}
+}
+
+// List handles retrieving a user's alert rules
+func (h *AlertHandler) List(c *gin.Context) {
+	id, ok := parseID(c, "id", "user")
+	if !ok || !ownAccount(c, id) {
+		return
+	}
+
+	rules, err := h.db.ListAlertRules(id)
+	if err != nil {
+		log.Error().Err(err).Int64("id", id).Msg("Failed to list alert rules")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list alert rules"})
+		return
+	}
+	respond(c, http.StatusOK, rules, nil)
+}
+
+// Create handles adding an alert rule for a user
+func (h *AlertHandler) Create(c *gin.Context) {
+	id, ok := parseID(c, "id", "user")
+	if !ok || !ownAccount(c, id) {
+		return
+	}
+
+	var request models.AlertRuleRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Error().Err(err).Msg("Failed to bind request")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if request.Filter != "" {
+		if _, err := filter.Parse(request.Filter, db.ApartmentFields); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid filter: " + err.Error()})
+			return
+		}
+	}
+
+	rule, err := h.db.CreateAlertRule(id, &request)
+	if err != nil {
+		log.Error().Err(err).Int64("id", id).Msg("Failed to create alert rule")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create alert rule"})
+		return
+	}
+	c.JSON(http.StatusCreated, rule)
+}
+
+// Delete handles removing one of a user's alert rules
+func (h *AlertHandler) Delete(c *gin.Context) {
+	id, ok := parseID(c, "id", "user")
+	if !ok || !ownAccount(c, id) {
+		return
+	}
+	ruleID, ok := parseID(c, "rule_id", "alert rule")
+	if !ok {
+		return
+	}
+
+	if err := h.db.DeleteAlertRule(id, ruleID); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Alert rule not found"})
+			return
+		}
+		log.Error().Err(err).Int64("id", ruleID).Msg("Failed to delete alert rule")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete alert rule"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success"})
+}
+
+// RegisterRoutes registers the alert rule routes
+func (h *AlertHandler) RegisterRoutes(router *gin.Engine) {
+	router.GET("/api/users/:id/alerts", h.List)
+	router.POST("/api/users/:id/alerts", h.Create)
+	router.DELETE("/api/users/:id/alerts/:rule_id", h.Delete)
+}
@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// altSvcMaxAge is how long clients may remember that HTTP/3 is available,
+// in seconds
+const altSvcMaxAge = 86400
+
+// AltSvc returns middleware that advertises the HTTP/3 listener on port to
+// clients connecting over TLS with HTTP/1.1 or HTTP/2
+func AltSvc(port string) gin.HandlerFunc {
+	value := `h3=":` + port + `"; ma=` + strconv.Itoa(altSvcMaxAge)
+	return func(c *gin.Context) {
+		if c.Request.TLS != nil && c.Request.ProtoMajor < 3 {
+			c.Header("Alt-Svc", value)
+		}
+		c.Next()
+	}
+}
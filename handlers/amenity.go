@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mojotx/apt-eval/db"
+	"github.com/mojotx/apt-eval/models"
+	"github.com/rs/zerolog/log"
+)
+
+// AmenityHandler handles the amenity taxonomy
+type AmenityHandler struct {
+	db *db.DB
+}
+
+// NewAmenityHandler creates a new amenity handler
+func NewAmenityHandler(db *db.DB) *AmenityHandler {
+	return &AmenityHandler{
+		db: db,
+	}
+}
+
+// List handles retrieving the amenity taxonomy
+func (h *AmenityHandler) List(c *gin.Context) {
+	amenities, err := h.db.ListAmenities()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list amenities")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list amenities"})
+		return
+	}
+	respond(c, http.StatusOK, amenities, nil)
+}
+
+// Create handles adding an amenity to the taxonomy
+func (h *AmenityHandler) Create(c *gin.Context) {
+	var request models.AmenityRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Error().Err(err).Msg("Failed to bind request")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	amenity, err := h.db.CreateAmenity(&request)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to create amenity")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create amenity"})
+		return
+	}
+	if amenity == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Amenity already exists"})
+		return
+	}
+	c.JSON(http.StatusCreated, amenity)
+}
+
+// respondUnknownAmenity responds with 400 if err names an amenity missing
+// from the taxonomy, reporting whether it did
+func respondUnknownAmenity(c *gin.Context, err error) bool {
+	var unknown *db.UnknownAmenityError
+	if !errors.As(err, &unknown) {
+		return false
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown amenity: " + unknown.Key})
+	return true
+}
+
+// RegisterRoutes registers all amenity routes
+func (h *AmenityHandler) RegisterRoutes(router *gin.Engine) {
+	amenities := router.Group("/api/amenities")
+	{
+		amenities.GET("", h.List)
+		amenities.POST("", h.Create)
+	}
+}
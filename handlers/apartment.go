@@ -1,24 +1,36 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"github.com/mojotx/apt-eval/db"
+	"github.com/mojotx/apt-eval/filter"
+	"github.com/mojotx/apt-eval/markdown"
 	"github.com/mojotx/apt-eval/models"
+	"github.com/mojotx/apt-eval/storage"
 	"github.com/rs/zerolog/log"
 )
 
 // ApartmentHandler handles apartment-related requests
 type ApartmentHandler struct {
-	db *db.DB
+	db    *db.DB
+	store *storage.Store
 }
 
-// NewApartmentHandler creates a new apartment handler
-func NewApartmentHandler(db *db.DB) *ApartmentHandler {
+// NewApartmentHandler creates a new apartment handler. store holds the
+// files of the attachments and floor plans deleted with an apartment.
+func NewApartmentHandler(db *db.DB, store *storage.Store) *ApartmentHandler {
 	return &ApartmentHandler{
-		db: db,
+		db:    db,
+		store: store,
 	}
 }
 
@@ -30,18 +42,54 @@ func (h *ApartmentHandler) Create(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if !checkVisibilityRequest(c, &request, nil) {
+		return
+	}
 
 	apartment, err := h.db.CreateApartment(&request)
 	if err != nil {
+		if respondUnknownAmenity(c, err) {
+			return
+		}
+		if respondUnknownBuilding(c, err) {
+			return
+		}
+		if respondFormError(c, err) {
+			return
+		}
+		if respondDuplicateAddress(c, h.db, err) {
+			return
+		}
 		log.Error().Err(err).Msg("Failed to create apartment")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create apartment"})
 		return
 	}
 
+	recordActivity(h.db, viewerID(c), models.ActivityAdded, apartment.ID, "")
 	c.JSON(http.StatusCreated, apartment)
 }
 
-// Get handles retrieving an apartment by ID
+// parseRender reads the render query parameter, which asks for notes
+// rendered as HTML, responding with an error if it's invalid
+func parseRender(c *gin.Context) (html bool, ok bool) {
+	switch c.Query("render") {
+	case "":
+		return false, true
+	case "html":
+		return true, true
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid render: must be html"})
+	return false, false
+}
+
+// renderNotes fills in the HTML rendering of an apartment's notes
+func renderNotes(apt *models.Apartment) {
+	html := markdown.HTML(apt.Notes)
+	apt.NotesHTML = &html
+}
+
+// Get handles retrieving an apartment by ID. ?render=html adds the notes
+// rendered as HTML.
 func (h *ApartmentHandler) Get(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := strconv.ParseInt(idStr, 10, 64)
@@ -51,6 +99,16 @@ func (h *ApartmentHandler) Get(c *gin.Context) {
 		return
 	}
 
+	fields, err := parseFields(c, apartmentFields)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid fields: " + err.Error()})
+		return
+	}
+	renderHTML, ok := parseRender(c)
+	if !ok {
+		return
+	}
+
 	apartment, err := h.db.GetApartment(id)
 	if err != nil {
 		log.Error().Err(err).Int64("id", id).Msg("Failed to get apartment")
@@ -58,24 +116,260 @@ func (h *ApartmentHandler) Get(c *gin.Context) {
 		return
 	}
 
-	if apartment == nil {
+	if apartment == nil || !apartment.VisibleTo(viewerID(c)) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Apartment not found"})
 		return
 	}
 
-	c.JSON(http.StatusOK, apartment)
+	if checkNotModified(c, apartment.UpdatedAt) {
+		return
+	}
+
+	if renderHTML {
+		renderNotes(apartment)
+	}
+	respond(c, http.StatusOK, apartment, fields)
+}
+
+// maxPageSize caps the limit query parameter on list endpoints
+const maxPageSize = 500
+
+// compactApartments reduces apartments to their compact list entries, with
+// a floor plan image as the cover thumbnail where there is one
+func compactApartments(database *db.DB, apartments []models.Apartment) ([]models.CompactApartment, error) {
+	images, err := database.FloorPlanImages()
+	if err != nil {
+		return nil, err
+	}
+
+	compact := make([]models.CompactApartment, len(apartments))
+	for i, a := range apartments {
+		compact[i] = models.CompactApartment{
+			ID:      a.ID,
+			UUID:    a.UUID,
+			Address: a.Address,
+			Unit:    a.Unit,
+			Price:   a.Price,
+			Rating:  a.Rating,
+			Status:  a.Status,
+		}
+		// The cover photo stands for the apartment, or else its floor plan
+		if a.CoverPhotoID != nil {
+			url := coverPhotoURL(strconv.FormatInt(a.ID, 10), *a.CoverPhotoID)
+			compact[i].ThumbnailURL = &url
+		} else if uploadedAt, ok := images[a.ID]; ok {
+			url := floorPlanThumbnailURL(a.ID, uploadedAt)
+			compact[i].ThumbnailURL = &url
+		}
+	}
+	return compact, nil
 }
 
-// List handles retrieving all apartments
+// List handles retrieving all apartments, optionally narrowed by a filter
+// expression in the q query parameter and paginated with limit/offset or
+// an opaque cursor. ?view=compact returns trimmed-down entries for phone
+// list views, and ?render=html adds the notes rendered as HTML.
 func (h *ApartmentHandler) List(c *gin.Context) {
-	apartments, err := h.db.ListApartments()
+	opts, err := parseListOptions(c)
+	if err != nil {
+		log.Error().Err(err).Str("query", c.Request.URL.RawQuery).Msg("Invalid list parameters")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query: " + err.Error()})
+		return
+	}
+
+	fields, err := parseFields(c, apartmentFields)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid fields: " + err.Error()})
+		return
+	}
+
+	view := c.Query("view")
+	if view != "" && view != "compact" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid view: must be compact"})
+		return
+	}
+	if view == "compact" && fields != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "fields can't be combined with view=compact"})
+		return
+	}
+	renderHTML, ok := parseRender(c)
+	if !ok {
+		return
+	}
+
+	apartments, err := h.db.ListApartments(opts)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to list apartments")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list apartments"})
 		return
 	}
 
-	c.JSON(http.StatusOK, apartments)
+	// A full page means there may be more rows; hand out a cursor for them.
+	// Cursors follow the default order, so a sorted list pages by offset.
+	if opts.Limit > 0 && len(apartments) == opts.Limit {
+		next := c.Request.URL.Query()
+		if len(opts.Sort) > 0 {
+			next.Set("offset", strconv.Itoa(opts.Offset+len(apartments)))
+		} else {
+			last := apartments[len(apartments)-1]
+			cursor := db.Cursor{CreatedAt: last.CreatedAt, ID: last.ID}.Encode()
+			c.Header("X-Next-Cursor", cursor)
+			next.Del("offset")
+			next.Set("cursor", cursor)
+		}
+		c.Header("Link", fmt.Sprintf("<%s?%s>; rel=\"next\"", c.Request.URL.Path, next.Encode()))
+	}
+
+	// A list's modification time is that of its most recently updated
+	// member. Removals don't advance it, so clients that need to notice
+	// deletions should revalidate without If-Modified-Since.
+	if checkNotModified(c, lastModified(apartments)) {
+		return
+	}
+
+	if view == "compact" {
+		compact, err := compactApartments(h.db, apartments)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to build compact list")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list apartments"})
+			return
+		}
+		respond(c, http.StatusOK, compact, nil)
+		return
+	}
+
+	if renderHTML {
+		for i := range apartments {
+			renderNotes(&apartments[i])
+		}
+	}
+	respond(c, http.StatusOK, apartments, fields)
+}
+
+// Count handles counting the apartments a list would return with the same
+// filters, across all its pages, for showing page numbers
+func (h *ApartmentHandler) Count(c *gin.Context) {
+	opts, err := parseListOptions(c)
+	if err != nil {
+		log.Error().Err(err).Str("query", c.Request.URL.RawQuery).Msg("Invalid list parameters")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query: " + err.Error()})
+		return
+	}
+
+	n, err := h.db.CountApartments(opts)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to count apartments")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count apartments"})
+		return
+	}
+	respond(c, http.StatusOK, gin.H{"count": n}, nil)
+}
+
+// Exists handles checking whether an apartment exists, answering HEAD
+// requests with only a status
+func (h *ApartmentHandler) Exists(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	exists, err := h.db.ApartmentExists(id, viewerID(c))
+	if err != nil {
+		log.Error().Err(err).Int64("id", id).Msg("Failed to check apartment")
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// andFilter combines two filter expressions, either of which may be empty
+func andFilter(a, b string) string {
+	if a == "" {
+		return b
+	}
+	return "(" + a + ") AND " + b
+}
+
+// parseListOptions builds list options from the q, search, limit, offset,
+// and cursor query parameters
+func parseListOptions(c *gin.Context) (db.ListOptions, error) {
+	opts := db.ListOptions{Viewer: viewerID(c)}
+
+	q := c.Query("q")
+	if s := c.Query("max_transit_walk"); s != "" {
+		minutes, err := strconv.Atoi(s)
+		if err != nil || minutes < 0 {
+			return opts, errors.New("max_transit_walk must be a non-negative integer")
+		}
+		q = andFilter(q, fmt.Sprintf("transit_walk_minutes <= %d", minutes))
+	}
+
+	if s := c.Query("amenities"); s != "" {
+		for _, key := range strings.Split(s, ",") {
+			switch key = strings.TrimSpace(key); key {
+			case "":
+			case models.AmenityGarage: // Now part of the parking details
+				q = andFilter(q, "has_garage")
+			default:
+				opts.Amenities = append(opts.Amenities, key)
+			}
+		}
+	}
+
+	opts.Search = c.Query("search")
+
+	if q != "" {
+		node, err := filter.Parse(q, db.ApartmentFields)
+		if err != nil {
+			return opts, fmt.Errorf("filter: %w", err)
+		}
+		opts.Filter = node
+	}
+
+	if s := c.Query("sort"); s != "" {
+		sort, err := parseSort(s)
+		if err != nil {
+			return opts, err
+		}
+		opts.Sort = sort
+	}
+
+	if s := c.Query("limit"); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil || limit < 1 || limit > maxPageSize {
+			return opts, fmt.Errorf("limit must be between 1 and %d", maxPageSize)
+		}
+		opts.Limit = limit
+	}
+
+	if s := c.Query("offset"); s != "" {
+		offset, err := strconv.Atoi(s)
+		if err != nil || offset < 0 {
+			return opts, errors.New("offset must be a non-negative integer")
+		}
+		opts.Offset = offset
+	}
+
+	if s := c.Query("cursor"); s != "" {
+		if opts.Offset > 0 {
+			return opts, errors.New("cursor and offset cannot be combined")
+		}
+		if len(opts.Sort) > 0 {
+			return opts, errors.New("cursor pagination requires the default sort order")
+		}
+		cursor, err := db.DecodeCursor(s)
+		if err != nil {
+			return opts, errors.New("invalid cursor")
+		}
+		opts.After = cursor
+	}
+
+	return opts, nil
 }
 
 // Update handles updating an apartment
@@ -95,8 +389,149 @@ func (h *ApartmentHandler) Update(c *gin.Context) {
 		return
 	}
 
-	apartment, err := h.db.UpdateApartment(id, &request)
+	existing, err := h.db.GetApartment(id)
+	if err != nil {
+		log.Error().Err(err).Int64("id", id).Msg("Failed to get apartment")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get apartment"})
+		return
+	}
+	if existing == nil || !existing.VisibleTo(viewerID(c)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Apartment not found"})
+		return
+	}
+	h.update(c, id, existing, &request)
+}
+
+// nullPatches are what a null in a merge patch means for request fields
+// that aren't simply set to nil by it: the value that clears them. An
+// address can't be cleared, so null fails validation like an empty one.
+var nullPatches = map[string]json.RawMessage{
+	"address":              json.RawMessage(`""`),
+	"unit":                 json.RawMessage(`""`),
+	"notes":                json.RawMessage(`""`),
+	"is_gated":             json.RawMessage(`false`),
+	"has_garage":           json.RawMessage(`false`),
+	"has_laundry":          json.RawMessage(`false`),
+	"listing_url":          json.RawMessage(`""`),
+	"ratings":              json.RawMessage(`{}`),
+	"amenities":            json.RawMessage(`[]`),
+	"answers":              json.RawMessage(`{}`),
+	"neighborhood":         json.RawMessage(`""`),
+	"utilities":            json.RawMessage(`{}`),
+	"parking":              json.RawMessage(`{}`),
+	"sale":                 json.RawMessage(`{}`),
+	"application_deadline": json.RawMessage(`""`),
+	"hold_expires":         json.RawMessage(`""`),
+	"building_id":          json.RawMessage(`0`),
+}
+
+// Patch handles updating some of an apartment's fields with a JSON merge
+// patch (RFC 7396): fields left out are unchanged, and null clears a
+// field, such as a rating or price that turned out to be wrong
+func (h *ApartmentHandler) Patch(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		log.Error().Err(err).Str("id", idStr).Msg("Invalid apartment ID")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid apartment ID"})
+		return
+	}
+
+	var patch map[string]json.RawMessage
+	if err := json.NewDecoder(c.Request.Body).Decode(&patch); err != nil || patch == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Request body must be a JSON object"})
+		return
+	}
+	for name, value := range patch {
+		if !slices.Contains(requestFields, name) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Unknown field %q", name)})
+			return
+		}
+		if clear, ok := nullPatches[name]; ok && string(value) == "null" {
+			patch[name] = clear
+		}
+	}
+
+	existing, err := h.db.GetApartment(id)
+	if err != nil {
+		log.Error().Err(err).Int64("id", id).Msg("Failed to get apartment")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get apartment"})
+		return
+	}
+	if existing == nil || !existing.VisibleTo(viewerID(c)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Apartment not found"})
+		return
+	}
+
+	request := patchBase(existing)
+	data, err := json.Marshal(patch)
+	if err == nil {
+		err = json.Unmarshal(data, &request)
+	}
+	if err == nil {
+		err = binding.Validator.ValidateStruct(&request)
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to bind request")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.update(c, id, existing, &request)
+}
+
+// patchBase returns the request a patch to existing starts from: fields
+// an update replaces start out as they are, and the rest are left
+// unchanged unless the patch sets them. Pointers are copied, since
+// decoding the patch writes through them and existing must stay as it was
+// to tell what changed.
+func patchBase(existing *models.Apartment) models.ApartmentRequest {
+	return models.ApartmentRequest{
+		Address:    existing.Address,
+		VisitDate:  existing.VisitDate,
+		Notes:      existing.Notes,
+		Rating:     clonePtr(existing.Rating),
+		Price:      clonePtr(existing.Price),
+		Floor:      clonePtr(existing.Floor),
+		IsGated:    existing.IsGated,
+		HasGarage:  existing.HasGarage,
+		HasLaundry: existing.HasLaundry,
+		Latitude:   clonePtr(existing.Latitude),
+		Longitude:  clonePtr(existing.Longitude),
+	}
+}
+
+// clonePtr returns a pointer to a copy of what p points to, or nil
+func clonePtr[T any](p *T) *T {
+	if p == nil {
+		return nil
+	}
+	v := *p
+	return &v
+}
+
+// update applies an update request to an existing apartment the viewer
+// can see
+func (h *ApartmentHandler) update(c *gin.Context, id int64, existing *models.Apartment, request *models.ApartmentRequest) {
+	if !checkVisibilityRequest(c, request, existing) {
+		return
+	}
+
+	snapshot := snapshotApartment(h.db, id)
+	apartment, err := h.db.UpdateApartment(id, request)
 	if err != nil {
+		if respondUnknownAmenity(c, err) {
+			return
+		}
+		if respondUnknownBuilding(c, err) {
+			return
+		}
+		if respondFormError(c, err) {
+			return
+		}
+		if respondDuplicateAddress(c, h.db, err) {
+			return
+		}
 		log.Error().Err(err).Int64("id", id).Msg("Failed to update apartment")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update apartment"})
 		return
@@ -107,10 +542,13 @@ func (h *ApartmentHandler) Update(c *gin.Context) {
 		return
 	}
 
+	recordApartmentChanges(h.db, viewerID(c), existing, apartment, snapshot)
 	c.JSON(http.StatusOK, apartment)
 }
 
-// Delete handles deleting an apartment
+// Delete handles deleting an apartment. One with records of its own,
+// like visits, photos, or comments, is only deleted, along with them, when
+// forced with ?force=true; otherwise the answer is 409, listing them.
 func (h *ApartmentHandler) Delete(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := strconv.ParseInt(idStr, 10, 64)
@@ -120,28 +558,241 @@ func (h *ApartmentHandler) Delete(c *gin.Context) {
 		return
 	}
 
-	err = h.db.DeleteApartment(id)
+	if !checkVisible(c, h.db, id) {
+		return
+	}
+
+	force := c.Query("force") == "true"
+	attachments, err := h.db.DeleteApartment(viewerID(c), id, force)
 	if err != nil {
-		log.Error().Err(err).Int64("id", id).Msg("Failed to delete apartment")
-		if err.Error() == "apartment not found" {
+		var hasDependents *db.HasDependentsError
+		switch {
+		case errors.Is(err, db.ErrNotFound):
 			c.JSON(http.StatusNotFound, gin.H{"error": "Apartment not found"})
-			return
+		case errors.As(err, &hasDependents):
+			respondHasDependents(c, hasDependents.Dependents)
+		default:
+			log.Error().Err(err).Int64("id", id).Msg("Failed to delete apartment")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete apartment"})
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete apartment"})
 		return
 	}
 
+	floorPlans := []int64{}
+	if force {
+		floorPlans = append(floorPlans, id)
+	}
+	removeApartmentFiles(h.store, attachments, floorPlans)
+
 	c.JSON(http.StatusOK, gin.H{"status": "success"})
 }
 
+// removeApartmentFiles removes the stored files of deleted apartments: the
+// attachments' files and thumbnails, and the floor plans of the apartments
+// listed. Failures are only logged, as the records are already gone.
+func removeApartmentFiles(store *storage.Store, attachments []models.Attachment, floorPlans []int64) {
+	for i := range attachments {
+		a := &attachments[i]
+		if err := store.Delete(attachmentKey(a)); err != nil {
+			log.Warn().Err(err).Int64("id", a.ID).Msg("Failed to remove attachment file")
+		}
+		if err := store.Delete(attachmentThumbnailKey(a)); err != nil {
+			log.Warn().Err(err).Int64("id", a.ID).Msg("Failed to remove attachment thumbnail")
+		}
+	}
+	for _, id := range floorPlans {
+		if err := store.Delete(floorPlanKey(id)); err != nil {
+			log.Warn().Err(err).Int64("apartment_id", id).Msg("Failed to remove floor plan file")
+		}
+		if err := store.Delete(floorPlanThumbnailKey(id)); err != nil {
+			log.Warn().Err(err).Int64("apartment_id", id).Msg("Failed to remove floor plan thumbnail")
+		}
+	}
+}
+
+// respondDuplicateAddress responds with 409 if err is an apartment taking
+// the address of another, giving the other's ID when the viewer may see it
+func respondDuplicateAddress(c *gin.Context, database *db.DB, err error) bool {
+	var duplicate *db.DuplicateAddressError
+	if !errors.As(err, &duplicate) {
+		return false
+	}
+	body := gin.H{"error": "An apartment at this address already exists"}
+	visible, err := database.ApartmentExists(duplicate.ID, viewerID(c))
+	if err != nil {
+		log.Error().Err(err).Int64("id", duplicate.ID).Msg("Failed to check apartment")
+	} else if visible {
+		body["existing_id"] = duplicate.ID
+	}
+	c.JSON(http.StatusConflict, body)
+	return true
+}
+
+// respondHasDependents refuses to delete an apartment that has records of
+// its own, listing them
+func respondHasDependents(c *gin.Context, dependents map[string]int) {
+	c.JSON(http.StatusConflict, gin.H{
+		"error":      "Apartment has records of its own; delete with ?force=true to delete them too",
+		"dependents": dependents,
+	})
+}
+
+// parseSort parses a comma-separated list of field names, each optionally
+// prefixed with "-" for descending order (e.g. "-walk_score,price")
+func parseSort(s string) ([]db.SortField, error) {
+	var fields []db.SortField
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		desc := strings.HasPrefix(name, "-")
+		name = strings.TrimPrefix(name, "-")
+
+		field, ok := db.ApartmentFields[name]
+		if !ok {
+			return nil, fmt.Errorf("cannot sort by %q", name)
+		}
+		fields = append(fields, db.SortField{Column: field.Column, Desc: desc})
+	}
+	return fields, nil
+}
+
+// Grouped handles summarizing apartments by the text field named in the by
+// query parameter, neighborhood by default
+func (h *ApartmentHandler) Grouped(c *gin.Context) {
+	by := c.DefaultQuery("by", "neighborhood")
+	if f, ok := db.ApartmentFields[by]; !ok || f.Type != filter.Text {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Cannot group by %q", by)})
+		return
+	}
+
+	groups, err := h.db.GroupApartments(by, viewerID(c))
+	if err != nil {
+		log.Error().Err(err).Str("by", by).Msg("Failed to group apartments")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to group apartments"})
+		return
+	}
+	respond(c, http.StatusOK, groups, nil)
+}
+
+// parseFilter parses the filter expression in the q query parameter,
+// responding with an error if it's invalid. It returns nil when there is
+// none.
+func parseFilter(c *gin.Context) (filter.Node, bool) {
+	q := c.Query("q")
+	if q == "" {
+		return nil, true
+	}
+	node, err := filter.Parse(q, db.ApartmentFields)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query: filter: " + err.Error()})
+		return nil, false
+	}
+	return node, true
+}
+
+// Ranked handles listing apartments from best to worst score under the
+// current category weights, with each category's contribution to the
+// score, so the order can be explained. q filters as in List, and ids
+// limits the ranking to the listed apartments.
+func (h *ApartmentHandler) Ranked(c *gin.Context) {
+	ranked, _, ok := h.ranked(c)
+	if !ok {
+		return
+	}
+	respond(c, http.StatusOK, ranked, nil)
+}
+
+// Aggregation limits
+const (
+	maxGroupBy          = 3
+	defaultBracketWidth = 500
+)
+
+// Aggregate handles computing statistics of a number field (price by
+// default) over apartments grouped by the fields in group_by, so charts
+// don't need every row. bracket sets the width of price_bracket groups,
+// and q filters the apartments as in List.
+func (h *ApartmentHandler) Aggregate(c *gin.Context) {
+	opts := db.AggregateOptions{
+		Field:        c.DefaultQuery("field", "price"),
+		BracketWidth: defaultBracketWidth,
+		Viewer:       viewerID(c),
+	}
+	f, ok := db.ApartmentFields[opts.Field]
+	if !ok || f.Type != filter.Number {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Cannot aggregate %q: must be a number field", opts.Field)})
+		return
+	}
+
+	if s := c.Query("group_by"); s != "" {
+		for _, name := range strings.Split(s, ",") {
+			name = strings.TrimSpace(name)
+			f, ok := db.ApartmentFields[name]
+			if name != db.PriceBracket && (!ok || f.Type == filter.Date) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Cannot group by %q", name)})
+				return
+			}
+			opts.GroupBy = append(opts.GroupBy, name)
+		}
+	}
+	if len(opts.GroupBy) > maxGroupBy {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("group_by accepts at most %d fields", maxGroupBy)})
+		return
+	}
+
+	if s := c.Query("bracket"); s != "" {
+		width, err := strconv.ParseFloat(s, 64)
+		if err != nil || width <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "bracket must be a positive number"})
+			return
+		}
+		opts.BracketWidth = width
+	}
+
+	if opts.Filter, ok = parseFilter(c); !ok {
+		return
+	}
+
+	aggregates, err := h.db.AggregateApartments(opts)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to aggregate apartments")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to aggregate apartments"})
+		return
+	}
+	respond(c, http.StatusOK, aggregates, nil)
+}
+
+// Duplicates handles listing apartments entered more than once, grouped
+// by canonical address
+func (h *ApartmentHandler) Duplicates(c *gin.Context) {
+	groups, err := h.db.ListDuplicateApartments(viewerID(c))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list duplicate apartments")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list duplicate apartments"})
+		return
+	}
+	respond(c, http.StatusOK, groups, nil)
+}
+
 // RegisterRoutes registers all apartment-related routes
 func (h *ApartmentHandler) RegisterRoutes(router *gin.Engine) {
 	apartments := router.Group("/api/apartments")
 	{
 		apartments.POST("", h.Create)
+		apartments.POST("/import", h.Import)
 		apartments.GET("", h.List)
+		apartments.GET("/count", h.Count)
+		apartments.GET("/duplicates", h.Duplicates)
+		apartments.GET("/grouped", h.Grouped)
+		apartments.GET("/aggregate", h.Aggregate)
+		apartments.GET("/ranked", h.Ranked)
+		apartments.GET("/suggest", h.Suggest)
+		apartments.GET("/decision-matrix", h.DecisionMatrix)
+		apartments.GET("/decision-matrix.csv", h.DecisionMatrix)
+		apartments.GET("/decision-matrix.xlsx", h.DecisionMatrix)
 		apartments.GET("/:id", h.Get)
+		apartments.HEAD("/:id", h.Exists)
 		apartments.PUT("/:id", h.Update)
+		apartments.PATCH("/:id", h.Patch)
 		apartments.DELETE("/:id", h.Delete)
 	}
 }
@@ -0,0 +1,783 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mojotx/apt-eval/db"
+	"github.com/mojotx/apt-eval/metrics"
+	"github.com/mojotx/apt-eval/models"
+	"github.com/mojotx/apt-eval/storage"
+	"github.com/mojotx/apt-eval/webhook"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRouter(t *testing.T) *gin.Engine {
+	router, _ := newTestApartmentHandler(t)
+	return router
+}
+
+// newTestApartmentHandler returns a router serving an apartment handler on
+// a fresh database and store, and the handler
+func newTestApartmentHandler(t *testing.T) (*gin.Engine, *ApartmentHandler) {
+	gin.SetMode(gin.TestMode)
+	database, err := db.New(t.TempDir())
+	assert.NoError(t, err, "Failed to initialize database")
+	t.Cleanup(func() { database.Close() })
+	store, err := storage.New(t.TempDir())
+	assert.NoError(t, err)
+
+	router := gin.New()
+	router.Use(Viewer(database), ApartmentUUIDs(database))
+	h := NewApartmentHandler(database, store)
+	h.RegisterRoutes(router)
+	return router, h
+}
+
+// createTestUser adds a household member, returning their ID
+func createTestUser(t *testing.T, database *db.DB, name string) int64 {
+	user, err := database.CreateUser(&models.UserRequest{Name: name})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return user.ID
+}
+
+// send performs a request and decodes the JSON response into out
+func send(t *testing.T, router *gin.Engine, method, url, body string, out any) int {
+	return sendAs(t, router, 0, method, url, body, out)
+}
+
+// sendAs is send on behalf of a household member, or no one when userID
+// is 0
+func sendAs(t *testing.T, router *gin.Engine, userID int64, method, url, body string, out any) int {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(method, url, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if userID != 0 {
+		req.Header.Set("X-User-ID", strconv.FormatInt(userID, 10))
+	}
+	router.ServeHTTP(w, req)
+
+	if out != nil {
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), out), "Failed to unmarshal response: %s", w.Body.String())
+	}
+	return w.Code
+}
+
+func TestApartmentAmenityFieldsRoundTrip(t *testing.T) {
+	router := newTestRouter(t)
+
+	var created models.Apartment
+	code := send(t, router, http.MethodPost, "/api/apartments",
+		`{"address":"1 Main St","floor":3,"is_gated":true,"has_garage":true,"has_laundry":false}`, &created)
+	assert.Equal(t, http.StatusCreated, code, "Create should return 201")
+	assert.Equal(t, uint(3), *created.Floor, "Floor should round-trip on create")
+	assert.True(t, created.IsGated, "IsGated should round-trip on create")
+	assert.True(t, created.HasGarage, "HasGarage should round-trip on create")
+	assert.False(t, created.HasLaundry, "HasLaundry should round-trip on create")
+
+	var fetched models.Apartment
+	code = send(t, router, http.MethodGet, "/api/apartments/1", "", &fetched)
+	assert.Equal(t, http.StatusOK, code, "Get should return 200")
+	assert.Equal(t, created.Floor, fetched.Floor, "Floor should be persisted")
+	assert.Equal(t, created.IsGated, fetched.IsGated, "IsGated should be persisted")
+	assert.Equal(t, created.HasGarage, fetched.HasGarage, "HasGarage should be persisted")
+	assert.Equal(t, created.HasLaundry, fetched.HasLaundry, "HasLaundry should be persisted")
+
+	var updated models.Apartment
+	code = send(t, router, http.MethodPut, "/api/apartments/1",
+		`{"address":"1 Main St","floor":7,"is_gated":false,"has_garage":false,"has_laundry":true}`, &updated)
+	assert.Equal(t, http.StatusOK, code, "Update should return 200")
+	assert.Equal(t, uint(7), *updated.Floor, "Floor should round-trip on update")
+	assert.False(t, updated.IsGated, "IsGated should round-trip on update")
+	assert.False(t, updated.HasGarage, "HasGarage should round-trip on update")
+	assert.True(t, updated.HasLaundry, "HasLaundry should round-trip on update")
+}
+
+func TestApartmentJSONIncludesAmenityFields(t *testing.T) {
+	router := newTestRouter(t)
+	send(t, router, http.MethodPost, "/api/apartments", `{"address":"1 Main St","floor":2,"has_laundry":true}`, nil)
+
+	var raw map[string]any
+	send(t, router, http.MethodGet, "/api/apartments/1", "", &raw)
+	assert.Equal(t, float64(2), raw["floor"], "floor should be present in JSON")
+	assert.Equal(t, false, raw["is_gated"], "is_gated should be present in JSON")
+	assert.Equal(t, false, raw["has_garage"], "has_garage should be present in JSON")
+	assert.Equal(t, true, raw["has_laundry"], "has_laundry should be present in JSON")
+}
+
+func TestApartmentOptionalFieldsNull(t *testing.T) {
+	router := newTestRouter(t)
+
+	var raw map[string]any
+	code := send(t, router, http.MethodPost, "/api/apartments", `{"address":"1 Main St"}`, &raw)
+	assert.Equal(t, http.StatusCreated, code)
+	assert.Nil(t, raw["rating"], "unrated is null, not 0")
+	assert.Nil(t, raw["price"], "unknown price is null, not 0")
+	assert.Nil(t, raw["floor"])
+
+	code = send(t, router, http.MethodPost, "/api/apartments", `{"address":"2 Main St","price":0,"floor":0}`, &raw)
+	assert.Equal(t, http.StatusCreated, code)
+	assert.Equal(t, 0.0, raw["price"], "free is different from unknown")
+	assert.Equal(t, 0.0, raw["floor"], "the ground floor is different from unknown")
+
+	code = send(t, router, http.MethodPost, "/api/apartments", `{"address":"3 Main St","rating":0}`, nil)
+	assert.Equal(t, http.StatusBadRequest, code, "ratings are 1-5 or null")
+}
+
+func TestApartmentPatch(t *testing.T) {
+	router := newTestRouter(t)
+	send(t, router, http.MethodPost, "/api/apartments",
+		`{"address":"1 Main St","notes":"Sunny","rating":4,"price":1500,"floor":2,"has_laundry":true,"listing_url":"https://example.com/1"}`, nil)
+
+	var patched models.Apartment
+	code := send(t, router, http.MethodPatch, "/api/apartments/1", `{"price":1450,"rating":null}`, &patched)
+	assert.Equal(t, http.StatusOK, code)
+	if assert.NotNil(t, patched.Price) {
+		assert.Equal(t, 1450.0, *patched.Price)
+	}
+	assert.Nil(t, patched.Rating, "null clears a field")
+	assert.Equal(t, "Sunny", patched.Notes, "fields left out are unchanged")
+	assert.True(t, patched.HasLaundry)
+	if assert.NotNil(t, patched.Floor) {
+		assert.Equal(t, uint(2), *patched.Floor)
+	}
+	assert.Equal(t, "https://example.com/1", patched.ListingURL)
+
+	code = send(t, router, http.MethodPatch, "/api/apartments/1", `{"listing_url":null,"floor":null}`, &patched)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Empty(t, patched.ListingURL)
+	assert.Nil(t, patched.Floor)
+	assert.NotNil(t, patched.Price)
+
+	code = send(t, router, http.MethodPatch, "/api/apartments/1", `{"unit":"4B"}`, &patched)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "4B", patched.Unit)
+	code = send(t, router, http.MethodPatch, "/api/apartments/1", `{"unit":null}`, nil)
+	assert.Equal(t, http.StatusOK, code)
+	var fetched models.Apartment
+	send(t, router, http.MethodGet, "/api/apartments/1", "", &fetched)
+	assert.Empty(t, fetched.Unit, "null clears the unit")
+	assert.Equal(t, "1 Main St", fetched.Address)
+
+	code = send(t, router, http.MethodPatch, "/api/apartments/1", `{"colour":"blue"}`, nil)
+	assert.Equal(t, http.StatusBadRequest, code, "unknown fields are rejected")
+	code = send(t, router, http.MethodPatch, "/api/apartments/1", `{"rating":9}`, nil)
+	assert.Equal(t, http.StatusBadRequest, code, "patched requests are validated")
+	code = send(t, router, http.MethodPatch, "/api/apartments/1", `{"address":null}`, nil)
+	assert.Equal(t, http.StatusBadRequest, code, "the address can't be cleared")
+	code = send(t, router, http.MethodPatch, "/api/apartments/1", `[]`, nil)
+	assert.Equal(t, http.StatusBadRequest, code)
+	code = send(t, router, http.MethodPatch, "/api/apartments/99", `{"price":1}`, nil)
+	assert.Equal(t, http.StatusNotFound, code)
+}
+
+func TestApartmentPatchRecordsChanges(t *testing.T) {
+	router, h := newTestApartmentHandler(t)
+	send(t, router, http.MethodPost, "/api/apartments", `{"address":"1 Main St","rating":4,"price":1500,"floor":2}`, nil)
+
+	assert.Equal(t, http.StatusOK, send(t, router, http.MethodPatch, "/api/apartments/1", `{"price":1450,"floor":3,"rating":5}`, nil))
+	entries, err := h.db.ListActivity(db.ActivityOptions{Limit: -1})
+	assert.NoError(t, err)
+	var actions []string
+	for _, e := range entries {
+		actions = append(actions, e.Action+" "+e.Detail)
+	}
+	assert.ElementsMatch(t, []string{"added ", "rated ", "edited price, floor"}, actions,
+		"fields the patch sets through pointers count as changed")
+}
+
+func TestApartmentUnits(t *testing.T) {
+	router := newTestRouter(t)
+
+	var first, second models.Apartment
+	send(t, router, http.MethodPost, "/api/apartments", `{"address":"123 Main St #1, Springfield, IL"}`, &first)
+	send(t, router, http.MethodPost, "/api/apartments", `{"address":"123 Main Street, Springfield, IL","unit":"Apt 2"}`, &second)
+	assert.Equal(t, "123 Main St, Springfield, IL", first.Address, "the unit is split off the address")
+	assert.Equal(t, "1", first.Unit)
+	assert.Equal(t, "2", second.Unit)
+	assert.Equal(t, "123 MAIN ST APT 1, SPRINGFIELD, IL", first.CanonicalAddress)
+	assert.Equal(t, first.BuildingAddress, second.BuildingAddress)
+
+	var duplicates []models.DuplicateGroup
+	send(t, router, http.MethodGet, "/api/apartments/duplicates", "", &duplicates)
+	assert.Empty(t, duplicates, "units of a building aren't duplicates")
+
+	var groups []models.ApartmentGroup
+	send(t, router, http.MethodGet, "/api/apartments/grouped?by=building_address", "", &groups)
+	if assert.Len(t, groups, 1) {
+		assert.Equal(t, 2, groups[0].Count)
+	}
+
+	var updated models.Apartment
+	send(t, router, http.MethodPut, "/api/apartments/1", `{"address":"123 Main St, Springfield, IL","notes":"Corner unit"}`, &updated)
+	assert.Equal(t, "1", updated.Unit, "an omitted unit is left alone")
+	send(t, router, http.MethodPatch, "/api/apartments/1", `{"unit":""}`, &updated)
+	assert.Empty(t, updated.Unit)
+	assert.Equal(t, updated.BuildingAddress, updated.CanonicalAddress)
+}
+
+func TestApartmentMarketRent(t *testing.T) {
+	router, h := newTestApartmentHandler(t)
+
+	var apt models.Apartment
+	send(t, router, http.MethodPost, "/api/apartments", `{"address":"742 Evergreen Terrace","price":1620}`, &apt)
+	_, err := h.db.CreateRoom(apt.ID, &models.RoomRequest{Name: "Bedroom", Kind: "bedroom"})
+	assert.NoError(t, err)
+	assert.NoError(t, h.db.SetMarketRent(apt.ID, 1500, "Springfield, IL MSA", 1))
+
+	send(t, router, http.MethodGet, "/api/apartments/1", "", &apt)
+	if assert.NotNil(t, apt.MarketRentDiffPct) {
+		assert.Equal(t, 8.0, *apt.MarketRentDiffPct)
+	}
+	assert.Equal(t, "8% over market", formatMarketDiff(apt.MarketRentDiffPct))
+	assert.NotNil(t, apt.MarketRentUpdatedAt)
+
+	var count struct{ Count int }
+	send(t, router, http.MethodGet, "/api/apartments/count?q=market_rent_diff_pct>5", "", &count)
+	assert.Equal(t, 1, count.Count)
+
+	_, err = h.db.CreateRoom(apt.ID, &models.RoomRequest{Name: "Kitchen", Kind: "kitchen"})
+	assert.NoError(t, err)
+	send(t, router, http.MethodGet, "/api/apartments/1", "", &apt)
+	assert.NotNil(t, apt.MarketRentUpdatedAt, "the bedroom count is the same")
+
+	_, err = h.db.CreateRoom(apt.ID, &models.RoomRequest{Name: "Bedroom 2", Kind: "bedroom"})
+	assert.NoError(t, err)
+	send(t, router, http.MethodGet, "/api/apartments/1", "", &apt)
+	assert.Nil(t, apt.MarketRentUpdatedAt, "a second bedroom makes it stale")
+	assert.Equal(t, "at market", formatMarketDiff(new(float64)))
+}
+
+func TestApartmentBuyVsRent(t *testing.T) {
+	router, h := newTestApartmentHandler(t)
+	NewCostHandler(h.db).RegisterRoutes(router)
+
+	var home, rental models.Apartment
+	send(t, router, http.MethodPost, "/api/apartments", `{"address":"1 Home Ln","listing_type":"sale","sale":{"purchase_price":400000,"hoa_fee":250}}`, &home)
+	send(t, router, http.MethodPost, "/api/apartments", `{"address":"2 Rental Rd","price":1800}`, &rental)
+	send(t, router, http.MethodPost, "/api/apartments", `{"address":"3 Gone St","price":900,"status":"rejected"}`, nil)
+	assert.Equal(t, models.ListingSale, home.ListingType)
+	assert.Equal(t, models.ListingRent, rental.ListingType, "rent by default")
+
+	var b models.BuyVsRent
+	assert.Equal(t, http.StatusOK, send(t, router, http.MethodGet, "/api/apartments/1/buy-vs-rent?down_payment=10&years=15", "", &b))
+	assert.Equal(t, 360000.0, b.LoanAmount)
+	assert.Equal(t, 15, b.Terms.Years)
+	if assert.Len(t, b.Rentals, 1, "rejected rentals are left out") {
+		assert.Equal(t, rental.ID, b.Rentals[0].ApartmentID)
+		assert.Equal(t, b.MonthlyTotal-1800, b.Rentals[0].Difference)
+	}
+
+	assert.Equal(t, http.StatusBadRequest, send(t, router, http.MethodGet, "/api/apartments/1/buy-vs-rent?rate=-1", "", nil))
+	assert.Equal(t, http.StatusUnprocessableEntity, send(t, router, http.MethodGet, "/api/apartments/2/buy-vs-rent", "", nil))
+
+	send(t, router, http.MethodPatch, "/api/apartments/1", `{"notes":"Big yard"}`, &home)
+	if assert.NotNil(t, home.Sale.PurchasePrice, "left alone when omitted") {
+		assert.Equal(t, 400000.0, *home.Sale.PurchasePrice)
+	}
+	send(t, router, http.MethodPatch, "/api/apartments/1", `{"sale":null}`, &home)
+	assert.Nil(t, home.Sale.PurchasePrice)
+	assert.Equal(t, http.StatusUnprocessableEntity, send(t, router, http.MethodGet, "/api/apartments/1/buy-vs-rent", "", nil))
+}
+
+func TestApartmentCountAndExists(t *testing.T) {
+	router := newTestRouter(t)
+	for _, body := range []string{
+		`{"address":"1 Main St","price":1200}`,
+		`{"address":"2 Main St","price":1800}`,
+		`{"address":"3 Main St","price":2400}`,
+	} {
+		send(t, router, http.MethodPost, "/api/apartments", body, nil)
+	}
+
+	var count struct{ Count int }
+	assert.Equal(t, http.StatusOK, send(t, router, http.MethodGet, "/api/apartments/count", "", &count))
+	assert.Equal(t, 3, count.Count)
+	send(t, router, http.MethodGet, "/api/apartments/count?q=price<2000&limit=1", "", &count)
+	assert.Equal(t, 2, count.Count, "filters count, pages don't")
+	send(t, router, http.MethodGet, "/api/apartments/count?search=nowhere", "", &count)
+	assert.Equal(t, 0, count.Count)
+	send(t, router, http.MethodGet, `/api/apartments/count?q=address~"%25"`, "", &count)
+	assert.Equal(t, 0, count.Count, "~ matches wildcards literally")
+	assert.Equal(t, http.StatusBadRequest, send(t, router, http.MethodGet, "/api/apartments/count?q=price<", "", nil))
+
+	head := func(url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodHead, url, nil))
+		return w
+	}
+	w := head("/api/apartments/2")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Body.String())
+	assert.Equal(t, http.StatusNotFound, head("/api/apartments/99").Code)
+	assert.Equal(t, http.StatusBadRequest, head("/api/apartments/abc").Code)
+}
+
+func TestApartmentVisibilityAcrossEndpoints(t *testing.T) {
+	router, h := newTestApartmentHandler(t)
+	database := h.db
+	NewActivityHandler(database).RegisterRoutes(router)
+	NewBoardHandler(database).RegisterRoutes(router)
+	NewCompareHandler(database).RegisterRoutes(router)
+	NewDeadlineHandler(database).RegisterRoutes(router)
+	NewSyncHandler(database).RegisterRoutes(router)
+	NewTriggerHandler(database).RegisterRoutes(router)
+	NewUsageHandler(database, 0).RegisterRoutes(router)
+
+	owner, other := createTestUser(t, database, "Alex"), createTestUser(t, database, "Sam")
+	due := time.Now().AddDate(0, 0, 3).Format(time.RFC3339)
+	var private models.Apartment
+	sendAs(t, router, owner, http.MethodPost, "/api/apartments",
+		`{"address":"1 Secret Ln","visibility":"private","price":1500,"rating":4,"application_deadline":"`+due+`"}`, &private)
+	sendAs(t, router, other, http.MethodPost, "/api/apartments", `{"address":"2 Shared St","price":1200}`, nil)
+	_, err := database.CreateAttachment(&models.Attachment{
+		ApartmentID: private.ID, Kind: models.AttachmentPhoto, Filename: "kitchen.jpg", ContentType: "image/jpeg", Size: 100,
+	})
+	assert.NoError(t, err)
+
+	get := func(userID int64, url string) string {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		req.Header.Set("X-User-ID", strconv.FormatInt(userID, 10))
+		router.ServeHTTP(w, req)
+		return w.Body.String()
+	}
+
+	// Each endpoint shows the owner something about the apartment that no
+	// one else may see
+	endpoints := []struct{ url, private string }{
+		{"/api/apartments", "Secret"},
+		{"/api/apartments/count", `"count":2`},
+		{"/api/apartments/grouped", "Secret"},
+		{"/api/apartments/aggregate?group_by=status", `"count":2`},
+		{"/api/apartments/ranked", "Secret"},
+		{"/api/apartments/suggest?q=Secret", "Secret"},
+		{"/api/apartments/decision-matrix", "Secret"},
+		{"/api/apartments/decision-matrix.csv", "Secret"},
+		{"/api/activity", "Secret"},
+		{"/api/deadlines", "Secret"},
+		{"/api/board", "Secret"},
+		{"/api/compare?ids=" + strconv.FormatInt(private.ID, 10), "Secret"},
+		{"/api/sync", "Secret"},
+		{"/api/triggers/new_apartment", "Secret"},
+		{"/api/settings/usage/largest", "kitchen.jpg"},
+	}
+	for _, e := range endpoints {
+		assert.Contains(t, get(owner, e.url), e.private, "the owner sees it in %s", e.url)
+		assert.NotContains(t, get(other, e.url), e.private, "others don't see it in %s", e.url)
+	}
+}
+
+func TestApartmentDefaultVisibility(t *testing.T) {
+	router, h := newTestApartmentHandler(t)
+	NewCompareHandler(h.db).RegisterRoutes(router)
+
+	var apartment models.Apartment
+	send(t, router, http.MethodPost, "/api/apartments", `{"address":"1 Main St"}`, &apartment)
+	assert.Equal(t, models.VisibilityHousehold, apartment.Visibility)
+	share := `{"ids":[` + strconv.FormatInt(apartment.ID, 10) + `]}`
+	assert.Equal(t, http.StatusBadRequest, send(t, router, http.MethodPost, "/api/compare/share", share, nil), "not shared until chosen")
+
+	url := "/api/apartments/" + strconv.FormatInt(apartment.ID, 10)
+	assert.Equal(t, http.StatusOK, send(t, router, http.MethodPatch, url, `{"visibility":"shared"}`, &apartment))
+	assert.Equal(t, models.VisibilityShared, apartment.Visibility)
+	assert.Equal(t, http.StatusCreated, send(t, router, http.MethodPost, "/api/compare/share", share, nil))
+
+	assert.Equal(t, http.StatusOK, send(t, router, http.MethodPatch, url, `{"notes":"Sunny"}`, &apartment))
+	assert.Equal(t, models.VisibilityShared, apartment.Visibility, "kept when a patch leaves it out")
+}
+
+func TestApartmentListSortedPages(t *testing.T) {
+	router := newTestRouter(t)
+	for _, price := range []string{"1800", "1200", "1500", "900", "2100"} {
+		send(t, router, http.MethodPost, "/api/apartments", `{"address":"`+price+` Main St","price":`+price+`}`, nil)
+	}
+
+	var addresses []string
+	next := "/api/apartments?sort=price&limit=2"
+	for next != "" {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, next, nil))
+		if !assert.Equal(t, http.StatusOK, w.Code, "following %s: %s", next, w.Body.String()) {
+			break
+		}
+		var page []models.Apartment
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+		for _, a := range page {
+			addresses = append(addresses, a.Address)
+		}
+		assert.Empty(t, w.Header().Get("X-Next-Cursor"), "cursors follow the default order")
+
+		next = ""
+		if link := w.Header().Get("Link"); link != "" {
+			next = strings.TrimPrefix(strings.TrimSuffix(link, `>; rel="next"`), "<")
+		}
+	}
+	assert.Equal(t, []string{"900 Main St", "1200 Main St", "1500 Main St", "1800 Main St", "2100 Main St"}, addresses)
+}
+
+func TestApartmentListCursorPages(t *testing.T) {
+	router, h := newTestApartmentHandler(t)
+	var ids []int64
+	for i := 1; i <= 7; i++ {
+		var apartment models.Apartment
+		send(t, router, http.MethodPost, "/api/apartments", `{"address":"`+strconv.Itoa(i)+` Main St"}`, &apartment)
+		ids = append(ids, apartment.ID)
+	}
+	// Pages of two end both inside and at the edge of runs of apartments
+	// created in the same second
+	for i, createdAt := range []string{
+		"2026-01-01 10:00:00", "2026-01-01 10:00:00", "2026-01-01 10:00:00",
+		"2026-01-02 10:00:00", "2026-01-02 10:00:00", "2026-01-02 10:00:00",
+		"2026-01-03 10:00:00",
+	} {
+		_, err := h.db.Exec("UPDATE apartments SET created_at = ? WHERE id = ?", createdAt, ids[i])
+		assert.NoError(t, err)
+	}
+
+	var seen []int64
+	next := "/api/apartments?limit=2"
+	for pages := 0; next != ""; pages++ {
+		if !assert.Less(t, pages, len(ids), "too many pages") {
+			break
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, next, nil))
+		if !assert.Equal(t, http.StatusOK, w.Code, "following %s: %s", next, w.Body.String()) {
+			break
+		}
+		var page []models.Apartment
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+		for _, a := range page {
+			seen = append(seen, a.ID)
+		}
+
+		next = ""
+		if link := w.Header().Get("Link"); link != "" {
+			next = strings.TrimPrefix(strings.TrimSuffix(link, `>; rel="next"`), "<")
+			assert.Contains(t, next, "cursor="+w.Header().Get("X-Next-Cursor"))
+		}
+	}
+	assert.Equal(t, []int64{ids[6], ids[5], ids[4], ids[3], ids[2], ids[1], ids[0]}, seen, "every apartment once, newest first")
+
+	tampered := func(payload string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(payload))
+	}
+	valid := db.Cursor{CreatedAt: time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC), ID: ids[4]}.Encode()
+	for _, query := range []string{
+		"cursor=not*base64",
+		"cursor=" + tampered(`{"c":"2026-01-02","i"`),
+		"cursor=" + tampered(`{"c":"yesterday","i":3}`),
+		"cursor=" + tampered(`{"c":"2026-01-02 10:00:00","i":0}`),
+		"cursor=" + valid + "&offset=2",
+		"cursor=" + valid + "&sort=price",
+	} {
+		assert.Equal(t, http.StatusBadRequest, send(t, router, http.MethodGet, "/api/apartments?"+query, "", nil), query)
+	}
+	var page []models.Apartment
+	assert.Equal(t, http.StatusOK, send(t, router, http.MethodGet, "/api/apartments?cursor="+valid, "", &page))
+	if assert.Len(t, page, 4) {
+		assert.Equal(t, ids[3], page[0].ID)
+	}
+}
+
+func TestApartmentFields(t *testing.T) {
+	router := newTestRouter(t)
+	var apartment models.Apartment
+	send(t, router, http.MethodPost, "/api/apartments",
+		`{"address":"1 Main St","price":1500,"parking":{"type":"garage","monthly_cost":75}}`, &apartment)
+	url := "/api/apartments/" + strconv.FormatInt(apartment.ID, 10)
+
+	var one map[string]any
+	assert.Equal(t, http.StatusOK, send(t, router, http.MethodGet, url+"?fields=address,price", "", &one))
+	assert.Equal(t, map[string]any{"address": "1 Main St", "price": 1500.0}, one)
+
+	var list []map[string]any
+	assert.Equal(t, http.StatusOK, send(t, router, http.MethodGet, "/api/apartments?fields=id,address", "", &list))
+	assert.Equal(t, []map[string]any{{"id": float64(apartment.ID), "address": "1 Main St"}}, list)
+
+	// Keys holding objects come back whole
+	var nested map[string]any
+	assert.Equal(t, http.StatusOK, send(t, router, http.MethodGet, url+"?fields=parking", "", &nested))
+	assert.Equal(t, map[string]any{"parking": map[string]any{
+		"type": "garage", "monthly_cost": 75.0, "ev_charging": false, "distance_m": nil,
+	}}, nested)
+
+	for _, fields := range []string{"nope", "address,nope", "parking.type", "Address"} {
+		var refused struct{ Error string }
+		assert.Equal(t, http.StatusBadRequest, send(t, router, http.MethodGet, url+"?fields="+fields, "", &refused), fields)
+		assert.Contains(t, refused.Error, "Invalid fields", fields)
+		assert.Equal(t, http.StatusBadRequest, send(t, router, http.MethodGet, "/api/apartments?fields="+fields, "", nil), fields)
+	}
+}
+
+func TestApartmentNotModified(t *testing.T) {
+	router, h := newTestApartmentHandler(t)
+	var apartment models.Apartment
+	send(t, router, http.MethodPost, "/api/apartments", `{"address":"1 Main St"}`, &apartment)
+	url := "/api/apartments/" + strconv.FormatInt(apartment.ID, 10)
+	// Updates within the same second as the last change couldn't be told apart
+	_, err := h.db.Exec("UPDATE apartments SET updated_at = '2026-01-01 10:00:00' WHERE id = ?", apartment.ID)
+	assert.NoError(t, err)
+
+	get := func(url string, since string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		if since != "" {
+			req.Header.Set("If-Modified-Since", since)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	for _, url := range []string{url, "/api/apartments"} {
+		w := get(url, "")
+		assert.Equal(t, http.StatusOK, w.Code, url)
+		modified := w.Header().Get("Last-Modified")
+		assert.Equal(t, "Thu, 01 Jan 2026 10:00:00 GMT", modified, url)
+
+		for _, since := range []string{modified, "Thu, 01 Jan 2026 10:00:01 GMT", "Fri, 02 Jan 2026 00:00:00 GMT"} {
+			w := get(url, since)
+			assert.Equal(t, http.StatusNotModified, w.Code, "%s since %s", url, since)
+			assert.Empty(t, w.Body.String())
+		}
+		for _, since := range []string{"Thu, 01 Jan 2026 09:59:59 GMT", "yesterday"} {
+			assert.Equal(t, http.StatusOK, get(url, since).Code, "%s since %s", url, since)
+		}
+	}
+
+	assert.Equal(t, http.StatusOK, send(t, router, http.MethodPatch, url, `{"notes":"Quiet street"}`, nil))
+	for _, url := range []string{url, "/api/apartments"} {
+		w := get(url, "Thu, 01 Jan 2026 10:00:00 GMT")
+		assert.Equal(t, http.StatusOK, w.Code, "%s after an update", url)
+		assert.Contains(t, w.Body.String(), "Quiet street")
+		assert.NotEqual(t, "Thu, 01 Jan 2026 10:00:00 GMT", w.Header().Get("Last-Modified"))
+	}
+}
+
+func TestApartmentDeleteDependents(t *testing.T) {
+	router, h := newTestApartmentHandler(t)
+	database, store := h.db, h.store
+
+	var apartment models.Apartment
+	send(t, router, http.MethodPost, "/api/apartments", `{"address":"1 Main St"}`, &apartment)
+	_, err := database.CreateVisit(apartment.ID, &models.VisitRequest{Notes: "Loud street"})
+	assert.NoError(t, err)
+	photo, err := database.CreateAttachment(&models.Attachment{
+		ApartmentID: apartment.ID, Kind: models.AttachmentPhoto, Filename: "kitchen.jpg", ContentType: "image/jpeg",
+	})
+	assert.NoError(t, err)
+	_, err = store.Put(attachmentKey(photo), strings.NewReader("jpeg"))
+	assert.NoError(t, err)
+
+	url := "/api/apartments/" + strconv.FormatInt(apartment.ID, 10)
+	var refused struct {
+		Error      string
+		Dependents map[string]int
+	}
+	assert.Equal(t, http.StatusConflict, send(t, router, http.MethodDelete, url, "", &refused))
+	assert.Equal(t, map[string]int{"visits": 1, "attachments": 1}, refused.Dependents)
+	assert.Equal(t, http.StatusOK, send(t, router, http.MethodGet, url, "", nil), "not deleted")
+	deletions := func() int {
+		entries, err := database.ListActivity(db.ActivityOptions{Actions: []string{models.ActivityDeleted}, Limit: -1})
+		assert.NoError(t, err)
+		return len(entries)
+	}
+	assert.Zero(t, deletions(), "a refused delete isn't recorded")
+
+	assert.Equal(t, http.StatusOK, send(t, router, http.MethodDelete, url+"?force=true", "", nil))
+	assert.Equal(t, 1, deletions())
+	assert.Equal(t, http.StatusNotFound, send(t, router, http.MethodGet, url, "", nil))
+	dependents, err := database.ApartmentDependents(apartment.ID)
+	assert.NoError(t, err)
+	assert.Empty(t, dependents, "deleted with it")
+	_, err = store.Open(attachmentKey(photo))
+	assert.ErrorIs(t, err, os.ErrNotExist, "file removed")
+
+	var bare models.Apartment
+	send(t, router, http.MethodPost, "/api/apartments", `{"address":"2 Main St"}`, &bare)
+	url = "/api/apartments/" + strconv.FormatInt(bare.ID, 10)
+	assert.Equal(t, http.StatusOK, send(t, router, http.MethodDelete, url, "", nil), "nothing to lose")
+	assert.Equal(t, http.StatusNotFound, send(t, router, http.MethodDelete, url, "", nil))
+	assert.Equal(t, 2, deletions(), "deleting a missing apartment isn't recorded")
+}
+
+func TestApartmentEraseOwnerRecords(t *testing.T) {
+	router, h := newTestApartmentHandler(t)
+	NewUserHandler(h.db, h.store).RegisterRoutes(router)
+	database, store := h.db, h.store
+
+	owner := createTestUser(t, database, "Alex")
+	var private models.Apartment
+	sendAs(t, router, owner, http.MethodPost, "/api/apartments", `{"address":"1 Secret Ln","visibility":"private"}`, &private)
+	_, err := database.CreateVisit(private.ID, &models.VisitRequest{Notes: "Loud street"})
+	assert.NoError(t, err)
+	_, err = database.CreateOffer(private.ID, &models.OfferRequest{Amount: 1400, Who: "us"})
+	assert.NoError(t, err)
+	_, err = database.CreateRoom(private.ID, &models.RoomRequest{Name: "Bedroom", Kind: "bedroom"})
+	assert.NoError(t, err)
+	_, err = database.SetMoveInCosts(private.ID, &models.MoveInCostsRequest{SecurityDeposit: 1400})
+	assert.NoError(t, err)
+	photo, err := database.CreateAttachment(&models.Attachment{
+		ApartmentID: private.ID, Kind: models.AttachmentPhoto, Filename: "kitchen.jpg", ContentType: "image/jpeg",
+	})
+	assert.NoError(t, err)
+	_, err = store.Put(attachmentKey(photo), strings.NewReader("jpeg"))
+	assert.NoError(t, err)
+	_, err = store.Put(floorPlanKey(private.ID), strings.NewReader("pdf"))
+	assert.NoError(t, err)
+
+	var erasure models.UserErasure
+	url := "/api/users/" + strconv.FormatInt(owner, 10) + "/erase"
+	assert.Equal(t, http.StatusOK, sendAs(t, router, owner, http.MethodPost, url, "", &erasure))
+	assert.Equal(t, int64(1), erasure.ApartmentsDeleted)
+
+	dependents, err := database.ApartmentDependents(private.ID)
+	assert.NoError(t, err)
+	assert.Empty(t, dependents, "nothing recorded about it is left behind")
+	_, err = store.Open(attachmentKey(photo))
+	assert.ErrorIs(t, err, os.ErrNotExist, "photo removed")
+	_, err = store.Open(floorPlanKey(private.ID))
+	assert.ErrorIs(t, err, os.ErrNotExist, "floor plan removed")
+}
+
+func TestApartmentUniqueAddresses(t *testing.T) {
+	router, h := newTestApartmentHandler(t)
+	var first models.Apartment
+	send(t, router, http.MethodPost, "/api/apartments", `{"address":"12 Elm Street, Springfield","unit":"4B"}`, &first)
+	send(t, router, http.MethodPost, "/api/apartments", `{"address":"12 Elm St Apt 4B, Springfield"}`, nil)
+	assert.Error(t, h.db.RequireUniqueAddresses(true), "already entered twice")
+
+	router, h = newTestApartmentHandler(t)
+	assert.NoError(t, h.db.RequireUniqueAddresses(true))
+	send(t, router, http.MethodPost, "/api/apartments", `{"address":"12 Elm Street, Springfield","unit":"4B"}`, &first)
+	var conflict struct {
+		Error      string
+		ExistingID int64 `json:"existing_id"`
+	}
+	assert.Equal(t, http.StatusConflict, send(t, router, http.MethodPost, "/api/apartments", `{"address":"12 Elm St Apt 4B, Springfield"}`, &conflict))
+	assert.Equal(t, first.ID, conflict.ExistingID)
+
+	var other models.Apartment
+	assert.Equal(t, http.StatusCreated, send(t, router, http.MethodPost, "/api/apartments", `{"address":"12 Elm St, Springfield","unit":"5A"}`, &other), "another unit")
+	url := "/api/apartments/" + strconv.FormatInt(other.ID, 10)
+	assert.Equal(t, http.StatusConflict, send(t, router, http.MethodPatch, url, `{"unit":"4B"}`, nil))
+
+	assert.NoError(t, h.db.RequireUniqueAddresses(false))
+	assert.Equal(t, http.StatusCreated, send(t, router, http.MethodPost, "/api/apartments", `{"address":"12 Elm St #4B, Springfield"}`, nil))
+}
+
+func TestApartmentUUIDs(t *testing.T) {
+	router := newTestRouter(t)
+	var first, second models.Apartment
+	send(t, router, http.MethodPost, "/api/apartments", `{"address":"1 Main St"}`, &first)
+	send(t, router, http.MethodPost, "/api/apartments", `{"address":"2 Main St"}`, &second)
+	assert.Regexp(t, `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, first.UUID)
+	assert.NotEqual(t, first.UUID, second.UUID)
+
+	var got models.Apartment
+	assert.Equal(t, http.StatusOK, send(t, router, http.MethodGet, "/api/apartments/"+second.UUID, "", &got))
+	assert.Equal(t, second.ID, got.ID)
+	assert.Equal(t, http.StatusOK, send(t, router, http.MethodGet, "/api/apartments/"+strings.ToUpper(second.UUID), "", nil))
+	assert.Equal(t, http.StatusOK, send(t, router, http.MethodPatch, "/api/apartments/"+first.UUID, `{"notes":"Sunny"}`, &got))
+	assert.Equal(t, "Sunny", got.Notes)
+
+	var listed []models.Apartment
+	send(t, router, http.MethodGet, "/api/apartments?q=uuid=\""+first.UUID+"\"", "", &listed)
+	assert.Len(t, listed, 1)
+
+	assert.Equal(t, http.StatusNotFound, send(t, router, http.MethodGet, "/api/apartments/00000000-0000-4000-8000-000000000000", "", nil))
+	assert.Equal(t, http.StatusOK, send(t, router, http.MethodDelete, "/api/apartments/"+first.UUID, "", nil))
+	assert.Equal(t, http.StatusNotFound, send(t, router, http.MethodGet, "/api/apartments/"+first.UUID, "", nil))
+}
+
+func TestApartmentCreateWhileLocked(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dir := t.TempDir()
+	database, err := db.New(dir)
+	assert.NoError(t, err)
+	defer database.Close()
+	router := gin.New()
+	NewApartmentHandler(database, nil).RegisterRoutes(router)
+
+	// Another process holds the write lock for longer than SQLite waits
+	other, err := sql.Open("sqlite3", filepath.Join(dir, "apartments.db"))
+	assert.NoError(t, err)
+	defer other.Close()
+	conn, err := other.Conn(context.Background())
+	assert.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.ExecContext(context.Background(), "BEGIN IMMEDIATE")
+	assert.NoError(t, err)
+	go func() {
+		time.Sleep(600 * time.Millisecond)
+		conn.ExecContext(context.Background(), "ROLLBACK")
+	}()
+
+	retries := func() int64 {
+		if v, ok := metrics.DB.Get("busy_retries").(*expvar.Int); ok {
+			return v.Value()
+		}
+		return 0
+	}
+	before := retries()
+	assert.Equal(t, http.StatusCreated, send(t, router, http.MethodPost, "/api/apartments", `{"address":"1 Main St"}`, nil))
+	assert.Greater(t, retries(), before, "the write waited for the lock")
+}
+
+func TestWebhookDraft(t *testing.T) {
+	router, h := newTestApartmentHandler(t)
+	NewWebhookHandler(h.db, map[string]*webhook.Source{
+		"alerts": {
+			Name:            "alerts",
+			Secret:          "s3cret",
+			SignatureHeader: "X-Hub-Signature-256",
+			Fields:          map[string]string{"address": "home.address", "price": "home.rent", "listing_url": "url"},
+		},
+	}).RegisterRoutes(router)
+
+	post := func(name, body, signature string) (int, map[string]any) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/inbound/webhooks/"+name, strings.NewReader(body))
+		req.Header.Set("X-Hub-Signature-256", signature)
+		router.ServeHTTP(w, req)
+		var out map[string]any
+		json.Unmarshal(w.Body.Bytes(), &out)
+		return w.Code, out
+	}
+
+	body := `{"home": {"address": "742 Evergreen Terrace", "rent": 1850}, "url": "https://listings.example.com/l/1"}`
+	code, _ := post("alerts", body, webhook.Sign("wrong", []byte(body)))
+	assert.Equal(t, http.StatusUnauthorized, code)
+	code, _ = post("other", body, webhook.Sign("s3cret", []byte(body)))
+	assert.Equal(t, http.StatusNotFound, code)
+
+	code, apartment := post("alerts", body, webhook.Sign("s3cret", []byte(body)))
+	assert.Equal(t, http.StatusCreated, code)
+	assert.Equal(t, "742 Evergreen Terrace", apartment["address"])
+	assert.Equal(t, models.StatusDraft, apartment["status"])
+	assert.Equal(t, 1850.0, apartment["price"])
+	assert.Equal(t, "https://listings.example.com/l/1", apartment["listing_url"])
+
+	body = `{"home": {"rent": 900}}`
+	code, _ = post("alerts", body, webhook.Sign("s3cret", []byte(body)))
+	assert.Equal(t, http.StatusUnprocessableEntity, code, "nothing to identify the listing by")
+
+	body = `{"url": "not a url"}`
+	code, _ = post("alerts", body, webhook.Sign("s3cret", []byte(body)))
+	assert.Equal(t, http.StatusUnprocessableEntity, code)
+}
@@ -0,0 +1,427 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mojotx/apt-eval/db"
+	"github.com/mojotx/apt-eval/enrich"
+	"github.com/mojotx/apt-eval/media"
+	"github.com/mojotx/apt-eval/models"
+	"github.com/mojotx/apt-eval/scan"
+	"github.com/mojotx/apt-eval/storage"
+	"github.com/rs/zerolog/log"
+)
+
+// photoNearMeters is how far from an apartment's geocoded position a photo
+// can be taken and still count as taken there, allowing for GPS error and
+// large complexes
+const photoNearMeters = 200
+
+// AttachmentHandler handles the photos and documents of apartments
+type AttachmentHandler struct {
+	db         *db.DB
+	store      *storage.Store
+	scanner    scan.Scanner // nil when uploads aren't virus scanned
+	limits     UploadLimits
+	extractGPS bool // Keep photos' GPS positions when stripping their metadata
+}
+
+// NewAttachmentHandler creates a new attachment handler
+func NewAttachmentHandler(db *db.DB, store *storage.Store, scanner scan.Scanner, limits UploadLimits, extractGPS bool) *AttachmentHandler {
+	return &AttachmentHandler{
+		db:         db,
+		store:      store,
+		scanner:    scanner,
+		limits:     limits,
+		extractGPS: extractGPS,
+	}
+}
+
+// checkLocation compares where a photo was taken with the apartment's
+// geocoded position
+func checkLocation(apartment *models.Apartment, a *models.Attachment) {
+	loc := a.Location
+	if loc == nil || apartment.Latitude == nil || apartment.Longitude == nil {
+		return
+	}
+	d := math.Round(enrich.DistanceMeters(*apartment.Latitude, *apartment.Longitude, loc.Latitude, loc.Longitude))
+	near := d <= photoNearMeters
+	loc.DistanceM, loc.NearApartment = &d, &near
+}
+
+// attachmentKey is the storage key of an attachment's file, under the
+// quarantine when it's infected
+func attachmentKey(a *models.Attachment) string {
+	key := "attachments/" + strconv.FormatInt(a.ID, 10)
+	if a.ScanStatus == scan.StatusInfected {
+		key = quarantinePrefix + key
+	}
+	return key
+}
+
+// attachmentThumbnailKey is the storage key of the cached thumbnail of a
+// photo
+func attachmentThumbnailKey(a *models.Attachment) string {
+	return attachmentKey(a) + ".thumb.jpg"
+}
+
+// coverPhotoURL is where the thumbnail of an apartment's cover photo is
+// served, naming the apartment by its ID or UUID
+func coverPhotoURL(apartment string, photoID int64) string {
+	return fmt.Sprintf("/api/apartments/%s/attachments/%d/thumbnail", apartment, photoID)
+}
+
+// loadAttachment resolves the :attachment_id path parameter within the
+// apartment named by :id, responding with 400 or 404 on failure
+func (h *AttachmentHandler) loadAttachment(c *gin.Context) (*models.Attachment, bool) {
+	apartment, ok := loadApartment(c, h.db)
+	if !ok {
+		return nil, false
+	}
+	id, ok := parseID(c, "attachment_id", "attachment")
+	if !ok {
+		return nil, false
+	}
+
+	a, err := h.db.GetAttachment(apartment.ID, id)
+	if err != nil {
+		log.Error().Err(err).Int64("apartment_id", apartment.ID).Int64("id", id).Msg("Failed to get attachment")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get attachment"})
+		return nil, false
+	}
+	if a == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+		return nil, false
+	}
+	checkLocation(apartment, a)
+	return a, true
+}
+
+// List handles retrieving an apartment's attachments, including
+// quarantined ones so their scan status can be seen
+func (h *AttachmentHandler) List(c *gin.Context) {
+	apartment, ok := loadApartment(c, h.db)
+	if !ok {
+		return
+	}
+
+	attachments, err := h.db.ListAttachments(apartment.ID)
+	if err != nil {
+		log.Error().Err(err).Int64("apartment_id", apartment.ID).Msg("Failed to list attachments")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list attachments"})
+		return
+	}
+	for i := range attachments {
+		checkLocation(apartment, &attachments[i])
+	}
+	respond(c, http.StatusOK, attachments, nil)
+}
+
+// Upload handles attaching a photo or document sent as the multipart
+// field "file", with an optional "caption" field, within the configured
+// limits on size, type, number of attachments, and storage used. Files the
+// virus scanner flags are recorded and quarantined, and the upload is
+// answered with 422. Photos are stored without their EXIF and similar
+// metadata.
+func (h *AttachmentHandler) Upload(c *gin.Context) {
+	apartment, ok := loadApartment(c, h.db)
+	if !ok {
+		return
+	}
+	apartmentID := apartment.ID
+
+	if limit := h.limits.MaxPerApartment; limit > 0 {
+		n, err := h.db.CountAttachments(apartmentID)
+		if err != nil {
+			log.Error().Err(err).Int64("apartment_id", apartmentID).Msg("Failed to count attachments")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count attachments"})
+			return
+		}
+		if n >= limit {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Apartment already has the maximum of %d attachments", limit)})
+			return
+		}
+	}
+
+	data, filename, ok := readUpload(c, h.limits.MaxSize, "Attachment")
+	if !ok {
+		return
+	}
+	caption := strings.TrimSpace(c.PostForm("caption"))
+	if len(caption) > maxCaptionLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Caption is longer than %d characters", maxCaptionLength)})
+		return
+	}
+
+	// The type is judged from the content, whatever the name or header
+	// claims
+	contentType := media.Sniff(data)
+	if !h.limits.allows(contentType) {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{
+			"error":         fmt.Sprintf("Files of type %s are not allowed", contentType),
+			"allowed_types": h.limits.AllowedTypes,
+		})
+		return
+	}
+	info, err := media.Inspect(data)
+	if errors.Is(err, media.ErrUnsupportedType) {
+		info, err = &media.Info{ContentType: contentType}, nil
+	}
+	if err != nil {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Unreadable " + contentType + " file: " + err.Error()})
+		return
+	}
+
+	if !withinQuota(c, h.db, h.limits.Quota, int64(len(data)), 0) {
+		return
+	}
+	verdict, ok := scanUpload(c, h.scanner, data)
+	if !ok {
+		return
+	}
+
+	// Quarantined files are kept exactly as they arrived
+	var gps *media.GPS
+	if !verdict.infected() {
+		if data, gps, err = media.StripMetadata(data); err != nil {
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Unreadable " + contentType + " file: " + err.Error()})
+			return
+		}
+	}
+
+	a := &models.Attachment{
+		ApartmentID:   apartmentID,
+		Kind:          models.AttachmentDocument,
+		Filename:      filename,
+		Caption:       caption,
+		ContentType:   info.ContentType,
+		Size:          int64(len(data)),
+		ScanStatus:    verdict.status,
+		ScanSignature: verdict.signature,
+		ScannedAt:     verdict.scannedAt,
+	}
+	if info.IsImage() {
+		a.Kind = models.AttachmentPhoto
+	}
+	if info.Width > 0 {
+		a.Width, a.Height = &info.Width, &info.Height
+	}
+	if info.Pages > 0 {
+		a.Pages = &info.Pages
+	}
+	if gps != nil && h.extractGPS {
+		a.Location = &models.PhotoLocation{Latitude: gps.Latitude, Longitude: gps.Longitude}
+	}
+
+	a, err = h.db.CreateAttachment(a)
+	if err != nil {
+		log.Error().Err(err).Int64("apartment_id", apartmentID).Msg("Failed to create attachment")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create attachment"})
+		return
+	}
+	if _, err := h.store.Put(attachmentKey(a), bytes.NewReader(data)); err != nil {
+		log.Error().Err(err).Int64("apartment_id", apartmentID).Msg("Failed to store attachment")
+		if err := h.db.DeleteAttachment(apartmentID, a.ID); err != nil {
+			log.Error().Err(err).Int64("id", a.ID).Msg("Failed to remove unstored attachment")
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store attachment"})
+		return
+	}
+
+	if verdict.infected() {
+		log.Warn().Int64("apartment_id", apartmentID).Int64("id", a.ID).Str("signature", verdict.signature).
+			Msg("Quarantined infected attachment")
+		respondInfected(c, verdict, gin.H{"attachment": a})
+		return
+	}
+	checkLocation(apartment, a)
+	recordActivity(h.db, viewerID(c), models.ActivityUploaded, apartmentID, a.Filename)
+	c.JSON(http.StatusCreated, a)
+}
+
+// Get handles retrieving an attachment's metadata
+func (h *AttachmentHandler) Get(c *gin.Context) {
+	a, ok := h.loadAttachment(c)
+	if !ok {
+		return
+	}
+	respond(c, http.StatusOK, a, nil)
+}
+
+// Update handles changing an attachment's caption
+func (h *AttachmentHandler) Update(c *gin.Context) {
+	a, ok := h.loadAttachment(c)
+	if !ok {
+		return
+	}
+
+	var request models.AttachmentRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Error().Err(err).Msg("Failed to bind request")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	id := a.ID
+	a, err := h.db.UpdateAttachmentCaption(a.ApartmentID, id, strings.TrimSpace(request.Caption))
+	if err != nil {
+		log.Error().Err(err).Int64("id", id).Msg("Failed to update attachment")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update attachment"})
+		return
+	}
+	if a == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+		return
+	}
+	c.JSON(http.StatusOK, a)
+}
+
+// File handles downloading an attachment. Quarantined files are never
+// served, and only images and PDFs are shown inline so other allowed
+// types can't run as pages of the app.
+func (h *AttachmentHandler) File(c *gin.Context) {
+	a, ok := h.loadAttachment(c)
+	if !ok {
+		return
+	}
+	if a.ScanStatus == scan.StatusInfected {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Attachment is quarantined: malware detected (" + a.ScanSignature + ")"})
+		return
+	}
+
+	f, err := h.store.Open(attachmentKey(a))
+	if err != nil {
+		log.Error().Err(err).Int64("id", a.ID).Msg("Failed to open attachment")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open attachment"})
+		return
+	}
+	defer f.Close()
+
+	disposition := "attachment"
+	if strings.HasPrefix(a.ContentType, "image/") || a.ContentType == "application/pdf" {
+		disposition = "inline"
+	}
+	c.Header("Content-Type", a.ContentType)
+	c.Header("Content-Disposition", fmt.Sprintf("%s; filename=%q", disposition, a.Filename))
+	c.Header("X-Content-Type-Options", "nosniff")
+	http.ServeContent(c.Writer, c.Request, a.Filename, a.UploadedAt, f)
+}
+
+// Thumbnail handles downloading a small JPEG preview of a photo, rendered
+// on first request
+func (h *AttachmentHandler) Thumbnail(c *gin.Context) {
+	a, ok := h.loadAttachment(c)
+	if !ok {
+		return
+	}
+	if a.ScanStatus == scan.StatusInfected || a.Width == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Photo not found"})
+		return
+	}
+	serveThumbnail(c, h.store, attachmentKey(a), attachmentThumbnailKey(a), a.UploadedAt)
+}
+
+// Reorder handles arranging an apartment's gallery. The listed
+// attachments come first, in order, followed by the rest as they were.
+func (h *AttachmentHandler) Reorder(c *gin.Context) {
+	apartmentID, ok := findApartment(c, h.db)
+	if !ok {
+		return
+	}
+
+	var request models.AttachmentOrderRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Error().Err(err).Msg("Failed to bind request")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.db.ReorderAttachments(apartmentID, request.IDs); err != nil {
+		var unknown *db.UnknownAttachmentError
+		if errors.As(err, &unknown) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Attachment %d not found", unknown.ID)})
+			return
+		}
+		log.Error().Err(err).Int64("apartment_id", apartmentID).Msg("Failed to reorder attachments")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reorder attachments"})
+		return
+	}
+
+	attachments, err := h.db.ListAttachments(apartmentID)
+	if err != nil {
+		log.Error().Err(err).Int64("apartment_id", apartmentID).Msg("Failed to list attachments")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list attachments"})
+		return
+	}
+	c.JSON(http.StatusOK, attachments)
+}
+
+// SetCover handles choosing the photo that represents an apartment in
+// lists, comparisons, and reports
+func (h *AttachmentHandler) SetCover(c *gin.Context) {
+	a, ok := h.loadAttachment(c)
+	if !ok {
+		return
+	}
+	if a.Kind != models.AttachmentPhoto || a.ScanStatus == scan.StatusInfected {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only photos that passed the virus scan can be the cover"})
+		return
+	}
+
+	if err := h.db.SetCoverPhoto(a.ApartmentID, a.ID); err != nil {
+		log.Error().Err(err).Int64("id", a.ID).Msg("Failed to set cover photo")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set cover photo"})
+		return
+	}
+	a.IsCover = true
+	c.JSON(http.StatusOK, a)
+}
+
+// Delete handles removing an attachment, quarantined or not
+func (h *AttachmentHandler) Delete(c *gin.Context) {
+	a, ok := h.loadAttachment(c)
+	if !ok {
+		return
+	}
+
+	if err := h.db.DeleteAttachment(a.ApartmentID, a.ID); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+			return
+		}
+		log.Error().Err(err).Int64("id", a.ID).Msg("Failed to delete attachment")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete attachment"})
+		return
+	}
+
+	if err := h.store.Delete(attachmentKey(a)); err != nil {
+		log.Warn().Err(err).Int64("id", a.ID).Msg("Failed to remove attachment file")
+	}
+	if err := h.store.Delete(attachmentThumbnailKey(a)); err != nil {
+		log.Warn().Err(err).Int64("id", a.ID).Msg("Failed to remove attachment thumbnail")
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success"})
+}
+
+// RegisterRoutes registers all attachment routes
+func (h *AttachmentHandler) RegisterRoutes(router *gin.Engine) {
+	attachments := router.Group("/api/apartments/:id/attachments")
+	{
+		attachments.GET("", h.List)
+		attachments.POST("", h.Upload)
+		attachments.PUT("/order", h.Reorder)
+		attachments.GET("/:attachment_id", h.Get)
+		attachments.PUT("/:attachment_id", h.Update)
+		attachments.GET("/:attachment_id/file", h.File)
+		attachments.GET("/:attachment_id/thumbnail", h.Thumbnail)
+		attachments.PUT("/:attachment_id/cover", h.SetCover)
+		attachments.DELETE("/:attachment_id", h.Delete)
+	}
+}
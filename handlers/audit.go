@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mojotx/apt-eval/db"
+	"github.com/mojotx/apt-eval/models"
+	"github.com/rs/zerolog/log"
+)
+
+// AuditHandler handles the admin view of the activity feed, which keeps
+// every change to every apartment. Its routes are kept to admins by
+// AdminOnly; should anyone else reach them, entries about apartments
+// private to others are left out.
+type AuditHandler struct {
+	db *db.DB
+}
+
+// NewAuditHandler creates a new audit handler
+func NewAuditHandler(db *db.DB) *AuditHandler {
+	return &AuditHandler{
+		db: db,
+	}
+}
+
+// auditHeader is the header row of the CSV export
+var auditHeader = []string{"id", "created_at", "user_id", "user", "action", "apartment_id", "address", "detail"}
+
+// List handles retrieving the audit trail, newest first, filtered by user,
+// apartment, action, and date range. The .csv path exports every matching
+// entry at once; JSON is paged like the activity feed.
+func (h *AuditHandler) List(c *gin.Context) {
+	opts, ok := auditOptions(c)
+	if !ok {
+		return
+	}
+	export := strings.HasSuffix(c.Request.URL.Path, ".csv")
+	if export {
+		opts.Before, opts.Limit = 0, -1
+	}
+
+	entries, err := h.db.ListActivity(opts)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list audit trail")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list audit trail"})
+		return
+	}
+
+	if export {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", `attachment; filename="audit.csv"`)
+		if err := writeAuditCSV(c.Writer, entries); err != nil {
+			log.Error().Err(err).Msg("Failed to write audit trail")
+		}
+		return
+	}
+
+	// A full page means there may be older entries
+	if len(entries) == opts.Limit {
+		before := strconv.FormatInt(entries[len(entries)-1].ID, 10)
+		c.Header("X-Next-Cursor", before)
+
+		next := c.Request.URL.Query()
+		next.Set("before", before)
+		c.Header("Link", fmt.Sprintf("<%s?%s>; rel=\"next\"", c.Request.URL.Path, next.Encode()))
+	}
+	respond(c, http.StatusOK, entries, nil)
+}
+
+// auditOptions reads the audit trail's filters from the query string,
+// answering with 400 Bad Request when one is invalid
+func auditOptions(c *gin.Context) (db.ActivityOptions, bool) {
+	opts := db.ActivityOptions{All: isAdmin(c), Viewer: viewerID(c), Action: c.Query("action"), Limit: defaultActivityLimit}
+	params := []struct {
+		name string
+		dest *int64
+	}{{"user", &opts.User}, {"apartment_id", &opts.ApartmentID}, {"before", &opts.Before}}
+	for _, p := range params {
+		if s := c.Query(p.name); s != "" {
+			v, err := strconv.ParseInt(s, 10, 64)
+			if err != nil || v < 1 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + p.name})
+				return opts, false
+			}
+			*p.dest = v
+		}
+	}
+
+	dates := []struct {
+		name string
+		dest *time.Time
+		days int // Added to a bare date, so to takes in the whole day
+	}{{"from", &opts.From, 0}, {"to", &opts.To, 1}}
+	for _, d := range dates {
+		s := c.Query(d.name)
+		if s == "" {
+			continue
+		}
+		if t, err := time.Parse(time.RFC3339, s); err == nil {
+			*d.dest = t
+			continue
+		}
+		t, err := time.Parse(time.DateOnly, s)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + d.name + ", want YYYY-MM-DD or RFC 3339"})
+			return opts, false
+		}
+		*d.dest = t.AddDate(0, 0, d.days)
+	}
+
+	if s := c.Query("limit"); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil || limit < 1 || limit > maxPageSize {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", maxPageSize)})
+			return opts, false
+		}
+		opts.Limit = limit
+	}
+	return opts, true
+}
+
+// writeAuditCSV writes audit entries as a table with a header row
+func writeAuditCSV(w http.ResponseWriter, entries []models.Activity) error {
+	cw := csv.NewWriter(w)
+	cw.Write(auditHeader)
+	for _, e := range entries {
+		userID := ""
+		if e.UserID != nil {
+			userID = strconv.FormatInt(*e.UserID, 10)
+		}
+		cw.Write([]string{
+			strconv.FormatInt(e.ID, 10),
+			e.CreatedAt.UTC().Format(time.RFC3339),
+			userID,
+			e.User,
+			e.Action,
+			strconv.FormatInt(e.ApartmentID, 10),
+			e.Address,
+			e.Detail,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// RegisterRoutes registers the audit trail routes
+func (h *AuditHandler) RegisterRoutes(router *gin.Engine) {
+	router.GET("/api/admin/audit", h.List)
+	router.GET("/api/admin/audit.csv", h.List)
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// basicAuthRealm is the realm browsers show when asking for the password
+const basicAuthRealm = `Basic realm="apt-eval", charset="UTF-8"`
+
+// BasicAuth requires the given user name and password, with HTTP Basic
+// authentication, on every request but those to the exempt path prefixes,
+// which are public or authenticate some other way
+func BasicAuth(user, pass string, exempt ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, prefix := range exempt {
+			if strings.HasPrefix(c.Request.URL.Path, prefix) {
+				c.Next()
+				return
+			}
+		}
+
+		gotUser, gotPass, ok := c.Request.BasicAuth()
+		// Both are compared either way, so timing doesn't tell which was wrong
+		userOK := subtle.ConstantTimeCompare([]byte(gotUser), []byte(user))
+		passOK := subtle.ConstantTimeCompare([]byte(gotPass), []byte(pass))
+		if !ok || userOK&passOK != 1 {
+			if ok {
+				log.Warn().Str("ip", c.ClientIP()).Msg("Basic auth failed")
+			}
+			c.Header("WWW-Authenticate", basicAuthRealm)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// BasicAuthLogout handles logging out of Basic authentication. Browsers
+// keep sending the password until a request for the realm is refused, so
+// this always refuses; cancel the password prompt it brings up.
+func BasicAuthLogout(c *gin.Context) {
+	c.Header("WWW-Authenticate", basicAuthRealm)
+	c.JSON(http.StatusUnauthorized, gin.H{"status": "logged out"})
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBasicAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(BasicAuth("alex", "s3cret", "/share/", "/health", "/logout"))
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	router.GET("/api/*path", ok)
+	router.GET("/share/*path", ok)
+	router.GET("/health", ok)
+	router.GET("/logout", BasicAuthLogout)
+
+	// serve requests target with the given credentials, or none when user
+	// is empty
+	serve := func(target, user, pass string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if user != "" {
+			req.SetBasicAuth(user, pass)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve("/api/apartments", "", "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code, "credentials are required")
+	assert.Equal(t, basicAuthRealm, w.Header().Get("WWW-Authenticate"))
+	assert.Equal(t, http.StatusUnauthorized, serve("/api/apartments", "alex", "wrong").Code, "a wrong password is rejected")
+	assert.Equal(t, http.StatusUnauthorized, serve("/api/apartments", "sam", "s3cret").Code, "a wrong user is rejected")
+	assert.Equal(t, http.StatusOK, serve("/api/apartments", "alex", "s3cret").Code)
+
+	assert.Equal(t, http.StatusOK, serve("/share/abc123", "", "").Code, "exempt paths need no credentials")
+	assert.Equal(t, http.StatusOK, serve("/health", "", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, serve("/logout", "alex", "s3cret").Code, "logging out always refuses")
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mojotx/apt-eval/db"
+	"github.com/mojotx/apt-eval/models"
+	"github.com/rs/zerolog/log"
+)
+
+// BoardHandler handles the pipeline board, a column of apartments per
+// status for drag-and-drop views
+type BoardHandler struct {
+	db *db.DB
+}
+
+// NewBoardHandler creates a new board handler
+func NewBoardHandler(db *db.DB) *BoardHandler {
+	return &BoardHandler{
+		db: db,
+	}
+}
+
+// Board handles retrieving every status column in pipeline order, each
+// listing its apartments in board order
+func (h *BoardHandler) Board(c *gin.Context) {
+	apartments, err := h.db.BoardApartments(viewerID(c))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list board apartments")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get board"})
+		return
+	}
+	cards, err := compactApartments(h.db, apartments)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to build board cards")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get board"})
+		return
+	}
+
+	columns := make([]models.BoardColumn, len(models.Statuses))
+	for i, status := range models.Statuses {
+		columns[i] = models.BoardColumn{Status: status, Apartments: []models.CompactApartment{}}
+	}
+	for _, card := range cards {
+		if i := slices.Index(models.Statuses, card.Status); i >= 0 {
+			columns[i].Apartments = append(columns[i].Apartments, card)
+		}
+	}
+	respond(c, http.StatusOK, columns, nil)
+}
+
+// Reorder handles saving the manual order of apartments within a status
+// column. Moving an apartment to another column is a status change on
+// the apartment itself.
+func (h *BoardHandler) Reorder(c *gin.Context) {
+	status := c.Param("status")
+	if !slices.Contains(models.Statuses, status) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown status"})
+		return
+	}
+
+	var request models.BoardOrderRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Error().Err(err).Msg("Failed to bind request")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.db.ReorderBoardColumn(status, request.IDs); err != nil {
+		var notInColumn *db.NotInColumnError
+		if errors.As(err, &notInColumn) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot reorder: " + notInColumn.Error()})
+			return
+		}
+		log.Error().Err(err).Str("status", status).Msg("Failed to reorder board column")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reorder board column"})
+		return
+	}
+	h.Board(c)
+}
+
+// RegisterRoutes registers all board routes
+func (h *BoardHandler) RegisterRoutes(router *gin.Engine) {
+	board := router.Group("/api/board")
+	{
+		board.GET("", h.Board)
+		board.PUT("/:status", h.Reorder)
+	}
+}
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mojotx/apt-eval/db"
+	"github.com/mojotx/apt-eval/models"
+	"github.com/rs/zerolog/log"
+)
+
+// BuildingHandler handles buildings, which group units in one complex
+type BuildingHandler struct {
+	db *db.DB
+}
+
+// NewBuildingHandler creates a new building handler
+func NewBuildingHandler(db *db.DB) *BuildingHandler {
+	return &BuildingHandler{
+		db: db,
+	}
+}
+
+// List handles retrieving all buildings
+func (h *BuildingHandler) List(c *gin.Context) {
+	buildings, err := h.db.ListBuildings()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list buildings")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list buildings"})
+		return
+	}
+	respond(c, http.StatusOK, buildings, nil)
+}
+
+// getBuilding retrieves the building named by the id parameter,
+// responding with an error if it's invalid or doesn't exist
+func (h *BuildingHandler) getBuilding(c *gin.Context) (*models.Building, bool) {
+	id, ok := parseID(c, "id", "building")
+	if !ok {
+		return nil, false
+	}
+
+	building, err := h.db.GetBuilding(id)
+	if err != nil {
+		log.Error().Err(err).Int64("id", id).Msg("Failed to get building")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get building"})
+		return nil, false
+	}
+	if building == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Building not found"})
+		return nil, false
+	}
+	return building, true
+}
+
+// Get handles retrieving a single building
+func (h *BuildingHandler) Get(c *gin.Context) {
+	if building, ok := h.getBuilding(c); ok {
+		respond(c, http.StatusOK, building, nil)
+	}
+}
+
+// Create handles adding a building
+func (h *BuildingHandler) Create(c *gin.Context) {
+	var request models.BuildingRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Error().Err(err).Msg("Failed to bind request")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	building, err := h.db.CreateBuilding(&request)
+	if err != nil {
+		if respondUnknownAmenity(c, err) {
+			return
+		}
+		log.Error().Err(err).Msg("Failed to create building")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create building"})
+		return
+	}
+	c.JSON(http.StatusCreated, building)
+}
+
+// Update handles modifying a building
+func (h *BuildingHandler) Update(c *gin.Context) {
+	id, ok := parseID(c, "id", "building")
+	if !ok {
+		return
+	}
+
+	var request models.BuildingRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Error().Err(err).Msg("Failed to bind request")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	building, err := h.db.UpdateBuilding(id, &request)
+	if err != nil {
+		if respondUnknownAmenity(c, err) {
+			return
+		}
+		log.Error().Err(err).Int64("id", id).Msg("Failed to update building")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update building"})
+		return
+	}
+	if building == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Building not found"})
+		return
+	}
+	c.JSON(http.StatusOK, building)
+}
+
+// Delete handles removing a building. Its units are kept.
+func (h *BuildingHandler) Delete(c *gin.Context) {
+	id, ok := parseID(c, "id", "building")
+	if !ok {
+		return
+	}
+
+	if err := h.db.DeleteBuilding(id); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Building not found"})
+			return
+		}
+		log.Error().Err(err).Int64("id", id).Msg("Failed to delete building")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete building"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success"})
+}
+
+// Units handles listing a building's apartments by address
+func (h *BuildingHandler) Units(c *gin.Context) {
+	building, ok := h.getBuilding(c)
+	if !ok {
+		return
+	}
+
+	units, err := h.db.ListApartments(db.ListOptions{
+		BuildingID: building.ID,
+		Sort:       []db.SortField{{Column: "canonical_address"}},
+		Viewer:     viewerID(c),
+	})
+	if err != nil {
+		log.Error().Err(err).Int64("id", building.ID).Msg("Failed to list building units")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list units"})
+		return
+	}
+	respond(c, http.StatusOK, units, nil)
+}
+
+// respondUnknownBuilding responds with 400 if err names a building that
+// doesn't exist, reporting whether it did
+func respondUnknownBuilding(c *gin.Context, err error) bool {
+	var unknown *db.UnknownBuildingError
+	if !errors.As(err, &unknown) {
+		return false
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": unknown.Error()})
+	return true
+}
+
+// RegisterRoutes registers all building routes
+func (h *BuildingHandler) RegisterRoutes(router *gin.Engine) {
+	buildings := router.Group("/api/buildings")
+	{
+		buildings.GET("", h.List)
+		buildings.POST("", h.Create)
+		buildings.GET("/:id", h.Get)
+		buildings.PUT("/:id", h.Update)
+		buildings.DELETE("/:id", h.Delete)
+		buildings.GET("/:id/units", h.Units)
+	}
+}
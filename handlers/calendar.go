@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mojotx/apt-eval/db"
+	"github.com/mojotx/apt-eval/gcal"
+	"github.com/mojotx/apt-eval/models"
+	"github.com/rs/zerolog/log"
+)
+
+// calendarStateTTL is how long the consent screen has to send the user back
+const calendarStateTTL = 10 * time.Minute
+
+// CalendarHandler handles connecting a Google Calendar and syncing visits
+// with it
+type CalendarHandler struct {
+	db          *db.DB
+	syncer      *gcal.Syncer // nil when Google OAuth isn't configured
+	calendarID  string
+	redirectURL string // Empty to derive it from the request
+
+	mu     sync.Mutex // Guards states
+	states map[string]time.Time
+}
+
+// NewCalendarHandler creates a new calendar handler. Events go on the
+// calendar with calendarID, and Google sends users back to redirectURL,
+// which must be registered with the OAuth client.
+func NewCalendarHandler(db *db.DB, syncer *gcal.Syncer, calendarID, redirectURL string) *CalendarHandler {
+	return &CalendarHandler{
+		db:          db,
+		syncer:      syncer,
+		calendarID:  calendarID,
+		redirectURL: redirectURL,
+		states:      map[string]time.Time{},
+	}
+}
+
+// callbackURL is where the consent screen sends the user back to
+func (h *CalendarHandler) callbackURL(c *gin.Context) string {
+	if h.redirectURL != "" {
+		return h.redirectURL
+	}
+	return baseURL(c) + "/api/calendar/callback"
+}
+
+// configured responds with an error when Google OAuth isn't configured
+func (h *CalendarHandler) configured(c *gin.Context) bool {
+	if h.syncer == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "Calendar sync isn't configured: set GOOGLE_CLIENT_ID and GOOGLE_CLIENT_SECRET"})
+		return false
+	}
+	return true
+}
+
+// Status handles reporting the connected calendar and how the last sync
+// went
+func (h *CalendarHandler) Status(c *gin.Context) {
+	conn, err := h.db.GetCalendarConnection()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get calendar connection")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get calendar connection"})
+		return
+	}
+
+	status := gin.H{
+		"configured": h.syncer != nil,
+		"connected":  conn != nil,
+	}
+	if conn != nil {
+		status["calendar_id"] = conn.CalendarID
+		status["connected_at"] = conn.ConnectedAt
+	}
+	if h.syncer != nil {
+		status["sync"] = h.syncer.Status()
+	}
+	respond(c, http.StatusOK, status, nil)
+}
+
+// Connect handles sending the user to Google's consent screen
+func (h *CalendarHandler) Connect(c *gin.Context) {
+	if !h.configured(c) {
+		return
+	}
+
+	state, err := newShareToken()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to generate OAuth state")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect calendar"})
+		return
+	}
+	h.mu.Lock()
+	now := time.Now()
+	for s, expires := range h.states {
+		if now.After(expires) {
+			delete(h.states, s)
+		}
+	}
+	h.states[state] = now.Add(calendarStateTTL)
+	h.mu.Unlock()
+
+	c.Redirect(http.StatusFound, h.syncer.OAuth.AuthCodeURL(state, h.callbackURL(c)))
+}
+
+// takeState reports whether state was handed out by Connect and hasn't
+// expired, so it can't be used again
+func (h *CalendarHandler) takeState(state string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	expires, ok := h.states[state]
+	delete(h.states, state)
+	return ok && time.Now().Before(expires)
+}
+
+// Callback handles the user coming back from the consent screen, saving
+// the granted access and syncing right away
+func (h *CalendarHandler) Callback(c *gin.Context) {
+	if !h.configured(c) {
+		return
+	}
+	if !h.takeState(c.Query("state")) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired OAuth state: connect again"})
+		return
+	}
+	if reason := c.Query("error"); reason != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Calendar access wasn't granted: " + reason})
+		return
+	}
+
+	token, err := h.syncer.OAuth.Exchange(c.Request.Context(), c.Query("code"), h.callbackURL(c))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to exchange OAuth code")
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to connect calendar"})
+		return
+	}
+	if token.RefreshToken == "" {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Google didn't grant offline access: connect again"})
+		return
+	}
+
+	err = h.db.ConnectCalendar(&models.CalendarConnection{
+		CalendarID:   h.calendarID,
+		RefreshToken: token.RefreshToken,
+		AccessToken:  token.AccessToken,
+		ExpiresAt:    &token.Expiry,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to save calendar connection")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect calendar"})
+		return
+	}
+	log.Info().Str("calendar_id", h.calendarID).Msg("Calendar connected")
+
+	go func() {
+		if _, err := h.syncer.Sync(context.Background()); err != nil && !errors.Is(err, gcal.ErrSyncRunning) {
+			log.Error().Err(err).Msg("Calendar sync failed")
+		}
+	}()
+	c.Redirect(http.StatusFound, "/")
+}
+
+// Disconnect handles forgetting the connected calendar. Events already on
+// it stay there.
+func (h *CalendarHandler) Disconnect(c *gin.Context) {
+	if err := h.db.DisconnectCalendar(); err != nil {
+		log.Error().Err(err).Msg("Failed to disconnect calendar")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disconnect calendar"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success"})
+}
+
+// Sync handles syncing with the calendar now, responding with what changed
+func (h *CalendarHandler) Sync(c *gin.Context) {
+	if !h.configured(c) {
+		return
+	}
+
+	result, err := h.syncer.Sync(c.Request.Context())
+	if errors.Is(err, gcal.ErrSyncRunning) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Calendar sync is already running"})
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Calendar sync failed")
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Calendar sync failed: " + err.Error(), "result": result})
+		return
+	}
+	if result == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "No calendar connected"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"result": result})
+}
+
+// RegisterRoutes registers all calendar routes
+func (h *CalendarHandler) RegisterRoutes(router *gin.Engine) {
+	calendar := router.Group("/api/calendar")
+	{
+		calendar.GET("", h.Status)
+		calendar.DELETE("", h.Disconnect)
+		calendar.GET("/connect", h.Connect)
+		calendar.GET("/callback", h.Callback)
+		calendar.POST("/sync", h.Sync)
+	}
+}
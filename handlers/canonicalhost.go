@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CanonicalHost returns middleware that keeps requests on the canonical
+// host. Requests for any other Host, a bare IP address included, are
+// redirected to the same URL on authority (host[:port]), or refused with
+// 421 Misdirected Request when redirect is false. Requests to the exempt
+// path prefixes are let through, so health checks by IP keep working.
+func CanonicalHost(authority string, redirect bool, exempt ...string) gin.HandlerFunc {
+	canonical := hostname(authority)
+	return func(c *gin.Context) {
+		if strings.EqualFold(hostname(c.Request.Host), canonical) {
+			c.Next()
+			return
+		}
+		for _, prefix := range exempt {
+			if strings.HasPrefix(c.Request.URL.Path, prefix) {
+				c.Next()
+				return
+			}
+		}
+
+		if !redirect {
+			c.AbortWithStatusJSON(http.StatusMisdirectedRequest, gin.H{"error": "Unknown host"})
+			return
+		}
+		// Keep the method and body of anything but a plain GET or HEAD
+		status := http.StatusMovedPermanently
+		if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+			status = http.StatusPermanentRedirect
+		}
+		c.Redirect(status, CanonicalURL(authority, c.Request))
+		c.Abort()
+	}
+}
+
+// CanonicalURL returns the HTTPS URL of r on authority (host[:port])
+func CanonicalURL(authority string, r *http.Request) string {
+	target := "https://" + authority + r.URL.Path
+	if r.URL.RawQuery != "" {
+		target += "?" + r.URL.RawQuery
+	}
+	return target
+}
+
+// hostname returns the host of a Host header or authority, without the
+// port
+func hostname(hostport string) string {
+	if host, _, err := net.SplitHostPort(hostport); err == nil {
+		return host
+	}
+	return strings.Trim(hostport, "[]")
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanonicalHost(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	serve := func(redirect bool, method, host, target string) *httptest.ResponseRecorder {
+		router := gin.New()
+		router.Use(CanonicalHost("apt.example.com:8443", redirect, "/health"))
+		router.Any("/*path", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, target, nil)
+		req.Host = host
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// The canonical host is served on any port, in any case
+	assert.Equal(t, http.StatusOK, serve(true, "GET", "apt.example.com:8443", "/api/apartments").Code)
+	assert.Equal(t, http.StatusOK, serve(true, "GET", "APT.example.com", "/").Code)
+
+	w := serve(true, "GET", "192.168.1.20:8443", "/api/apartments?status=visited")
+	assert.Equal(t, http.StatusMovedPermanently, w.Code)
+	assert.Equal(t, "https://apt.example.com:8443/api/apartments?status=visited", w.Header().Get("Location"))
+
+	w = serve(true, "POST", "[::1]:8443", "/api/apartments")
+	assert.Equal(t, http.StatusPermanentRedirect, w.Code)
+
+	assert.Equal(t, http.StatusMisdirectedRequest, serve(false, "GET", "evil.example.net", "/").Code)
+	assert.Equal(t, http.StatusOK, serve(false, "GET", "10.0.0.5", "/health").Code)
+}
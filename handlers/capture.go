@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mojotx/apt-eval/capture"
+	"github.com/mojotx/apt-eval/db"
+	"github.com/mojotx/apt-eval/models"
+	"github.com/rs/zerolog/log"
+)
+
+// maxCaptureSize caps capture request bodies, which carry page HTML
+const maxCaptureSize = 5 << 20
+
+// CaptureHandler turns listing pages, sent by a bookmarklet or browser
+// extension, into draft apartments
+type CaptureHandler struct {
+	db     *db.DB
+	apiKey string
+}
+
+// NewCaptureHandler creates a new capture handler. Requests must carry
+// apiKey.
+func NewCaptureHandler(db *db.DB, apiKey string) *CaptureHandler {
+	return &CaptureHandler{
+		db:     db,
+		apiKey: apiKey,
+	}
+}
+
+// allowAnyOrigin lets pages on any site call the capture endpoint, which
+// is where the bookmarklet runs. The API key, not cookies, authenticates
+// the caller, so no credentials are allowed.
+func allowAnyOrigin(c *gin.Context) {
+	h := c.Writer.Header()
+	h.Set("Access-Control-Allow-Origin", "*")
+	h.Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+	h.Set("Access-Control-Allow-Headers", "Content-Type, X-API-Key")
+	h.Set("Access-Control-Max-Age", "86400")
+}
+
+// Preflight handles the browser's CORS preflight request
+func (h *CaptureHandler) Preflight(c *gin.Context) {
+	allowAnyOrigin(c)
+	c.Status(http.StatusNoContent)
+}
+
+// draftFromPage builds a draft apartment from a captured page. The page
+// is the listing, so its URL wins over links found in the selection.
+func draftFromPage(page *models.CaptureRequest) *models.ApartmentRequest {
+	listing := capture.Parse(page.Title, page.Text, page.HTML)
+
+	req := &models.ApartmentRequest{Address: listing.Address, Status: models.StatusDraft, ListingURL: &page.URL}
+	if req.Address == "" {
+		req.Address = strings.TrimSpace(page.Title)
+	}
+	if req.Address == "" {
+		req.Address = "Untitled listing"
+	}
+	req.Price = listing.Price
+
+	body := strings.TrimSpace(page.Text)
+	if body == "" {
+		body = strings.TrimSpace(capture.HTMLText(page.HTML))
+	}
+	req.Notes = "Captured from " + page.URL
+	if body != "" {
+		req.Notes += "\n\n" + clipNotes(body)
+	}
+	return req
+}
+
+// Capture handles a listing page sent by the bookmarklet, creating a draft
+// apartment from it
+func (h *CaptureHandler) Capture(c *gin.Context) {
+	allowAnyOrigin(c)
+
+	key := c.GetHeader("X-API-Key")
+	if key == "" {
+		key = c.Query("key")
+	}
+	if subtle.ConstantTimeCompare([]byte(key), []byte(h.apiKey)) != 1 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key"})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxCaptureSize)
+	var request models.CaptureRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Error().Err(err).Msg("Failed to bind request")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	apartment, err := h.db.CreateApartment(draftFromPage(&request))
+	if err != nil {
+		if respondDuplicateAddress(c, h.db, err) {
+			return
+		}
+		log.Error().Err(err).Msg("Failed to create apartment from page")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create apartment"})
+		return
+	}
+
+	log.Info().Int64("id", apartment.ID).Str("url", request.URL).Msg("Captured apartment from page")
+	recordActivity(h.db, viewerID(c), models.ActivityCaptured, apartment.ID, "")
+	c.JSON(http.StatusCreated, apartment)
+}
+
+// RegisterRoutes registers the capture route
+func (h *CaptureHandler) RegisterRoutes(router *gin.Engine) {
+	router.POST("/api/capture", h.Capture)
+	router.OPTIONS("/api/capture", h.Preflight)
+}
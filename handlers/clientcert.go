@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mojotx/apt-eval/db"
+	"github.com/rs/zerolog/log"
+)
+
+// ClientCert requires a TLS client certificate, verified against the
+// configured CAs, on every request but those to the exempt path prefixes,
+// which authenticate some other way. The user named by the certificate's
+// common name becomes the viewer, in place of X-User-ID.
+func ClientCert(database *db.DB, exempt ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, prefix := range exempt {
+			if strings.HasPrefix(c.Request.URL.Path, prefix) {
+				c.Next()
+				return
+			}
+		}
+
+		state := c.Request.TLS
+		if state == nil || len(state.VerifiedChains) == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Client certificate required"})
+			return
+		}
+		cn := state.VerifiedChains[0][0].Subject.CommonName
+		user, err := database.FindUserByName(cn)
+		if err != nil {
+			log.Error().Err(err).Str("cn", cn).Msg("Failed to find user")
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user"})
+			return
+		}
+		if user == nil {
+			log.Warn().Str("cn", cn).Msg("Client certificate doesn't name a user")
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Client certificate doesn't name a user"})
+			return
+		}
+
+		c.Set(viewerKey, user.ID)
+		c.Next()
+	}
+}
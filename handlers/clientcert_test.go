@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mojotx/apt-eval/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientCert(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	database, err := db.New(t.TempDir())
+	assert.NoError(t, err)
+	t.Cleanup(func() { database.Close() })
+	alex := createTestUser(t, database, "Alex")
+
+	router := gin.New()
+	router.Use(ClientCert(database, "/share/", "/health"))
+	router.Any("/*path", func(c *gin.Context) { c.String(http.StatusOK, strconv.FormatInt(viewerID(c), 10)) })
+
+	// serve requests target with a client certificate naming cn, or none
+	// when cn is empty
+	serve := func(target, cn string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("X-User-ID", "99")
+		if cn != "" {
+			cert := &x509.Certificate{Subject: pkix.Name{CommonName: cn}}
+			req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, serve("/api/apartments", "").Code, "a certificate is required")
+	assert.Equal(t, http.StatusForbidden, serve("/api/apartments", "Mallory").Code, "the certificate must name a user")
+
+	w := serve("/api/apartments", "Alex")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, strconv.FormatInt(alex, 10), w.Body.String(), "the named user is the viewer, not X-User-ID")
+
+	assert.Equal(t, http.StatusOK, serve("/share/abc123", "").Code, "exempt paths need no certificate")
+	assert.Equal(t, http.StatusOK, serve("/health", "").Code)
+}
@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mojotx/apt-eval/db"
+	"github.com/mojotx/apt-eval/models"
+	"github.com/rs/zerolog/log"
+)
+
+// CommentHandler handles the discussion threads of apartments
+type CommentHandler struct {
+	db *db.DB
+}
+
+// NewCommentHandler creates a new comment handler
+func NewCommentHandler(db *db.DB) *CommentHandler {
+	return &CommentHandler{
+		db: db,
+	}
+}
+
+// List handles retrieving an apartment's discussion thread
+func (h *CommentHandler) List(c *gin.Context) {
+	apartmentID, ok := findApartment(c, h.db)
+	if !ok {
+		return
+	}
+
+	comments, err := h.db.ListComments(apartmentID)
+	if err != nil {
+		log.Error().Err(err).Int64("apartment_id", apartmentID).Msg("Failed to list comments")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list comments"})
+		return
+	}
+	respond(c, http.StatusOK, comments, nil)
+}
+
+// Create handles posting a comment, as the X-User-ID user when given
+func (h *CommentHandler) Create(c *gin.Context) {
+	apartmentID, ok := findApartment(c, h.db)
+	if !ok {
+		return
+	}
+
+	var request models.CommentRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Error().Err(err).Msg("Failed to bind request")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if viewer := viewerID(c); viewer != 0 {
+		request.AuthorID = &viewer
+	}
+
+	comment, err := h.db.CreateComment(apartmentID, &request)
+	if err != nil {
+		log.Error().Err(err).Int64("apartment_id", apartmentID).Msg("Failed to create comment")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create comment"})
+		return
+	}
+	recordActivity(h.db, viewerID(c), models.ActivityCommented, apartmentID, "")
+	c.JSON(http.StatusCreated, comment)
+}
+
+// getOwnComment looks up the comment named by the path, responding with 404
+// when it doesn't exist and 403 when it belongs to someone other than the
+// viewer. Comments posted without an author are anyone's to change.
+func (h *CommentHandler) getOwnComment(c *gin.Context) (*models.Comment, bool) {
+	apartmentID, ok := findApartment(c, h.db)
+	if !ok {
+		return nil, false
+	}
+	id, ok := parseID(c, "comment_id", "comment")
+	if !ok {
+		return nil, false
+	}
+
+	comment, err := h.db.GetComment(apartmentID, id)
+	if err != nil {
+		log.Error().Err(err).Int64("id", id).Msg("Failed to get comment")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get comment"})
+		return nil, false
+	}
+	if comment == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+		return nil, false
+	}
+	if comment.AuthorID != nil && *comment.AuthorID != viewerID(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the comment's author can change it"})
+		return nil, false
+	}
+	return comment, true
+}
+
+// Update handles editing a comment
+func (h *CommentHandler) Update(c *gin.Context) {
+	comment, ok := h.getOwnComment(c)
+	if !ok {
+		return
+	}
+
+	var request models.CommentRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Error().Err(err).Msg("Failed to bind request")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	request.AuthorID = comment.AuthorID
+
+	updated, err := h.db.UpdateComment(comment.ApartmentID, comment.ID, &request)
+	if err != nil {
+		log.Error().Err(err).Int64("id", comment.ID).Msg("Failed to update comment")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update comment"})
+		return
+	}
+	if updated == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+		return
+	}
+	c.JSON(http.StatusOK, updated)
+}
+
+// Delete handles removing a comment
+func (h *CommentHandler) Delete(c *gin.Context) {
+	comment, ok := h.getOwnComment(c)
+	if !ok {
+		return
+	}
+
+	if err := h.db.DeleteComment(comment.ApartmentID, comment.ID); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+			return
+		}
+		log.Error().Err(err).Int64("id", comment.ID).Msg("Failed to delete comment")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete comment"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success"})
+}
+
+// RegisterRoutes registers all comment routes
+func (h *CommentHandler) RegisterRoutes(router *gin.Engine) {
+	comments := router.Group("/api/apartments/:id/comments")
+	{
+		comments.GET("", h.List)
+		comments.POST("", h.Create)
+		comments.PUT("/:comment_id", h.Update)
+		comments.DELETE("/:comment_id", h.Delete)
+	}
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mojotx/apt-eval/db"
+	"github.com/mojotx/apt-eval/models"
+	"github.com/rs/zerolog/log"
+)
+
+// CommuteHandler handles saved destinations and apartment commute matrices
+type CommuteHandler struct {
+	db *db.DB
+}
+
+// NewCommuteHandler creates a new commute handler
+func NewCommuteHandler(db *db.DB) *CommuteHandler {
+	return &CommuteHandler{
+		db: db,
+	}
+}
+
+// ListDestinations handles retrieving all saved destinations
+func (h *CommuteHandler) ListDestinations(c *gin.Context) {
+	destinations, err := h.db.ListDestinations()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list destinations")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list destinations"})
+		return
+	}
+	respond(c, http.StatusOK, destinations, nil)
+}
+
+// CreateDestination handles saving a new destination
+func (h *CommuteHandler) CreateDestination(c *gin.Context) {
+	var request models.DestinationRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Error().Err(err).Msg("Failed to bind request")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	destination, err := h.db.CreateDestination(&request)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to create destination")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create destination"})
+		return
+	}
+	c.JSON(http.StatusCreated, destination)
+}
+
+// UpdateDestination handles modifying a destination
+func (h *CommuteHandler) UpdateDestination(c *gin.Context) {
+	id, ok := parseID(c, "id", "destination")
+	if !ok {
+		return
+	}
+
+	var request models.DestinationRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Error().Err(err).Msg("Failed to bind request")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	destination, err := h.db.UpdateDestination(id, &request)
+	if err != nil {
+		log.Error().Err(err).Int64("id", id).Msg("Failed to update destination")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update destination"})
+		return
+	}
+	if destination == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Destination not found"})
+		return
+	}
+	c.JSON(http.StatusOK, destination)
+}
+
+// DeleteDestination handles removing a destination
+func (h *CommuteHandler) DeleteDestination(c *gin.Context) {
+	id, ok := parseID(c, "id", "destination")
+	if !ok {
+		return
+	}
+
+	if err := h.db.DeleteDestination(id); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Destination not found"})
+			return
+		}
+		log.Error().Err(err).Int64("id", id).Msg("Failed to delete destination")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete destination"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success"})
+}
+
+// Commutes handles retrieving an apartment's commute matrix
+func (h *CommuteHandler) Commutes(c *gin.Context) {
+	id, ok := findApartment(c, h.db)
+	if !ok {
+		return
+	}
+
+	matrix, err := h.db.GetCommutes(id)
+	if err != nil {
+		log.Error().Err(err).Int64("id", id).Msg("Failed to get commutes")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get commutes"})
+		return
+	}
+	respond(c, http.StatusOK, matrix, nil)
+}
+
+// RegisterRoutes registers all destination and commute routes
+func (h *CommuteHandler) RegisterRoutes(router *gin.Engine) {
+	destinations := router.Group("/api/destinations")
+	{
+		destinations.GET("", h.ListDestinations)
+		destinations.POST("", h.CreateDestination)
+		destinations.PUT("/:id", h.UpdateDestination)
+		destinations.DELETE("/:id", h.DeleteDestination)
+	}
+
+	router.GET("/api/apartments/:id/commutes", h.Commutes)
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mojotx/apt-eval/db"
+	"github.com/mojotx/apt-eval/models"
+	"github.com/rs/zerolog/log"
+)
+
+// maxCompareIDs caps how many apartments one comparison may include
+const maxCompareIDs = 20
+
+// CompareHandler handles side-by-side apartment comparisons
+type CompareHandler struct {
+	db *db.DB
+}
+
+// NewCompareHandler creates a new compare handler
+func NewCompareHandler(db *db.DB) *CompareHandler {
+	return &CompareHandler{
+		db: db,
+	}
+}
+
+// parseIDList parses a comma-separated list of apartment IDs
+func parseIDList(s string) ([]int64, error) {
+	var ids []int64
+	for _, part := range strings.Split(s, ",") {
+		id, err := strconv.ParseInt(strings.TrimSpace(part), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid apartment ID %q", part)
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) > maxCompareIDs {
+		return nil, fmt.Errorf("at most %d apartments can be compared", maxCompareIDs)
+	}
+	return ids, nil
+}
+
+// compare assembles the comparison for ids in order. It returns nil and
+// the missing ID if one doesn't exist or viewer can't see it.
+func (h *CompareHandler) compare(ids []int64, viewer int64) ([]models.ComparedApartment, int64, error) {
+	compared := make([]models.ComparedApartment, 0, len(ids))
+	for _, id := range ids {
+		apartment, err := h.db.GetApartment(id)
+		if err != nil {
+			return nil, 0, err
+		}
+		if apartment == nil || !apartment.VisibleTo(viewer) {
+			return nil, id, nil
+		}
+
+		visits, err := h.db.ListVisits(id)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		rooms, err := h.db.ListRooms(id)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		split, err := h.db.RentSplit(apartment)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		entry := models.ComparedApartment{Apartment: *apartment, VisitCount: len(visits), Rooms: rooms, Split: split}
+		if len(visits) > 0 {
+			entry.Environment = &visits[0]
+		}
+		compared = append(compared, entry)
+	}
+	return compared, 0, nil
+}
+
+// Compare handles comparing the apartments listed in the ids query
+// parameter side by side
+func (h *CompareHandler) Compare(c *gin.Context) {
+	ids, err := parseIDList(c.Query("ids"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ids: " + err.Error()})
+		return
+	}
+
+	compared, missing, err := h.compare(ids, viewerID(c))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to compare apartments")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compare apartments"})
+		return
+	}
+	if compared == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Apartment %d not found", missing)})
+		return
+	}
+
+	respond(c, http.StatusOK, compared, nil)
+}
+
+// RegisterRoutes registers the comparison, comparison set, and sharing
+// routes
+func (h *CompareHandler) RegisterRoutes(router *gin.Engine) {
+	router.GET("/api/compare", h.Compare)
+	router.POST("/api/compare/share", h.Share)
+	router.DELETE("/api/compare/share/:token", h.Unshare)
+	router.GET("/share/:token", h.Report)
+	router.GET("/share/:token/qr.png", h.QR)
+	router.GET("/share/:token/qr.svg", h.QR)
+
+	sets := router.Group("/api/compare-sets")
+	{
+		sets.GET("", h.ListSets)
+		sets.POST("", h.CreateSet)
+		sets.GET("/:id", h.GetSet)
+		sets.PUT("/:id", h.UpdateSet)
+		sets.DELETE("/:id", h.DeleteSet)
+		sets.GET("/:id/compare", h.CompareSet)
+	}
+}
@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mojotx/apt-eval/db"
+	"github.com/mojotx/apt-eval/models"
+	"github.com/rs/zerolog/log"
+)
+
+// respondUnknownApartment responds with 400 if err names an apartment that
+// doesn't exist, reporting whether it did
+func respondUnknownApartment(c *gin.Context, err error) bool {
+	var unknown *db.UnknownApartmentError
+	if !errors.As(err, &unknown) {
+		return false
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": unknown.Error()})
+	return true
+}
+
+// ListSets handles retrieving the saved comparison sets
+func (h *CompareHandler) ListSets(c *gin.Context) {
+	sets, err := h.db.ListCompareSets()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list comparison sets")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list comparison sets"})
+		return
+	}
+	respond(c, http.StatusOK, sets, nil)
+}
+
+// getSet retrieves the comparison set named by the id parameter,
+// responding with an error if it's invalid or doesn't exist
+func (h *CompareHandler) getSet(c *gin.Context) (*models.CompareSet, bool) {
+	id, ok := parseID(c, "id", "comparison set")
+	if !ok {
+		return nil, false
+	}
+
+	set, err := h.db.GetCompareSet(id)
+	if err != nil {
+		log.Error().Err(err).Int64("id", id).Msg("Failed to get comparison set")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get comparison set"})
+		return nil, false
+	}
+	if set == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Comparison set not found"})
+		return nil, false
+	}
+	return set, true
+}
+
+// GetSet handles retrieving a single comparison set
+func (h *CompareHandler) GetSet(c *gin.Context) {
+	if set, ok := h.getSet(c); ok {
+		respond(c, http.StatusOK, set, nil)
+	}
+}
+
+// CreateSet handles saving a comparison set
+func (h *CompareHandler) CreateSet(c *gin.Context) {
+	var request models.CompareSetRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Error().Err(err).Msg("Failed to bind request")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	set, err := h.db.CreateCompareSet(&request)
+	if err != nil {
+		if respondUnknownApartment(c, err) {
+			return
+		}
+		log.Error().Err(err).Msg("Failed to create comparison set")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create comparison set"})
+		return
+	}
+	c.JSON(http.StatusCreated, set)
+}
+
+// UpdateSet handles renaming a comparison set or changing its apartments
+func (h *CompareHandler) UpdateSet(c *gin.Context) {
+	id, ok := parseID(c, "id", "comparison set")
+	if !ok {
+		return
+	}
+
+	var request models.CompareSetRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Error().Err(err).Msg("Failed to bind request")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	set, err := h.db.UpdateCompareSet(id, &request)
+	if err != nil {
+		if respondUnknownApartment(c, err) {
+			return
+		}
+		log.Error().Err(err).Int64("id", id).Msg("Failed to update comparison set")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update comparison set"})
+		return
+	}
+	if set == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Comparison set not found"})
+		return
+	}
+	c.JSON(http.StatusOK, set)
+}
+
+// DeleteSet handles removing a comparison set
+func (h *CompareHandler) DeleteSet(c *gin.Context) {
+	id, ok := parseID(c, "id", "comparison set")
+	if !ok {
+		return
+	}
+
+	if err := h.db.DeleteCompareSet(id); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Comparison set not found"})
+			return
+		}
+		log.Error().Err(err).Int64("id", id).Msg("Failed to delete comparison set")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete comparison set"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success"})
+}
+
+// CompareSet handles comparing the apartments of a saved set side by side,
+// the same as listing their IDs to /api/compare
+func (h *CompareHandler) CompareSet(c *gin.Context) {
+	set, ok := h.getSet(c)
+	if !ok {
+		return
+	}
+
+	compared, missing, err := h.compare(set.ApartmentIDs, viewerID(c))
+	if err != nil {
+		log.Error().Err(err).Int64("id", set.ID).Msg("Failed to compare apartments")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compare apartments"})
+		return
+	}
+	if compared == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Apartment %d not found", missing)})
+		return
+	}
+
+	respond(c, http.StatusOK, compared, nil)
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mojotx/apt-eval/models"
+)
+
+// checkNotModified sets the Last-Modified header and, if the request's
+// If-Modified-Since is at or after modified, writes 304 Not Modified. It
+// reports whether the response has been written.
+func checkNotModified(c *gin.Context, modified time.Time) bool {
+	if modified.IsZero() {
+		return false
+	}
+
+	// HTTP dates only carry whole seconds
+	modified = modified.UTC().Truncate(time.Second)
+	c.Header("Last-Modified", modified.Format(http.TimeFormat))
+
+	ims := c.GetHeader("If-Modified-Since")
+	if ims == "" || (c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead) {
+		return false
+	}
+
+	since, err := http.ParseTime(ims)
+	if err != nil || modified.After(since) {
+		return false
+	}
+
+	c.Status(http.StatusNotModified)
+	c.Writer.WriteHeaderNow()
+	return true
+}
+
+// lastModified returns the most recent UpdatedAt among apartments
+func lastModified(apartments []models.Apartment) time.Time {
+	var latest time.Time
+	for _, apt := range apartments {
+		if apt.UpdatedAt.After(latest) {
+			latest = apt.UpdatedAt
+		}
+	}
+	return latest
+}
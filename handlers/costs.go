@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mojotx/apt-eval/costs"
+	"github.com/mojotx/apt-eval/db"
+	"github.com/mojotx/apt-eval/models"
+	"github.com/rs/zerolog/log"
+)
+
+// CostHandler handles the move-in cost calculator
+type CostHandler struct {
+	db *db.DB
+}
+
+// NewCostHandler creates a new cost handler
+func NewCostHandler(db *db.DB) *CostHandler {
+	return &CostHandler{
+		db: db,
+	}
+}
+
+// Get handles retrieving an apartment's itemized move-in cost and
+// first-year cost of occupancy
+func (h *CostHandler) Get(c *gin.Context) {
+	apt, ok := loadApartment(c, h.db)
+	if !ok {
+		return
+	}
+
+	inputs, err := h.db.GetMoveInCosts(apt.ID)
+	if err != nil {
+		log.Error().Err(err).Int64("apartment_id", apt.ID).Msg("Failed to get move-in costs")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get move-in costs"})
+		return
+	}
+	respond(c, http.StatusOK, costs.Calculate(apt, *inputs), nil)
+}
+
+// Set handles replacing an apartment's move-in costs, returning the new
+// breakdown
+func (h *CostHandler) Set(c *gin.Context) {
+	apt, ok := loadApartment(c, h.db)
+	if !ok {
+		return
+	}
+
+	var request models.MoveInCostsRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Error().Err(err).Msg("Failed to bind request")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	inputs, err := h.db.SetMoveInCosts(apt.ID, &request)
+	if err != nil {
+		log.Error().Err(err).Int64("apartment_id", apt.ID).Msg("Failed to set move-in costs")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set move-in costs"})
+		return
+	}
+	c.JSON(http.StatusOK, costs.Calculate(apt, *inputs))
+}
+
+// parseTerms reads the query parameters overriding the default mortgage
+// terms, responding with 400 if one is out of range
+func parseTerms(c *gin.Context) (models.MortgageTerms, bool) {
+	terms := models.DefaultMortgageTerms
+	params := []struct {
+		name string
+		dest *float64
+		max  float64
+	}{
+		{"down_payment", &terms.DownPaymentPct, 100},
+		{"rate", &terms.RatePct, 30},
+		{"property_tax", &terms.PropertyTaxPct, 10},
+		{"insurance", &terms.InsurancePct, 10},
+		{"maintenance", &terms.MaintenancePct, 10},
+		{"closing_costs", &terms.ClosingCostPct, 20},
+	}
+	for _, p := range params {
+		s := c.Query(p.name)
+		if s == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil || v < 0 || v > p.max {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s must be a percentage between 0 and %g", p.name, p.max)})
+			return terms, false
+		}
+		*p.dest = v
+	}
+	if s := c.Query("years"); s != "" {
+		years, err := strconv.Atoi(s)
+		if err != nil || years < 1 || years > 50 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "years must be between 1 and 50"})
+			return terms, false
+		}
+		terms.Years = years
+	}
+	return terms, true
+}
+
+// BuyVsRent handles comparing the cost of buying a home listed for sale
+// with the rentals still in the running
+func (h *CostHandler) BuyVsRent(c *gin.Context) {
+	home, ok := loadApartment(c, h.db)
+	if !ok {
+		return
+	}
+	if home.ListingType != models.ListingSale {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Apartment isn't listed for sale"})
+		return
+	}
+	if home.Sale.PurchasePrice == nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Apartment has no purchase price"})
+		return
+	}
+	terms, ok := parseTerms(c)
+	if !ok {
+		return
+	}
+
+	apartments, err := h.db.ListApartments(db.ListOptions{Viewer: viewerID(c)})
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list apartments")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list apartments"})
+		return
+	}
+	var rentals []costs.Rental
+	for i, apt := range apartments {
+		if apt.ListingType != models.ListingRent || apt.Price == nil ||
+			slices.Contains([]string{models.StatusRejected, models.StatusArchived}, apt.Status) {
+			continue
+		}
+		inputs, err := h.db.GetMoveInCosts(apt.ID)
+		if err != nil {
+			log.Error().Err(err).Int64("apartment_id", apt.ID).Msg("Failed to get move-in costs")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get move-in costs"})
+			return
+		}
+		rentals = append(rentals, costs.Rental{Apartment: &apartments[i], Costs: *inputs})
+	}
+	respond(c, http.StatusOK, costs.BuyVsRent(home, terms, rentals), nil)
+}
+
+// RegisterRoutes registers the move-in cost and buy-vs-rent routes
+func (h *CostHandler) RegisterRoutes(router *gin.Engine) {
+	router.GET("/api/apartments/:id/costs", h.Get)
+	router.PUT("/api/apartments/:id/costs", h.Set)
+	router.GET("/api/apartments/:id/buy-vs-rent", h.BuyVsRent)
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mojotx/apt-eval/db"
+	"github.com/rs/zerolog/log"
+)
+
+// DeadlineHandler handles application deadlines and hold expiries
+type DeadlineHandler struct {
+	db *db.DB
+}
+
+// NewDeadlineHandler creates a new deadline handler
+func NewDeadlineHandler(db *db.DB) *DeadlineHandler {
+	return &DeadlineHandler{
+		db: db,
+	}
+}
+
+// ListDeadlines handles listing upcoming deadlines of the apartments the
+// viewer may see, most urgent first. days limits them to those due within
+// that many days.
+func (h *DeadlineHandler) ListDeadlines(c *gin.Context) {
+	var within time.Duration
+	if s := c.Query("days"); s != "" {
+		days, err := strconv.Atoi(s)
+		if err != nil || days < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "days must be a positive integer"})
+			return
+		}
+		within = time.Duration(days) * 24 * time.Hour
+	}
+
+	deadlines, err := h.db.UpcomingDeadlines(viewerID(c), within)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list deadlines")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list deadlines"})
+		return
+	}
+	respond(c, http.StatusOK, deadlines, nil)
+}
+
+// RegisterRoutes registers all deadline routes
+func (h *DeadlineHandler) RegisterRoutes(router *gin.Engine) {
+	router.GET("/api/deadlines", h.ListDeadlines)
+}
@@ -0,0 +1,16 @@
+package handlers
+
+import "github.com/gin-gonic/gin"
+
+// DemoModeHeader marks every response in demo mode, so clients can show a
+// banner saying the data isn't real
+const DemoModeHeader = "X-Demo-Mode"
+
+// DemoMode returns middleware that marks responses as coming from demo
+// mode
+func DemoMode() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header(DemoModeHeader, "true")
+		c.Next()
+	}
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// member is a single key/value pair of a decoded JSON object
+type member struct {
+	Key   string
+	Value any
+}
+
+// object is a JSON object that remembers its key order, so renderers can
+// emit columns and elements in the same order as the model's fields
+type object []member
+
+// MarshalJSON implements json.Marshaler for object
+func (o object) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, m := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(m.Key)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		val, err := json.Marshal(m.Value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(val)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// toDocument converts data into its generic JSON form: objects become
+// object, arrays []any, and numbers json.Number
+func toDocument(data any) (any, error) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	return decodeValue(dec)
+}
+
+// decodeValue reads the next complete value from the token stream
+func decodeValue(dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch t := tok.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			obj := object{}
+			for dec.More() {
+				keyTok, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				val, err := decodeValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				obj = append(obj, member{Key: keyTok.(string), Value: val})
+			}
+			_, err := dec.Token() // closing brace
+			return obj, err
+		case '[':
+			list := []any{}
+			for dec.More() {
+				val, err := decodeValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, val)
+			}
+			_, err := dec.Token() // closing bracket
+			return list, err
+		}
+		return nil, fmt.Errorf("unexpected delimiter %v", t)
+	}
+	return tok, nil
+}
+
+// plain converts a document back into ordinary maps, for encoders that
+// don't care about key order
+func plain(doc any) any {
+	switch v := doc.(type) {
+	case object:
+		m := make(map[string]any, len(v))
+		for _, f := range v {
+			m[f.Key] = plain(f.Value)
+		}
+		return m
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			out[i] = plain(item)
+		}
+		return out
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	}
+	return doc
+}
@@ -0,0 +1,20 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/mojotx/apt-eval/drain"
+)
+
+// Drain returns middleware that counts requests in flight for the
+// shutdown report, with the HTTPS listener's tracker or, for requests that
+// came in without TLS behind a proxy, the HTTP listener's
+func Drain(https, http *drain.Tracker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tracker := https
+		if c.Request.TLS == nil {
+			tracker = http
+		}
+		defer tracker.Begin()()
+		c.Next()
+	}
+}
@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mojotx/apt-eval/db"
+	"github.com/mojotx/apt-eval/enrich"
+	"github.com/mojotx/apt-eval/models"
+	"github.com/rs/zerolog/log"
+)
+
+// Address suggestion limits. Queries shorter than minSuggestQuery match too
+// much to be useful and aren't sent to the provider.
+const (
+	minSuggestQuery    = 3
+	defaultSuggestions = 5
+	maxSuggestions     = 10
+)
+
+// EnrichmentHandler handles on-demand enrichment of apartments
+type EnrichmentHandler struct {
+	db       *db.DB
+	enricher *enrich.Enricher
+}
+
+// NewEnrichmentHandler creates a new enrichment handler
+func NewEnrichmentHandler(db *db.DB, enricher *enrich.Enricher) *EnrichmentHandler {
+	return &EnrichmentHandler{
+		db:       db,
+		enricher: enricher,
+	}
+}
+
+// Refresh runs one kind of enrichment (walkability, crime, ...) for an
+// apartment and returns the updated record
+func (h *EnrichmentHandler) Refresh(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		log.Error().Err(err).Str("id", idStr).Msg("Invalid apartment ID")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid apartment ID"})
+		return
+	}
+
+	apartment, err := h.db.GetApartment(id)
+	if err != nil {
+		log.Error().Err(err).Int64("id", id).Msg("Failed to get apartment")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get apartment"})
+		return
+	}
+	if apartment == nil || !apartment.VisibleTo(viewerID(c)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Apartment not found"})
+		return
+	}
+
+	if err := h.enricher.Refresh(c.Request.Context(), c.Param("kind"), apartment); err != nil {
+		respondEnrichmentError(c, id, err)
+		return
+	}
+
+	apartment, err = h.db.GetApartment(id)
+	if err != nil {
+		log.Error().Err(err).Int64("id", id).Msg("Failed to get apartment")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get apartment"})
+		return
+	}
+	c.JSON(http.StatusOK, apartment)
+}
+
+// respondEnrichmentError maps enrichment failures to HTTP responses
+func respondEnrichmentError(c *gin.Context, id int64, err error) {
+	switch {
+	case errors.Is(err, enrich.ErrUnknownKind):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown enrichment kind"})
+	case errors.Is(err, enrich.ErrNotConfigured):
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "No provider is configured for this enrichment"})
+	case errors.Is(err, enrich.ErrNoLocation):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Apartment has no latitude/longitude"})
+	case errors.Is(err, enrich.ErrAddressNotFound):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Address could not be geocoded"})
+	case errors.Is(err, enrich.ErrNoCoverage):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "No configured data source covers this location"})
+	case errors.Is(err, enrich.ErrNoRooms):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Apartment has no rooms to count bedrooms from"})
+	default:
+		log.Error().Err(err).Int64("id", id).Msg("Enrichment failed")
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Enrichment provider request failed"})
+	}
+}
+
+// Locate handles geocoding an apartment's address into its coordinates and
+// returns the updated record
+func (h *EnrichmentHandler) Locate(c *gin.Context) {
+	id, ok := parseID(c, "id", "apartment")
+	if !ok {
+		return
+	}
+
+	apartment, err := h.db.GetApartment(id)
+	if err != nil {
+		log.Error().Err(err).Int64("id", id).Msg("Failed to get apartment")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get apartment"})
+		return
+	}
+	if apartment == nil || !apartment.VisibleTo(viewerID(c)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Apartment not found"})
+		return
+	}
+
+	if err := h.enricher.Locate(c.Request.Context(), apartment); err != nil {
+		respondEnrichmentError(c, id, err)
+		return
+	}
+
+	apartment, err = h.db.GetApartment(id)
+	if err != nil {
+		log.Error().Err(err).Int64("id", id).Msg("Failed to get apartment")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get apartment"})
+		return
+	}
+	c.JSON(http.StatusOK, apartment)
+}
+
+// Geocode handles looking up the position of the address in the address
+// query parameter
+func (h *EnrichmentHandler) Geocode(c *gin.Context) {
+	address := c.Query("address")
+	if address == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "address is required"})
+		return
+	}
+
+	result, err := h.enricher.Geocode(c.Request.Context(), address)
+	if err != nil {
+		if errors.Is(err, enrich.ErrAddressNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Address not found"})
+			return
+		}
+		respondEnrichmentError(c, 0, err)
+		return
+	}
+	respond(c, http.StatusOK, result, nil)
+}
+
+// SuggestAddress handles completing the partly typed address in the q
+// query parameter, so the browser never needs the provider's API key
+func (h *EnrichmentHandler) SuggestAddress(c *gin.Context) {
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "q is required"})
+		return
+	}
+	if len(query) < minSuggestQuery {
+		respond(c, http.StatusOK, []models.AddressSuggestion{}, nil)
+		return
+	}
+
+	limit := defaultSuggestions
+	if s := c.Query("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > maxSuggestions {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", maxSuggestions)})
+			return
+		}
+		limit = n
+	}
+
+	suggestions, err := h.enricher.Suggest(c.Request.Context(), query, limit)
+	if err != nil {
+		respondEnrichmentError(c, 0, err)
+		return
+	}
+	respond(c, http.StatusOK, suggestions, nil)
+}
+
+// Kinds lists the enrichment kinds with a configured provider, and the
+// configured geocoder if any
+func (h *EnrichmentHandler) Kinds(c *gin.Context) {
+	var geocoder *string
+	if name := h.enricher.GeocoderName(); name != "" {
+		geocoder = &name
+	}
+	respond(c, http.StatusOK, gin.H{"kinds": h.enricher.Kinds(), "geocoder": geocoder}, nil)
+}
+
+// RegisterRoutes registers all enrichment routes
+func (h *EnrichmentHandler) RegisterRoutes(router *gin.Engine) {
+	router.GET("/api/enrichments", h.Kinds)
+	router.GET("/api/geocode", h.Geocode)
+	router.GET("/api/address/suggest", h.SuggestAddress)
+
+	apartments := router.Group("/api/apartments")
+	{
+		apartments.POST("/:id/enrich/:kind", h.Refresh)
+		apartments.POST("/:id/geocode", h.Locate)
+	}
+}
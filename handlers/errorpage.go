@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"embed"
+	"html/template"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+//go:embed templates/error.html
+var errorTemplateFS embed.FS
+
+var errorTemplate = template.Must(template.ParseFS(errorTemplateFS, "templates/error.html"))
+
+// errorPages holds what the error page says for each status it's shown
+// for
+var errorPages = map[int]struct{ title, message string }{
+	http.StatusNotFound: {
+		"Page not found",
+		"There's nothing here. The link may be old, or the apartment it pointed to may have been deleted.",
+	},
+	http.StatusInternalServerError: {
+		"Something went wrong",
+		"The server ran into a problem showing this page. Try again in a moment; if it keeps happening, mention the request ID below.",
+	},
+}
+
+// isAPIPath reports whether a path is part of the JSON API, which answers
+// errors in JSON rather than with error pages
+func isAPIPath(path string) bool {
+	return path == "/api" || strings.HasPrefix(path, "/api/")
+}
+
+// renderErrorPage answers with the error page for status, showing the
+// request ID
+func renderErrorPage(c *gin.Context, status int) {
+	page, ok := errorPages[status]
+	if !ok {
+		page.title = http.StatusText(status)
+	}
+
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(status)
+	err := errorTemplate.Execute(c.Writer, struct {
+		Status         int
+		Title, Message string
+		RequestID      string
+	}{status, page.title, page.message, requestID(c)})
+	if err != nil {
+		log.Error().Err(err).Int("status", status).Msg("Failed to render error page")
+	}
+}
+
+// NotFound handles requests no route matched: with the error page for the
+// web UI, or JSON for the API
+func NotFound(c *gin.Context) {
+	if isAPIPath(c.Request.URL.Path) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+		return
+	}
+	renderErrorPage(c, http.StatusNotFound)
+}
+
+// Recovered answers a request whose handler panicked, after Recovery has
+// logged it: with the error page for the web UI, or JSON for the API, both
+// showing the request ID
+func Recovered(c *gin.Context, _ any) {
+	if c.Writer.Written() {
+		c.Abort()
+		return
+	}
+	if isAPIPath(c.Request.URL.Path) {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Internal server error", "request_id": requestID(c)})
+		return
+	}
+	renderErrorPage(c, http.StatusInternalServerError)
+	c.Abort()
+}
@@ -0,0 +1,291 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mojotx/apt-eval/db"
+	"github.com/mojotx/apt-eval/health"
+	"github.com/mojotx/apt-eval/sentry"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorPages(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Recovery(), RequestID())
+	router.NoRoute(NotFound)
+	router.GET("/boom", func(c *gin.Context) { panic("boom") })
+	router.GET("/api/boom", func(c *gin.Context) { panic("boom") })
+
+	get := func(path, id string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set(RequestIDHeader, id)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("/nowhere", "abc-123")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/html")
+	assert.Contains(t, w.Body.String(), "Page not found")
+	assert.Contains(t, w.Body.String(), "abc-123")
+
+	w = get("/boom", "<script>")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), "Something went wrong")
+	assert.NotContains(t, w.Body.String(), "<script>", "unsafe request IDs are replaced")
+	assert.Len(t, w.Header().Get(RequestIDHeader), 32)
+
+	w = get("/api/nowhere", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.JSONEq(t, `{"error": "Not found"}`, w.Body.String())
+
+	w = get("/api/boom", "abc-456")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.JSONEq(t, `{"error": "Internal server error", "request_id": "abc-456"}`, w.Body.String())
+}
+
+func TestRecoveryCrashReport(t *testing.T) {
+	var logs bytes.Buffer
+	defer func(l zerolog.Logger) { log.Logger = l }(log.Logger)
+	log.Logger = zerolog.New(&logs)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Recovery(), RequestID())
+	router.POST("/api/users/:id", func(c *gin.Context) {
+		var body map[string]any
+		c.ShouldBindJSON(&body)
+		panic("boom")
+	})
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/api/users/3", strings.NewReader(`{"name":"Sam","phone":"+15551234567","prefs":{"api_token":"abc"},"floor":3}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(RequestIDHeader, "crash-1")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+	var entry map[string]any
+	assert.NoError(t, json.Unmarshal(logs.Bytes(), &entry))
+	assert.Equal(t, "Handler panicked", entry["message"])
+	assert.Equal(t, "boom", entry["panic"])
+	assert.Equal(t, "crash-1", entry["request_id"])
+	assert.Equal(t, "/api/users/:id", entry["route"])
+	assert.Contains(t, entry["stack"], "errorpage_test.go")
+	assert.JSONEq(t, `{"name":"Sam","phone":"[redacted]","prefs":{"api_token":"[redacted]"},"floor":3}`, entry["body"].(string))
+}
+
+func TestLogPayloads(t *testing.T) {
+	var logs bytes.Buffer
+	defer func(l zerolog.Logger) { log.Logger = l }(log.Logger)
+	log.Logger = zerolog.New(&logs).Level(zerolog.DebugLevel)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RequestID(), LogPayloads("/api/users/"))
+	echo := func(c *gin.Context) {
+		var body map[string]any
+		c.ShouldBindJSON(&body)
+		c.JSON(http.StatusOK, gin.H{"id": 7, "notes": "Call the landlord", "price": body["price"]})
+	}
+	router.PATCH("/api/apartments/:id", echo)
+	router.PATCH("/api/users/:id", echo)
+	router.GET("/health", echo)
+
+	send := func(method, path string) {
+		req := httptest.NewRequest(method, path, strings.NewReader(`{"price":1450,"notes":"Ask about pets","contact_email":"a@example.com"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(RequestIDHeader, "payload-1")
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	send("PATCH", "/api/apartments/7")
+	var entry map[string]any
+	assert.NoError(t, json.Unmarshal(logs.Bytes(), &entry))
+	assert.Equal(t, "API payloads", entry["message"])
+	assert.Equal(t, "debug", entry["level"])
+	assert.Equal(t, "payload-1", entry["request_id"])
+	assert.Equal(t, "/api/apartments/:id", entry["route"])
+	assert.Equal(t, float64(http.StatusOK), entry["status"])
+	assert.JSONEq(t, `{"price":1450,"notes":"[redacted]","contact_email":"[redacted]"}`, entry["request_body"].(string))
+	assert.JSONEq(t, `{"id":7,"notes":"[redacted]","price":1450}`, entry["response_body"].(string))
+
+	logs.Reset()
+	send("PATCH", "/api/users/3")
+	send("GET", "/health")
+	assert.Empty(t, logs.String(), "excluded, or not the API")
+
+	log.Logger = log.Logger.Level(zerolog.InfoLevel)
+	send("PATCH", "/api/apartments/7")
+	assert.Empty(t, logs.String(), "only at debug level")
+}
+
+func TestTimeout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Timeout(20*time.Millisecond, map[string]time.Duration{
+		"/api/exports":           time.Second,
+		"/api/exports/:name/run": 0,
+	}))
+	deadline := func(c *gin.Context) {
+		d, ok := c.Request.Context().Deadline()
+		c.JSON(http.StatusOK, gin.H{"bounded": ok, "left_ms": time.Until(d).Milliseconds()})
+	}
+	router.GET("/api/apartments", deadline)
+	router.GET("/api/exports", deadline)
+	router.POST("/api/exports/:name/run", deadline)
+	router.GET("/api/slow", func(c *gin.Context) {
+		<-c.Request.Context().Done()
+	})
+
+	get := func(method, path string) (int, map[string]any) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		var body map[string]any
+		json.Unmarshal(w.Body.Bytes(), &body)
+		return w.Code, body
+	}
+
+	_, body := get("GET", "/api/apartments")
+	assert.Equal(t, true, body["bounded"])
+	assert.LessOrEqual(t, body["left_ms"], float64(20))
+
+	_, body = get("GET", "/api/exports")
+	assert.Greater(t, body["left_ms"], float64(20), "the route's own timeout")
+
+	_, body = get("POST", "/api/exports/notion/run")
+	assert.Equal(t, false, body["bounded"], "a timeout of zero is unbounded")
+
+	code, body := get("GET", "/api/slow")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "Request timed out", body["error"])
+}
+
+func TestReady(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	database, err := db.New(t.TempDir())
+	assert.NoError(t, err)
+	router := gin.New()
+	router.GET("/readyz", Ready(database))
+
+	get := func() (int, map[string]any) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+		var body map[string]any
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return w.Code, body
+	}
+
+	health.Watch("geocoder", "nominatim")
+	health.Record("geocoder", nil)
+	code, body := get()
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ready", body["status"])
+	assert.Equal(t, "ok", body["database"])
+
+	health.Record("geocoder", errors.New("connection refused"))
+	code, body = get()
+	assert.Equal(t, http.StatusOK, code, "the app serves without its integrations")
+	assert.Equal(t, "degraded", body["status"])
+	geocoder := body["integrations"].([]any)[0].(map[string]any)
+	assert.Equal(t, "failing", geocoder["status"])
+	assert.NotNil(t, geocoder["last_success"])
+	assert.NotContains(t, geocoder, "error", "errors aren't shown publicly")
+
+	database.Close()
+	code, body = get()
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "unavailable", body["status"])
+}
+
+func TestParseRouteTimeouts(t *testing.T) {
+	routes, err := ParseRouteTimeouts(" /api/exports=300; /api/apartments/:id/summarize = 120 ;")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]time.Duration{
+		"/api/exports":                  300 * time.Second,
+		"/api/apartments/:id/summarize": 120 * time.Second,
+	}, routes)
+
+	for _, s := range []string{"/api/exports", "api/exports=30", "/api/exports=soon", "/api/exports=-1"} {
+		_, err := ParseRouteTimeouts(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestSanitizeBody(t *testing.T) {
+	form := &recordingBody{data: []byte("user=sam&password=hunter2")}
+	assert.Equal(t, "password=%5Bredacted%5D&user=sam", sanitizeBody("application/x-www-form-urlencoded", form))
+
+	upload := &recordingBody{data: []byte("\x89PNG")}
+	assert.Equal(t, "<4 bytes of image/png>", sanitizeBody("image/png", upload))
+
+	long := &recordingBody{data: []byte(`{"notes":"`), truncated: true}
+	assert.Equal(t, "<10 bytes of application/json>", sanitizeBody("application/json", long), "partial JSON isn't parsed")
+}
+
+func TestReportErrors(t *testing.T) {
+	var mu sync.Mutex
+	var events []sentry.Event
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scanner := bufio.NewScanner(r.Body)
+		var lines []string
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		var event sentry.Event
+		assert.NoError(t, json.Unmarshal([]byte(lines[len(lines)-1]), &event))
+		mu.Lock()
+		events = append(events, event)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+	client, err := sentry.New(strings.Replace(srv.URL, "http://", "http://key@", 1)+"/1", "v1", "test")
+	assert.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Recovery(), RequestID(), ReportErrors(client))
+	router.GET("/api/apartments/:id", func(c *gin.Context) { panic("boom") })
+	router.GET("/api/fail", func(c *gin.Context) { c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Unavailable"}) })
+	router.GET("/api/ok", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{}) })
+
+	for _, path := range []string{"/api/apartments/7", "/api/fail", "/api/ok"} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set(RequestIDHeader, "req-1")
+		router.ServeHTTP(w, req)
+	}
+	client.Flush(context.Background())
+
+	assert.Len(t, events, 2, "only server errors are reported")
+	byRoute := map[string]sentry.Event{}
+	for _, e := range events {
+		byRoute[e.Tags["route"]] = e
+	}
+
+	panicked := byRoute["/api/apartments/:id"]
+	assert.Equal(t, "boom", panicked.Message)
+	assert.Equal(t, "GET /api/apartments/:id", panicked.Transaction)
+	assert.Equal(t, "req-1", panicked.Tags["request_id"])
+	assert.Equal(t, "v1", panicked.Release)
+	assert.NotEmpty(t, panicked.Exception.Values[0].Stacktrace.Frames)
+
+	failed := byRoute["/api/fail"]
+	assert.Equal(t, "503", failed.Tags["status_code"])
+	assert.Equal(t, "HTTP 503", failed.Exception.Values[0].Type)
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mojotx/apt-eval/sentry"
+)
+
+// ReportErrors sends handler panics and 5xx responses to the error tracker,
+// with the request, its route, its request ID, and the viewer. Panics are
+// passed on to the recovery middleware to answer. With a nil client it
+// does nothing.
+func ReportErrors(client *sentry.Client) gin.HandlerFunc {
+	if client == nil {
+		return func(c *gin.Context) { c.Next() }
+	}
+	return func(c *gin.Context) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			// A handler aborting on purpose isn't an error
+			if rec != http.ErrAbortHandler {
+				client.Capture(errorEvent(c, http.StatusInternalServerError, sentry.Exception{
+					Type:  fmt.Sprintf("panic: %T", rec),
+					Value: fmt.Sprint(rec),
+					// Skip this function and the runtime's panic handling
+					Stacktrace: sentry.Stack(2),
+				}))
+			}
+			panic(rec)
+		}()
+
+		c.Next()
+
+		status := c.Writer.Status()
+		if status < http.StatusInternalServerError {
+			return
+		}
+		exc := sentry.Exception{Type: "HTTP " + strconv.Itoa(status), Value: http.StatusText(status)}
+		if err := c.Errors.Last(); err != nil {
+			exc.Type, exc.Value = fmt.Sprintf("%T", err.Err), err.Error()
+		}
+		client.Capture(errorEvent(c, status, exc))
+	}
+}
+
+// errorEvent describes an error during the request in c
+func errorEvent(c *gin.Context, status int, exc sentry.Exception) sentry.Event {
+	route := c.FullPath()
+	if route == "" {
+		route = "unmatched"
+	}
+	event := sentry.Event{
+		Message:     exc.Value,
+		Logger:      "http",
+		Transaction: c.Request.Method + " " + route,
+		Request:     sentry.NewRequest(c.Request),
+		Tags: map[string]string{
+			"route":       route,
+			"status_code": strconv.Itoa(status),
+		},
+		Exception: &sentry.Exceptions{Values: []sentry.Exception{exc}},
+	}
+	if id := requestID(c); id != "" {
+		event.Tags["request_id"] = id
+	}
+	if id := viewerID(c); id != 0 {
+		event.User = &sentry.User{ID: strconv.FormatInt(id, 10)}
+	}
+	return event
+}
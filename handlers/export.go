@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mojotx/apt-eval/export"
+	"github.com/rs/zerolog/log"
+)
+
+// ExportHandler handles syncing the apartments to outside services
+type ExportHandler struct {
+	syncers []*export.Syncer
+}
+
+// NewExportHandler creates a new export handler for the configured
+// exporters
+func NewExportHandler(syncers []*export.Syncer) *ExportHandler {
+	return &ExportHandler{
+		syncers: syncers,
+	}
+}
+
+// List handles listing the configured exporters and how their last sync
+// went
+func (h *ExportHandler) List(c *gin.Context) {
+	statuses := make([]export.Status, len(h.syncers))
+	for i, s := range h.syncers {
+		statuses[i] = s.Status()
+	}
+	respond(c, http.StatusOK, statuses, nil)
+}
+
+// Run handles syncing one exporter now, responding with what changed
+func (h *ExportHandler) Run(c *gin.Context) {
+	name := c.Param("name")
+	var syncer *export.Syncer
+	for _, s := range h.syncers {
+		if s.Name() == name {
+			syncer = s
+		}
+	}
+	if syncer == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Exporter not configured"})
+		return
+	}
+
+	result, err := syncer.Sync(c.Request.Context())
+	if errors.Is(err, export.ErrSyncRunning) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Export is already running"})
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Str("exporter", name).Msg("Export failed")
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Export failed: " + err.Error(), "result": result})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"exporter": name, "result": result})
+}
+
+// RegisterRoutes registers all export routes
+func (h *ExportHandler) RegisterRoutes(router *gin.Engine) {
+	exports := router.Group("/api/exports")
+	{
+		exports.GET("", h.List)
+		exports.POST("/:name/run", h.Run)
+	}
+}
@@ -0,0 +1,298 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/mojotx/apt-eval/db"
+	"github.com/mojotx/apt-eval/media"
+	"github.com/mojotx/apt-eval/models"
+	"github.com/mojotx/apt-eval/scan"
+	"github.com/mojotx/apt-eval/storage"
+	"github.com/rs/zerolog/log"
+)
+
+// maxFloorPlanSize caps floor plan uploads
+const maxFloorPlanSize = 20 << 20
+
+// FloorPlanHandler handles the floor plan sub-resource of apartments
+type FloorPlanHandler struct {
+	db      *db.DB
+	store   *storage.Store
+	scanner scan.Scanner // nil when uploads aren't virus scanned
+	quota   int64        // Bytes all uploaded files may take up; 0 for no limit
+}
+
+// NewFloorPlanHandler creates a new floor plan handler
+func NewFloorPlanHandler(db *db.DB, store *storage.Store, scanner scan.Scanner, quota int64) *FloorPlanHandler {
+	return &FloorPlanHandler{
+		db:      db,
+		store:   store,
+		scanner: scanner,
+		quota:   quota,
+	}
+}
+
+// floorPlanKey is the storage key of an apartment's floor plan file
+func floorPlanKey(apartmentID int64) string {
+	return "floorplans/" + strconv.FormatInt(apartmentID, 10)
+}
+
+// floorPlanThumbnailKey is the storage key of the cached thumbnail of an
+// apartment's floor plan
+func floorPlanThumbnailKey(apartmentID int64) string {
+	return floorPlanKey(apartmentID) + ".thumb.jpg"
+}
+
+// floorPlanThumbnailURL is where an apartment's floor plan thumbnail is
+// served. The upload time is included so clients refetch after a new upload.
+func floorPlanThumbnailURL(apartmentID int64, uploadedAt time.Time) string {
+	return fmt.Sprintf("/api/apartments/%d/floorplan/thumbnail?v=%d", apartmentID, uploadedAt.Unix())
+}
+
+// Get handles retrieving floor plan metadata
+func (h *FloorPlanHandler) Get(c *gin.Context) {
+	apartmentID, ok := findApartment(c, h.db)
+	if !ok {
+		return
+	}
+
+	fp, err := h.db.GetFloorPlan(apartmentID)
+	if err != nil {
+		log.Error().Err(err).Int64("apartment_id", apartmentID).Msg("Failed to get floor plan")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get floor plan"})
+		return
+	}
+	if fp == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Floor plan not found"})
+		return
+	}
+	respond(c, http.StatusOK, fp, nil)
+}
+
+// Upload handles attaching a floor plan image or PDF as the multipart
+// field "file", with optional room dimensions as JSON in the "rooms" field
+func (h *FloorPlanHandler) Upload(c *gin.Context) {
+	apartmentID, ok := findApartment(c, h.db)
+	if !ok {
+		return
+	}
+
+	data, filename, ok := readUpload(c, maxFloorPlanSize, "Floor plan")
+	if !ok {
+		return
+	}
+
+	var rooms []models.RoomDimensions
+	if raw := c.Request.FormValue("rooms"); raw != "" {
+		err := json.Unmarshal([]byte(raw), &rooms)
+		if err == nil {
+			err = binding.Validator.ValidateStruct(rooms)
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rooms: " + err.Error()})
+			return
+		}
+	}
+
+	info, err := media.Inspect(data)
+	if err != nil {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Floor plans must be PNG, JPEG, GIF, or PDF"})
+		return
+	}
+
+	// A new floor plan replaces the old one's space
+	if h.quota > 0 {
+		current, err := h.db.FloorPlanSize(apartmentID)
+		if err != nil {
+			log.Error().Err(err).Int64("apartment_id", apartmentID).Msg("Failed to get floor plan size")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check storage quota"})
+			return
+		}
+		if !withinQuota(c, h.db, h.quota, int64(len(data)), current) {
+			return
+		}
+	}
+
+	// A flagged file is set aside for inspection and the current floor
+	// plan, if any, is kept
+	verdict, ok := scanUpload(c, h.scanner, data)
+	if !ok {
+		return
+	}
+	if verdict.infected() {
+		key := quarantinePrefix + floorPlanKey(apartmentID)
+		if _, err := h.store.Put(key, bytes.NewReader(data)); err != nil {
+			log.Error().Err(err).Int64("apartment_id", apartmentID).Msg("Failed to quarantine floor plan")
+		}
+		log.Warn().Int64("apartment_id", apartmentID).Str("signature", verdict.signature).Str("key", key).
+			Msg("Quarantined infected floor plan")
+		respondInfected(c, verdict, nil)
+		return
+	}
+	if data, _, err = media.StripMetadata(data); err != nil {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Unreadable floor plan: " + err.Error()})
+		return
+	}
+
+	size, err := h.store.Put(floorPlanKey(apartmentID), bytes.NewReader(data))
+	if err != nil {
+		log.Error().Err(err).Int64("apartment_id", apartmentID).Msg("Failed to store floor plan")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store floor plan"})
+		return
+	}
+	if err := h.store.Delete(floorPlanThumbnailKey(apartmentID)); err != nil {
+		log.Warn().Err(err).Int64("apartment_id", apartmentID).Msg("Failed to remove floor plan thumbnail")
+	}
+
+	fp := &models.FloorPlan{
+		ApartmentID: apartmentID,
+		Filename:    filename,
+		ContentType: info.ContentType,
+		Size:        size,
+		ScanStatus:  verdict.status,
+		ScannedAt:   verdict.scannedAt,
+	}
+	if info.IsImage() {
+		fp.Width, fp.Height = &info.Width, &info.Height
+	} else if info.Pages > 0 {
+		fp.Pages = &info.Pages
+	}
+
+	fp, err = h.db.SaveFloorPlan(fp, rooms)
+	if err != nil {
+		log.Error().Err(err).Int64("apartment_id", apartmentID).Msg("Failed to save floor plan")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save floor plan"})
+		return
+	}
+	c.JSON(http.StatusOK, fp)
+}
+
+// SetRooms handles replacing the room dimensions recorded for a floor plan
+func (h *FloorPlanHandler) SetRooms(c *gin.Context) {
+	apartmentID, ok := findApartment(c, h.db)
+	if !ok {
+		return
+	}
+
+	var rooms []models.RoomDimensions
+	if err := c.ShouldBindJSON(&rooms); err != nil {
+		log.Error().Err(err).Msg("Failed to bind request")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if rooms == nil {
+		rooms = []models.RoomDimensions{}
+	}
+
+	fp, err := h.db.SetFloorPlanRooms(apartmentID, rooms)
+	if err != nil {
+		log.Error().Err(err).Int64("apartment_id", apartmentID).Msg("Failed to set floor plan rooms")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set floor plan rooms"})
+		return
+	}
+	if fp == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Floor plan not found"})
+		return
+	}
+	c.JSON(http.StatusOK, fp)
+}
+
+// File handles downloading the floor plan file itself
+func (h *FloorPlanHandler) File(c *gin.Context) {
+	apartmentID, ok := findApartment(c, h.db)
+	if !ok {
+		return
+	}
+
+	fp, err := h.db.GetFloorPlan(apartmentID)
+	if err != nil {
+		log.Error().Err(err).Int64("apartment_id", apartmentID).Msg("Failed to get floor plan")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get floor plan"})
+		return
+	}
+	if fp == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Floor plan not found"})
+		return
+	}
+
+	f, err := h.store.Open(floorPlanKey(apartmentID))
+	if err != nil {
+		log.Error().Err(err).Int64("apartment_id", apartmentID).Msg("Failed to open floor plan")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open floor plan"})
+		return
+	}
+	defer f.Close()
+
+	c.Header("Content-Type", fp.ContentType)
+	c.Header("Content-Disposition", fmt.Sprintf("inline; filename=%q", fp.Filename))
+	http.ServeContent(c.Writer, c.Request, fp.Filename, fp.UploadedAt, f)
+}
+
+// Thumbnail handles downloading a small JPEG preview of an image floor
+// plan, rendered on first request and kept until the floor plan changes
+func (h *FloorPlanHandler) Thumbnail(c *gin.Context) {
+	apartmentID, ok := findApartment(c, h.db)
+	if !ok {
+		return
+	}
+
+	fp, err := h.db.GetFloorPlan(apartmentID)
+	if err != nil {
+		log.Error().Err(err).Int64("apartment_id", apartmentID).Msg("Failed to get floor plan")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get floor plan"})
+		return
+	}
+	if fp == nil || fp.Width == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Floor plan image not found"})
+		return
+	}
+
+	serveThumbnail(c, h.store, floorPlanKey(apartmentID), floorPlanThumbnailKey(apartmentID), fp.UploadedAt)
+}
+
+// Delete handles removing a floor plan
+func (h *FloorPlanHandler) Delete(c *gin.Context) {
+	apartmentID, ok := findApartment(c, h.db)
+	if !ok {
+		return
+	}
+
+	if err := h.db.DeleteFloorPlan(apartmentID); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Floor plan not found"})
+			return
+		}
+		log.Error().Err(err).Int64("apartment_id", apartmentID).Msg("Failed to delete floor plan")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete floor plan"})
+		return
+	}
+
+	if err := h.store.Delete(floorPlanKey(apartmentID)); err != nil {
+		log.Warn().Err(err).Int64("apartment_id", apartmentID).Msg("Failed to remove floor plan file")
+	}
+	if err := h.store.Delete(floorPlanThumbnailKey(apartmentID)); err != nil {
+		log.Warn().Err(err).Int64("apartment_id", apartmentID).Msg("Failed to remove floor plan thumbnail")
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success"})
+}
+
+// RegisterRoutes registers all floor plan routes
+func (h *FloorPlanHandler) RegisterRoutes(router *gin.Engine) {
+	floorPlan := router.Group("/api/apartments/:id/floorplan")
+	{
+		floorPlan.GET("", h.Get)
+		floorPlan.PUT("", h.Upload)
+		floorPlan.DELETE("", h.Delete)
+		floorPlan.GET("/file", h.File)
+		floorPlan.GET("/thumbnail", h.Thumbnail)
+		floorPlan.PUT("/rooms", h.SetRooms)
+	}
+}
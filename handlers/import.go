@@ -0,0 +1,280 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/mojotx/apt-eval/address"
+	"github.com/mojotx/apt-eval/db"
+	"github.com/mojotx/apt-eval/models"
+	"github.com/rs/zerolog/log"
+)
+
+// maxImportSize caps import request bodies
+const maxImportSize = 10 << 20
+
+// requestFieldTypes maps the JSON names of the fields an apartment request
+// can set to their types, pointers followed
+var requestFieldTypes = func() map[string]reflect.Type {
+	types := map[string]reflect.Type{}
+	t := reflect.TypeOf(models.ApartmentRequest{})
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		ft := t.Field(i).Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		types[name] = ft
+	}
+	return types
+}()
+
+// cellJSON returns the JSON of a CSV cell for a request field of type t.
+// Cells hold what the CSV rendering of apartments writes: text as is, and
+// numbers, booleans, lists, and objects as JSON. A list may also be
+// written as comma-separated text.
+func cellJSON(t reflect.Type, cell string) json.RawMessage {
+	switch {
+	case t.Kind() == reflect.String:
+	case t.Kind() == reflect.Slice && !strings.HasPrefix(cell, "["):
+		items := strings.Split(cell, ",")
+		for i := range items {
+			items[i] = strings.TrimSpace(items[i])
+		}
+		data, _ := json.Marshal(items)
+		return data
+	case json.Valid([]byte(cell)):
+		return json.RawMessage(cell)
+	}
+	data, _ := json.Marshal(cell)
+	return data
+}
+
+// importRow is a row of an import, the fields it sets keyed by JSON name
+type importRow struct {
+	line   int
+	fields map[string]json.RawMessage
+}
+
+// readImport reads the rows of a CSV import. The header names the fields
+// of each column; columns that aren't request fields, like id or score
+// in a CSV export, are ignored. Empty cells leave their field out.
+func readImport(r io.Reader) ([]importRow, error) {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, errors.New("the file is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+	columns := make([]string, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := requestFieldTypes[name]; ok {
+			columns[i] = name
+		}
+	}
+	if !slices.Contains(columns, "address") {
+		return nil, errors.New("there's no address column")
+	}
+
+	var rows []importRow
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := cr.FieldPos(0)
+		row := importRow{line: line, fields: map[string]json.RawMessage{}}
+		for i, name := range columns {
+			if cell := strings.TrimSpace(record[i]); name != "" && cell != "" {
+				row.fields[name] = cellJSON(requestFieldTypes[name], cell)
+			}
+		}
+		rows = append(rows, row)
+	}
+}
+
+// importChanges returns the fields an import row sets to something other
+// than what existing has, in request field order
+func importChanges(existing *models.Apartment, request *models.ApartmentRequest, fields map[string]json.RawMessage) []string {
+	before, err := toJSONMap(existing)
+	if err != nil {
+		return nil
+	}
+	after, err := toJSONMap(request)
+	if err != nil {
+		return nil
+	}
+	// A unit typed into the address is split off into the unit
+	street, _ := address.SplitUnit(request.Address)
+	after["address"] = street
+
+	var changed []string
+	for _, name := range requestFields {
+		if _, ok := fields[name]; !ok {
+			continue
+		}
+		was, now := before[name], after[name]
+		// Amenities are a set
+		if wasList, ok := was.([]any); ok {
+			if nowList, ok := now.([]any); ok {
+				was, now = sortedStrings(wasList), sortedStrings(nowList)
+			}
+		}
+		if !reflect.DeepEqual(was, now) {
+			changed = append(changed, name)
+		}
+	}
+	return changed
+}
+
+// sortedStrings returns a list's items as sorted text
+func sortedStrings(list []any) []string {
+	items := make([]string, len(list))
+	for i, v := range list {
+		items[i] = fmt.Sprint(v)
+	}
+	slices.Sort(items)
+	return items
+}
+
+// Import handles importing apartments from a CSV file, a row per
+// apartment, with a header naming each column's field as in the CSV
+// export. In the default create mode every row becomes a new apartment;
+// in upsert mode a row updates the apartment the viewer can see at its
+// address and unit instead, when there is one. A dry run reports what
+// importing would do without saving anything. Rows are imported one by
+// one, so a rejected row doesn't stop the rest.
+func (h *ApartmentHandler) Import(c *gin.Context) {
+	mode := c.DefaultQuery("mode", models.ImportCreate)
+	if mode != models.ImportCreate && mode != models.ImportUpsert {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid mode: must be create or upsert"})
+		return
+	}
+	dryRun := c.Query("dry_run") == "true"
+
+	rows, err := readImport(http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to read import")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid CSV: " + err.Error()})
+		return
+	}
+
+	viewer := viewerID(c)
+	report := models.ImportReport{Mode: mode, DryRun: dryRun, Results: []models.ImportResult{}}
+	seen := map[string]int{}
+	for _, row := range rows {
+		report.Add(h.importRow(viewer, mode, dryRun, row, seen))
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// importRow imports one row as viewer. seen holds the canonical addresses
+// of earlier rows and their lines, so an upsert can't name an apartment
+// twice.
+func (h *ApartmentHandler) importRow(viewer int64, mode string, dryRun bool, row importRow, seen map[string]int) models.ImportResult {
+	result := models.ImportResult{Line: row.line}
+	reject := func(msg string) models.ImportResult {
+		result.Status, result.Error = models.ImportRejected, msg
+		return result
+	}
+	fail := func(msg string, err error) models.ImportResult {
+		if problem := requestProblem(err); problem != "" {
+			return reject(problem)
+		}
+		log.Error().Err(err).Int("line", row.line).Msg(msg)
+		result.Status, result.Error = models.ImportFailed, msg
+		return result
+	}
+
+	data, _ := json.Marshal(row.fields)
+	var probe models.ApartmentRequest
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return reject(err.Error())
+	}
+	result.Address = probe.Address
+	if probe.Address == "" {
+		return reject("Missing address")
+	}
+	key := db.CanonicalAddress(&probe)
+
+	var existing *models.Apartment
+	if mode == models.ImportUpsert {
+		// What a later row would update depends on whether the earlier
+		// one was saved, which a dry run can't tell
+		if line, ok := seen[key]; ok {
+			return reject(fmt.Sprintf("Same address as line %d", line))
+		}
+		var err error
+		if existing, err = h.db.FindApartmentByAddress(key, viewer); err != nil {
+			return fail("Failed to find apartment", err)
+		}
+	}
+
+	var request models.ApartmentRequest
+	if existing != nil {
+		request = patchBase(existing)
+	}
+	if err := json.Unmarshal(data, &request); err != nil {
+		return reject(err.Error())
+	}
+	if err := binding.Validator.ValidateStruct(&request); err != nil {
+		return reject(err.Error())
+	}
+	if _, msg := visibilityProblem(viewer, &request, existing); msg != "" {
+		return reject(msg)
+	}
+	seen[key] = row.line
+
+	if existing == nil {
+		result.Status = models.ImportCreated
+		if dryRun {
+			return result
+		}
+		apartment, err := h.db.CreateApartment(&request)
+		if err != nil {
+			return fail("Failed to create apartment", err)
+		}
+		recordActivity(h.db, viewer, models.ActivityAdded, apartment.ID, "")
+		result.ID = apartment.ID
+		return result
+	}
+
+	result.ID = existing.ID
+	result.Changes = importChanges(existing, &request, row.fields)
+	if len(result.Changes) == 0 {
+		result.Status = models.ImportUnchanged
+		return result
+	}
+	result.Status = models.ImportUpdated
+	if dryRun {
+		return result
+	}
+	snapshot := snapshotApartment(h.db, existing.ID)
+	apartment, err := h.db.UpdateApartment(existing.ID, &request)
+	if err != nil {
+		return fail("Failed to update apartment", err)
+	}
+	if apartment == nil {
+		return reject("Apartment not found")
+	}
+	recordApartmentChanges(h.db, viewer, existing, apartment, snapshot)
+	return result
+}
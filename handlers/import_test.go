@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/mojotx/apt-eval/db"
+	"github.com/mojotx/apt-eval/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImportCreate(t *testing.T) {
+	router := newTestRouter(t)
+	csv := "id,Address,unit,price,is_gated,amenities,notes,score\n" +
+		`9,12 Elm St,4B,1500,true,"ac, balcony",Quiet,80` + "\n" +
+		`9,3 Oak Ave,,,,"[""dishwasher""]",1400,` + "\n" +
+		"10,,,1200,,,,\n" +
+		"11,5 Pine Rd,,cheap,,,,\n"
+
+	var report models.ImportReport
+	assert.Equal(t, http.StatusOK, send(t, router, http.MethodPost, "/api/apartments/import", csv, &report))
+	assert.Equal(t, models.ImportCreate, report.Mode)
+	assert.Equal(t, 2, report.Created)
+	assert.Equal(t, 2, report.Rejected)
+	if assert.Len(t, report.Results, 4) {
+		assert.Equal(t, 2, report.Results[0].Line)
+		assert.Equal(t, "Missing address", report.Results[2].Error)
+		assert.Equal(t, 5, report.Results[3].Line)
+		assert.Equal(t, models.ImportRejected, report.Results[3].Status, "the price isn't a number")
+	}
+
+	var elm models.Apartment
+	send(t, router, http.MethodGet, "/api/apartments/"+strconv.FormatInt(report.Results[0].ID, 10), "", &elm)
+	assert.Equal(t, "12 Elm St", elm.Address)
+	assert.Equal(t, "4B", elm.Unit)
+	if assert.NotNil(t, elm.Price) {
+		assert.Equal(t, 1500.0, *elm.Price)
+	}
+	assert.True(t, elm.IsGated)
+	assert.ElementsMatch(t, []string{"ac", "balcony", "gated"}, elm.Amenities, "is_gated adds its amenity")
+	assert.Equal(t, "Quiet", elm.Notes)
+	var oak models.Apartment
+	send(t, router, http.MethodGet, "/api/apartments/"+strconv.FormatInt(report.Results[1].ID, 10), "", &oak)
+	assert.Equal(t, []string{"dishwasher"}, oak.Amenities)
+	assert.Equal(t, "1400", oak.Notes, "text columns stay text")
+	assert.Nil(t, oak.Price)
+
+	// Created again, as create mode doesn't look for existing apartments
+	assert.Equal(t, http.StatusOK, send(t, router, http.MethodPost, "/api/apartments/import", "address\n12 Elm St\n12 Elm St\n", &report))
+	assert.Equal(t, 2, report.Created)
+
+	for _, body := range []string{"", "price\n1500\n", "address,price\n\"12 Elm St,1500\n"} {
+		assert.Equal(t, http.StatusBadRequest, send(t, router, http.MethodPost, "/api/apartments/import", body, nil), body)
+	}
+	assert.Equal(t, http.StatusBadRequest, send(t, router, http.MethodPost, "/api/apartments/import?mode=merge", "address\n1 Main St\n", nil))
+}
+
+func TestImportUpsert(t *testing.T) {
+	router, h := newTestApartmentHandler(t)
+	var elm, unit models.Apartment
+	send(t, router, http.MethodPost, "/api/apartments", `{"address":"12 Elm Street","price":1500,"notes":"Quiet","amenities":["balcony","ac"]}`, &elm)
+	send(t, router, http.MethodPost, "/api/apartments", `{"address":"12 Elm St","unit":"4B","price":1700}`, &unit)
+
+	csv := "address,unit,price,amenities,listing_url\n" +
+		"12 elm st,,1450,\"ac,balcony\",\n" + // Another spelling of the first, with a new price
+		"12 Elm St #4B,,1700,,\n" + // The unit, as it is
+		"12 Elm St,5A,1600,,\n" + // A unit not seen before
+		"12 Elm St,,1400,,\n" + // The first again
+		"3 Oak Ave,,,,not a url\n"
+	apartments := func() []models.Apartment {
+		list, err := h.db.ListApartments(db.ListOptions{})
+		assert.NoError(t, err)
+		return list
+	}
+
+	var preview models.ImportReport
+	assert.Equal(t, http.StatusOK, send(t, router, http.MethodPost, "/api/apartments/import?mode=upsert&dry_run=true", csv, &preview))
+	assert.True(t, preview.DryRun)
+	assert.Equal(t, []models.ImportResult{
+		{Line: 2, Address: "12 elm st", Status: models.ImportUpdated, ID: elm.ID, Changes: []string{"address", "price"}},
+		{Line: 3, Address: "12 Elm St #4B", Status: models.ImportUnchanged, ID: unit.ID},
+		{Line: 4, Address: "12 Elm St", Status: models.ImportCreated},
+		{Line: 5, Address: "12 Elm St", Status: models.ImportRejected, Error: "Same address as line 2"},
+		{Line: 6, Address: "3 Oak Ave", Status: models.ImportRejected, Error: preview.Results[4].Error},
+	}, preview.Results)
+	assert.NotEmpty(t, preview.Results[4].Error)
+	assert.Equal(t, 1, preview.Created)
+	assert.Equal(t, 1, preview.Updated)
+	assert.Equal(t, 1, preview.Unchanged)
+	assert.Equal(t, 2, preview.Rejected)
+	assert.Len(t, apartments(), 2, "a dry run saves nothing")
+	var got models.Apartment
+	send(t, router, http.MethodGet, "/api/apartments/"+strconv.FormatInt(elm.ID, 10), "", &got)
+	assert.Equal(t, 1500.0, *got.Price)
+
+	var report models.ImportReport
+	assert.Equal(t, http.StatusOK, send(t, router, http.MethodPost, "/api/apartments/import?mode=upsert", csv, &report))
+	assert.False(t, report.DryRun)
+	for i, result := range report.Results {
+		assert.Equal(t, preview.Results[i].Status, result.Status, "line %d as previewed", result.Line)
+		assert.Equal(t, preview.Results[i].Changes, result.Changes)
+	}
+	assert.Len(t, apartments(), 3)
+	send(t, router, http.MethodGet, "/api/apartments/"+strconv.FormatInt(elm.ID, 10), "", &got)
+	assert.Equal(t, "12 elm st", got.Address)
+	assert.Equal(t, 1450.0, *got.Price)
+	assert.Equal(t, "Quiet", got.Notes, "columns left out are unchanged")
+	assert.ElementsMatch(t, []string{"ac", "balcony"}, got.Amenities)
+
+	// Imported again, nothing changes
+	assert.Equal(t, http.StatusOK, send(t, router, http.MethodPost, "/api/apartments/import?mode=upsert", csv, &report))
+	assert.Equal(t, 3, report.Unchanged)
+	assert.Len(t, apartments(), 3)
+}
+
+func TestImportUpsertPrivate(t *testing.T) {
+	router, h := newTestApartmentHandler(t)
+	alex, sam := createTestUser(t, h.db, "Alex"), createTestUser(t, h.db, "Sam")
+	var private models.Apartment
+	sendAs(t, router, alex, http.MethodPost, "/api/apartments", `{"address":"1 Secret Ln","visibility":"private","price":1500}`, &private)
+
+	var report models.ImportReport
+	assert.Equal(t, http.StatusOK, sendAs(t, router, sam, http.MethodPost, "/api/apartments/import?mode=upsert", "address,price\n1 Secret Ln,1400\n", &report))
+	if assert.Len(t, report.Results, 1) {
+		assert.Equal(t, models.ImportCreated, report.Results[0].Status, "someone else's private apartment isn't matched")
+		assert.NotEqual(t, private.ID, report.Results[0].ID)
+	}
+	var got models.Apartment
+	sendAs(t, router, alex, http.MethodGet, "/api/apartments/"+strconv.FormatInt(private.ID, 10), "", &got)
+	assert.Equal(t, 1500.0, *got.Price)
+}
+
+func TestImportExportRoundTrip(t *testing.T) {
+	router := newTestRouter(t)
+	send(t, router, http.MethodPost, "/api/apartments",
+		`{"address":"12 Elm St","unit":"4B","price":1500,"rating":4,"amenities":["ac"],"parking":{"type":"garage"},"visit_date":"2026-03-01T10:00:00Z"}`, nil)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/apartments", nil)
+	req.Header.Set("Accept", "text/csv")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var report models.ImportReport
+	assert.Equal(t, http.StatusOK, send(t, router, http.MethodPost, "/api/apartments/import?mode=upsert", w.Body.String(), &report))
+	if assert.Len(t, report.Results, 1) {
+		assert.Equal(t, models.ImportUnchanged, report.Results[0].Status, "changes: %v, error: %s", report.Results[0].Changes, report.Results[0].Error)
+	}
+}
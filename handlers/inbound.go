@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mojotx/apt-eval/capture"
+	"github.com/mojotx/apt-eval/db"
+	"github.com/mojotx/apt-eval/models"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// maxInboundEmailSize caps inbound email webhook bodies, attachments
+	// included
+	maxInboundEmailSize = 10 << 20
+
+	// maxCapturedNotes caps how much of the captured text is kept in the
+	// draft's notes
+	maxCapturedNotes = 4000
+)
+
+// InboundHandler turns listing emails, posted by an email provider's
+// inbound webhook, into draft apartments
+type InboundHandler struct {
+	db    *db.DB
+	token string
+}
+
+// NewInboundHandler creates a new inbound email handler. Webhook requests
+// must carry token.
+func NewInboundHandler(db *db.DB, token string) *InboundHandler {
+	return &InboundHandler{
+		db:    db,
+		token: token,
+	}
+}
+
+// inboundEmail is the part of an inbound email webhook payload we use
+type inboundEmail struct {
+	From, Subject, Text, HTML string
+}
+
+// Field names used by the common providers: Postmark posts JSON with
+// capitalized names, Mailgun and SendGrid post forms
+var (
+	fromKeys    = []string{"From", "from", "sender"}
+	subjectKeys = []string{"Subject", "subject"}
+	textKeys    = []string{"TextBody", "stripped-text", "body-plain", "text"}
+	htmlKeys    = []string{"HtmlBody", "stripped-html", "body-html", "html"}
+)
+
+// bindInboundEmail reads a provider's webhook payload, JSON or form
+func bindInboundEmail(c *gin.Context) (*inboundEmail, error) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxInboundEmailSize)
+
+	var lookup func(key string) string
+	if c.ContentType() == gin.MIMEJSON {
+		var payload map[string]any
+		if err := json.NewDecoder(c.Request.Body).Decode(&payload); err != nil {
+			return nil, err
+		}
+		lookup = func(key string) string {
+			s, _ := payload[key].(string)
+			return s
+		}
+	} else {
+		if err := c.Request.ParseMultipartForm(maxInboundEmailSize); err != nil && err != http.ErrNotMultipart {
+			return nil, err
+		}
+		lookup = c.Request.PostFormValue
+	}
+
+	first := func(keys []string) string {
+		for _, k := range keys {
+			if v := strings.TrimSpace(lookup(k)); v != "" {
+				return v
+			}
+		}
+		return ""
+	}
+	return &inboundEmail{
+		From:    first(fromKeys),
+		Subject: first(subjectKeys),
+		Text:    first(textKeys),
+		HTML:    first(htmlKeys),
+	}, nil
+}
+
+// forwardPrefix matches the "Fwd:" and "Re:" prefixes of a subject line
+var forwardPrefix = regexp.MustCompile(`(?i)^(?:\s*(?:fwd?|re)\s*:\s*)+`)
+
+// draftFromEmail builds a draft apartment from an email, falling back to
+// the subject when no address could be found
+func draftFromEmail(email *inboundEmail) *models.ApartmentRequest {
+	listing := capture.Parse(email.Subject, email.Text, email.HTML)
+
+	req := &models.ApartmentRequest{Address: listing.Address, Status: models.StatusDraft}
+	if req.Address == "" {
+		req.Address = forwardPrefix.ReplaceAllString(email.Subject, "")
+	}
+	if req.Address == "" {
+		req.Address = "Untitled listing"
+	}
+	req.Price = listing.Price
+	if listing.URL != "" {
+		req.ListingURL = &listing.URL
+	}
+
+	body := email.Text
+	if body == "" {
+		body = strings.TrimSpace(capture.HTMLText(email.HTML))
+	}
+	req.Notes = "Captured from email"
+	if email.From != "" {
+		req.Notes += " from " + email.From
+	}
+	req.Notes += "\nSubject: " + email.Subject + "\n\n" + clipNotes(body)
+	return req
+}
+
+// clipNotes shortens captured text to fit in a draft's notes
+func clipNotes(body string) string {
+	if len(body) > maxCapturedNotes {
+		return strings.ToValidUTF8(body[:maxCapturedNotes], "") + "…"
+	}
+	return body
+}
+
+// Email handles an inbound email webhook, creating a draft apartment from
+// the listing it describes
+func (h *InboundHandler) Email(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		token = c.GetHeader("X-Inbound-Token")
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) != 1 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid inbound token"})
+		return
+	}
+
+	email, err := bindInboundEmail(c)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to read inbound email")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid inbound email payload"})
+		return
+	}
+	if email.Subject == "" && email.Text == "" && email.HTML == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Email has no subject or body"})
+		return
+	}
+
+	apartment, err := h.db.CreateApartment(draftFromEmail(email))
+	if err != nil {
+		if respondDuplicateAddress(c, h.db, err) {
+			return
+		}
+		log.Error().Err(err).Msg("Failed to create apartment from email")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create apartment"})
+		return
+	}
+
+	log.Info().Int64("id", apartment.ID).Str("from", email.From).Msg("Captured apartment from email")
+	recordActivity(h.db, 0, models.ActivityCaptured, apartment.ID, "")
+	c.JSON(http.StatusCreated, apartment)
+}
+
+// RegisterRoutes registers the inbound email route
+func (h *InboundHandler) RegisterRoutes(router *gin.Engine) {
+	router.POST("/api/inbound/email", h.Email)
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mojotx/apt-eval/db"
+	"github.com/mojotx/apt-eval/ldap"
+	"github.com/mojotx/apt-eval/models"
+	"github.com/rs/zerolog/log"
+)
+
+// directoryUsers serializes adding users on their first sign-in, so two
+// requests at once don't add the same one twice
+var directoryUsers sync.Mutex
+
+// LDAPAuth requires a user name and password, with HTTP Basic
+// authentication, that the directory accepts, on every request but those
+// to the exempt path prefixes. The user with the same name becomes the
+// viewer, in place of X-User-ID, and is added on first sign-in. Users
+// whose groups give them the admin role are admins (see AdminOnly).
+func LDAPAuth(auth *ldap.Authenticator, database *db.DB, exempt ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, prefix := range exempt {
+			if strings.HasPrefix(c.Request.URL.Path, prefix) {
+				c.Next()
+				return
+			}
+		}
+
+		username, password, ok := c.Request.BasicAuth()
+		if !ok {
+			c.Header("WWW-Authenticate", basicAuthRealm)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+			return
+		}
+		identity, err := auth.Authenticate(c.Request.Context(), username, password)
+		switch {
+		case errors.Is(err, ldap.ErrInvalidCredentials):
+			log.Warn().Str("ip", c.ClientIP()).Str("user", username).Msg("Directory sign-in failed")
+			c.Header("WWW-Authenticate", basicAuthRealm)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+			return
+		case errors.Is(err, ldap.ErrNoRole):
+			log.Warn().Str("user", username).Msg("Directory user has no role")
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Your directory account isn't allowed in"})
+			return
+		case err != nil:
+			log.Error().Err(err).Str("user", username).Msg("Failed to check directory")
+			c.AbortWithStatusJSON(http.StatusBadGateway, gin.H{"error": "Failed to check directory"})
+			return
+		}
+
+		user, err := directoryUser(database, identity)
+		if err != nil {
+			log.Error().Err(err).Str("user", username).Msg("Failed to get directory user")
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user"})
+			return
+		}
+
+		c.Set(viewerKey, user.ID)
+		c.Set(adminKey, identity.HasRole(ldap.RoleAdmin))
+		c.Next()
+	}
+}
+
+// directoryUser returns the user signed in as identity, adding them if
+// this is their first time
+func directoryUser(database *db.DB, identity *ldap.Identity) (*models.User, error) {
+	directoryUsers.Lock()
+	defer directoryUsers.Unlock()
+
+	user, err := database.FindUserByName(identity.Username)
+	if err != nil || user != nil {
+		return user, err
+	}
+	user, err = database.CreateUser(&models.UserRequest{Name: identity.Username})
+	if err != nil {
+		return nil, err
+	}
+	log.Info().Int64("id", user.ID).Str("dn", identity.DN).Msg("Added directory user")
+	return user, nil
+}
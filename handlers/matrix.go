@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mojotx/apt-eval/db"
+	"github.com/mojotx/apt-eval/filter"
+	"github.com/mojotx/apt-eval/models"
+	"github.com/mojotx/apt-eval/scoring"
+	"github.com/mojotx/apt-eval/xlsx"
+	"github.com/rs/zerolog/log"
+)
+
+// ranked lists the apartments selected by the q and ids query parameters
+// from best to worst score, responding with an error if that fails
+func (h *ApartmentHandler) ranked(c *gin.Context) ([]models.RankedApartment, scoring.Weights, bool) {
+	q := c.Query("q")
+	var ids []int64
+	if s := c.Query("ids"); s != "" {
+		var err error
+		if ids, err = parseIDList(s); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ids: " + err.Error()})
+			return nil, nil, false
+		}
+		terms := make([]string, len(ids))
+		for i, id := range ids {
+			terms[i] = fmt.Sprintf("id = %d", id)
+		}
+		q = andFilter(q, "("+strings.Join(terms, " OR ")+")")
+	}
+
+	var node filter.Node
+	if q != "" {
+		var err error
+		if node, err = filter.Parse(q, db.ApartmentFields); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query: filter: " + err.Error()})
+			return nil, nil, false
+		}
+	}
+
+	weights, err := h.db.RatingWeights()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get rating weights")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get rating weights"})
+		return nil, nil, false
+	}
+
+	apartments, err := h.db.ListApartments(db.ListOptions{
+		Filter: node,
+		Sort:   []db.SortField{{Column: "score", Desc: true}, {Column: "overall_rating", Desc: true}},
+		Viewer: viewerID(c),
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list apartments")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list apartments"})
+		return nil, nil, false
+	}
+
+	// Listed apartments must exist, unless q filtered them out
+	if c.Query("q") == "" {
+		found := make(map[int64]bool, len(apartments))
+		for _, apt := range apartments {
+			found[apt.ID] = true
+		}
+		for _, id := range ids {
+			if !found[id] {
+				c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Apartment %d not found", id)})
+				return nil, nil, false
+			}
+		}
+	}
+
+	return rankApartments(apartments, weights), weights, true
+}
+
+// rankApartments ranks apartments already ordered by score and explains
+// their scores. Equal scores share a rank, and the next rank skips past
+// them; apartments without a score get no rank.
+func rankApartments(apartments []models.Apartment, weights scoring.Weights) []models.RankedApartment {
+	ranked := make([]models.RankedApartment, 0, len(apartments))
+	for i, apt := range apartments {
+		entry := models.RankedApartment{
+			Apartment:     apt,
+			Contributions: scoring.Breakdown(apt.Ratings.ByCategory(), weights),
+		}
+		if apt.Score != nil {
+			rank := i + 1
+			if i > 0 && *ranked[i-1].Score == *apt.Score {
+				rank = *ranked[i-1].Rank
+			}
+			entry.Rank = &rank
+		}
+		ranked = append(ranked, entry)
+	}
+	return ranked
+}
+
+// decisionMatrix builds the decision matrix of ranked apartments
+func decisionMatrix(ranked []models.RankedApartment, weights scoring.Weights) models.DecisionMatrix {
+	var total float64
+	for _, category := range scoring.Categories {
+		total += weights[category]
+	}
+
+	m := models.DecisionMatrix{
+		Criteria:   make([]models.MatrixCriterion, 0, len(scoring.Categories)),
+		Apartments: make([]models.MatrixColumn, 0, len(ranked)),
+	}
+	for _, category := range scoring.Categories {
+		criterion := models.MatrixCriterion{Category: category, Weight: weights[category]}
+		if total > 0 {
+			criterion.Share = math.Round(weights[category]/total*1000) / 1000
+		}
+		m.Criteria = append(m.Criteria, criterion)
+	}
+	for _, r := range ranked {
+		m.Apartments = append(m.Apartments, models.MatrixColumn{
+			ID:      r.ID,
+			Address: r.FullAddress(),
+			Rank:    r.Rank,
+			Score:   r.Score,
+			Cells:   r.Contributions,
+		})
+	}
+	return m
+}
+
+// matrixRows lays the matrix out as a table: a header of apartments, a row
+// of ratings per criterion, the same again as weighted points, then the
+// score and rank
+func matrixRows(m models.DecisionMatrix) [][]any {
+	header := []any{"Criterion", "Weight"}
+	for _, apt := range m.Apartments {
+		header = append(header, apt.Address)
+	}
+	rows := [][]any{header}
+
+	for i, criterion := range m.Criteria {
+		row := []any{criterion.Category, criterion.Weight}
+		for _, apt := range m.Apartments {
+			row = append(row, apt.Cells[i].Rating)
+		}
+		rows = append(rows, row)
+	}
+	for i, criterion := range m.Criteria {
+		row := []any{criterion.Category + " (weighted)", nil}
+		for _, apt := range m.Apartments {
+			var points *float64
+			if apt.Cells[i].Rating != nil {
+				points = &apt.Cells[i].Points
+			}
+			row = append(row, points)
+		}
+		rows = append(rows, row)
+	}
+
+	score, rank := []any{"Score", nil}, []any{"Rank", nil}
+	for _, apt := range m.Apartments {
+		score = append(score, apt.Score)
+		rank = append(rank, apt.Rank)
+	}
+	return append(rows, score, rank)
+}
+
+// csvCell formats a matrix cell for CSV, leaving nil values empty
+func csvCell(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case *float64:
+		if v != nil {
+			return strconv.FormatFloat(*v, 'f', -1, 64)
+		}
+	case *int:
+		if v != nil {
+			return strconv.Itoa(*v)
+		}
+	}
+	return ""
+}
+
+// DecisionMatrix handles exporting the weighted decision matrix of the
+// apartments selected as in Ranked: as JSON, or as a spreadsheet when the
+// path ends in .csv or .xlsx
+func (h *ApartmentHandler) DecisionMatrix(c *gin.Context) {
+	ranked, weights, ok := h.ranked(c)
+	if !ok {
+		return
+	}
+	m := decisionMatrix(ranked, weights)
+
+	switch {
+	case strings.HasSuffix(c.Request.URL.Path, ".csv"):
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", `attachment; filename="decision-matrix.csv"`)
+		cw := csv.NewWriter(c.Writer)
+		for _, row := range matrixRows(m) {
+			record := make([]string, len(row))
+			for i, v := range row {
+				record[i] = csvCell(v)
+			}
+			cw.Write(record)
+		}
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			log.Error().Err(err).Msg("Failed to write decision matrix")
+		}
+	case strings.HasSuffix(c.Request.URL.Path, ".xlsx"):
+		c.Header("Content-Type", xlsx.ContentType)
+		c.Header("Content-Disposition", `attachment; filename="decision-matrix.xlsx"`)
+		if err := xlsx.Write(c.Writer, "Decision matrix", matrixRows(m)); err != nil {
+			log.Error().Err(err).Msg("Failed to write decision matrix")
+		}
+	default:
+		respond(c, http.StatusOK, m, nil)
+	}
+}
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mojotx/apt-eval/db"
+	"github.com/mojotx/apt-eval/models"
+	"github.com/mojotx/apt-eval/oembed"
+	"github.com/rs/zerolog/log"
+)
+
+// MediaLinkHandler handles video walkthrough and 3D tour links
+type MediaLinkHandler struct {
+	db     *db.DB
+	oembed *oembed.Client
+}
+
+// NewMediaLinkHandler creates a new media link handler
+func NewMediaLinkHandler(db *db.DB, client *oembed.Client) *MediaLinkHandler {
+	return &MediaLinkHandler{
+		db:     db,
+		oembed: client,
+	}
+}
+
+// fetch looks up a link's oEmbed metadata and copies it onto the link
+func (h *MediaLinkHandler) fetch(ctx context.Context, link *models.MediaLink) error {
+	embed, err := h.oembed.Lookup(ctx, link.URL)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	link.Title = embed.Title
+	link.AuthorName = embed.AuthorName
+	link.ThumbnailURL = embed.ThumbnailURL
+	link.EmbedHTML = embed.HTML
+	link.Width, link.Height = nil, nil
+	if embed.Width > 0 && embed.Height > 0 {
+		link.Width, link.Height = &embed.Width, &embed.Height
+	}
+	link.FetchedAt = &now
+	return nil
+}
+
+// loadMediaLink finds the media link named in the URL, responding with an
+// error if it can't
+func (h *MediaLinkHandler) loadMediaLink(c *gin.Context) (*models.MediaLink, bool) {
+	apartmentID, ok := findApartment(c, h.db)
+	if !ok {
+		return nil, false
+	}
+	id, ok := parseID(c, "media_id", "media link")
+	if !ok {
+		return nil, false
+	}
+
+	link, err := h.db.GetMediaLink(apartmentID, id)
+	if err != nil {
+		log.Error().Err(err).Int64("id", id).Msg("Failed to get media link")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get media link"})
+		return nil, false
+	}
+	if link == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Media link not found"})
+		return nil, false
+	}
+	return link, true
+}
+
+// List handles retrieving an apartment's media links
+func (h *MediaLinkHandler) List(c *gin.Context) {
+	apartmentID, ok := findApartment(c, h.db)
+	if !ok {
+		return
+	}
+
+	links, err := h.db.ListMediaLinks(apartmentID)
+	if err != nil {
+		log.Error().Err(err).Int64("apartment_id", apartmentID).Msg("Failed to list media links")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list media links"})
+		return
+	}
+	respond(c, http.StatusOK, links, nil)
+}
+
+// Create handles adding a media link. Its metadata is fetched right away;
+// if the provider can't be reached the link is still saved and can be
+// refreshed later.
+func (h *MediaLinkHandler) Create(c *gin.Context) {
+	apartmentID, ok := findApartment(c, h.db)
+	if !ok {
+		return
+	}
+
+	var request models.MediaLinkRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Error().Err(err).Msg("Failed to bind request")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	provider, err := h.oembed.Match(request.URL)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only YouTube, Vimeo, and Matterport links are supported"})
+		return
+	}
+
+	link := &models.MediaLink{ApartmentID: apartmentID, URL: request.URL, Provider: provider.Name}
+	if err := h.fetch(c.Request.Context(), link); err != nil {
+		log.Warn().Err(err).Str("url", request.URL).Msg("Failed to fetch media link metadata")
+	}
+
+	link, err = h.db.CreateMediaLink(link)
+	if err != nil {
+		log.Error().Err(err).Int64("apartment_id", apartmentID).Msg("Failed to create media link")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create media link"})
+		return
+	}
+	c.JSON(http.StatusCreated, link)
+}
+
+// Get handles retrieving a media link
+func (h *MediaLinkHandler) Get(c *gin.Context) {
+	link, ok := h.loadMediaLink(c)
+	if !ok {
+		return
+	}
+	respond(c, http.StatusOK, link, nil)
+}
+
+// Refresh handles fetching a media link's metadata again, such as after
+// the video's title changed or an earlier lookup failed
+func (h *MediaLinkHandler) Refresh(c *gin.Context) {
+	link, ok := h.loadMediaLink(c)
+	if !ok {
+		return
+	}
+
+	if err := h.fetch(c.Request.Context(), link); err != nil {
+		if errors.Is(err, oembed.ErrUnsupported) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Link is no longer supported"})
+			return
+		}
+		log.Error().Err(err).Int64("id", link.ID).Msg("Failed to fetch media link metadata")
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to fetch details from " + link.Provider})
+		return
+	}
+
+	link, err := h.db.UpdateMediaLinkMetadata(link)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to update media link")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update media link"})
+		return
+	}
+	c.JSON(http.StatusOK, link)
+}
+
+// Delete handles removing a media link
+func (h *MediaLinkHandler) Delete(c *gin.Context) {
+	apartmentID, ok := findApartment(c, h.db)
+	if !ok {
+		return
+	}
+	id, ok := parseID(c, "media_id", "media link")
+	if !ok {
+		return
+	}
+
+	if err := h.db.DeleteMediaLink(apartmentID, id); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Media link not found"})
+			return
+		}
+		log.Error().Err(err).Int64("id", id).Msg("Failed to delete media link")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete media link"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success"})
+}
+
+// RegisterRoutes registers all media link routes
+func (h *MediaLinkHandler) RegisterRoutes(router *gin.Engine) {
+	media := router.Group("/api/apartments/:id/media")
+	{
+		media.GET("", h.List)
+		media.POST("", h.Create)
+		media.GET("/:media_id", h.Get)
+		media.POST("/:media_id/refresh", h.Refresh)
+		media.DELETE("/:media_id", h.Delete)
+	}
+}
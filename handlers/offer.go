@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mojotx/apt-eval/db"
+	"github.com/mojotx/apt-eval/models"
+	"github.com/rs/zerolog/log"
+)
+
+// OfferHandler handles the negotiation log of apartments
+type OfferHandler struct {
+	db *db.DB
+}
+
+// NewOfferHandler creates a new offer handler
+func NewOfferHandler(db *db.DB) *OfferHandler {
+	return &OfferHandler{
+		db: db,
+	}
+}
+
+// List handles retrieving an apartment's negotiation log
+func (h *OfferHandler) List(c *gin.Context) {
+	apartmentID, ok := findApartment(c, h.db)
+	if !ok {
+		return
+	}
+
+	offers, err := h.db.ListOffers(apartmentID)
+	if err != nil {
+		log.Error().Err(err).Int64("apartment_id", apartmentID).Msg("Failed to list offers")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list offers"})
+		return
+	}
+	respond(c, http.StatusOK, offers, nil)
+}
+
+// Create handles logging an offer or counteroffer
+func (h *OfferHandler) Create(c *gin.Context) {
+	apartmentID, ok := findApartment(c, h.db)
+	if !ok {
+		return
+	}
+
+	var request models.OfferRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Error().Err(err).Msg("Failed to bind request")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	offer, err := h.db.CreateOffer(apartmentID, &request)
+	if err != nil {
+		log.Error().Err(err).Int64("apartment_id", apartmentID).Msg("Failed to create offer")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create offer"})
+		return
+	}
+	c.JSON(http.StatusCreated, offer)
+}
+
+// Update handles modifying an offer
+func (h *OfferHandler) Update(c *gin.Context) {
+	apartmentID, ok := findApartment(c, h.db)
+	if !ok {
+		return
+	}
+	id, ok := parseID(c, "offer_id", "offer")
+	if !ok {
+		return
+	}
+
+	var request models.OfferRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Error().Err(err).Msg("Failed to bind request")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	offer, err := h.db.UpdateOffer(apartmentID, id, &request)
+	if err != nil {
+		log.Error().Err(err).Int64("id", id).Msg("Failed to update offer")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update offer"})
+		return
+	}
+	if offer == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Offer not found"})
+		return
+	}
+	c.JSON(http.StatusOK, offer)
+}
+
+// Delete handles removing an offer
+func (h *OfferHandler) Delete(c *gin.Context) {
+	apartmentID, ok := findApartment(c, h.db)
+	if !ok {
+		return
+	}
+	id, ok := parseID(c, "offer_id", "offer")
+	if !ok {
+		return
+	}
+
+	if err := h.db.DeleteOffer(apartmentID, id); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Offer not found"})
+			return
+		}
+		log.Error().Err(err).Int64("id", id).Msg("Failed to delete offer")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete offer"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success"})
+}
+
+// RegisterRoutes registers all offer routes
+func (h *OfferHandler) RegisterRoutes(router *gin.Engine) {
+	offers := router.Group("/api/apartments/:id/offers")
+	{
+		offers.GET("", h.List)
+		offers.POST("", h.Create)
+		offers.PUT("/:offer_id", h.Update)
+		offers.DELETE("/:offer_id", h.Delete)
+	}
+}
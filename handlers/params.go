@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mojotx/apt-eval/db"
+	"github.com/mojotx/apt-eval/models"
+	"github.com/rs/zerolog/log"
+)
+
+// parseID reads an integer ID path parameter, responding with 400 if it
+// is malformed
+func parseID(c *gin.Context, param, what string) (int64, bool) {
+	idStr := c.Param(param)
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		log.Error().Err(err).Str("id", idStr).Msg("Invalid " + what + " ID")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + what + " ID"})
+		return 0, false
+	}
+	return id, true
+}
+
+// findApartment resolves the :id path parameter of an apartment
+// sub-resource, responding with 400 or 404 when it doesn't name an
+// existing apartment
+func findApartment(c *gin.Context, database *db.DB) (int64, bool) {
+	apartment, ok := loadApartment(c, database)
+	if !ok {
+		return 0, false
+	}
+	return apartment.ID, true
+}
+
+// loadApartment is findApartment for handlers that need the apartment
+// itself
+func loadApartment(c *gin.Context, database *db.DB) (*models.Apartment, bool) {
+	id, ok := parseID(c, "id", "apartment")
+	if !ok {
+		return nil, false
+	}
+
+	apartment, err := database.GetApartment(id)
+	if err != nil {
+		log.Error().Err(err).Int64("id", id).Msg("Failed to get apartment")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get apartment"})
+		return nil, false
+	}
+	if apartment == nil || !apartment.VisibleTo(viewerID(c)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Apartment not found"})
+		return nil, false
+	}
+	return apartment, true
+}
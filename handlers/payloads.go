@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// LogPayloads logs the request and response bodies of API calls at debug
+// level, for working out what a client integration sends and gets back.
+// Bodies are cut off and redacted as in crash reports, so notes, contact
+// details, and credentials never reach the log. Paths under any of
+// excluded aren't logged at all. Nothing is recorded while the log level
+// is above debug, so the cost is only paid while it's being looked at.
+func LogPayloads(excluded ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		path := c.Request.URL.Path
+		if !isAPIPath(path) || isExcludedPath(path, excluded) || !log.Debug().Enabled() {
+			c.Next()
+			return
+		}
+
+		request := &recordingBody{}
+		if c.Request.Body != nil && c.Request.Body != http.NoBody {
+			request.ReadCloser = c.Request.Body
+			c.Request.Body = request
+		}
+		response := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = response
+		start := time.Now()
+
+		c.Next()
+
+		log.Debug().
+			Str("request_id", requestID(c)).
+			Str("method", c.Request.Method).
+			Str("path", path).
+			Str("route", c.FullPath()).
+			Int("status", response.Status()).
+			Dur("duration", time.Since(start)).
+			Str("request_body", sanitizeBody(c.ContentType(), request)).
+			Str("response_body", sanitizeBody(response.Header().Get("Content-Type"), &response.body)).
+			Msg("API payloads")
+	}
+}
+
+// isExcludedPath reports whether path is, or is under, one of prefixes
+func isExcludedPath(path string, prefixes []string) bool {
+	for _, p := range prefixes {
+		p = strings.TrimSuffix(p, "/")
+		if path == p || strings.HasPrefix(path, p+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// recordingWriter keeps the first maxLoggedBody bytes of a response as
+// it's written
+type recordingWriter struct {
+	gin.ResponseWriter
+	body recordingBody
+}
+
+func (w *recordingWriter) Write(p []byte) (int, error) {
+	w.body.record(p)
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.record([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mojotx/apt-eval/db"
+	"github.com/mojotx/apt-eval/filter"
+	"github.com/mojotx/apt-eval/llm"
+	"github.com/mojotx/apt-eval/models"
+	"github.com/mojotx/apt-eval/nlquery"
+	"github.com/rs/zerolog/log"
+)
+
+// QueryHandler handles natural-language questions about the apartments
+type QueryHandler struct {
+	db       *db.DB
+	provider llm.Provider // nil when no provider is configured
+}
+
+// NewQueryHandler creates a new query handler
+func NewQueryHandler(db *db.DB, provider llm.Provider) *QueryHandler {
+	return &QueryHandler{
+		db:       db,
+		provider: provider,
+	}
+}
+
+// queryRequest is the body of a natural-language query
+type queryRequest struct {
+	Question string `json:"question" binding:"required,max=500"`
+	// Mode picks the interpreter: "rules", "llm", or empty for the
+	// language model when one is configured and the rules otherwise
+	Mode string `json:"mode" binding:"omitempty,oneof=rules llm"`
+}
+
+// interpreted is a question's interpretation along with what produced it
+type interpreted struct {
+	*nlquery.Query
+	Source string `json:"source"` // "rules" or "llm"
+}
+
+// interpret turns a question into a query, falling back to the rules when
+// the language model fails and wasn't asked for specifically
+func (h *QueryHandler) interpret(c *gin.Context, request *queryRequest) (*interpreted, error) {
+	if request.Mode == "rules" || (request.Mode == "" && h.provider == nil) {
+		return &interpreted{nlquery.Parse(request.Question), "rules"}, nil
+	}
+
+	amenities, err := h.db.ListAmenities()
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for _, a := range amenities {
+		if a.Key != models.AmenityGarage { // Now part of the parking details
+			keys = append(keys, a.Key)
+		}
+	}
+
+	q, err := nlquery.Translate(c.Request.Context(), h.provider, request.Question, db.ApartmentFields, keys)
+	if err != nil {
+		if request.Mode == "llm" {
+			return nil, err
+		}
+		log.Warn().Err(err).Str("provider", h.provider.Name()).Str("question", request.Question).
+			Msg("Failed to translate question, falling back to rules")
+		return &interpreted{nlquery.Parse(request.Question), "rules"}, nil
+	}
+	return &interpreted{q, "llm"}, nil
+}
+
+// Query handles answering a question like "cheapest 2-bed with laundry
+// under 25 min commute" with the matching apartments and the filter it was
+// read as, so the interpretation can be checked and refined by hand
+func (h *QueryHandler) Query(c *gin.Context) {
+	var request queryRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if request.Mode == "llm" && h.provider == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "No LLM provider is configured"})
+		return
+	}
+
+	in, err := h.interpret(c, &request)
+	if err != nil {
+		log.Error().Err(err).Str("question", request.Question).Msg("Failed to interpret question")
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to interpret question"})
+		return
+	}
+
+	opts := db.ListOptions{Limit: maxPageSize, Viewer: viewerID(c)}
+	if in.Limit > 0 && in.Limit < maxPageSize {
+		opts.Limit = in.Limit
+	}
+	if in.Filter != "" {
+		node, err := filter.Parse(in.Filter, db.ApartmentFields)
+		if err != nil {
+			log.Error().Err(err).Str("question", request.Question).Str("q", in.Filter).Msg("Interpreted filter is invalid")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Interpreted filter is invalid: %v", err)})
+			return
+		}
+		opts.Filter = node
+	}
+	if in.Sort != "" {
+		sort, err := parseSort(in.Sort)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Interpreted sort is invalid: %v", err)})
+			return
+		}
+		opts.Sort = sort
+	}
+	opts.Amenities = in.Amenities
+
+	apartments, err := h.db.ListApartments(opts)
+	if err != nil {
+		if respondUnknownAmenity(c, err) {
+			return
+		}
+		log.Error().Err(err).Msg("Failed to list apartments")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list apartments"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"question":    request.Question,
+		"interpreted": in,
+		"apartments":  apartments,
+	})
+}
+
+// RegisterRoutes registers all query routes
+func (h *QueryHandler) RegisterRoutes(router *gin.Engine) {
+	router.POST("/api/query", h.Query)
+}
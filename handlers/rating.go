@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mojotx/apt-eval/db"
+	"github.com/mojotx/apt-eval/scoring"
+	"github.com/rs/zerolog/log"
+)
+
+// RatingHandler handles the category weights behind apartment scores
+type RatingHandler struct {
+	db *db.DB
+}
+
+// NewRatingHandler creates a new rating handler
+func NewRatingHandler(db *db.DB) *RatingHandler {
+	return &RatingHandler{
+		db: db,
+	}
+}
+
+// GetWeights handles retrieving the category weights
+func (h *RatingHandler) GetWeights(c *gin.Context) {
+	weights, err := h.db.RatingWeights()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get rating weights")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get rating weights"})
+		return
+	}
+	respond(c, http.StatusOK, weights, nil)
+}
+
+// UpdateWeights handles changing category weights, which rescores every
+// rated apartment. Categories left out of the request keep their weight.
+func (h *RatingHandler) UpdateWeights(c *gin.Context) {
+	var request scoring.Weights
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Error().Err(err).Msg("Failed to bind request")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	current, err := h.db.RatingWeights()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get rating weights")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get rating weights"})
+		return
+	}
+	merged := scoring.Weights{}
+	for category, weight := range current {
+		merged[category] = weight
+	}
+	for category, weight := range request {
+		merged[category] = weight
+	}
+	if err := merged.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	weights, err := h.db.SetRatingWeights(request)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to update rating weights")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update rating weights"})
+		return
+	}
+	c.JSON(http.StatusOK, weights)
+}
+
+// RegisterRoutes registers all rating routes
+func (h *RatingHandler) RegisterRoutes(router *gin.Engine) {
+	ratings := router.Group("/api/ratings")
+	{
+		ratings.GET("/weights", h.GetWeights)
+		ratings.PUT("/weights", h.UpdateWeights)
+	}
+}
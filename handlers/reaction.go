@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mojotx/apt-eval/db"
+	"github.com/mojotx/apt-eval/models"
+	"github.com/rs/zerolog/log"
+)
+
+// ReactionHandler handles household members' reactions to apartments
+type ReactionHandler struct {
+	db *db.DB
+}
+
+// NewReactionHandler creates a new reaction handler
+func NewReactionHandler(db *db.DB) *ReactionHandler {
+	return &ReactionHandler{
+		db: db,
+	}
+}
+
+// List handles retrieving who reacted to an apartment and how
+func (h *ReactionHandler) List(c *gin.Context) {
+	apartmentID, ok := findApartment(c, h.db)
+	if !ok {
+		return
+	}
+
+	reactions, err := h.db.ListReactions(apartmentID)
+	if err != nil {
+		log.Error().Err(err).Int64("apartment_id", apartmentID).Msg("Failed to list reactions")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list reactions"})
+		return
+	}
+	respond(c, http.StatusOK, reactions, nil)
+}
+
+// parseReaction resolves the apartment, the :reaction path parameter, and
+// the reacting user, responding with an error when any is missing
+func (h *ReactionHandler) parseReaction(c *gin.Context) (apartmentID, userID int64, reaction string, ok bool) {
+	if apartmentID, ok = findApartment(c, h.db); !ok {
+		return
+	}
+	if reaction, ok = models.ParseReaction(c.Param("reaction")); !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown reaction"})
+		return
+	}
+	if userID = viewerID(c); userID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Reacting needs a user: send X-User-ID"})
+		return 0, 0, "", false
+	}
+	return
+}
+
+// respondCounts responds with an apartment's reaction counts
+func (h *ReactionHandler) respondCounts(c *gin.Context, apartmentID int64) {
+	apartment, err := h.db.GetApartment(apartmentID)
+	if err != nil {
+		log.Error().Err(err).Int64("id", apartmentID).Msg("Failed to get apartment")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get apartment"})
+		return
+	}
+	if apartment == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Apartment not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"reactions": apartment.Reactions})
+}
+
+// Add handles reacting to an apartment as the X-User-ID user
+func (h *ReactionHandler) Add(c *gin.Context) {
+	apartmentID, userID, reaction, ok := h.parseReaction(c)
+	if !ok {
+		return
+	}
+
+	added, err := h.db.AddReaction(apartmentID, userID, reaction)
+	if err != nil {
+		log.Error().Err(err).Int64("apartment_id", apartmentID).Msg("Failed to add reaction")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add reaction"})
+		return
+	}
+	if added {
+		recordActivity(h.db, userID, models.ActivityReacted, apartmentID, reaction)
+	}
+	h.respondCounts(c, apartmentID)
+}
+
+// Remove handles taking back a reaction of the X-User-ID user
+func (h *ReactionHandler) Remove(c *gin.Context) {
+	apartmentID, userID, reaction, ok := h.parseReaction(c)
+	if !ok {
+		return
+	}
+
+	if err := h.db.RemoveReaction(apartmentID, userID, reaction); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Reaction not found"})
+			return
+		}
+		log.Error().Err(err).Int64("apartment_id", apartmentID).Msg("Failed to remove reaction")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove reaction"})
+		return
+	}
+	h.respondCounts(c, apartmentID)
+}
+
+// RegisterRoutes registers all reaction routes
+func (h *ReactionHandler) RegisterRoutes(router *gin.Engine) {
+	reactions := router.Group("/api/apartments/:id/reactions")
+	{
+		reactions.GET("", h.List)
+		reactions.PUT("/:reaction", h.Add)
+		reactions.DELETE("/:reaction", h.Remove)
+	}
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mojotx/apt-eval/db"
+	"github.com/mojotx/apt-eval/health"
+	"github.com/rs/zerolog/log"
+)
+
+// Readiness statuses
+const (
+	readyStatus       = "ready"
+	degradedStatus    = "degraded"    // Serving, with an integration failing
+	unavailableStatus = "unavailable" // The database doesn't answer
+)
+
+// Ready handles the readiness check: whether the database answers, and how
+// calls to each configured integration have been going, so a dead
+// geocoder shows up before apartments quietly stop being located. Only
+// the database failing is answered with 503; the app still serves without
+// its integrations. Errors aren't shown, as the check is public; they're
+// in the log.
+func Ready(database *db.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		status, code := readyStatus, http.StatusOK
+		dbStatus := health.StatusOK
+		if err := database.PingContext(c.Request.Context()); err != nil {
+			log.Error().Err(err).Msg("Readiness check failed to reach the database")
+			status, code, dbStatus = unavailableStatus, http.StatusServiceUnavailable, health.StatusFailing
+		}
+
+		integrations := health.Report()
+		for _, i := range integrations {
+			if i.Status == health.StatusFailing && status == readyStatus {
+				status = degradedStatus
+			}
+		}
+		c.JSON(code, gin.H{
+			"status":       status,
+			"time":         time.Now().Unix(),
+			"database":     dbStatus,
+			"integrations": integrations,
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"runtime/debug"
+	"strings"
+	"syscall"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// maxLoggedBody is how much of a request body is kept for a crash report
+const maxLoggedBody = 4 << 10
+
+// sensitiveFields are parts of field names whose values are never logged:
+// credentials, and the contact details that are encrypted at rest
+var sensitiveFields = []string{
+	"password", "passwd", "secret", "token", "key", "auth", "cookie", "session",
+	"phone", "email", "contact", "notes", "ssn",
+}
+
+// Recovery answers a request whose handler panicked with the standard
+// error, after logging a crash report: the panic, its stack trace, the
+// request ID, the route, and the start of the request body with
+// credentials and contact details redacted. A client hanging up while the
+// response is written isn't a crash, and is only noted.
+func Recovery() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body := &recordingBody{}
+		if c.Request.Body != nil && c.Request.Body != http.NoBody {
+			body.ReadCloser = c.Request.Body
+			c.Request.Body = body
+		}
+
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			// net/http aborts the connection quietly for this one
+			if rec == http.ErrAbortHandler {
+				panic(rec)
+			}
+
+			if err, ok := rec.(error); ok && isBrokenPipe(err) {
+				log.Warn().Err(err).
+					Str("request_id", requestID(c)).
+					Str("path", c.Request.URL.Path).
+					Msg("Client went away mid-response")
+				c.Error(err)
+				c.Abort()
+				return
+			}
+
+			log.Error().
+				Str("panic", fmt.Sprint(rec)).
+				Str("request_id", requestID(c)).
+				Str("method", c.Request.Method).
+				Str("path", c.Request.URL.Path).
+				Str("route", c.FullPath()).
+				Str("body", sanitizeBody(c.ContentType(), body)).
+				Str("stack", string(debug.Stack())).
+				Msg("Handler panicked")
+			Recovered(c, rec)
+		}()
+
+		c.Next()
+	}
+}
+
+// isBrokenPipe reports whether err is the client closing its connection
+// while the response was being written
+func isBrokenPipe(err error) bool {
+	var opErr *net.OpError
+	if !errors.As(err, &opErr) {
+		return false
+	}
+	var sysErr *os.SyscallError
+	if errors.As(opErr, &sysErr) {
+		return errors.Is(sysErr.Err, syscall.EPIPE) || errors.Is(sysErr.Err, syscall.ECONNRESET)
+	}
+	return false
+}
+
+// recordingBody keeps the first maxLoggedBody bytes a handler reads of a
+// request body, so a crash report can show what it was working on without
+// reading bodies no handler asked for
+type recordingBody struct {
+	io.ReadCloser
+	data      []byte
+	truncated bool
+}
+
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.record(p[:n])
+	return n, err
+}
+
+// record keeps as much of p as there's room for
+func (b *recordingBody) record(p []byte) {
+	if room := maxLoggedBody - len(b.data); len(p) > room {
+		b.data = append(b.data, p[:room]...)
+		b.truncated = true
+	} else {
+		b.data = append(b.data, p...)
+	}
+}
+
+// sanitizeBody describes a request body for the log. JSON and forms are
+// shown with the values of sensitive fields redacted; anything else, or a
+// body too long to parse whole, only by its size and type.
+func sanitizeBody(contentType string, body *recordingBody) string {
+	if len(body.data) == 0 {
+		return ""
+	}
+	summary := fmt.Sprintf("<%d bytes of %s>", len(body.data), contentType)
+	if body.truncated {
+		return summary
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "application/json":
+		var v any
+		if err := json.Unmarshal(body.data, &v); err != nil {
+			return summary
+		}
+		out, err := json.Marshal(redactJSON(v))
+		if err != nil {
+			return summary
+		}
+		return string(out)
+	case "application/x-www-form-urlencoded":
+		values, err := url.ParseQuery(string(body.data))
+		if err != nil {
+			return summary
+		}
+		for k := range values {
+			if isSensitiveField(k) {
+				values[k] = []string{"[redacted]"}
+			}
+		}
+		return values.Encode()
+	}
+	return summary
+}
+
+// redactJSON replaces the values of sensitive fields throughout a decoded
+// JSON value
+func redactJSON(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, field := range v {
+			if isSensitiveField(k) {
+				v[k] = "[redacted]"
+			} else {
+				v[k] = redactJSON(field)
+			}
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = redactJSON(item)
+		}
+	}
+	return v
+}
+
+func isSensitiveField(name string) bool {
+	name = strings.ToLower(name)
+	for _, s := range sensitiveFields {
+		if strings.Contains(name, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Renderer encodes a response document in a particular media type
+type Renderer interface {
+	// ContentTypes lists the media types this renderer answers to; the
+	// first is used for the Content-Type header
+	ContentTypes() []string
+
+	// Render writes doc to w. name is a singular noun describing the
+	// payload (e.g. "apartment"), for formats that need element names.
+	Render(w io.Writer, name string, doc any) error
+}
+
+// renderers holds the registered renderers in order of preference; the
+// first entry is used when the client expresses no preference
+var renderers = []Renderer{
+	jsonRenderer{},
+	xmlRenderer{},
+	csvRenderer{},
+}
+
+// RegisterRenderer adds a renderer to the negotiation registry
+func RegisterRenderer(r Renderer) {
+	renderers = append(renderers, r)
+}
+
+// offeredTypes returns every media type the registry can produce
+func offeredTypes() []string {
+	var types []string
+	for _, r := range renderers {
+		types = append(types, r.ContentTypes()...)
+	}
+	return types
+}
+
+// rendererFor returns the renderer registered for a media type
+func rendererFor(contentType string) Renderer {
+	for _, r := range renderers {
+		for _, t := range r.ContentTypes() {
+			if t == contentType {
+				return r
+			}
+		}
+	}
+	return nil
+}
+
+// negotiate picks the offered media type best matching an Accept header,
+// honoring q-values and wildcards. An empty header selects the first
+// offered type; no acceptable match returns "".
+func negotiate(accept string, offered []string) string {
+	if strings.TrimSpace(accept) == "" {
+		return offered[0]
+	}
+
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+
+		q := 1.0
+		for _, p := range strings.Split(params, ";") {
+			k, v, ok := strings.Cut(strings.TrimSpace(p), "=")
+			if ok && strings.TrimSpace(k) == "q" {
+				if parsed, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		if q <= bestQ {
+			continue
+		}
+
+		for _, o := range offered {
+			if mediaTypeMatches(mediaType, o) {
+				best, bestQ = o, q
+				break
+			}
+		}
+	}
+	return best
+}
+
+// mediaTypeMatches reports whether an Accept range such as "text/*"
+// covers the offered media type
+func mediaTypeMatches(pattern, offered string) bool {
+	if pattern == "*/*" || pattern == offered {
+		return true
+	}
+	prefix, ok := strings.CutSuffix(pattern, "/*")
+	return ok && strings.HasPrefix(offered, prefix+"/")
+}
+
+// payloadName derives the singular element name for data from its Go type,
+// e.g. []models.Apartment becomes "apartment"
+func payloadName(data any) string {
+	t := reflect.TypeOf(data)
+	for t != nil && (t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice) {
+		t = t.Elem()
+	}
+	if t == nil || t.Name() == "" || t.Kind() == reflect.Map {
+		return "item"
+	}
+
+	var sb strings.Builder
+	for i, r := range t.Name() {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				sb.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// jsonRenderer is the default encoding
+type jsonRenderer struct{}
+
+func (jsonRenderer) ContentTypes() []string {
+	return []string{"application/json"}
+}
+
+func (jsonRenderer) Render(w io.Writer, _ string, doc any) error {
+	return json.NewEncoder(w).Encode(doc)
+}
+
+// xmlRenderer encodes objects as elements named after their keys
+type xmlRenderer struct{}
+
+func (xmlRenderer) ContentTypes() []string {
+	return []string{"application/xml", "text/xml"}
+}
+
+func (xmlRenderer) Render(w io.Writer, name string, doc any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	tag, itemTag := name, "item"
+	if _, ok := doc.([]any); ok {
+		tag, itemTag = name+"s", name
+	}
+	if err := writeXMLElement(enc, xmlElement(tag), itemTag, doc); err != nil {
+		return err
+	}
+	return enc.Flush()
+}
+
+// writeXMLElement writes start, value, and the matching end; array members
+// use itemTag
+func writeXMLElement(enc *xml.Encoder, start xml.StartElement, itemTag string, value any) error {
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+
+	switch v := value.(type) {
+	case nil:
+	case object:
+		for _, m := range v {
+			if err := writeXMLElement(enc, xmlElement(m.Key), "item", m.Value); err != nil {
+				return err
+			}
+		}
+	case []any:
+		for _, item := range v {
+			if err := writeXMLElement(enc, xmlElement(itemTag), "item", item); err != nil {
+				return err
+			}
+		}
+	default:
+		if err := enc.EncodeToken(xml.CharData(scalarString(v))); err != nil {
+			return err
+		}
+	}
+
+	return enc.EncodeToken(start.End())
+}
+
+// xmlElement returns the start of the element for an object key. Keys
+// that aren't XML names, like the free-form questions of answers, become
+// <item key="...">.
+func xmlElement(key string) xml.StartElement {
+	if isXMLName(key) {
+		return xml.StartElement{Name: xml.Name{Local: key}}
+	}
+	return xml.StartElement{
+		Name: xml.Name{Local: "item"},
+		Attr: []xml.Attr{{Name: xml.Name{Local: "key"}, Value: key}},
+	}
+}
+
+// isXMLName reports whether s can name an element: a letter or underscore
+// followed by letters, digits, and "_-.", without the reserved xml prefix
+func isXMLName(s string) bool {
+	if s == "" || strings.HasPrefix(strings.ToLower(s), "xml") {
+		return false
+	}
+	for i, r := range s {
+		switch {
+		case unicode.IsLetter(r) || r == '_':
+		case i > 0 && (unicode.IsDigit(r) || r == '-' || r == '.'):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// csvRenderer encodes a list of objects as a table with a header row
+type csvRenderer struct{}
+
+func (csvRenderer) ContentTypes() []string {
+	return []string{"text/csv"}
+}
+
+func (csvRenderer) Render(w io.Writer, _ string, doc any) error {
+	rows, ok := doc.([]any)
+	if !ok {
+		rows = []any{doc}
+	}
+
+	cw := csv.NewWriter(w)
+	var header []string
+	for i, row := range rows {
+		obj, ok := row.(object)
+		if !ok {
+			return fmt.Errorf("csv rendering requires objects, got %T", row)
+		}
+		if i == 0 {
+			for _, m := range obj {
+				header = append(header, m.Key)
+			}
+			if err := cw.Write(header); err != nil {
+				return err
+			}
+		}
+
+		// Match cells to columns by key, in case rows differ in shape
+		record := make([]string, len(header))
+		for _, m := range obj {
+			if j := slices.Index(header, m.Key); j >= 0 {
+				record[j] = cellString(m.Value)
+			}
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// cellString flattens a document value into a single CSV cell
+func cellString(v any) string {
+	switch v.(type) {
+	case object, []any:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+	return scalarString(v)
+}
+
+// scalarString formats a scalar document value as text
+func scalarString(v any) string {
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"io"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/ugorji/go/codec"
+)
+
+// Compact binary encodings for bandwidth-sensitive clients, such as the
+// mobile app over cellular. They're negotiated through Accept like any other
+// renderer.
+func init() {
+	RegisterRenderer(msgpackRenderer{})
+	RegisterRenderer(cborRenderer{})
+}
+
+// binaryRenderer is implemented by renderers whose output is not text, so
+// no charset parameter is added to their Content-Type
+type binaryRenderer interface {
+	binary()
+}
+
+// msgpackRenderer encodes responses as MessagePack
+type msgpackRenderer struct{}
+
+func (msgpackRenderer) binary() {}
+
+func (msgpackRenderer) ContentTypes() []string {
+	return []string{"application/msgpack", "application/x-msgpack", "application/vnd.msgpack"}
+}
+
+func (msgpackRenderer) Render(w io.Writer, _ string, doc any) error {
+	handle := &codec.MsgpackHandle{}
+	handle.WriteExt = true
+	return codec.NewEncoder(w, handle).Encode(plain(doc))
+}
+
+// cborRenderer encodes responses as CBOR (RFC 8949)
+type cborRenderer struct{}
+
+func (cborRenderer) binary() {}
+
+func (cborRenderer) ContentTypes() []string {
+	return []string{"application/cbor"}
+}
+
+func (cborRenderer) Render(w io.Writer, _ string, doc any) error {
+	return cbor.NewEncoder(w).Encode(plain(doc))
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegotiate(t *testing.T) {
+	offered := []string{"application/json", "application/xml", "text/csv"}
+
+	tests := []struct {
+		accept   string
+		expected string
+	}{
+		{"", "application/json"},
+		{"*/*", "application/json"},
+		{"text/csv", "text/csv"},
+		{"text/*", "text/csv"},
+		{"application/xml, application/json", "application/xml"},
+		{"text/csv;q=0.5, application/json", "application/json"},
+		{"application/json;q=0.1, application/xml;q=0.9", "application/xml"},
+		{"image/png", ""},
+		{"text/csv;q=0", ""},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, negotiate(tt.accept, offered), "Accept: %q", tt.accept)
+	}
+}
+
+func TestXMLRendererKeys(t *testing.T) {
+	doc := object{
+		{"address", "1 Main St"},
+		{"answers", object{
+			{"Pets allowed?", "yes"},
+			{"a><script>alert(1)</script><b", "no"},
+			{"xmlns", "x"},
+		}},
+		{"tags", []any{"quiet", "<sunny>"}},
+		{"rating", nil},
+	}
+	var buf bytes.Buffer
+	assert.NoError(t, xmlRenderer{}.Render(&buf, "apartment", doc))
+
+	dec := xml.NewDecoder(&buf)
+	var elements []string
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if !assert.NoError(t, err, "well-formed") {
+			return
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			name := start.Name.Local
+			for _, a := range start.Attr {
+				name += "[" + a.Name.Local + "=" + a.Value + "]"
+			}
+			elements = append(elements, name)
+		}
+	}
+	assert.Equal(t, []string{
+		"apartment", "address", "answers",
+		"item[key=Pets allowed?]", "item[key=a><script>alert(1)</script><b]", "item[key=xmlns]",
+		"tags", "item", "item", "rating",
+	}, elements, "keys that aren't XML names are attributes")
+}
+
+func TestRespondAcrossHandlers(t *testing.T) {
+	router, h := newTestApartmentHandler(t)
+	NewVisitHandler(h.db).RegisterRoutes(router)
+	NewAmenityHandler(h.db).RegisterRoutes(router)
+	send(t, router, http.MethodPost, "/api/apartments", `{"address":"1 Main St"}`, nil)
+	send(t, router, http.MethodPost, "/api/apartments/1/visits", `{"notes":"Sunny kitchen"}`, nil)
+
+	get := func(target, accept string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("Accept", accept)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("/api/apartments/1/visits", "text/csv")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), "Sunny kitchen")
+
+	w = get("/api/amenities", "application/xml")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/xml; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.NoError(t, xml.Unmarshal(w.Body.Bytes(), new(struct{})), "well-formed")
+
+	assert.Equal(t, http.StatusNotAcceptable, get("/api/apartments/1/visits", "image/png").Code)
+	w = get("/api/apartments/1/visits", "")
+	assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"), "JSON by default")
+}
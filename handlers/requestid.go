@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader carries the ID of a request, from the client or a proxy
+// in front of the app, and back in the response
+const RequestIDHeader = "X-Request-ID"
+
+// requestIDKey is the context key holding the request's ID
+const requestIDKey = "request_id"
+
+// maxRequestIDLength is the longest request ID taken from a client
+const maxRequestIDLength = 64
+
+// RequestID gives every request an ID, for matching what a user saw with
+// the logs. An ID sent in X-Request-ID is kept when it's short and plain;
+// otherwise a random one is made up.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		c.Set(requestIDKey, id)
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
+
+// requestID returns the ID RequestID gave the request, or ""
+func requestID(c *gin.Context) string {
+	return c.GetString(requestIDKey)
+}
+
+// validRequestID reports whether id is fit to echo back and log: letters,
+// digits, dashes, underscores, and dots
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID returns a random 128-bit ID in hex
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
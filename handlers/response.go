@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mojotx/apt-eval/models"
+	"github.com/rs/zerolog/log"
+)
+
+// apartmentFields is the set of JSON keys a client may request via ?fields=
+var apartmentFields = jsonFieldNames(reflect.TypeOf(models.Apartment{}))
+
+// jsonFieldNames returns the JSON keys produced when encoding a struct type
+func jsonFieldNames(t reflect.Type) map[string]bool {
+	names := make(map[string]bool)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		names[name] = true
+	}
+	return names
+}
+
+// parseFields reads the comma-separated ?fields= parameter, validating each
+// entry against allowed. A nil result means the full representation.
+func parseFields(c *gin.Context, allowed map[string]bool) ([]string, error) {
+	raw := c.Query("fields")
+	if raw == "" {
+		return nil, nil
+	}
+
+	var fields []string
+	for _, f := range strings.Split(raw, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		if !allowed[f] {
+			return nil, fmt.Errorf("unknown field %q", f)
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// shape reduces a document to the requested keys, in the requested order
+func shape(doc any, fields []string) any {
+	if len(fields) == 0 {
+		return doc
+	}
+
+	pick := func(v any) any {
+		obj, ok := v.(object)
+		if !ok {
+			return v
+		}
+		out := make(object, 0, len(fields))
+		for _, f := range fields {
+			for _, m := range obj {
+				if m.Key == f {
+					out = append(out, m)
+					break
+				}
+			}
+		}
+		return out
+	}
+
+	if list, ok := doc.([]any); ok {
+		for i := range list {
+			list[i] = pick(list[i])
+		}
+		return list
+	}
+	return pick(doc)
+}
+
+// respond writes data in the representation negotiated from the Accept
+// header, reduced to the selected fields if any. Field selection works on
+// the encoded form so every model gets it without bespoke queries.
+func respond(c *gin.Context, status int, data any, fields []string) {
+	renderer := rendererFor(negotiate(c.GetHeader("Accept"), offeredTypes()))
+	if renderer == nil {
+		c.JSON(http.StatusNotAcceptable, gin.H{"error": "Unsupported media type requested", "supported": offeredTypes()})
+		return
+	}
+
+	doc, err := toDocument(data)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to encode response")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encode response"})
+		return
+	}
+	doc = shape(doc, fields)
+
+	var buf bytes.Buffer
+	if err := renderer.Render(&buf, payloadName(data), doc); err != nil {
+		log.Error().Err(err).Msg("Failed to render response")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encode response"})
+		return
+	}
+
+	contentType := renderer.ContentTypes()[0]
+	if _, ok := renderer.(binaryRenderer); !ok {
+		contentType += "; charset=utf-8"
+	}
+
+	c.Writer.Header().Add("Vary", "Accept")
+	c.Data(status, contentType, buf.Bytes())
+}
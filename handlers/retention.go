@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mojotx/apt-eval/db"
+	"github.com/rs/zerolog/log"
+)
+
+// RetentionHandler reports what the data retention policy would do
+type RetentionHandler struct {
+	db     *db.DB
+	policy db.RetentionPolicy
+}
+
+// NewRetentionHandler creates a new retention handler
+func NewRetentionHandler(db *db.DB, policy db.RetentionPolicy) *RetentionHandler {
+	return &RetentionHandler{
+		db:     db,
+		policy: policy,
+	}
+}
+
+// Report handles a dry run of the retention policy, listing what it would
+// archive, purge, and prune if it ran now
+func (h *RetentionHandler) Report(c *gin.Context) {
+	report, err := h.db.ApplyRetention(h.policy, time.Now(), true)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to report on retention")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to report on retention"})
+		return
+	}
+	respond(c, http.StatusOK, report, nil)
+}
+
+// RegisterRoutes registers the retention report route
+func (h *RetentionHandler) RegisterRoutes(router *gin.Engine) {
+	router.GET("/api/admin/retention", h.Report)
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mojotx/apt-eval/db"
+	"github.com/mojotx/apt-eval/models"
+	"github.com/rs/zerolog/log"
+)
+
+// RoomHandler handles the rooms sub-resource of apartments
+type RoomHandler struct {
+	db *db.DB
+}
+
+// NewRoomHandler creates a new room handler
+func NewRoomHandler(db *db.DB) *RoomHandler {
+	return &RoomHandler{
+		db: db,
+	}
+}
+
+// List handles retrieving an apartment's rooms
+func (h *RoomHandler) List(c *gin.Context) {
+	apartmentID, ok := findApartment(c, h.db)
+	if !ok {
+		return
+	}
+
+	rooms, err := h.db.ListRooms(apartmentID)
+	if err != nil {
+		log.Error().Err(err).Int64("apartment_id", apartmentID).Msg("Failed to list rooms")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list rooms"})
+		return
+	}
+	respond(c, http.StatusOK, rooms, nil)
+}
+
+// Create handles adding a room
+func (h *RoomHandler) Create(c *gin.Context) {
+	apartmentID, ok := findApartment(c, h.db)
+	if !ok {
+		return
+	}
+
+	var request models.RoomRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Error().Err(err).Msg("Failed to bind request")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	room, err := h.db.CreateRoom(apartmentID, &request)
+	if err != nil {
+		log.Error().Err(err).Int64("apartment_id", apartmentID).Msg("Failed to create room")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create room"})
+		return
+	}
+	c.JSON(http.StatusCreated, room)
+}
+
+// Update handles modifying a room
+func (h *RoomHandler) Update(c *gin.Context) {
+	apartmentID, ok := findApartment(c, h.db)
+	if !ok {
+		return
+	}
+	id, ok := parseID(c, "room_id", "room")
+	if !ok {
+		return
+	}
+
+	var request models.RoomRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Error().Err(err).Msg("Failed to bind request")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	room, err := h.db.UpdateRoom(apartmentID, id, &request)
+	if err != nil {
+		log.Error().Err(err).Int64("id", id).Msg("Failed to update room")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update room"})
+		return
+	}
+	if room == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Room not found"})
+		return
+	}
+	c.JSON(http.StatusOK, room)
+}
+
+// Delete handles removing a room
+func (h *RoomHandler) Delete(c *gin.Context) {
+	apartmentID, ok := findApartment(c, h.db)
+	if !ok {
+		return
+	}
+	id, ok := parseID(c, "room_id", "room")
+	if !ok {
+		return
+	}
+
+	if err := h.db.DeleteRoom(apartmentID, id); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Room not found"})
+			return
+		}
+		log.Error().Err(err).Int64("id", id).Msg("Failed to delete room")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete room"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success"})
+}
+
+// RegisterRoutes registers all room routes
+func (h *RoomHandler) RegisterRoutes(router *gin.Engine) {
+	rooms := router.Group("/api/apartments/:id/rooms")
+	{
+		rooms.GET("", h.List)
+		rooms.POST("", h.Create)
+		rooms.PUT("/:room_id", h.Update)
+		rooms.DELETE("/:room_id", h.Delete)
+	}
+}
@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mojotx/apt-eval/db"
+	"github.com/mojotx/apt-eval/models"
+	"github.com/rs/zerolog/log"
+)
+
+// RoommateHandler handles roommates and how the rent is split between them
+type RoommateHandler struct {
+	db *db.DB
+}
+
+// NewRoommateHandler creates a new roommate handler
+func NewRoommateHandler(db *db.DB) *RoommateHandler {
+	return &RoommateHandler{
+		db: db,
+	}
+}
+
+// List handles retrieving all roommates
+func (h *RoommateHandler) List(c *gin.Context) {
+	roommates, err := h.db.ListRoommates()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list roommates")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list roommates"})
+		return
+	}
+	respond(c, http.StatusOK, roommates, nil)
+}
+
+// Create handles adding a roommate
+func (h *RoommateHandler) Create(c *gin.Context) {
+	var request models.RoommateRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Error().Err(err).Msg("Failed to bind request")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	roommate, err := h.db.CreateRoommate(&request)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to create roommate")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create roommate"})
+		return
+	}
+	c.JSON(http.StatusCreated, roommate)
+}
+
+// Update handles modifying a roommate
+func (h *RoommateHandler) Update(c *gin.Context) {
+	id, ok := parseID(c, "id", "roommate")
+	if !ok {
+		return
+	}
+
+	var request models.RoommateRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Error().Err(err).Msg("Failed to bind request")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	roommate, err := h.db.UpdateRoommate(id, &request)
+	if err != nil {
+		log.Error().Err(err).Int64("id", id).Msg("Failed to update roommate")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update roommate"})
+		return
+	}
+	if roommate == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Roommate not found"})
+		return
+	}
+	c.JSON(http.StatusOK, roommate)
+}
+
+// Delete handles removing a roommate
+func (h *RoommateHandler) Delete(c *gin.Context) {
+	id, ok := parseID(c, "id", "roommate")
+	if !ok {
+		return
+	}
+
+	if err := h.db.DeleteRoommate(id); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Roommate not found"})
+			return
+		}
+		log.Error().Err(err).Int64("id", id).Msg("Failed to delete roommate")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete roommate"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success"})
+}
+
+// GetSplit handles retrieving the rent split rule
+func (h *RoommateHandler) GetSplit(c *gin.Context) {
+	rule, err := h.db.RentSplitRule()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get rent split rule")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get rent split rule"})
+		return
+	}
+	respond(c, http.StatusOK, models.RentSplitRequest{Rule: rule}, nil)
+}
+
+// SetSplit handles changing the rent split rule
+func (h *RoommateHandler) SetSplit(c *gin.Context) {
+	var request models.RentSplitRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Error().Err(err).Msg("Failed to bind request")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.db.SetRentSplitRule(request.Rule); err != nil {
+		log.Error().Err(err).Msg("Failed to set rent split rule")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set rent split rule"})
+		return
+	}
+	c.JSON(http.StatusOK, request)
+}
+
+// GetRooms handles retrieving which room each roommate would take in an
+// apartment
+func (h *RoommateHandler) GetRooms(c *gin.Context) {
+	apartmentID, ok := findApartment(c, h.db)
+	if !ok {
+		return
+	}
+
+	assignments, err := h.db.RoomAssignments(apartmentID)
+	if err != nil {
+		log.Error().Err(err).Int64("apartment_id", apartmentID).Msg("Failed to list room assignments")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list room assignments"})
+		return
+	}
+	respond(c, http.StatusOK, assignments, nil)
+}
+
+// SetRooms handles replacing the room assignments for an apartment
+func (h *RoommateHandler) SetRooms(c *gin.Context) {
+	apartmentID, ok := findApartment(c, h.db)
+	if !ok {
+		return
+	}
+
+	var request []models.RoomAssignment
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Error().Err(err).Msg("Failed to bind request")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.db.SetRoomAssignments(apartmentID, request); err != nil {
+		var invalid *db.InvalidAssignmentError
+		if errors.As(err, &invalid) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown roommate or room: " + invalid.Error()})
+			return
+		}
+		log.Error().Err(err).Int64("apartment_id", apartmentID).Msg("Failed to set room assignments")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set room assignments"})
+		return
+	}
+	h.GetRooms(c)
+}
+
+// RegisterRoutes registers the roommate routes
+func (h *RoommateHandler) RegisterRoutes(router *gin.Engine) {
+	roommates := router.Group("/api/roommates")
+	{
+		roommates.GET("", h.List)
+		roommates.POST("", h.Create)
+		roommates.GET("/split", h.GetSplit)
+		roommates.PUT("/split", h.SetSplit)
+		roommates.PUT("/:id", h.Update)
+		roommates.DELETE("/:id", h.Delete)
+	}
+	router.GET("/api/apartments/:id/roommates", h.GetRooms)
+	router.PUT("/api/apartments/:id/roommates", h.SetRooms)
+}
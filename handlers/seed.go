@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mojotx/apt-eval/db"
+	"github.com/mojotx/apt-eval/seed"
+	"github.com/rs/zerolog/log"
+)
+
+// SeedHandler fills the database with generated apartments for load
+// testing
+type SeedHandler struct {
+	db *db.DB
+}
+
+// NewSeedHandler creates a new seed handler
+func NewSeedHandler(db *db.DB) *SeedHandler {
+	return &SeedHandler{
+		db: db,
+	}
+}
+
+// seedRequest is the body of POST /api/admin/seed
+type seedRequest struct {
+	Count int     `json:"count" binding:"required,min=1"`
+	Seed  *uint64 `json:"seed"` // Random when omitted
+}
+
+// Seed handles generating apartments and adding them to the database
+func (h *SeedHandler) Seed(c *gin.Context) {
+	var request seedRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Error().Err(err).Msg("Failed to bind request")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if request.Count > seed.MaxCount {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("count must be at most %d", seed.MaxCount)})
+		return
+	}
+	seedValue := uint64(time.Now().UnixNano())
+	if request.Seed != nil {
+		seedValue = *request.Seed
+	}
+
+	started := time.Now()
+	if err := seed.Load(h.db, request.Count, seedValue); err != nil {
+		log.Error().Err(err).Msg("Failed to seed apartments")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to seed apartments"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"count":       request.Count,
+		"seed":        seedValue,
+		"duration_ms": time.Since(started).Milliseconds(),
+	})
+}
+
+// RegisterRoutes registers the seed route
+func (h *SeedHandler) RegisterRoutes(router *gin.Engine) {
+	router.POST("/api/admin/seed", h.Seed)
+}
@@ -0,0 +1,371 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"embed"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html/template"
+	"image/png"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mojotx/apt-eval/db"
+	"github.com/mojotx/apt-eval/markdown"
+	"github.com/mojotx/apt-eval/models"
+	"github.com/mojotx/apt-eval/qr"
+	"github.com/rs/zerolog/log"
+)
+
+//go:embed templates/share.html
+var shareTemplateFS embed.FS
+
+var shareTemplate = template.Must(template.ParseFS(shareTemplateFS, "templates/share.html"))
+
+// newShareToken returns an unguessable URL-safe token
+func newShareToken() (string, error) {
+	b := make([]byte, 18)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// baseURL returns the scheme and host the request was made to, for
+// building links that work outside the app
+func baseURL(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host
+}
+
+// Share handles publishing a snapshot of a comparison at a public URL
+func (h *CompareHandler) Share(c *gin.Context) {
+	var request models.ShareRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Error().Err(err).Msg("Failed to bind request")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	compared, missing, err := h.compare(request.IDs, viewerID(c))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to compare apartments")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compare apartments"})
+		return
+	}
+	if compared == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Apartment %d not found", missing)})
+		return
+	}
+	// Only apartments marked for link sharing may leave the household
+	for _, a := range compared {
+		if a.Visibility != models.VisibilityShared {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Apartment %d is %s and can't be shared", a.ID, a.Visibility)})
+			return
+		}
+	}
+
+	token, err := newShareToken()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to generate share token")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to share comparison"})
+		return
+	}
+
+	share := &models.SharedComparison{Token: token, Title: request.Title, Apartments: compared}
+	if request.ExpiresInDays != nil {
+		expires := time.Now().UTC().Truncate(time.Second).AddDate(0, 0, *request.ExpiresInDays)
+		share.ExpiresAt = &expires
+	}
+
+	share, err = h.db.CreateSharedComparison(share)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to share comparison")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to share comparison"})
+		return
+	}
+	url := baseURL(c) + "/share/" + share.Token
+	c.JSON(http.StatusCreated, gin.H{
+		"token":      share.Token,
+		"url":        url,
+		"qr_png":     url + "/qr.png",
+		"qr_svg":     url + "/qr.svg",
+		"expires_at": share.ExpiresAt,
+	})
+}
+
+// Unshare handles revoking a shared comparison
+func (h *CompareHandler) Unshare(c *gin.Context) {
+	if err := h.db.DeleteSharedComparison(c.Param("token")); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Shared comparison not found"})
+			return
+		}
+		log.Error().Err(err).Msg("Failed to delete shared comparison")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete shared comparison"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success"})
+}
+
+// reportRow is one line of the shared report table
+type reportRow struct {
+	Label  string
+	Values []any // Strings are escaped; template.HTML is shown as is
+}
+
+// shareReport is the data behind the shared report page
+type shareReport struct {
+	Token     string
+	Title     string
+	Created   string
+	Addresses []string
+	Rows      []reportRow
+}
+
+func formatInt(v *int) string {
+	if v == nil {
+		return "—"
+	}
+	return strconv.Itoa(*v)
+}
+
+func formatFloat(v *float64) string {
+	if v == nil {
+		return "—"
+	}
+	return strconv.FormatFloat(*v, 'f', -1, 64)
+}
+
+// formatMarketDiff describes how a price compares to the market rent, e.g.
+// "8% over market"
+func formatMarketDiff(pct *float64) string {
+	if pct == nil {
+		return "—"
+	}
+	switch rounded := math.Round(*pct); {
+	case rounded > 0:
+		return fmt.Sprintf("%.0f%% over market", rounded)
+	case rounded < 0:
+		return fmt.Sprintf("%.0f%% under market", -rounded)
+	}
+	return "at market"
+}
+
+// reportField is a row of a shared comparison and how to show it for an
+// apartment
+type reportField struct {
+	label string
+	value func(a *models.ComparedApartment) string
+}
+
+// splitFields returns a row per roommate with their share of each
+// apartment's rent
+func splitFields(apartments []models.ComparedApartment) []reportField {
+	var fields []reportField
+	seen := map[int64]bool{}
+	for _, a := range apartments {
+		if a.Split == nil {
+			continue
+		}
+		for _, share := range a.Split.Shares {
+			if seen[share.RoommateID] {
+				continue
+			}
+			seen[share.RoommateID] = true
+
+			id := share.RoommateID
+			fields = append(fields, reportField{share.Name + "'s share", func(a *models.ComparedApartment) string {
+				if a.Split != nil {
+					for _, s := range a.Split.Shares {
+						if s.RoommateID == id {
+							return "$" + strconv.FormatFloat(s.MonthlyCost, 'f', 2, 64)
+						}
+					}
+				}
+				return "—"
+			}})
+		}
+	}
+	return fields
+}
+
+// newShareReport lays out a shared comparison as a table with one column
+// per apartment
+func newShareReport(share *models.SharedComparison) shareReport {
+	report := shareReport{
+		Token:   share.Token,
+		Title:   share.Title,
+		Created: share.CreatedAt.Format("January 2, 2006"),
+	}
+	if report.Title == "" {
+		report.Title = "Apartment comparison"
+	}
+
+	rows := []reportField{
+		{"Price", func(a *models.ComparedApartment) string {
+			if a.Price == nil {
+				return "—"
+			}
+			return "$" + strconv.FormatFloat(*a.Price, 'f', -1, 64)
+		}},
+		{"True monthly cost", func(a *models.ComparedApartment) string {
+			if a.TrueMonthlyCost == nil {
+				return "—"
+			}
+			return "$" + strconv.FormatFloat(*a.TrueMonthlyCost, 'f', -1, 64)
+		}},
+	}
+	rows = append(rows, splitFields(share.Apartments)...)
+	rows = append(rows, []reportField{
+		{"Floor", func(a *models.ComparedApartment) string {
+			if a.Floor == nil {
+				return "—"
+			}
+			return strconv.FormatUint(uint64(*a.Floor), 10)
+		}},
+		{"Rating", func(a *models.ComparedApartment) string { return formatInt(a.Rating) }},
+		{"Score", func(a *models.ComparedApartment) string { return formatFloat(a.Score) }},
+		{"Overall rating", func(a *models.ComparedApartment) string { return formatFloat(a.OverallRating) }},
+		{"Amenities", func(a *models.ComparedApartment) string { return strings.Join(a.Amenities, ", ") }},
+		{"Walk score", func(a *models.ComparedApartment) string { return formatInt(a.WalkScore) }},
+		{"Transit score", func(a *models.ComparedApartment) string { return formatInt(a.TransitScore) }},
+		{"Safety", func(a *models.ComparedApartment) string {
+			if a.Safety == nil {
+				return "—"
+			}
+			return *a.Safety
+		}},
+		{"School rating", func(a *models.ComparedApartment) string { return formatFloat(a.SchoolRating) }},
+		{"Vs. market rent", func(a *models.ComparedApartment) string { return formatMarketDiff(a.MarketRentDiffPct) }},
+		{"Commute score", func(a *models.ComparedApartment) string { return formatFloat(a.CommuteScore) }},
+		{"Visits", func(a *models.ComparedApartment) string { return strconv.Itoa(a.VisitCount) }},
+		{"Noise", func(a *models.ComparedApartment) string {
+			if a.Environment == nil {
+				return "—"
+			}
+			return formatInt(a.Environment.NoiseLevel)
+		}},
+		{"Natural light", func(a *models.ComparedApartment) string {
+			if a.Environment == nil {
+				return "—"
+			}
+			return formatInt(a.Environment.NaturalLight)
+		}},
+		{"Rooms", func(a *models.ComparedApartment) string { return strconv.Itoa(len(a.Rooms)) }},
+	}...)
+
+	for i := range share.Apartments {
+		report.Addresses = append(report.Addresses, share.Apartments[i].FullAddress())
+	}
+
+	// The cover photo leads each column. The page is public, so it names
+	// apartments by UUID, unless shared before they had one.
+	photos := reportRow{Label: "Photo"}
+	for i := range share.Apartments {
+		a := &share.Apartments[i]
+		if a.CoverPhotoID == nil {
+			photos.Values = append(photos.Values, "—")
+			continue
+		}
+		ref := a.UUID
+		if ref == "" {
+			ref = strconv.FormatInt(a.ID, 10)
+		}
+		photos.Values = append(photos.Values, template.HTML(fmt.Sprintf(`<img class="cover" src="%s" alt="">`,
+			template.HTMLEscapeString(coverPhotoURL(ref, *a.CoverPhotoID)))))
+	}
+	report.Rows = append(report.Rows, photos)
+
+	for _, r := range rows {
+		row := reportRow{Label: r.label}
+		for i := range share.Apartments {
+			row.Values = append(row.Values, r.value(&share.Apartments[i]))
+		}
+		report.Rows = append(report.Rows, row)
+	}
+
+	// Notes are Markdown, rendered to sanitized HTML
+	notes := reportRow{Label: "Notes"}
+	for i := range share.Apartments {
+		notes.Values = append(notes.Values, template.HTML(markdown.HTML(share.Apartments[i].Notes)))
+	}
+	report.Rows = append(report.Rows, notes)
+	return report
+}
+
+// Report handles rendering a shared comparison as a read-only page
+func (h *CompareHandler) Report(c *gin.Context) {
+	share, err := h.db.GetSharedComparison(c.Param("token"))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get shared comparison")
+		c.String(http.StatusInternalServerError, "Failed to get shared comparison")
+		return
+	}
+	if share == nil {
+		c.String(http.StatusNotFound, "This comparison doesn't exist or has expired")
+		return
+	}
+
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Header("X-Robots-Tag", "noindex")
+	c.Status(http.StatusOK)
+	if err := shareTemplate.Execute(c.Writer, newShareReport(share)); err != nil {
+		log.Error().Err(err).Msg("Failed to render shared comparison")
+	}
+}
+
+// QR handles rendering a QR code of a shared comparison's URL, as a PNG
+// or SVG depending on the route. PNGs take an optional scale in pixels
+// per module.
+func (h *CompareHandler) QR(c *gin.Context) {
+	scale := 8
+	if s := c.Query("scale"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > 40 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid scale: must be 1-40"})
+			return
+		}
+		scale = n
+	}
+
+	token := c.Param("token")
+	share, err := h.db.GetSharedComparison(token)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get shared comparison")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get shared comparison"})
+		return
+	}
+	if share == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shared comparison not found"})
+		return
+	}
+
+	code, err := qr.Encode(baseURL(c) + "/share/" + token)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to encode QR code")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encode QR code"})
+		return
+	}
+
+	if strings.HasSuffix(c.FullPath(), ".svg") {
+		c.Data(http.StatusOK, "image/svg+xml", []byte(code.SVG()))
+		return
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, code.Image(scale)); err != nil {
+		log.Error().Err(err).Msg("Failed to encode QR code")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encode QR code"})
+		return
+	}
+	c.Data(http.StatusOK, "image/png", buf.Bytes())
+}
@@ -0,0 +1,302 @@
+package handlers
+
+import (
+	"embed"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mojotx/apt-eval/db"
+	"github.com/mojotx/apt-eval/sso"
+	"github.com/rs/zerolog/log"
+)
+
+// Cookies that keep users signed in, and carry a sign-in while the user is
+// away at the identity provider
+const (
+	sessionCookie = "apt_session"
+	ssoFlowCookie = "apt_sso_flow"
+)
+
+// Purposes signed tokens are bound to
+const (
+	sessionPurpose  = "session"
+	oidcFlowPurpose = "oidc"
+	samlFlowPurpose = "saml"
+)
+
+// ssoFlowTTL is how long the identity provider has to send the user back
+const ssoFlowTTL = 10 * time.Minute
+
+//go:embed templates/login.html
+var loginTemplateFS embed.FS
+
+var loginTemplate = template.Must(template.ParseFS(loginTemplateFS, "templates/login.html"))
+
+// SSOHandler handles signing in through an identity provider, with OpenID
+// Connect, SAML, or both
+type SSOHandler struct {
+	db         *db.DB
+	signer     *sso.Signer
+	oidc       *sso.OIDC // nil when OpenID Connect isn't configured
+	saml       *sso.SAML // nil when SAML isn't configured
+	baseURL    string    // Where the app is reached, for callback URLs
+	sessionTTL time.Duration
+}
+
+// NewSSOHandler creates a new SSO handler. Sessions last sessionTTL, and
+// the identity providers send users back to baseURL.
+func NewSSOHandler(db *db.DB, signer *sso.Signer, oidc *sso.OIDC, saml *sso.SAML, baseURL string, sessionTTL time.Duration) *SSOHandler {
+	return &SSOHandler{
+		db:         db,
+		signer:     signer,
+		oidc:       oidc,
+		saml:       saml,
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		sessionTTL: sessionTTL,
+	}
+}
+
+// RegisterRoutes registers the SSO routes
+func (h *SSOHandler) RegisterRoutes(router *gin.Engine) {
+	auth := router.Group("/auth")
+	auth.GET("/login", h.Login)
+	auth.GET("/logout", h.Logout)
+	if h.oidc != nil {
+		auth.GET("/oidc/login", h.OIDCLogin)
+		auth.GET("/oidc/callback", h.OIDCCallback)
+	}
+	if h.saml != nil {
+		auth.GET("/saml/metadata", h.SAMLMetadata)
+		auth.GET("/saml/login", h.SAMLLogin)
+		auth.POST("/saml/acs", h.SAMLACS)
+	}
+}
+
+// OIDCRedirectURL is where the OpenID provider sends users back to, which
+// must be registered with it
+func OIDCRedirectURL(baseURL string) string {
+	return strings.TrimSuffix(baseURL, "/") + "/auth/oidc/callback"
+}
+
+// SAMLEntityID is the app's SAML entity ID, which is also where its
+// metadata is served
+func SAMLEntityID(baseURL string) string {
+	return strings.TrimSuffix(baseURL, "/") + "/auth/saml/metadata"
+}
+
+// SAMLACSURL is where the SAML identity provider posts its assertions
+func SAMLACSURL(baseURL string) string {
+	return strings.TrimSuffix(baseURL, "/") + "/auth/saml/acs"
+}
+
+// Login handles starting a sign-in. With a single identity provider the
+// user goes straight to it; with both, they choose.
+func (h *SSOHandler) Login(c *gin.Context) {
+	next := localPath(c.Query("next"))
+	switch {
+	case h.oidc != nil && h.saml == nil:
+		c.Redirect(http.StatusFound, "/auth/oidc/login?next="+url.QueryEscape(next))
+		return
+	case h.saml != nil && h.oidc == nil:
+		c.Redirect(http.StatusFound, "/auth/saml/login?next="+url.QueryEscape(next))
+		return
+	}
+
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(http.StatusOK)
+	err := loginTemplate.Execute(c.Writer, struct {
+		OIDC, SAML bool
+		Next       string
+	}{h.oidc != nil, h.saml != nil, next})
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to render sign-in page")
+	}
+}
+
+// Logout handles signing out. The identity provider's own session isn't
+// ended, so signing in again may not ask for a password.
+func (h *SSOHandler) Logout(c *gin.Context) {
+	h.setCookie(c, sessionCookie, "", "/", -1, http.SameSiteLaxMode)
+	c.JSON(http.StatusOK, gin.H{"status": "logged out"})
+}
+
+// OIDCLogin handles sending the user to the OpenID provider
+func (h *SSOHandler) OIDCLogin(c *gin.Context) {
+	state, err := newShareToken()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to generate OIDC state")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start sign-in"})
+		return
+	}
+	nonce, err := newShareToken()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to generate OIDC nonce")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start sign-in"})
+		return
+	}
+
+	next := localPath(c.Query("next"))
+	flow := h.signer.Sign(oidcFlowPurpose, state+" "+nonce+" "+next, time.Now().Add(ssoFlowTTL))
+	h.setCookie(c, ssoFlowCookie, flow, "/auth/oidc", int(ssoFlowTTL.Seconds()), http.SameSiteLaxMode)
+	c.Redirect(http.StatusFound, h.oidc.AuthCodeURL(state, nonce, OIDCRedirectURL(h.baseURL)))
+}
+
+// OIDCCallback handles the user coming back from the OpenID provider
+func (h *SSOHandler) OIDCCallback(c *gin.Context) {
+	cookie, _ := c.Cookie(ssoFlowCookie)
+	h.setCookie(c, ssoFlowCookie, "", "/auth/oidc", -1, http.SameSiteLaxMode)
+	flow, err := h.signer.Open(oidcFlowPurpose, cookie)
+	parts := strings.SplitN(flow, " ", 3)
+	if err != nil || len(parts) != 3 || parts[0] != c.Query("state") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired sign-in: sign in again"})
+		return
+	}
+	nonce, next := parts[1], parts[2]
+	if reason := c.Query("error"); reason != "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Sign-in wasn't allowed: " + reason})
+		return
+	}
+
+	identity, err := h.oidc.Exchange(c.Request.Context(), c.Query("code"), OIDCRedirectURL(h.baseURL), nonce)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to complete OIDC sign-in")
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to complete sign-in"})
+		return
+	}
+	h.signIn(c, "oidc:"+h.oidc.Issuer, identity, next, http.StatusFound)
+}
+
+// SAMLMetadata handles serving the app's SAML metadata
+func (h *SSOHandler) SAMLMetadata(c *gin.Context) {
+	c.Data(http.StatusOK, "application/samlmetadata+xml", h.saml.Metadata())
+}
+
+// SAMLLogin handles sending the user to the SAML identity provider
+func (h *SSOHandler) SAMLLogin(c *gin.Context) {
+	u, requestID, err := h.saml.AuthnRequestURL("")
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to create SAML request")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start sign-in"})
+		return
+	}
+
+	// The identity provider posts back from its own site, so the cookie
+	// must be sent cross-site, which browsers only allow over HTTPS
+	next := localPath(c.Query("next"))
+	flow := h.signer.Sign(samlFlowPurpose, requestID+" "+next, time.Now().Add(ssoFlowTTL))
+	h.setCookie(c, ssoFlowCookie, flow, "/auth/saml", int(ssoFlowTTL.Seconds()), http.SameSiteNoneMode)
+	c.Redirect(http.StatusFound, u)
+}
+
+// SAMLACS handles the SAML identity provider posting back an assertion
+func (h *SSOHandler) SAMLACS(c *gin.Context) {
+	cookie, _ := c.Cookie(ssoFlowCookie)
+	h.setCookie(c, ssoFlowCookie, "", "/auth/saml", -1, http.SameSiteNoneMode)
+	flow, err := h.signer.Open(samlFlowPurpose, cookie)
+	requestID, next, ok := strings.Cut(flow, " ")
+	if err != nil || !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired sign-in: sign in again"})
+		return
+	}
+
+	identity, err := h.saml.ParseResponse(c.PostForm("SAMLResponse"), requestID)
+	if err != nil {
+		log.Warn().Err(err).Msg("Rejected SAML response")
+		c.JSON(http.StatusForbidden, gin.H{"error": "Sign-in was rejected: " + err.Error()})
+		return
+	}
+	h.signIn(c, "saml:"+h.saml.IdPEntityID, identity, next, http.StatusSeeOther)
+}
+
+// signIn finds or adds the user identity is for, starts their session,
+// and sends them on to next
+func (h *SSOHandler) signIn(c *gin.Context, provider string, identity *sso.Identity, next string, status int) {
+	name := identity.DisplayName()
+	if len(name) > 100 {
+		name = name[:100]
+	}
+	user, created, err := h.db.SSOUser(provider, identity.Subject, name)
+	if err != nil {
+		log.Error().Err(err).Str("provider", provider).Msg("Failed to get signed-in user")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user"})
+		return
+	}
+	if created {
+		log.Info().Int64("id", user.ID).Str("provider", provider).Msg("Added user on first sign-in")
+	}
+
+	session := h.signer.Sign(sessionPurpose, strconv.FormatInt(user.ID, 10), time.Now().Add(h.sessionTTL))
+	h.setCookie(c, sessionCookie, session, "/", int(h.sessionTTL.Seconds()), http.SameSiteLaxMode)
+	c.Redirect(status, next)
+}
+
+// setCookie sets an HTTP-only cookie, secure unless the app is reached
+// over plain HTTP; maxAge below zero deletes it
+func (h *SSOHandler) setCookie(c *gin.Context, name, value, path string, maxAge int, sameSite http.SameSite) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   !strings.HasPrefix(h.baseURL, "http://"),
+		SameSite: sameSite,
+	})
+}
+
+// SSOSession requires a session from signing in through an identity
+// provider on every request but those to the exempt path prefixes. The
+// signed-in user becomes the viewer, in place of X-User-ID. Browsers
+// without a session are sent to sign in; API clients are refused.
+func SSOSession(signer *sso.Signer, database *db.DB, exempt ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, prefix := range exempt {
+			if strings.HasPrefix(c.Request.URL.Path, prefix) {
+				c.Next()
+				return
+			}
+		}
+
+		var userID int64
+		if cookie, err := c.Cookie(sessionCookie); err == nil {
+			if value, err := signer.Open(sessionPurpose, cookie); err == nil {
+				userID, _ = strconv.ParseInt(value, 10, 64)
+			}
+		}
+		if userID > 0 {
+			user, err := database.GetUser(userID)
+			if err != nil {
+				log.Error().Err(err).Int64("id", userID).Msg("Failed to get user")
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user"})
+				return
+			}
+			// A deleted user's session ends with them
+			if user != nil {
+				c.Set(viewerKey, user.ID)
+				c.Next()
+				return
+			}
+		}
+
+		if c.Request.Method == http.MethodGet && strings.Contains(c.GetHeader("Accept"), "text/html") {
+			c.Redirect(http.StatusFound, "/auth/login?next="+url.QueryEscape(c.Request.URL.RequestURI()))
+			c.Abort()
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Sign-in required"})
+	}
+}
+
+// localPath returns next if it's a path on this site, or "/", so sign-in
+// can't be used to send users elsewhere
+func localPath(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+	return next
+}
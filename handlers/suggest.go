@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// Limits on the number of search bar completions
+const (
+	defaultCompletions = 10
+	maxCompletions     = 25
+)
+
+// Suggest handles completing what's been typed into the search bar with
+// addresses, neighborhoods, building names, and amenities
+func (h *ApartmentHandler) Suggest(c *gin.Context) {
+	limit := defaultCompletions
+	if s := c.Query("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > maxCompletions {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", maxCompletions)})
+			return
+		}
+		limit = n
+	}
+
+	suggestions, err := h.db.Suggest(c.Query("q"), limit, viewerID(c))
+	if err != nil {
+		log.Error().Err(err).Str("q", c.Query("q")).Msg("Failed to suggest completions")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to suggest completions"})
+		return
+	}
+	respond(c, http.StatusOK, suggestions, nil)
+}
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mojotx/apt-eval/db"
+	"github.com/mojotx/apt-eval/llm"
+	"github.com/mojotx/apt-eval/models"
+	"github.com/mojotx/apt-eval/scan"
+	"github.com/rs/zerolog/log"
+)
+
+// maxNotesLength caps each block of notes sent to the model, in bytes
+const maxNotesLength = 4000
+
+// SummarizeHandler handles generating pros and cons summaries of
+// apartments with a language model
+type SummarizeHandler struct {
+	db       *db.DB
+	provider llm.Provider // nil when no provider is configured
+}
+
+// NewSummarizeHandler creates a new summarize handler
+func NewSummarizeHandler(db *db.DB, provider llm.Provider) *SummarizeHandler {
+	return &SummarizeHandler{
+		db:       db,
+		provider: provider,
+	}
+}
+
+// truncate shortens s to at most n bytes without splitting a character
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && s[n]&0xC0 == 0x80 {
+		n--
+	}
+	return s[:n] + "…"
+}
+
+// summaryFacts writes what's known about an apartment as plain text for
+// the model
+func summaryFacts(apartment *models.Apartment, visits []models.Visit, attachments []models.Attachment) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Address: %s\n", apartment.FullAddress())
+	if apartment.Price != nil {
+		fmt.Fprintf(&b, "Rent: %s\n", formatPrice(*apartment.Price))
+	}
+	if apartment.Floor != nil {
+		fmt.Fprintf(&b, "Floor: %d\n", *apartment.Floor)
+	}
+	if apartment.Rating != nil {
+		fmt.Fprintf(&b, "Our rating: %d of 5\n", *apartment.Rating)
+	}
+	if len(apartment.Amenities) > 0 {
+		fmt.Fprintf(&b, "Amenities: %s\n", strings.Join(apartment.Amenities, ", "))
+	}
+	if apartment.Safety != nil {
+		fmt.Fprintf(&b, "Neighborhood safety: %s\n", *apartment.Safety)
+	}
+	if notes := strings.TrimSpace(apartment.Notes); notes != "" {
+		fmt.Fprintf(&b, "\nNotes:\n%s\n", truncate(notes, maxNotesLength))
+	}
+
+	for _, v := range visits {
+		fmt.Fprintf(&b, "\nVisit on %s:\n", v.VisitedAt.Format("January 2, 2006 at 3:04 PM"))
+		if v.NoiseLevel != nil {
+			fmt.Fprintf(&b, "- Street noise: %d of 5 (5 is loud)\n", *v.NoiseLevel)
+		}
+		if v.NaturalLight != nil {
+			fmt.Fprintf(&b, "- Natural light: %d of 5 (5 is bright)\n", *v.NaturalLight)
+		}
+		if v.Facing != nil {
+			fmt.Fprintf(&b, "- Windows face %s\n", *v.Facing)
+		}
+		if s := strings.TrimSpace(v.SmellNotes); s != "" {
+			fmt.Fprintf(&b, "- Smells: %s\n", truncate(s, maxNotesLength))
+		}
+		if s := strings.TrimSpace(v.Notes); s != "" {
+			fmt.Fprintf(&b, "- Notes: %s\n", truncate(s, maxNotesLength))
+		}
+	}
+
+	var captions []string
+	for _, a := range attachments {
+		if a.Caption != "" && a.ScanStatus != scan.StatusInfected {
+			captions = append(captions, "- "+a.Caption)
+		}
+	}
+	if len(captions) > 0 {
+		fmt.Fprintf(&b, "\nPhoto captions:\n%s\n", strings.Join(captions, "\n"))
+	}
+	return b.String()
+}
+
+// Summarize handles producing a short pros and cons summary of an
+// apartment. The summary is stored and returned again until the notes,
+// visits, captions, or model change; ?refresh=true regenerates it anyway.
+func (h *SummarizeHandler) Summarize(c *gin.Context) {
+	apartment, ok := loadApartment(c, h.db)
+	if !ok {
+		return
+	}
+	if h.provider == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "No LLM provider is configured"})
+		return
+	}
+
+	visits, err := h.db.ListVisits(apartment.ID)
+	if err != nil {
+		log.Error().Err(err).Int64("apartment_id", apartment.ID).Msg("Failed to list visits")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list visits"})
+		return
+	}
+	attachments, err := h.db.ListAttachments(apartment.ID)
+	if err != nil {
+		log.Error().Err(err).Int64("apartment_id", apartment.ID).Msg("Failed to list attachments")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list attachments"})
+		return
+	}
+
+	facts := summaryFacts(apartment, visits, attachments)
+	sum := sha256.Sum256([]byte(h.provider.Name() + "\x00" + h.provider.Model() + "\x00" + facts))
+	hash := hex.EncodeToString(sum[:])
+
+	if c.Query("refresh") != "true" {
+		cached, cachedHash, err := h.db.GetAISummary(apartment.ID)
+		if err != nil {
+			log.Error().Err(err).Int64("apartment_id", apartment.ID).Msg("Failed to get summary")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get summary"})
+			return
+		}
+		if cached != nil && cachedHash == hash {
+			cached.Cached = true
+			c.JSON(http.StatusOK, cached)
+			return
+		}
+	}
+
+	result, err := llm.Summarize(c.Request.Context(), h.provider, facts)
+	if err != nil {
+		log.Error().Err(err).Int64("apartment_id", apartment.ID).Str("provider", h.provider.Name()).
+			Msg("Failed to generate summary")
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to generate summary"})
+		return
+	}
+
+	summary := &models.AISummary{
+		ApartmentID: apartment.ID,
+		Summary:     result.Summary,
+		Pros:        result.Pros,
+		Cons:        result.Cons,
+		Provider:    h.provider.Name(),
+		Model:       h.provider.Model(),
+		GeneratedAt: time.Now().UTC().Truncate(time.Second),
+	}
+	if err := h.db.SaveAISummary(summary, hash); err != nil {
+		log.Error().Err(err).Int64("apartment_id", apartment.ID).Msg("Failed to save summary")
+	}
+	c.JSON(http.StatusOK, summary)
+}
+
+// RegisterRoutes registers all summarize routes
+func (h *SummarizeHandler) RegisterRoutes(router *gin.Engine) {
+	router.POST("/api/apartments/:id/summarize", h.Summarize)
+}
@@ -0,0 +1,326 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif" // Photos may be GIFs
+	_ "image/png" // or PNGs
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mojotx/apt-eval/db"
+	"github.com/mojotx/apt-eval/markdown"
+	"github.com/mojotx/apt-eval/media"
+	"github.com/mojotx/apt-eval/models"
+	"github.com/mojotx/apt-eval/pdf"
+	"github.com/mojotx/apt-eval/qr"
+	"github.com/mojotx/apt-eval/scoring"
+	"github.com/mojotx/apt-eval/storage"
+	"github.com/rs/zerolog/log"
+)
+
+// Summary sheet layout, in points
+const (
+	sheetMargin = 48
+	photoWidth  = 300
+	photoHeight = 200
+	qrSize      = 108
+	lineHeight  = 15
+)
+
+// SummaryHandler handles printable apartment summaries
+type SummaryHandler struct {
+	db    *db.DB
+	store *storage.Store
+}
+
+// NewSummaryHandler creates a new summary handler
+func NewSummaryHandler(db *db.DB, store *storage.Store) *SummaryHandler {
+	return &SummaryHandler{
+		db:    db,
+		store: store,
+	}
+}
+
+// summarySheet draws the parts of a summary top to bottom, tracking the
+// current position
+type summarySheet struct {
+	page *pdf.Page
+	y    float64
+}
+
+// heading starts a section
+func (s *summarySheet) heading(x float64, title string) {
+	s.y -= 22
+	s.page.Text(x, s.y, pdf.HelveticaBold, 12, title)
+	s.y -= 4
+	s.page.Line(x, s.y, s.page.Width-sheetMargin, s.y, 0.5)
+}
+
+// fact writes a label and value on one line
+func (s *summarySheet) fact(x float64, label, value string) {
+	s.y -= lineHeight
+	s.page.Text(x, s.y, pdf.HelveticaBold, 10, label)
+	s.page.Text(x+110, s.y, pdf.Helvetica, 10, value)
+}
+
+// ratingBar writes a 1-5 rating as a row of boxes with the weight beside it
+func (s *summarySheet) ratingBar(x float64, label string, rating *int, weight float64) {
+	s.y -= lineHeight
+	s.page.Text(x, s.y, pdf.HelveticaBold, 10, label)
+	for i := range 5 {
+		bx := x + 110 + float64(i)*16
+		if rating != nil && i < *rating {
+			s.page.FillRect(bx, s.y-1, 12, 9, 0.25)
+		}
+		s.page.StrokeRect(bx, s.y-1, 12, 9, 0.5)
+	}
+	value := "not rated"
+	if rating != nil {
+		value = fmt.Sprintf("%d/5", *rating)
+	}
+	s.page.Text(x+200, s.y, pdf.Helvetica, 10, fmt.Sprintf("%s  (weight %s)", value, formatFloat(&weight)))
+}
+
+// formatPrice formats a monthly rent with thousands separators
+func formatPrice(price float64) string {
+	digits := strconv.FormatFloat(price, 'f', 0, 64)
+	var b strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(d)
+	}
+	return "$" + b.String() + "/month"
+}
+
+// sheetPhoto is an image shown on a summary sheet
+type sheetPhoto struct {
+	key         string
+	contentType string
+	width       int
+	height      int
+}
+
+// photo picks the image for an apartment's summary: its cover photo, or
+// else its floor plan if that's an image
+func (h *SummaryHandler) photo(apartment *models.Apartment) (*sheetPhoto, error) {
+	if apartment.CoverPhotoID != nil {
+		a, err := h.db.GetAttachment(apartment.ID, *apartment.CoverPhotoID)
+		if err != nil {
+			return nil, err
+		}
+		if a != nil && a.Width != nil && a.Height != nil {
+			return &sheetPhoto{attachmentKey(a), a.ContentType, *a.Width, *a.Height}, nil
+		}
+	}
+
+	fp, err := h.db.GetFloorPlan(apartment.ID)
+	if err != nil || fp == nil || fp.Width == nil || fp.Height == nil {
+		return nil, err
+	}
+	return &sheetPhoto{floorPlanKey(apartment.ID), fp.ContentType, *fp.Width, *fp.Height}, nil
+}
+
+// drawPhoto places the apartment's photo, if it has one, in the photo box
+// at the top left, reporting whether it did
+func (h *SummaryHandler) drawPhoto(page *pdf.Page, apartment *models.Apartment, top float64) bool {
+	photo, err := h.photo(apartment)
+	if err != nil {
+		log.Error().Err(err).Int64("id", apartment.ID).Msg("Failed to get summary photo")
+		return false
+	}
+	if photo == nil {
+		return false
+	}
+
+	data, err := readStored(h.store, photo.key)
+	if err != nil {
+		log.Error().Err(err).Int64("id", apartment.ID).Msg("Failed to read summary photo")
+		return false
+	}
+
+	// Photos taken sideways are stored as shot, with the EXIF orientation
+	// saying how to turn them
+	orientation := media.Orientation(data)
+	width, height := photo.width, photo.height
+	if orientation >= 5 {
+		width, height = height, width
+	}
+
+	// Fit the image in the box, keeping its aspect ratio
+	scale := min(photoWidth/float64(width), photoHeight/float64(height))
+	w, ht := float64(width)*scale, float64(height)*scale
+	x, y := float64(sheetMargin), top-ht
+
+	if photo.contentType == "image/jpeg" && orientation == 1 {
+		err = page.JPEG(data, x, y, w, ht)
+	} else {
+		var img image.Image
+		if img, _, err = image.Decode(bytes.NewReader(data)); err == nil {
+			page.Image(media.Orient(img, orientation), x, y, w, ht)
+		}
+	}
+	if err != nil {
+		log.Error().Err(err).Int64("id", apartment.ID).Msg("Failed to draw summary photo")
+		return false
+	}
+	return true
+}
+
+// drawQR draws a QR code for url with its top-right corner at x, y
+func drawQR(page *pdf.Page, url string, x, y float64) {
+	code, err := qr.Encode(url)
+	if err != nil {
+		log.Error().Err(err).Str("url", url).Msg("Failed to encode QR code")
+		return
+	}
+
+	module := qrSize / float64(code.Size+2*qr.QuietZone)
+	left, top := x-qrSize+qr.QuietZone*module, y-qr.QuietZone*module
+	for my := range code.Size {
+		for mx := range code.Size {
+			if code.Dark(mx, my) {
+				page.FillRect(left+float64(mx)*module, top-float64(my+1)*module, module, module, 0)
+			}
+		}
+	}
+	page.Text(left, y-qrSize-6, pdf.Helvetica, 7, "Scan to open in the app")
+}
+
+// Summary handles rendering a one-page printable summary of an apartment
+func (h *SummaryHandler) Summary(c *gin.Context) {
+	id, ok := parseID(c, "id", "apartment")
+	if !ok {
+		return
+	}
+
+	apartment, err := h.db.GetApartment(id)
+	if err != nil {
+		log.Error().Err(err).Int64("id", id).Msg("Failed to get apartment")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get apartment"})
+		return
+	}
+	if apartment == nil || !apartment.VisibleTo(viewerID(c)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Apartment not found"})
+		return
+	}
+
+	weights, err := h.db.RatingWeights()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get rating weights")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get rating weights"})
+		return
+	}
+	rooms, err := h.db.ListRooms(id)
+	if err != nil {
+		log.Error().Err(err).Int64("id", id).Msg("Failed to list rooms")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list rooms"})
+		return
+	}
+
+	page := pdf.NewPage(pdf.LetterWidth, pdf.LetterHeight)
+	sheet := &summarySheet{page: page, y: pdf.LetterHeight - sheetMargin}
+	left := float64(sheetMargin)
+	right := float64(pdf.LetterWidth - sheetMargin)
+
+	// Title block, with the QR code beside it
+	drawQR(page, fmt.Sprintf("%s/?apartment=%d", baseURL(c), id), right, sheet.y+8)
+	for i, line := range pdf.Wrap(pdf.HelveticaBold, 18, right-left-qrSize-12, apartment.FullAddress()) {
+		if i > 0 {
+			sheet.y -= 4
+		}
+		sheet.y -= 18
+		page.Text(left, sheet.y, pdf.HelveticaBold, 18, line)
+	}
+	var subtitle []string
+	if !apartment.VisitDate.IsZero() {
+		subtitle = append(subtitle, "Visited "+apartment.VisitDate.Format("January 2, 2006"))
+	}
+	if apartment.Price != nil {
+		subtitle = append(subtitle, formatPrice(*apartment.Price))
+	}
+	if apartment.Floor != nil {
+		subtitle = append(subtitle, fmt.Sprintf("Floor %d", *apartment.Floor))
+	}
+	sheet.y -= lineHeight
+	page.Text(left, sheet.y, pdf.Helvetica, 11, strings.Join(subtitle, " · "))
+
+	sheet.y = min(sheet.y, pdf.LetterHeight-sheetMargin-qrSize-16)
+	if h.drawPhoto(page, apartment, sheet.y-8) {
+		sheet.y -= photoHeight + 8
+	}
+
+	sheet.heading(left, "Key facts")
+	amenities := strings.Join(apartment.Amenities, ", ")
+	if amenities == "" {
+		amenities = "—"
+	}
+	if apartment.TrueMonthlyCost != nil && apartment.Price != nil && *apartment.TrueMonthlyCost != *apartment.Price {
+		sheet.fact(left, "With utilities", formatPrice(*apartment.TrueMonthlyCost))
+	}
+	if apartment.MarketRent != nil {
+		sheet.fact(left, "Market rent", formatPrice(*apartment.MarketRent)+" ("+formatMarketDiff(apartment.MarketRentDiffPct)+")")
+	}
+	sheet.fact(left, "Amenities", amenities)
+	sheet.fact(left, "Walk / transit", formatInt(apartment.WalkScore)+" / "+formatInt(apartment.TransitScore))
+	safety := "—"
+	if apartment.Safety != nil {
+		safety = *apartment.Safety
+	}
+	sheet.fact(left, "Safety", safety)
+	sheet.fact(left, "School rating", formatFloat(apartment.SchoolRating))
+	sheet.fact(left, "Commute score", formatFloat(apartment.CommuteScore))
+	if apartment.TransitStop != nil {
+		sheet.fact(left, "Nearest stop", *apartment.TransitStop)
+	}
+	if len(rooms) > 0 {
+		names := make([]string, len(rooms))
+		for i, r := range rooms {
+			names[i] = r.Name
+			if r.Rating != nil {
+				names[i] += fmt.Sprintf(" (%d/5)", *r.Rating)
+			}
+		}
+		sheet.fact(left, "Rooms", strings.Join(names, ", "))
+	}
+
+	sheet.heading(left, "Score breakdown")
+	ratings := apartment.Ratings.ByCategory()
+	for _, category := range scoring.Categories {
+		sheet.ratingBar(left, strings.ToUpper(category[:1])+category[1:], ratings[category], weights[category])
+	}
+	sheet.fact(left, "Weighted score", formatFloat(apartment.Score))
+	sheet.fact(left, "Room rating", formatFloat(apartment.RoomRating))
+	sheet.fact(left, "Overall rating", formatFloat(apartment.OverallRating))
+
+	sheet.heading(left, "Notes")
+	notes := markdown.Text(apartment.Notes)
+	for _, line := range pdf.Wrap(pdf.Helvetica, 10, right-left, notes) {
+		if line == "" && notes == "" {
+			continue
+		}
+		sheet.y -= 13
+		page.Text(left, sheet.y, pdf.Helvetica, 10, line)
+	}
+	// Ruled lines for notes taken at the viewing
+	for sheet.y -= 24; sheet.y > sheetMargin; sheet.y -= 22 {
+		page.Line(left, sheet.y, right, sheet.y, 0.25)
+	}
+
+	c.Header("Content-Type", "application/pdf")
+	c.Header("Content-Disposition", fmt.Sprintf(`inline; filename="apartment-%d-summary.pdf"`, id))
+	c.Status(http.StatusOK)
+	if _, err := page.WriteTo(c.Writer); err != nil {
+		log.Error().Err(err).Int64("id", id).Msg("Failed to write summary")
+	}
+}
+
+// RegisterRoutes registers the summary route
+func (h *SummaryHandler) RegisterRoutes(router *gin.Engine) {
+	router.GET("/api/apartments/:id/summary.pdf", h.Summary)
+}
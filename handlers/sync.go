@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mojotx/apt-eval/db"
+	"github.com/mojotx/apt-eval/forms"
+	"github.com/mojotx/apt-eval/models"
+	"github.com/rs/zerolog/log"
+)
+
+// defaultSyncLimit is how many changes a sync page holds by default
+const defaultSyncLimit = 100
+
+// SyncHandler handles offline clients catching up on changes and sending
+// the changes they made while offline
+type SyncHandler struct {
+	db *db.DB
+	mu sync.Mutex // Serializes batches, so versions can't change mid-check
+}
+
+// NewSyncHandler creates a new sync handler
+func NewSyncHandler(db *db.DB) *SyncHandler {
+	return &SyncHandler{
+		db: db,
+	}
+}
+
+// Changes handles listing the apartments created, updated, or deleted
+// since the since cursor, oldest change first. Apartments made private to
+// someone other than the viewer are reported as deleted.
+func (h *SyncHandler) Changes(c *gin.Context) {
+	var since int64
+	if s := c.Query("since"); s != "" {
+		var err error
+		if since, err = strconv.ParseInt(s, 10, 64); err != nil || since < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid since cursor"})
+			return
+		}
+	}
+	if since > 0 {
+		horizon, err := h.db.SyncHorizon()
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to get sync horizon")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list changes"})
+			return
+		}
+		// Deletions since then may have been purged, so the client can't
+		// catch up from its cursor and has to sync from scratch
+		if since < horizon {
+			c.JSON(http.StatusGone, gin.H{"error": "Cursor is too old; sync again from the start"})
+			return
+		}
+	}
+	limit := defaultSyncLimit
+	if s := c.Query("limit"); s != "" {
+		var err error
+		if limit, err = strconv.Atoi(s); err != nil || limit < 1 || limit > maxPageSize {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and " + strconv.Itoa(maxPageSize)})
+			return
+		}
+	}
+
+	// One more than asked for tells whether there are more
+	changes, err := h.db.ApartmentChanges(since, limit+1)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list apartment changes")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list changes"})
+		return
+	}
+	page := models.SyncChanges{Changes: changes, Cursor: strconv.FormatInt(since, 10)}
+	if len(changes) > limit {
+		page.Changes, page.HasMore = changes[:limit], true
+	}
+
+	viewer := viewerID(c)
+	for i := range page.Changes {
+		ch := &page.Changes[i]
+		page.Cursor = strconv.FormatInt(ch.Version, 10)
+		if ch.Deleted {
+			continue
+		}
+		apartment, err := h.db.GetApartment(ch.ApartmentID)
+		if err != nil {
+			log.Error().Err(err).Int64("id", ch.ApartmentID).Msg("Failed to get apartment")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list changes"})
+			return
+		}
+		if apartment == nil || !apartment.VisibleTo(viewer) {
+			ch.Deleted = true
+			continue
+		}
+		ch.Apartment = apartment
+	}
+
+	respond(c, http.StatusOK, page, nil)
+}
+
+// Push handles a batch of changes made offline, applying each in order and
+// responding with the outcome of each. An update or delete made on an
+// older version than the server's is a conflict and isn't applied; the
+// result carries the server's copy to merge.
+func (h *SyncHandler) Push(c *gin.Context) {
+	var request models.SyncRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Error().Err(err).Msg("Failed to bind request")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	viewer := viewerID(c)
+	results := make([]models.SyncResult, len(request.Changes))
+	for i := range request.Changes {
+		results[i] = h.apply(viewer, &request.Changes[i])
+	}
+	c.JSON(http.StatusOK, gin.H{"results": results})
+}
+
+// apply makes one change as viewer
+func (h *SyncHandler) apply(viewer int64, ch *models.SyncChange) models.SyncResult {
+	result := models.SyncResult{Op: ch.Op, ID: ch.ID, UUID: ch.UUID, ClientID: ch.ClientID}
+	reject := func(msg string) models.SyncResult {
+		result.Status, result.Error = models.SyncRejected, msg
+		return result
+	}
+	fail := func(msg string, err error) models.SyncResult {
+		if problem := requestProblem(err); problem != "" {
+			return reject(problem)
+		}
+		log.Error().Err(err).Int64("id", result.ID).Msg(msg)
+		result.Status, result.Error = models.SyncFailed, msg
+		return result
+	}
+	if ch.Op != models.SyncDelete && ch.Apartment == nil {
+		return reject("Missing apartment")
+	}
+
+	if ch.Op != models.SyncCreate && ch.ID == 0 && ch.UUID != "" {
+		id, err := h.db.ApartmentIDByUUID(strings.ToLower(ch.UUID))
+		if errors.Is(err, db.ErrNotFound) {
+			return reject("Apartment not found")
+		}
+		if err != nil {
+			return fail("Failed to get apartment", err)
+		}
+		ch.ID, result.ID = id, id
+	}
+
+	if ch.Op == models.SyncCreate {
+		if status, msg := visibilityProblem(viewer, ch.Apartment, nil); status != 0 {
+			return reject(msg)
+		}
+		apartment, err := h.db.CreateApartment(ch.Apartment)
+		if err != nil {
+			return fail("Failed to create apartment", err)
+		}
+		result.ID, result.UUID = apartment.ID, apartment.UUID
+		recordActivity(h.db, viewer, models.ActivityAdded, apartment.ID, "")
+		return h.applied(result, apartment, fail)
+	}
+
+	existing, err := h.db.GetApartment(ch.ID)
+	if err != nil {
+		return fail("Failed to get apartment", err)
+	}
+	version, _, err := h.db.ApartmentVersion(ch.ID)
+	if err != nil {
+		return fail("Failed to get apartment version", err)
+	}
+	if version == 0 {
+		return reject("Apartment not found")
+	}
+
+	gone := existing == nil || !existing.VisibleTo(viewer)
+	if gone && ch.Op == models.SyncDelete {
+		result.Status, result.Version, result.Deleted = models.SyncApplied, version, true
+		return result
+	}
+	if gone || version != ch.Version {
+		result.Status, result.Version = models.SyncConflict, version
+		if gone {
+			result.Deleted = true
+		} else {
+			result.Apartment = existing
+		}
+		return result
+	}
+
+	if ch.Op == models.SyncDelete {
+		// Sync has no way to force it, so only an apartment without
+		// records of its own is deleted
+		dependents, err := h.db.ApartmentDependents(ch.ID)
+		if err != nil {
+			return fail("Failed to delete apartment", err)
+		}
+		if len(dependents) > 0 {
+			return reject("Apartment has records of its own; delete it with ?force=true")
+		}
+		if _, err := h.db.DeleteApartment(viewer, ch.ID, false); err != nil {
+			return fail("Failed to delete apartment", err)
+		}
+		return h.applied(result, nil, fail)
+	}
+
+	if status, msg := visibilityProblem(viewer, ch.Apartment, existing); status != 0 {
+		return reject(msg)
+	}
+	snapshot := snapshotApartment(h.db, ch.ID)
+	apartment, err := h.db.UpdateApartment(ch.ID, ch.Apartment)
+	if err != nil {
+		return fail("Failed to update apartment", err)
+	}
+	if apartment != nil {
+		recordApartmentChanges(h.db, viewer, existing, apartment, snapshot)
+	}
+	return h.applied(result, apartment, fail)
+}
+
+// applied completes the result of an applied change with the apartment's
+// new version, and the apartment unless it was deleted
+func (h *SyncHandler) applied(result models.SyncResult, apartment *models.Apartment, fail func(string, error) models.SyncResult) models.SyncResult {
+	version, deleted, err := h.db.ApartmentVersion(result.ID)
+	if err != nil {
+		return fail("Failed to get apartment version", err)
+	}
+	result.Status, result.Version, result.Deleted, result.Apartment = models.SyncApplied, version, deleted, apartment
+	return result
+}
+
+// requestProblem returns the message for errors caused by what a request
+// asked for, or empty for other errors
+func requestProblem(err error) string {
+	var unknownAmenity *db.UnknownAmenityError
+	var unknownBuilding *db.UnknownBuildingError
+	var formErr *forms.Error
+	var duplicate *db.DuplicateAddressError
+	switch {
+	case errors.As(err, &unknownAmenity):
+		return "Unknown amenity: " + unknownAmenity.Key
+	case errors.As(err, &unknownBuilding):
+		return unknownBuilding.Error()
+	case errors.As(err, &formErr):
+		return formErr.Error()
+	case errors.As(err, &duplicate):
+		return "An apartment at this address already exists"
+	}
+	return ""
+}
+
+// RegisterRoutes registers all sync routes
+func (h *SyncHandler) RegisterRoutes(router *gin.Engine) {
+	router.GET("/api/sync", h.Changes)
+	router.POST("/api/sync", h.Push)
+}
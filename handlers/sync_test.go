@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mojotx/apt-eval/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestSyncRouter returns a router serving apartments and sync on a
+// fresh database
+func newTestSyncRouter(t *testing.T) *gin.Engine {
+	router, h := newTestApartmentHandler(t)
+	NewSyncHandler(h.db).RegisterRoutes(router)
+	return router
+}
+
+// push sends a batch of sync changes, returning their results
+func push(t *testing.T, router *gin.Engine, changes string) []models.SyncResult {
+	var response struct{ Results []models.SyncResult }
+	assert.Equal(t, http.StatusOK, send(t, router, http.MethodPost, "/api/sync", `{"changes":[`+changes+`]}`, &response))
+	return response.Results
+}
+
+func TestSyncPushConflicts(t *testing.T) {
+	router := newTestSyncRouter(t)
+
+	results := push(t, router, `{"op":"create","client_id":"local-1","apartment":{"address":"1 Main St","price":1500}}`)
+	if !assert.Len(t, results, 1) || !assert.Equal(t, models.SyncApplied, results[0].Status) {
+		t.FailNow()
+	}
+	created := results[0]
+	assert.Equal(t, "local-1", created.ClientID)
+	assert.NotEmpty(t, created.UUID)
+	id, stale := strconv.FormatInt(created.ID, 10), strconv.FormatInt(created.Version, 10)
+
+	results = push(t, router, `{"op":"update","id":`+id+`,"version":`+stale+`,"apartment":{"address":"1 Main St","price":1400}}`)
+	assert.Equal(t, models.SyncApplied, results[0].Status)
+	current := results[0].Version
+	assert.Greater(t, current, created.Version)
+
+	// Another client still on the first version
+	results = push(t, router,
+		`{"op":"update","id":`+id+`,"version":`+stale+`,"apartment":{"address":"1 Main St","price":1600}},`+
+			`{"op":"delete","id":`+id+`,"version":`+stale+`}`)
+	for _, result := range results {
+		assert.Equal(t, models.SyncConflict, result.Status, result.Op)
+		assert.Equal(t, current, result.Version, "the server's version")
+		assert.False(t, result.Deleted)
+		if assert.NotNil(t, result.Apartment, "the server's copy") && assert.NotNil(t, result.Apartment.Price) {
+			assert.Equal(t, 1400.0, *result.Apartment.Price)
+		}
+	}
+	var got models.Apartment
+	assert.Equal(t, http.StatusOK, send(t, router, http.MethodGet, "/api/apartments/"+id, "", &got), "not deleted")
+	if assert.NotNil(t, got.Price) {
+		assert.Equal(t, 1400.0, *got.Price, "not updated")
+	}
+
+	// Merged and sent again on the server's version, by UUID this time
+	results = push(t, router, `{"op":"update","uuid":"`+created.UUID+`","version":`+strconv.FormatInt(current, 10)+`,"apartment":{"address":"1 Main St","price":1600}}`)
+	assert.Equal(t, models.SyncApplied, results[0].Status)
+	assert.Equal(t, created.ID, results[0].ID)
+}
+
+func TestSyncPushAfterServerDelete(t *testing.T) {
+	router := newTestSyncRouter(t)
+
+	created := push(t, router, `{"op":"create","apartment":{"address":"1 Main St"}}`)[0]
+	id, version := strconv.FormatInt(created.ID, 10), strconv.FormatInt(created.Version, 10)
+	assert.Equal(t, http.StatusOK, send(t, router, http.MethodDelete, "/api/apartments/"+id, "", nil))
+
+	results := push(t, router,
+		`{"op":"update","id":`+id+`,"version":`+version+`,"apartment":{"address":"1 Main St","price":1600}},`+
+			`{"op":"delete","id":`+id+`,"version":`+version+`}`)
+	if assert.Len(t, results, 2) {
+		assert.Equal(t, models.SyncConflict, results[0].Status, "an update to a deleted apartment")
+		assert.True(t, results[0].Deleted)
+		assert.Nil(t, results[0].Apartment)
+		assert.Equal(t, models.SyncApplied, results[1].Status, "deleting it again is a no-op")
+		assert.True(t, results[1].Deleted)
+	}
+	assert.Equal(t, http.StatusNotFound, send(t, router, http.MethodGet, "/api/apartments/"+id, "", nil), "not brought back")
+
+	results = push(t, router, `{"op":"update","id":999,"version":1,"apartment":{"address":"2 Main St"}}`)
+	assert.Equal(t, models.SyncRejected, results[0].Status, "an apartment that never existed")
+}
+
+func TestSyncChanges(t *testing.T) {
+	router := newTestSyncRouter(t)
+	first := push(t, router, `{"op":"create","apartment":{"address":"1 Main St"}}`)[0]
+	second := push(t, router, `{"op":"create","apartment":{"address":"2 Main St"}}`)[0]
+	push(t, router, `{"op":"delete","id":`+strconv.FormatInt(first.ID, 10)+`,"version":`+strconv.FormatInt(first.Version, 10)+`}`)
+
+	var page models.SyncChanges
+	assert.Equal(t, http.StatusOK, send(t, router, http.MethodGet, "/api/sync?limit=1", "", &page))
+	assert.True(t, page.HasMore)
+	if assert.Len(t, page.Changes, 1) {
+		assert.Equal(t, second.ID, page.Changes[0].ApartmentID, "oldest change first")
+	}
+
+	var next models.SyncChanges
+	assert.Equal(t, http.StatusOK, send(t, router, http.MethodGet, "/api/sync?since="+page.Cursor, "", &next))
+	assert.False(t, next.HasMore)
+	if assert.Len(t, next.Changes, 1) {
+		assert.Equal(t, first.ID, next.Changes[0].ApartmentID)
+		assert.True(t, next.Changes[0].Deleted)
+		assert.Nil(t, next.Changes[0].Apartment)
+	}
+
+	assert.Equal(t, http.StatusBadRequest, send(t, router, http.MethodGet, "/api/sync?since=soon", "", nil))
+}
@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mojotx/apt-eval/db"
+	"github.com/mojotx/apt-eval/forms"
+	"github.com/mojotx/apt-eval/models"
+	"github.com/rs/zerolog/log"
+)
+
+// TemplateHandler handles user-defined evaluation templates
+type TemplateHandler struct {
+	db *db.DB
+}
+
+// NewTemplateHandler creates a new template handler
+func NewTemplateHandler(db *db.DB) *TemplateHandler {
+	return &TemplateHandler{
+		db: db,
+	}
+}
+
+// List handles retrieving all templates
+func (h *TemplateHandler) List(c *gin.Context) {
+	templates, err := h.db.ListTemplates()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list templates")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list templates"})
+		return
+	}
+	respond(c, http.StatusOK, templates, nil)
+}
+
+// Get handles retrieving a single template
+func (h *TemplateHandler) Get(c *gin.Context) {
+	id, ok := parseID(c, "id", "template")
+	if !ok {
+		return
+	}
+
+	template, err := h.db.GetTemplate(id)
+	if err != nil {
+		log.Error().Err(err).Int64("id", id).Msg("Failed to get template")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get template"})
+		return
+	}
+	if template == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return
+	}
+	respond(c, http.StatusOK, template, nil)
+}
+
+// Active handles retrieving the active template
+func (h *TemplateHandler) Active(c *gin.Context) {
+	template, err := h.db.ActiveTemplate()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get active template")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get active template"})
+		return
+	}
+	if template == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No template is active"})
+		return
+	}
+	respond(c, http.StatusOK, template, nil)
+}
+
+// Create handles saving a new template
+func (h *TemplateHandler) Create(c *gin.Context) {
+	var request models.TemplateRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Error().Err(err).Msg("Failed to bind request")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	template, err := h.db.CreateTemplate(&request)
+	if err != nil {
+		if respondFormError(c, err) {
+			return
+		}
+		log.Error().Err(err).Msg("Failed to create template")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create template"})
+		return
+	}
+	c.JSON(http.StatusCreated, template)
+}
+
+// Update handles modifying a template
+func (h *TemplateHandler) Update(c *gin.Context) {
+	id, ok := parseID(c, "id", "template")
+	if !ok {
+		return
+	}
+
+	var request models.TemplateRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Error().Err(err).Msg("Failed to bind request")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	template, err := h.db.UpdateTemplate(id, &request)
+	if err != nil {
+		if respondFormError(c, err) {
+			return
+		}
+		log.Error().Err(err).Int64("id", id).Msg("Failed to update template")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update template"})
+		return
+	}
+	if template == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return
+	}
+	c.JSON(http.StatusOK, template)
+}
+
+// Delete handles removing a template
+func (h *TemplateHandler) Delete(c *gin.Context) {
+	id, ok := parseID(c, "id", "template")
+	if !ok {
+		return
+	}
+
+	if err := h.db.DeleteTemplate(id); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+			return
+		}
+		log.Error().Err(err).Int64("id", id).Msg("Failed to delete template")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete template"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success"})
+}
+
+// Activate handles making a template the active one
+func (h *TemplateHandler) Activate(c *gin.Context) {
+	id, ok := parseID(c, "id", "template")
+	if !ok {
+		return
+	}
+	h.activate(c, id)
+}
+
+// Deactivate handles turning off template validation
+func (h *TemplateHandler) Deactivate(c *gin.Context) {
+	h.activate(c, 0)
+}
+
+func (h *TemplateHandler) activate(c *gin.Context, id int64) {
+	if err := h.db.ActivateTemplate(id); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+			return
+		}
+		log.Error().Err(err).Int64("id", id).Msg("Failed to activate template")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to activate template"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success"})
+}
+
+// respondFormError responds with 400 and the per-field problems if err is
+// a template validation failure, reporting whether it was
+func respondFormError(c *gin.Context, err error) bool {
+	var formErr *forms.Error
+	if !errors.As(err, &formErr) {
+		return false
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": formErr.Error(), "fields": formErr.Problems})
+	return true
+}
+
+// RegisterRoutes registers all template routes
+func (h *TemplateHandler) RegisterRoutes(router *gin.Engine) {
+	templates := router.Group("/api/templates")
+	{
+		templates.GET("", h.List)
+		templates.POST("", h.Create)
+		templates.GET("/active", h.Active)
+		templates.DELETE("/active", h.Deactivate)
+		templates.GET("/:id", h.Get)
+		templates.PUT("/:id", h.Update)
+		templates.DELETE("/:id", h.Delete)
+		templates.POST("/:id/activate", h.Activate)
+	}
+}
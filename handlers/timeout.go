@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// timeoutGrace is how long past its timeout a request still has to send
+// its response, so one that gave up can say so
+const timeoutGrace = 5 * time.Second
+
+// Timeout bounds how long a request may take: its context is cancelled
+// after fallback, or after the timeout of the longest route prefix in
+// routes that matches it, so calls made with it give up. The connection's
+// read and write deadlines are moved to match, letting uploads and
+// exports that are allowed longer outlast the server's own timeouts. A
+// timeout of zero leaves the request unbounded.
+func Timeout(fallback time.Duration, routes map[string]time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		timeout := routeTimeout(c.FullPath(), fallback, routes)
+		rc := http.NewResponseController(c.Writer)
+		if timeout <= 0 {
+			// Unsupported on HTTP/3, which has its own idle timeout
+			_ = rc.SetReadDeadline(time.Time{})
+			_ = rc.SetWriteDeadline(time.Time{})
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+		deadline := time.Now().Add(timeout + timeoutGrace)
+		_ = rc.SetReadDeadline(deadline)
+		_ = rc.SetWriteDeadline(deadline)
+
+		c.Next()
+
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Warn().
+				Str("request_id", requestID(c)).
+				Str("route", c.FullPath()).
+				Dur("timeout", timeout).
+				Msg("Request timed out")
+			if !c.Writer.Written() {
+				c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Request timed out"})
+			}
+		}
+	}
+}
+
+// routeTimeout returns the timeout for route: that of the longest prefix
+// in routes it's under, or fallback
+func routeTimeout(route string, fallback time.Duration, routes map[string]time.Duration) time.Duration {
+	timeout, longest := fallback, -1
+	for prefix, t := range routes {
+		if len(prefix) > longest && isExcludedPath(route, []string{prefix}) {
+			timeout, longest = t, len(prefix)
+		}
+	}
+	return timeout
+}
+
+// ParseRouteTimeouts parses request timeouts by route, written as
+// route=seconds entries separated by semicolons. Routes are written as
+// they're registered, with :params, and cover the routes under them.
+func ParseRouteTimeouts(s string) (map[string]time.Duration, error) {
+	routes := make(map[string]time.Duration)
+	for entry := range strings.SplitSeq(s, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		route, seconds, ok := strings.Cut(entry, "=")
+		route = strings.TrimSpace(route)
+		if !ok || !strings.HasPrefix(route, "/") {
+			return nil, fmt.Errorf("invalid route timeout %q, want /route=seconds", entry)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(seconds))
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid timeout %q for route %q", seconds, route)
+		}
+		routes[route] = time.Duration(n) * time.Second
+	}
+	return routes, nil
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mojotx/apt-eval/db"
+	"github.com/mojotx/apt-eval/models"
+	"github.com/rs/zerolog/log"
+)
+
+// defaultTriggerLimit is how many events a trigger returns by default,
+// as many as Zapier looks at per poll
+const defaultTriggerLimit = 100
+
+// triggerActions are the activity feed actions each trigger reports
+var triggerActions = map[string][]string{
+	models.TriggerNewApartment:  {models.ActivityAdded, models.ActivityCaptured},
+	models.TriggerStatusChanged: {models.ActivityStatusChanged},
+	models.TriggerRatingChanged: {models.ActivityRated},
+}
+
+// createApartmentFields are the inputs of the create apartment action, a
+// subset of the apartment request that covers what's typically known
+// about a listing
+var createApartmentFields = []models.ActionField{
+	{Key: "address", Label: "Address", Type: "string", Required: true, HelpText: "Street address, with the unit if any"},
+	{Key: "status", Label: "Status", Type: "string", HelpText: "Defaults to considering", Choices: models.Statuses},
+	{Key: "price", Label: "Monthly rent", Type: "number"},
+	{Key: "listing_url", Label: "Listing URL", Type: "string"},
+	{Key: "notes", Label: "Notes", Type: "text"},
+	{Key: "rating", Label: "Rating", Type: "integer", HelpText: "From 1 to 5"},
+	{Key: "visit_date", Label: "Visit date", Type: "datetime"},
+	{Key: "is_gated", Label: "Gated", Type: "boolean"},
+	{Key: "has_garage", Label: "Garage parking", Type: "boolean"},
+	{Key: "has_laundry", Label: "In-unit laundry", Type: "boolean"},
+}
+
+// TriggerHandler serves polling triggers and action schemas shaped for
+// automation services like Zapier and IFTTT
+type TriggerHandler struct {
+	db *db.DB
+}
+
+// NewTriggerHandler creates a new trigger handler
+func NewTriggerHandler(db *db.DB) *TriggerHandler {
+	return &TriggerHandler{
+		db: db,
+	}
+}
+
+// Poll handles a polling trigger, returning its events newest first, each
+// with an ID of its own for the service to tell new events from those it
+// has seen. since returns only events newer than the given ID.
+func (h *TriggerHandler) Poll(c *gin.Context) {
+	trigger := c.Param("trigger")
+	actions, ok := triggerActions[trigger]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown trigger"})
+		return
+	}
+
+	viewer := viewerID(c)
+	opts := db.ActivityOptions{Viewer: viewer, Actions: actions, Limit: defaultTriggerLimit}
+	if s := c.Query("since"); s != "" {
+		since, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || since < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid since"})
+			return
+		}
+		opts.Since = since
+	}
+	if s := c.Query("limit"); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil || limit < 1 || limit > maxPageSize {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", maxPageSize)})
+			return
+		}
+		opts.Limit = limit
+	}
+
+	activity, err := h.db.ListActivity(opts)
+	if err != nil {
+		log.Error().Err(err).Str("trigger", trigger).Msg("Failed to list trigger events")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list trigger events"})
+		return
+	}
+
+	apartments := map[int64]*models.Apartment{}
+	events := make([]models.TriggerEvent, 0, len(activity))
+	for _, a := range activity {
+		apartment, seen := apartments[a.ApartmentID]
+		if !seen {
+			apartment, err = h.db.GetApartment(a.ApartmentID)
+			if err != nil {
+				log.Error().Err(err).Int64("id", a.ApartmentID).Msg("Failed to get apartment")
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list trigger events"})
+				return
+			}
+			if apartment != nil && !apartment.VisibleTo(viewer) {
+				apartment = nil
+			}
+			apartments[a.ApartmentID] = apartment
+		}
+
+		event := models.TriggerEvent{
+			ID:          a.ID,
+			Trigger:     trigger,
+			ApartmentID: a.ApartmentID,
+			Address:     a.Address,
+			User:        a.User,
+			OccurredAt:  a.CreatedAt,
+			Apartment:   apartment,
+		}
+		if a.Action == models.ActivityStatusChanged {
+			event.PreviousStatus, event.NewStatus, _ = strings.Cut(a.Detail, " → ")
+		}
+		events = append(events, event)
+	}
+	respond(c, http.StatusOK, events, nil)
+}
+
+// CreateApartmentSchema handles describing the create apartment action:
+// the request that performs it and its input fields
+func (h *TriggerHandler) CreateApartmentSchema(c *gin.Context) {
+	respond(c, http.StatusOK, models.ActionSchema{
+		Key:         "create_apartment",
+		Noun:        "Apartment",
+		Label:       "Create Apartment",
+		Method:      http.MethodPost,
+		URL:         baseURL(c) + "/api/apartments",
+		InputFields: createApartmentFields,
+	}, nil)
+}
+
+// RegisterRoutes registers the trigger and action schema routes
+func (h *TriggerHandler) RegisterRoutes(router *gin.Engine) {
+	router.GET("/api/triggers/:trigger", h.Poll)
+	router.GET("/api/actions/create_apartment", h.CreateApartmentSchema)
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mojotx/apt-eval/db"
+	"github.com/rs/zerolog/log"
+)
+
+// UndoHandler handles undoing the caller's most recent change
+type UndoHandler struct {
+	db     *db.DB
+	window time.Duration
+}
+
+// NewUndoHandler creates a new undo handler for changes made within window
+func NewUndoHandler(db *db.DB, window time.Duration) *UndoHandler {
+	return &UndoHandler{
+		db:     db,
+		window: window,
+	}
+}
+
+// Undo handles reversing the caller's most recent edit or delete of an
+// apartment
+func (h *UndoHandler) Undo(c *gin.Context) {
+	entry, err := h.db.Undo(viewerID(c), time.Now().Add(-h.window))
+	if errors.Is(err, db.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Nothing to undo"})
+		return
+	}
+	if errors.Is(err, db.ErrUndoConflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Apartment was changed since; it can't be undone"})
+		return
+	}
+	if respondDuplicateAddress(c, h.db, err) {
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to undo")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to undo"})
+		return
+	}
+
+	apartment, err := h.db.GetApartment(entry.ApartmentID)
+	if err != nil {
+		log.Error().Err(err).Int64("id", entry.ApartmentID).Msg("Failed to get apartment")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get apartment"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"undone": entry, "apartment": apartment})
+}
+
+// RegisterRoutes registers the undo route
+func (h *UndoHandler) RegisterRoutes(router *gin.Engine) {
+	router.POST("/api/undo", h.Undo)
+}
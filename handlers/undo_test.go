@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mojotx/apt-eval/db"
+	"github.com/mojotx/apt-eval/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestUndoRouter returns a router serving apartments and undo on a
+// fresh database, and the database
+func newTestUndoRouter(t *testing.T) (*gin.Engine, *db.DB) {
+	router, h := newTestApartmentHandler(t)
+	NewUndoHandler(h.db, time.Hour).RegisterRoutes(router)
+	return router, h.db
+}
+
+func TestUndoEditAfterConcurrentEdit(t *testing.T) {
+	router, database := newTestUndoRouter(t)
+	alice := createTestUser(t, database, "Alice")
+	bob := createTestUser(t, database, "Bob")
+
+	var apartment models.Apartment
+	sendAs(t, router, alice, http.MethodPost, "/api/apartments", `{"address":"1 Main St","price":1500}`, &apartment)
+	url := "/api/apartments/" + strconv.FormatInt(apartment.ID, 10)
+
+	assert.Equal(t, http.StatusOK, sendAs(t, router, alice, http.MethodPatch, url, `{"price":1600,"notes":"Sunny"}`, nil))
+	assert.Equal(t, http.StatusOK, sendAs(t, router, bob, http.MethodPatch, url, `{"price":1700}`, nil))
+
+	var refused struct{ Error string }
+	assert.Equal(t, http.StatusConflict, sendAs(t, router, alice, http.MethodPost, "/api/undo", "", &refused))
+	assert.Contains(t, refused.Error, "changed since")
+	var got models.Apartment
+	send(t, router, http.MethodGet, url, "", &got)
+	if assert.NotNil(t, got.Price) {
+		assert.Equal(t, 1700.0, *got.Price, "Bob's edit is kept")
+	}
+	assert.Equal(t, "Sunny", got.Notes, "nothing is partly undone")
+
+	// Bob's own edit can still be undone, and then Alice's
+	assert.Equal(t, http.StatusOK, sendAs(t, router, bob, http.MethodPost, "/api/undo", "", nil))
+	assert.Equal(t, http.StatusOK, sendAs(t, router, alice, http.MethodPost, "/api/undo", "", nil))
+	send(t, router, http.MethodGet, url, "", &got)
+	if assert.NotNil(t, got.Price) {
+		assert.Equal(t, 1500.0, *got.Price)
+	}
+	assert.Empty(t, got.Notes)
+}
+
+func TestUndoEditKeepsOtherFields(t *testing.T) {
+	router, database := newTestUndoRouter(t)
+	alice := createTestUser(t, database, "Alice")
+	bob := createTestUser(t, database, "Bob")
+
+	var apartment models.Apartment
+	sendAs(t, router, alice, http.MethodPost, "/api/apartments", `{"address":"1 Main St","price":1500}`, &apartment)
+	url := "/api/apartments/" + strconv.FormatInt(apartment.ID, 10)
+
+	assert.Equal(t, http.StatusOK, sendAs(t, router, alice, http.MethodPatch, url, `{"notes":"Sunny"}`, nil))
+	assert.Equal(t, http.StatusOK, sendAs(t, router, bob, http.MethodPatch, url, `{"price":1700}`, nil))
+
+	assert.Equal(t, http.StatusOK, sendAs(t, router, alice, http.MethodPost, "/api/undo", "", nil))
+	var got models.Apartment
+	send(t, router, http.MethodGet, url, "", &got)
+	assert.Empty(t, got.Notes)
+	if assert.NotNil(t, got.Price) {
+		assert.Equal(t, 1700.0, *got.Price)
+	}
+}
+
+func TestUndoDelete(t *testing.T) {
+	router, database := newTestUndoRouter(t)
+	alice := createTestUser(t, database, "Alice")
+
+	var apartment models.Apartment
+	sendAs(t, router, alice, http.MethodPost, "/api/apartments",
+		`{"address":"1 Main St","unit":"4B","price":1500,"notes":"Sunny","amenities":["dishwasher","balcony"]}`, &apartment)
+	url := "/api/apartments/" + strconv.FormatInt(apartment.ID, 10)
+	assert.Equal(t, http.StatusOK, sendAs(t, router, alice, http.MethodDelete, url, "", nil))
+	assert.Equal(t, http.StatusNotFound, send(t, router, http.MethodGet, url, "", nil))
+
+	var undone struct {
+		Undone    models.Activity
+		Apartment models.Apartment
+	}
+	assert.Equal(t, http.StatusOK, sendAs(t, router, alice, http.MethodPost, "/api/undo", "", &undone))
+	assert.Equal(t, models.ActivityDeleted, undone.Undone.Action)
+	assert.Equal(t, apartment.ID, undone.Apartment.ID)
+
+	var got models.Apartment
+	assert.Equal(t, http.StatusOK, send(t, router, http.MethodGet, url, "", &got))
+	assert.Equal(t, "1 Main St", got.Address)
+	assert.Equal(t, "4B", got.Unit)
+	assert.Equal(t, "Sunny", got.Notes)
+	if assert.NotNil(t, got.Price) {
+		assert.Equal(t, 1500.0, *got.Price)
+	}
+	assert.ElementsMatch(t, []string{"dishwasher", "balcony"}, got.Amenities)
+
+	var found []models.Apartment
+	send(t, router, http.MethodGet, "/api/apartments?amenities=balcony", "", &found)
+	if assert.Len(t, found, 1, "the restored apartment is searchable again") {
+		assert.Equal(t, apartment.ID, found[0].ID)
+	}
+
+	// Undos aren't themselves undone
+	assert.Equal(t, http.StatusNotFound, sendAs(t, router, alice, http.MethodPost, "/api/undo", "", nil))
+}
+
+func TestUndoDeleteAfterAddressTaken(t *testing.T) {
+	router, database := newTestUndoRouter(t)
+	alice := createTestUser(t, database, "Alice")
+	assert.NoError(t, database.RequireUniqueAddresses(true))
+
+	var apartment models.Apartment
+	sendAs(t, router, alice, http.MethodPost, "/api/apartments", `{"address":"1 Main St"}`, &apartment)
+	assert.Equal(t, http.StatusOK, sendAs(t, router, alice, http.MethodDelete, "/api/apartments/"+strconv.FormatInt(apartment.ID, 10), "", nil))
+	assert.Equal(t, http.StatusCreated, send(t, router, http.MethodPost, "/api/apartments", `{"address":"1 Main St"}`, nil))
+
+	var conflict struct {
+		ExistingID int64 `json:"existing_id"`
+	}
+	assert.Equal(t, http.StatusConflict, sendAs(t, router, alice, http.MethodPost, "/api/undo", "", &conflict))
+	assert.NotZero(t, conflict.ExistingID)
+	assert.NotEqual(t, apartment.ID, conflict.ExistingID)
+}
+
+func TestUndoAmenities(t *testing.T) {
+	router, database := newTestUndoRouter(t)
+	alice := createTestUser(t, database, "Alice")
+	bob := createTestUser(t, database, "Bob")
+
+	var apartment models.Apartment
+	sendAs(t, router, alice, http.MethodPost, "/api/apartments", `{"address":"1 Main St","amenities":["ac"]}`, &apartment)
+	url := "/api/apartments/" + strconv.FormatInt(apartment.ID, 10)
+
+	assert.Equal(t, http.StatusOK, sendAs(t, router, alice, http.MethodPatch, url, `{"amenities":["ac","balcony"]}`, nil))
+	assert.Equal(t, http.StatusOK, sendAs(t, router, alice, http.MethodPost, "/api/undo", "", nil))
+	var got models.Apartment
+	send(t, router, http.MethodGet, url, "", &got)
+	assert.Equal(t, []string{"ac"}, got.Amenities)
+
+	assert.Equal(t, http.StatusOK, sendAs(t, router, alice, http.MethodPatch, url, `{"amenities":["ac","balcony"]}`, nil))
+	assert.Equal(t, http.StatusOK, sendAs(t, router, bob, http.MethodPatch, url, `{"amenities":["balcony"]}`, nil))
+	assert.Equal(t, http.StatusConflict, sendAs(t, router, alice, http.MethodPost, "/api/undo", "", nil))
+	send(t, router, http.MethodGet, url, "", &got)
+	assert.Equal(t, []string{"balcony"}, got.Amenities)
+}
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mojotx/apt-eval/health"
+	"github.com/mojotx/apt-eval/media"
+	"github.com/mojotx/apt-eval/scan"
+	"github.com/mojotx/apt-eval/storage"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// quarantinePrefix is prepended to the storage keys of files the virus
+	// scanner flagged, keeping them apart from files that may be served
+	quarantinePrefix = "quarantine/"
+
+	// multipartOverhead allows for the form encoding around an upload
+	multipartOverhead = 64 << 10
+
+	// thumbnailSize is the longer side, in pixels, of image thumbnails
+	thumbnailSize = 320
+
+	// maxCaptionLength is the longest attachment caption, in bytes
+	maxCaptionLength = 500
+)
+
+// UploadLimits restricts the attachments that can be uploaded
+type UploadLimits struct {
+	MaxSize int64 // Bytes per file
+	// AllowedTypes are MIME types as sniffed from the content; "image/*"
+	// allows every image type
+	AllowedTypes    []string
+	MaxPerApartment int   // 0 for no limit
+	Quota           int64 // Bytes all uploaded files may take up; 0 for no limit
+}
+
+// allows reports whether files of the sniffed contentType may be uploaded
+func (l UploadLimits) allows(contentType string) bool {
+	for _, t := range l.AllowedTypes {
+		if t == contentType || strings.HasSuffix(t, "/*") && strings.HasPrefix(contentType, strings.TrimSuffix(t, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+// readUpload reads the multipart field "file" of an upload of at most
+// maxSize bytes, responding with 400 or 413 when it's missing or too big
+func readUpload(c *gin.Context, maxSize int64, what string) (data []byte, filename string, ok bool) {
+	tooBig := func() {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("%s exceeds the %d MB limit", what, maxSize>>20)})
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize+multipartOverhead)
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			tooBig()
+			return nil, "", false
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing file: " + err.Error()})
+		return nil, "", false
+	}
+	defer file.Close()
+
+	data, err = io.ReadAll(file)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to read upload")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read upload"})
+		return nil, "", false
+	}
+	if int64(len(data)) > maxSize {
+		tooBig()
+		return nil, "", false
+	}
+	return data, filepath.Base(header.Filename), true
+}
+
+// uploadScan is the virus scanner's verdict on an upload
+type uploadScan struct {
+	status    string
+	signature string
+	scannedAt *time.Time
+}
+
+// infected reports whether the file must be quarantined
+func (s uploadScan) infected() bool {
+	return s.status == scan.StatusInfected
+}
+
+// scanUpload checks an upload with the virus scanner, if one is
+// configured. When the scanner can't be reached the upload is refused
+// with 503 rather than stored unchecked.
+func scanUpload(c *gin.Context, scanner scan.Scanner, data []byte) (uploadScan, bool) {
+	if scanner == nil {
+		return uploadScan{status: scan.StatusUnscanned}, true
+	}
+
+	result, err := scanner.Scan(c.Request.Context(), bytes.NewReader(data))
+	health.Record("virus_scanner", err)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to scan upload")
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Virus scanner unavailable, try again later"})
+		return uploadScan{}, false
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	return uploadScan{status: result.Status(), signature: result.Signature, scannedAt: &now}, true
+}
+
+// respondInfected answers an upload the virus scanner flagged
+func respondInfected(c *gin.Context, verdict uploadScan, extra gin.H) {
+	body := gin.H{"error": "File rejected: malware detected (" + verdict.signature + ")"}
+	for k, v := range extra {
+		body[k] = v
+	}
+	c.JSON(http.StatusUnprocessableEntity, body)
+}
+
+// makeThumbnail renders and stores the thumbnail of the image stored under
+// key, turned upright, returning it opened for reading
+func makeThumbnail(store *storage.Store, key, thumbKey string) (*os.File, error) {
+	data, err := readStored(store, key)
+	if err != nil {
+		return nil, err
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	img = media.Orient(img, media.Orientation(data))
+	img = media.Thumbnail(img, thumbnailSize)
+
+	// JPEG has no transparency, so flatten onto white
+	flat := image.NewRGBA(img.Bounds())
+	draw.Draw(flat, flat.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), img, img.Bounds().Min, draw.Over)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, flat, &jpeg.Options{Quality: 75}); err != nil {
+		return nil, fmt.Errorf("failed to encode thumbnail: %w", err)
+	}
+	if _, err := store.Put(thumbKey, &buf); err != nil {
+		return nil, fmt.Errorf("failed to store thumbnail: %w", err)
+	}
+	return store.Open(thumbKey)
+}
+
+// readStored reads the whole file stored under key
+func readStored(store *storage.Store, key string) ([]byte, error) {
+	f, err := store.Open(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", key, err)
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", key, err)
+	}
+	return data, nil
+}
+
+// serveThumbnail responds with a small JPEG preview of the image stored
+// under key, rendered on first request and kept under thumbKey until the
+// image changes
+func serveThumbnail(c *gin.Context, store *storage.Store, key, thumbKey string, modTime time.Time) {
+	f, err := store.Open(thumbKey)
+	if errors.Is(err, os.ErrNotExist) {
+		f, err = makeThumbnail(store, key, thumbKey)
+	}
+	if err != nil {
+		log.Error().Err(err).Str("key", key).Msg("Failed to get thumbnail")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get thumbnail"})
+		return
+	}
+	defer f.Close()
+
+	c.Header("Content-Type", "image/jpeg")
+	c.Header("Cache-Control", "public, max-age=86400")
+	http.ServeContent(c.Writer, c.Request, "", modTime, f)
+}
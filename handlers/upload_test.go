@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mojotx/apt-eval/db"
+	"github.com/mojotx/apt-eval/models"
+	"github.com/mojotx/apt-eval/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUploadLimitsAllows(t *testing.T) {
+	limits := UploadLimits{AllowedTypes: []string{"image/*", "application/pdf"}}
+
+	tests := []struct {
+		contentType string
+		expected    bool
+	}{
+		{"image/png", true},
+		{"image/webp", true},
+		{"application/pdf", true},
+		{"application/zip", false},
+		{"text/html", false},
+		{"imagex/png", false},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, limits.allows(tt.contentType), tt.contentType)
+	}
+	assert.False(t, UploadLimits{}.allows("image/png"))
+}
+
+func TestStorageQuota(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	database, err := db.New(t.TempDir())
+	assert.NoError(t, err)
+	defer database.Close()
+	store, err := storage.New(t.TempDir())
+	assert.NoError(t, err)
+
+	router := gin.New()
+	NewAttachmentHandler(database, store, nil, UploadLimits{
+		MaxSize:      1 << 20,
+		AllowedTypes: []string{"text/plain"},
+		Quota:        2500,
+	}, false).RegisterRoutes(router)
+	NewUsageHandler(database, 2500).RegisterRoutes(router)
+	apartment, err := database.CreateApartment(&models.ApartmentRequest{Address: "1 Main St"})
+	assert.NoError(t, err)
+
+	upload := func(size int) int {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		fw, _ := mw.CreateFormFile("file", "lease.txt")
+		fw.Write(bytes.Repeat([]byte("a"), size))
+		mw.Close()
+		req := httptest.NewRequest(http.MethodPost, "/api/apartments/"+strconv.FormatInt(apartment.ID, 10)+"/attachments", &body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+	assert.Equal(t, http.StatusCreated, upload(1000))
+	assert.Equal(t, http.StatusCreated, upload(1200))
+	assert.Equal(t, http.StatusInsufficientStorage, upload(400), "over the quota")
+	assert.Equal(t, http.StatusCreated, upload(300))
+
+	var usage models.StorageUsage
+	send(t, router, http.MethodGet, "/api/settings/usage", "", &usage)
+	assert.Equal(t, models.FileUsage{Count: 3, Bytes: 2500}, usage.Documents)
+	assert.Equal(t, int64(2500), usage.UsedBytes)
+	assert.Equal(t, int64(0), *usage.RemainingBytes)
+
+	var largest []models.Attachment
+	send(t, router, http.MethodGet, "/api/settings/usage/largest?limit=2", "", &largest)
+	if assert.Len(t, largest, 2) {
+		assert.Equal(t, int64(1200), largest[0].Size)
+		assert.Equal(t, int64(1000), largest[1].Size)
+	}
+	assert.Equal(t, http.StatusBadRequest, send(t, router, http.MethodGet, "/api/settings/usage/largest?limit=0", "", nil))
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mojotx/apt-eval/db"
+	"github.com/rs/zerolog/log"
+)
+
+// defaultLargestLimit is how many attachments are listed for cleanup
+// unless asked for more
+const defaultLargestLimit = 20
+
+// UsageHandler reports the space taken up by uploaded files
+type UsageHandler struct {
+	db    *db.DB
+	quota int64 // Bytes all uploaded files may take up; 0 for no limit
+}
+
+// NewUsageHandler creates a new usage handler
+func NewUsageHandler(db *db.DB, quota int64) *UsageHandler {
+	return &UsageHandler{
+		db:    db,
+		quota: quota,
+	}
+}
+
+// Usage handles reporting the storage used by photos, documents, and floor
+// plans, and how much of the quota is left
+func (h *UsageHandler) Usage(c *gin.Context) {
+	usage, err := h.db.StorageUsage()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get storage usage")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get storage usage"})
+		return
+	}
+	if h.quota > 0 {
+		remaining := max(h.quota-usage.UsedBytes, 0)
+		usage.QuotaBytes, usage.RemainingBytes = h.quota, &remaining
+	}
+	respond(c, http.StatusOK, usage, nil)
+}
+
+// Largest handles listing the biggest attachments, largest first, to pick
+// from when freeing space. ?limit= sets how many.
+func (h *UsageHandler) Largest(c *gin.Context) {
+	limit := defaultLargestLimit
+	if s := c.Query("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > maxPageSize {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", maxPageSize)})
+			return
+		}
+		limit = n
+	}
+
+	attachments, err := h.db.LargestAttachments(viewerID(c), limit)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list largest attachments")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list largest attachments"})
+		return
+	}
+	respond(c, http.StatusOK, attachments, nil)
+}
+
+// withinQuota reports whether storing a file of size bytes, in place of
+// one of replacing bytes, keeps uploads within quota, responding with 507
+// when it doesn't
+func withinQuota(c *gin.Context, database *db.DB, quota, size, replacing int64) bool {
+	if quota <= 0 {
+		return true
+	}
+	usage, err := database.StorageUsage()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get storage usage")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check storage quota"})
+		return false
+	}
+	if usage.UsedBytes-replacing+size <= quota {
+		return true
+	}
+	c.JSON(http.StatusInsufficientStorage, gin.H{
+		"error":       fmt.Sprintf("Upload would exceed the %d MB storage quota", quota>>20),
+		"used_bytes":  usage.UsedBytes,
+		"quota_bytes": quota,
+	})
+	return false
+}
+
+// RegisterRoutes registers the storage usage routes
+func (h *UsageHandler) RegisterRoutes(router *gin.Engine) {
+	router.GET("/api/settings/usage", h.Usage)
+	router.GET("/api/settings/usage/largest", h.Largest)
+}
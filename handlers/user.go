@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mojotx/apt-eval/db"
+	"github.com/mojotx/apt-eval/models"
+	"github.com/mojotx/apt-eval/storage"
+	"github.com/rs/zerolog/log"
+)
+
+// notificationHistory is how many of a user's notifications are listed
+const notificationHistory = 50
+
+// UserHandler handles users and their notification settings
+type UserHandler struct {
+	db    *db.DB
+	store *storage.Store
+}
+
+// NewUserHandler creates a new user handler
+func NewUserHandler(db *db.DB, store *storage.Store) *UserHandler {
+	return &UserHandler{
+		db:    db,
+		store: store,
+	}
+}
+
+// ListUsers handles retrieving all users
+func (h *UserHandler) ListUsers(c *gin.Context) {
+	users, err := h.db.ListUsers()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list users")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list users"})
+		return
+	}
+	respond(c, http.StatusOK, users, nil)
+}
+
+// GetUser handles retrieving a single user
+func (h *UserHandler) GetUser(c *gin.Context) {
+	id, ok := parseID(c, "id", "user")
+	if !ok {
+		return
+	}
+
+	user, err := h.db.GetUser(id)
+	if err != nil {
+		log.Error().Err(err).Int64("id", id).Msg("Failed to get user")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user"})
+		return
+	}
+	if user == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	respond(c, http.StatusOK, user, nil)
+}
+
+// CreateUser handles adding a user
+func (h *UserHandler) CreateUser(c *gin.Context) {
+	var request models.UserRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Error().Err(err).Msg("Failed to bind request")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.db.CreateUser(&request)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to create user")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+		return
+	}
+	c.JSON(http.StatusCreated, user)
+}
+
+// UpdateUser handles modifying a user
+func (h *UserHandler) UpdateUser(c *gin.Context) {
+	id, ok := parseID(c, "id", "user")
+	if !ok {
+		return
+	}
+
+	var request models.UserRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Error().Err(err).Msg("Failed to bind request")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.db.UpdateUser(id, &request)
+	if err != nil {
+		log.Error().Err(err).Int64("id", id).Msg("Failed to update user")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		return
+	}
+	if user == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+// DeleteUser handles removing a user
+func (h *UserHandler) DeleteUser(c *gin.Context) {
+	id, ok := parseID(c, "id", "user")
+	if !ok {
+		return
+	}
+
+	if err := h.db.DeleteUser(id); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		log.Error().Err(err).Int64("id", id).Msg("Failed to delete user")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success"})
+}
+
+// Notifications handles listing a user's recent notifications and their
+// delivery status
+func (h *UserHandler) Notifications(c *gin.Context) {
+	id, ok := parseID(c, "id", "user")
+	if !ok {
+		return
+	}
+
+	user, err := h.db.GetUser(id)
+	if err != nil {
+		log.Error().Err(err).Int64("id", id).Msg("Failed to get user")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user"})
+		return
+	}
+	if user == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	notifications, err := h.db.ListNotifications(id, notificationHistory)
+	if err != nil {
+		log.Error().Err(err).Int64("id", id).Msg("Failed to list notifications")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list notifications"})
+		return
+	}
+	respond(c, http.StatusOK, notifications, nil)
+}
+
+// ownAccount checks that the request is made as the user with the given
+// ID, responding 403 if not
+func ownAccount(c *gin.Context, id int64) bool {
+	if viewerID(c) != id {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the user themselves can do this"})
+		return false
+	}
+	return true
+}
+
+// Export handles a user downloading everything stored about them
+func (h *UserHandler) Export(c *gin.Context) {
+	id, ok := parseID(c, "id", "user")
+	if !ok || !ownAccount(c, id) {
+		return
+	}
+
+	export, err := h.db.ExportUser(id)
+	if err != nil {
+		log.Error().Err(err).Int64("id", id).Msg("Failed to export user data")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export user data"})
+		return
+	}
+	if export == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="user-%d-data.json"`, id))
+	c.JSON(http.StatusOK, export)
+}
+
+// Erase handles a user deleting their account and scrubbing their data,
+// leaving the household's shared records in place
+func (h *UserHandler) Erase(c *gin.Context) {
+	id, ok := parseID(c, "id", "user")
+	if !ok || !ownAccount(c, id) {
+		return
+	}
+
+	erasure, err := h.db.EraseUser(id)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		log.Error().Err(err).Int64("id", id).Msg("Failed to erase user")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to erase user"})
+		return
+	}
+	removeApartmentFiles(h.store, erasure.Attachments, erasure.DeletedApartments)
+	log.Info().Int64("id", id).Msg("Erased user")
+	c.JSON(http.StatusOK, erasure)
+}
+
+// RegisterRoutes registers all user routes
+func (h *UserHandler) RegisterRoutes(router *gin.Engine) {
+	users := router.Group("/api/users")
+	{
+		users.GET("", h.ListUsers)
+		users.POST("", h.CreateUser)
+		users.GET("/:id", h.GetUser)
+		users.PUT("/:id", h.UpdateUser)
+		users.DELETE("/:id", h.DeleteUser)
+		users.GET("/:id/notifications", h.Notifications)
+		users.GET("/:id/export", h.Export)
+		users.POST("/:id/erase", h.Erase)
+	}
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mojotx/apt-eval/db"
+	"github.com/mojotx/apt-eval/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestUserHandler returns a router serving the apartment and user
+// handlers on a fresh database, and the database
+func newTestUserHandler(t *testing.T) (*gin.Engine, *db.DB) {
+	router, h := newTestApartmentHandler(t)
+	NewUserHandler(h.db, h.store).RegisterRoutes(router)
+	return router, h.db
+}
+
+func userURL(id int64, action string) string {
+	return "/api/users/" + strconv.FormatInt(id, 10) + "/" + action
+}
+
+func TestUserOwnAccount(t *testing.T) {
+	router, database := newTestUserHandler(t)
+	alex := createTestUser(t, database, "Alex")
+	sam := createTestUser(t, database, "Sam")
+
+	for _, req := range []struct{ method, action string }{
+		{http.MethodGet, "export"},
+		{http.MethodPost, "erase"},
+	} {
+		url := userURL(alex, req.action)
+		assert.Equal(t, http.StatusForbidden, sendAs(t, router, sam, req.method, url, "", nil), "%s as another user", req.action)
+		assert.Equal(t, http.StatusForbidden, send(t, router, req.method, url, "", nil), "%s as no one", req.action)
+	}
+	user, err := database.GetUser(alex)
+	assert.NoError(t, err)
+	assert.NotNil(t, user, "not erased by someone else")
+}
+
+func TestUserExport(t *testing.T) {
+	router, database := newTestUserHandler(t)
+	alex := createTestUser(t, database, "Alex")
+	sam := createTestUser(t, database, "Sam")
+
+	var mine, theirs models.Apartment
+	sendAs(t, router, alex, http.MethodPost, "/api/apartments", `{"address":"1 Main St"}`, &mine)
+	sendAs(t, router, sam, http.MethodPost, "/api/apartments", `{"address":"2 Main St"}`, &theirs)
+	_, err := database.CreateComment(theirs.ID, &models.CommentRequest{Body: "Nice porch", AuthorID: &alex})
+	assert.NoError(t, err)
+	_, err = database.CreateComment(theirs.ID, &models.CommentRequest{Body: "Agreed", AuthorID: &sam})
+	assert.NoError(t, err)
+	_, err = database.AddReaction(theirs.ID, alex, models.ReactionHeart)
+	assert.NoError(t, err)
+
+	var export models.UserExport
+	assert.Equal(t, http.StatusOK, sendAs(t, router, alex, http.MethodGet, userURL(alex, "export"), "", &export))
+	assert.Equal(t, "Alex", export.User.Name)
+	if assert.Len(t, export.Apartments, 1, "only the apartments they added") {
+		assert.Equal(t, mine.ID, export.Apartments[0].ID)
+	}
+	if assert.Len(t, export.Comments, 1, "only the comments they wrote") {
+		assert.Equal(t, "Nice porch", export.Comments[0].Body)
+	}
+	if assert.Len(t, export.Reactions, 1) {
+		assert.Equal(t, models.ReactionHeart, export.Reactions[0].Reaction)
+	}
+}
+
+func TestUserErase(t *testing.T) {
+	router, database := newTestUserHandler(t)
+	alex := createTestUser(t, database, "Alex")
+	sam := createTestUser(t, database, "Sam")
+
+	var private, shared, theirs models.Apartment
+	sendAs(t, router, alex, http.MethodPost, "/api/apartments", `{"address":"1 Secret Ln","visibility":"private"}`, &private)
+	sendAs(t, router, alex, http.MethodPost, "/api/apartments", `{"address":"1 Main St"}`, &shared)
+	sendAs(t, router, sam, http.MethodPost, "/api/apartments", `{"address":"2 Main St"}`, &theirs)
+	_, err := database.CreateComment(theirs.ID, &models.CommentRequest{Body: "Nice porch", AuthorID: &alex})
+	assert.NoError(t, err)
+	_, err = database.CreateComment(theirs.ID, &models.CommentRequest{Body: "Agreed", AuthorID: &sam})
+	assert.NoError(t, err)
+	_, err = database.AddReaction(theirs.ID, alex, models.ReactionHeart)
+	assert.NoError(t, err)
+
+	var erasure models.UserErasure
+	assert.Equal(t, http.StatusOK, sendAs(t, router, alex, http.MethodPost, userURL(alex, "erase"), "", &erasure))
+	assert.Equal(t, models.UserErasure{
+		ApartmentsDeleted:  1,
+		ApartmentsDisowned: 1,
+		CommentsScrubbed:   1,
+		ReactionsRemoved:   1,
+		ActivityRemoved:    erasure.ActivityRemoved,
+	}, erasure)
+
+	user, err := database.GetUser(alex)
+	assert.NoError(t, err)
+	assert.Nil(t, user)
+
+	gone, err := database.GetApartment(private.ID)
+	assert.NoError(t, err)
+	assert.Nil(t, gone, "private apartments are removed")
+
+	var kept models.Apartment
+	assert.Equal(t, http.StatusOK, sendAs(t, router, sam, http.MethodGet, "/api/apartments/"+strconv.FormatInt(shared.ID, 10), "", &kept))
+	assert.Nil(t, kept.OwnerID, "household apartments are kept without an owner")
+
+	comments, err := database.ListComments(theirs.ID)
+	assert.NoError(t, err)
+	if assert.Len(t, comments, 2, "the thread keeps its shape") {
+		assert.Equal(t, models.ErasedCommentBody, comments[0].Body)
+		assert.Equal(t, "Agreed", comments[1].Body, "others' comments are untouched")
+	}
+}
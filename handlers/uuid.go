@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mojotx/apt-eval/db"
+	"github.com/rs/zerolog/log"
+)
+
+// uuidPattern matches a UUID in its canonical form
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// ApartmentUUIDs lets apartment routes take the apartment's UUID wherever
+// they take its ID: a UUID in the :id of an /api/apartments route is
+// swapped for the ID it stands for before the handler sees it, and one
+// that isn't an apartment's is answered with 404
+func ApartmentUUIDs(database *db.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !strings.HasPrefix(c.FullPath(), "/api/apartments/:id") {
+			c.Next()
+			return
+		}
+		for i, p := range c.Params {
+			if p.Key != "id" || !uuidPattern.MatchString(p.Value) {
+				continue
+			}
+			id, err := database.ApartmentIDByUUID(strings.ToLower(p.Value))
+			if errors.Is(err, db.ErrNotFound) {
+				c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "Apartment not found"})
+				return
+			}
+			if err != nil {
+				log.Error().Err(err).Str("uuid", p.Value).Msg("Failed to look up apartment")
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to get apartment"})
+				return
+			}
+			c.Params[i].Value = strconv.FormatInt(id, 10)
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mojotx/apt-eval/db"
+	"github.com/mojotx/apt-eval/models"
+	"github.com/rs/zerolog/log"
+)
+
+// viewerKey is the context key holding the ID of the household member
+// making the request
+const viewerKey = "viewer_id"
+
+// Viewer identifies the household member making each request from the
+// X-User-ID header, so private apartments are only shown to their owner.
+// Requests without the header see everything but private apartments; an
+// ID that isn't a user is rejected. The header is ignored when a client
+// certificate, directory or single sign-on already identified the viewer.
+func Viewer(database *db.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// What's listed depends on who's asking
+		c.Writer.Header().Add("Vary", "X-User-ID")
+		if _, ok := c.Get(viewerKey); ok {
+			c.Next()
+			return
+		}
+
+		s := c.GetHeader("X-User-ID")
+		if s == "" {
+			c.Next()
+			return
+		}
+
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || id < 1 {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid X-User-ID"})
+			return
+		}
+		user, err := database.GetUser(id)
+		if err != nil {
+			log.Error().Err(err).Int64("id", id).Msg("Failed to get user")
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user"})
+			return
+		}
+		if user == nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "X-User-ID is not a user"})
+			return
+		}
+
+		c.Set(viewerKey, id)
+		c.Next()
+	}
+}
+
+// viewerID returns the household member making the request, or 0 when
+// they haven't said
+func viewerID(c *gin.Context) int64 {
+	return c.GetInt64(viewerKey)
+}
+
+// checkVisible responds with 404 when an apartment exists but is private
+// to someone other than the viewer, reporting whether the request may go
+// on. Apartments that don't exist are left for the caller to report.
+func checkVisible(c *gin.Context, database *db.DB, id int64) bool {
+	apartment, err := database.GetApartment(id)
+	if err != nil {
+		log.Error().Err(err).Int64("id", id).Msg("Failed to get apartment")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get apartment"})
+		return false
+	}
+	if apartment != nil && !apartment.VisibleTo(viewerID(c)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Apartment not found"})
+		return false
+	}
+	return true
+}
+
+// checkVisibilityRequest responds with an error when a request would make
+// an apartment private for no one, or private to someone else, reporting
+// whether it may go on. existing is nil when creating.
+func checkVisibilityRequest(c *gin.Context, request *models.ApartmentRequest, existing *models.Apartment) bool {
+	if status, msg := visibilityProblem(viewerID(c), request, existing); status != 0 {
+		c.JSON(status, gin.H{"error": msg})
+		return false
+	}
+	return true
+}
+
+// visibilityProblem makes the viewer the owner of what a request saves,
+// and returns the status and message of the error to respond with when it
+// would make an apartment private for no one, or private to someone else.
+// The status is 0 when the request may go on.
+func visibilityProblem(viewer int64, request *models.ApartmentRequest, existing *models.Apartment) (int, string) {
+	if viewer != 0 {
+		request.OwnerID = &viewer
+	}
+	if request.Visibility != models.VisibilityPrivate {
+		return 0, ""
+	}
+	if existing != nil && existing.OwnerID != nil && *existing.OwnerID != viewer {
+		return http.StatusForbidden, "Only the apartment's owner can make it private"
+	}
+	if viewer == 0 {
+		return http.StatusBadRequest, "Private apartments need an owner: send X-User-ID"
+	}
+	return 0, ""
+}
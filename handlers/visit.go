@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mojotx/apt-eval/db"
+	"github.com/mojotx/apt-eval/models"
+	"github.com/rs/zerolog/log"
+)
+
+// VisitHandler handles the visits sub-resource of apartments
+type VisitHandler struct {
+	db *db.DB
+}
+
+// NewVisitHandler creates a new visit handler
+func NewVisitHandler(db *db.DB) *VisitHandler {
+	return &VisitHandler{
+		db: db,
+	}
+}
+
+// List handles retrieving an apartment's visits
+func (h *VisitHandler) List(c *gin.Context) {
+	apartmentID, ok := findApartment(c, h.db)
+	if !ok {
+		return
+	}
+
+	visits, err := h.db.ListVisits(apartmentID)
+	if err != nil {
+		log.Error().Err(err).Int64("apartment_id", apartmentID).Msg("Failed to list visits")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list visits"})
+		return
+	}
+	respond(c, http.StatusOK, visits, nil)
+}
+
+// Create handles recording a visit
+func (h *VisitHandler) Create(c *gin.Context) {
+	apartmentID, ok := findApartment(c, h.db)
+	if !ok {
+		return
+	}
+
+	var request models.VisitRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Error().Err(err).Msg("Failed to bind request")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	visit, err := h.db.CreateVisit(apartmentID, &request)
+	if err != nil {
+		log.Error().Err(err).Int64("apartment_id", apartmentID).Msg("Failed to create visit")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create visit"})
+		return
+	}
+	recordActivity(h.db, viewerID(c), models.ActivityVisited, apartmentID, "")
+	c.JSON(http.StatusCreated, visit)
+}
+
+// Update handles modifying a visit
+func (h *VisitHandler) Update(c *gin.Context) {
+	apartmentID, ok := findApartment(c, h.db)
+	if !ok {
+		return
+	}
+	id, ok := parseID(c, "visit_id", "visit")
+	if !ok {
+		return
+	}
+
+	var request models.VisitRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Error().Err(err).Msg("Failed to bind request")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	visit, err := h.db.UpdateVisit(apartmentID, id, &request)
+	if err != nil {
+		log.Error().Err(err).Int64("id", id).Msg("Failed to update visit")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update visit"})
+		return
+	}
+	if visit == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Visit not found"})
+		return
+	}
+	c.JSON(http.StatusOK, visit)
+}
+
+// Delete handles removing a visit
+func (h *VisitHandler) Delete(c *gin.Context) {
+	apartmentID, ok := findApartment(c, h.db)
+	if !ok {
+		return
+	}
+	id, ok := parseID(c, "visit_id", "visit")
+	if !ok {
+		return
+	}
+
+	if err := h.db.DeleteVisit(apartmentID, id); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Visit not found"})
+			return
+		}
+		log.Error().Err(err).Int64("id", id).Msg("Failed to delete visit")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete visit"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success"})
+}
+
+// RegisterRoutes registers all visit routes
+func (h *VisitHandler) RegisterRoutes(router *gin.Engine) {
+	visits := router.Group("/api/apartments/:id/visits")
+	{
+		visits.GET("", h.List)
+		visits.POST("", h.Create)
+		visits.PUT("/:visit_id", h.Update)
+		visits.DELETE("/:visit_id", h.Delete)
+	}
+}
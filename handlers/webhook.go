@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/mojotx/apt-eval/db"
+	"github.com/mojotx/apt-eval/models"
+	"github.com/mojotx/apt-eval/webhook"
+	"github.com/rs/zerolog/log"
+)
+
+// maxWebhookSize caps inbound webhook bodies
+const maxWebhookSize = 1 << 20
+
+// WebhookHandler turns listings, posted as signed webhooks by services
+// such as listing alerts or Zapier, into draft apartments
+type WebhookHandler struct {
+	db      *db.DB
+	sources map[string]*webhook.Source
+}
+
+// NewWebhookHandler creates a new inbound webhook handler for the
+// configured sources
+func NewWebhookHandler(db *db.DB, sources map[string]*webhook.Source) *WebhookHandler {
+	return &WebhookHandler{
+		db:      db,
+		sources: sources,
+	}
+}
+
+// draftFromWebhook builds a draft apartment from a mapped listing, falling
+// back to its URL when no address was mapped
+func draftFromWebhook(source string, listing webhook.Listing) *models.ApartmentRequest {
+	req := &models.ApartmentRequest{
+		Address:   listing.Address,
+		Status:    models.StatusDraft,
+		Price:     listing.Price,
+		Latitude:  listing.Latitude,
+		Longitude: listing.Longitude,
+	}
+	if req.Address == "" {
+		req.Address = listing.URL
+	}
+	if req.Address == "" {
+		req.Address = "Untitled listing"
+	}
+	if listing.Unit != "" {
+		req.Unit = &listing.Unit
+	}
+	if listing.URL != "" {
+		req.ListingURL = &listing.URL
+	}
+	req.Notes = "Captured from the " + source + " webhook"
+	if listing.Notes != "" {
+		req.Notes += "\n\n" + clipNotes(listing.Notes)
+	}
+	return req
+}
+
+// Receive handles a webhook from a configured source, creating a draft
+// apartment from the listing it carries. Requests must be signed with the
+// source's secret.
+func (h *WebhookHandler) Receive(c *gin.Context) {
+	source, ok := h.sources[c.Param("name")]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown webhook"})
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxWebhookSize))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Webhook payload too large"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read webhook payload"})
+		return
+	}
+	if !webhook.Verify(source.Secret, body, c.GetHeader(source.SignatureHeader)) {
+		log.Warn().Str("source", source.Name).Msg("Rejected webhook with an invalid signature")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid webhook signature"})
+		return
+	}
+
+	var payload any
+	if err := json.Unmarshal(body, &payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook payload"})
+		return
+	}
+	listing := source.Map(payload)
+	if listing.Address == "" && listing.URL == "" {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Webhook payload has no address or listing URL"})
+		return
+	}
+
+	req := draftFromWebhook(source.Name, listing)
+	if err := binding.Validator.ValidateStruct(req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	apartment, err := h.db.CreateApartment(req)
+	if err != nil {
+		if respondDuplicateAddress(c, h.db, err) {
+			return
+		}
+		log.Error().Err(err).Msg("Failed to create apartment from webhook")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create apartment"})
+		return
+	}
+
+	log.Info().Int64("id", apartment.ID).Str("source", source.Name).Msg("Captured apartment from webhook")
+	recordActivity(h.db, 0, models.ActivityCaptured, apartment.ID, "")
+	c.JSON(http.StatusCreated, apartment)
+}
+
+// RegisterRoutes registers the inbound webhook route
+func (h *WebhookHandler) RegisterRoutes(router *gin.Engine) {
+	router.POST("/api/inbound/webhooks/:name", h.Receive)
+}
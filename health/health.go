@@ -0,0 +1,83 @@
+// Package health keeps track of how calls to the external services the
+// app is configured with have been going, for the readiness check. Only
+// watched integrations are reported, so those that aren't configured stay
+// out of it.
+package health
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Integration statuses
+const (
+	StatusOK      = "ok"
+	StatusFailing = "failing"
+	StatusUnknown = "unknown" // Not called since startup
+)
+
+// Integration is how calls to one external service have been going
+type Integration struct {
+	Name        string     `json:"name"`
+	Provider    string     `json:"provider"`
+	Status      string     `json:"status"`
+	LastSuccess *time.Time `json:"last_success"`
+	LastFailure *time.Time `json:"last_failure"`
+	// Failures counts the calls that have failed since the last success
+	Failures int `json:"failures"`
+}
+
+var (
+	mu           sync.Mutex
+	integrations = map[string]*Integration{}
+)
+
+// Watch adds an integration to the report under name, as unknown until
+// it's first called. Watching it again changes its provider and keeps its
+// history.
+func Watch(name, provider string) {
+	mu.Lock()
+	defer mu.Unlock()
+	if i, ok := integrations[name]; ok {
+		i.Provider = provider
+		return
+	}
+	integrations[name] = &Integration{Name: name, Provider: provider, Status: StatusUnknown}
+}
+
+// Record notes how a call to the integration name went: a nil err is a
+// success. Calls cut short by their caller say nothing about the service
+// and aren't counted, nor are calls to integrations that aren't watched.
+func Record(name string, err error) {
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	i, ok := integrations[name]
+	if !ok {
+		return
+	}
+	now := time.Now().UTC()
+	if err == nil {
+		i.Status, i.LastSuccess, i.Failures = StatusOK, &now, 0
+	} else {
+		i.Status, i.LastFailure = StatusFailing, &now
+		i.Failures++
+	}
+}
+
+// Report returns the watched integrations by name
+func Report() []Integration {
+	mu.Lock()
+	defer mu.Unlock()
+	report := make([]Integration, 0, len(integrations))
+	for _, i := range integrations {
+		report = append(report, *i)
+	}
+	sort.Slice(report, func(a, b int) bool { return report[a].Name < report[b].Name })
+	return report
+}
@@ -0,0 +1,62 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func find(name string) *Integration {
+	for _, i := range Report() {
+		if i.Name == name {
+			return &i
+		}
+	}
+	return nil
+}
+
+func TestRecord(t *testing.T) {
+	Record("unwatched", nil)
+	assert.Nil(t, find("unwatched"), "only watched integrations are reported")
+
+	Watch("geocoder", "nominatim")
+	i := find("geocoder")
+	assert.Equal(t, StatusUnknown, i.Status)
+	assert.Nil(t, i.LastSuccess)
+
+	Record("geocoder", nil)
+	i = find("geocoder")
+	assert.Equal(t, StatusOK, i.Status)
+	assert.NotNil(t, i.LastSuccess)
+
+	Record("geocoder", context.Canceled)
+	assert.Equal(t, StatusOK, find("geocoder").Status, "cancelled calls aren't counted")
+
+	Record("geocoder", errors.New("connection refused"))
+	Record("geocoder", fmt.Errorf("geocode: %w", errors.New("503 Service Unavailable")))
+	i = find("geocoder")
+	assert.Equal(t, StatusFailing, i.Status)
+	assert.Equal(t, 2, i.Failures)
+	assert.NotNil(t, i.LastSuccess, "the last success is kept")
+	assert.NotNil(t, i.LastFailure)
+
+	Watch("geocoder", "google")
+	Record("geocoder", nil)
+	i = find("geocoder")
+	assert.Equal(t, "google", i.Provider)
+	assert.Equal(t, StatusOK, i.Status)
+	assert.Zero(t, i.Failures)
+}
+
+func TestReportSorted(t *testing.T) {
+	Watch("sms", "twilio")
+	Watch("llm", "ollama")
+	var names []string
+	for _, i := range Report() {
+		names = append(names, i.Name)
+	}
+	assert.IsNonDecreasing(t, names)
+}
@@ -0,0 +1,219 @@
+package ldap
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// BER tag classes and the constructed bit, as they appear in a tag byte
+const (
+	classUniversal   = 0x00
+	classApplication = 0x40
+	classContext     = 0x80
+	constructed      = 0x20
+)
+
+// Universal tags used by LDAP
+const (
+	tagBoolean     = 0x01
+	tagInteger     = 0x02
+	tagOctetString = 0x04
+	tagEnumerated  = 0x0a
+	tagSequence    = 0x30
+	tagSet         = 0x31
+)
+
+// maxPacketSize bounds what's read from the server, so a broken or
+// hostile one can't make us allocate without limit
+const maxPacketSize = 1 << 20
+
+// packet is one BER element. Constructed elements carry their children;
+// primitive ones their raw value.
+type packet struct {
+	tag      byte
+	value    []byte
+	children []*packet
+}
+
+// newPrimitive creates a primitive element holding value
+func newPrimitive(tag byte, value []byte) *packet {
+	return &packet{tag: tag, value: value}
+}
+
+// newConstructed creates a constructed element holding children
+func newConstructed(tag byte, children ...*packet) *packet {
+	return &packet{tag: tag | constructed, children: children}
+}
+
+// newString creates an OCTET STRING
+func newString(s string) *packet {
+	return newPrimitive(tagOctetString, []byte(s))
+}
+
+// newInteger creates an INTEGER, or another integer-valued tag such as
+// ENUMERATED
+func newInteger(tag byte, n int64) *packet {
+	var b []byte
+	for {
+		b = append([]byte{byte(n)}, b...)
+		if (n < 0x80 && n >= -0x80) || len(b) == 8 {
+			break
+		}
+		n >>= 8
+	}
+	return newPrimitive(tag, b)
+}
+
+// newBoolean creates a BOOLEAN
+func newBoolean(v bool) *packet {
+	if v {
+		return newPrimitive(tagBoolean, []byte{0xff})
+	}
+	return newPrimitive(tagBoolean, []byte{0x00})
+}
+
+// isConstructed reports whether the element holds children
+func (p *packet) isConstructed() bool {
+	return p.tag&constructed != 0
+}
+
+// encode returns the element's BER encoding
+func (p *packet) encode() []byte {
+	value := p.value
+	if p.isConstructed() {
+		value = nil
+		for _, child := range p.children {
+			value = append(value, child.encode()...)
+		}
+	}
+	out := append([]byte{p.tag}, encodeLength(len(value))...)
+	return append(out, value...)
+}
+
+// encodeLength returns a BER definite length
+func encodeLength(n int) []byte {
+	if n < 0x80 {
+		return []byte{byte(n)}
+	}
+	var b []byte
+	for ; n > 0; n >>= 8 {
+		b = append([]byte{byte(n)}, b...)
+	}
+	return append([]byte{0x80 | byte(len(b))}, b...)
+}
+
+// int reads the element's value as a signed integer
+func (p *packet) int() (int64, error) {
+	if len(p.value) == 0 || len(p.value) > 8 {
+		return 0, fmt.Errorf("invalid integer of %d bytes", len(p.value))
+	}
+	n := int64(int8(p.value[0]))
+	for _, b := range p.value[1:] {
+		n = n<<8 | int64(b)
+	}
+	return n, nil
+}
+
+// child returns the i'th child, or an error naming what was expected
+func (p *packet) child(i int, what string) (*packet, error) {
+	if i >= len(p.children) {
+		return nil, fmt.Errorf("missing %s", what)
+	}
+	return p.children[i], nil
+}
+
+// readPacket reads one BER element from r
+func readPacket(r *bufio.Reader) (*packet, error) {
+	tag, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	if tag&0x1f == 0x1f {
+		return nil, errors.New("multi-byte BER tags are not supported")
+	}
+	length, err := readLength(r)
+	if err != nil {
+		return nil, err
+	}
+	value := make([]byte, length)
+	if _, err := io.ReadFull(r, value); err != nil {
+		return nil, err
+	}
+	return parsePacket(tag, value)
+}
+
+// parsePacket builds an element from its tag and value, parsing the
+// children of constructed elements
+func parsePacket(tag byte, value []byte) (*packet, error) {
+	p := &packet{tag: tag, value: value}
+	if !p.isConstructed() {
+		return p, nil
+	}
+	for len(value) > 0 {
+		if len(value) < 2 {
+			return nil, errors.New("truncated BER element")
+		}
+		childTag := value[0]
+		length, n, err := decodeLength(value[1:])
+		if err != nil {
+			return nil, err
+		}
+		start := 1 + n
+		if length > len(value)-start {
+			return nil, errors.New("truncated BER element")
+		}
+		child, err := parsePacket(childTag, value[start:start+length])
+		if err != nil {
+			return nil, err
+		}
+		p.children = append(p.children, child)
+		value = value[start+length:]
+	}
+	return p, nil
+}
+
+// readLength reads a BER definite length from r
+func readLength(r *bufio.Reader) (int, error) {
+	first, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	if first < 0x80 {
+		return int(first), nil
+	}
+	n := int(first & 0x7f)
+	if n == 0 || n > 4 {
+		return 0, fmt.Errorf("unsupported BER length of %d bytes", n)
+	}
+	length := 0
+	for range n {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		length = length<<8 | int(b)
+	}
+	if length > maxPacketSize {
+		return 0, fmt.Errorf("BER element of %d bytes is too large", length)
+	}
+	return length, nil
+}
+
+// decodeLength decodes a BER definite length at the start of b, returning
+// it and the number of bytes it took
+func decodeLength(b []byte) (int, int, error) {
+	if b[0] < 0x80 {
+		return int(b[0]), 1, nil
+	}
+	n := int(b[0] & 0x7f)
+	if n == 0 || n > 4 || len(b) < 1+n {
+		return 0, 0, errors.New("invalid BER length")
+	}
+	length := 0
+	for _, c := range b[1 : 1+n] {
+		length = length<<8 | int(c)
+	}
+	return length, 1 + n, nil
+}
@@ -0,0 +1,195 @@
+package ldap
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Filter choices, as context-specific tags (RFC 4511 section 4.5.1)
+const (
+	filterAnd            = classContext | constructed | 0
+	filterOr             = classContext | constructed | 1
+	filterNot            = classContext | constructed | 2
+	filterEqualityMatch  = classContext | constructed | 3
+	filterSubstrings     = classContext | constructed | 4
+	filterGreaterOrEqual = classContext | constructed | 5
+	filterLessOrEqual    = classContext | constructed | 6
+	filterPresent        = classContext | 7
+	filterApproxMatch    = classContext | constructed | 8
+)
+
+// Substring choices
+const (
+	substringInitial = classContext | 0
+	substringAny     = classContext | 1
+	substringFinal   = classContext | 2
+)
+
+// EscapeFilter escapes s for use as a value in a search filter, so user
+// input can't change the filter's meaning (RFC 4515 section 3)
+func EscapeFilter(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '*', '(', ')', '\\', 0:
+			fmt.Fprintf(&b, `\%02x`, c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// compileFilter turns a search filter in its string form, such as
+// "(&(objectClass=person)(uid=jo))", into its BER encoding
+func compileFilter(s string) (*packet, error) {
+	f, rest, err := parseFilter(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter %q: %w", s, err)
+	}
+	if rest != "" {
+		return nil, fmt.Errorf("invalid filter %q: unexpected %q after the filter", s, rest)
+	}
+	return f, nil
+}
+
+// parseFilter parses the parenthesized filter at the start of s, returning
+// it and what follows
+func parseFilter(s string) (*packet, string, error) {
+	if !strings.HasPrefix(s, "(") {
+		return nil, "", fmt.Errorf("expected ( at %q", s)
+	}
+	s = s[1:]
+	if s == "" {
+		return nil, "", fmt.Errorf("unterminated filter")
+	}
+
+	switch s[0] {
+	case '&', '|':
+		tag := byte(filterAnd)
+		if s[0] == '|' {
+			tag = filterOr
+		}
+		list := newConstructed(tag)
+		s = s[1:]
+		for strings.HasPrefix(s, "(") {
+			var f *packet
+			var err error
+			if f, s, err = parseFilter(s); err != nil {
+				return nil, "", err
+			}
+			list.children = append(list.children, f)
+		}
+		if !strings.HasPrefix(s, ")") {
+			return nil, "", fmt.Errorf("expected ) at %q", s)
+		}
+		return list, s[1:], nil
+	case '!':
+		f, rest, err := parseFilter(s[1:])
+		if err != nil {
+			return nil, "", err
+		}
+		if !strings.HasPrefix(rest, ")") {
+			return nil, "", fmt.Errorf("expected ) at %q", rest)
+		}
+		return newConstructed(filterNot, f), rest[1:], nil
+	}
+
+	// Values can't hold an unescaped ), so the first one ends the item
+	end := strings.IndexByte(s, ')')
+	if end < 0 {
+		return nil, "", fmt.Errorf("unterminated filter")
+	}
+	f, err := parseItem(s[:end])
+	if err != nil {
+		return nil, "", err
+	}
+	return f, s[end+1:], nil
+}
+
+// parseItem parses a single comparison, such as "uid=jo" or "cn=J*n"
+func parseItem(s string) (*packet, error) {
+	eq := strings.IndexByte(s, '=')
+	if eq <= 0 {
+		return nil, fmt.Errorf("expected attribute=value at %q", s)
+	}
+	attr, value := s[:eq], s[eq+1:]
+
+	tag := byte(filterEqualityMatch)
+	switch attr[len(attr)-1] {
+	case '~':
+		tag = filterApproxMatch
+	case '>':
+		tag = filterGreaterOrEqual
+	case '<':
+		tag = filterLessOrEqual
+	}
+	if tag != filterEqualityMatch {
+		attr = attr[:len(attr)-1]
+	}
+	if attr == "" {
+		return nil, fmt.Errorf("missing attribute at %q", s)
+	}
+
+	if tag == filterEqualityMatch && value == "*" {
+		return newPrimitive(filterPresent, []byte(attr)), nil
+	}
+	if tag == filterEqualityMatch && strings.Contains(value, "*") {
+		return parseSubstrings(attr, value)
+	}
+
+	v, err := unescapeFilter(value)
+	if err != nil {
+		return nil, err
+	}
+	return newConstructed(tag, newString(attr), newString(v)), nil
+}
+
+// parseSubstrings parses a value with wildcards, such as "J*n*"
+func parseSubstrings(attr, value string) (*packet, error) {
+	parts := strings.Split(value, "*")
+	subs := newConstructed(tagSequence)
+	for i, part := range parts {
+		if part == "" {
+			continue
+		}
+		v, err := unescapeFilter(part)
+		if err != nil {
+			return nil, err
+		}
+		tag := byte(substringAny)
+		switch i {
+		case 0:
+			tag = substringInitial
+		case len(parts) - 1:
+			tag = substringFinal
+		}
+		subs.children = append(subs.children, newPrimitive(tag, []byte(v)))
+	}
+	return newConstructed(filterSubstrings, newString(attr), subs), nil
+}
+
+// unescapeFilter decodes the \XX escapes in a filter value
+func unescapeFilter(s string) (string, error) {
+	if !strings.Contains(s, `\`) {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i+3 > len(s) {
+			return "", fmt.Errorf("truncated escape in %q", s)
+		}
+		c, err := hex.DecodeString(s[i+1 : i+3])
+		if err != nil {
+			return "", fmt.Errorf("invalid escape in %q", s)
+		}
+		b.WriteByte(c[0])
+		i += 2
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,487 @@
+// Package ldap checks user names and passwords against a directory server,
+// so a household or lab that already keeps its accounts in LDAP can sign
+// in with them. It speaks just enough LDAPv3 for that: simple binds and
+// subtree searches, over ldap:// or ldaps://.
+package ldap
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Roles a directory group can map to
+const (
+	RoleAdmin  = "admin"
+	RoleMember = "member"
+)
+
+var (
+	// ErrInvalidCredentials is returned when the user name or password is
+	// wrong, or the user isn't in the directory
+	ErrInvalidCredentials = errors.New("invalid credentials")
+
+	// ErrNoRole is returned when the user signed in but isn't in any group
+	// that maps to a role
+	ErrNoRole = errors.New("user has no role")
+)
+
+// timeout bounds each sign-in, from dialing to the last response
+const timeout = 10 * time.Second
+
+// LDAP result codes (RFC 4511 appendix A)
+const (
+	resultSuccess            = 0
+	resultSizeLimitExceeded  = 4
+	resultInvalidCredentials = 49
+)
+
+// Protocol operations, as application tags
+const (
+	opBindRequest     = classApplication | constructed | 0
+	opBindResponse    = classApplication | constructed | 1
+	opUnbindRequest   = classApplication | 2
+	opSearchRequest   = classApplication | constructed | 3
+	opSearchEntry     = classApplication | constructed | 4
+	opSearchDone      = classApplication | constructed | 5
+	opSearchReference = classApplication | constructed | 19
+)
+
+// Error is a failed result from the directory server
+type Error struct {
+	Code    int64
+	Message string
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("LDAP result code %d", e.Code)
+	}
+	return fmt.Sprintf("LDAP result code %d: %s", e.Code, e.Message)
+}
+
+// Entry is an entry found by a search. Attribute names are lowercased.
+type Entry struct {
+	DN         string
+	Attributes map[string][]string
+}
+
+// Get returns the values of the named attribute
+func (e *Entry) Get(name string) []string {
+	return e.Attributes[strings.ToLower(name)]
+}
+
+// Identity is a user who signed in through the directory
+type Identity struct {
+	Username string
+	DN       string
+	Roles    []string
+}
+
+// HasRole reports whether the user has the given role
+func (id *Identity) HasRole(role string) bool {
+	return slices.Contains(id.Roles, role)
+}
+
+// Authenticator signs users in against a directory. A service account
+// (BindDN) finds the user's entry with UserFilter, in which %s stands for
+// the escaped user name; binding as that entry with the user's password
+// proves it. The groups listed in the entry's GroupAttribute give its
+// roles through GroupRoles, keyed by group DN. With no GroupRoles, every
+// user who signs in is a member.
+type Authenticator struct {
+	URL            string
+	BindDN         string
+	BindPassword   string
+	BaseDN         string
+	UserFilter     string
+	GroupAttribute string
+	GroupRoles     map[string]string
+	TLSConfig      *tls.Config
+
+	// CacheTTL is how long a successful sign-in is remembered, sparing the
+	// directory a round of binds on every request. Zero disables it.
+	CacheTTL time.Duration
+
+	mu    sync.Mutex
+	cache map[[sha256.Size]byte]cachedIdentity
+}
+
+// cachedIdentity is a remembered sign-in
+type cachedIdentity struct {
+	identity *Identity
+	expires  time.Time
+}
+
+// NewAuthenticator creates an authenticator for the directory at rawURL,
+// finding users under baseDN with the usual defaults
+func NewAuthenticator(rawURL, baseDN string) *Authenticator {
+	return &Authenticator{
+		URL:            rawURL,
+		BaseDN:         baseDN,
+		UserFilter:     "(uid=%s)",
+		GroupAttribute: "memberOf",
+		CacheTTL:       5 * time.Minute,
+	}
+}
+
+// ParseGroupRoles parses a group-to-role mapping such as
+// "cn=parents,ou=groups,dc=home:admin;cn=kids,ou=groups,dc=home:member".
+// Group DNs are compared without regard to case.
+func ParseGroupRoles(s string) (map[string]string, error) {
+	roles := make(map[string]string)
+	for entry := range strings.SplitSeq(s, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		i := strings.LastIndexByte(entry, ':')
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid group mapping %q, want group-dn:role", entry)
+		}
+		role := strings.TrimSpace(entry[i+1:])
+		if role != RoleAdmin && role != RoleMember {
+			return nil, fmt.Errorf("invalid role %q for group %q", role, entry[:i])
+		}
+		roles[normalizeDN(entry[:i])] = role
+	}
+	return roles, nil
+}
+
+// normalizeDN puts a DN in a form that compares equal to others naming the
+// same entry, in the common cases of differing case and spacing
+func normalizeDN(dn string) string {
+	parts := strings.Split(dn, ",")
+	for i, part := range parts {
+		parts[i] = strings.ToLower(strings.TrimSpace(part))
+	}
+	return strings.Join(parts, ",")
+}
+
+// Authenticate checks the user name and password against the directory
+func (a *Authenticator) Authenticate(ctx context.Context, username, password string) (*Identity, error) {
+	// An empty password would make an unauthenticated bind, which succeeds
+	if username == "" || password == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	key := sha256.Sum256([]byte(username + "\x00" + password))
+	if id := a.cached(key); id != nil {
+		return id, nil
+	}
+
+	conn, err := dial(ctx, a.URL, a.TLSConfig)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.close()
+
+	if a.BindDN != "" {
+		if err := conn.bind(a.BindDN, a.BindPassword); err != nil {
+			// That's the app's misconfiguration, not the user's wrong password
+			if errors.Is(err, ErrInvalidCredentials) {
+				err = errors.New("service account credentials refused")
+			}
+			return nil, fmt.Errorf("failed to bind as %s: %w", a.BindDN, err)
+		}
+	}
+
+	filter := strings.ReplaceAll(a.UserFilter, "%s", EscapeFilter(username))
+	entries, err := conn.search(a.BaseDN, filter, []string{a.GroupAttribute}, 2)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search for user: %w", err)
+	}
+	switch len(entries) {
+	case 0:
+		return nil, ErrInvalidCredentials
+	case 1:
+	default:
+		return nil, fmt.Errorf("more than one directory entry matches user %q", username)
+	}
+	entry := entries[0]
+
+	if err := conn.bind(entry.DN, password); err != nil {
+		return nil, err
+	}
+
+	id := &Identity{Username: username, DN: entry.DN, Roles: a.roles(entry)}
+	if len(id.Roles) == 0 {
+		return nil, ErrNoRole
+	}
+	a.remember(key, id)
+	return id, nil
+}
+
+// roles returns the roles the entry's groups map to
+func (a *Authenticator) roles(entry *Entry) []string {
+	if len(a.GroupRoles) == 0 {
+		return []string{RoleMember}
+	}
+	var roles []string
+	for _, group := range entry.Get(a.GroupAttribute) {
+		role, ok := a.GroupRoles[normalizeDN(group)]
+		if ok && !slices.Contains(roles, role) {
+			roles = append(roles, role)
+		}
+	}
+	slices.Sort(roles)
+	return roles
+}
+
+// cached returns the remembered sign-in for key, if it hasn't expired
+func (a *Authenticator) cached(key [sha256.Size]byte) *Identity {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	c, ok := a.cache[key]
+	if !ok || a.CacheTTL <= 0 || time.Now().After(c.expires) {
+		return nil
+	}
+	return c.identity
+}
+
+// remember keeps a successful sign-in for CacheTTL, dropping expired ones
+func (a *Authenticator) remember(key [sha256.Size]byte, id *Identity) {
+	if a.CacheTTL <= 0 {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	now := time.Now()
+	if a.cache == nil {
+		a.cache = make(map[[sha256.Size]byte]cachedIdentity)
+	}
+	for k, c := range a.cache {
+		if now.After(c.expires) {
+			delete(a.cache, k)
+		}
+	}
+	a.cache[key] = cachedIdentity{identity: id, expires: now.Add(a.CacheTTL)}
+}
+
+// conn is a connection to a directory server, used for one request at a
+// time
+type conn struct {
+	c     net.Conn
+	r     *bufio.Reader
+	msgID int64
+}
+
+// dial connects to the directory server at rawURL
+func dial(ctx context.Context, rawURL string, tlsConfig *tls.Config) (*conn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid LDAP URL: %w", err)
+	}
+	host, port := u.Hostname(), u.Port()
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	dialer := &net.Dialer{}
+	var c net.Conn
+	switch u.Scheme {
+	case "ldap":
+		if port == "" {
+			port = "389"
+		}
+		c, err = dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, port))
+	case "ldaps":
+		if port == "" {
+			port = "636"
+		}
+		config := &tls.Config{}
+		if tlsConfig != nil {
+			config = tlsConfig.Clone()
+		}
+		if config.ServerName == "" {
+			config.ServerName = host
+		}
+		tlsDialer := &tls.Dialer{NetDialer: dialer, Config: config}
+		c, err = tlsDialer.DialContext(ctx, "tcp", net.JoinHostPort(host, port))
+	default:
+		return nil, fmt.Errorf("unsupported LDAP URL scheme %q", u.Scheme)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to directory: %w", err)
+	}
+
+	deadline, _ := ctx.Deadline()
+	if err := c.SetDeadline(deadline); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("failed to set deadline: %w", err)
+	}
+	return &conn{c: c, r: bufio.NewReader(c)}, nil
+}
+
+// send writes a request with the next message ID
+func (c *conn) send(op *packet) error {
+	c.msgID++
+	msg := newConstructed(tagSequence, newInteger(tagInteger, c.msgID), op)
+	if _, err := c.c.Write(msg.encode()); err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	return nil
+}
+
+// receive reads the next response to the last request
+func (c *conn) receive() (*packet, error) {
+	for {
+		msg, err := readPacket(c.r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read response: %w", err)
+		}
+		if msg.tag != tagSequence|constructed || len(msg.children) < 2 {
+			return nil, errors.New("malformed response")
+		}
+		id, err := msg.children[0].int()
+		if err != nil {
+			return nil, fmt.Errorf("malformed response: %w", err)
+		}
+		// Message ID 0 is an unsolicited notice, such as the server
+		// disconnecting; anything else stale is skipped
+		if id == 0 {
+			return nil, resultError(msg.children[1])
+		}
+		if id == c.msgID {
+			return msg.children[1], nil
+		}
+	}
+}
+
+// bind authenticates the connection as dn
+func (c *conn) bind(dn, password string) error {
+	err := c.send(newConstructed(opBindRequest,
+		newInteger(tagInteger, 3),
+		newString(dn),
+		newPrimitive(classContext|0, []byte(password)),
+	))
+	if err != nil {
+		return err
+	}
+	op, err := c.receive()
+	if err != nil {
+		return err
+	}
+	if op.tag != opBindResponse {
+		return fmt.Errorf("unexpected response %#x to bind", op.tag)
+	}
+	return resultError(op)
+}
+
+// search finds the entries under base matching filter, with the given
+// attributes, returning at most limit of them
+func (c *conn) search(base, filter string, attrs []string, limit int64) ([]*Entry, error) {
+	f, err := compileFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	attrList := newConstructed(tagSequence)
+	for _, attr := range attrs {
+		attrList.children = append(attrList.children, newString(attr))
+	}
+	err = c.send(newConstructed(opSearchRequest,
+		newString(base),
+		newInteger(tagEnumerated, 2), // whole subtree
+		newInteger(tagEnumerated, 0), // never dereference aliases
+		newInteger(tagInteger, limit),
+		newInteger(tagInteger, int64(timeout/time.Second)),
+		newBoolean(false),
+		f,
+		attrList,
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*Entry
+	for {
+		op, err := c.receive()
+		if err != nil {
+			return nil, err
+		}
+		switch op.tag {
+		case opSearchEntry:
+			entry, err := parseEntry(op)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, entry)
+		case opSearchReference:
+			// Referrals to other servers aren't followed
+		case opSearchDone:
+			err := resultError(op)
+			var ldapErr *Error
+			if errors.As(err, &ldapErr) && ldapErr.Code == resultSizeLimitExceeded {
+				err = nil
+			}
+			return entries, err
+		default:
+			return nil, fmt.Errorf("unexpected response %#x to search", op.tag)
+		}
+	}
+}
+
+// close says goodbye to the server and closes the connection
+func (c *conn) close() {
+	_ = c.send(newPrimitive(opUnbindRequest, nil))
+	c.c.Close()
+}
+
+// parseEntry reads a search result entry
+func parseEntry(op *packet) (*Entry, error) {
+	dn, err := op.child(0, "entry DN")
+	if err != nil {
+		return nil, err
+	}
+	attrs, err := op.child(1, "entry attributes")
+	if err != nil {
+		return nil, err
+	}
+	entry := &Entry{DN: string(dn.value), Attributes: make(map[string][]string)}
+	for _, attr := range attrs.children {
+		name, err := attr.child(0, "attribute name")
+		if err != nil {
+			return nil, err
+		}
+		vals, err := attr.child(1, "attribute values")
+		if err != nil {
+			return nil, err
+		}
+		key := strings.ToLower(string(name.value))
+		for _, v := range vals.children {
+			entry.Attributes[key] = append(entry.Attributes[key], string(v.value))
+		}
+	}
+	return entry, nil
+}
+
+// resultError reads an LDAPResult, returning nil for success
+func resultError(op *packet) error {
+	codePacket, err := op.child(0, "result code")
+	if err != nil {
+		return err
+	}
+	code, err := codePacket.int()
+	if err != nil {
+		return fmt.Errorf("malformed result code: %w", err)
+	}
+	switch code {
+	case resultSuccess:
+		return nil
+	case resultInvalidCredentials:
+		return ErrInvalidCredentials
+	}
+	ldapErr := &Error{Code: code}
+	if msg, err := op.child(2, "diagnostic message"); err == nil {
+		ldapErr.Message = string(msg.value)
+	}
+	return ldapErr
+}
@@ -0,0 +1,205 @@
+package ldap
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEscapeFilter(t *testing.T) {
+	assert.Equal(t, "jo", EscapeFilter("jo"))
+	assert.Equal(t, `\2a\29\28uid=\5c`, EscapeFilter(`*)(uid=\`))
+	assert.Equal(t, `a\2a\28b\29\00`, EscapeFilter("a*(b)\x00"))
+}
+
+func TestCompileFilter(t *testing.T) {
+	f, err := compileFilter("(&(objectClass=person)(!(uid=a\\2ab))(cn=J*n*e)(mail=*)(age>=18))")
+	require.NoError(t, err)
+	require.Equal(t, byte(filterAnd), f.tag)
+	require.Len(t, f.children, 5)
+
+	eq := f.children[0]
+	assert.Equal(t, byte(filterEqualityMatch), eq.tag)
+	assert.Equal(t, "objectClass", string(eq.children[0].value))
+	assert.Equal(t, "person", string(eq.children[1].value))
+
+	not := f.children[1]
+	assert.Equal(t, byte(filterNot), not.tag)
+	assert.Equal(t, "a*b", string(not.children[0].children[1].value))
+
+	sub := f.children[2]
+	assert.Equal(t, byte(filterSubstrings), sub.tag)
+	parts := sub.children[1].children
+	require.Len(t, parts, 3)
+	assert.Equal(t, byte(substringInitial), parts[0].tag)
+	assert.Equal(t, "J", string(parts[0].value))
+	assert.Equal(t, byte(substringAny), parts[1].tag)
+	assert.Equal(t, "n", string(parts[1].value))
+	assert.Equal(t, byte(substringFinal), parts[2].tag)
+	assert.Equal(t, "e", string(parts[2].value))
+
+	assert.Equal(t, byte(filterPresent), f.children[3].tag)
+	assert.Equal(t, "mail", string(f.children[3].value))
+	assert.Equal(t, byte(filterGreaterOrEqual), f.children[4].tag)
+
+	// The encoding survives a round trip
+	parsed, err := parsePacket(f.encode()[0], f.encode()[2:])
+	require.NoError(t, err)
+	assert.Equal(t, f.encode(), parsed.encode())
+
+	for _, bad := range []string{"", "uid=jo", "(uid=jo", "(=jo)", "(uid=jo))", "(&(uid=jo)", `(uid=\4)`} {
+		_, err := compileFilter(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestInteger(t *testing.T) {
+	for _, n := range []int64{0, 1, 127, 128, 255, 256, -1, -128, -129, 1 << 40} {
+		got, err := newInteger(tagInteger, n).int()
+		require.NoError(t, err)
+		assert.Equal(t, n, got)
+	}
+	assert.Equal(t, []byte{0x00, 0x80}, newInteger(tagInteger, 128).value)
+}
+
+func TestParseGroupRoles(t *testing.T) {
+	roles, err := ParseGroupRoles("CN=Parents, OU=Groups,DC=home:admin; cn=kids,ou=groups,dc=home:member")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"cn=parents,ou=groups,dc=home": RoleAdmin,
+		"cn=kids,ou=groups,dc=home":    RoleMember,
+	}, roles)
+
+	_, err = ParseGroupRoles("cn=kids,dc=home")
+	assert.Error(t, err)
+	_, err = ParseGroupRoles("cn=kids,dc=home:owner")
+	assert.Error(t, err)
+}
+
+// fakeDirectory serves a directory with a service account and two users
+func fakeDirectory(t *testing.T) (string, *atomic.Int64) {
+	passwords := map[string]string{
+		"cn=svc,dc=home":            "svcpass",
+		"uid=ann,ou=people,dc=home": "annpass",
+		"uid=bob,ou=people,dc=home": "bobpass",
+	}
+	groups := map[string][]string{
+		"uid=ann,ou=people,dc=home": {"cn=parents,ou=groups,dc=home", "cn=everyone,ou=groups,dc=home"},
+		"uid=bob,ou=people,dc=home": {"cn=everyone,ou=groups,dc=home"},
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+
+	binds := &atomic.Int64{}
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				r := bufio.NewReader(c)
+				bound := ""
+				for {
+					msg, err := readPacket(r)
+					if err != nil {
+						return
+					}
+					id := msg.children[0]
+					op := msg.children[1]
+					reply := func(resp *packet) {
+						c.Write(newConstructed(tagSequence, id, resp).encode())
+					}
+					result := func(tag byte, code int64) *packet {
+						return newConstructed(tag&^constructed, newInteger(tagEnumerated, code), newString(""), newString(""))
+					}
+
+					switch op.tag {
+					case opBindRequest:
+						binds.Add(1)
+						dn, pw := string(op.children[1].value), string(op.children[2].value)
+						if want, ok := passwords[dn]; ok && want == pw {
+							bound = dn
+							reply(result(opBindResponse, resultSuccess))
+						} else {
+							reply(result(opBindResponse, resultInvalidCredentials))
+						}
+					case opSearchRequest:
+						if bound != "cn=svc,dc=home" {
+							reply(result(opSearchDone, 50))
+							continue
+						}
+						// Only (uid=name) filters are understood
+						f := op.children[6]
+						uid := string(f.children[1].value)
+						dn := "uid=" + uid + ",ou=people,dc=home"
+						if _, ok := passwords[dn]; ok {
+							vals := newConstructed(tagSet)
+							for _, g := range groups[dn] {
+								vals.children = append(vals.children, newString(g))
+							}
+							attrs := newConstructed(tagSequence, newConstructed(tagSequence, newString("memberOf"), vals))
+							reply(newConstructed(opSearchEntry, newString(dn), attrs))
+						}
+						reply(result(opSearchDone, resultSuccess))
+					case opUnbindRequest:
+						return
+					}
+				}
+			}()
+		}
+	}()
+	return "ldap://" + l.Addr().String(), binds
+}
+
+func TestAuthenticate(t *testing.T) {
+	url, binds := fakeDirectory(t)
+	a := NewAuthenticator(url, "ou=people,dc=home")
+	a.BindDN, a.BindPassword = "cn=svc,dc=home", "svcpass"
+	ctx := context.Background()
+
+	id, err := a.Authenticate(ctx, "ann", "annpass")
+	require.NoError(t, err)
+	assert.Equal(t, "uid=ann,ou=people,dc=home", id.DN)
+	assert.Equal(t, []string{RoleMember}, id.Roles, "everyone is a member without a mapping")
+
+	_, err = a.Authenticate(ctx, "ann", "wrong")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	_, err = a.Authenticate(ctx, "nobody", "annpass")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	_, err = a.Authenticate(ctx, "ann", "")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	a.GroupRoles, err = ParseGroupRoles("cn=parents,ou=groups,dc=home:admin")
+	require.NoError(t, err)
+	a.CacheTTL = 0
+	id, err = a.Authenticate(ctx, "ann", "annpass")
+	require.NoError(t, err)
+	assert.True(t, id.HasRole(RoleAdmin))
+	_, err = a.Authenticate(ctx, "bob", "bobpass")
+	assert.ErrorIs(t, err, ErrNoRole)
+
+	// A wrong service password is an error of its own, not the user's
+	a.BindPassword = "wrong"
+	_, err = a.Authenticate(ctx, "ann", "annpass")
+	assert.NotErrorIs(t, err, ErrInvalidCredentials)
+	assert.Contains(t, err.Error(), "failed to bind as cn=svc")
+
+	// Successful sign-ins are remembered
+	a.BindPassword, a.CacheTTL = "svcpass", time.Minute
+	_, err = a.Authenticate(ctx, "ann", "annpass")
+	require.NoError(t, err)
+	before := binds.Load()
+	_, err = a.Authenticate(ctx, "ann", "annpass")
+	require.NoError(t, err)
+	assert.Equal(t, before, binds.Load())
+}
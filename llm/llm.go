@@ -0,0 +1,226 @@
+// Package llm produces short pros and cons summaries of apartments with a
+// large language model, reached through an OpenAI-compatible chat API or a
+// local Ollama server. Everything sent to the model comes from the notes
+// the user wrote, so nothing is shared unless a provider is configured.
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mojotx/apt-eval/health"
+)
+
+// Provider completes a chat prompt
+type Provider interface {
+	Name() string
+	Model() string
+	Complete(ctx context.Context, system, prompt string) (string, error)
+}
+
+// Config holds the connection settings of a provider
+type Config struct {
+	BaseURL string // Defaults to the provider's public or local address
+	APIKey  string
+	Model   string
+}
+
+// NewProvider returns the provider selected by name: "openai" for OpenAI
+// or any server implementing its chat completions API, "ollama" for a
+// local Ollama server, or "" / "none" for no provider
+func NewProvider(name string, config Config) (Provider, error) {
+	switch strings.ToLower(name) {
+	case "", "none":
+		return nil, nil
+	case "openai":
+		if config.BaseURL == "" {
+			if config.APIKey == "" {
+				return nil, fmt.Errorf("the openai provider requires an API key")
+			}
+			config.BaseURL = "https://api.openai.com/v1"
+		}
+		if config.Model == "" {
+			config.Model = "gpt-4o-mini"
+		}
+		return &OpenAI{Config: config}, nil
+	case "ollama":
+		if config.BaseURL == "" {
+			config.BaseURL = "http://localhost:11434"
+		}
+		if config.Model == "" {
+			config.Model = "llama3.2"
+		}
+		return &Ollama{Config: config}, nil
+	}
+	return nil, fmt.Errorf("unknown LLM provider %q", name)
+}
+
+// httpClient is used for calls to providers; local models can be slow
+var httpClient = &http.Client{Timeout: 2 * time.Minute}
+
+// message is a chat message in both APIs
+type message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// postJSON sends body to url and decodes the JSON response into v
+func postJSON(ctx context.Context, url, apiKey string, body, v any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s returned %s: %s", req.URL.Host, resp.Status, bytes.TrimSpace(msg))
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// OpenAI uses the chat completions API of OpenAI or a compatible server
+// (vLLM, LM Studio, llama.cpp, and the like)
+type OpenAI struct {
+	Config Config
+}
+
+// Name implements Provider
+func (p *OpenAI) Name() string {
+	return "openai"
+}
+
+// Model implements Provider
+func (p *OpenAI) Model() string {
+	return p.Config.Model
+}
+
+// Complete implements Provider
+func (p *OpenAI) Complete(ctx context.Context, system, prompt string) (string, error) {
+	var resp struct {
+		Choices []struct {
+			Message message `json:"message"`
+		} `json:"choices"`
+	}
+	err := postJSON(ctx, strings.TrimSuffix(p.Config.BaseURL, "/")+"/chat/completions", p.Config.APIKey, map[string]any{
+		"model":       p.Config.Model,
+		"messages":    []message{{"system", system}, {"user", prompt}},
+		"temperature": 0.3,
+	}, &resp)
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Choices) == 0 {
+		return "", errors.New("model returned no choices")
+	}
+	return resp.Choices[0].Message.Content, nil
+}
+
+// Ollama uses the chat API of an Ollama server
+type Ollama struct {
+	Config Config
+}
+
+// Name implements Provider
+func (p *Ollama) Name() string {
+	return "ollama"
+}
+
+// Model implements Provider
+func (p *Ollama) Model() string {
+	return p.Config.Model
+}
+
+// Complete implements Provider
+func (p *Ollama) Complete(ctx context.Context, system, prompt string) (string, error) {
+	var resp struct {
+		Message message `json:"message"`
+	}
+	err := postJSON(ctx, strings.TrimSuffix(p.Config.BaseURL, "/")+"/api/chat", p.Config.APIKey, map[string]any{
+		"model":    p.Config.Model,
+		"messages": []message{{"system", system}, {"user", prompt}},
+		"stream":   false,
+		"format":   "json",
+		"options":  map[string]any{"temperature": 0.3},
+	}, &resp)
+	if err != nil {
+		return "", err
+	}
+	return resp.Message.Content, nil
+}
+
+// Summary is a short assessment of an apartment
+type Summary struct {
+	Summary string   `json:"summary"`
+	Pros    []string `json:"pros"`
+	Cons    []string `json:"cons"`
+}
+
+// systemPrompt tells the model what to produce
+const systemPrompt = `You help someone choose an apartment to rent. From their notes, write a short, ` +
+	`neutral assessment. Use only what the notes say; don't invent details. Reply with JSON only, ` +
+	`in the form {"summary": "two or three sentences", "pros": ["..."], "cons": ["..."]}, ` +
+	`with at most five short pros and five short cons.`
+
+// Summarize asks the model for a pros and cons summary of an apartment
+// described by facts, a plain-text digest of what's known about it
+func Summarize(ctx context.Context, p Provider, facts string) (*Summary, error) {
+	reply, err := p.Complete(ctx, systemPrompt, facts)
+	health.Record("llm", err)
+	if err != nil {
+		return nil, err
+	}
+	return parseSummary(reply)
+}
+
+// parseSummary reads the JSON object in a model's reply, tolerating the
+// code fences and preambles models add despite being told not to
+func parseSummary(reply string) (*Summary, error) {
+	start, end := strings.Index(reply, "{"), strings.LastIndex(reply, "}")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("model reply has no JSON object: %.100q", reply)
+	}
+
+	var s Summary
+	if err := json.Unmarshal([]byte(reply[start:end+1]), &s); err != nil {
+		return nil, fmt.Errorf("failed to decode model reply: %w", err)
+	}
+	s.Summary = strings.TrimSpace(s.Summary)
+	if s.Summary == "" {
+		return nil, errors.New("model reply has no summary")
+	}
+	s.Pros, s.Cons = cleanList(s.Pros), cleanList(s.Cons)
+	return &s, nil
+}
+
+// cleanList drops blank items, never returning nil
+func cleanList(items []string) []string {
+	out := []string{}
+	for _, item := range items {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
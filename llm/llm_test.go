@@ -0,0 +1,82 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSummary(t *testing.T) {
+	s, err := parseSummary("Here you go:\n```json\n{\"summary\": \" Bright and quiet. \", \"pros\": [\"light\", \" \"], \"cons\": null}\n```")
+	require.NoError(t, err)
+	assert.Equal(t, "Bright and quiet.", s.Summary)
+	assert.Equal(t, []string{"light"}, s.Pros)
+	assert.Equal(t, []string{}, s.Cons)
+
+	_, err = parseSummary("I can't help with that.")
+	assert.Error(t, err)
+	_, err = parseSummary(`{"pros": ["light"]}`)
+	assert.Error(t, err)
+}
+
+func TestProviders(t *testing.T) {
+	reply := `{"summary": "Fine.", "pros": ["cheap"], "cons": ["loud"]}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Model    string    `json:"model"`
+			Messages []message `json:"messages"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "test-model", body.Model)
+		assert.Equal(t, "user", body.Messages[1].Role)
+
+		switch r.URL.Path {
+		case "/v1/chat/completions":
+			assert.Equal(t, "Bearer key", r.Header.Get("Authorization"))
+			json.NewEncoder(w).Encode(map[string]any{"choices": []any{map[string]any{"message": message{"assistant", reply}}}})
+		case "/api/chat":
+			json.NewEncoder(w).Encode(map[string]any{"message": message{"assistant", reply}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	for name, config := range map[string]Config{
+		"openai": {BaseURL: srv.URL + "/v1/", APIKey: "key", Model: "test-model"},
+		"ollama": {BaseURL: srv.URL, Model: "test-model"},
+	} {
+		p, err := NewProvider(name, config)
+		require.NoError(t, err)
+		s, err := Summarize(context.Background(), p, "Price: $1,800/month")
+		if assert.NoError(t, err, name) {
+			assert.Equal(t, &Summary{Summary: "Fine.", Pros: []string{"cheap"}, Cons: []string{"loud"}}, s)
+		}
+	}
+
+	p, err := NewProvider("ollama", Config{BaseURL: srv.URL + "/missing", Model: "test-model"})
+	require.NoError(t, err)
+	_, err = Summarize(context.Background(), p, "")
+	assert.ErrorContains(t, err, "404")
+}
+
+func TestNewProvider(t *testing.T) {
+	p, err := NewProvider("", Config{})
+	assert.NoError(t, err)
+	assert.Nil(t, p)
+
+	_, err = NewProvider("openai", Config{})
+	assert.Error(t, err)
+
+	p, err = NewProvider("ollama", Config{})
+	require.NoError(t, err)
+	assert.Equal(t, "llama3.2", p.Model())
+
+	_, err = NewProvider("gpt", Config{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,171 @@
+// Package logfile writes logs to a file that's rotated by size, keeping a
+// limited number of old files for a limited time
+package logfile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat names rotated files, sorting in the order they were
+// rotated
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// Options controls when a log file is rotated and how long old ones are
+// kept
+type Options struct {
+	MaxSizeMB  int // Rotate once the file would grow past this; 0 never rotates
+	MaxBackups int // Rotated files kept; 0 keeps them all
+	MaxAgeDays int // Rotated files older than this are removed; 0 keeps them all
+}
+
+// File is a log file, safe for concurrent writes. When a write would take
+// it past its maximum size, it's renamed with the time, as app-<time>.log
+// next to app.log, and a new one is started.
+type File struct {
+	path string
+	opts Options
+	now  func() time.Time
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// Open opens the log file at path for appending, creating it and its
+// directory if needed
+func Open(path string, opts Options) (*File, error) {
+	f := &File{path: path, opts: opts, now: time.Now}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *File) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+	f.file, f.size = file, info.Size()
+	return nil
+}
+
+// Write appends p to the file, rotating it first if p would take it past
+// the maximum size. A single write is never split across files.
+func (f *File) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	maxSize := int64(f.opts.MaxSizeMB) << 20
+	if maxSize > 0 && f.size > 0 && f.size+int64(len(p)) > maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Rotate starts a new file now, whatever the size of the current one
+func (f *File) Rotate() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return os.ErrClosed
+	}
+	return f.rotate()
+}
+
+func (f *File) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return fmt.Errorf("failed to close log file: %w", err)
+	}
+	f.file = nil
+	now := f.now()
+	if err := os.Rename(f.path, f.backupName(now)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+	f.removeOld(now)
+	return nil
+}
+
+// Close closes the file
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+// backupName is the name the file is rotated to at t
+func (f *File) backupName(t time.Time) string {
+	ext := filepath.Ext(f.path)
+	return strings.TrimSuffix(f.path, ext) + "-" + t.UTC().Format(backupTimeFormat) + ext
+}
+
+// removeOld removes the rotated files beyond the number or the age kept.
+// Failing to remove one isn't worth failing a write over, so errors are
+// ignored; the next rotation tries again.
+func (f *File) removeOld(now time.Time) {
+	if f.opts.MaxBackups <= 0 && f.opts.MaxAgeDays <= 0 {
+		return
+	}
+	ext := filepath.Ext(f.path)
+	prefix := filepath.Base(strings.TrimSuffix(f.path, ext)) + "-"
+	entries, err := os.ReadDir(filepath.Dir(f.path))
+	if err != nil {
+		return
+	}
+
+	type backup struct {
+		name string
+		at   time.Time
+	}
+	var backups []backup
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ext) {
+			continue
+		}
+		at, err := time.Parse(backupTimeFormat, strings.TrimSuffix(strings.TrimPrefix(name, prefix), ext))
+		if err != nil {
+			continue
+		}
+		backups = append(backups, backup{name, at})
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].at.After(backups[j].at) })
+
+	cutoff := now.AddDate(0, 0, -f.opts.MaxAgeDays)
+	for i, b := range backups {
+		tooMany := f.opts.MaxBackups > 0 && i >= f.opts.MaxBackups
+		tooOld := f.opts.MaxAgeDays > 0 && b.at.Before(cutoff)
+		if tooMany || tooOld {
+			os.Remove(filepath.Join(filepath.Dir(f.path), b.name))
+		}
+	}
+}
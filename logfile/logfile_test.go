@@ -0,0 +1,74 @@
+package logfile
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRotateBySize(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "apt-eval.log")
+	f, err := Open(path, Options{MaxSizeMB: 1, MaxBackups: 2})
+	assert.NoError(t, err)
+	defer f.Close()
+
+	clock := time.Date(2026, 5, 10, 15, 0, 0, 0, time.UTC)
+	f.now = func() time.Time { clock = clock.Add(time.Minute); return clock }
+
+	line := []byte(strings.Repeat("x", 1023) + "\n")
+	for range 1024 * 4 {
+		_, err := f.Write(line)
+		assert.NoError(t, err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	assert.Equal(t, []string{
+		"apt-eval-2026-05-10T15-02-00.000.log",
+		"apt-eval-2026-05-10T15-03-00.000.log",
+		"apt-eval.log",
+	}, names, "only the newest backups are kept")
+
+	info, err := os.Stat(filepath.Join(dir, "apt-eval-2026-05-10T15-03-00.000.log"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1<<20), info.Size(), "lines aren't split across files")
+}
+
+func TestRemoveOldBackups(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "apt-eval.log")
+	now := time.Date(2026, 5, 10, 15, 0, 0, 0, time.UTC)
+	old := filepath.Join(dir, "apt-eval-2026-04-01T00-00-00.000.log")
+	recent := filepath.Join(dir, "apt-eval-2026-05-09T00-00-00.000.log")
+	other := filepath.Join(dir, "other-2026-04-01T00-00-00.000.log")
+	for _, p := range []string{old, recent, other} {
+		assert.NoError(t, os.WriteFile(p, []byte("log\n"), 0644))
+	}
+
+	f, err := Open(path, Options{MaxAgeDays: 7})
+	assert.NoError(t, err)
+	defer f.Close()
+	f.now = func() time.Time { return now }
+
+	_, err = f.Write([]byte("started\n"))
+	assert.NoError(t, err)
+	assert.NoError(t, f.Rotate())
+
+	assert.NoFileExists(t, old)
+	assert.FileExists(t, recent)
+	assert.FileExists(t, other, "other logs in the directory are left alone")
+	assert.FileExists(t, filepath.Join(dir, "apt-eval-2026-05-10T15-00-00.000.log"))
+
+	assert.NoError(t, f.Close())
+	_, err = f.Write([]byte("closed\n"))
+	assert.ErrorIs(t, err, os.ErrClosed)
+}
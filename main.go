@@ -1,30 +1,84 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"expvar"
+	"flag"
+	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"reflect"
+	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/mojotx/apt-eval/db"
+	"github.com/mojotx/apt-eval/drain"
+	"github.com/mojotx/apt-eval/enrich"
+	"github.com/mojotx/apt-eval/export"
+	"github.com/mojotx/apt-eval/fieldcrypt"
+	"github.com/mojotx/apt-eval/gcal"
 	"github.com/mojotx/apt-eval/handlers"
+	"github.com/mojotx/apt-eval/health"
+	"github.com/mojotx/apt-eval/ldap"
+	"github.com/mojotx/apt-eval/llm"
+	"github.com/mojotx/apt-eval/logfile"
+	"github.com/mojotx/apt-eval/models"
+	"github.com/mojotx/apt-eval/notify"
+	"github.com/mojotx/apt-eval/oembed"
+	"github.com/mojotx/apt-eval/scan"
+	"github.com/mojotx/apt-eval/scheduler"
+	"github.com/mojotx/apt-eval/seed"
+	"github.com/mojotx/apt-eval/sentry"
+	"github.com/mojotx/apt-eval/sso"
+	"github.com/mojotx/apt-eval/storage"
+	"github.com/mojotx/apt-eval/webhook"
+	"github.com/quic-go/quic-go/http3"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
 
 // App holds the application components
 type App struct {
-	DB       *db.DB
-	Router   *gin.Engine
-	HTTPSrv  *http.Server
-	RedirSrv *http.Server
-	Config   AppConfig
+	DB         *db.DB
+	Router     *gin.Engine
+	HTTPSrv    *http.Server
+	RedirSrv   *http.Server
+	HTTPSDrain *drain.Tracker // Requests and connections for the shutdown report
+	RedirDrain *drain.Tracker
+	H3Srv      *http3.Server // nil unless HTTP/3 is enabled
+	Scheduler  *scheduler.Scheduler
+	Enricher   *enrich.Enricher
+	Notifier   *notify.Notifier // nil when no SMS provider is configured
+	Storage    *storage.Store
+	Scanner    scan.Scanner // nil when uploads aren't virus scanned
+	LLM        llm.Provider // nil when AI summaries are disabled
+	Exporters  []*export.Syncer
+	Calendar   *gcal.Syncer        // nil when calendar sync isn't configured
+	ClientCAs  *x509.CertPool      // nil when client certificates aren't required
+	Directory  *ldap.Authenticator // nil when directory sign-in isn't configured
+	OIDC       *sso.OIDC           // nil when OpenID Connect sign-in isn't configured
+	SAML       *sso.SAML           // nil when SAML sign-in isn't configured
+	Cert       *certificate        // Served certificate, reloaded on SIGHUP
+	Sentry     *sentry.Client      // nil when errors aren't reported
+	Config     AppConfig
+
+	// RouteTimeouts are the request timeouts set for particular routes
+	RouteTimeouts map[string]time.Duration
+
+	// WebhookSources are the services allowed to post listings, by name
+	WebhookSources map[string]*webhook.Source
 }
 
 // AppConfig holds application configuration
@@ -35,13 +89,256 @@ type AppConfig struct {
 	CertFile   string
 	KeyFile    string
 	StaticPath string
+	CacheSize  int
+
+	// LogLevel is the lowest level logged: debug, info, warn, or error
+	LogLevel string
+	// LogFormat is console for people or json for log collectors
+	LogFormat string
+	// LogCaller adds the file and line that logged to every entry
+	LogCaller bool
+	// LogPayloads logs the bodies of API calls at debug level, except
+	// under the paths in LogPayloadsExclude
+	LogPayloads        bool
+	LogPayloadsExclude []string
+	// LogFile is a file logs are also written to; empty for none
+	LogFile           string
+	LogFileMaxSizeMB  int
+	LogFileMaxBackups int
+	LogFileMaxAgeDays int
+
+	// SentryDSN is where handler panics and 5xx errors are reported; empty
+	// reports nothing
+	SentryDSN         string
+	SentryEnvironment string
+	SentryRelease     string
+
+	// DateFormat is how dates are written in responses: rfc3339 or date
+	DateFormat string
+
+	// Statements slower than this are logged; 0 logs none
+	SlowQueryMS int
+
+	// The database connection pool; see db.PoolOptions
+	DBMaxOpenConns           int
+	DBMaxIdleConns           int
+	DBConnMaxLifetimeMinutes int
+
+	// UniqueAddresses refuses a second apartment at an address and unit
+	// already entered
+	UniqueAddresses bool
+
+	// Statements running longer than this are interrupted; 0 lets them run
+	DBStatementTimeoutMS int
+
+	// Connection timeouts for both listeners, so slow or idle clients
+	// can't hold connections open; 0 disables one
+	HTTPReadHeaderTimeoutSeconds int
+	HTTPReadTimeoutSeconds       int
+	HTTPWriteTimeoutSeconds      int
+	HTTPIdleTimeoutSeconds       int
+
+	// RequestTimeoutSeconds bounds how long a request is worked on, and
+	// RequestTimeouts overrides it by route, as route=seconds entries
+	// separated by semicolons
+	RequestTimeoutSeconds int
+	RequestTimeouts       string
+
+	// BehindProxy serves the app on the HTTP port, for a proxy that
+	// terminates TLS, instead of redirecting to HTTPS. H2C accepts
+	// cleartext HTTP/2 there. HTTP3 adds an experimental QUIC listener on
+	// the HTTPS port.
+	BehindProxy bool
+	H2C         bool
+	HTTP3       bool
+
+	// CanonicalHost (host or host:port) is the only Host served; requests
+	// for others are redirected there, or refused when CanonicalHostMode
+	// is "reject". Empty serves any Host.
+	CanonicalHost     string
+	CanonicalHostMode string
+
+	// CA bundle for verifying client certificates; when set, every request
+	// to the HTTPS listener needs one, naming a user as its common name
+	ClientCAFile string
+
+	// Single-user mode: one user name and password for the whole app, with
+	// HTTP Basic authentication; both empty to turn it off
+	BasicAuthUser string
+	BasicAuthPass string
+
+	// Directory sign-in: users sign in, with HTTP Basic authentication, as
+	// accounts in an LDAP directory; an empty URL turns it off. %s in the
+	// user filter stands for the user name. Group roles map group DNs to
+	// roles, as "group-dn:admin;group-dn:member".
+	LDAPURL            string
+	LDAPBindDN         string
+	LDAPBindPassword   string
+	LDAPBaseDN         string
+	LDAPUserFilter     string
+	LDAPGroupAttribute string
+	LDAPGroupRoles     string
+	LDAPCacheSeconds   int
+
+	// Single sign-on through an identity provider, with OpenID Connect,
+	// SAML, or both; users are added on first sign-in. The base URL is
+	// where users reach the app, for the providers to send them back to,
+	// and the secret signs session cookies.
+	SSOBaseURL       string
+	SSOSecret        string
+	SSOSessionHours  int
+	OIDCIssuer       string
+	OIDCClientID     string
+	OIDCClientSecret string
+	SAMLIdPMetadata  string // URL or file
+
+	// Key for encrypting notes and contact details, base64 or in a file;
+	// neither leaves them unencrypted
+	FieldEncryptionKey     string
+	FieldEncryptionKeyFile string
+
+	// Schedules use cron syntax ("0 3 * * *") or descriptors ("@daily");
+	// an empty schedule disables the task
+	BackupSchedule string
+	BackupKeep     int
+
+	// Enrichment providers and refresh cadence
+	WalkabilityProvider  string
+	WalkScoreAPIKey      string
+	OverpassURL          string
+	CrimeSourcesFile     string
+	SchoolProvider       string
+	GreatSchoolsAPIKey   string
+	MarketRentProvider   string
+	MarketRentAPIKey     string
+	MarketRentURL        string
+	GTFSFeed             string
+	CommuteProvider      string
+	OSRMURL              string
+	GoogleMapsAPIKey     string
+	EnrichmentSchedule   string
+	EnrichmentMaxAgeDays int
+
+	// Geocoding of apartments entered without coordinates
+	GeocoderProvider  string
+	GeocoderAPIKey    string
+	GeocoderURL       string
+	GeocoderRateLimit float64
+
+	// Address autocomplete proxied for the frontend
+	AddressSuggestProvider string
+	AddressSuggestAPIKey   string
+	AddressSuggestURL      string
+
+	// clamd socket for virus scanning uploads; empty disables scanning
+	ClamdAddress string
+
+	// Attachment upload limits
+	UploadMaxSizeMB    int
+	UploadAllowedTypes []string
+	MaxAttachments     int
+	ExtractPhotoGPS    bool
+
+	// StorageQuotaMB caps the space all uploaded files may take up
+	// together; 0 for no limit
+	StorageQuotaMB int
+
+	// Language model for apartment summaries; an empty provider disables
+	// them
+	LLMProvider string
+	LLMBaseURL  string
+	LLMAPIKey   string
+	LLMModel    string
+
+	// Shared secret for the inbound email webhook; empty disables it
+	InboundEmailToken string
+
+	// API key for the bookmarklet capture endpoint; empty disables it
+	CaptureAPIKey string
+
+	// JSON file configuring signed inbound webhooks; empty disables them
+	InboundWebhooksFile string
+
+	// SMS notifications; an empty provider disables them
+	SMSProvider          string
+	TwilioAccountSID     string
+	TwilioAuthToken      string
+	TwilioFrom           string
+	NotifySchedule       string
+	VisitReminderHours   int
+	DeadlineReminderDays int
+	AlertSchedule        string // When alert rules are checked
+
+	// Notion and Airtable exports; a service without a token isn't
+	// exported to
+	NotionToken      string
+	NotionDatabaseID string
+	AirtableToken    string
+	AirtableBaseID   string
+	AirtableTable    string
+	ExportSchedule   string
+
+	// Two-way Google Calendar sync of visits; without a client ID it's
+	// disabled
+	GoogleClientID       string
+	GoogleClientSecret   string
+	GoogleRedirectURL    string // Empty to derive it from the request
+	GoogleCalendarID     string
+	CalendarSyncSchedule string
+	VisitEventMinutes    int
+
+	UndoWindowMinutes int // How long a change can be undone for
+
+	// Data retention; a zero limit keeps that data forever
+	RetentionSchedule     string
+	ArchiveAfterMonths    int
+	PurgeDeletedAfterDays int
+	HistoryMaxRows        int
+
+	// Demo mode serves sample data from its own database, reset on
+	// DemoResetSchedule, instead of the real one
+	DemoMode          bool
+	DemoResetSchedule string
+
+	// SeedAPI opens POST /api/admin/seed, which adds generated apartments
+	// to the live database
+	SeedAPI bool
 }
 
-func main() {
-	setupLogging()
+// canonicalAuthority returns the host[:port] requests are sent to on the
+// canonical host, using the HTTPS port unless CanonicalHost names one
+func (c AppConfig) canonicalAuthority() string {
+	if _, _, err := net.SplitHostPort(c.CanonicalHost); err == nil {
+		return c.CanonicalHost
+	}
+	return net.JoinHostPort(c.CanonicalHost, c.HTTPSPort)
+}
 
-	// Initialize application config
+// retentionPolicy returns the data retention policy the config sets
+func (c AppConfig) retentionPolicy() db.RetentionPolicy {
+	return db.RetentionPolicy{
+		ArchiveAfterMonths:    c.ArchiveAfterMonths,
+		PurgeDeletedAfterDays: c.PurgeDeletedAfterDays,
+		HistoryMaxRows:        c.HistoryMaxRows,
+	}
+}
+
+func main() {
+	// Initialize application config, from the environment and CONFIG_FILE
+	if err := loadEnvFile(os.Getenv("CONFIG_FILE")); err != nil {
+		log.Fatal().Err(err).Msg("Failed to read config file")
+	}
 	config := loadConfig()
+	if err := setupLogging(config); err != nil {
+		log.Fatal().Err(err).Msg("Failed to set up logging")
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "seed" {
+		if err := runSeed(config, os.Args[2:]); err != nil {
+			log.Fatal().Err(err).Msg("Failed to seed apartments")
+		}
+		return
+	}
 
 	// Create and initialize the app
 	app, err := initApp(config)
@@ -50,19 +347,109 @@ func main() {
 	}
 	defer app.DB.Close()
 
-	// Start the servers
+	// Start background tasks and the servers
+	app.Scheduler.Start()
 	startServers(app)
+	go handleReload(app)
 
 	// Wait for shutdown signal and handle graceful shutdown
 	handleShutdown(app)
 }
 
-// setupLogging configures the application logging
-func setupLogging() {
+// runSeed handles the seed subcommand, which fills the database with
+// generated apartments for load testing
+func runSeed(config AppConfig, args []string) error {
+	flags := flag.NewFlagSet("seed", flag.ContinueOnError)
+	count := flags.Int("n", 10000, "number of apartments to generate")
+	seedValue := flags.Uint64("seed", uint64(time.Now().UnixNano()), "random seed, for generating the same apartments again")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	database, err := db.New(config.DataDir)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+	if err := enableEncryption(database, config); err != nil {
+		return err
+	}
+
+	started := time.Now()
+	if err := seed.Load(database, *count, *seedValue); err != nil {
+		return err
+	}
+	log.Info().Int("count", *count).Uint64("seed", *seedValue).Dur("took", time.Since(started)).Msg("Seeded apartments")
+	return nil
+}
+
+// logFile is the file logs are also written to, if any
+var logFile *logfile.File
+
+// setupLogging configures the application logging: human-readable console
+// output by default, or one JSON object per line for a log collector. With
+// LOG_FILE, logs are also written to a file in the same format, which is
+// rotated by size.
+func setupLogging(config AppConfig) error {
 	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
-	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
+
+	var file *logfile.File
+	if config.LogFile != "" {
+		var err error
+		file, err = logfile.Open(config.LogFile, logfile.Options{
+			MaxSizeMB:  config.LogFileMaxSizeMB,
+			MaxBackups: config.LogFileMaxBackups,
+			MaxAgeDays: config.LogFileMaxAgeDays,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	var writers []io.Writer
+	switch config.LogFormat {
+	case "", "console":
+		writers = append(writers, zerolog.ConsoleWriter{Out: os.Stderr})
+		if file != nil {
+			writers = append(writers, zerolog.ConsoleWriter{Out: file, NoColor: true})
+		}
+	case "json":
+		// Collectors parse RFC 3339 more readily than Unix seconds
+		zerolog.TimeFieldFormat = time.RFC3339Nano
+		writers = append(writers, os.Stderr)
+		if file != nil {
+			writers = append(writers, file)
+		}
+	default:
+		if file != nil {
+			file.Close()
+		}
+		return fmt.Errorf("unknown LOG_FORMAT %q, want console or json", config.LogFormat)
+	}
+	fields := zerolog.New(zerolog.MultiLevelWriter(writers...)).With().Timestamp()
+	if config.LogCaller {
+		fields = fields.Caller()
+	}
+	log.Logger = fields.Logger()
+
+	// Replace the file of an earlier setup
+	if logFile != nil {
+		logFile.Close()
+	}
+	logFile = file
+
+	if config.LogLevel == "" {
+		return nil
+	}
+	return setLogLevel(config.LogLevel)
 }
 
+// defaultRequestTimeouts gives the routes that upload files, build
+// exports, or call out to slow services longer than the default
+const defaultRequestTimeouts = "/api/admin=300;/api/exports=300;/api/users/:id/export=300;" +
+	"/api/calendar/sync=120;/api/apartments/:id/summarize=120;/api/apartments/:id/summary.pdf=120;" +
+	"/api/apartments/:id/attachments=300;/api/apartments/:id/floorplan=300;/api/sync=120"
+
 // loadConfig loads application configuration from environment variables
 func loadConfig() AppConfig {
 	return AppConfig{
@@ -72,36 +459,702 @@ func loadConfig() AppConfig {
 		CertFile:   getEnv("CERT_FILE", "./certs/wildcard.crt"),
 		KeyFile:    getEnv("KEY_FILE", "./certs/wildcard.key"),
 		StaticPath: "./static",
+		CacheSize:  getEnvInt("CACHE_SIZE", 256),
+
+		LogLevel:  getEnv("LOG_LEVEL", "info"),
+		LogFormat: getEnv("LOG_FORMAT", "console"),
+		LogCaller: getEnvBool("LOG_CALLER", false),
+
+		LogPayloads:        getEnvBool("LOG_PAYLOADS", false),
+		LogPayloadsExclude: getEnvList("LOG_PAYLOADS_EXCLUDE", nil),
+
+		LogFile:           getEnv("LOG_FILE", ""),
+		LogFileMaxSizeMB:  getEnvInt("LOG_FILE_MAX_SIZE_MB", 100),
+		LogFileMaxBackups: getEnvInt("LOG_FILE_MAX_BACKUPS", 5),
+		LogFileMaxAgeDays: getEnvInt("LOG_FILE_MAX_AGE_DAYS", 30),
+
+		SentryDSN:         getEnv("SENTRY_DSN", ""),
+		SentryEnvironment: getEnv("SENTRY_ENVIRONMENT", "production"),
+		SentryRelease:     getEnv("SENTRY_RELEASE", ""),
+
+		DateFormat: getEnv("DATE_FORMAT", models.DateFormatRFC3339),
+
+		SlowQueryMS: getEnvInt("SLOW_QUERY_MS", 200),
+
+		DBMaxOpenConns:           getEnvInt("DB_MAX_OPEN_CONNS", db.DefaultPool.MaxOpenConns),
+		DBMaxIdleConns:           getEnvInt("DB_MAX_IDLE_CONNS", db.DefaultPool.MaxIdleConns),
+		DBConnMaxLifetimeMinutes: getEnvInt("DB_CONN_MAX_LIFETIME_MINUTES", 0),
+
+		UniqueAddresses: getEnvBool("UNIQUE_ADDRESSES", false),
+
+		DBStatementTimeoutMS: getEnvInt("DB_STATEMENT_TIMEOUT_MS", 15000),
+
+		HTTPReadHeaderTimeoutSeconds: getEnvInt("HTTP_READ_HEADER_TIMEOUT_SECONDS", 10),
+		HTTPReadTimeoutSeconds:       getEnvInt("HTTP_READ_TIMEOUT_SECONDS", 60),
+		HTTPWriteTimeoutSeconds:      getEnvInt("HTTP_WRITE_TIMEOUT_SECONDS", 60),
+		HTTPIdleTimeoutSeconds:       getEnvInt("HTTP_IDLE_TIMEOUT_SECONDS", 120),
+
+		RequestTimeoutSeconds: getEnvInt("REQUEST_TIMEOUT_SECONDS", 30),
+		RequestTimeouts:       getEnv("REQUEST_TIMEOUTS", defaultRequestTimeouts),
+
+		BehindProxy: getEnvBool("BEHIND_PROXY", false),
+		H2C:         getEnvBool("H2C", false),
+		HTTP3:       getEnvBool("HTTP3", false),
+
+		CanonicalHost:     getEnv("CANONICAL_HOST", ""),
+		CanonicalHostMode: getEnv("CANONICAL_HOST_MODE", "redirect"),
+
+		ClientCAFile: getEnv("CLIENT_CA_FILE", ""),
+
+		BasicAuthUser: getEnv("BASIC_AUTH_USER", ""),
+		BasicAuthPass: getEnv("BASIC_AUTH_PASS", ""),
+
+		LDAPURL:            getEnv("LDAP_URL", ""),
+		LDAPBindDN:         getEnv("LDAP_BIND_DN", ""),
+		LDAPBindPassword:   getEnv("LDAP_BIND_PASSWORD", ""),
+		LDAPBaseDN:         getEnv("LDAP_BASE_DN", ""),
+		LDAPUserFilter:     getEnv("LDAP_USER_FILTER", "(uid=%s)"),
+		LDAPGroupAttribute: getEnv("LDAP_GROUP_ATTRIBUTE", "memberOf"),
+		LDAPGroupRoles:     getEnv("LDAP_GROUP_ROLES", ""),
+		LDAPCacheSeconds:   getEnvInt("LDAP_CACHE_SECONDS", 300),
+
+		SSOBaseURL:       getEnv("SSO_BASE_URL", ""),
+		SSOSecret:        getEnv("SSO_SECRET", ""),
+		SSOSessionHours:  getEnvInt("SSO_SESSION_HOURS", 12),
+		OIDCIssuer:       getEnv("OIDC_ISSUER", ""),
+		OIDCClientID:     getEnv("OIDC_CLIENT_ID", ""),
+		OIDCClientSecret: getEnv("OIDC_CLIENT_SECRET", ""),
+		SAMLIdPMetadata:  getEnv("SAML_IDP_METADATA", ""),
+
+		FieldEncryptionKey:     getEnv("FIELD_ENCRYPTION_KEY", ""),
+		FieldEncryptionKeyFile: getEnv("FIELD_ENCRYPTION_KEY_FILE", ""),
+
+		BackupSchedule: getEnv("BACKUP_SCHEDULE", "@daily"),
+		BackupKeep:     getEnvInt("BACKUP_KEEP", 7),
+
+		WalkabilityProvider:  getEnv("WALKABILITY_PROVIDER", ""),
+		WalkScoreAPIKey:      getEnv("WALKSCORE_API_KEY", ""),
+		OverpassURL:          getEnv("OVERPASS_URL", ""),
+		CrimeSourcesFile:     getEnv("CRIME_SOURCES_FILE", ""),
+		SchoolProvider:       getEnv("SCHOOL_PROVIDER", ""),
+		GreatSchoolsAPIKey:   getEnv("GREATSCHOOLS_API_KEY", ""),
+		MarketRentProvider:   getEnv("MARKET_RENT_PROVIDER", ""),
+		MarketRentAPIKey:     getEnv("MARKET_RENT_API_KEY", ""),
+		MarketRentURL:        getEnv("MARKET_RENT_URL", ""),
+		GTFSFeed:             getEnv("GTFS_FEED", ""),
+		CommuteProvider:      getEnv("COMMUTE_PROVIDER", "estimate"),
+		OSRMURL:              getEnv("OSRM_URL", ""),
+		GoogleMapsAPIKey:     getEnv("GOOGLE_MAPS_API_KEY", ""),
+		EnrichmentSchedule:   getEnv("ENRICHMENT_SCHEDULE", "@hourly"),
+		EnrichmentMaxAgeDays: getEnvInt("ENRICHMENT_MAX_AGE_DAYS", 30),
+
+		GeocoderProvider:  getEnv("GEOCODER_PROVIDER", ""),
+		GeocoderAPIKey:    getEnv("GEOCODER_API_KEY", ""),
+		GeocoderURL:       getEnv("GEOCODER_URL", ""),
+		GeocoderRateLimit: getEnvFloat("GEOCODER_RATE_LIMIT", 0),
+
+		AddressSuggestProvider: getEnv("ADDRESS_SUGGEST_PROVIDER", ""),
+		AddressSuggestAPIKey:   getEnv("ADDRESS_SUGGEST_API_KEY", ""),
+		AddressSuggestURL:      getEnv("ADDRESS_SUGGEST_URL", ""),
+
+		ClamdAddress: getEnv("CLAMD_ADDRESS", ""),
+
+		UploadMaxSizeMB:    getEnvInt("UPLOAD_MAX_SIZE_MB", 20),
+		UploadAllowedTypes: getEnvList("UPLOAD_ALLOWED_TYPES", []string{"image/png", "image/jpeg", "image/gif", "application/pdf"}),
+		MaxAttachments:     getEnvInt("MAX_ATTACHMENTS", 50),
+		ExtractPhotoGPS:    getEnvBool("EXTRACT_PHOTO_GPS", false),
+
+		StorageQuotaMB: getEnvInt("STORAGE_QUOTA_MB", 0),
+
+		LLMProvider: getEnv("LLM_PROVIDER", ""),
+		LLMBaseURL:  getEnv("LLM_BASE_URL", ""),
+		LLMAPIKey:   getEnv("LLM_API_KEY", ""),
+		LLMModel:    getEnv("LLM_MODEL", ""),
+
+		InboundEmailToken: getEnv("INBOUND_EMAIL_TOKEN", ""),
+
+		CaptureAPIKey: getEnv("CAPTURE_API_KEY", ""),
+
+		InboundWebhooksFile: getEnv("INBOUND_WEBHOOKS_FILE", ""),
+
+		SMSProvider:          getEnv("SMS_PROVIDER", ""),
+		TwilioAccountSID:     getEnv("TWILIO_ACCOUNT_SID", ""),
+		TwilioAuthToken:      getEnv("TWILIO_AUTH_TOKEN", ""),
+		TwilioFrom:           getEnv("TWILIO_FROM", ""),
+		NotifySchedule:       getEnv("NOTIFY_SCHEDULE", "@every 1m"),
+		VisitReminderHours:   getEnvInt("VISIT_REMINDER_HOURS", 24),
+		DeadlineReminderDays: getEnvInt("DEADLINE_REMINDER_DAYS", 2),
+		AlertSchedule:        getEnv("ALERT_SCHEDULE", "@every 15m"),
+
+		NotionToken:      getEnv("NOTION_TOKEN", ""),
+		NotionDatabaseID: getEnv("NOTION_DATABASE_ID", ""),
+		AirtableToken:    getEnv("AIRTABLE_TOKEN", ""),
+		AirtableBaseID:   getEnv("AIRTABLE_BASE_ID", ""),
+		AirtableTable:    getEnv("AIRTABLE_TABLE", ""),
+		ExportSchedule:   getEnv("EXPORT_SCHEDULE", "@hourly"),
+
+		GoogleClientID:       getEnv("GOOGLE_CLIENT_ID", ""),
+		GoogleClientSecret:   getEnv("GOOGLE_CLIENT_SECRET", ""),
+		GoogleRedirectURL:    getEnv("GOOGLE_REDIRECT_URL", ""),
+		GoogleCalendarID:     getEnv("GOOGLE_CALENDAR_ID", "primary"),
+		CalendarSyncSchedule: getEnv("CALENDAR_SYNC_SCHEDULE", "@every 5m"),
+		VisitEventMinutes:    getEnvInt("VISIT_EVENT_MINUTES", 30),
+
+		UndoWindowMinutes: getEnvInt("UNDO_WINDOW_MINUTES", 10),
+
+		RetentionSchedule:     getEnv("RETENTION_SCHEDULE", "@daily"),
+		ArchiveAfterMonths:    getEnvInt("ARCHIVE_AFTER_MONTHS", 0),
+		PurgeDeletedAfterDays: getEnvInt("PURGE_DELETED_AFTER_DAYS", 30),
+		HistoryMaxRows:        getEnvInt("HISTORY_MAX_ROWS", 10000),
+
+		DemoMode:          getEnvBool("DEMO_MODE", false),
+		DemoResetSchedule: getEnv("DEMO_RESET_SCHEDULE", "@hourly"),
+
+		SeedAPI: getEnvBool("SEED_API", false),
 	}
 }
 
 // initApp initializes the application components
 func initApp(config AppConfig) (*App, error) {
+	if (config.BasicAuthUser == "") != (config.BasicAuthPass == "") {
+		return nil, errors.New("BASIC_AUTH_USER and BASIC_AUTH_PASS must be set together")
+	}
+	if config.LDAPURL != "" && config.BasicAuthUser != "" {
+		return nil, errors.New("LDAP_URL and BASIC_AUTH_USER can't both be set")
+	}
+	if config.CanonicalHostMode != "" && config.CanonicalHostMode != "redirect" && config.CanonicalHostMode != "reject" {
+		return nil, fmt.Errorf("invalid CANONICAL_HOST_MODE %q, want redirect or reject", config.CanonicalHostMode)
+	}
+	if err := models.SetDateFormat(config.DateFormat); err != nil {
+		return nil, err
+	}
+	if config.DemoMode {
+		// Leave the real data alone, starting from a clean slate each time
+		config.DataDir = filepath.Join(config.DataDir, "demo")
+		if err := os.RemoveAll(config.DataDir); err != nil {
+			return nil, fmt.Errorf("failed to clear demo data: %w", err)
+		}
+	}
+
 	// Initialize database
 	database, err := db.New(config.DataDir)
 	if err != nil {
 		return nil, err
 	}
+	database.SetPool(db.PoolOptions{
+		MaxOpenConns:    config.DBMaxOpenConns,
+		MaxIdleConns:    config.DBMaxIdleConns,
+		ConnMaxLifetime: time.Duration(config.DBConnMaxLifetimeMinutes) * time.Minute,
+	})
+	database.EnableCache(config.CacheSize)
+	database.LogSlowQueries(time.Duration(config.SlowQueryMS) * time.Millisecond)
+	database.SetStatementTimeout(time.Duration(config.DBStatementTimeoutMS) * time.Millisecond)
+	if err := database.RequireUniqueAddresses(config.UniqueAddresses); err != nil {
+		database.Close()
+		return nil, err
+	}
 
-	// Setup router with routes
-	router := setupRouter(database, config)
+	if err := enableEncryption(database, config); err != nil {
+		database.Close()
+		return nil, err
+	}
+	if config.DemoMode {
+		if err := seed.LoadDemo(database, time.Now()); err != nil {
+			database.Close()
+			return nil, err
+		}
+		if err := database.Snapshot(demoSnapshotPath(config)); err != nil {
+			database.Close()
+			return nil, err
+		}
+		log.Warn().Str("data_dir", config.DataDir).Msg("Demo mode: serving sample data")
+	}
+
+	clientCAs, err := loadClientCAs(config.ClientCAFile)
+	if err != nil {
+		database.Close()
+		return nil, err
+	}
+
+	directory, err := newDirectory(config)
+	if err != nil {
+		database.Close()
+		return nil, err
+	}
+
+	oidc, saml, err := newSSO(config)
+	if err != nil {
+		database.Close()
+		return nil, err
+	}
+
+	routeTimeouts, err := handlers.ParseRouteTimeouts(config.RequestTimeouts)
+	if err != nil {
+		database.Close()
+		return nil, fmt.Errorf("invalid REQUEST_TIMEOUTS: %w", err)
+	}
+
+	webhookSources, err := webhook.LoadSources(config.InboundWebhooksFile)
+	if err != nil {
+		database.Close()
+		return nil, err
+	}
+
+	// Configure enrichment providers
+	enricher, err := newEnricher(database, config)
+	if err != nil {
+		database.Close()
+		return nil, err
+	}
+
+	sender, err := notify.NewSender(config.SMSProvider, notify.TwilioConfig{
+		AccountSID: config.TwilioAccountSID,
+		AuthToken:  config.TwilioAuthToken,
+		From:       config.TwilioFrom,
+	})
+	if err != nil {
+		database.Close()
+		return nil, err
+	}
+
+	// Uploaded files live alongside the database
+	store, err := storage.New(filepath.Join(config.DataDir, "uploads"))
+	if err != nil {
+		database.Close()
+		return nil, err
+	}
+
+	scanner, err := scan.New(config.ClamdAddress)
+	if err != nil {
+		database.Close()
+		return nil, err
+	}
+
+	model, err := llm.NewProvider(config.LLMProvider, llm.Config{
+		BaseURL: config.LLMBaseURL,
+		APIKey:  config.LLMAPIKey,
+		Model:   config.LLMModel,
+	})
+	if err != nil {
+		database.Close()
+		return nil, err
+	}
+
+	exporters, err := export.New(export.Config{
+		NotionToken:      config.NotionToken,
+		NotionDatabaseID: config.NotionDatabaseID,
+		AirtableToken:    config.AirtableToken,
+		AirtableBaseID:   config.AirtableBaseID,
+		AirtableTable:    config.AirtableTable,
+	})
+	if err != nil {
+		database.Close()
+		return nil, err
+	}
+
+	tracker, err := sentry.New(config.SentryDSN, config.SentryRelease, config.SentryEnvironment)
+	if err != nil {
+		database.Close()
+		return nil, err
+	}
 
 	// Create app instance
 	app := &App{
-		DB:     database,
-		Router: router,
-		Config: config,
+		DB:         database,
+		ClientCAs:  clientCAs,
+		Directory:  directory,
+		OIDC:       oidc,
+		SAML:       saml,
+		Scheduler:  scheduler.New(),
+		HTTPSDrain: drain.New("https"),
+		RedirDrain: drain.New("http"),
+		Enricher:   enricher,
+		Storage:    store,
+		Scanner:    scanner,
+		LLM:        model,
+		Sentry:     tracker,
+		Config:     config,
+
+		RouteTimeouts:  routeTimeouts,
+		WebhookSources: webhookSources,
+	}
+	for _, e := range exporters {
+		app.Exporters = append(app.Exporters, export.NewSyncer(database, e))
+	}
+	if config.GoogleClientID != "" {
+		app.Calendar = gcal.NewSyncer(database, gcal.NewOAuth(config.GoogleClientID, config.GoogleClientSecret))
+		app.Calendar.EventLength = time.Duration(config.VisitEventMinutes) * time.Minute
+	}
+	if sender != nil {
+		reminderLead := time.Duration(config.VisitReminderHours) * time.Hour
+		app.Notifier = notify.NewNotifier(database, sender, reminderLead)
+		app.Notifier.DeadlineLead = time.Duration(config.DeadlineReminderDays) * 24 * time.Hour
+	}
+
+	watchIntegrations(app, sender)
+
+	// Register recurring background tasks
+	if err := registerTasks(app); err != nil {
+		database.Close()
+		return nil, err
 	}
 
+	// Setup router with routes
+	app.Router = setupRouter(app)
+
 	// Configure HTTP and HTTPS servers
 	setupServers(app)
 
 	return app, nil
 }
 
+// loadClientCAs reads the CA bundle client certificates are verified
+// against, or returns nil when none is configured
+func loadClientCAs(path string) (*x509.CertPool, error) {
+	if path == "" {
+		return nil, nil
+	}
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client CA file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in client CA file %s", path)
+	}
+	return pool, nil
+}
+
+// watchIntegrations adds the configured external services to the
+// readiness check, with the sender of SMS notifications, if any
+func watchIntegrations(app *App, sender notify.Sender) {
+	if app.Enricher != nil {
+		for name, provider := range app.Enricher.Providers() {
+			health.Watch(name, provider)
+		}
+	}
+	if sender != nil {
+		health.Watch("sms", sender.Name())
+	}
+	if app.Scanner != nil {
+		health.Watch("virus_scanner", "clamav")
+	}
+	if app.LLM != nil {
+		health.Watch("llm", app.LLM.Name())
+	}
+	for _, s := range app.Exporters {
+		health.Watch("export_"+s.Name(), s.Name())
+	}
+	if app.Calendar != nil {
+		health.Watch("calendar", "google")
+	}
+}
+
+// newDirectory configures signing in against an LDAP directory, or
+// returns nil when none is configured
+func newDirectory(config AppConfig) (*ldap.Authenticator, error) {
+	if config.LDAPURL == "" {
+		return nil, nil
+	}
+	if config.LDAPBaseDN == "" {
+		return nil, errors.New("LDAP_BASE_DN is required with LDAP_URL")
+	}
+	if !strings.Contains(config.LDAPUserFilter, "%s") {
+		return nil, errors.New("LDAP_USER_FILTER must contain %s for the user name")
+	}
+	roles, err := ldap.ParseGroupRoles(config.LDAPGroupRoles)
+	if err != nil {
+		return nil, fmt.Errorf("invalid LDAP_GROUP_ROLES: %w", err)
+	}
+
+	auth := ldap.NewAuthenticator(config.LDAPURL, config.LDAPBaseDN)
+	auth.BindDN = config.LDAPBindDN
+	auth.BindPassword = config.LDAPBindPassword
+	auth.UserFilter = config.LDAPUserFilter
+	auth.GroupAttribute = config.LDAPGroupAttribute
+	auth.GroupRoles = roles
+	auth.CacheTTL = time.Duration(config.LDAPCacheSeconds) * time.Second
+	return auth, nil
+}
+
+// newSSO configures single sign-on with OpenID Connect and SAML, returning
+// nil for those that aren't configured
+func newSSO(config AppConfig) (*sso.OIDC, *sso.SAML, error) {
+	if config.OIDCIssuer == "" && config.SAMLIdPMetadata == "" {
+		return nil, nil, nil
+	}
+	switch {
+	case config.SSOBaseURL == "" || config.SSOSecret == "":
+		return nil, nil, errors.New("SSO_BASE_URL and SSO_SECRET are required for single sign-on")
+	case config.BasicAuthUser != "" || config.LDAPURL != "":
+		return nil, nil, errors.New("single sign-on can't be combined with BASIC_AUTH_USER or LDAP_URL")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var oidc *sso.OIDC
+	if config.OIDCIssuer != "" {
+		var err error
+		oidc, err = sso.DiscoverOIDC(ctx, config.OIDCIssuer, config.OIDCClientID, config.OIDCClientSecret)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var saml *sso.SAML
+	if config.SAMLIdPMetadata != "" {
+		md, err := sso.LoadIdPMetadata(ctx, config.SAMLIdPMetadata)
+		if err != nil {
+			return nil, nil, err
+		}
+		saml = sso.NewSAML(handlers.SAMLEntityID(config.SSOBaseURL), handlers.SAMLACSURL(config.SSOBaseURL), md)
+	}
+	return oidc, saml, nil
+}
+
+// enableEncryption turns on encryption of notes and contact details when a
+// key is configured, encrypting what was stored before
+func enableEncryption(database *db.DB, config AppConfig) error {
+	var key []byte
+	var err error
+	switch {
+	case config.FieldEncryptionKeyFile != "":
+		key, err = fieldcrypt.LoadKey(config.FieldEncryptionKeyFile)
+	case config.FieldEncryptionKey != "":
+		key, err = fieldcrypt.ParseKey(config.FieldEncryptionKey)
+	default:
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	cipher, err := fieldcrypt.New(key)
+	if err != nil {
+		return err
+	}
+
+	database.EnableEncryption(cipher)
+	n, err := database.EncryptExisting()
+	if err != nil {
+		return err
+	}
+	log.Info().Int64("encrypted", n).Msg("Field encryption enabled")
+	return nil
+}
+
+// newEnricher builds the enricher from the configured providers
+func newEnricher(database *db.DB, config AppConfig) (*enrich.Enricher, error) {
+	geocoderKey := config.GeocoderAPIKey
+	if geocoderKey == "" && config.GeocoderProvider == "google" {
+		geocoderKey = config.GoogleMapsAPIKey
+	}
+	geocoder, err := enrich.NewGeocodeProvider(config.GeocoderProvider, geocoderKey, config.GeocoderURL, config.GeocoderRateLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	// Suggestions from the geocoder's provider can share its key
+	suggestKey := config.AddressSuggestAPIKey
+	if suggestKey == "" && config.AddressSuggestProvider == config.GeocoderProvider {
+		suggestKey = geocoderKey
+	}
+	if suggestKey == "" && config.AddressSuggestProvider == "google" {
+		suggestKey = config.GoogleMapsAPIKey
+	}
+	suggest, err := enrich.NewSuggestProvider(config.AddressSuggestProvider, suggestKey, config.AddressSuggestURL)
+	if err != nil {
+		return nil, err
+	}
+
+	walkability, err := enrich.NewWalkabilityProvider(config.WalkabilityProvider, config.WalkScoreAPIKey, config.OverpassURL)
+	if err != nil {
+		return nil, err
+	}
+
+	crimeSources, err := enrich.LoadCrimeSources(config.CrimeSourcesFile)
+	if err != nil {
+		return nil, err
+	}
+
+	schools, err := enrich.NewSchoolProvider(config.SchoolProvider, config.GreatSchoolsAPIKey)
+	if err != nil {
+		return nil, err
+	}
+
+	marketRent, err := enrich.NewMarketRentProvider(config.MarketRentProvider, config.MarketRentAPIKey, config.MarketRentURL)
+	if err != nil {
+		return nil, err
+	}
+
+	transit, err := enrich.LoadGTFS(config.GTFSFeed)
+	if err != nil {
+		return nil, err
+	}
+	if transit != nil {
+		log.Info().Int("stops", transit.Len()).Str("feed", config.GTFSFeed).Msg("Loaded GTFS feed")
+	}
+
+	commute, err := enrich.NewCommuteProvider(config.CommuteProvider, config.OSRMURL, config.GoogleMapsAPIKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return enrich.NewEnricher(database, enrich.Config{
+		Geocoder:     geocoder,
+		Suggest:      suggest,
+		Walkability:  walkability,
+		CrimeSources: crimeSources,
+		Schools:      schools,
+		MarketRent:   marketRent,
+		Transit:      transit,
+		Commute:      commute,
+		MaxAge:       time.Duration(config.EnrichmentMaxAgeDays) * 24 * time.Hour,
+		RequestDelay: time.Second,
+	}), nil
+}
+
+// demoSnapshotPath is where demo mode keeps the sample data it resets to
+func demoSnapshotPath(config AppConfig) string {
+	return filepath.Join(config.DataDir, "demo-snapshot.db")
+}
+
+// registerTasks adds the application's recurring tasks to the scheduler
+func registerTasks(app *App) error {
+	if app.Config.BackupSchedule != "" {
+		backupDir := filepath.Join(app.Config.DataDir, "backups")
+		err := app.Scheduler.Register("backup", app.Config.BackupSchedule, func(ctx context.Context) error {
+			path, err := app.DB.Backup(backupDir, app.Config.BackupKeep)
+			if err == nil {
+				log.Info().Str("path", path).Msg("Database backed up")
+			}
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if app.Config.EnrichmentSchedule != "" {
+		if err := app.Scheduler.Register("enrich", app.Config.EnrichmentSchedule, app.Enricher.RefreshStale); err != nil {
+			return err
+		}
+	}
+
+	if app.Notifier != nil && app.Config.NotifySchedule != "" {
+		if err := app.Scheduler.Register("visit-reminders", app.Config.NotifySchedule, app.Notifier.QueueVisitReminders); err != nil {
+			return err
+		}
+		if err := app.Scheduler.Register("deadline-reminders", app.Config.NotifySchedule, app.Notifier.QueueDeadlineReminders); err != nil {
+			return err
+		}
+		if err := app.Scheduler.Register("notify", app.Config.NotifySchedule, app.Notifier.Dispatch); err != nil {
+			return err
+		}
+	}
+	if app.Notifier != nil && app.Config.AlertSchedule != "" {
+		if err := app.Scheduler.Register("alerts", app.Config.AlertSchedule, app.Notifier.QueueAlerts); err != nil {
+			return err
+		}
+	}
+
+	if app.Config.ExportSchedule != "" {
+		for _, s := range app.Exporters {
+			if err := app.Scheduler.Register("export-"+s.Name(), app.Config.ExportSchedule, s.Run); err != nil {
+				return err
+			}
+		}
+	}
+
+	if app.Calendar != nil && app.Config.CalendarSyncSchedule != "" {
+		if err := app.Scheduler.Register("calendar-sync", app.Config.CalendarSyncSchedule, app.Calendar.Run); err != nil {
+			return err
+		}
+	}
+
+	if app.Config.DemoMode && app.Config.DemoResetSchedule != "" {
+		snapshot := demoSnapshotPath(app.Config)
+		err := app.Scheduler.Register("demo-reset", app.Config.DemoResetSchedule, func(ctx context.Context) error {
+			err := app.DB.Restore(snapshot)
+			if err == nil {
+				log.Info().Msg("Demo data reset")
+			}
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if app.Config.RetentionSchedule != "" {
+		policy := app.Config.retentionPolicy()
+		err := app.Scheduler.Register("retention", app.Config.RetentionSchedule, func(ctx context.Context) error {
+			report, err := app.DB.ApplyRetention(policy, time.Now(), false)
+			if err == nil {
+				log.Info().
+					Int("archived", len(report.Archived)).
+					Int64("purged_tombstones", report.PurgedTombstones).
+					Int64("purged_undo_data", report.PurgedUndoData).
+					Int64("pruned_activity", report.PrunedActivity).
+					Int64("pruned_notifications", report.PrunedNotifications).
+					Msg("Applied data retention")
+			}
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// publicPaths are the route prefixes that are public or authenticate with
+// tokens of their own, so they're left open by client certificate, Basic,
+// directory and single sign-on authentication
+var publicPaths = []string{"/share/", "/api/inbound/", "/api/capture", "/api/calendar/callback", "/health", "/readyz"}
+
 // setupRouter configures the Gin router with all routes
-func setupRouter(database *db.DB, config AppConfig) *gin.Engine {
-	router := gin.Default()
+func setupRouter(app *App) *gin.Engine {
+	database, config := app.DB, app.Config
+	router := gin.New()
+	router.Use(gin.Logger(), handlers.Recovery(), handlers.RequestID())
+	router.Use(handlers.ReportErrors(app.Sentry))
+	router.Use(handlers.Timeout(time.Duration(config.RequestTimeoutSeconds)*time.Second, app.RouteTimeouts))
+	if config.LogPayloads {
+		router.Use(handlers.LogPayloads(config.LogPayloadsExclude...))
+	}
+	router.NoRoute(handlers.NotFound)
+	router.Use(handlers.Drain(app.HTTPSDrain, app.RedirDrain))
+	if config.CanonicalHost != "" {
+		router.Use(handlers.CanonicalHost(config.canonicalAuthority(), config.CanonicalHostMode != "reject", "/health", "/readyz"))
+	}
+	if config.HTTP3 {
+		router.Use(handlers.AltSvc(config.HTTPSPort))
+	}
+	if config.DemoMode {
+		router.Use(handlers.DemoMode())
+	}
+	if app.ClientCAs != nil {
+		router.Use(handlers.ClientCert(database, publicPaths...))
+	}
+	if config.BasicAuthUser != "" {
+		router.Use(handlers.BasicAuth(config.BasicAuthUser, config.BasicAuthPass, append(publicPaths, "/logout")...))
+		router.GET("/logout", handlers.BasicAuthLogout)
+	}
+	if app.Directory != nil {
+		router.Use(handlers.LDAPAuth(app.Directory, database, append(publicPaths, "/logout")...))
+		router.GET("/logout", handlers.BasicAuthLogout)
+	}
+	if app.OIDC != nil || app.SAML != nil {
+		signer := sso.NewSigner(config.SSOSecret)
+		router.Use(handlers.SSOSession(signer, database, append(publicPaths, "/auth/")...))
+		sessionTTL := time.Duration(config.SSOSessionHours) * time.Hour
+		handlers.NewSSOHandler(database, signer, app.OIDC, app.SAML, config.SSOBaseURL, sessionTTL).RegisterRoutes(router)
+	}
+	router.Use(handlers.Viewer(database))
+	router.Use(handlers.ApartmentUUIDs(database))
+	router.Use(handlers.AdminOnly())
 
 	// Serve static files
 	router.Static("/static", config.StaticPath)
@@ -112,48 +1165,237 @@ func setupRouter(database *db.DB, config AppConfig) *gin.Engine {
 	})
 
 	// Setup API routes
-	apartmentHandler := handlers.NewApartmentHandler(database)
+	apartmentHandler := handlers.NewApartmentHandler(database, app.Storage)
 	apartmentHandler.RegisterRoutes(router)
 
+	amenityHandler := handlers.NewAmenityHandler(database)
+	amenityHandler.RegisterRoutes(router)
+
+	ratingHandler := handlers.NewRatingHandler(database)
+	ratingHandler.RegisterRoutes(router)
+
+	templateHandler := handlers.NewTemplateHandler(database)
+	templateHandler.RegisterRoutes(router)
+
+	enrichmentHandler := handlers.NewEnrichmentHandler(database, app.Enricher)
+	enrichmentHandler.RegisterRoutes(router)
+
+	commuteHandler := handlers.NewCommuteHandler(database)
+	commuteHandler.RegisterRoutes(router)
+
+	visitHandler := handlers.NewVisitHandler(database)
+	visitHandler.RegisterRoutes(router)
+
+	quota := int64(config.StorageQuotaMB) << 20
+	floorPlanHandler := handlers.NewFloorPlanHandler(database, app.Storage, app.Scanner, quota)
+	floorPlanHandler.RegisterRoutes(router)
+
+	attachmentHandler := handlers.NewAttachmentHandler(database, app.Storage, app.Scanner, handlers.UploadLimits{
+		MaxSize:         int64(config.UploadMaxSizeMB) << 20,
+		AllowedTypes:    config.UploadAllowedTypes,
+		MaxPerApartment: config.MaxAttachments,
+		Quota:           quota,
+	}, config.ExtractPhotoGPS)
+	attachmentHandler.RegisterRoutes(router)
+
+	usageHandler := handlers.NewUsageHandler(database, quota)
+	usageHandler.RegisterRoutes(router)
+
+	summaryHandler := handlers.NewSummaryHandler(database, app.Storage)
+	summaryHandler.RegisterRoutes(router)
+
+	mediaLinkHandler := handlers.NewMediaLinkHandler(database, oembed.New())
+	mediaLinkHandler.RegisterRoutes(router)
+
+	summarizeHandler := handlers.NewSummarizeHandler(database, app.LLM)
+	summarizeHandler.RegisterRoutes(router)
+
+	queryHandler := handlers.NewQueryHandler(database, app.LLM)
+	queryHandler.RegisterRoutes(router)
+
+	if config.InboundEmailToken != "" {
+		inboundHandler := handlers.NewInboundHandler(database, config.InboundEmailToken)
+		inboundHandler.RegisterRoutes(router)
+	}
+
+	if config.CaptureAPIKey != "" {
+		captureHandler := handlers.NewCaptureHandler(database, config.CaptureAPIKey)
+		captureHandler.RegisterRoutes(router)
+	}
+
+	if len(app.WebhookSources) > 0 {
+		webhookHandler := handlers.NewWebhookHandler(database, app.WebhookSources)
+		webhookHandler.RegisterRoutes(router)
+	}
+
+	userHandler := handlers.NewUserHandler(database, app.Storage)
+	userHandler.RegisterRoutes(router)
+
+	alertHandler := handlers.NewAlertHandler(database)
+	alertHandler.RegisterRoutes(router)
+
+	roomHandler := handlers.NewRoomHandler(database)
+	roomHandler.RegisterRoutes(router)
+
+	offerHandler := handlers.NewOfferHandler(database)
+	offerHandler.RegisterRoutes(router)
+
+	commentHandler := handlers.NewCommentHandler(database)
+	commentHandler.RegisterRoutes(router)
+
+	reactionHandler := handlers.NewReactionHandler(database)
+	reactionHandler.RegisterRoutes(router)
+
+	compareHandler := handlers.NewCompareHandler(database)
+	compareHandler.RegisterRoutes(router)
+
+	costHandler := handlers.NewCostHandler(database)
+	costHandler.RegisterRoutes(router)
+
+	roommateHandler := handlers.NewRoommateHandler(database)
+	roommateHandler.RegisterRoutes(router)
+
+	deadlineHandler := handlers.NewDeadlineHandler(database)
+	deadlineHandler.RegisterRoutes(router)
+
+	buildingHandler := handlers.NewBuildingHandler(database)
+	buildingHandler.RegisterRoutes(router)
+
+	boardHandler := handlers.NewBoardHandler(database)
+	boardHandler.RegisterRoutes(router)
+
+	adminHandler := handlers.NewAdminHandler(app.Scheduler)
+	adminHandler.RegisterRoutes(router)
+
+	exportHandler := handlers.NewExportHandler(app.Exporters)
+	exportHandler.RegisterRoutes(router)
+
+	calendarHandler := handlers.NewCalendarHandler(database, app.Calendar, config.GoogleCalendarID, config.GoogleRedirectURL)
+	calendarHandler.RegisterRoutes(router)
+
+	syncHandler := handlers.NewSyncHandler(database)
+	syncHandler.RegisterRoutes(router)
+
+	activityHandler := handlers.NewActivityHandler(database)
+	activityHandler.RegisterRoutes(router)
+
+	triggerHandler := handlers.NewTriggerHandler(database)
+	triggerHandler.RegisterRoutes(router)
+
+	undoHandler := handlers.NewUndoHandler(database, time.Duration(config.UndoWindowMinutes)*time.Minute)
+	undoHandler.RegisterRoutes(router)
+
+	retentionHandler := handlers.NewRetentionHandler(database, config.retentionPolicy())
+	retentionHandler.RegisterRoutes(router)
+
+	auditHandler := handlers.NewAuditHandler(database)
+	auditHandler.RegisterRoutes(router)
+
+	if config.SeedAPI {
+		seedHandler := handlers.NewSeedHandler(database)
+		seedHandler.RegisterRoutes(router)
+	}
+
 	// Add health check endpoint
 	router.GET("/health", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{
 			"status": "up",
 			"time":   time.Now().Unix(),
+			"demo":   config.DemoMode,
 		})
 	})
 
+	// Readiness: the database, and how calls to integrations have been going
+	router.GET("/readyz", handlers.Ready(database))
+
+	// Expose runtime metrics (cache hit rates, etc.) as JSON
+	router.GET("/debug/vars", gin.WrapH(expvar.Handler()))
+
 	return router
 }
 
 // setupServers configures the HTTP and HTTPS servers
 func setupServers(app *App) {
+	app.Cert = new(certificate)
+
 	// Configure TLS settings for HTTPS server
 	app.HTTPSrv = &http.Server{
 		Addr:      ":" + app.Config.HTTPSPort,
 		Handler:   app.Router,
-		TLSConfig: getTLSConfig(),
+		TLSConfig: getTLSConfig(app.ClientCAs, app.Cert),
+		ConnState: app.HTTPSDrain.ConnState,
+		Protocols: protocols(true, true, false),
 	}
+	app.Config.setTimeouts(app.HTTPSrv)
 
 	// Setup HTTP server to redirect to HTTPS
 	app.RedirSrv = &http.Server{
 		Addr: ":" + app.Config.HTTPPort,
-		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Handler: app.RedirDrain.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Never echo an arbitrary Host when there's a canonical one
+			if app.Config.CanonicalHost != "" {
+				http.Redirect(w, r, handlers.CanonicalURL(app.Config.canonicalAuthority(), r), http.StatusMovedPermanently)
+				return
+			}
 			host := strings.Split(r.Host, ":")[0]
 			target := "https://" + host + ":" + app.Config.HTTPSPort + r.URL.Path
 			if len(r.URL.RawQuery) > 0 {
 				target += "?" + r.URL.RawQuery
 			}
 			http.Redirect(w, r, target, http.StatusMovedPermanently)
-		}),
+		})),
+		ConnState: app.RedirDrain.ConnState,
+	}
+	app.Config.setTimeouts(app.RedirSrv)
+	if app.Config.BehindProxy {
+		// The proxy has done TLS, so serve the app itself
+		app.RedirSrv.Handler = app.Router
+		app.RedirSrv.Protocols = protocols(true, false, app.Config.H2C)
+	}
+
+	if app.Config.HTTP3 {
+		app.H3Srv = &http3.Server{
+			Addr:      ":" + app.Config.HTTPSPort,
+			Handler:   app.Router,
+			TLSConfig: http3.ConfigureTLSConfig(getTLSConfig(app.ClientCAs, app.Cert)),
+		}
 	}
 }
 
+// setTimeouts sets a server's connection timeouts. Routes allowed longer
+// than the read and write timeouts move the deadlines themselves; see
+// handlers.Timeout.
+func (c AppConfig) setTimeouts(srv *http.Server) {
+	srv.ReadHeaderTimeout = time.Duration(c.HTTPReadHeaderTimeoutSeconds) * time.Second
+	srv.ReadTimeout = time.Duration(c.HTTPReadTimeoutSeconds) * time.Second
+	srv.WriteTimeout = time.Duration(c.HTTPWriteTimeoutSeconds) * time.Second
+	srv.IdleTimeout = time.Duration(c.HTTPIdleTimeoutSeconds) * time.Second
+}
+
+// protocols returns the HTTP versions a server accepts
+func protocols(http1, http2, h2c bool) *http.Protocols {
+	p := new(http.Protocols)
+	p.SetHTTP1(http1)
+	p.SetHTTP2(http2)
+	p.SetUnencryptedHTTP2(h2c)
+	return p
+}
+
 // startServers starts both HTTP and HTTPS servers
 func startServers(app *App) {
-	// Run HTTP server in a goroutine for redirects
+	// Every listener serves the same certificate, reloaded on SIGHUP
+	if _, err := app.Cert.load(app.Config.CertFile, app.Config.KeyFile); err != nil {
+		log.Fatal().Err(err).Msg("Failed to load certificate")
+	}
+
+	// Run HTTP server in a goroutine, for redirects or behind a proxy
 	go func() {
-		log.Info().Str("port", app.Config.HTTPPort).Msg("Starting HTTP server (for redirects)")
+		purpose := "for redirects"
+		if app.Config.BehindProxy {
+			purpose = "behind a proxy"
+		}
+		log.Info().Str("port", app.Config.HTTPPort).Bool("h2c", app.Config.H2C && app.Config.BehindProxy).
+			Msg("Starting HTTP server (" + purpose + ")")
 		if err := app.RedirSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Error().Err(err).Msg("HTTP server failed")
 		}
@@ -162,10 +1404,22 @@ func startServers(app *App) {
 	// Run HTTPS server in a goroutine
 	go func() {
 		log.Info().Str("port", app.Config.HTTPSPort).Msg("Starting secure server (HTTPS)")
-		if err := app.HTTPSrv.ListenAndServeTLS(app.Config.CertFile, app.Config.KeyFile); err != nil && err != http.ErrServerClosed {
+		if err := app.HTTPSrv.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
 			log.Fatal().Err(err).Msg("Failed to start secure server")
 		}
 	}()
+
+	// Run the experimental HTTP/3 server in a goroutine. quic-go's
+	// ListenAndServeTLS would drop the client CAs, so it gets the
+	// certificate from the TLS config too.
+	if app.H3Srv != nil {
+		go func() {
+			log.Info().Str("port", app.Config.HTTPSPort).Msg("Starting HTTP/3 server (experimental)")
+			if err := app.H3Srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Error().Err(err).Msg("HTTP/3 server failed")
+			}
+		}()
+	}
 }
 
 // handleShutdown waits for termination signal and performs graceful shutdown
@@ -176,23 +1430,219 @@ func handleShutdown(app *App) {
 	<-quit
 	log.Info().Msg("Shutting down servers...")
 
+	// Stop scheduling new background work first
+	if app.Scheduler != nil {
+		app.Scheduler.Stop()
+	}
+
 	// Give servers 5 seconds to shutdown gracefully
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	// Shutdown HTTPS server
 	log.Info().Msg("Shutting down HTTPS server...")
-	if err := app.HTTPSrv.Shutdown(ctx); err != nil {
-		log.Error().Err(err).Msg("HTTPS server forced to shutdown")
-	}
+	httpsReport := app.HTTPSDrain.Shutdown(ctx, app.HTTPSrv)
+	logShutdown(httpsReport)
 
 	// Shutdown HTTP server
 	log.Info().Msg("Shutting down HTTP server...")
-	if err := app.RedirSrv.Shutdown(ctx); err != nil {
-		log.Error().Err(err).Msg("HTTP server forced to shutdown")
+	redirReport := app.RedirDrain.Shutdown(ctx, app.RedirSrv)
+	logShutdown(redirReport)
+
+	if app.H3Srv != nil {
+		log.Info().Msg("Shutting down HTTP/3 server...")
+		if err := app.H3Srv.Shutdown(ctx); err != nil {
+			log.Error().Err(err).Msg("HTTP/3 server forced to shutdown")
+			app.H3Srv.Close()
+		}
+	}
+
+	// Send any errors still being reported
+	app.Sentry.Flush(ctx)
+
+	if httpsReport.Graceful && redirReport.Graceful {
+		log.Info().Msg("Servers exited properly")
+	} else {
+		log.Warn().
+			Int("cut_off", httpsReport.CutOff+redirReport.CutOff).
+			Int("forced_conns", httpsReport.ForcedConns+redirReport.ForcedConns).
+			Msg("Servers exited with requests cut off")
+	}
+}
+
+// handleReload rereads the configuration on SIGHUP and applies what can
+// change without a restart
+func handleReload(app *App) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	for range hup {
+		if err := loadEnvFile(os.Getenv("CONFIG_FILE")); err != nil {
+			log.Error().Err(err).Msg("Failed to reload config file")
+			continue
+		}
+		reloadConfig(app, loadConfig())
+	}
+}
+
+// reloadConfig applies the reloadable settings of next: the log level, the
+// slow query threshold, the geocoder rate limit, and the certificate, which
+// is read again even when its paths haven't changed. Other settings only
+// take effect on restart, and are logged as such.
+func reloadConfig(app *App, next AppConfig) []string {
+	prev := app.Config
+	var changed []string
+
+	if next.LogLevel != prev.LogLevel {
+		if err := setLogLevel(next.LogLevel); err != nil {
+			log.Error().Err(err).Msg("Invalid LOG_LEVEL, keeping the old level")
+			next.LogLevel = prev.LogLevel
+		} else {
+			changed = append(changed, "LOG_LEVEL")
+		}
+	}
+	if next.SlowQueryMS != prev.SlowQueryMS {
+		app.DB.LogSlowQueries(time.Duration(next.SlowQueryMS) * time.Millisecond)
+		changed = append(changed, "SLOW_QUERY_MS")
 	}
+	if next.DBStatementTimeoutMS != prev.DBStatementTimeoutMS {
+		app.DB.SetStatementTimeout(time.Duration(next.DBStatementTimeoutMS) * time.Millisecond)
+		changed = append(changed, "DB_STATEMENT_TIMEOUT_MS")
+	}
+	if next.GeocoderRateLimit != prev.GeocoderRateLimit {
+		if app.Enricher != nil {
+			app.Enricher.SetGeocodeRate(next.GeocoderRateLimit)
+		}
+		changed = append(changed, "GEOCODER_RATE_LIMIT")
+	}
+	if app.Cert != nil {
+		renewed, err := app.Cert.load(next.CertFile, next.KeyFile)
+		switch {
+		case err != nil:
+			log.Error().Err(err).Msg("Failed to reload certificate, keeping the old one")
+			next.CertFile, next.KeyFile = prev.CertFile, prev.KeyFile
+		case renewed:
+			changed = append(changed, "certificate")
+		}
+	}
+
+	// Everything else is kept as it was until the next restart
+	var restart []string
+	reloadable := map[string]bool{"LogLevel": true, "SlowQueryMS": true, "DBStatementTimeoutMS": true, "GeocoderRateLimit": true, "CertFile": true, "KeyFile": true}
+	pv, nv := reflect.ValueOf(&prev).Elem(), reflect.ValueOf(&next).Elem()
+	for i := range pv.NumField() {
+		name := pv.Type().Field(i).Name
+		if reloadable[name] {
+			pv.Field(i).Set(nv.Field(i))
+		} else if !reflect.DeepEqual(pv.Field(i).Interface(), nv.Field(i).Interface()) {
+			restart = append(restart, name)
+		}
+	}
+	app.Config = prev
+
+	event := log.Info().Strs("changed", changed)
+	if len(restart) > 0 {
+		event = log.Warn().Strs("changed", changed).Strs("needs_restart", restart)
+	}
+	event.Msg("Configuration reloaded")
+	return changed
+}
+
+// certificate is the TLS certificate the servers present, swapped in place
+// when it's reloaded
+type certificate struct {
+	mu   sync.RWMutex
+	cert *tls.Certificate
+}
+
+// load reads the certificate and key, reporting whether they differ from
+// the ones already loaded. On error the old certificate is kept.
+func (c *certificate) load(certFile, keyFile string) (bool, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return false, fmt.Errorf("failed to load certificate: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	renewed := c.cert == nil || !bytes.Equal(c.cert.Certificate[0], cert.Certificate[0])
+	c.cert = &cert
+	return renewed, nil
+}
+
+// get is the tls.Config GetCertificate callback
+func (c *certificate) get(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.cert == nil {
+		return nil, errors.New("no certificate loaded")
+	}
+	return c.cert, nil
+}
 
-	log.Info().Msg("Servers exited properly")
+// logShutdown reports how a listener's shutdown went: the requests in
+// flight when it began, how many of them completed or were cut off, and
+// how its connections were closed
+func logShutdown(report drain.Report) {
+	event := log.Info()
+	if !report.Graceful {
+		event = log.Error().Err(report.Err)
+	}
+	event.
+		Str("listener", report.Listener).
+		Bool("graceful", report.Graceful).
+		Int("in_flight", report.InFlight).
+		Int("completed", report.Completed).
+		Int("cut_off", report.CutOff).
+		Int("open_conns", report.OpenConns).
+		Int("active_conns", report.ActiveConns).
+		Int("forced_conns", report.ForcedConns).
+		Msg("Listener shut down")
+}
+
+// setLogLevel sets the lowest level logged
+func setLogLevel(name string) error {
+	level, err := zerolog.ParseLevel(strings.ToLower(name))
+	if err != nil || level == zerolog.NoLevel {
+		return fmt.Errorf("unknown log level %q", name)
+	}
+	zerolog.SetGlobalLevel(level)
+	return nil
+}
+
+// loadEnvFile sets the environment variables in an env file of KEY=VALUE
+// lines, for settings that should be reloadable: the environment of a
+// running process can't be changed from outside it. Blank lines and lines
+// starting with # are skipped, and values may be quoted. Values in the file
+// take precedence over the environment. An empty path does nothing.
+func loadEnvFile(path string) error {
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	for n, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return fmt.Errorf("%s:%d: expected KEY=VALUE", path, n+1)
+		}
+		value = strings.TrimSpace(value)
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		} else if len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'' {
+			value = value[1 : len(value)-1]
+		}
+		if err := os.Setenv(key, value); err != nil {
+			return fmt.Errorf("%s:%d: %w", path, n+1, err)
+		}
+	}
+	return nil
 }
 
 // getEnv returns environment variable value or fallback if not set
@@ -203,9 +1653,72 @@ func getEnv(key, fallback string) string {
 	return fallback
 }
 
-// getTLSConfig returns TLS configuration with secure defaults
-func getTLSConfig() *tls.Config {
-	return &tls.Config{
+// getEnvInt returns an integer environment variable, or fallback if it is
+// unset or not a valid integer
+func getEnvInt(key string, fallback int) int {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Warn().Str("key", key).Str("value", value).Msg("Ignoring invalid integer environment variable")
+		return fallback
+	}
+	return n
+}
+
+// getEnvFloat returns a floating-point environment variable, or fallback if
+// it is unset or not a valid number
+func getEnvFloat(key string, fallback float64) float64 {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Warn().Str("key", key).Str("value", value).Msg("Ignoring invalid numeric environment variable")
+		return fallback
+	}
+	return f
+}
+
+// getEnvBool returns a boolean environment variable, or fallback if it is
+// unset or not a valid boolean
+func getEnvBool(key string, fallback bool) bool {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Warn().Str("key", key).Str("value", value).Msg("Ignoring invalid boolean environment variable")
+		return fallback
+	}
+	return b
+}
+
+// getEnvList returns a comma-separated environment variable as a list, or
+// fallback if it is unset
+func getEnvList(key string, fallback []string) []string {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback
+	}
+	list := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.ToLower(strings.TrimSpace(item)); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// getTLSConfig returns TLS configuration with secure defaults, serving
+// cert. With client CAs, client certificates are verified against them;
+// requiring one is left to handlers.ClientCert, since a few routes don't.
+func getTLSConfig(clientCAs *x509.CertPool, cert *certificate) *tls.Config {
+	config := &tls.Config{
 		MinVersion: tls.VersionTLS12,
 		CurvePreferences: []tls.CurveID{
 			tls.CurveP521,
@@ -222,5 +1735,11 @@ func getTLSConfig() *tls.Config {
 			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
 			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
 		},
+		GetCertificate: cert.get,
+	}
+	if clientCAs != nil {
+		config.ClientCAs = clientCAs
+		config.ClientAuth = tls.VerifyClientCertIfGiven
 	}
+	return config
 }
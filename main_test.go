@@ -6,12 +6,15 @@ import (
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"syscall"
 	"testing"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/mojotx/apt-eval/db"
+	"github.com/mojotx/apt-eval/handlers"
+	"github.com/mojotx/apt-eval/scheduler"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/stretchr/testify/assert"
@@ -19,7 +22,7 @@ import (
 
 func TestSetupLogging(t *testing.T) {
 	// Call the setup function
-	setupLogging()
+	assert.NoError(t, setupLogging(AppConfig{}))
 
 	// Verify time format was set correctly
 	assert.Equal(t, zerolog.TimeFormatUnix, zerolog.TimeFieldFormat, "TimeFieldFormat should be set to Unix format")
@@ -31,6 +34,34 @@ func TestSetupLogging(t *testing.T) {
 
 	// Note: Testing the actual ConsoleWriter configuration would require
 	// accessing internal logger state which is not easily testable
+
+	assert.Error(t, setupLogging(AppConfig{LogFormat: "xml"}), "unknown formats are rejected")
+}
+
+func TestSetupLoggingJSON(t *testing.T) {
+	defer setupLogging(AppConfig{})
+	defer zerolog.SetGlobalLevel(zerolog.TraceLevel)
+
+	r, w, err := os.Pipe()
+	assert.NoError(t, err)
+	stderr := os.Stderr
+	os.Stderr = w
+	err = setupLogging(AppConfig{LogFormat: "json", LogCaller: true, LogLevel: "info"})
+	os.Stderr = stderr
+	assert.NoError(t, err)
+
+	log.Debug().Msg("hidden")
+	log.Info().Str("listener", "https").Msg("Started")
+	w.Close()
+
+	var entry map[string]any
+	assert.NoError(t, json.NewDecoder(r).Decode(&entry), "one JSON object per line")
+	assert.Equal(t, "info", entry["level"])
+	assert.Equal(t, "Started", entry["message"])
+	assert.Equal(t, "https", entry["listener"])
+	assert.Contains(t, entry["caller"], "main_test.go")
+	_, err = time.Parse(time.RFC3339Nano, entry["time"].(string))
+	assert.NoError(t, err)
 }
 func TestLoadConfig(t *testing.T) {
 	// Test default values when env vars not set
@@ -41,6 +72,7 @@ func TestLoadConfig(t *testing.T) {
 	assert.Equal(t, "./certs/wildcard.crt", defaultConfig.CertFile, "Default CertFile should be './certs/wildcard.crt'")
 	assert.Equal(t, "./certs/wildcard.key", defaultConfig.KeyFile, "Default KeyFile should be './certs/wildcard.key'")
 	assert.Equal(t, "./static", defaultConfig.StaticPath, "Default StaticPath should be './static'")
+	assert.Equal(t, 1, defaultConfig.DBMaxOpenConns, "SQLite gets a single connection by default")
 
 	// Test with environment variables set
 	os.Setenv("DATA_DIR", "/test/data")
@@ -77,12 +109,16 @@ func TestInitApp(t *testing.T) {
 		CertFile:   "./certs/wildcard.crt",
 		KeyFile:    "./certs/wildcard.key",
 		StaticPath: "./static",
+
+		DBMaxOpenConns: 2,
+		DBMaxIdleConns: 2,
 	}
 
 	app, err := initApp(config)
 	assert.NoError(t, err, "initApp should not return an error")
 	assert.NotNil(t, app, "app should not be nil")
 	defer app.DB.Close()
+	assert.Equal(t, 2, app.DB.Stats().MaxOpenConnections, "pool should be sized from the config")
 
 	// Verify app components are initialized
 	assert.NotNil(t, app.DB, "DB should be initialized")
@@ -142,7 +178,7 @@ func TestSetupRouter(t *testing.T) {
 	}
 
 	// Test router setup
-	router := setupRouter(database, config)
+	router := setupRouter(&App{DB: database, Scheduler: scheduler.New(), Config: config})
 	assert.NotNil(t, router, "Router should be initialized")
 
 	// Test health check endpoint
@@ -179,6 +215,21 @@ func TestSetupRouter(t *testing.T) {
 
 	assert.Equal(t, http.StatusOK, w.Code, "Static file should return 200 OK")
 	assert.Equal(t, testContent, w.Body.String(), "Static file content should match")
+
+	// Without directory sign-in there are no admins
+	for _, target := range []string{"/api/admin/tasks", "/api/admin/retention", "/api/admin/audit", "/api/admin/audit.csv"} {
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("GET", target, nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Code, "%s should be for admins only", target)
+	}
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/api/admin/seed", strings.NewReader(`{"count":10}`))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code, "seeding the live database should be for admins only")
+	count, err := database.CountApartments(db.ListOptions{})
+	assert.NoError(t, err)
+	assert.Zero(t, count)
 }
 func TestSetupServers(t *testing.T) {
 	// Create a minimal app instance for testing
@@ -243,6 +294,83 @@ func TestSetupServers(t *testing.T) {
 	location = w.Header().Get("Location")
 	assert.Equal(t, expectedLocation, location, "Location header should be correct for host without port")
 }
+func TestSetupServersBehindProxy(t *testing.T) {
+	config := AppConfig{
+		HTTPPort:    "8080",
+		HTTPSPort:   "8443",
+		BehindProxy: true,
+		H2C:         true,
+		HTTP3:       true,
+	}
+
+	app := &App{
+		Router: gin.New(),
+		Config: config,
+	}
+
+	setupServers(app)
+
+	// HTTP/2 is on over TLS, but never in cleartext there
+	assert.True(t, app.HTTPSrv.Protocols.HTTP2(), "HTTPS server should accept HTTP/2")
+	assert.False(t, app.HTTPSrv.Protocols.UnencryptedHTTP2(), "HTTPS server shouldn't accept h2c")
+
+	// Behind a proxy, the HTTP server serves the app, with h2c
+	assert.Equal(t, app.Router, app.RedirSrv.Handler, "HTTP server handler should be the router")
+	assert.True(t, app.RedirSrv.Protocols.UnencryptedHTTP2(), "HTTP server should accept h2c")
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "http://example.com:8080/missing", nil)
+	app.RedirSrv.Handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code, "HTTP server shouldn't redirect behind a proxy")
+
+	assert.NotNil(t, app.H3Srv, "H3Srv should be initialized")
+	assert.Equal(t, ":8443", app.H3Srv.Addr, "HTTP/3 server addr should be ':8443'")
+}
+func TestSetupServersCanonicalHost(t *testing.T) {
+	config := AppConfig{
+		HTTPPort:      "8080",
+		HTTPSPort:     "8443",
+		CanonicalHost: "apt.example.com",
+	}
+
+	app := &App{
+		Router: gin.New(),
+		Config: config,
+	}
+
+	setupServers(app)
+
+	// The redirect goes to the canonical host, whatever Host was sent
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "http://192.168.1.20:8080/test/path?query=value", nil)
+	req.Host = "192.168.1.20:8080"
+	app.RedirSrv.Handler.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusMovedPermanently, w.Code, "Redirect should return 301 status")
+	assert.Equal(t, "https://apt.example.com:8443/test/path?query=value", w.Header().Get("Location"), "Redirect should use the canonical host")
+}
+func TestSetupServersTimeouts(t *testing.T) {
+	config := loadConfig()
+	app := &App{
+		Router: gin.New(),
+		Config: config,
+	}
+
+	setupServers(app)
+
+	// Both listeners drop slow and idle clients
+	for _, srv := range []*http.Server{app.HTTPSrv, app.RedirSrv} {
+		assert.Equal(t, 10*time.Second, srv.ReadHeaderTimeout, "Server should time out slow headers")
+		assert.Equal(t, 60*time.Second, srv.ReadTimeout)
+		assert.Equal(t, 60*time.Second, srv.WriteTimeout)
+		assert.Equal(t, 120*time.Second, srv.IdleTimeout)
+	}
+
+	// The default route timeouts are valid
+	routes, err := handlers.ParseRouteTimeouts(config.RequestTimeouts)
+	assert.NoError(t, err)
+	assert.Equal(t, 300*time.Second, routes["/api/exports"])
+}
 func TestStartServers(t *testing.T) {
 	// Create a minimal app instance for testing
 	config := AppConfig{
@@ -365,3 +493,61 @@ func TestHandleShutdownSIGTERM(t *testing.T) {
 
 	assert.Less(t, elapsed, 2*time.Second, "SIGTERM shutdown should complete in reasonable time")
 }
+
+func TestLoadEnvFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "apt-eval.env")
+	content := "# Reloaded on SIGHUP\n\nLOG_LEVEL=debug\nexport SLOW_QUERY_MS = 50\nGEOCODER_URL=\"http://localhost:8088/search?q=a b\"\n"
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	t.Cleanup(func() {
+		os.Unsetenv("LOG_LEVEL")
+		os.Unsetenv("SLOW_QUERY_MS")
+		os.Unsetenv("GEOCODER_URL")
+	})
+
+	assert.NoError(t, loadEnvFile(path))
+	assert.Equal(t, "debug", os.Getenv("LOG_LEVEL"))
+	assert.Equal(t, "50", os.Getenv("SLOW_QUERY_MS"))
+	assert.Equal(t, "http://localhost:8088/search?q=a b", os.Getenv("GEOCODER_URL"))
+
+	assert.NoError(t, loadEnvFile(""), "no config file is fine")
+	assert.NoError(t, os.WriteFile(path, []byte("LOG_LEVEL\n"), 0600))
+	assert.ErrorContains(t, loadEnvFile(path), ":1: expected KEY=VALUE")
+}
+
+func TestReloadConfig(t *testing.T) {
+	database, err := db.New(t.TempDir())
+	assert.NoError(t, err)
+	defer database.Close()
+	defer zerolog.SetGlobalLevel(zerolog.TraceLevel)
+
+	config := AppConfig{LogLevel: "info", SlowQueryMS: 200, HTTPPort: "8080"}
+	app := &App{DB: database, Config: config}
+
+	next := config
+	next.LogLevel = "warn"
+	next.SlowQueryMS = 50
+	next.HTTPPort = "9090"
+	changed := reloadConfig(app, next)
+	assert.Equal(t, []string{"LOG_LEVEL", "SLOW_QUERY_MS"}, changed)
+	assert.Equal(t, zerolog.WarnLevel, zerolog.GlobalLevel())
+	assert.Equal(t, 50, app.Config.SlowQueryMS)
+	assert.Equal(t, "8080", app.Config.HTTPPort, "the port needs a restart")
+
+	next.LogLevel = "loud"
+	assert.Empty(t, reloadConfig(app, next))
+	assert.Equal(t, "warn", app.Config.LogLevel, "an invalid level is ignored")
+}
+
+func TestSetupLoggingFile(t *testing.T) {
+	defer setupLogging(AppConfig{})
+
+	path := filepath.Join(t.TempDir(), "logs", "apt-eval.log")
+	assert.NoError(t, setupLogging(AppConfig{LogFormat: "json", LogFile: path, LogFileMaxSizeMB: 1}))
+	log.Info().Str("listener", "https").Msg("Started")
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	var entry map[string]any
+	assert.NoError(t, json.Unmarshal(data, &entry))
+	assert.Equal(t, "Started", entry["message"])
+}
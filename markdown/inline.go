@@ -0,0 +1,208 @@
+package markdown
+
+import (
+	"html"
+	"net/url"
+	"strings"
+)
+
+type spanKind int
+
+const (
+	text spanKind = iota
+	codeSpan
+	strong
+	emphasis
+	strike
+	link
+)
+
+// span is an inline element of a line
+type span struct {
+	kind     spanKind
+	text     string // Content of text and code spans
+	url      string // Target of links
+	children []span
+}
+
+// pairs are the delimiters of the emphasis kinds, doubled ones first
+var pairs = []struct {
+	delim string
+	kind  spanKind
+}{
+	{"**", strong}, {"__", strong}, {"~~", strike}, {"*", emphasis}, {"_", emphasis},
+}
+
+// parseInline splits a line into spans
+func parseInline(s string) []span {
+	var spans []span
+	var plain strings.Builder
+	flush := func() {
+		if plain.Len() > 0 {
+			spans = append(spans, span{kind: text, text: plain.String()})
+			plain.Reset()
+		}
+	}
+
+	for i := 0; i < len(s); {
+		rest := s[i:]
+		switch {
+		case rest[0] == '\\' && len(rest) > 1 && strings.IndexByte("\\`*_~[]()#>-+.!", rest[1]) >= 0:
+			plain.WriteByte(rest[1])
+			i += 2
+			continue
+
+		case rest[0] == '`':
+			if end := strings.IndexByte(rest[1:], '`'); end >= 0 {
+				flush()
+				spans = append(spans, span{kind: codeSpan, text: rest[1 : end+1]})
+				i += end + 2
+				continue
+			}
+
+		case rest[0] == '[':
+			if label, target, n, ok := linkAt(rest); ok {
+				flush()
+				spans = append(spans, span{kind: link, url: target, children: parseInline(label)})
+				i += n
+				continue
+			}
+
+		case strings.HasPrefix(rest, "http://") || strings.HasPrefix(rest, "https://"):
+			if i == 0 || !isWordByte(s[i-1]) {
+				end := strings.IndexAny(rest, " \t<>\"")
+				if end < 0 {
+					end = len(rest)
+				}
+				target := strings.TrimRight(rest[:end], ".,;:!?)'")
+				flush()
+				spans = append(spans, span{kind: link, url: target, children: []span{{kind: text, text: target}}})
+				i += len(target)
+				continue
+			}
+		}
+
+		if kind, inner, n, ok := emphasisAt(s, i); ok {
+			flush()
+			spans = append(spans, span{kind: kind, children: parseInline(inner)})
+			i += n
+			continue
+		}
+
+		plain.WriteByte(rest[0])
+		i++
+	}
+	flush()
+	return spans
+}
+
+// linkAt matches a [label](url) link at the start of s, returning its
+// parts and length
+func linkAt(s string) (label, target string, n int, ok bool) {
+	mid := strings.Index(s, "](")
+	if mid < 0 {
+		return "", "", 0, false
+	}
+	end := strings.IndexByte(s[mid+2:], ')')
+	if end < 0 {
+		return "", "", 0, false
+	}
+	return s[1:mid], strings.TrimSpace(s[mid+2 : mid+2+end]), mid + 3 + end, true
+}
+
+// emphasisAt matches emphasis opening at s[i], returning its kind,
+// content, and length. Content can't start or end with a space, and
+// underscores only count at word boundaries so snake_case stays as is.
+func emphasisAt(s string, i int) (kind spanKind, inner string, n int, ok bool) {
+	for _, p := range pairs {
+		if !strings.HasPrefix(s[i:], p.delim) {
+			continue
+		}
+		if p.delim[0] == '_' && i > 0 && isWordByte(s[i-1]) {
+			return 0, "", 0, false
+		}
+		body := s[i+len(p.delim):]
+		end := strings.Index(body, p.delim)
+		if end <= 0 || body[0] == ' ' || body[end-1] == ' ' {
+			continue
+		}
+		after := i + len(p.delim) + end + len(p.delim)
+		if p.delim[0] == '_' && after < len(s) && isWordByte(s[after]) {
+			continue
+		}
+		return p.kind, body[:end], after - i, true
+	}
+	return 0, "", 0, false
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+// safeURL reports whether a link target may be used in HTML: http, https,
+// and mailto links are, while javascript: and data: URLs and the like
+// aren't
+func safeURL(target string) bool {
+	u, err := url.Parse(target)
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https":
+		return u.Host != ""
+	case "mailto":
+		return true
+	}
+	return false
+}
+
+func escape(s string) string {
+	return html.EscapeString(s)
+}
+
+// spansHTML renders spans as HTML
+func spansHTML(spans []span) string {
+	var b strings.Builder
+	for _, sp := range spans {
+		switch sp.kind {
+		case text:
+			b.WriteString(escape(sp.text))
+		case codeSpan:
+			b.WriteString("<code>" + escape(sp.text) + "</code>")
+		case strong:
+			b.WriteString("<strong>" + spansHTML(sp.children) + "</strong>")
+		case emphasis:
+			b.WriteString("<em>" + spansHTML(sp.children) + "</em>")
+		case strike:
+			b.WriteString("<del>" + spansHTML(sp.children) + "</del>")
+		case link:
+			if !safeURL(sp.url) {
+				b.WriteString(spansHTML(sp.children))
+				continue
+			}
+			b.WriteString(`<a href="` + escape(sp.url) + `" rel="nofollow noopener noreferrer">` +
+				spansHTML(sp.children) + "</a>")
+		}
+	}
+	return b.String()
+}
+
+// spansText renders spans as plain text
+func spansText(spans []span) string {
+	var b strings.Builder
+	for _, sp := range spans {
+		switch sp.kind {
+		case text, codeSpan:
+			b.WriteString(sp.text)
+		case link:
+			label := spansText(sp.children)
+			b.WriteString(label)
+			if label != sp.url && safeURL(sp.url) {
+				b.WriteString(" (" + strings.TrimPrefix(sp.url, "mailto:") + ")")
+			}
+		default:
+			b.WriteString(spansText(sp.children))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,222 @@
+// Package markdown renders the Markdown used in apartment notes: headings,
+// paragraphs, bulleted, numbered, and task lists, block quotes, code, and
+// inline emphasis, code, and links. It's a practical subset rather than
+// full CommonMark. HTML in the source is shown as text, never passed
+// through, so the output is safe to embed in a page.
+package markdown
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type blockKind int
+
+const (
+	paragraph blockKind = iota
+	heading
+	list
+	quote
+	code
+	rule
+)
+
+// block is a top-level element of a document
+type block struct {
+	kind    blockKind
+	level   int      // Heading level
+	ordered bool     // Numbered list
+	start   int      // Number of a numbered list's first item
+	lines   []string // Text of paragraphs, headings, quotes, and code
+	items   []item
+}
+
+// item is an entry of a list
+type item struct {
+	task bool // Task list item, with a checkbox
+	done bool // Checked task
+	text string
+}
+
+var (
+	headingLine = regexp.MustCompile(`^(#{1,6})\s+(.*?)(?:\s+#+)?\s*$`)
+	ruleLine    = regexp.MustCompile(`^\s*(?:\*\s*){3,}$|^\s*(?:-\s*){3,}$|^\s*(?:_\s*){3,}$`)
+	listLine    = regexp.MustCompile(`^\s*(?:([-*+])|(\d{1,9})[.)])\s+(.*)$`)
+	taskText    = regexp.MustCompile(`^\[([ xX])\]\s+(.*)$`)
+	quoteLine   = regexp.MustCompile(`^\s*>\s?(.*)$`)
+)
+
+// parse splits a document into blocks
+func parse(src string) []block {
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+
+	var blocks []block
+	var cur *block
+	closeBlock := func() {
+		if cur != nil {
+			blocks = append(blocks, *cur)
+			cur = nil
+		}
+	}
+
+	for _, line := range strings.Split(src, "\n") {
+		if cur != nil && cur.kind == code {
+			if strings.HasPrefix(strings.TrimSpace(line), "```") {
+				closeBlock()
+			} else {
+				cur.lines = append(cur.lines, line)
+			}
+			continue
+		}
+
+		if strings.TrimSpace(line) == "" {
+			closeBlock()
+			continue
+		}
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			closeBlock()
+			cur = &block{kind: code}
+			continue
+		}
+		if m := headingLine.FindStringSubmatch(line); m != nil {
+			closeBlock()
+			blocks = append(blocks, block{kind: heading, level: len(m[1]), lines: []string{m[2]}})
+			continue
+		}
+		if ruleLine.MatchString(line) {
+			closeBlock()
+			blocks = append(blocks, block{kind: rule})
+			continue
+		}
+		if m := listLine.FindStringSubmatch(line); m != nil {
+			ordered := m[1] == ""
+			if cur == nil || cur.kind != list || cur.ordered != ordered {
+				closeBlock()
+				cur = &block{kind: list, ordered: ordered}
+				if ordered {
+					cur.start, _ = strconv.Atoi(m[2])
+				}
+			}
+			it := item{text: m[3]}
+			if t := taskText.FindStringSubmatch(m[3]); t != nil {
+				it = item{task: true, done: t[1] != " ", text: t[2]}
+			}
+			cur.items = append(cur.items, it)
+			continue
+		}
+		if m := quoteLine.FindStringSubmatch(line); m != nil {
+			if cur == nil || cur.kind != quote {
+				closeBlock()
+				cur = &block{kind: quote}
+			}
+			cur.lines = append(cur.lines, m[1])
+			continue
+		}
+
+		// An indented line continues the last list item; anything else
+		// continues or starts a paragraph
+		if cur != nil && cur.kind == list && (line[0] == ' ' || line[0] == '\t') {
+			last := &cur.items[len(cur.items)-1]
+			last.text += " " + strings.TrimSpace(line)
+			continue
+		}
+		if cur == nil || cur.kind != paragraph {
+			closeBlock()
+			cur = &block{kind: paragraph}
+		}
+		cur.lines = append(cur.lines, strings.TrimSpace(line))
+	}
+	closeBlock()
+	return blocks
+}
+
+// HTML renders Markdown as HTML. Line breaks within a paragraph are kept,
+// as people expect of notes, and links go only to http, https, and mailto
+// URLs.
+func HTML(src string) string {
+	var b strings.Builder
+	for _, bl := range parse(src) {
+		switch bl.kind {
+		case paragraph:
+			b.WriteString("<p>")
+			for i, line := range bl.lines {
+				if i > 0 {
+					b.WriteString("<br>\n")
+				}
+				b.WriteString(spansHTML(parseInline(line)))
+			}
+			b.WriteString("</p>\n")
+		case heading:
+			tag := "h" + strconv.Itoa(bl.level)
+			b.WriteString("<" + tag + ">" + spansHTML(parseInline(bl.lines[0])) + "</" + tag + ">\n")
+		case list:
+			tag := "ul"
+			open := "<ul>"
+			if bl.ordered {
+				tag = "ol"
+				open = "<ol>"
+				if bl.start != 1 {
+					open = `<ol start="` + strconv.Itoa(bl.start) + `">`
+				}
+			}
+			b.WriteString(open + "\n")
+			for _, it := range bl.items {
+				switch {
+				case it.task && it.done:
+					b.WriteString(`<li class="task"><input type="checkbox" disabled checked> `)
+				case it.task:
+					b.WriteString(`<li class="task"><input type="checkbox" disabled> `)
+				default:
+					b.WriteString("<li>")
+				}
+				b.WriteString(spansHTML(parseInline(it.text)) + "</li>\n")
+			}
+			b.WriteString("</" + tag + ">\n")
+		case quote:
+			b.WriteString("<blockquote>\n" + HTML(strings.Join(bl.lines, "\n")) + "</blockquote>\n")
+		case code:
+			b.WriteString("<pre><code>" + escape(strings.Join(bl.lines, "\n")) + "</code></pre>\n")
+		case rule:
+			b.WriteString("<hr>\n")
+		}
+	}
+	return b.String()
+}
+
+// Text renders Markdown as plain text for places that can't show HTML,
+// such as PDF reports: markup is dropped, list items get bullets or
+// numbers, tasks get [ ] or [x], and links show their URL after the text.
+func Text(src string) string {
+	var parts []string
+	for _, bl := range parse(src) {
+		var lines []string
+		switch bl.kind {
+		case paragraph, heading:
+			for _, line := range bl.lines {
+				lines = append(lines, spansText(parseInline(line)))
+			}
+		case list:
+			for i, it := range bl.items {
+				marker := "•"
+				switch {
+				case it.task && it.done:
+					marker = "[x]"
+				case it.task:
+					marker = "[ ]"
+				case bl.ordered:
+					marker = strconv.Itoa(bl.start+i) + "."
+				}
+				lines = append(lines, marker+" "+spansText(parseInline(it.text)))
+			}
+		case quote:
+			lines = append(lines, Text(strings.Join(bl.lines, "\n")))
+		case code:
+			lines = bl.lines
+		case rule:
+			lines = []string{"—"}
+		}
+		parts = append(parts, strings.Join(lines, "\n"))
+	}
+	return strings.Join(parts, "\n\n")
+}
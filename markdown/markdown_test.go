@@ -0,0 +1,56 @@
+package markdown
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTML(t *testing.T) {
+	tests := []struct {
+		name, src, want string
+	}{
+		{"empty", "", ""},
+		{"paragraphs", "Nice light\nbig closets\n\nNoisy street", "<p>Nice light<br>\nbig closets</p>\n<p>Noisy street</p>\n"},
+		{"heading", "## Kitchen ##", "<h2>Kitchen</h2>\n"},
+		{"emphasis", "**gas** stove, *tiny* fridge, ~~dishwasher~~", "<p><strong>gas</strong> stove, <em>tiny</em> fridge, <del>dishwasher</del></p>\n"},
+		{"snake case", "pet_fee and _maybe_", "<p>pet_fee and <em>maybe</em></p>\n"},
+		{"unclosed", "2 * 3 and **bold", "<p>2 * 3 and **bold</p>\n"},
+		{"code", "Code `#1234` at the gate", "<p>Code <code>#1234</code> at the gate</p>\n"},
+		{"escaped", `\*not emphasis\*`, "<p>*not emphasis*</p>\n"},
+		{"bullets", "- one\n* two\n  continued", "<ul>\n<li>one</li>\n<li>two continued</li>\n</ul>\n"},
+		{"numbered", "3. three\n4. four", "<ol start=\"3\">\n<li>three</li>\n<li>four</li>\n</ol>\n"},
+		{
+			"tasks", "- [x] Check water pressure\n- [ ] Ask about parking",
+			"<ul>\n<li class=\"task\"><input type=\"checkbox\" disabled checked> Check water pressure</li>\n" +
+				"<li class=\"task\"><input type=\"checkbox\" disabled> Ask about parking</li>\n</ul>\n",
+		},
+		{"quote", "> Landlord: *no* pets", "<blockquote>\n<p>Landlord: <em>no</em> pets</p>\n</blockquote>\n"},
+		{"fence", "```\n<b>x</b>\n  y\n```", "<pre><code>&lt;b&gt;x&lt;/b&gt;\n  y</code></pre>\n"},
+		{"rule", "a\n\n---\n\nb", "<p>a</p>\n<hr>\n<p>b</p>\n"},
+		{
+			"link", "[Listing](https://example.com/a?b=1&c=2)",
+			"<p><a href=\"https://example.com/a?b=1&amp;c=2\" rel=\"nofollow noopener noreferrer\">Listing</a></p>\n",
+		},
+		{
+			"autolink", "See https://example.com/unit.",
+			"<p>See <a href=\"https://example.com/unit\" rel=\"nofollow noopener noreferrer\">https://example.com/unit</a>.</p>\n",
+		},
+		{"unsafe link", "[click](javascript:alert(1))", "<p>click)</p>\n"},
+		{"html", `<script>alert("x")</script> & <img src=x onerror=y>`, "<p>&lt;script&gt;alert(&#34;x&#34;)&lt;/script&gt; &amp; &lt;img src=x onerror=y&gt;</p>\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, HTML(tt.src))
+		})
+	}
+}
+
+func TestText(t *testing.T) {
+	src := "# Visit\nGreat **light**, see [listing](https://example.com/1)\n\n" +
+		"- [x] Water pressure\n- [ ] Parking\n\n1. Call\n2. Apply\n\n- bullet"
+	want := "Visit\n\nGreat light, see listing (https://example.com/1)\n\n" +
+		"[x] Water pressure\n[ ] Parking\n\n1. Call\n2. Apply\n\n• bullet"
+	assert.Equal(t, want, Text(src))
+	assert.Equal(t, "", Text(""))
+}
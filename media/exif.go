@@ -0,0 +1,334 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+)
+
+// GPS is the position a photo's metadata says it was taken at
+type GPS struct {
+	Latitude  float64
+	Longitude float64
+}
+
+// ErrMalformed is returned for images whose structure can't be followed
+var ErrMalformed = errors.New("malformed image")
+
+// StripMetadata removes the metadata that can identify where, when, and
+// with what a photo was taken (EXIF, XMP, IPTC, comments, and PNG text
+// chunks) from JPEG and PNG images, returning the GPS position it held.
+// The EXIF orientation is kept so photos still display upright. Other
+// content is returned unchanged.
+func StripMetadata(data []byte) ([]byte, *GPS, error) {
+	switch Sniff(data) {
+	case "image/jpeg":
+		return stripJPEG(data)
+	case "image/png":
+		return stripPNG(data)
+	}
+	return data, nil, nil
+}
+
+// exifHeader starts the APP1 segments holding EXIF data
+var exifHeader = []byte("Exif\x00\x00")
+
+// JPEG markers
+const (
+	markerSOI  = 0xD8
+	markerSOS  = 0xDA
+	markerAPP1 = 0xE1
+	markerAPP2 = 0xE2 // ICC color profile, kept
+	markerCOM  = 0xFE
+)
+
+// stripJPEG drops the APP1 (EXIF, XMP), APP3-APP13 (IPTC and maker data),
+// and COM segments of a JPEG. APP0 (JFIF), APP2 (color profile), and APP14
+// (Adobe color transform) affect how the image decodes and stay.
+func stripJPEG(data []byte) ([]byte, *GPS, error) {
+	if len(data) < 2 || data[0] != 0xFF || data[1] != markerSOI {
+		return nil, nil, ErrMalformed
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:2])
+	var gps *GPS
+	var orientation uint16
+	wroteOrientation := false
+
+	for i := 2; ; {
+		if i+4 > len(data) || data[i] != 0xFF {
+			return nil, nil, ErrMalformed
+		}
+		marker := data[i+1]
+		if marker == 0xFF { // Fill byte
+			i++
+			continue
+		}
+		if marker == markerSOS {
+			// Entropy-coded data follows; nothing after it is metadata
+			// worth the trouble
+			if !wroteOrientation && orientation > 1 {
+				out.Write(orientationSegment(orientation))
+			}
+			out.Write(data[i:])
+			return out.Bytes(), gps, nil
+		}
+
+		end := i + 2 + int(binary.BigEndian.Uint16(data[i+2:]))
+		if end > len(data) || end < i+4 {
+			return nil, nil, ErrMalformed
+		}
+		segment := data[i:end]
+		payload := segment[4:]
+
+		switch {
+		case marker == markerAPP1 && bytes.HasPrefix(payload, exifHeader):
+			o, g := parseExif(payload[len(exifHeader):])
+			if g != nil {
+				gps = g
+			}
+			if o > 0 {
+				orientation = o
+			}
+		case marker == markerAPP1, marker > markerAPP2 && marker <= 0xED, marker == markerCOM:
+		default:
+			// The orientation goes after JFIF, where EXIF normally sits
+			if marker != 0xE0 && !wroteOrientation && orientation > 1 {
+				out.Write(orientationSegment(orientation))
+				wroteOrientation = true
+			}
+			out.Write(segment)
+		}
+		i = end
+	}
+}
+
+// Orientation returns the EXIF orientation of a JPEG, from 1 (upright) to
+// 8, or 1 when it records none
+func Orientation(data []byte) int {
+	if Sniff(data) != "image/jpeg" {
+		return 1
+	}
+	for i := 2; i+4 <= len(data) && data[i] == 0xFF; {
+		marker := data[i+1]
+		if marker == markerSOS {
+			break
+		}
+		end := i + 2 + int(binary.BigEndian.Uint16(data[i+2:]))
+		if end > len(data) || end < i+4 {
+			break
+		}
+		if payload := data[i+4 : end]; marker == markerAPP1 && bytes.HasPrefix(payload, exifHeader) {
+			if o, _ := parseExif(payload[len(exifHeader):]); o > 0 {
+				return int(o)
+			}
+		}
+		i = end
+	}
+	return 1
+}
+
+// Orient turns a decoded image upright according to its EXIF orientation,
+// since decoders return the pixels as stored
+func Orient(img image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if orientation >= 5 { // A quarter turn
+		w, h = h, w
+	}
+	out := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range b.Dy() {
+		for x := range b.Dx() {
+			var dx, dy int
+			switch orientation {
+			case 2: // Mirror
+				dx, dy = w-1-x, y
+			case 3: // Turn around
+				dx, dy = w-1-x, h-1-y
+			case 4: // Flip
+				dx, dy = x, h-1-y
+			case 5: // Transpose
+				dx, dy = y, x
+			case 6: // Turn clockwise
+				dx, dy = w-1-y, x
+			case 7: // Transverse
+				dx, dy = w-1-y, h-1-x
+			case 8: // Turn counterclockwise
+				dx, dy = y, h-1-x
+			}
+			out.Set(dx, dy, img.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+	return out
+}
+
+// orientationSegment builds an APP1 EXIF segment holding only the
+// orientation tag
+func orientationSegment(orientation uint16) []byte {
+	tiff := []byte{
+		'M', 'M', 0, 42, 0, 0, 0, 8, // Big-endian header, IFD0 at offset 8
+		0, 1, // One entry
+		0x01, 0x12, 0, 3, 0, 0, 0, 1, byte(orientation >> 8), byte(orientation), 0, 0, // Orientation, SHORT
+		0, 0, 0, 0, // No next IFD
+	}
+	payload := append(append([]byte{}, exifHeader...), tiff...)
+	seg := []byte{0xFF, markerAPP1, 0, 0}
+	binary.BigEndian.PutUint16(seg[2:], uint16(len(payload)+2))
+	return append(seg, payload...)
+}
+
+// pngSignature starts every PNG file
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// pngMetadata are the chunks dropped from PNGs
+var pngMetadata = map[string]bool{"eXIf": true, "tEXt": true, "zTXt": true, "iTXt": true, "tIME": true}
+
+// stripPNG drops the metadata chunks of a PNG. The rest are copied as
+// they are, CRCs and all.
+func stripPNG(data []byte) ([]byte, *GPS, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, nil, ErrMalformed
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(pngSignature)
+	var gps *GPS
+	for i := len(pngSignature); i < len(data); {
+		if i+12 > len(data) {
+			return nil, nil, ErrMalformed
+		}
+		n := int(binary.BigEndian.Uint32(data[i:]))
+		end := i + 12 + n
+		if end > len(data) {
+			return nil, nil, ErrMalformed
+		}
+		kind := string(data[i+4 : i+8])
+		if kind == "eXIf" {
+			_, gps = parseExif(data[i+8 : i+8+n])
+		}
+		if !pngMetadata[kind] {
+			out.Write(data[i:end])
+		}
+		i = end
+	}
+	return out.Bytes(), gps, nil
+}
+
+// EXIF tags read before stripping
+const (
+	tagOrientation  = 0x0112
+	tagGPSIFD       = 0x8825
+	tagGPSLatRef    = 1
+	tagGPSLatitude  = 2
+	tagGPSLongRef   = 3
+	tagGPSLongitude = 4
+)
+
+// tiffReader reads the TIFF structure EXIF data is stored in, treating
+// anything out of bounds as absent
+type tiffReader struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+// ifdEntry is a tag of an image file directory
+type ifdEntry struct {
+	kind  uint16
+	count uint32
+	value []byte // The 4-byte value or offset field
+}
+
+// ifd reads the entries of the directory at offset
+func (t tiffReader) ifd(offset uint32) map[uint16]ifdEntry {
+	entries := map[uint16]ifdEntry{}
+	if uint64(offset)+2 > uint64(len(t.data)) {
+		return entries
+	}
+	n := int(t.order.Uint16(t.data[offset:]))
+	for k := range n {
+		at := int(offset) + 2 + 12*k
+		if at+12 > len(t.data) {
+			break
+		}
+		e := t.data[at : at+12]
+		entries[t.order.Uint16(e)] = ifdEntry{kind: t.order.Uint16(e[2:]), count: t.order.Uint32(e[4:]), value: e[8:12]}
+	}
+	return entries
+}
+
+// rationals reads an entry of unsigned rationals
+func (t tiffReader) rationals(e ifdEntry) []float64 {
+	const rational = 5
+	if e.kind != rational {
+		return nil
+	}
+	offset := uint64(t.order.Uint32(e.value))
+	if offset+8*uint64(e.count) > uint64(len(t.data)) {
+		return nil
+	}
+	vals := make([]float64, e.count)
+	for k := range vals {
+		at := offset + 8*uint64(k)
+		num, den := t.order.Uint32(t.data[at:]), t.order.Uint32(t.data[at+4:])
+		if den == 0 {
+			return nil
+		}
+		vals[k] = float64(num) / float64(den)
+	}
+	return vals
+}
+
+// parseExif reads the orientation and GPS position from EXIF data,
+// returning zero values for whatever is missing or malformed
+func parseExif(data []byte) (orientation uint16, gps *GPS) {
+	if len(data) < 8 {
+		return 0, nil
+	}
+	t := tiffReader{data: data}
+	switch string(data[:2]) {
+	case "II":
+		t.order = binary.LittleEndian
+	case "MM":
+		t.order = binary.BigEndian
+	default:
+		return 0, nil
+	}
+
+	ifd0 := t.ifd(t.order.Uint32(data[4:]))
+	if e, ok := ifd0[tagOrientation]; ok && e.kind == 3 {
+		if o := t.order.Uint16(e.value); o >= 1 && o <= 8 {
+			orientation = o
+		}
+	}
+
+	e, ok := ifd0[tagGPSIFD]
+	if !ok {
+		return orientation, nil
+	}
+	g := t.ifd(t.order.Uint32(e.value))
+	lat := degrees(t.rationals(g[tagGPSLatitude]), g[tagGPSLatRef], 'S')
+	lng := degrees(t.rationals(g[tagGPSLongitude]), g[tagGPSLongRef], 'W')
+	// Cameras without a fix often record 0,0
+	if lat == nil || lng == nil || *lat == 0 && *lng == 0 || *lat < -90 || *lat > 90 || *lng < -180 || *lng > 180 {
+		return orientation, nil
+	}
+	return orientation, &GPS{Latitude: *lat, Longitude: *lng}
+}
+
+// degrees converts degrees, minutes, and seconds to decimal degrees,
+// negated when the reference is the negative hemisphere
+func degrees(dms []float64, ref ifdEntry, negative byte) *float64 {
+	if len(dms) != 3 || ref.value == nil {
+		return nil
+	}
+	d := dms[0] + dms[1]/60 + dms[2]/3600
+	if ref.value[0] == negative {
+		d = -d
+	}
+	return &d
+}
@@ -0,0 +1,121 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testExif builds little-endian EXIF data with an orientation and a GPS
+// position of 37°46'30"N 122°25'9"W
+func testExif() []byte {
+	le := binary.LittleEndian
+	var b bytes.Buffer
+	b.WriteString("II\x2a\x00")
+	binary.Write(&b, le, uint32(8))
+
+	// IFD0 at 8: orientation and the GPS IFD pointer
+	binary.Write(&b, le, uint16(2))
+	binary.Write(&b, le, []uint16{tagOrientation, 3})
+	binary.Write(&b, le, []uint32{1, 6})
+	binary.Write(&b, le, []uint16{tagGPSIFD, 4})
+	binary.Write(&b, le, []uint32{1, 38})
+	binary.Write(&b, le, uint32(0))
+
+	// GPS IFD at 38 with four entries, its rationals following at 92
+	binary.Write(&b, le, uint16(4))
+	entry := func(tag, kind uint16, count uint32, value []byte) {
+		binary.Write(&b, le, []uint16{tag, kind})
+		binary.Write(&b, le, count)
+		b.Write(value)
+	}
+	offset := func(n uint32) []byte { return le.AppendUint32(nil, n) }
+	entry(tagGPSLatRef, 2, 2, []byte("N\x00\x00\x00"))
+	entry(tagGPSLatitude, 5, 3, offset(92))
+	entry(tagGPSLongRef, 2, 2, []byte("W\x00\x00\x00"))
+	entry(tagGPSLongitude, 5, 3, offset(116))
+	binary.Write(&b, le, uint32(0))
+	binary.Write(&b, le, []uint32{37, 1, 46, 1, 30, 1, 122, 1, 25, 1, 900, 100})
+	return b.Bytes()
+}
+
+func TestStripJPEG(t *testing.T) {
+	var img bytes.Buffer
+	require.NoError(t, jpeg.Encode(&img, image.NewGray(image.Rect(0, 0, 8, 4)), nil))
+	exif := append([]byte("Exif\x00\x00"), testExif()...)
+	app1 := append([]byte{0xFF, 0xE1, byte((len(exif) + 2) >> 8), byte(len(exif) + 2)}, exif...)
+	com := []byte("\xFF\xFE\x00\x08secret")
+	data := append(append(append([]byte{0xFF, 0xD8}, app1...), com...), img.Bytes()[2:]...)
+
+	clean, gps, err := StripMetadata(data)
+	require.NoError(t, err)
+	require.NotNil(t, gps)
+	assert.InDelta(t, 37.775, gps.Latitude, 1e-9)
+	assert.InDelta(t, -122.4191667, gps.Longitude, 1e-6)
+
+	assert.NotContains(t, string(clean), "secret")
+	assert.NotContains(t, string(clean), string(testExif()))
+	assert.Equal(t, 6, Orientation(data))
+	assert.Equal(t, 6, Orientation(clean))
+
+	decoded, err := jpeg.Decode(bytes.NewReader(clean))
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 8, 4), decoded.Bounds())
+}
+
+func TestStripPNG(t *testing.T) {
+	var img bytes.Buffer
+	require.NoError(t, png.Encode(&img, image.NewGray(image.Rect(0, 0, 8, 4))))
+	chunk := func(kind string, body []byte) []byte {
+		c := binary.BigEndian.AppendUint32(nil, uint32(len(body)))
+		c = append(append(c, kind...), body...)
+		return binary.BigEndian.AppendUint32(c, crc32.ChecksumIEEE(c[4:]))
+	}
+	// Metadata chunks go after IHDR, which is 8+25 bytes in
+	head, tail := img.Bytes()[:33], img.Bytes()[33:]
+	data := append(append(append(append([]byte{}, head...), chunk("eXIf", testExif())...),
+		chunk("tEXt", []byte("Author\x00me"))...), tail...)
+
+	clean, gps, err := StripMetadata(data)
+	require.NoError(t, err)
+	require.NotNil(t, gps)
+	assert.InDelta(t, 37.775, gps.Latitude, 1e-9)
+	assert.Equal(t, img.Bytes(), clean)
+}
+
+func TestOrient(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 2, 1))
+	img.Pix = []byte{10, 20}
+	gray := func(img image.Image, x, y int) uint8 { return color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y }
+
+	turned := Orient(img, 6)
+	assert.Equal(t, image.Rect(0, 0, 1, 2), turned.Bounds())
+	assert.Equal(t, []uint8{10, 20}, []uint8{gray(turned, 0, 0), gray(turned, 0, 1)})
+
+	turned = Orient(img, 8)
+	assert.Equal(t, []uint8{20, 10}, []uint8{gray(turned, 0, 0), gray(turned, 0, 1)})
+
+	turned = Orient(img, 3)
+	assert.Equal(t, []uint8{20, 10}, []uint8{gray(turned, 0, 0), gray(turned, 1, 0)})
+
+	assert.Same(t, img, Orient(img, 1).(*image.Gray))
+}
+
+func TestStripMetadataOther(t *testing.T) {
+	pdf := []byte("%PDF-1.4\n")
+	clean, gps, err := StripMetadata(pdf)
+	assert.NoError(t, err)
+	assert.Nil(t, gps)
+	assert.Equal(t, pdf, clean)
+
+	_, _, err = StripMetadata([]byte("\xFF\xD8\xFF\xE1\x00"))
+	assert.ErrorIs(t, err, ErrMalformed)
+}
@@ -0,0 +1,101 @@
+// Package media inspects uploaded images and documents
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	_ "image/gif"  // register GIF decoding
+	_ "image/jpeg" // register JPEG decoding
+	_ "image/png"  // register PNG decoding
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// ErrUnsupportedType is returned for content that isn't an accepted
+// image or document format
+var ErrUnsupportedType = errors.New("unsupported file type")
+
+// Info is the metadata extracted from an upload
+type Info struct {
+	ContentType string
+	// Width and Height are the pixel dimensions of images
+	Width  int
+	Height int
+	// Pages is the page count of PDFs
+	Pages int
+}
+
+// IsImage reports whether the content is an image
+func (i Info) IsImage() bool {
+	return strings.HasPrefix(i.ContentType, "image/")
+}
+
+// Sniff returns the MIME type of data judged from its content rather than
+// any name or header it came with, without parameters such as the charset
+func Sniff(data []byte) string {
+	ct, _, _ := strings.Cut(http.DetectContentType(data), ";")
+	return ct
+}
+
+// Inspect sniffs the type of data and extracts its metadata. Only PNG,
+// JPEG, GIF, and PDF are accepted.
+func Inspect(data []byte) (*Info, error) {
+	info := &Info{ContentType: Sniff(data)}
+	switch info.ContentType {
+	case "image/png", "image/jpeg", "image/gif":
+		cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		info.Width, info.Height = cfg.Width, cfg.Height
+	case "application/pdf":
+		info.Pages = countPDFPages(data)
+	default:
+		return nil, ErrUnsupportedType
+	}
+	return info, nil
+}
+
+// pdfPage matches page objects but not the /Pages tree nodes
+var pdfPage = regexp.MustCompile(`/Type\s*/Page[^s]`)
+
+// countPDFPages estimates a PDF's page count from its page objects. PDFs
+// with compressed object streams hide their pages, so this can return 0.
+func countPDFPages(data []byte) int {
+	return len(pdfPage.FindAllIndex(data, -1))
+}
+
+// Thumbnail scales img down to fit within maxSide pixels on its longer side,
+// averaging the source pixels each output pixel covers. Images already small
+// enough are returned unchanged.
+func Thumbnail(img image.Image, maxSide int) image.Image {
+	b := img.Bounds()
+	if b.Dx() <= maxSide && b.Dy() <= maxSide {
+		return img
+	}
+	scale := float64(maxSide) / float64(max(b.Dx(), b.Dy()))
+	w, h := max(1, int(float64(b.Dx())*scale+0.5)), max(1, int(float64(b.Dy())*scale+0.5))
+
+	thumb := image.NewRGBA(image.Rect(0, 0, w, h))
+	for ty := range h {
+		y0, y1 := b.Min.Y+ty*b.Dy()/h, b.Min.Y+(ty+1)*b.Dy()/h
+		for tx := range w {
+			x0, x1 := b.Min.X+tx*b.Dx()/w, b.Min.X+(tx+1)*b.Dx()/w
+			var r, g, bl, a, n uint64
+			for y := y0; y < max(y1, y0+1); y++ {
+				for x := x0; x < max(x1, x0+1); x++ {
+					cr, cg, cb, ca := img.At(x, y).RGBA()
+					r, g, bl, a, n = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca), n+1
+				}
+			}
+			i := thumb.PixOffset(tx, ty)
+			thumb.Pix[i] = uint8(r / n >> 8)
+			thumb.Pix[i+1] = uint8(g / n >> 8)
+			thumb.Pix[i+2] = uint8(bl / n >> 8)
+			thumb.Pix[i+3] = uint8(a / n >> 8)
+		}
+	}
+	return thumb
+}
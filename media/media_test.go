@@ -0,0 +1,65 @@
+package media
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInspectImage(t *testing.T) {
+	var buf bytes.Buffer
+	png.Encode(&buf, image.NewGray(image.Rect(0, 0, 40, 30)))
+
+	info, err := Inspect(buf.Bytes())
+	assert.NoError(t, err)
+	assert.Equal(t, "image/png", info.ContentType)
+	assert.Equal(t, 40, info.Width)
+	assert.Equal(t, 30, info.Height)
+	assert.True(t, info.IsImage())
+}
+
+func TestInspectPDF(t *testing.T) {
+	pdf := []byte("%PDF-1.4\n1 0 obj << /Type /Pages /Kids [2 0 R 3 0 R] /Count 2 >> endobj\n" +
+		"2 0 obj << /Type /Page /Parent 1 0 R >> endobj\n3 0 obj << /Type/Page /Parent 1 0 R >> endobj\n")
+
+	info, err := Inspect(pdf)
+	assert.NoError(t, err)
+	assert.Equal(t, "application/pdf", info.ContentType)
+	assert.Equal(t, 2, info.Pages)
+	assert.False(t, info.IsImage())
+}
+
+func TestInspectUnsupported(t *testing.T) {
+	_, err := Inspect([]byte("just some text"))
+	assert.ErrorIs(t, err, ErrUnsupportedType)
+}
+
+func TestSniff(t *testing.T) {
+	assert.Equal(t, "text/plain", Sniff([]byte("just some text")))
+	assert.Equal(t, "application/pdf", Sniff([]byte("%PDF-1.7\n")))
+	assert.Equal(t, "text/html", Sniff([]byte("<html><script>")))
+}
+
+func TestThumbnail(t *testing.T) {
+	// Left half black, right half white
+	img := image.NewGray(image.Rect(0, 0, 400, 100))
+	for y := range 100 {
+		for x := 200; x < 400; x++ {
+			img.SetGray(x, y, color.Gray{Y: 255})
+		}
+	}
+
+	thumb := Thumbnail(img, 100)
+	assert.Equal(t, image.Rect(0, 0, 100, 25), thumb.Bounds())
+	r, _, _, _ := thumb.At(10, 10).RGBA()
+	assert.Equal(t, uint32(0), r)
+	r, _, _, _ = thumb.At(90, 10).RGBA()
+	assert.Equal(t, uint32(0xffff), r)
+
+	small := image.NewGray(image.Rect(0, 0, 50, 20))
+	assert.Same(t, small, Thumbnail(small, 100))
+}
@@ -0,0 +1,20 @@
+// Package metrics publishes runtime counters through expvar. They are
+// served as JSON at /debug/vars.
+package metrics
+
+import "expvar"
+
+// Cache counts read-cache activity: hits, misses, evictions, and
+// invalidations
+var Cache = expvar.NewMap("cache")
+
+// DB counts database statements: how many ran, the rows they returned or
+// changed, their total duration in microseconds, how many failed or were
+// slow, and how often they were retried, or gave up, finding the database
+// locked
+var DB = expvar.NewMap("db")
+
+// DBPool reports the database connection pool: the open, in-use, and idle
+// connections, how often and how long statements waited for one, and how
+// many were closed for being idle or old
+var DBPool = expvar.NewMap("db_pool")
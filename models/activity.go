@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// Activity actions
+const (
+	ActivityAdded         = "added"
+	ActivityCaptured      = "captured" // Added from an email, a webhook, or the bookmarklet
+	ActivityEdited        = "edited"   // Detail lists the changed fields
+	ActivityStatusChanged = "status_changed"
+	ActivityRated         = "rated"
+	ActivityDeleted       = "deleted"
+	ActivityVisited       = "visited"
+	ActivityCommented     = "commented"
+	ActivityReacted       = "reacted" // Detail is the reaction
+	ActivityUploaded      = "uploaded"
+	ActivityUndone        = "undone" // Detail is the action undone
+)
+
+// Activity is one entry in the household's activity feed
+type Activity struct {
+	ID          int64     `json:"id"`
+	UserID      *int64    `json:"user_id"` // nil when done without X-User-ID
+	User        string    `json:"user"`    // The user's name, or empty
+	Action      string    `json:"action"`
+	ApartmentID int64     `json:"apartment_id"`
+	Address     string    `json:"address"` // As it was at the time
+	Detail      string    `json:"detail"`
+	CreatedAt   time.Time `json:"created_at"`
+}
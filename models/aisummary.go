@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// AISummary is a short pros and cons assessment of an apartment written by
+// a language model from the user's notes, visits, and photo captions
+type AISummary struct {
+	ApartmentID int64     `json:"apartment_id"`
+	Summary     string    `json:"summary"`
+	Pros        []string  `json:"pros"`
+	Cons        []string  `json:"cons"`
+	Provider    string    `json:"provider"`
+	Model       string    `json:"model"`
+	GeneratedAt time.Time `json:"generated_at"`
+	Cached      bool      `json:"cached"` // Whether it was generated by an earlier request
+}
@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// Alert rule kinds
+const (
+	AlertPriceBelow = "price_below" // An apartment's price is below Price
+	AlertNewMatch   = "new_match"   // A new apartment matches Filter
+)
+
+// AlertRule texts its user when apartments they can see meet a condition,
+// optionally narrowed by a filter like a saved search
+type AlertRule struct {
+	ID     int64  `json:"id"`
+	UserID int64  `json:"user_id"`
+	Name   string `json:"name"`
+	Kind   string `json:"kind"`
+
+	// Price is the threshold of a price_below rule; nil otherwise
+	Price *float64 `json:"price"`
+
+	// Filter narrows the apartments the rule looks at, in the list
+	// endpoint's q syntax; empty for every apartment
+	Filter string `json:"filter"`
+
+	// LastApartmentID is the newest apartment a new_match rule has
+	// looked at
+	LastApartmentID int64 `json:"-"`
+
+	LastFiredAt *time.Time `json:"last_fired_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// AlertRuleRequest is used for creating an alert rule
+type AlertRuleRequest struct {
+	Name   string   `json:"name" binding:"max=100"`
+	Kind   string   `json:"kind" binding:"required,oneof=price_below new_match"`
+	Price  *float64 `json:"price" binding:"required_if=Kind price_below,omitempty,gt=0"`
+	Filter string   `json:"filter" binding:"max=1000"`
+}
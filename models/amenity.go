@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// Amenity is an entry in the amenity taxonomy (dishwasher, ac, ...)
+type Amenity struct {
+	ID        int64     `json:"id"`
+	Key       string    `json:"key"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AmenityRequest is used for adding an amenity to the taxonomy
+type AmenityRequest struct {
+	Key  string `json:"key" binding:"required,max=64"`
+	Name string `json:"name" binding:"required"`
+}
+
+// Amenity keys behind the legacy boolean fields
+const (
+	AmenityGated   = "gated"
+	AmenityLaundry = "laundry"
+)
+
+// AmenityGarage was the garage amenity before parking details replaced it.
+// It's still accepted in requests as a way of setting garage parking.
+const AmenityGarage = "garage"
+
+// LegacyAmenities maps the amenity keys that have their own boolean
+// field to whether the request sets them
+func (r *ApartmentRequest) LegacyAmenities() map[string]bool {
+	return map[string]bool{
+		AmenityGated:   r.IsGated,
+		AmenityLaundry: r.HasLaundry,
+	}
+}
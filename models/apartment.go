@@ -1,33 +1,248 @@
 package models
 
 import (
+	"database/sql/driver"
+	"fmt"
+	"strconv"
 	"strings"
 	"time"
+
+	"github.com/mojotx/apt-eval/address"
 )
 
 // Apartment represents an apartment evaluation record
 type Apartment struct {
-	ID         int64     `json:"id"`
-	Address    string    `json:"address" binding:"required"`
-	VisitDate  time.Time `json:"visit_date"`
-	Notes      string    `json:"notes"`
-	Rating     int       `json:"rating"`      // Rating from 1-5
-	Price      float64   `json:"price"`       // Monthly rent/price
-	Floor      uint      `json:"floor"`       // Floor number
-	IsGated    bool      `json:"is_gated"`    // Is the apartment complex gated
-	HasGarage  bool      `json:"has_garage"`  // Has a garage
-	HasLaundry bool      `json:"has_laundry"` // Has in-unit laundry
-	CreatedAt  time.Time `json:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at"`
-}
-
-// CustomTime is a wrapper around time.Time to handle various date formats
+	ID         int64      `json:"id"`
+	UUID       string     `json:"uuid"`                       // Random public ID, usable wherever the ID is
+	Address    string     `json:"address" binding:"required"` // Street address, without the unit
+	Unit       string     `json:"unit"`                       // Unit or apartment number, like "4B"
+	VisitDate  CustomTime `json:"visit_date"`
+	Notes      string     `json:"notes"`       // Markdown
+	Rating     *int       `json:"rating"`      // Rating from 1-5; nil when unrated
+	Price      *float64   `json:"price"`       // Monthly rent/price; nil when unknown
+	Floor      *uint      `json:"floor"`       // Floor number; nil when unknown
+	IsGated    bool       `json:"is_gated"`    // Is the apartment complex gated
+	HasGarage  bool       `json:"has_garage"`  // Mirrors Parking.Type == garage
+	HasLaundry bool       `json:"has_laundry"` // Has in-unit laundry
+	Status     string     `json:"status"`      // Pipeline status; see Statuses
+	ListingURL string     `json:"listing_url"` // Where the listing was found
+
+	// Notes rendered as sanitized HTML, only set when asked for with
+	// ?render=html
+	NotesHTML *string `json:"notes_html,omitempty"`
+
+	// Address with the unit in canonical form, for spotting duplicates;
+	// BuildingAddress leaves the unit out, for grouping units of a building
+	CanonicalAddress string `json:"canonical_address"`
+	BuildingAddress  string `json:"building_address"`
+
+	// Per-category ratings and their weighted combination. When any
+	// category is rated, Rating is the score rounded to a whole number.
+	Ratings CategoryRatings `json:"ratings"`
+	Score   *float64        `json:"score"`
+
+	// Amenity keys from the taxonomy; the boolean fields above mirror
+	// the gated and laundry amenities
+	Amenities []string `json:"amenities"`
+
+	Parking Parking `json:"parking"`
+
+	// ListingType is rent for a rental, or sale for a home to buy instead,
+	// whose costs are in Sale
+	ListingType string `json:"listing_type"`
+	Sale        Sale   `json:"sale"`
+
+	// Lowest rent the landlord has offered in the negotiation log
+	BestOffer *float64 `json:"best_offer"`
+
+	// When the application is due, and when the landlord stops holding
+	// the unit for us
+	ApplicationDeadline *CustomTime `json:"application_deadline"`
+	HoldExpires         *CustomTime `json:"hold_expires"`
+
+	// Building holding the unit, whose amenities it shares
+	BuildingID *int64 `json:"building_id"`
+
+	// Photo representing the apartment in lists, comparisons, and reports
+	CoverPhotoID *int64 `json:"cover_photo_id"`
+
+	// Who may see the apartment (see VisibleTo), and the household member
+	// who added it
+	Visibility string `json:"visibility"`
+	OwnerID    *int64 `json:"owner_id"`
+
+	// How many household members left each reaction
+	Reactions ReactionCounts `json:"reactions"`
+
+	// Answers to the evaluation template's questions, keyed by field
+	Answers map[string]any `json:"answers"`
+
+	// Location, when known, enables neighborhood enrichment
+	Latitude  *float64 `json:"latitude"`
+	Longitude *float64 `json:"longitude"`
+
+	// Walkability scores (0-100) from the configured provider
+	WalkScore            *int       `json:"walk_score"`
+	BikeScore            *int       `json:"bike_score"`
+	TransitScore         *int       `json:"transit_score"`
+	WalkabilityUpdatedAt *time.Time `json:"walkability_updated_at"`
+
+	// Reported incidents nearby and the derived safety level
+	// ("high", "medium", or "low")
+	CrimeIncidents *int       `json:"crime_incidents"`
+	Safety         *string    `json:"safety"`
+	CrimeUpdatedAt *time.Time `json:"crime_updated_at"`
+
+	// Assigned school district and average nearby school rating (1-10)
+	SchoolDistrict   *string    `json:"school_district"`
+	SchoolRating     *float64   `json:"school_rating"`
+	SchoolsUpdatedAt *time.Time `json:"schools_updated_at"`
+
+	// Going rent in the area for the apartment's bedroom count, and how far
+	// its price is over (positive) or under it, in percent
+	MarketRent          *float64   `json:"market_rent"`
+	MarketRentArea      *string    `json:"market_rent_area"`
+	MarketRentBedrooms  *int       `json:"market_rent_bedrooms"`
+	MarketRentDiffPct   *float64   `json:"market_rent_diff_pct"`
+	MarketRentUpdatedAt *time.Time `json:"market_rent_updated_at"`
+
+	// Nearest transit stop, the estimated walk to it, and the lines
+	// (comma-separated) serving stops within a short walk
+	TransitStop        *string    `json:"transit_stop"`
+	TransitWalkMinutes *int       `json:"transit_walk_minutes"`
+	TransitLines       *string    `json:"transit_lines"`
+	TransitUpdatedAt   *time.Time `json:"transit_updated_at"`
+
+	// Weighted 0-100 score of commutes to the saved destinations
+	CommuteScore     *float64   `json:"commute_score"`
+	CommuteUpdatedAt *time.Time `json:"commute_updated_at"`
+
+	// Neighborhood entered by hand (source "manual") or found by reverse
+	// geocoding (source is the geocoder's name)
+	Neighborhood          string     `json:"neighborhood"`
+	NeighborhoodSource    string     `json:"neighborhood_source"`
+	NeighborhoodUpdatedAt *time.Time `json:"neighborhood_updated_at"`
+
+	// Average rating of the apartment's rooms, and that blended with
+	// Rating into an overall 1-5 rating
+	RoomRating    *float64 `json:"room_rating"`
+	OverallRating *float64 `json:"overall_rating"`
+
+	// Estimated utility costs, and Price plus those the rent doesn't
+	// include. TrueMonthlyCost is nil while Price is unset.
+	Utilities       Utilities `json:"utilities"`
+	TrueMonthlyCost *float64  `json:"true_monthly_cost"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// FullAddress returns the address with the unit in it, for display
+func (a *Apartment) FullAddress() string {
+	return address.JoinUnit(a.Address, a.Unit)
+}
+
+// CompactApartment is the trimmed-down list entry returned by
+// ?view=compact, small enough for a phone list view over cellular
+type CompactApartment struct {
+	ID           int64    `json:"id"`
+	UUID         string   `json:"uuid"`
+	Address      string   `json:"address"`
+	Unit         string   `json:"unit"`
+	Price        *float64 `json:"price"`
+	Rating       *int     `json:"rating"`
+	Status       string   `json:"status"`
+	ThumbnailURL *string  `json:"thumbnail_url"` // Cover image, when there is one
+}
+
+// NeighborhoodManual is the neighborhood source of hand-entered values
+const NeighborhoodManual = "manual"
+
+// ApartmentGroup summarizes the apartments sharing a value of a field
+type ApartmentGroup struct {
+	Key          string     `json:"key"` // Empty for apartments without a value
+	Count        int        `json:"count"`
+	AveragePrice *float64   `json:"average_price"` // Ignores apartments without a price
+	BestRated    *Apartment `json:"best_rated"`
+}
+
+// Aggregate holds statistics of one field over a group of apartments, keyed
+// in Group by the grouping fields' values. The statistics are null when no
+// apartment in the group has the field set.
+type Aggregate struct {
+	Group map[string]any `json:"group"`
+	Count int            `json:"count"`
+	Avg   *float64       `json:"avg"`
+	Min   *float64       `json:"min"`
+	Max   *float64       `json:"max"`
+}
+
+// DuplicateGroup is a set of apartments entered under the same canonical
+// address
+type DuplicateGroup struct {
+	CanonicalAddress string      `json:"canonical_address"`
+	Apartments       []Apartment `json:"apartments"`
+}
+
+// Date output formats for SetDateFormat
+const (
+	DateFormatRFC3339 = "rfc3339" // 2006-01-02T15:04:05Z07:00
+	DateFormatDate    = "date"    // 2006-01-02, dropping the time of day
+)
+
+// dateLayout is the layout CustomTime values are written in
+var dateLayout = time.RFC3339
+
+// SetDateFormat sets how CustomTime values are written in JSON responses:
+// DateFormatRFC3339, the default, or DateFormatDate
+func SetDateFormat(format string) error {
+	switch strings.ToLower(format) {
+	case "", DateFormatRFC3339:
+		dateLayout = time.RFC3339
+	case DateFormatDate:
+		dateLayout = time.DateOnly
+	default:
+		return fmt.Errorf("unknown date format %q, want %s or %s", format, DateFormatRFC3339, DateFormatDate)
+	}
+	return nil
+}
+
+// CustomTime is a wrapper around time.Time to handle various date formats.
+// It's read from JSON as RFC 3339, a date and time without a zone, a date,
+// or Unix time, and written in the format set by SetDateFormat, or null
+// when unset.
 type CustomTime struct {
 	time.Time
 }
 
+// MarshalJSON implements json.Marshaler for CustomTime
+func (ct CustomTime) MarshalJSON() ([]byte, error) {
+	if ct.IsZero() {
+		return []byte("null"), nil
+	}
+	t := ct.Time
+	if dateLayout == time.RFC3339 {
+		t = t.UTC()
+	}
+	return []byte(`"` + t.Format(dateLayout) + `"`), nil
+}
+
 // UnmarshalJSON implements json.Unmarshaler for CustomTime
 func (ct *CustomTime) UnmarshalJSON(b []byte) error {
+	// Unix time, in seconds or, when too large for seconds, milliseconds
+	if len(b) > 0 && (b[0] == '-' || b[0] >= '0' && b[0] <= '9') {
+		n, err := strconv.ParseInt(string(b), 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid Unix time %s: %w", b, err)
+		}
+		if n > maxUnixSeconds || n < -maxUnixSeconds {
+			ct.Time = time.UnixMilli(n).UTC()
+		} else {
+			ct.Time = time.Unix(n, 0).UTC()
+		}
+		return nil
+	}
+
 	s := strings.Trim(string(b), "\"")
 	if s == "null" || s == "" {
 		ct.Time = time.Time{}
@@ -55,15 +270,190 @@ func (ct *CustomTime) UnmarshalJSON(b []byte) error {
 	return err
 }
 
+// maxUnixSeconds is the largest Unix time taken as seconds, in the year
+// 5138; anything larger is taken as milliseconds
+const maxUnixSeconds = 1e11
+
+// Scan implements sql.Scanner for CustomTime, for reading date columns
+func (ct *CustomTime) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		ct.Time = time.Time{}
+	case time.Time:
+		ct.Time = v
+	case string:
+		return ct.parseSQLite(v)
+	case []byte:
+		return ct.parseSQLite(string(v))
+	default:
+		return fmt.Errorf("can't scan %T into a date", src)
+	}
+	return nil
+}
+
+// parseSQLite reads a date stored as text, in the layouts SQLite uses
+func (ct *CustomTime) parseSQLite(s string) error {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999-07:00", time.DateTime, time.DateOnly} {
+		if t, err := time.Parse(layout, s); err == nil {
+			ct.Time = t
+			return nil
+		}
+	}
+	return fmt.Errorf("can't parse %q as a date", s)
+}
+
+// Value implements driver.Valuer for CustomTime
+func (ct CustomTime) Value() (driver.Value, error) {
+	return ct.Time, nil
+}
+
 // ApartmentRequest is used for creating/updating an apartment record
 type ApartmentRequest struct {
 	Address    string     `json:"address" binding:"required"`
 	VisitDate  CustomTime `json:"visit_date"`
 	Notes      string     `json:"notes"`
-	Rating     int        `json:"rating"`
-	Price      float64    `json:"price"`
-	Floor      uint       `json:"floor"`       // Floor number
-	IsGated    bool       `json:"is_gated"`    // Is the apartment complex gated
-	HasGarage  bool       `json:"has_garage"`  // Has a garage
-	HasLaundry bool       `json:"has_laundry"` // Has in-unit laundry
+	Rating     *int       `json:"rating" binding:"omitempty,min=1,max=5"` // Unrated when nil
+	Price      *float64   `json:"price" binding:"omitempty,min=0"`        // Unknown when nil
+	Floor      *uint      `json:"floor"`                                  // Floor number; unknown when nil
+	IsGated    bool       `json:"is_gated"`                               // Is the apartment complex gated
+	HasGarage  bool       `json:"has_garage"`                             // Garage parking; ignored when Parking is present
+	HasLaundry bool       `json:"has_laundry"`                            // Has in-unit laundry
+	Latitude   *float64   `json:"latitude" binding:"omitempty,latitude"`
+	Longitude  *float64   `json:"longitude" binding:"omitempty,longitude"`
+
+	// Status defaults to considering on create and is left unchanged on
+	// update when empty; ListingURL is left unchanged when omitted
+	Status     string  `json:"status" binding:"omitempty,oneof=draft considering scheduled visited applied signed rejected archived"`
+	ListingURL *string `json:"listing_url" binding:"omitempty,eq=|url"`
+
+	// Unit sets the unit number when present and is left unchanged on
+	// update when omitted. A unit typed into the address, as in "123 Main
+	// St #4B", is split off into it.
+	Unit *string `json:"unit" binding:"omitempty,max=20"`
+
+	// Ratings replaces the category ratings when present; Rating is
+	// ignored once any category is rated
+	Ratings *CategoryRatings `json:"ratings"`
+
+	// Amenities replaces the apartment's amenities when present. When
+	// omitted, only those behind the boolean fields above are updated.
+	Amenities []string `json:"amenities" binding:"omitempty,dive,required"`
+
+	// Answers replaces the template answers when present and must satisfy
+	// the active template. When omitted on update, they're left as is.
+	Answers map[string]any `json:"answers"`
+
+	// Neighborhood sets the neighborhood by hand when present. An empty
+	// string clears it so reverse geocoding can fill it in again.
+	Neighborhood *string `json:"neighborhood" binding:"omitempty,max=100"`
+
+	// Utilities replaces the utility estimates when present
+	Utilities *Utilities `json:"utilities"`
+
+	// Parking replaces the parking details when present. Otherwise
+	// HasGarage sets garage parking, or clears it without touching other
+	// kinds of parking.
+	Parking *Parking `json:"parking"`
+
+	// ListingType defaults to rent on create and is left unchanged on
+	// update when empty. Sale replaces the sale details when present.
+	ListingType string `json:"listing_type" binding:"omitempty,oneof=rent sale"`
+	Sale        *Sale  `json:"sale"`
+
+	// Deadlines are set when present and cleared when empty
+	ApplicationDeadline *CustomTime `json:"application_deadline"`
+	HoldExpires         *CustomTime `json:"hold_expires"`
+
+	// BuildingID links the apartment to a building, or unlinks it when 0
+	BuildingID *int64 `json:"building_id"`
+
+	// Visibility defaults to DefaultVisibility on create and is left
+	// unchanged on update when empty
+	Visibility string `json:"visibility" binding:"omitempty,oneof=private household shared"`
+
+	// OwnerID is the household member making the request, set by the
+	// handler rather than the client. It becomes the owner of a new
+	// apartment, or of an existing one without an owner.
+	OwnerID *int64 `json:"-"`
+}
+
+// Kinds of parking
+const (
+	ParkingStreet   = "street"
+	ParkingGarage   = "garage"
+	ParkingAssigned = "assigned"
+)
+
+// Parking describes where residents park. An empty Type means it isn't
+// known.
+type Parking struct {
+	Type        string   `json:"type" binding:"omitempty,oneof=street garage assigned"`
+	MonthlyCost *float64 `json:"monthly_cost" binding:"omitempty,min=0"`
+	EVCharging  bool     `json:"ev_charging"`
+	DistanceM   *float64 `json:"distance_m" binding:"omitempty,min=0"` // From the spot to the unit
+}
+
+// Kinds of listing
+const (
+	ListingRent = "rent"
+	ListingSale = "sale"
+)
+
+// Sale holds the costs of buying a home listed for sale
+type Sale struct {
+	PurchasePrice *float64 `json:"purchase_price" binding:"omitempty,min=0"`
+	HOAFee        *float64 `json:"hoa_fee" binding:"omitempty,min=0"`      // Monthly
+	PropertyTax   *float64 `json:"property_tax" binding:"omitempty,min=0"` // Yearly; estimated from the price when unknown
+}
+
+// Utilities holds estimated monthly utility costs and which utilities the
+// rent includes. An included utility adds nothing to the monthly cost,
+// whatever its estimate.
+type Utilities struct {
+	Electricity         *float64 `json:"electricity" binding:"omitempty,min=0"`
+	Gas                 *float64 `json:"gas" binding:"omitempty,min=0"`
+	Water               *float64 `json:"water" binding:"omitempty,min=0"`
+	Internet            *float64 `json:"internet" binding:"omitempty,min=0"`
+	ElectricityIncluded bool     `json:"electricity_included"`
+	GasIncluded         bool     `json:"gas_included"`
+	WaterIncluded       bool     `json:"water_included"`
+	InternetIncluded    bool     `json:"internet_included"`
+}
+
+// UtilityCost is one utility's estimate and whether the rent includes it
+type UtilityCost struct {
+	Name     string
+	Estimate *float64
+	Included bool
+}
+
+// List returns each utility in display order
+func (u Utilities) List() []UtilityCost {
+	return []UtilityCost{
+		{"Electricity", u.Electricity, u.ElectricityIncluded},
+		{"Gas", u.Gas, u.GasIncluded},
+		{"Water", u.Water, u.WaterIncluded},
+		{"Internet", u.Internet, u.InternetIncluded},
+	}
+}
+
+// CategoryRatings holds 1-5 ratings for each scoring category; unrated
+// categories are nil
+type CategoryRatings struct {
+	Location  *int `json:"location" binding:"omitempty,min=1,max=5"`
+	Condition *int `json:"condition" binding:"omitempty,min=1,max=5"`
+	Kitchen   *int `json:"kitchen" binding:"omitempty,min=1,max=5"`
+	Noise     *int `json:"noise" binding:"omitempty,min=1,max=5"` // Higher is quieter
+	Value     *int `json:"value" binding:"omitempty,min=1,max=5"`
+}
+
+// ByCategory returns the ratings keyed by scoring category name
+func (r CategoryRatings) ByCategory() map[string]*int {
+	return map[string]*int{
+		"location":  r.Location,
+		"condition": r.Condition,
+		"kitchen":   r.Kitchen,
+		"noise":     r.Noise,
+		"value":     r.Value,
+	}
 }
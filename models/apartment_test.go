@@ -0,0 +1,54 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomTimeJSON(t *testing.T) {
+	defer SetDateFormat(DateFormatRFC3339)
+
+	var req struct {
+		VisitDate CustomTime  `json:"visit_date"`
+		Deadline  *CustomTime `json:"deadline"`
+	}
+	want := time.Date(2026, 5, 10, 15, 30, 0, 0, time.UTC)
+	for _, input := range []string{
+		`"2026-05-10T15:30:00Z"`,
+		`"2026-05-10T09:30:00-06:00"`,
+		`"2026-05-10T15:30:00"`,
+		`1778427000`,
+		`1778427000000`,
+	} {
+		assert.NoError(t, json.Unmarshal([]byte(`{"visit_date": `+input+`}`), &req), input)
+		assert.True(t, want.Equal(req.VisitDate.Time), "%s: got %s", input, req.VisitDate)
+	}
+
+	out, err := json.Marshal(req)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"visit_date": "2026-05-10T15:30:00Z", "deadline": null}`, string(out))
+
+	assert.NoError(t, SetDateFormat(DateFormatDate))
+	out, err = json.Marshal(req)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"visit_date": "2026-05-10", "deadline": null}`, string(out))
+
+	out, err = json.Marshal(CustomTime{})
+	assert.NoError(t, err)
+	assert.Equal(t, "null", string(out), "unset dates are null")
+
+	assert.Error(t, SetDateFormat("unix"))
+	assert.Error(t, json.Unmarshal([]byte(`{"visit_date": 1.5}`), &req))
+}
+
+func TestCustomTimeScan(t *testing.T) {
+	var ct CustomTime
+	assert.NoError(t, ct.Scan("2026-05-10 15:30:00"))
+	assert.Equal(t, time.Date(2026, 5, 10, 15, 30, 0, 0, time.UTC), ct.Time)
+	assert.NoError(t, ct.Scan(nil))
+	assert.True(t, ct.IsZero())
+	assert.Error(t, ct.Scan(42))
+}
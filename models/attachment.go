@@ -0,0 +1,77 @@
+package models
+
+import "time"
+
+// Attachment kinds
+const (
+	AttachmentPhoto    = "photo"
+	AttachmentDocument = "document"
+)
+
+// Attachment is a photo or document uploaded for an apartment
+type Attachment struct {
+	ID          int64  `json:"id"`
+	ApartmentID int64  `json:"apartment_id"`
+	Kind        string `json:"kind"` // photo or document
+	Filename    string `json:"filename"`
+	Caption     string `json:"caption"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	Width       *int   `json:"width"`  // Image width in pixels
+	Height      *int   `json:"height"` // Image height in pixels
+	Pages       *int   `json:"pages"`  // PDF page count
+	// ScanStatus is clean, infected (quarantined), or unscanned when no
+	// virus scanner was configured at upload
+	ScanStatus    string     `json:"scan_status"`
+	ScanSignature string     `json:"scan_signature,omitempty"` // Malware found in infected files
+	ScannedAt     *time.Time `json:"scanned_at"`
+	// SortOrder is the attachment's place in the apartment's gallery
+	SortOrder int `json:"sort_order"`
+	// IsCover marks the photo chosen to represent the apartment. Without
+	// one, the first photo in the gallery is used.
+	IsCover bool `json:"is_cover"`
+	// Location is where a photo was taken according to its GPS metadata,
+	// when GPS extraction is enabled
+	Location   *PhotoLocation `json:"location,omitempty"`
+	UploadedAt time.Time      `json:"uploaded_at"`
+}
+
+// PhotoLocation is the position a photo was taken at, compared with the
+// apartment's geocoded position to suggest or verify it
+type PhotoLocation struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	// DistanceM and NearApartment are null when the apartment has no
+	// coordinates, in which case the photo's position is a suggestion
+	DistanceM     *float64 `json:"distance_m"`
+	NearApartment *bool    `json:"near_apartment"`
+}
+
+// AttachmentRequest is used for updating an attachment's caption
+type AttachmentRequest struct {
+	Caption string `json:"caption" binding:"max=500"`
+}
+
+// AttachmentOrderRequest is used for reordering an apartment's gallery.
+// Attachments left out keep their relative order after the listed ones.
+type AttachmentOrderRequest struct {
+	IDs []int64 `json:"ids" binding:"required"`
+}
+
+// StorageUsage is how much space the household's uploaded files take up,
+// against the storage quota
+type StorageUsage struct {
+	Photos     FileUsage `json:"photos"`
+	Documents  FileUsage `json:"documents"`
+	FloorPlans FileUsage `json:"floor_plans"`
+	UsedBytes  int64     `json:"used_bytes"`
+	// QuotaBytes is 0 and RemainingBytes null when there's no quota
+	QuotaBytes     int64  `json:"quota_bytes"`
+	RemainingBytes *int64 `json:"remaining_bytes"`
+}
+
+// FileUsage counts the files of one kind and their total size
+type FileUsage struct {
+	Count int   `json:"count"`
+	Bytes int64 `json:"bytes"`
+}
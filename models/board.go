@@ -0,0 +1,14 @@
+package models
+
+// BoardColumn is one status column of the pipeline board, with its
+// apartments in board order
+type BoardColumn struct {
+	Status     string             `json:"status"`
+	Apartments []CompactApartment `json:"apartments"`
+}
+
+// BoardOrderRequest is used for reordering a board column. Apartments
+// left out keep their relative order after the listed ones.
+type BoardOrderRequest struct {
+	IDs []int64 `json:"ids" binding:"required"`
+}
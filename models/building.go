@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// Building is a building or complex with several of the units under
+// consideration, holding the details they share
+type Building struct {
+	ID               int64      `json:"id"`
+	Name             string     `json:"name"`
+	Address          string     `json:"address"`
+	CanonicalAddress string     `json:"canonical_address"`
+	Management       Management `json:"management"`
+	Amenities        []string   `json:"amenities"` // Shared by every unit
+	Notes            string     `json:"notes"`
+	UnitCount        int        `json:"unit_count"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
+
+// Management is who to contact about a building
+type Management struct {
+	Company string `json:"company"`
+	Contact string `json:"contact"`
+	Phone   string `json:"phone"`
+	Email   string `json:"email" binding:"omitempty,email"`
+}
+
+// BuildingRequest is used for creating/updating a building
+type BuildingRequest struct {
+	Name       string     `json:"name" binding:"max=200"`
+	Address    string     `json:"address" binding:"required"`
+	Management Management `json:"management"`
+	Amenities  []string   `json:"amenities"`
+	Notes      string     `json:"notes"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// CalendarConnection is the Google Calendar visits are synced with
+type CalendarConnection struct {
+	CalendarID   string     `json:"calendar_id"`
+	RefreshToken string     `json:"-"`
+	AccessToken  string     `json:"-"`
+	ExpiresAt    *time.Time `json:"-"`
+	SyncToken    string     `json:"-"` // Where the last pull of changes left off
+	ConnectedAt  time.Time  `json:"connected_at"`
+}
+
+// CalendarVisit is a visit along with what's needed to put it on the
+// calendar
+type CalendarVisit struct {
+	VisitID     int64
+	ApartmentID int64
+	Address     string
+	VisitedAt   time.Time
+	Notes       string
+	Private     bool       // The apartment is private, so the visit stays off the calendar
+	EventID     *string    // nil until the visit is on the calendar, empty once removed there
+	Hash        string     // Of the event as last written
+	EventStart  *time.Time // The event's start as last written or read
+}
@@ -0,0 +1,10 @@
+package models
+
+// CaptureRequest is a listing page sent by the bookmarklet: its URL and
+// title, and the selected part of the page, as HTML, text, or both
+type CaptureRequest struct {
+	URL   string `json:"url" binding:"required,url"`
+	Title string `json:"title" binding:"max=1000"`
+	HTML  string `json:"html"`
+	Text  string `json:"text"`
+}
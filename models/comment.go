@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// Comment is one message in the discussion thread of an apartment
+type Comment struct {
+	ID          int64  `json:"id"`
+	ApartmentID int64  `json:"apartment_id"`
+	AuthorID    *int64 `json:"author_id"` // nil when posted without X-User-ID
+	Author      string `json:"author"`    // The author's name, or empty
+	Body        string `json:"body"`
+	// Mentions are the users the body @mentions by name
+	Mentions  []int64   `json:"mentions"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CommentRequest is used for creating/updating a comment
+type CommentRequest struct {
+	Body string `json:"body" binding:"required,max=4000"`
+	// AuthorID is the user posting the comment, set from the request's
+	// viewer rather than the body
+	AuthorID *int64 `json:"-"`
+}
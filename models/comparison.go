@@ -0,0 +1,92 @@
+package models
+
+import (
+	"time"
+
+	"github.com/mojotx/apt-eval/scoring"
+)
+
+// ComparedApartment is one column of a side-by-side comparison: the
+// apartment plus details gathered from its sub-resources
+type ComparedApartment struct {
+	Apartment
+
+	VisitCount int `json:"visit_count"`
+	// Environment holds the observations from the most recent visit
+	Environment *Visit `json:"environment"`
+
+	Rooms []Room `json:"rooms"`
+
+	// Split is each roommate's share of the true monthly cost, when there
+	// are roommates and the price is known
+	Split *RentSplit `json:"split"`
+}
+
+// RankedApartment is an apartment's place in the ranking by score, with
+// each category's contribution to the score. Apartments without a score
+// are listed last with no rank.
+type RankedApartment struct {
+	Rank *int `json:"rank"`
+	Apartment
+	Contributions []scoring.Contribution `json:"contributions"`
+}
+
+// DecisionMatrix weighs every apartment against every scoring criterion.
+// Apartments are in rank order, and each one's Cells follow Criteria.
+type DecisionMatrix struct {
+	Criteria   []MatrixCriterion `json:"criteria"`
+	Apartments []MatrixColumn    `json:"apartments"`
+}
+
+// MatrixCriterion is a scoring category and its weight, with Share the
+// weight's fraction of all the weights
+type MatrixCriterion struct {
+	Category string  `json:"category"`
+	Weight   float64 `json:"weight"`
+	Share    float64 `json:"share"`
+}
+
+// MatrixColumn is one apartment's ratings and weighted points in a
+// DecisionMatrix
+type MatrixColumn struct {
+	ID      int64                  `json:"id"`
+	Address string                 `json:"address"`
+	Rank    *int                   `json:"rank"`
+	Score   *float64               `json:"score"`
+	Cells   []scoring.Contribution `json:"cells"`
+}
+
+// SharedComparison is a snapshot of a comparison published under a token.
+// It doesn't change when the apartments are later edited.
+type SharedComparison struct {
+	Token      string              `json:"token"`
+	Title      string              `json:"title"`
+	Apartments []ComparedApartment `json:"apartments"`
+	ExpiresAt  *time.Time          `json:"expires_at"`
+	CreatedAt  time.Time           `json:"created_at"`
+}
+
+// ShareRequest is used for publishing a comparison
+type ShareRequest struct {
+	IDs   []int64 `json:"ids" binding:"required,min=1,max=20"`
+	Title string  `json:"title" binding:"max=200"`
+	// ExpiresInDays limits how long the link works; it never expires when
+	// omitted
+	ExpiresInDays *int `json:"expires_in_days" binding:"omitempty,min=1"`
+}
+
+// CompareSet is a named group of apartments to compare, such as
+// "finalists" or "backup options"
+type CompareSet struct {
+	ID           int64     `json:"id"`
+	Name         string    `json:"name"`
+	ApartmentIDs []int64   `json:"apartment_ids"` // In the order they're compared
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// CompareSetRequest is used for creating/updating a comparison set
+type CompareSetRequest struct {
+	Name         string  `json:"name" binding:"required,max=100"`
+	ApartmentIDs []int64 `json:"apartment_ids" binding:"required,min=1,max=20"`
+}
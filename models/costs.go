@@ -0,0 +1,117 @@
+package models
+
+// MoveInCosts are the costs of moving into an apartment beyond its rent.
+// Unset amounts are zero.
+type MoveInCosts struct {
+	ApartmentID     int64   `json:"apartment_id"`
+	SecurityDeposit float64 `json:"security_deposit"`
+	PetDeposit      float64 `json:"pet_deposit"`
+	ApplicationFee  float64 `json:"application_fee"`
+	AdminFee        float64 `json:"admin_fee"`
+	BrokerFee       float64 `json:"broker_fee"`
+	OtherFees       float64 `json:"other_fees"`
+	MoverEstimate   float64 `json:"mover_estimate"`
+	MonthlyFees     float64 `json:"monthly_fees"` // Recurring charges on top of rent, such as pet rent
+
+	// MoveInDate prorates the first month's rent when it isn't the 1st
+	MoveInDate *CustomTime `json:"move_in_date"`
+}
+
+// MoveInCostsRequest is used for setting an apartment's move-in costs
+type MoveInCostsRequest struct {
+	SecurityDeposit float64     `json:"security_deposit" binding:"min=0"`
+	PetDeposit      float64     `json:"pet_deposit" binding:"min=0"`
+	ApplicationFee  float64     `json:"application_fee" binding:"min=0"`
+	AdminFee        float64     `json:"admin_fee" binding:"min=0"`
+	BrokerFee       float64     `json:"broker_fee" binding:"min=0"`
+	OtherFees       float64     `json:"other_fees" binding:"min=0"`
+	MoverEstimate   float64     `json:"mover_estimate" binding:"min=0"`
+	MonthlyFees     float64     `json:"monthly_fees" binding:"min=0"`
+	MoveInDate      *CustomTime `json:"move_in_date"`
+}
+
+// CostItem is one line of a cost breakdown
+type CostItem struct {
+	Label      string  `json:"label"`
+	Amount     float64 `json:"amount"`
+	Refundable bool    `json:"refundable"` // Deposits come back at move-out
+}
+
+// CostBreakdown itemizes what an apartment costs to move into and to live
+// in for a year
+type CostBreakdown struct {
+	ApartmentID int64       `json:"apartment_id"`
+	Inputs      MoveInCosts `json:"inputs"`
+
+	// MoveIn is the cash due at move-in, deposits included
+	MoveIn      []CostItem `json:"move_in"`
+	MoveInTotal float64    `json:"move_in_total"`
+
+	Monthly      []CostItem `json:"monthly"`
+	MonthlyTotal float64    `json:"monthly_total"`
+
+	// FirstYearTotal is the cost of occupancy for the first year: twelve
+	// months of monthly costs plus the one-time costs that aren't
+	// refunded
+	FirstYearTotal float64 `json:"first_year_total"`
+}
+
+// MortgageTerms are the assumptions behind a buy-vs-rent comparison.
+// Percentages are of the purchase price, and yearly where they're rates.
+type MortgageTerms struct {
+	DownPaymentPct float64 `json:"down_payment_pct"`
+	RatePct        float64 `json:"rate_pct"` // Yearly interest rate
+	Years          int     `json:"years"`
+	PropertyTaxPct float64 `json:"property_tax_pct"` // Used when the property tax isn't known
+	InsurancePct   float64 `json:"insurance_pct"`
+	MaintenancePct float64 `json:"maintenance_pct"`
+	ClosingCostPct float64 `json:"closing_cost_pct"`
+}
+
+// DefaultMortgageTerms are typical terms for a 30-year fixed mortgage
+var DefaultMortgageTerms = MortgageTerms{
+	DownPaymentPct: 20,
+	RatePct:        6.5,
+	Years:          30,
+	PropertyTaxPct: 1.1,
+	InsurancePct:   0.35,
+	MaintenancePct: 1,
+	ClosingCostPct: 3,
+}
+
+// RentComparison is a rental's monthly cost next to buying
+type RentComparison struct {
+	ApartmentID  int64   `json:"apartment_id"`
+	Address      string  `json:"address"`
+	Unit         string  `json:"unit"`
+	Status       string  `json:"status"`
+	MonthlyTotal float64 `json:"monthly_total"`
+	MoveInTotal  float64 `json:"move_in_total"`
+
+	// Difference is buying's monthly cost less the rental's; positive
+	// when buying costs more each month
+	Difference float64 `json:"difference"`
+}
+
+// BuyVsRent itemizes what a home for sale costs to buy and to live in,
+// compared with the rentals
+type BuyVsRent struct {
+	ApartmentID   int64         `json:"apartment_id"`
+	Terms         MortgageTerms `json:"terms"`
+	PurchasePrice float64       `json:"purchase_price"`
+	LoanAmount    float64       `json:"loan_amount"`
+
+	// Upfront is the cash due at closing
+	Upfront      []CostItem `json:"upfront"`
+	UpfrontTotal float64    `json:"upfront_total"`
+
+	Monthly      []CostItem `json:"monthly"`
+	MonthlyTotal float64    `json:"monthly_total"`
+
+	// EquityFirstYear is the loan principal paid off in the first year,
+	// part of the monthly cost that stays with the owner
+	EquityFirstYear float64 `json:"equity_first_year"`
+
+	// Rentals are cheapest first
+	Rentals []RentComparison `json:"rentals"`
+}
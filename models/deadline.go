@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// Kinds of deadline
+const (
+	DeadlineApplication = "application" // The application is due
+	DeadlineHold        = "hold"        // The landlord stops holding the unit
+)
+
+// Deadline is an upcoming deadline on an apartment
+type Deadline struct {
+	ApartmentID int64     `json:"apartment_id"`
+	Address     string    `json:"address"`
+	Status      string    `json:"status"`
+	Kind        string    `json:"kind"` // application or hold
+	Due         time.Time `json:"due"`
+	DaysLeft    int       `json:"days_left"` // Whole days until Due
+}
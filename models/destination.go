@@ -0,0 +1,53 @@
+package models
+
+import "time"
+
+// Commute modes
+const (
+	ModeDrive   = "drive"
+	ModeTransit = "transit"
+	ModeBike    = "bike"
+)
+
+// Destination is a place the household travels to regularly (work,
+// school, ...). Commutes from each apartment to every destination make up
+// its commute matrix.
+type Destination struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Address   string    `json:"address"`
+	Latitude  float64   `json:"latitude"`
+	Longitude float64   `json:"longitude"`
+	Mode      string    `json:"mode"`   // drive, transit, or bike
+	Weight    float64   `json:"weight"` // Relative importance in the commute score
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// DestinationRequest is used for creating/updating a destination
+type DestinationRequest struct {
+	Name      string   `json:"name" binding:"required"`
+	Address   string   `json:"address"`
+	Latitude  *float64 `json:"latitude" binding:"required,latitude"`
+	Longitude *float64 `json:"longitude" binding:"required,longitude"`
+	Mode      string   `json:"mode" binding:"required,oneof=drive transit bike"`
+	Weight    *float64 `json:"weight" binding:"omitempty,gte=0"`
+}
+
+// Commute is the travel time from an apartment to one destination
+type Commute struct {
+	DestinationID  int64     `json:"destination_id"`
+	Destination    string    `json:"destination"`
+	Mode           string    `json:"mode"`
+	Minutes        int       `json:"minutes"`
+	DistanceMeters int       `json:"distance_m"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// CommuteMatrix lists an apartment's commutes to every destination along
+// with its weighted commute score (0-100, higher is better)
+type CommuteMatrix struct {
+	ApartmentID int64     `json:"apartment_id"`
+	Score       *float64  `json:"commute_score"`
+	Commutes    []Commute `json:"commutes"`
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// ExportRecord links an apartment to the row an exporter made for it in an
+// outside service
+type ExportRecord struct {
+	Exporter    string    `json:"exporter"`
+	ApartmentID int64     `json:"apartment_id"`
+	RemoteID    string    `json:"remote_id"`
+	Hash        string    `json:"hash"` // Of the fields last sent
+	SyncedAt    time.Time `json:"synced_at"`
+}
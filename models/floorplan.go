@@ -0,0 +1,43 @@
+package models
+
+import (
+	"math"
+	"time"
+)
+
+// RoomDimensions records the size of one room on a floor plan
+type RoomDimensions struct {
+	Name    string  `json:"name" binding:"required"`
+	WidthM  float64 `json:"width_m" binding:"gte=0"`
+	LengthM float64 `json:"length_m" binding:"gte=0"`
+	// AreaM2 is width × length unless given explicitly, for rooms that
+	// aren't rectangular
+	AreaM2 float64 `json:"area_m2" binding:"gte=0"`
+}
+
+// Area returns the room's area, computing it from the dimensions when
+// not set
+func (r RoomDimensions) Area() float64 {
+	if r.AreaM2 > 0 {
+		return r.AreaM2
+	}
+	return math.Round(r.WidthM*r.LengthM*100) / 100
+}
+
+// FloorPlan describes an apartment's floor plan file and the room
+// dimensions recorded from it
+type FloorPlan struct {
+	ApartmentID int64            `json:"apartment_id"`
+	Filename    string           `json:"filename"`
+	ContentType string           `json:"content_type"`
+	Size        int64            `json:"size"`
+	Width       *int             `json:"width"`  // Image width in pixels
+	Height      *int             `json:"height"` // Image height in pixels
+	Pages       *int             `json:"pages"`  // PDF page count
+	Rooms       []RoomDimensions `json:"rooms"`
+	TotalAreaM2 float64          `json:"total_area_m2"`
+	ScanStatus  string           `json:"scan_status"` // clean, or unscanned when no virus scanner was configured
+	ScannedAt   *time.Time       `json:"scanned_at"`
+	UploadedAt  time.Time        `json:"uploaded_at"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+}
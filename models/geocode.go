@@ -0,0 +1,18 @@
+package models
+
+// Geocode is the position a geocoding provider found for an address
+type Geocode struct {
+	Query            string  `json:"query"`
+	Latitude         float64 `json:"latitude"`
+	Longitude        float64 `json:"longitude"`
+	FormattedAddress string  `json:"formatted_address"` // The provider's canonical form of the address
+	Provider         string  `json:"provider"`
+}
+
+// AddressSuggestion is an autocomplete match for a partly typed address.
+// Coordinates are null when the provider doesn't return them.
+type AddressSuggestion struct {
+	Address   string   `json:"address"`
+	Latitude  *float64 `json:"latitude"`
+	Longitude *float64 `json:"longitude"`
+}
@@ -0,0 +1,56 @@
+package models
+
+// Import modes
+const (
+	ImportCreate = "create" // Every row is a new apartment
+	ImportUpsert = "upsert" // A row updates the apartment at its address and unit, if there is one
+)
+
+// Outcomes of importing a row
+const (
+	ImportCreated   = "created"
+	ImportUpdated   = "updated"
+	ImportUnchanged = "unchanged" // Matched an apartment that already has its values
+	ImportRejected  = "rejected"  // Invalid, or it repeats an earlier row's address
+	ImportFailed    = "failed"    // A server error; import the row again later
+)
+
+// ImportReport is the outcome of an import, or what it would be on a dry
+// run, with a result for each row
+type ImportReport struct {
+	Mode      string         `json:"mode"`
+	DryRun    bool           `json:"dry_run"`
+	Created   int            `json:"created"`
+	Updated   int            `json:"updated"`
+	Unchanged int            `json:"unchanged"`
+	Rejected  int            `json:"rejected"`
+	Failed    int            `json:"failed"`
+	Results   []ImportResult `json:"results"`
+}
+
+// ImportResult is the outcome of importing one row
+type ImportResult struct {
+	Line    int      `json:"line"` // Where the row starts in the file, the header being line 1
+	Address string   `json:"address"`
+	Status  string   `json:"status"`
+	ID      int64    `json:"id,omitempty"`      // The apartment updated, or created unless on a dry run
+	Changes []string `json:"changes,omitempty"` // The fields an update changes
+	Error   string   `json:"error,omitempty"`
+}
+
+// Add adds a result to the report, counting it under its status
+func (r *ImportReport) Add(result ImportResult) {
+	switch result.Status {
+	case ImportCreated:
+		r.Created++
+	case ImportUpdated:
+		r.Updated++
+	case ImportUnchanged:
+		r.Unchanged++
+	case ImportRejected:
+		r.Rejected++
+	case ImportFailed:
+		r.Failed++
+	}
+	r.Results = append(r.Results, result)
+}
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// MediaLink is a video walkthrough or 3D tour of an apartment hosted on
+// YouTube, Vimeo, or Matterport, with the metadata its provider reports
+type MediaLink struct {
+	ID           int64      `json:"id"`
+	ApartmentID  int64      `json:"apartment_id"`
+	URL          string     `json:"url"`
+	Provider     string     `json:"provider"`
+	Title        string     `json:"title"`
+	AuthorName   string     `json:"author_name"`
+	ThumbnailURL string     `json:"thumbnail_url"`
+	EmbedHTML    string     `json:"embed_html"` // An iframe of the provider's player
+	Width        *int       `json:"width"`
+	Height       *int       `json:"height"`
+	FetchedAt    *time.Time `json:"fetched_at"` // Null until the metadata has been fetched
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// MediaProviderPlaceholder is the provider of the placeholder photos given
+// to apartments generated for load testing. They have a thumbnail and
+// nothing to embed.
+const MediaProviderPlaceholder = "Placeholder"
+
+// MediaLinkRequest is used for adding a media link
+type MediaLinkRequest struct {
+	URL string `json:"url" binding:"required,url"`
+}
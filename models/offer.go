@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// Sides of a negotiation
+const (
+	OfferFromUs       = "us"
+	OfferFromLandlord = "landlord"
+)
+
+// Offer is one offer or counteroffer of monthly rent in the negotiation
+// over an apartment
+type Offer struct {
+	ID          int64      `json:"id"`
+	ApartmentID int64      `json:"apartment_id"`
+	OfferedAt   CustomTime `json:"offered_at"`
+	Amount      float64    `json:"amount"`
+	Who         string     `json:"who"` // us or landlord
+	Notes       string     `json:"notes"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// OfferRequest is used for creating/updating an offer. OfferedAt defaults
+// to now.
+type OfferRequest struct {
+	OfferedAt CustomTime `json:"offered_at"`
+	Amount    float64    `json:"amount" binding:"required,gt=0"`
+	Who       string     `json:"who" binding:"required,oneof=us landlord"`
+	Notes     string     `json:"notes"`
+}
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// ErasedCommentBody replaces the text of an erased user's comments, so the
+// threads they were part of still read in order
+const ErasedCommentBody = "[deleted]"
+
+// UserExport is everything stored about a user, for them to take away
+type UserExport struct {
+	ExportedAt    time.Time      `json:"exported_at"`
+	User          User           `json:"user"`
+	Apartments    []Apartment    `json:"apartments"` // Those they added
+	Comments      []Comment      `json:"comments"`
+	Reactions     []Reaction     `json:"reactions"`
+	Activity      []Activity     `json:"activity"` // What they did
+	Notifications []Notification `json:"notifications"`
+	AlertRules    []AlertRule    `json:"alert_rules"`
+}
+
+// UserErasure counts what erasing a user removed or scrubbed
+type UserErasure struct {
+	ApartmentsDeleted  int64 `json:"apartments_deleted"`  // Their private apartments
+	ApartmentsDisowned int64 `json:"apartments_disowned"` // Apartments they added that aren't private, kept without an owner
+	CommentsScrubbed   int64 `json:"comments_scrubbed"`
+	ReactionsRemoved   int64 `json:"reactions_removed"`
+	ActivityRemoved    int64 `json:"activity_removed"` // Entries about their private apartments and reactions
+
+	// The private apartments deleted and their attachments, whose stored
+	// files go too
+	DeletedApartments []int64      `json:"-"`
+	Attachments       []Attachment `json:"-"`
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Reactions household members can leave on an apartment
+const (
+	ReactionThumbsUp   = "thumbs_up"
+	ReactionThumbsDown = "thumbs_down"
+	ReactionHeart      = "heart"
+	ReactionThinking   = "thinking"
+)
+
+// ReactionEmoji is how each reaction is shown
+var ReactionEmoji = map[string]string{
+	ReactionThumbsUp:   "👍",
+	ReactionThumbsDown: "👎",
+	ReactionHeart:      "❤️",
+	ReactionThinking:   "🤔",
+}
+
+// ParseReaction accepts a reaction by name or as its emoji, with or without
+// the variation selector, and returns its name
+func ParseReaction(s string) (string, bool) {
+	if _, ok := ReactionEmoji[s]; ok {
+		return s, true
+	}
+	s = strings.TrimSuffix(s, "\ufe0f")
+	for name, emoji := range ReactionEmoji {
+		if s == strings.TrimSuffix(emoji, "\ufe0f") {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// ReactionCounts is how many household members left each reaction
+type ReactionCounts struct {
+	ThumbsUp   int `json:"thumbs_up"`
+	ThumbsDown int `json:"thumbs_down"`
+	Heart      int `json:"heart"`
+	Thinking   int `json:"thinking"`
+}
+
+// Reaction is one user's reaction to an apartment
+type Reaction struct {
+	ApartmentID int64     `json:"apartment_id"`
+	UserID      int64     `json:"user_id"`
+	User        string    `json:"user"` // The user's name
+	Reaction    string    `json:"reaction"`
+	CreatedAt   time.Time `json:"created_at"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// RetentionReport lists what a retention run changed, or would change on
+// a dry run
+type RetentionReport struct {
+	DryRun              bool                `json:"dry_run"`
+	Archived            []ArchivedApartment `json:"archived"`
+	PurgedTombstones    int64               `json:"purged_tombstones"`    // Sync records of deleted apartments
+	PurgedUndoData      int64               `json:"purged_undo_data"`     // Activity entries that can no longer be undone
+	PrunedActivity      int64               `json:"pruned_activity"`      // Oldest activity entries past the row limit
+	PrunedNotifications int64               `json:"pruned_notifications"` // Oldest delivered or abandoned notifications past the row limit
+}
+
+// ArchivedApartment is an apartment archived for being untouched
+type ArchivedApartment struct {
+	ID          int64     `json:"id"`
+	Address     string    `json:"address"`
+	Status      string    `json:"status"` // Status before archiving
+	LastTouched time.Time `json:"last_touched"`
+}
@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// Room is the evaluation of a single room within an apartment
+type Room struct {
+	ID          int64     `json:"id"`
+	ApartmentID int64     `json:"apartment_id"`
+	Name        string    `json:"name"` // e.g. "Bedroom 2"
+	Kind        string    `json:"kind"` // kitchen, bedroom, bathroom, ...
+	Rating      *int      `json:"rating"`
+	WidthM      *float64  `json:"width_m"`
+	LengthM     *float64  `json:"length_m"`
+	AreaM2      *float64  `json:"area_m2"`
+	Notes       string    `json:"notes"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// RoomRequest is used for creating/updating a room
+type RoomRequest struct {
+	Name    string   `json:"name" binding:"required"`
+	Kind    string   `json:"kind" binding:"required,oneof=kitchen bedroom bathroom living dining office laundry closet balcony other"`
+	Rating  *int     `json:"rating" binding:"omitempty,min=1,max=5"`
+	WidthM  *float64 `json:"width_m" binding:"omitempty,gt=0"`
+	LengthM *float64 `json:"length_m" binding:"omitempty,gt=0"`
+	// AreaM2 defaults to width × length when both are given
+	AreaM2 *float64 `json:"area_m2" binding:"omitempty,gt=0"`
+	Notes  string   `json:"notes"`
+}
+
+// Area returns the requested area, computing it from the dimensions when
+// not given
+func (r *RoomRequest) Area() *float64 {
+	if r.AreaM2 != nil || r.WidthM == nil || r.LengthM == nil {
+		return r.AreaM2
+	}
+	area := RoomDimensions{WidthM: *r.WidthM, LengthM: *r.LengthM}.Area()
+	return &area
+}
@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+// Roommate is a person sharing the rent
+type Roommate struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Percent   *float64  `json:"percent"` // Share of the rent under the custom split rule
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// RoommateRequest is used for creating/updating a roommate
+type RoommateRequest struct {
+	Name    string   `json:"name" binding:"required,max=100"`
+	Percent *float64 `json:"percent" binding:"omitempty,gt=0,max=100"`
+}
+
+// Rent split rules
+const (
+	SplitEqual    = "equal"     // Everyone pays the same
+	SplitRoomSize = "room_size" // In proportion to the area of each roommate's room
+	SplitCustom   = "custom"    // By each roommate's percent
+)
+
+// RentSplitRequest is used for choosing the split rule
+type RentSplitRequest struct {
+	Rule string `json:"rule" binding:"required,oneof=equal room_size custom"`
+}
+
+// RoomAssignment puts a roommate in one of an apartment's rooms. Roommates
+// sharing a room share its area.
+type RoomAssignment struct {
+	RoommateID int64 `json:"roommate_id" binding:"required"`
+	RoomID     int64 `json:"room_id" binding:"required"`
+}
+
+// RentSplit divides an apartment's true monthly cost between the
+// roommates. Rule is the rule actually applied, which falls back to equal
+// when the chosen rule lacks a room area or percent for someone.
+type RentSplit struct {
+	Rule   string          `json:"rule"`
+	Total  float64         `json:"total"`
+	Shares []RoommateShare `json:"shares"`
+}
+
+// RoommateShare is one roommate's part of the rent
+type RoommateShare struct {
+	RoommateID  int64   `json:"roommate_id"`
+	Name        string  `json:"name"`
+	Percent     float64 `json:"percent"`
+	MonthlyCost float64 `json:"monthly_cost"`
+}
@@ -0,0 +1,19 @@
+package models
+
+// Apartment statuses, in pipeline order
+const (
+	StatusDraft       = "draft" // Captured automatically, awaiting review
+	StatusConsidering = "considering"
+	StatusScheduled   = "scheduled"
+	StatusVisited     = "visited"
+	StatusApplied     = "applied"
+	StatusSigned      = "signed"
+	StatusRejected    = "rejected"
+	StatusArchived    = "archived" // Untouched for a long time; see retention
+)
+
+// Statuses lists every apartment status in pipeline order
+var Statuses = []string{
+	StatusDraft, StatusConsidering, StatusScheduled, StatusVisited,
+	StatusApplied, StatusSigned, StatusRejected, StatusArchived,
+}
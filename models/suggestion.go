@@ -0,0 +1,20 @@
+package models
+
+// Kinds of typeahead suggestion
+const (
+	SuggestAddress      = "address"
+	SuggestNeighborhood = "neighborhood"
+	SuggestBuilding     = "building"
+	SuggestAmenity      = "amenity"
+)
+
+// Suggestion is a completion for text typed into the search bar
+type Suggestion struct {
+	Kind string `json:"kind"`
+	Text string `json:"text"`
+	// ID is the apartment, building, or amenity suggested. It's left out
+	// for neighborhoods, which are shared by many apartments.
+	ID    *int64  `json:"id,omitempty"`
+	Key   string  `json:"key,omitempty"` // The amenity key, for amenities
+	Score float64 `json:"score"`         // 1 or more for prefix matches, less for near misses
+}
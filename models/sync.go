@@ -0,0 +1,72 @@
+package models
+
+import "time"
+
+// Sync operations
+const (
+	SyncCreate = "create"
+	SyncUpdate = "update"
+	SyncDelete = "delete"
+)
+
+// Sync outcomes of a change sent by a client
+const (
+	SyncApplied  = "applied"
+	SyncConflict = "conflict" // Changed on the server since the client's version
+	SyncRejected = "rejected" // Invalid, or the apartment doesn't exist
+	SyncFailed   = "failed"   // A server error; send the change again later
+)
+
+// ApartmentChange is the latest change to an apartment. Every change moves
+// the apartment to a new, higher version.
+type ApartmentChange struct {
+	ApartmentID int64     `json:"id"`
+	UUID        string    `json:"uuid,omitempty"` // Empty for apartments deleted before they had one
+	Version     int64     `json:"version"`
+	Deleted     bool      `json:"deleted"`
+	ChangedAt   time.Time `json:"changed_at"`
+	// Apartment is the apartment as it is now; nil when deleted, or
+	// private to someone else
+	Apartment *Apartment `json:"apartment,omitempty"`
+}
+
+// SyncChanges is a page of changes since a cursor
+type SyncChanges struct {
+	Changes []ApartmentChange `json:"changes"`
+	Cursor  string            `json:"cursor"` // Pass as since to get the next changes
+	HasMore bool              `json:"has_more"`
+}
+
+// SyncChange is one change made on a client while offline
+type SyncChange struct {
+	Op string `json:"op" binding:"required,oneof=create update delete"`
+	// ID or UUID is the apartment to update or delete
+	ID   int64  `json:"id"`
+	UUID string `json:"uuid"`
+	// Version is the apartment's version the update or delete was made
+	// on; a newer one on the server is a conflict
+	Version int64 `json:"version"`
+	// ClientID is the client's own ID for a created apartment, echoed in
+	// the result
+	ClientID  string            `json:"client_id" binding:"max=200"`
+	Apartment *ApartmentRequest `json:"apartment"`
+}
+
+// SyncRequest is a batch of changes made on a client, applied in order
+type SyncRequest struct {
+	Changes []SyncChange `json:"changes" binding:"required,max=500,dive"`
+}
+
+// SyncResult is the outcome of one change. On a conflict, Apartment and
+// Version are the server's, or Deleted is set when the server deleted it.
+type SyncResult struct {
+	Op        string     `json:"op"`
+	ID        int64      `json:"id,omitempty"`
+	UUID      string     `json:"uuid,omitempty"`
+	ClientID  string     `json:"client_id,omitempty"`
+	Status    string     `json:"status"`
+	Error     string     `json:"error,omitempty"`
+	Version   int64      `json:"version,omitempty"`
+	Deleted   bool       `json:"deleted,omitempty"`
+	Apartment *Apartment `json:"apartment,omitempty"`
+}
@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// Template field types
+const (
+	FieldText    = "text"
+	FieldNumber  = "number"
+	FieldBoolean = "boolean"
+	FieldChoice  = "choice"
+	FieldRating  = "rating"
+	FieldDate    = "date"
+)
+
+// TemplateField is one question on an evaluation form. Choice fields list
+// their allowed answers in Options; number fields may be bounded by Min and
+// Max.
+type TemplateField struct {
+	Key      string   `json:"key" binding:"required,max=64"`
+	Label    string   `json:"label" binding:"required"`
+	Type     string   `json:"type" binding:"required,oneof=text number boolean choice rating date"`
+	Required bool     `json:"required"`
+	Options  []string `json:"options,omitempty"`
+	Min      *float64 `json:"min,omitempty"`
+	Max      *float64 `json:"max,omitempty"`
+}
+
+// Template is a user-defined evaluation form. While a template is active,
+// apartment answers are validated against its fields.
+type Template struct {
+	ID        int64           `json:"id"`
+	Name      string          `json:"name"`
+	Fields    []TemplateField `json:"fields"`
+	Active    bool            `json:"active"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// TemplateRequest is used for creating/updating a template
+type TemplateRequest struct {
+	Name   string          `json:"name" binding:"required"`
+	Fields []TemplateField `json:"fields" binding:"required,min=1,dive"`
+}
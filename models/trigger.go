@@ -0,0 +1,52 @@
+package models
+
+import "time"
+
+// Polling triggers, for automation services like Zapier and IFTTT
+const (
+	TriggerNewApartment  = "new_apartment"
+	TriggerStatusChanged = "status_changed"
+	TriggerRatingChanged = "rating_changed"
+)
+
+// TriggerEvent is one event a polling trigger reports, built from an
+// activity feed entry
+type TriggerEvent struct {
+	// ID is the activity entry's, so each event is seen once however often
+	// the trigger is polled
+	ID          int64     `json:"id"`
+	Trigger     string    `json:"trigger"`
+	ApartmentID int64     `json:"apartment_id"`
+	Address     string    `json:"address"` // As it was at the time
+	User        string    `json:"user"`    // Who did it, or empty
+	OccurredAt  time.Time `json:"occurred_at"`
+
+	// For status changes; empty otherwise
+	PreviousStatus string `json:"previous_status"`
+	NewStatus      string `json:"new_status"`
+
+	// Apartment is as it is now; nil once deleted
+	Apartment *Apartment `json:"apartment"`
+}
+
+// ActionField describes one input of an action, in the shape of a
+// Zapier input field
+type ActionField struct {
+	Key      string   `json:"key"`
+	Label    string   `json:"label"`
+	Type     string   `json:"type"` // string, text, number, integer, boolean, or datetime
+	Required bool     `json:"required"`
+	HelpText string   `json:"helpText,omitempty"`
+	Choices  []string `json:"choices,omitempty"`
+}
+
+// ActionSchema describes an action an automation service can perform:
+// the request to send and the fields it takes
+type ActionSchema struct {
+	Key         string        `json:"key"`
+	Noun        string        `json:"noun"`
+	Label       string        `json:"label"`
+	Method      string        `json:"method"`
+	URL         string        `json:"url"`
+	InputFields []ActionField `json:"inputFields"`
+}
@@ -0,0 +1,92 @@
+package models
+
+import "time"
+
+// User is someone in the household who receives notifications
+type User struct {
+	ID       int64  `json:"id"`
+	Name     string `json:"name"`
+	Phone    string `json:"phone"`    // E.164, e.g. +15551234567
+	Timezone string `json:"timezone"` // IANA name, e.g. America/Denver
+
+	// Messages are held during quiet hours, given as local "HH:MM" times.
+	// The range may wrap past midnight; empty means no quiet hours.
+	QuietStart string `json:"quiet_start"`
+	QuietEnd   string `json:"quiet_end"`
+
+	NotifyVisits    bool      `json:"notify_visits"`    // Reminders before scheduled visits
+	NotifyStatus    bool      `json:"notify_status"`    // Apartment status changes
+	NotifyDeadlines bool      `json:"notify_deadlines"` // Reminders before application and hold deadlines
+	NotifyMentions  bool      `json:"notify_mentions"`  // @mentions in comments
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// UserRequest is used for creating/updating a user
+type UserRequest struct {
+	Name            string `json:"name" binding:"required,max=100"`
+	Phone           string `json:"phone" binding:"omitempty,e164"`
+	Timezone        string `json:"timezone" binding:"omitempty,timezone"`
+	QuietStart      string `json:"quiet_start" binding:"required_with=QuietEnd,omitempty,datetime=15:04"`
+	QuietEnd        string `json:"quiet_end" binding:"required_with=QuietStart,omitempty,datetime=15:04"`
+	NotifyVisits    *bool  `json:"notify_visits"`
+	NotifyStatus    *bool  `json:"notify_status"`
+	NotifyDeadlines *bool  `json:"notify_deadlines"`
+	NotifyMentions  *bool  `json:"notify_mentions"`
+}
+
+// Notification events
+const (
+	EventVisitReminder       = "visit_reminder"
+	EventStatusChange        = "status_change"
+	EventApplicationDeadline = "application_deadline"
+	EventHoldExpiry          = "hold_expiry"
+	EventMention             = "mention"
+	EventAlert               = "alert" // An alert rule went off
+)
+
+// Notification delivery statuses
+const (
+	NotificationPending = "pending"
+	NotificationSent    = "sent"
+	NotificationFailed  = "failed"  // Gave up after repeated errors
+	NotificationExpired = "expired" // No longer relevant by the time it could be sent
+)
+
+// Notification is a message queued for a user
+type Notification struct {
+	ID        int64      `json:"id"`
+	UserID    int64      `json:"user_id"`
+	Event     string     `json:"event"`
+	Body      string     `json:"body"`
+	Status    string     `json:"status"`
+	Attempts  int        `json:"attempts"`
+	Error     string     `json:"error,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	SentAt    *time.Time `json:"sent_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// OutgoingNotification is a pending notification along with the recipient
+// details needed to deliver it
+type OutgoingNotification struct {
+	Notification
+	User User
+}
+
+// DeadlineReminder is an upcoming deadline that hasn't been reminded about
+// yet. Event names the kind of deadline.
+type DeadlineReminder struct {
+	ApartmentID int64
+	Address     string
+	Event       string
+	Due         time.Time
+}
+
+// VisitReminder is an upcoming visit that hasn't been reminded about yet
+type VisitReminder struct {
+	VisitID     int64
+	ApartmentID int64
+	Address     string
+	VisitedAt   time.Time
+}
@@ -0,0 +1,19 @@
+package models
+
+// Apartment visibilities
+const (
+	VisibilityPrivate   = "private"   // Only its owner sees it
+	VisibilityHousehold = "household" // Every household member, but not share links
+	VisibilityShared    = "shared"    // The household, and anyone sent a share link
+)
+
+// DefaultVisibility is the visibility of apartments created without one.
+// Publishing an apartment beyond the household takes choosing shared.
+const DefaultVisibility = VisibilityHousehold
+
+// VisibleTo reports whether the household member userID may see the
+// apartment. A userID of 0 is someone who hasn't said who they are, who
+// sees everything but private apartments.
+func (a *Apartment) VisibleTo(userID int64) bool {
+	return a.Visibility != VisibilityPrivate || (a.OwnerID != nil && *a.OwnerID == userID)
+}
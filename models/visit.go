@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// Visit records the environment observed on one visit to an apartment.
+// Conditions like noise and light vary by time of day, so they are kept
+// per visit rather than on the apartment.
+type Visit struct {
+	ID           int64      `json:"id"`
+	ApartmentID  int64      `json:"apartment_id"`
+	VisitedAt    CustomTime `json:"visited_at"`
+	NoiseLevel   *int       `json:"noise_level"`   // Street noise from 1 (silent) to 5 (loud)
+	NaturalLight *int       `json:"natural_light"` // Natural light from 1 (dark) to 5 (bright)
+	SmellNotes   string     `json:"smell_notes"`
+	Facing       *string    `json:"facing"` // Compass direction the main windows face
+	Notes        string     `json:"notes"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// VisitRequest is used for creating/updating a visit
+type VisitRequest struct {
+	VisitedAt    CustomTime `json:"visited_at"`
+	NoiseLevel   *int       `json:"noise_level" binding:"omitempty,min=1,max=5"`
+	NaturalLight *int       `json:"natural_light" binding:"omitempty,min=1,max=5"`
+	SmellNotes   string     `json:"smell_notes"`
+	Facing       *string    `json:"facing" binding:"omitempty,oneof=N NE E SE S SW W NW"`
+	Notes        string     `json:"notes"`
+}
@@ -0,0 +1,266 @@
+// Package nlquery turns questions like "cheapest 2-bed with laundry under
+// 25 min commute" into the filter language of the apartment list, either
+// with a few dozen phrase rules or by asking a language model. Either way
+// the result is plain filter syntax, checked against the filterable fields
+// before it's used.
+package nlquery
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Query is a question's interpretation, in the terms of the list
+// endpoint's parameters
+type Query struct {
+	Filter    string   `json:"q"`         // Filter expression; empty matches everything
+	Sort      string   `json:"sort"`      // Sort fields, "-" prefixed for descending
+	Limit     int      `json:"limit"`     // 0 for no limit
+	Amenities []string `json:"amenities"` // Amenity keys apartments must all have
+	// Unrecognized lists the words of the question no rule understood
+	Unrecognized []string `json:"unrecognized"`
+}
+
+// interpretation collects the pieces of a Query as rules match
+type interpretation struct {
+	clauses   []string
+	sort      string
+	limit     int
+	amenities []string
+}
+
+// rule matches a phrase and adds its meaning to the interpretation
+type rule struct {
+	pattern *regexp.Regexp
+	apply   func(in *interpretation, m []string)
+}
+
+// Building blocks of the rule patterns
+const (
+	// amount matches "2,500" or "2.5k", optionally "a month"
+	amount = `(\d[\d,]*(?:\.\d+)?)\s?(k)?(?:\s*(?:/|per|a)\s*mo(?:nth)?)?`
+	money  = `\$?\s?` + amount
+	// count matches a small number as digits or a word
+	count   = `(\d+|one|two|three|four|five|six)`
+	bedroom = `\s*-?\s*(?:bed(?:room)?s?|br|bd)\b`
+	minutes = `\s*-?\s*min(?:ute)?s?`
+	atMost  = `(?:under|below|less than|within|at most|max(?:imum)?|up to|no more than|<=?)`
+	atLeast = `(?:over|above|more than|at least|min(?:imum)?|>=?)`
+	negated = `(no|without|not|non)[\s-]+`
+)
+
+var words = map[string]int{"one": 1, "two": 2, "three": 3, "four": 4, "five": 5, "six": 6}
+
+// number reads a count
+func number(s string) int {
+	if n, ok := words[s]; ok {
+		return n
+	}
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+// dollars reads a money match, expanding thousands written as "k"
+func dollars(digits, k string) string {
+	v, _ := strconv.ParseFloat(strings.ReplaceAll(digits, ",", ""), 64)
+	if k != "" {
+		v *= 1000
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// flag adds a boolean field, negated when the phrase says so
+func flag(field string) func(*interpretation, []string) {
+	return func(in *interpretation, m []string) {
+		if m[1] != "" {
+			in.clauses = append(in.clauses, "NOT "+field)
+		} else {
+			in.clauses = append(in.clauses, field)
+		}
+	}
+}
+
+// amenity requires an amenity key, or its absence when negated
+func amenity(key string) func(*interpretation, []string) {
+	return func(in *interpretation, m []string) {
+		if m[1] != "" {
+			in.clauses = append(in.clauses, `NOT amenities~"`+key+`"`)
+		} else {
+			in.amenities = append(in.amenities, key)
+		}
+	}
+}
+
+// ordering matches a superlative, optionally preceded by how many results
+// to return ("3 cheapest"), and sorts by field
+func ordering(pattern, field string) rule {
+	return rule{regexp.MustCompile(`(?:\b(\d+)\s+)?(?:` + pattern + `)`), func(in *interpretation, m []string) {
+		in.sort = field
+		if m[1] != "" {
+			in.limit = number(m[1])
+		}
+	}}
+}
+
+// clause adds a fixed filter expression
+func clause(expr string) func(*interpretation, []string) {
+	return func(in *interpretation, _ []string) {
+		in.clauses = append(in.clauses, expr)
+	}
+}
+
+// rules are tried in order, each removing the phrases it matched so later
+// rules don't see them; more specific phrases come first
+var rules = []rule{
+	// Result counts: "top 3"
+	{regexp.MustCompile(`\b(?:top|first)\s+(\d+)\b`), func(in *interpretation, m []string) { in.limit = number(m[1]) }},
+
+	// Bedrooms
+	{regexp.MustCompile(`\bstudios?\b`), clause("bedrooms=0")},
+	{regexp.MustCompile(`\b` + atLeast + `\s+` + count + bedroom), func(in *interpretation, m []string) {
+		in.clauses = append(in.clauses, "bedrooms>="+strconv.Itoa(number(m[1])))
+	}},
+	{regexp.MustCompile(`\b` + count + `\s*(?:\+|or more)` + bedroom), func(in *interpretation, m []string) {
+		in.clauses = append(in.clauses, "bedrooms>="+strconv.Itoa(number(m[1])))
+	}},
+	{regexp.MustCompile(`\b` + count + bedroom), func(in *interpretation, m []string) {
+		in.clauses = append(in.clauses, "bedrooms="+strconv.Itoa(number(m[1])))
+	}},
+
+	// Travel times
+	{regexp.MustCompile(`\b(?:commute|drive|ride)\s+(?:of\s+|time\s+)?` + atMost + `\s*(\d+)` + minutes), func(in *interpretation, m []string) {
+		in.clauses = append(in.clauses, "commute_minutes<="+m[1])
+	}},
+	{regexp.MustCompile(`\b(?:` + atMost + `\s*)?(\d+)` + minutes + `\s+(?:or less\s+)?(?:commute|drive|ride)\b`), func(in *interpretation, m []string) {
+		in.clauses = append(in.clauses, "commute_minutes<="+m[1])
+	}},
+	{regexp.MustCompile(`\b(?:` + atMost + `\s*)?(\d+)` + minutes + `\s+walk\s+(?:to|from)\s+(?:transit|the train|the subway|a train|a bus|a stop|the bus)\b`), func(in *interpretation, m []string) {
+		in.clauses = append(in.clauses, "transit_walk_minutes<="+m[1])
+	}},
+	{regexp.MustCompile(`\b(?:near|close to)\s+(?:transit|the train|the subway|a train|a bus stop|the bus)\b`), clause("transit_walk_minutes<=10")},
+
+	// Ratings and scores
+	{regexp.MustCompile(`\b(?:rated|rating(?:\s+of)?)\s+(?:` + atLeast + `\s+)?([1-5])(?:\s*\+|\s*stars?|\s+or (?:more|higher|better|above))*`), func(in *interpretation, m []string) {
+		in.clauses = append(in.clauses, "rating>="+m[1])
+	}},
+	{regexp.MustCompile(`\b(?:` + atLeast + `\s+)?([1-5])\s*\+?\s*stars?\b`), func(in *interpretation, m []string) {
+		in.clauses = append(in.clauses, "rating>="+m[1])
+	}},
+	{regexp.MustCompile(`\b(walk|bike|transit)\s+score\s+(?:of\s+)?` + atLeast + `?\s*(\d+)`), func(in *interpretation, m []string) {
+		in.clauses = append(in.clauses, m[1]+"_score>="+m[2])
+	}},
+
+	// Price
+	{regexp.MustCompile(`\b(?:between|from)\s+` + money + `\s+(?:and|to|-)\s+` + money), func(in *interpretation, m []string) {
+		in.clauses = append(in.clauses, "price>="+dollars(m[1], m[2]), "price<="+dollars(m[3], m[4]))
+	}},
+	{regexp.MustCompile(`(?:\b|^)(?:` + atMost + `|cheaper than|for less than)\s*` + money), func(in *interpretation, m []string) {
+		in.clauses = append(in.clauses, "price<="+dollars(m[1], m[2]))
+	}},
+	{regexp.MustCompile(`(?:\b|^)` + atLeast + `\s*\$\s?` + amount), func(in *interpretation, m []string) {
+		in.clauses = append(in.clauses, "price>="+dollars(m[1], m[2]))
+	}},
+
+	// Orderings
+	ordering(`\b(?:cheapest|least expensive|lowest (?:price|rent)|most affordable)\b`, "price"),
+	ordering(`\b(?:most expensive|priciest|highest (?:price|rent))\b`, "-price"),
+	ordering(`\b(?:best|highest|top)[\s-]rated\b|\bbest\b`, "-rating"),
+	ordering(`\b(?:newest|latest|most recent(?:ly added)?)\b`, "-created_at"),
+	ordering(`\b(?:shortest|quickest) commutes?\b|\bclosest to work\b`, "commute_minutes"),
+	ordering(`\bmost walkable\b`, "-walk_score"),
+	ordering(`\bsafest\b`, "crime_incidents"),
+
+	// Features
+	{regexp.MustCompile(`\b(?:` + negated + `)?(?:in[\s-]unit\s+)?(?:laundry|washer(?:\s*(?:/|and)\s*dryer)?|w/d)\b`), flag("has_laundry")},
+	{regexp.MustCompile(`\b(?:` + negated + `)?(?:a\s+)?garage\b`), flag("has_garage")},
+	{regexp.MustCompile(`\b(?:` + negated + `)?gated\b`), flag("is_gated")},
+	{regexp.MustCompile(`\b(?:` + negated + `)?(?:a\s+)?dishwasher\b`), amenity("dishwasher")},
+	{regexp.MustCompile(`\b(?:` + negated + `)?(?:air[\s-]conditioning|a/c|\bac\b)`), amenity("ac")},
+	{regexp.MustCompile(`\b(?:` + negated + `)?(?:a\s+)?(?:balcony|patio|deck)\b`), amenity("balcony")},
+	{regexp.MustCompile(`\b(?:` + negated + `)?ev[\s-]charg(?:ing|ers?)\b`), amenity("ev_charging")},
+	{regexp.MustCompile(`\b(?:` + negated + `)?bike storage\b`), amenity("bike_storage")},
+	{regexp.MustCompile(`\b(?:` + negated + `)?(?:an\s+)?elevator\b`), amenity("elevator")},
+	{regexp.MustCompile(`\b(?:` + negated + `)?(?:a\s+)?(?:pool|swimming pool)\b`), amenity("pool")},
+	{regexp.MustCompile(`\b(?:` + negated + `)?(?:a\s+)?(?:gym|fitness center)\b`), amenity("gym")},
+	{regexp.MustCompile(`\b(?:` + negated + `)?(?:pets?(?:[\s-](?:friendly|allowed|ok))?|dogs?|cats?)\b`), amenity("pets")},
+	{regexp.MustCompile(`\b(?:safe(?:\s+(?:area|neighborhood))?|low[\s-]crime)\b`), clause(`safety="high"`)},
+	{regexp.MustCompile(`\bground[\s-]floor\b|\bfirst floor\b`), clause("floor<=1")},
+	{regexp.MustCompile(`\b(?:upper|upstairs|not (?:on )?(?:the )?ground)\s+floor\b`), clause("floor>1")},
+
+	// Pipeline status
+	{regexp.MustCompile(`\b(?:(not|haven't|havent|un)\s*)?(visited|applied|rejected|signed|scheduled|considering)\b`), func(in *interpretation, m []string) {
+		if m[1] != "" {
+			in.clauses = append(in.clauses, `status!="`+m[2]+`"`)
+		} else {
+			in.clauses = append(in.clauses, `status="`+m[2]+`"`)
+		}
+	}},
+	{regexp.MustCompile(`\bdrafts?\b`), clause(`status="draft"`)},
+}
+
+// neighborhoodPattern matches "in <place>" among the words left over, up
+// to a word that starts another phrase
+var neighborhoodPattern = regexp.MustCompile(`\b(?:in|around)\s+(?:the\s+)?([a-z][a-z'.-]*(?:\s+[a-z][a-z'.-]*){0,3}?)\s*(?:\b(?:with|and|for|that|near|under|below|area|neighborhood)\b|[,;?!]|$)`)
+
+// stopWords are ignored when reporting unrecognized words
+var stopWords = map[string]bool{}
+
+func init() {
+	for _, w := range strings.Fields(`a an the and or with in on of for to at by is are that which have has
+		me my i we us our show find list get give want need looking some any all only also please
+		apartment apartments apt apts place places unit units one ones rent rental rentals home homes
+		min mins minute minutes commute month per mo what whats what's there`) {
+		stopWords[w] = true
+	}
+}
+
+// Parse interprets a question with the phrase rules
+func Parse(question string) *Query {
+	text := " " + strings.ToLower(strings.TrimSpace(question)) + " "
+	var in interpretation
+
+	for _, r := range rules {
+		text = r.pattern.ReplaceAllStringFunc(text, func(match string) string {
+			r.apply(&in, r.pattern.FindStringSubmatch(match))
+			return " , "
+		})
+	}
+	if m := neighborhoodPattern.FindStringSubmatchIndex(text); m != nil {
+		place := strings.Trim(text[m[2]:m[3]], " .-'")
+		if place != "" && !stopWords[place] {
+			in.clauses = append(in.clauses, `neighborhood~"`+strings.ReplaceAll(place, `"`, "")+`"`)
+			text = text[:m[0]] + " , " + text[m[3]:]
+		}
+	}
+
+	q := &Query{
+		Filter:       strings.Join(in.clauses, " AND "),
+		Sort:         in.sort,
+		Limit:        in.limit,
+		Amenities:    dedupe(in.amenities),
+		Unrecognized: []string{},
+	}
+	for _, w := range strings.FieldsFunc(text, func(r rune) bool {
+		return strings.ContainsRune(" ,;?!.\t\n", r)
+	}) {
+		if !stopWords[w] {
+			q.Unrecognized = append(q.Unrecognized, w)
+		}
+	}
+	return q
+}
+
+// dedupe removes repeated strings, keeping the first of each and never
+// returning nil
+func dedupe(items []string) []string {
+	out := []string{}
+	seen := map[string]bool{}
+	for _, s := range items {
+		if !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	return out
+}
@@ -0,0 +1,96 @@
+package nlquery
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mojotx/apt-eval/filter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		question string
+		want     Query
+	}{
+		{
+			"cheapest 2-bed with laundry under 25 min commute",
+			Query{Filter: "bedrooms=2 AND commute_minutes<=25 AND has_laundry", Sort: "price"},
+		},
+		{
+			"3 best rated places under $2.5k a month with a dishwasher and no garage",
+			Query{Filter: "price<=2500 AND NOT has_garage", Sort: "-rating", Limit: 3, Amenities: []string{"dishwasher"}},
+		},
+		{
+			"studios between $1,200 and $1,800 in capitol hill",
+			Query{Filter: `bedrooms=0 AND price>=1200 AND price<=1800 AND neighborhood~"capitol hill"`},
+		},
+		{
+			"at least 3 bedrooms, pet friendly, 4+ stars, not visited",
+			Query{Filter: `bedrooms>=3 AND rating>=4 AND status!="visited"`, Amenities: []string{"pets"}},
+		},
+		{
+			"safe area with a pool and a view",
+			Query{Filter: `safety="high"`, Amenities: []string{"pool"}, Unrecognized: []string{"view"}},
+		},
+	}
+
+	fields := filter.Fields{
+		"bedrooms": {}, "commute_minutes": {}, "has_laundry": {Type: filter.Bool}, "has_garage": {Type: filter.Bool},
+		"price": {}, "neighborhood": {Type: filter.Text}, "rating": {}, "status": {Type: filter.Text},
+		"safety": {Type: filter.Text}, "amenities": {Type: filter.Text},
+	}
+	for _, tt := range tests {
+		got := Parse(tt.question)
+		if tt.want.Amenities == nil {
+			tt.want.Amenities = []string{}
+		}
+		if tt.want.Unrecognized == nil {
+			tt.want.Unrecognized = []string{}
+		}
+		assert.Equal(t, &tt.want, got, tt.question)
+
+		_, err := filter.Parse(got.Filter, fields)
+		assert.NoError(t, err, tt.question)
+	}
+}
+
+// fakeModel replies with a fixed answer
+type fakeModel struct {
+	reply string
+	err   error
+}
+
+func (m fakeModel) Name() string  { return "fake" }
+func (m fakeModel) Model() string { return "fake" }
+func (m fakeModel) Complete(context.Context, string, string) (string, error) {
+	return m.reply, m.err
+}
+
+func TestTranslate(t *testing.T) {
+	fields := filter.Fields{"price": {}, "bedrooms": {}}
+	amenities := []string{"laundry", "pool"}
+
+	q, err := Translate(context.Background(), fakeModel{reply: "```json\n" +
+		`{"q": "bedrooms=2 AND price<=2000", "sort": "price", "limit": 5, "amenities": ["pool"]}` + "\n```"},
+		"cheap 2 bed with a pool", fields, amenities)
+	require.NoError(t, err)
+	assert.Equal(t, &Query{Filter: "bedrooms=2 AND price<=2000", Sort: "price", Limit: 5,
+		Amenities: []string{"pool"}, Unrecognized: []string{}}, q)
+
+	for _, reply := range []string{
+		`{"q": "sqft>900"}`,
+		`{"q": "price<2000; DROP TABLE apartments"}`,
+		`{"sort": "-views"}`,
+		`{"amenities": ["rooftop"]}`,
+		`Sorry, I can't do that.`,
+	} {
+		_, err := Translate(context.Background(), fakeModel{reply: reply}, "q", fields, amenities)
+		assert.Error(t, err, reply)
+	}
+
+	_, err = Translate(context.Background(), fakeModel{err: errors.New("down")}, "q", fields, amenities)
+	assert.Error(t, err)
+}
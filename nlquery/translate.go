@@ -0,0 +1,94 @@
+package nlquery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mojotx/apt-eval/filter"
+	"github.com/mojotx/apt-eval/health"
+	"github.com/mojotx/apt-eval/llm"
+)
+
+// typeNames describe field types to the model
+var typeNames = map[filter.Type]string{
+	filter.Number: "number",
+	filter.Text:   "text",
+	filter.Bool:   "boolean",
+	filter.Date:   "date",
+}
+
+// translatePrompt explains the filter language; the field list and
+// amenity keys are appended
+const translatePrompt = `You translate questions about rental apartments into a filter language. ` +
+	`Expressions compare fields with = != < <= > >= ~ (contains) and !~ (doesn't contain), ` +
+	`quote text values with double quotes, name boolean fields alone to require them, ` +
+	`and combine terms with AND, OR, NOT, and parentheses. Example: ` +
+	`price<=2000 AND bedrooms>=2 AND NOT is_gated AND neighborhood~"capitol hill". ` +
+	`Prices are monthly rent in dollars. commute_minutes is the longest commute to any saved destination. ` +
+	`safety is "high", "medium", or "low". ` +
+	`Reply with JSON only: {"q": "filter expression, or empty", "sort": "comma-separated fields, ` +
+	`each prefixed with - for descending, or empty", "limit": 0, "amenities": ["amenity keys the apartment must have"]}. ` +
+	`Use only the fields and amenity keys listed.`
+
+// Translate asks a language model to interpret a question, then checks
+// that what it wrote only uses known fields and valid syntax
+func Translate(ctx context.Context, p llm.Provider, question string, fields filter.Fields, amenityKeys []string) (*Query, error) {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString(translatePrompt)
+	b.WriteString("\n\nFields:\n")
+	for _, name := range names {
+		fmt.Fprintf(&b, "- %s (%s)\n", name, typeNames[fields[name].Type])
+	}
+	fmt.Fprintf(&b, "\nAmenity keys: %s\n", strings.Join(amenityKeys, ", "))
+
+	reply, err := p.Complete(ctx, b.String(), question)
+	health.Record("llm", err)
+	if err != nil {
+		return nil, err
+	}
+
+	start, end := strings.Index(reply, "{"), strings.LastIndex(reply, "}")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("model reply has no JSON object: %.100q", reply)
+	}
+	q := &Query{}
+	if err := json.Unmarshal([]byte(reply[start:end+1]), q); err != nil {
+		return nil, fmt.Errorf("failed to decode model reply: %w", err)
+	}
+	q.Filter, q.Sort = strings.TrimSpace(q.Filter), strings.TrimSpace(q.Sort)
+	q.Unrecognized = []string{}
+	if q.Limit < 0 {
+		q.Limit = 0
+	}
+
+	if _, err := filter.Parse(q.Filter, fields); err != nil {
+		return nil, fmt.Errorf("model wrote an invalid filter %q: %w", q.Filter, err)
+	}
+	if q.Sort != "" {
+		for _, name := range strings.Split(q.Sort, ",") {
+			if _, ok := fields[strings.TrimPrefix(strings.TrimSpace(name), "-")]; !ok {
+				return nil, fmt.Errorf("model sorted by unknown field %q", name)
+			}
+		}
+	}
+	known := map[string]bool{}
+	for _, key := range amenityKeys {
+		known[key] = true
+	}
+	q.Amenities = dedupe(q.Amenities)
+	for _, key := range q.Amenities {
+		if !known[key] {
+			return nil, fmt.Errorf("model required unknown amenity %q", key)
+		}
+	}
+	return q, nil
+}
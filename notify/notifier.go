@@ -0,0 +1,227 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	_ "time/tzdata" // Users' time zones must load on hosts without zoneinfo
+
+	"github.com/mojotx/apt-eval/address"
+	"github.com/mojotx/apt-eval/db"
+	"github.com/mojotx/apt-eval/health"
+	"github.com/mojotx/apt-eval/models"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// maxAttempts is how many times a notification is tried before it's
+	// marked failed
+	maxAttempts = 5
+
+	// batchSize caps how many notifications one dispatch run sends
+	batchSize = 100
+)
+
+// Notifier queues notifications and sends them through a Sender
+type Notifier struct {
+	db     *db.DB
+	sender Sender
+
+	// ReminderLead is how long before a visit its reminder is sent
+	ReminderLead time.Duration
+
+	// DeadlineLead is how long before an application deadline or hold
+	// expiry its reminder is sent
+	DeadlineLead time.Duration
+}
+
+// NewNotifier creates a new notifier
+func NewNotifier(database *db.DB, sender Sender, reminderLead time.Duration) *Notifier {
+	return &Notifier{
+		db:           database,
+		sender:       sender,
+		ReminderLead: reminderLead,
+	}
+}
+
+// Dispatch sends pending notifications, holding those whose recipient is in
+// quiet hours and dropping those that have expired
+func (n *Notifier) Dispatch(ctx context.Context) error {
+	pending, err := n.db.PendingNotifications(batchSize)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	var sent, failed int
+	for _, p := range pending {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if p.ExpiresAt != nil && now.After(*p.ExpiresAt) {
+			if err := n.db.ExpireNotification(p.ID); err != nil {
+				return err
+			}
+			continue
+		}
+		if p.User.Phone == "" || InQuietHours(now, p.User.QuietStart, p.User.QuietEnd, location(p.User.Timezone)) {
+			continue
+		}
+
+		sendErr := n.sender.Send(ctx, p.User.Phone, p.Body)
+		health.Record("sms", sendErr)
+		if sendErr != nil {
+			failed++
+			giveUp := p.Attempts+1 >= maxAttempts
+			log.Warn().Err(sendErr).Int64("id", p.ID).Int64("user_id", p.UserID).Bool("gave_up", giveUp).
+				Str("provider", n.sender.Name()).Msg("Failed to send notification")
+			if err := n.db.MarkNotificationFailed(p.ID, sendErr, giveUp); err != nil {
+				return err
+			}
+			continue
+		}
+		sent++
+		if err := n.db.MarkNotificationSent(p.ID); err != nil {
+			return err
+		}
+	}
+
+	if sent > 0 || failed > 0 {
+		log.Info().Int("sent", sent).Int("failed", failed).Msg("Dispatched notifications")
+	}
+	return nil
+}
+
+// QueueVisitReminders queues a reminder for each visit starting within the
+// reminder lead time, for every user who wants them
+func (n *Notifier) QueueVisitReminders(ctx context.Context) error {
+	reminders, err := n.db.DueVisitReminders(n.ReminderLead)
+	if err != nil || len(reminders) == 0 {
+		return err
+	}
+	users, err := n.db.ListUsers()
+	if err != nil {
+		return err
+	}
+
+	for _, r := range reminders {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		messages := map[int64]string{}
+		for _, u := range users {
+			if u.NotifyVisits && u.Phone != "" {
+				messages[u.ID] = reminderMessage(&r, location(u.Timezone))
+			}
+		}
+		if err := n.db.QueueVisitReminder(&r, messages); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reminderMessage describes a visit with its time in the recipient's zone
+func reminderMessage(r *models.VisitReminder, loc *time.Location) string {
+	return fmt.Sprintf("Reminder: visit to %s on %s", r.Address, r.VisitedAt.In(loc).Format("Mon Jan 2 at 3:04 PM MST"))
+}
+
+// QueueDeadlineReminders queues a reminder for each application deadline or
+// hold expiry within the deadline lead time, for every user who wants them
+func (n *Notifier) QueueDeadlineReminders(ctx context.Context) error {
+	reminders, err := n.db.DueDeadlineReminders(n.DeadlineLead)
+	if err != nil || len(reminders) == 0 {
+		return err
+	}
+	users, err := n.db.ListUsers()
+	if err != nil {
+		return err
+	}
+
+	for _, r := range reminders {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		messages := map[int64]string{}
+		for _, u := range users {
+			if u.NotifyDeadlines && u.Phone != "" {
+				messages[u.ID] = deadlineMessage(&r, location(u.Timezone))
+			}
+		}
+		if err := n.db.QueueDeadlineReminder(&r, messages); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deadlineMessage describes a deadline with its time in the recipient's zone
+func deadlineMessage(r *models.DeadlineReminder, loc *time.Location) string {
+	due := r.Due.In(loc).Format("Mon Jan 2 at 3:04 PM MST")
+	if r.Event == models.EventHoldExpiry {
+		return fmt.Sprintf("Reminder: the hold on %s expires %s", r.Address, due)
+	}
+	return fmt.Sprintf("Reminder: the application for %s is due %s", r.Address, due)
+}
+
+// maxAlertAddresses caps how many apartments an alert names
+const maxAlertAddresses = 3
+
+// QueueAlerts checks every alert rule against the apartments and queues a
+// message for each rule that went off
+func (n *Notifier) QueueAlerts(ctx context.Context) error {
+	rules, err := n.db.ListAlertRules(0)
+	if err != nil || len(rules) == 0 {
+		return err
+	}
+
+	for _, r := range rules {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		matches, newest, err := n.db.AlertMatches(&r)
+		if err != nil {
+			// One broken rule shouldn't hold up the others
+			log.Warn().Err(err).Int64("rule_id", r.ID).Msg("Failed to check alert rule")
+			continue
+		}
+		var body string
+		if len(matches) > 0 {
+			body = alertMessage(&r, matches)
+		}
+		if err := n.db.QueueAlert(&r, matches, newest, body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// alertMessage describes what set off an alert rule, naming the first few
+// apartments
+func alertMessage(r *models.AlertRule, matches []models.Apartment) string {
+	var names []string
+	for _, a := range matches[:min(len(matches), maxAlertAddresses)] {
+		name := address.JoinUnit(a.Address, a.Unit)
+		if r.Kind == models.AlertPriceBelow {
+			name += fmt.Sprintf(" at $%.0f", *a.Price)
+		}
+		names = append(names, name)
+	}
+	list := strings.Join(names, "; ")
+	if more := len(matches) - len(names); more > 0 {
+		list += fmt.Sprintf(" and %d more", more)
+	}
+
+	label := r.Name
+	if label == "" && r.Kind == models.AlertPriceBelow {
+		label = fmt.Sprintf("under $%.0f", *r.Price)
+	}
+	if label != "" {
+		label = " (" + label + ")"
+	}
+	if r.Kind == models.AlertPriceBelow {
+		return "Price alert" + label + ": " + list
+	}
+	return "New apartment match" + label + ": " + list
+}
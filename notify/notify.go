@@ -0,0 +1,120 @@
+// Package notify delivers text message notifications (visit reminders and
+// apartment status changes) to users. Messages are queued in the database
+// and sent by a scheduled task through a pluggable SMS provider, so quiet
+// hours and provider outages only delay them.
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Sender delivers a text message to a phone number
+type Sender interface {
+	Name() string
+	Send(ctx context.Context, to, body string) error
+}
+
+// TwilioConfig holds the credentials for the Twilio provider
+type TwilioConfig struct {
+	AccountSID string
+	AuthToken  string
+	From       string // Sending number in E.164 form, or a messaging service SID
+}
+
+// NewSender returns the provider selected by name: "twilio" (requires
+// credentials), "log" to write messages to the log instead of sending them,
+// or "" / "none" for no provider
+func NewSender(name string, twilio TwilioConfig) (Sender, error) {
+	switch strings.ToLower(name) {
+	case "", "none":
+		return nil, nil
+	case "log":
+		return LogSender{}, nil
+	case "twilio":
+		if twilio.AccountSID == "" || twilio.AuthToken == "" || twilio.From == "" {
+			return nil, fmt.Errorf("the twilio provider requires an account SID, auth token, and from number")
+		}
+		return &TwilioSender{Config: twilio, BaseURL: "https://api.twilio.com"}, nil
+	}
+	return nil, fmt.Errorf("unknown SMS provider %q", name)
+}
+
+// LogSender logs messages instead of sending them, for development
+type LogSender struct{}
+
+// Name implements Sender
+func (LogSender) Name() string {
+	return "log"
+}
+
+// Send implements Sender
+func (LogSender) Send(_ context.Context, to, body string) error {
+	log.Info().Str("to", to).Str("body", body).Msg("SMS notification")
+	return nil
+}
+
+// httpClient is used for calls to SMS providers
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// TwilioSender sends messages through Twilio's Programmable Messaging API
+type TwilioSender struct {
+	Config  TwilioConfig
+	BaseURL string
+}
+
+// Name implements Sender
+func (s *TwilioSender) Name() string {
+	return "twilio"
+}
+
+// twilioError is the error body returned by the Twilio API
+type twilioError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Send implements Sender
+func (s *TwilioSender) Send(ctx context.Context, to, body string) error {
+	form := url.Values{}
+	form.Set("To", to)
+	form.Set("Body", body)
+	if strings.HasPrefix(s.Config.From, "MG") {
+		form.Set("MessagingServiceSid", s.Config.From)
+	} else {
+		form.Set("From", s.Config.From)
+	}
+
+	endpoint := fmt.Sprintf("%s/2010-04-01/Accounts/%s/Messages.json", s.BaseURL, url.PathEscape(s.Config.AccountSID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(s.Config.AccountSID, s.Config.AuthToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		var e twilioError
+		if json.Unmarshal(data, &e) == nil && e.Message != "" {
+			return fmt.Errorf("twilio returned %s: %s (code %d)", resp.Status, e.Message, e.Code)
+		}
+		return fmt.Errorf("twilio returned %s: %s", resp.Status, data)
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package notify
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mojotx/apt-eval/db"
+	"github.com/mojotx/apt-eval/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInQuietHours(t *testing.T) {
+	at := func(clock string) time.Time {
+		tm, err := time.Parse("2006-01-02 15:04", "2026-03-10 "+clock)
+		require.NoError(t, err)
+		return tm
+	}
+
+	tests := []struct {
+		name, start, end, clock string
+		want                    bool
+	}{
+		{"no quiet hours", "", "", "03:00", false},
+		{"equal bounds", "08:00", "08:00", "08:00", false},
+		{"same day inside", "13:00", "15:00", "14:30", true},
+		{"same day start", "13:00", "15:00", "13:00", true},
+		{"same day end", "13:00", "15:00", "15:00", false},
+		{"same day outside", "13:00", "15:00", "09:00", false},
+		{"overnight late", "22:00", "07:00", "23:15", true},
+		{"overnight early", "22:00", "07:00", "06:59", true},
+		{"overnight end", "22:00", "07:00", "07:00", false},
+		{"overnight daytime", "22:00", "07:00", "12:00", false},
+		{"bad bound", "25:00", "07:00", "03:00", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, InQuietHours(at(tt.clock), tt.start, tt.end, time.UTC))
+		})
+	}
+}
+
+func TestInQuietHoursTimezone(t *testing.T) {
+	denver, err := time.LoadLocation("America/Denver")
+	require.NoError(t, err)
+
+	// 05:00 UTC is 23:00 the previous evening in Denver (MDT), and 14:00
+	// UTC is 08:00
+	assert.True(t, InQuietHours(time.Date(2026, 7, 1, 5, 0, 0, 0, time.UTC), "22:00", "07:00", denver))
+	assert.False(t, InQuietHours(time.Date(2026, 7, 1, 14, 0, 0, 0, time.UTC), "22:00", "07:00", denver))
+	assert.True(t, InQuietHours(time.Date(2026, 7, 1, 14, 0, 0, 0, time.UTC), "08:00", "17:00", time.UTC))
+}
+
+func TestNewSender(t *testing.T) {
+	s, err := NewSender("", TwilioConfig{})
+	require.NoError(t, err)
+	assert.Nil(t, s)
+
+	_, err = NewSender("twilio", TwilioConfig{AccountSID: "AC123"})
+	assert.Error(t, err)
+
+	_, err = NewSender("pigeon", TwilioConfig{})
+	assert.Error(t, err)
+
+	s, err = NewSender("Twilio", TwilioConfig{AccountSID: "AC123", AuthToken: "secret", From: "+15550001111"})
+	require.NoError(t, err)
+	assert.Equal(t, "twilio", s.Name())
+}
+
+func TestTwilioSend(t *testing.T) {
+	var got *http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		got = r
+		if r.PostForm.Get("To") == "+15550000000" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"code": 21211, "message": "Invalid 'To' Phone Number"}`))
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"sid": "SM123"}`))
+	}))
+	defer server.Close()
+
+	s := &TwilioSender{
+		Config:  TwilioConfig{AccountSID: "AC123", AuthToken: "secret", From: "+15550001111"},
+		BaseURL: server.URL,
+	}
+	require.NoError(t, s.Send(context.Background(), "+15552223333", "Hello"))
+	assert.Equal(t, "/2010-04-01/Accounts/AC123/Messages.json", got.URL.Path)
+	user, pass, ok := got.BasicAuth()
+	assert.True(t, ok)
+	assert.Equal(t, "AC123", user)
+	assert.Equal(t, "secret", pass)
+	assert.Equal(t, "+15550001111", got.PostForm.Get("From"))
+	assert.Equal(t, "+15552223333", got.PostForm.Get("To"))
+	assert.Equal(t, "Hello", got.PostForm.Get("Body"))
+
+	err := s.Send(context.Background(), "+15550000000", "Hello")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Invalid 'To' Phone Number")
+}
+
+func TestReminderMessage(t *testing.T) {
+	denver, err := time.LoadLocation("America/Denver")
+	require.NoError(t, err)
+
+	r := &models.VisitReminder{Address: "12 Elm St", VisitedAt: time.Date(2026, 7, 1, 23, 30, 0, 0, time.UTC)}
+	assert.Equal(t, "Reminder: visit to 12 Elm St on Wed Jul 1 at 5:30 PM MDT", reminderMessage(r, denver))
+}
+
+func TestDeadlineMessage(t *testing.T) {
+	denver, err := time.LoadLocation("America/Denver")
+	require.NoError(t, err)
+
+	due := time.Date(2026, 7, 1, 23, 30, 0, 0, time.UTC)
+	r := &models.DeadlineReminder{Address: "12 Elm St", Event: models.EventApplicationDeadline, Due: due}
+	assert.Equal(t, "Reminder: the application for 12 Elm St is due Wed Jul 1 at 5:30 PM MDT", deadlineMessage(r, denver))
+
+	r.Event = models.EventHoldExpiry
+	assert.Equal(t, "Reminder: the hold on 12 Elm St expires Wed Jul 1 at 5:30 PM MDT", deadlineMessage(r, denver))
+}
+
+func TestAlertMessage(t *testing.T) {
+	price := func(v float64) *float64 { return &v }
+	matches := []models.Apartment{
+		{Address: "1 Elm St", Price: price(1500)},
+		{Address: "2 Elm St", Unit: "4B", Price: price(1600)},
+		{Address: "3 Elm St", Price: price(1700)},
+		{Address: "4 Elm St", Price: price(1800)},
+	}
+
+	r := &models.AlertRule{Kind: models.AlertPriceBelow, Price: price(2000)}
+	assert.Equal(t, "Price alert (under $2000): 1 Elm St at $1500; 2 Elm St #4B at $1600; 3 Elm St at $1700 and 1 more", alertMessage(r, matches))
+
+	r = &models.AlertRule{Kind: models.AlertNewMatch, Name: "Near work"}
+	assert.Equal(t, "New apartment match (Near work): 1 Elm St", alertMessage(r, matches[:1]))
+}
+
+func TestQueueAlerts(t *testing.T) {
+	database, err := db.New(t.TempDir())
+	require.NoError(t, err)
+	defer database.Close()
+	n := NewNotifier(database, LogSender{}, time.Hour)
+
+	user, err := database.CreateUser(&models.UserRequest{Name: "Sam", Phone: "+15551234567"})
+	require.NoError(t, err)
+	price := func(v float64) *float64 { return &v }
+	cheap, err := database.CreateApartment(&models.ApartmentRequest{Address: "1 Elm St", Price: price(1500)})
+	require.NoError(t, err)
+	pricey, err := database.CreateApartment(&models.ApartmentRequest{Address: "2 Elm St", Price: price(2500)})
+	require.NoError(t, err)
+
+	_, err = database.CreateAlertRule(user.ID, &models.AlertRuleRequest{Kind: models.AlertPriceBelow, Price: price(2000)})
+	require.NoError(t, err)
+	_, err = database.CreateAlertRule(user.ID, &models.AlertRuleRequest{Kind: models.AlertNewMatch, Name: "Laundry", Filter: "has_laundry"})
+	require.NoError(t, err)
+
+	bodies := func() []string {
+		notifications, err := database.ListNotifications(user.ID, -1)
+		require.NoError(t, err)
+		var bodies []string
+		for _, n := range notifications {
+			bodies = append(bodies, n.Body)
+		}
+		return bodies
+	}
+
+	require.NoError(t, n.QueueAlerts(context.Background()))
+	assert.Empty(t, bodies(), "apartments from before the rules are taken as seen")
+
+	_, err = database.UpdateApartment(pricey.ID, &models.ApartmentRequest{Address: "2 Elm St", Price: price(1900)})
+	require.NoError(t, err)
+	_, err = database.CreateApartment(&models.ApartmentRequest{Address: "3 Elm St", HasLaundry: true})
+	require.NoError(t, err)
+	_, err = database.CreateApartment(&models.ApartmentRequest{Address: "4 Elm St"})
+	require.NoError(t, err)
+	require.NoError(t, n.QueueAlerts(context.Background()))
+	assert.ElementsMatch(t, []string{
+		"Price alert (under $2000): 2 Elm St at $1900",
+		"New apartment match (Laundry): 3 Elm St",
+	}, bodies())
+
+	require.NoError(t, n.QueueAlerts(context.Background()))
+	assert.Len(t, bodies(), 2, "the same apartments don't set rules off again")
+
+	_, err = database.UpdateApartment(cheap.ID, &models.ApartmentRequest{Address: "1 Elm St", Price: price(1400)})
+	require.NoError(t, err)
+	require.NoError(t, n.QueueAlerts(context.Background()))
+	assert.Contains(t, bodies(), "Price alert (under $2000): 1 Elm St at $1400", "a further drop")
+}
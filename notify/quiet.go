@@ -0,0 +1,43 @@
+package notify
+
+import (
+	"time"
+)
+
+// clockLayout is the format of quiet hour boundaries
+const clockLayout = "15:04"
+
+// InQuietHours reports whether t falls within the quiet hours from start to
+// end, local "HH:MM" times in loc. The range includes start but not end and
+// wraps past midnight when end is earlier than start. Empty or equal
+// boundaries mean no quiet hours.
+func InQuietHours(t time.Time, start, end string, loc *time.Location) bool {
+	if start == "" || end == "" || start == end {
+		return false
+	}
+	s, err := time.Parse(clockLayout, start)
+	if err != nil {
+		return false
+	}
+	e, err := time.Parse(clockLayout, end)
+	if err != nil {
+		return false
+	}
+
+	local := t.In(loc)
+	now := local.Hour()*60 + local.Minute()
+	from, to := s.Hour()*60+s.Minute(), e.Hour()*60+e.Minute()
+	if from < to {
+		return now >= from && now < to
+	}
+	return now >= from || now < to
+}
+
+// location loads an IANA time zone, falling back to UTC for unknown names
+func location(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
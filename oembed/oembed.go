@@ -0,0 +1,213 @@
+// Package oembed looks up titles, thumbnails, and embeddable players for
+// video and 3D tour links using the oEmbed protocol (https://oembed.com/).
+// Only a fixed list of providers is supported, so requests never go to a
+// host taken from user input.
+package oembed
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// userAgent identifies this application to oEmbed providers
+const userAgent = "apt-eval/1.0 (+https://github.com/mojotx/apt-eval)"
+
+// maxResponse caps the size of an oEmbed response
+const maxResponse = 1 << 20
+
+// ErrUnsupported is returned for links to sites without a known provider
+var ErrUnsupported = errors.New("unsupported media link")
+
+// Provider is a site serving oEmbed metadata for its links
+type Provider struct {
+	Name     string
+	Hosts    []string // Hosts of the links it handles
+	Endpoint string   // oEmbed endpoint, queried with the link as url
+	// PlayerHosts are the hosts its embedded players may be loaded from
+	PlayerHosts []string
+}
+
+// Providers are the supported video and tour sites
+var Providers = []Provider{
+	{
+		Name:        "YouTube",
+		Hosts:       []string{"youtube.com", "www.youtube.com", "m.youtube.com", "youtu.be"},
+		Endpoint:    "https://www.youtube.com/oembed",
+		PlayerHosts: []string{"www.youtube.com", "www.youtube-nocookie.com"},
+	},
+	{
+		Name:        "Vimeo",
+		Hosts:       []string{"vimeo.com", "www.vimeo.com", "player.vimeo.com"},
+		Endpoint:    "https://vimeo.com/api/oembed.json",
+		PlayerHosts: []string{"player.vimeo.com"},
+	},
+	{
+		Name:        "Matterport",
+		Hosts:       []string{"matterport.com", "my.matterport.com"},
+		Endpoint:    "https://my.matterport.com/api/v1/models/oembed/",
+		PlayerHosts: []string{"my.matterport.com"},
+	},
+}
+
+// Embed is the metadata of a link
+type Embed struct {
+	Provider     string
+	Title        string
+	AuthorName   string
+	ThumbnailURL string // Empty if the provider gave none over HTTPS
+	// HTML is an iframe of the provider's player, rebuilt from the player
+	// URL rather than passed through; empty if the player isn't on one of
+	// the provider's hosts
+	HTML   string
+	Width  int
+	Height int
+}
+
+// Client fetches metadata from the providers it knows
+type Client struct {
+	HTTP      *http.Client
+	Providers []Provider
+}
+
+// New creates a client for the supported providers
+func New() *Client {
+	return &Client{
+		HTTP:      &http.Client{Timeout: 15 * time.Second},
+		Providers: Providers,
+	}
+}
+
+// Match returns the provider handling a link, or ErrUnsupported
+func (c *Client) Match(link string) (*Provider, error) {
+	u, err := url.Parse(link)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") {
+		return nil, ErrUnsupported
+	}
+	host := strings.ToLower(u.Hostname())
+	for i := range c.Providers {
+		for _, h := range c.Providers[i].Hosts {
+			if host == h {
+				return &c.Providers[i], nil
+			}
+		}
+	}
+	return nil, ErrUnsupported
+}
+
+// response is the part of an oEmbed response that's used
+type response struct {
+	Type         string `json:"type"`
+	Title        string `json:"title"`
+	AuthorName   string `json:"author_name"`
+	ThumbnailURL string `json:"thumbnail_url"`
+	HTML         string `json:"html"`
+	Width        any    `json:"width"` // Some providers send strings
+	Height       any    `json:"height"`
+}
+
+// Lookup fetches the metadata of a link from its provider
+func (c *Client) Lookup(ctx context.Context, link string) (*Embed, error) {
+	p, err := c.Match(link)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint, err := url.Parse(p.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s endpoint: %w", p.Name, err)
+	}
+	q := endpoint.Query()
+	q.Set("url", link)
+	q.Set("format", "json")
+	endpoint.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach %s: %w", p.Name, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %s", p.Name, resp.Status)
+	}
+
+	var r response
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponse)).Decode(&r); err != nil {
+		return nil, fmt.Errorf("failed to decode %s response: %w", p.Name, err)
+	}
+
+	e := &Embed{
+		Provider:   p.Name,
+		Title:      r.Title,
+		AuthorName: r.AuthorName,
+		Width:      dimension(r.Width),
+		Height:     dimension(r.Height),
+	}
+	if u, err := url.Parse(r.ThumbnailURL); err == nil && u.Scheme == "https" {
+		e.ThumbnailURL = r.ThumbnailURL
+	}
+	if src := playerURL(r.HTML, p.PlayerHosts); src != "" {
+		e.HTML = iframe(src, e.Width, e.Height, e.Title)
+	}
+	return e, nil
+}
+
+// dimension reads a width or height sent as a number or a string
+func dimension(v any) int {
+	switch v := v.(type) {
+	case float64:
+		return int(v)
+	case string:
+		var n int
+		fmt.Sscanf(v, "%d", &n)
+		return n
+	}
+	return 0
+}
+
+var iframeSrcPattern = regexp.MustCompile(`(?i)<iframe\b[^>]*?\ssrc\s*=\s*["']([^"']+)["']`)
+
+// playerURL extracts the player URL from a provider's embed HTML, if it's
+// an HTTPS URL on one of the allowed hosts
+func playerURL(embedHTML string, hosts []string) string {
+	m := iframeSrcPattern.FindStringSubmatch(embedHTML)
+	if m == nil {
+		return ""
+	}
+	src := html.UnescapeString(m[1])
+	u, err := url.Parse(src)
+	if err != nil || u.Scheme != "https" {
+		return ""
+	}
+	for _, h := range hosts {
+		if strings.EqualFold(u.Hostname(), h) {
+			return u.String()
+		}
+	}
+	return ""
+}
+
+// iframe builds the HTML embedding a player
+func iframe(src string, width, height int, title string) string {
+	if width <= 0 || height <= 0 {
+		width, height = 640, 360
+	}
+	return fmt.Sprintf(`<iframe src="%s" width="%d" height="%d" title="%s" frameborder="0" `+
+		`allow="autoplay; fullscreen; picture-in-picture; xr-spatial-tracking" allowfullscreen></iframe>`,
+		html.EscapeString(src), width, height, html.EscapeString(title))
+}
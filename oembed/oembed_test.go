@@ -0,0 +1,86 @@
+package oembed
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testClient points the YouTube provider at a fake endpoint answering with
+// body
+func testClient(t *testing.T, body string) *Client {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("url") == "" || r.URL.Query().Get("format") != "json" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if r.URL.Query().Get("url") == "https://youtu.be/private" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+
+	c := New()
+	c.Providers = []Provider{Providers[0]}
+	c.Providers[0].Endpoint = srv.URL
+	return c
+}
+
+func TestLookup(t *testing.T) {
+	c := testClient(t, `{"type":"video","title":"Tour of <Unit 4>","author_name":"Acme Rentals",
+		"thumbnail_url":"https://i.ytimg.com/vi/abc/hqdefault.jpg","width":"480","height":270,
+		"html":"<iframe width=\"480\" height=\"270\" src=\"https://www.youtube.com/embed/abc?feature=oembed\" frameborder=\"0\"></iframe>"}`)
+
+	e, err := c.Lookup(context.Background(), "https://www.youtube.com/watch?v=abc")
+	require.NoError(t, err)
+	assert.Equal(t, "YouTube", e.Provider)
+	assert.Equal(t, "Tour of <Unit 4>", e.Title)
+	assert.Equal(t, "Acme Rentals", e.AuthorName)
+	assert.Equal(t, "https://i.ytimg.com/vi/abc/hqdefault.jpg", e.ThumbnailURL)
+	assert.Equal(t, 480, e.Width)
+	assert.Equal(t, 270, e.Height)
+	assert.Contains(t, e.HTML, `src="https://www.youtube.com/embed/abc?feature=oembed"`)
+	assert.Contains(t, e.HTML, `title="Tour of &lt;Unit 4&gt;"`)
+
+	_, err = c.Lookup(context.Background(), "https://youtu.be/private")
+	assert.ErrorContains(t, err, "401")
+
+	_, err = c.Lookup(context.Background(), "https://example.com/video")
+	assert.ErrorIs(t, err, ErrUnsupported)
+}
+
+func TestLookupUntrustedHTML(t *testing.T) {
+	c := testClient(t, `{"title":"x","thumbnail_url":"http://i.ytimg.com/x.jpg",
+		"html":"<iframe src=\"https://evil.example/embed\"></iframe><script>alert(1)</script>"}`)
+
+	e, err := c.Lookup(context.Background(), "https://youtu.be/abc")
+	require.NoError(t, err)
+	assert.Empty(t, e.HTML)
+	assert.Empty(t, e.ThumbnailURL)
+}
+
+func TestMatch(t *testing.T) {
+	c := New()
+	for link, name := range map[string]string{
+		"https://www.youtube.com/watch?v=abc":      "YouTube",
+		"https://youtu.be/abc":                     "YouTube",
+		"https://vimeo.com/123456":                 "Vimeo",
+		"https://my.matterport.com/show/?m=abc123": "Matterport",
+	} {
+		p, err := c.Match(link)
+		if assert.NoError(t, err, link) {
+			assert.Equal(t, name, p.Name, link)
+		}
+	}
+
+	for _, link := range []string{"https://youtube.com.evil.example/watch", "ftp://vimeo.com/1", "not a url"} {
+		_, err := c.Match(link)
+		assert.ErrorIs(t, err, ErrUnsupported, link)
+	}
+}
@@ -0,0 +1,224 @@
+// Package pdf writes simple one-page PDF documents: text in the standard
+// Helvetica fonts, rectangles, lines, and images. Coordinates are in points
+// from the bottom-left corner of the page.
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"strings"
+)
+
+// US Letter page size in points
+const (
+	LetterWidth  = 612
+	LetterHeight = 792
+)
+
+// Font is one of the standard fonts every PDF reader provides
+type Font int
+
+// Supported fonts
+const (
+	Helvetica Font = iota
+	HelveticaBold
+)
+
+func (f Font) resource() string {
+	if f == HelveticaBold {
+		return "F2"
+	}
+	return "F1"
+}
+
+// Page is a single page being drawn
+type Page struct {
+	Width, Height float64
+
+	content bytes.Buffer
+	images  []pageImage
+}
+
+// pageImage is an image XObject, either a JPEG passed through as is or raw
+// RGB samples
+type pageImage struct {
+	width, height int
+	colorSpace    string
+	filter        string
+	data          []byte
+}
+
+// NewPage starts a page of the given size
+func NewPage(width, height float64) *Page {
+	return &Page{Width: width, Height: height}
+}
+
+// Text draws s with its baseline starting at x, y
+func (p *Page) Text(x, y float64, font Font, size float64, s string) {
+	fmt.Fprintf(&p.content, "BT /%s %s Tf %s %s Td (%s) Tj ET\n",
+		font.resource(), num(size), num(x), num(y), escape(winAnsi(s)))
+}
+
+// FillRect draws a rectangle filled with a gray level from 0 (black) to 1
+// (white)
+func (p *Page) FillRect(x, y, w, h, gray float64) {
+	fmt.Fprintf(&p.content, "%s g %s %s %s %s re f\n", num(gray), num(x), num(y), num(w), num(h))
+}
+
+// StrokeRect outlines a rectangle
+func (p *Page) StrokeRect(x, y, w, h, lineWidth float64) {
+	fmt.Fprintf(&p.content, "0 G %s w %s %s %s %s re S\n", num(lineWidth), num(x), num(y), num(w), num(h))
+}
+
+// Line draws a straight black line
+func (p *Page) Line(x1, y1, x2, y2, lineWidth float64) {
+	fmt.Fprintf(&p.content, "0 G %s w %s %s m %s %s l S\n", num(lineWidth), num(x1), num(y1), num(x2), num(y2))
+}
+
+// maxImageSide caps the pixel size of images embedded by Image; larger
+// ones are downsampled, which is still plenty for print
+const maxImageSide = 1200
+
+// Image draws img scaled into the w by h box at x, y
+func (p *Page) Image(img image.Image, x, y, w, h float64) {
+	b := img.Bounds()
+	step := max(1, (max(b.Dx(), b.Dy())+maxImageSide-1)/maxImageSide)
+	width, height := (b.Dx()+step-1)/step, (b.Dy()+step-1)/step
+
+	data := make([]byte, 0, width*height*3)
+	for py := b.Min.Y; py < b.Max.Y; py += step {
+		for px := b.Min.X; px < b.Max.X; px += step {
+			r, g, bl, _ := img.At(px, py).RGBA()
+			data = append(data, byte(r>>8), byte(g>>8), byte(bl>>8))
+		}
+	}
+	p.addImage(pageImage{width: width, height: height, colorSpace: "/DeviceRGB", filter: "/FlateDecode", data: deflate(data)}, x, y, w, h)
+}
+
+// JPEG draws JPEG data scaled into the w by h box at x, y without
+// recompressing it. Color spaces other than RGB and grayscale are decoded
+// and drawn like any other image.
+func (p *Page) JPEG(data []byte, x, y, w, h float64) error {
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to read JPEG: %w", err)
+	}
+
+	var colorSpace string
+	switch cfg.ColorModel {
+	case color.YCbCrModel:
+		colorSpace = "/DeviceRGB"
+	case color.GrayModel:
+		colorSpace = "/DeviceGray"
+	default:
+		img, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			return fmt.Errorf("failed to decode JPEG: %w", err)
+		}
+		p.Image(img, x, y, w, h)
+		return nil
+	}
+	p.addImage(pageImage{width: cfg.Width, height: cfg.Height, colorSpace: colorSpace, filter: "/DCTDecode", data: data}, x, y, w, h)
+	return nil
+}
+
+func (p *Page) addImage(img pageImage, x, y, w, h float64) {
+	p.images = append(p.images, img)
+	fmt.Fprintf(&p.content, "q %s 0 0 %s %s %s cm /Im%d Do Q\n", num(w), num(h), num(x), num(y), len(p.images))
+}
+
+// WriteTo writes the page as a complete PDF document
+func (p *Page) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+	var offsets []int
+	object := func(body string, stream []byte) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\n", len(offsets), body)
+		if stream != nil {
+			buf.WriteString("stream\n")
+			buf.Write(stream)
+			buf.WriteString("\nendstream\n")
+		}
+		buf.WriteString("endobj\n")
+	}
+
+	var xobjects strings.Builder
+	for i := range p.images {
+		fmt.Fprintf(&xobjects, "/Im%d %d 0 R ", i+1, 7+i)
+	}
+	content := deflate(p.content.Bytes())
+
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	object("<< /Type /Catalog /Pages 2 0 R >>", nil)
+	object("<< /Type /Pages /Kids [3 0 R] /Count 1 >>", nil)
+	object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Contents 4 0 R "+
+		"/Resources << /Font << /F1 5 0 R /F2 6 0 R >> /XObject << %s>> >> >>",
+		num(p.Width), num(p.Height), xobjects.String()), nil)
+	object(fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>", len(content)), content)
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>", nil)
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>", nil)
+	for _, img := range p.images {
+		object(fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace %s "+
+			"/BitsPerComponent 8 /Filter %s /Length %d >>",
+			img.width, img.height, img.colorSpace, img.filter, len(img.data)), img.data)
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	return buf.WriteTo(w)
+}
+
+// num formats a coordinate compactly
+func num(f float64) string {
+	s := fmt.Sprintf("%.2f", f)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+func deflate(data []byte) []byte {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	zw.Write(data)
+	zw.Close()
+	return buf.Bytes()
+}
+
+// escape quotes a string for use in a PDF literal string
+func escape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "(", `\(`, ")", `\)`, "\r", `\r`, "\n", `\n`).Replace(s)
+}
+
+// winAnsiExtras maps the characters WinAnsiEncoding places in 0x80-0x9F
+var winAnsiExtras = map[rune]byte{
+	'€': 0x80, '‚': 0x82, 'ƒ': 0x83, '„': 0x84, '…': 0x85, '†': 0x86, '‡': 0x87,
+	'ˆ': 0x88, '‰': 0x89, 'Š': 0x8A, '‹': 0x8B, 'Œ': 0x8C, 'Ž': 0x8E, '‘': 0x91,
+	'’': 0x92, '“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97, '˜': 0x98,
+	'™': 0x99, 'š': 0x9A, '›': 0x9B, 'œ': 0x9C, 'ž': 0x9E, 'Ÿ': 0x9F,
+}
+
+// winAnsi converts s to the encoding of the standard fonts, replacing
+// characters they can't show with '?'
+func winAnsi(s string) string {
+	out := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch {
+		case r < 0x80 || (r >= 0xA0 && r <= 0xFF):
+			out = append(out, byte(r))
+		case winAnsiExtras[r] != 0:
+			out = append(out, winAnsiExtras[r])
+		default:
+			out = append(out, '?')
+		}
+	}
+	return string(out)
+}
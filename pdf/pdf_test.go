@@ -0,0 +1,54 @@
+package pdf
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteTo(t *testing.T) {
+	p := NewPage(LetterWidth, LetterHeight)
+	p.Text(72, 700, HelveticaBold, 18, "12 (Main) St — Apt 4")
+	p.FillRect(72, 600, 100, 10, 0.5)
+	p.Line(72, 590, 540, 590, 1)
+	img := image.NewRGBA(image.Rect(0, 0, 2500, 10))
+	img.Set(0, 0, color.White)
+	p.Image(img, 72, 400, 200, 100)
+
+	var buf bytes.Buffer
+	_, err := p.WriteTo(&buf)
+	require.NoError(t, err)
+	out := buf.Bytes()
+
+	assert.True(t, bytes.HasPrefix(out, []byte("%PDF-1.4")))
+	assert.True(t, bytes.HasSuffix(out, []byte("%%EOF\n")))
+	assert.Contains(t, string(out), "/Width 834 /Height 4", "large images are downsampled")
+
+	// Every xref entry must point at the start of its object
+	m := regexp.MustCompile(`startxref\n(\d+)`).FindSubmatch(out)
+	require.NotNil(t, m)
+	xref, _ := strconv.Atoi(string(m[1]))
+	entries := regexp.MustCompile(`(\d{10}) 00000 n`).FindAllSubmatch(out[xref:], -1)
+	require.Len(t, entries, 7)
+	for i, e := range entries {
+		off, _ := strconv.Atoi(string(e[1]))
+		assert.True(t, bytes.HasPrefix(out[off:], []byte(strconv.Itoa(i+1)+" 0 obj")), "object %d", i+1)
+	}
+}
+
+func TestEncoding(t *testing.T) {
+	assert.Equal(t, "12 \\(Main\\) St \x97 caf\xe9 ?", escape(winAnsi("12 (Main) St — café ☃")))
+}
+
+func TestWrap(t *testing.T) {
+	assert.Equal(t, 18.012, TextWidth(Helvetica, 12, "Apt"))
+	assert.Equal(t,
+		[]string{"Bright corner unit", "with a", "", "balcony"},
+		Wrap(Helvetica, 10, 90, "Bright corner unit with a\n\nbalcony"))
+}
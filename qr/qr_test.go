@@ -0,0 +1,125 @@
+package qr
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRSRemainder(t *testing.T) {
+	// "HELLO WORLD" as 1-M from the ISO/IEC 18004 worked example
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	assert.Equal(t, []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}, rsRemainder(data, rsDivisor(10)))
+}
+
+func TestFormatAndVersionBits(t *testing.T) {
+	assert.Equal(t, 0b101010000010010, formatBits(0))
+	assert.Equal(t, 0b100101010100000, formatBits(7))
+	assert.Equal(t, 0b000111110010010100, versionBits(7))
+	assert.Equal(t, 0b001010010011010011, versionBits(10))
+}
+
+// readCodewords undoes the mask named by the format information and reads
+// the codewords back in placement order
+func readCodewords(t *testing.T, c *Code) []byte {
+	format := 0
+	for i := range 6 {
+		if c.Dark(8, i) {
+			format |= 1 << i
+		}
+	}
+	var mask int
+	for m := range 8 {
+		if formatBits(m)&0x3F == format {
+			mask = m
+		}
+	}
+
+	c.applyMask(mask)
+	defer c.applyMask(mask)
+
+	var bits bitBuffer
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for vert := range c.Size {
+			y := vert
+			if upward {
+				y = c.Size - 1 - vert
+			}
+			for j := range 2 {
+				if !c.function[y][right-j] {
+					bits = append(bits, c.modules[y][right-j])
+				}
+			}
+		}
+	}
+	return bits.bytes()
+}
+
+func TestEncode(t *testing.T) {
+	for _, tc := range []struct {
+		text    string
+		version int
+	}{
+		{"https://example.com/", 2},
+		{"https://apt.example.com/share/AevN35LrG4JxQ1JR70jDmiZm", 4},
+		{strings.Repeat("x", 150), 8},
+		{strings.Repeat("x", 213), 10},
+	} {
+		c, err := Encode(tc.text)
+		require.NoError(t, err)
+		assert.Equal(t, tc.version*4+17, c.Size, tc.text)
+
+		// Finder centers and the always-dark module
+		assert.True(t, c.Dark(3, 3))
+		assert.True(t, c.Dark(c.Size-4, 3))
+		assert.True(t, c.Dark(3, c.Size-4))
+		assert.False(t, c.Dark(1, 1))
+		assert.True(t, c.Dark(8, c.Size-8))
+		assert.False(t, c.Dark(-1, 0), "quiet zone is light")
+
+		spec := versions[tc.version]
+		codewords := readCodewords(t, c)
+		// With a single block group the first data block leads the stream
+		// every blocks1+blocks2 codewords
+		stride := spec.blocks1 + spec.blocks2
+		var first bitBuffer
+		for i := range 4 {
+			first.append(int(codewords[i*stride]), 8)
+		}
+		countBits := 8
+		if tc.version >= 10 {
+			countBits = 16
+		}
+		var want bitBuffer
+		want.append(0b0100, 4)
+		want.append(len(tc.text), countBits)
+		want.append(int(tc.text[0]), 8)
+		assert.Equal(t, want, first[:len(want)], tc.text)
+	}
+
+	_, err := Encode(strings.Repeat("x", 214))
+	assert.ErrorIs(t, err, ErrTooLong)
+}
+
+func TestRender(t *testing.T) {
+	c, err := Encode("https://example.com/")
+	require.NoError(t, err)
+
+	img := c.Image(2)
+	side := (c.Size + 2*QuietZone) * 2
+	assert.Equal(t, side, img.Bounds().Dx())
+	r, _, _, _ := img.At(0, 0).RGBA()
+	assert.Equal(t, uint32(0xFFFF), r, "quiet zone is light")
+	r, _, _, _ = img.At(QuietZone*2, QuietZone*2).RGBA()
+	assert.Equal(t, uint32(0), r, "finder corner is dark")
+
+	svg := c.SVG()
+	assert.True(t, strings.HasPrefix(svg, "<svg "))
+	assert.Contains(t, svg, "M4 4h1v1h-1z")
+}
@@ -0,0 +1,144 @@
+// Package scan checks uploaded files for malware before they're stored,
+// using a ClamAV daemon
+package scan
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// Scan statuses recorded for uploaded files
+const (
+	StatusClean     = "clean"
+	StatusInfected  = "infected"  // Quarantined, never served
+	StatusUnscanned = "unscanned" // Uploaded while no scanner was configured
+)
+
+// Result is the verdict on a scanned file
+type Result struct {
+	Infected  bool
+	Signature string // Name of the detected malware
+}
+
+// Status returns the scan status to record for the result
+func (r Result) Status() string {
+	if r.Infected {
+		return StatusInfected
+	}
+	return StatusClean
+}
+
+// Scanner checks file contents for malware
+type Scanner interface {
+	Scan(ctx context.Context, r io.Reader) (Result, error)
+}
+
+// New returns a scanner for the clamd listening at address: a unix socket
+// path ("/run/clamav/clamd.ctl" or "unix:/run/clamav/clamd.ctl") or a TCP
+// address ("tcp://localhost:3310" or "localhost:3310"). An empty address
+// disables scanning and returns nil.
+func New(address string) (Scanner, error) {
+	switch {
+	case address == "":
+		return nil, nil
+	case strings.HasPrefix(address, "unix:"):
+		return &ClamAV{Network: "unix", Address: strings.TrimPrefix(address, "unix:")}, nil
+	case strings.HasPrefix(address, "/"):
+		return &ClamAV{Network: "unix", Address: address}, nil
+	case strings.HasPrefix(address, "tcp://"):
+		address = strings.TrimPrefix(address, "tcp://")
+	}
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		return nil, fmt.Errorf("invalid clamd address %q: %w", address, err)
+	}
+	return &ClamAV{Network: "tcp", Address: address}, nil
+}
+
+const (
+	// chunkSize is how much data is sent to clamd per INSTREAM chunk
+	chunkSize = 64 << 10
+
+	// timeout bounds a whole scan, since clamd may be slow on large files
+	timeout = 2 * time.Minute
+)
+
+// ClamAV scans files with clamd over its INSTREAM protocol, so the daemon
+// needn't share a filesystem with the application
+type ClamAV struct {
+	Network string // "unix" or "tcp"
+	Address string
+}
+
+// Scan implements Scanner
+func (s *ClamAV) Scan(ctx context.Context, r io.Reader) (Result, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, s.Network, s.Address)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to connect to clamd: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if err := stream(conn, r); err != nil {
+		return Result{}, fmt.Errorf("failed to send file to clamd: %w", err)
+	}
+
+	reply, err := io.ReadAll(conn)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to read clamd reply: %w", err)
+	}
+	return parseReply(string(reply))
+}
+
+// stream sends r to clamd as an INSTREAM command: length-prefixed chunks
+// ended by an empty one
+func stream(w io.Writer, r io.Reader) error {
+	if _, err := io.WriteString(w, "zINSTREAM\x00"); err != nil {
+		return err
+	}
+	buf := make([]byte, 4+chunkSize)
+	for {
+		n, err := io.ReadFull(r, buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf, uint32(n))
+			if _, err := w.Write(buf[:4+n]); err != nil {
+				return err
+			}
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	_, err := w.Write([]byte{0, 0, 0, 0})
+	return err
+}
+
+// parseReply interprets clamd's answer to INSTREAM: "stream: OK",
+// "stream: <signature> FOUND", or an error message ending in "ERROR"
+func parseReply(reply string) (Result, error) {
+	reply = strings.TrimSpace(strings.TrimRight(reply, "\x00"))
+	body := strings.TrimPrefix(reply, "stream: ")
+	switch {
+	case body == "OK":
+		return Result{}, nil
+	case strings.HasSuffix(body, " FOUND"):
+		return Result{Infected: true, Signature: strings.TrimSuffix(body, " FOUND")}, nil
+	case reply == "":
+		return Result{}, errors.New("clamd closed the connection without a reply")
+	}
+	return Result{}, fmt.Errorf("clamd: %s", reply)
+}
@@ -0,0 +1,97 @@
+package scan
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClamd accepts INSTREAM scans, flagging files containing "EICAR"
+func fakeClamd(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			r := bufio.NewReader(conn)
+			cmd, _ := r.ReadString(0)
+			if cmd != "zINSTREAM\x00" {
+				io.WriteString(conn, "UNKNOWN COMMAND\x00")
+				conn.Close()
+				continue
+			}
+			var data bytes.Buffer
+			for {
+				var n uint32
+				if binary.Read(r, binary.BigEndian, &n) != nil || n == 0 {
+					break
+				}
+				io.CopyN(&data, r, int64(n))
+			}
+			if bytes.Contains(data.Bytes(), []byte("EICAR")) {
+				io.WriteString(conn, "stream: Eicar-Test-Signature FOUND\x00")
+			} else {
+				io.WriteString(conn, "stream: OK\x00")
+			}
+			conn.Close()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestClamAVScan(t *testing.T) {
+	s, err := New("tcp://" + fakeClamd(t))
+	require.NoError(t, err)
+
+	res, err := s.Scan(context.Background(), strings.NewReader("floor plan"))
+	require.NoError(t, err)
+	assert.Equal(t, Result{}, res)
+	assert.Equal(t, StatusClean, res.Status())
+
+	// Spans several chunks, with the signature in the last
+	big := strings.Repeat("x", 3*chunkSize) + "EICAR"
+	res, err = s.Scan(context.Background(), strings.NewReader(big))
+	require.NoError(t, err)
+	assert.Equal(t, Result{Infected: true, Signature: "Eicar-Test-Signature"}, res)
+	assert.Equal(t, StatusInfected, res.Status())
+}
+
+func TestParseReply(t *testing.T) {
+	_, err := parseReply("INSTREAM size limit exceeded. ERROR\x00")
+	assert.EqualError(t, err, "clamd: INSTREAM size limit exceeded. ERROR")
+	_, err = parseReply("")
+	assert.Error(t, err)
+}
+
+func TestNew(t *testing.T) {
+	s, err := New("")
+	assert.NoError(t, err)
+	assert.Nil(t, s)
+
+	for address, want := range map[string]ClamAV{
+		"/run/clamav/clamd.ctl":      {Network: "unix", Address: "/run/clamav/clamd.ctl"},
+		"unix:/run/clamav/clamd.ctl": {Network: "unix", Address: "/run/clamav/clamd.ctl"},
+		"tcp://localhost:3310":       {Network: "tcp", Address: "localhost:3310"},
+		"clamav:3310":                {Network: "tcp", Address: "clamav:3310"},
+	} {
+		s, err := New(address)
+		require.NoError(t, err, address)
+		assert.Equal(t, &want, s, address)
+	}
+
+	_, err = New("localhost")
+	assert.Error(t, err)
+}
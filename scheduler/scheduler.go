@@ -0,0 +1,210 @@
+// Package scheduler runs recurring background tasks (backups, cleanups,
+// reminders) on cron-style schedules and tracks the outcome of each run.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ErrUnknownTask is returned when a task name isn't registered
+var ErrUnknownTask = errors.New("unknown task")
+
+// ErrTaskRunning is returned when a task is triggered while already running
+var ErrTaskRunning = errors.New("task is already running")
+
+// TaskFunc is the work performed by a task
+type TaskFunc func(ctx context.Context) error
+
+// TaskStatus reports the schedule and most recent outcome of a task
+type TaskStatus struct {
+	Name         string     `json:"name"`
+	Schedule     string     `json:"schedule"`
+	Running      bool       `json:"running"`
+	NextRun      *time.Time `json:"next_run,omitempty"`
+	LastRun      *time.Time `json:"last_run,omitempty"`
+	LastDuration string     `json:"last_duration,omitempty"`
+	LastError    string     `json:"last_error,omitempty"`
+	RunCount     int        `json:"run_count"`
+	FailCount    int        `json:"fail_count"`
+}
+
+// task is a registered task and its bookkeeping
+type task struct {
+	fn       TaskFunc
+	schedule Schedule
+	status   TaskStatus
+}
+
+// Scheduler runs registered tasks on their schedules
+type Scheduler struct {
+	mu      sync.Mutex
+	tasks   map[string]*task
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	started bool
+}
+
+// New creates an idle scheduler; register tasks and then call Start
+func New() *Scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Scheduler{
+		tasks:  make(map[string]*task),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Register adds a task. spec is anything ParseSchedule accepts. Tasks
+// registered after Start begin running immediately.
+func (s *Scheduler) Register(name, spec string, fn TaskFunc) error {
+	schedule, err := ParseSchedule(spec)
+	if err != nil {
+		return fmt.Errorf("invalid schedule %q for task %s: %w", spec, name, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.tasks[name]; exists {
+		return fmt.Errorf("task %s is already registered", name)
+	}
+
+	t := &task{
+		fn:       fn,
+		schedule: schedule,
+		status:   TaskStatus{Name: name, Schedule: spec},
+	}
+	s.tasks[name] = t
+
+	if s.started {
+		s.loop(t)
+	}
+	return nil
+}
+
+// Start launches the scheduling loop for every registered task
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.started {
+		return
+	}
+	s.started = true
+	for _, t := range s.tasks {
+		s.loop(t)
+	}
+	log.Info().Int("tasks", len(s.tasks)).Msg("Scheduler started")
+}
+
+// Stop cancels pending runs and waits for running tasks to return
+func (s *Scheduler) Stop() {
+	s.cancel()
+	s.wg.Wait()
+	log.Info().Msg("Scheduler stopped")
+}
+
+// loop runs t whenever its schedule fires; callers must hold s.mu
+func (s *Scheduler) loop(t *task) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			next := t.schedule.Next(time.Now())
+			s.mu.Lock()
+			t.status.NextRun = &next
+			s.mu.Unlock()
+
+			timer := time.NewTimer(time.Until(next))
+			select {
+			case <-s.ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+				_ = s.run(t)
+			}
+		}
+	}()
+}
+
+// run executes t once, recording the outcome
+func (s *Scheduler) run(t *task) error {
+	s.mu.Lock()
+	if t.status.Running {
+		s.mu.Unlock()
+		return ErrTaskRunning
+	}
+	t.status.Running = true
+	name := t.status.Name
+	s.mu.Unlock()
+
+	start := time.Now()
+	log.Info().Str("task", name).Msg("Running scheduled task")
+	err := t.fn(s.ctx)
+	elapsed := time.Since(start)
+
+	s.mu.Lock()
+	t.status.Running = false
+	t.status.LastRun = &start
+	t.status.LastDuration = elapsed.Round(time.Millisecond).String()
+	t.status.RunCount++
+	t.status.LastError = ""
+	if err != nil {
+		t.status.LastError = err.Error()
+		t.status.FailCount++
+	}
+	s.mu.Unlock()
+
+	if err != nil {
+		log.Error().Err(err).Str("task", name).Dur("elapsed", elapsed).Msg("Scheduled task failed")
+	} else {
+		log.Info().Str("task", name).Dur("elapsed", elapsed).Msg("Scheduled task completed")
+	}
+	return err
+}
+
+// RunNow runs the named task immediately in the background, outside its
+// regular schedule
+func (s *Scheduler) RunNow(name string) error {
+	s.mu.Lock()
+	t, ok := s.tasks[name]
+	running := ok && t.status.Running
+	s.mu.Unlock()
+
+	if !ok {
+		return ErrUnknownTask
+	}
+	if running {
+		return ErrTaskRunning
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		_ = s.run(t)
+	}()
+	return nil
+}
+
+// Status returns the status of every registered task, sorted by name
+func (s *Scheduler) Status() []TaskStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := make([]TaskStatus, 0, len(s.tasks))
+	for _, t := range s.tasks {
+		statuses = append(statuses, t.status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+	return statuses
+}
@@ -0,0 +1,89 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSchedule(t *testing.T) {
+	base := time.Date(2025, 9, 5, 14, 30, 0, 0, time.UTC) // a Friday
+
+	tests := []struct {
+		spec     string
+		expected time.Time
+	}{
+		{"@hourly", time.Date(2025, 9, 5, 15, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2025, 9, 6, 0, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2025, 9, 5, 14, 45, 0, 0, time.UTC)},
+		{"0 9 * * 1-5", time.Date(2025, 9, 8, 9, 0, 0, 0, time.UTC)},
+		{"30 2 1 * *", time.Date(2025, 10, 1, 2, 30, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"@every 90m", time.Date(2025, 9, 5, 16, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		schedule, err := ParseSchedule(tt.spec)
+		assert.NoError(t, err, tt.spec)
+		assert.Equal(t, tt.expected, schedule.Next(base), tt.spec)
+	}
+}
+
+func TestParseScheduleErrors(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "*/0 * * * *", "5-1 * * * *", "@every soon", "@fortnightly"} {
+		_, err := ParseSchedule(spec)
+		assert.Error(t, err, "Expected error for %q", spec)
+	}
+}
+
+func TestRegisterDuplicate(t *testing.T) {
+	s := New()
+	noop := func(context.Context) error { return nil }
+	assert.NoError(t, s.Register("task", "@daily", noop))
+	assert.Error(t, s.Register("task", "@hourly", noop), "Duplicate task names should be rejected")
+	assert.Error(t, s.Register("bad", "not a schedule", noop), "Invalid schedules should be rejected")
+}
+
+func TestRunNowRecordsStatus(t *testing.T) {
+	s := New()
+	done := make(chan struct{}, 2)
+	calls := 0
+	assert.NoError(t, s.Register("flaky", "@daily", func(context.Context) error {
+		defer func() { done <- struct{}{} }()
+		calls++
+		if calls == 1 {
+			return errors.New("boom")
+		}
+		return nil
+	}))
+
+	assert.ErrorIs(t, s.RunNow("missing"), ErrUnknownTask)
+
+	assert.NoError(t, s.RunNow("flaky"))
+	<-done
+	assert.Eventually(t, func() bool { return s.Status()[0].RunCount == 1 }, time.Second, 10*time.Millisecond)
+
+	status := s.Status()[0]
+	assert.Equal(t, "flaky", status.Name)
+	assert.Equal(t, 1, status.FailCount)
+	assert.Equal(t, "boom", status.LastError)
+	assert.NotNil(t, status.LastRun)
+
+	assert.NoError(t, s.RunNow("flaky"))
+	<-done
+	assert.Eventually(t, func() bool { return s.Status()[0].RunCount == 2 }, time.Second, 10*time.Millisecond)
+	assert.Empty(t, s.Status()[0].LastError, "A successful run should clear the last error")
+
+	s.Stop()
+}
+
+func TestStartSchedulesNextRun(t *testing.T) {
+	s := New()
+	assert.NoError(t, s.Register("tick", "@every 1h", func(context.Context) error { return nil }))
+	s.Start()
+	assert.Eventually(t, func() bool { return s.Status()[0].NextRun != nil }, time.Second, 10*time.Millisecond)
+	s.Stop()
+}
@@ -0,0 +1,119 @@
+// Package scoring combines per-category apartment ratings into a single
+// weighted score
+package scoring
+
+import (
+	"fmt"
+	"math"
+)
+
+// Rating categories
+const (
+	Location  = "location"
+	Condition = "condition"
+	Kitchen   = "kitchen"
+	Noise     = "noise"
+	Value     = "value"
+)
+
+// Categories lists the rating categories in display order
+var Categories = []string{Location, Condition, Kitchen, Noise, Value}
+
+// Weights maps each category to its relative importance
+type Weights map[string]float64
+
+// DefaultWeights weighs every category equally
+func DefaultWeights() Weights {
+	w := Weights{}
+	for _, c := range Categories {
+		w[c] = 1
+	}
+	return w
+}
+
+// Validate checks that weights only names known categories, that none is
+// negative, and that at least one is positive
+func (w Weights) Validate() error {
+	var total float64
+	for c, weight := range w {
+		if !isCategory(c) {
+			return fmt.Errorf("unknown rating category %q", c)
+		}
+		if weight < 0 || math.IsNaN(weight) || math.IsInf(weight, 0) {
+			return fmt.Errorf("weight for %s must be a non-negative number", c)
+		}
+		total += weight
+	}
+	if total == 0 {
+		return fmt.Errorf("at least one weight must be positive")
+	}
+	return nil
+}
+
+func isCategory(c string) bool {
+	for _, known := range Categories {
+		if c == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Score returns the weighted average of the rated categories, rounded to
+// two decimals. Unrated categories are left out rather than counted as
+// zero. It returns nil when no weighted category is rated.
+func Score(ratings map[string]*int, w Weights) *float64 {
+	var sum, total float64
+	for _, c := range Categories {
+		r := ratings[c]
+		if r == nil {
+			continue
+		}
+		sum += w[c] * float64(*r)
+		total += w[c]
+	}
+	if total == 0 {
+		return nil
+	}
+	score := math.Round(sum/total*100) / 100
+	return &score
+}
+
+// Contribution is one category's part in a score. Points across the
+// categories add up to the score, give or take rounding.
+type Contribution struct {
+	Category string  `json:"category"`
+	Rating   *int    `json:"rating"` // nil when unrated
+	Weight   float64 `json:"weight"`
+	Share    float64 `json:"share"`  // Fraction of the score this category decides
+	Points   float64 `json:"points"` // Rating times share
+}
+
+// Breakdown explains Score: how much each category contributes, in
+// Categories order. Unrated categories are listed with no share, since
+// Score leaves them out.
+func Breakdown(ratings map[string]*int, w Weights) []Contribution {
+	var total float64
+	for _, c := range Categories {
+		if ratings[c] != nil {
+			total += w[c]
+		}
+	}
+
+	contributions := make([]Contribution, 0, len(Categories))
+	for _, c := range Categories {
+		contribution := Contribution{Category: c, Rating: ratings[c], Weight: w[c]}
+		if r := ratings[c]; r != nil && total > 0 {
+			contribution.Share = math.Round(w[c]/total*1000) / 1000
+			contribution.Points = math.Round(w[c]*float64(*r)/total*100) / 100
+		}
+		contributions = append(contributions, contribution)
+	}
+	return contributions
+}
+
+// LegacyRating converts a score to the whole-number 1-5 rating older
+// clients expect
+func LegacyRating(score float64) int {
+	return int(math.Round(score))
+}
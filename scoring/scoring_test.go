@@ -0,0 +1,54 @@
+package scoring
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func intp(i int) *int { return &i }
+
+func TestScore(t *testing.T) {
+	w := Weights{Location: 2, Condition: 1, Kitchen: 1, Noise: 0, Value: 1}
+
+	score := Score(map[string]*int{Location: intp(5), Condition: intp(2), Noise: intp(1)}, w)
+	if assert.NotNil(t, score) {
+		// (2*5 + 1*2) / 3; noise has no weight and kitchen/value are unrated
+		assert.Equal(t, 4.0, *score)
+	}
+
+	assert.Nil(t, Score(map[string]*int{}, w), "no ratings should give no score")
+	assert.Nil(t, Score(map[string]*int{Noise: intp(3)}, w), "only zero-weight ratings should give no score")
+}
+
+func TestBreakdown(t *testing.T) {
+	w := Weights{Location: 2, Condition: 1, Kitchen: 1, Noise: 0, Value: 1}
+	ratings := map[string]*int{Location: intp(5), Condition: intp(2), Noise: intp(1)}
+	b := Breakdown(ratings, w)
+	assert.Len(t, b, len(Categories))
+
+	var points float64
+	for _, c := range b {
+		points += c.Points
+	}
+	assert.InDelta(t, *Score(ratings, w), points, 0.01)
+
+	assert.Equal(t, Location, b[0].Category)
+	assert.InDelta(t, 0.667, b[0].Share, 1e-9)
+	assert.InDelta(t, 3.33, b[0].Points, 1e-9)
+	assert.Nil(t, b[2].Rating, "kitchen is unrated")
+	assert.Zero(t, b[2].Share)
+	assert.Zero(t, b[3].Points, "noise has no weight")
+}
+
+func TestLegacyRating(t *testing.T) {
+	assert.Equal(t, 4, LegacyRating(3.5))
+	assert.Equal(t, 3, LegacyRating(3.49))
+}
+
+func TestWeightsValidate(t *testing.T) {
+	assert.NoError(t, DefaultWeights().Validate())
+	assert.Error(t, Weights{"size": 1}.Validate())
+	assert.Error(t, Weights{Location: -1, Value: 2}.Validate())
+	assert.Error(t, Weights{Location: 0}.Validate())
+}
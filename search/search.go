@@ -0,0 +1,131 @@
+// Package search holds the text matching behind the typeahead and the
+// list's search parameter: folding text to a comparable form, breaking it
+// into trigrams, the three-character pieces that let "mapel" still share
+// most of its pieces with "maple", and counting the typos between words.
+package search
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Normalize lowercases s, strips accents, and turns runs of punctuation
+// and spaces into single spaces, so "Café  Row, #2" becomes "cafe row 2"
+func Normalize(s string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range norm.NFD.String(s) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			continue
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if space && b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			space = false
+			b.WriteRune(unicode.ToLower(r))
+		default:
+			space = true
+		}
+	}
+	return b.String()
+}
+
+// Trigrams returns the distinct trigrams of each word of normalized text.
+// Words are padded with two spaces in front and one behind, as in
+// PostgreSQL's pg_trgm, so word starts count for more and a single letter
+// still has trigrams.
+func Trigrams(text string) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, word := range strings.Fields(text) {
+		padded := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(padded); i++ {
+			t := string(padded[i : i+3])
+			if !seen[t] {
+				seen[t] = true
+				out = append(out, t)
+			}
+		}
+	}
+	return out
+}
+
+// Similarity is the share of trigrams two normalized texts have in common,
+// from 0 for nothing to 1 for the same trigrams, given how many each has
+// and how many they share
+func Similarity(a, b, shared int) float64 {
+	if a+b-shared <= 0 {
+		return 0
+	}
+	return float64(shared) / float64(a+b-shared)
+}
+
+// MaxEdits is how many typos a word of a search may have: none for short
+// words, which would otherwise match too much, one up to six letters, and
+// two beyond that
+func MaxEdits(word string) int {
+	switch n := len([]rune(word)); {
+	case n <= 3:
+		return 0
+	case n <= 6:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// MinShared is the fewest trigrams a word matching the search word must
+// share with it. Each typo changes at most three trigrams, and a longer
+// word starting with the search word lacks only its closing trigram, so
+// anything with fewer can't match and is skipped before comparing.
+func MinShared(word string) int {
+	return max(1, len(Trigrams(word))-1-3*MaxEdits(word))
+}
+
+// Distance is the number of single-letter insertions, deletions,
+// substitutions, and swaps of adjacent letters that turn a into b
+func Distance(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	// Three rows of the dynamic programming table: two back, one back, and
+	// the current one
+	prev2 := make([]int, len(t)+1)
+	prev := make([]int, len(t)+1)
+	cur := make([]int, len(t)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		cur[0] = i
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && s[i-1] == t[j-2] && s[i-2] == t[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(t)]
+}
+
+// MatchWord reports whether a word of normalized text is the search word
+// or starts with it, give or take MaxEdits typos
+func MatchWord(search, text string) bool {
+	k := MaxEdits(search)
+	n := len([]rune(search))
+	for _, word := range strings.Fields(text) {
+		if Distance(search, word) <= k {
+			return true
+		}
+		if r := []rune(word); len(r) > n && Distance(search, string(r[:n])) <= k {
+			return true
+		}
+	}
+	return false
+}
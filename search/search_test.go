@@ -0,0 +1,62 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalize(t *testing.T) {
+	assert.Equal(t, "cafe row 2", Normalize("  Café  Row, #2 "))
+	assert.Equal(t, "willowbrook apartments", Normalize("WILLOWBROOK Apartments!"))
+	assert.Equal(t, "", Normalize(" -- "))
+}
+
+func TestTrigrams(t *testing.T) {
+	assert.Equal(t, []string{"  a", " a "}, Trigrams("a"))
+	assert.Equal(t, []string{"  o", " oa", "oak", "ak "}, Trigrams("oak oak"))
+
+	a, b := Trigrams("maple"), Trigrams("mapel")
+	shared := 0
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				shared++
+			}
+		}
+	}
+	assert.InDelta(t, 1.0/3, Similarity(len(a), len(b), shared), 0.01)
+	assert.Equal(t, 1.0, Similarity(len(a), len(a), len(a)))
+	assert.Equal(t, 0.0, Similarity(0, 0, 0))
+}
+
+func TestDistance(t *testing.T) {
+	assert.Equal(t, 0, Distance("oak", "oak"))
+	assert.Equal(t, 1, Distance("willowbrok", "willowbrook"))
+	assert.Equal(t, 1, Distance("mapel", "maple"))
+	assert.Equal(t, 3, Distance("kitten", "sitting"))
+	assert.Equal(t, 3, Distance("", "abc"))
+	assert.Equal(t, 2, Distance("café", "cfae"))
+}
+
+func TestMatchWord(t *testing.T) {
+	assert.True(t, MatchWord("willowbrok", "willowbrook apartments"))
+	assert.True(t, MatchWord("willow", "willowbrook apartments"))
+	assert.True(t, MatchWord("apartmnets", "willowbrook apartments"))
+	assert.True(t, MatchWord("oak", "40 oak st"))
+	assert.False(t, MatchWord("oat", "40 oak st"))
+	assert.False(t, MatchWord("pine", "40 oak st"))
+
+	// Every word that matches shares at least MinShared trigrams with it
+	for _, pair := range [][2]string{{"willowbrok", "willowbrook"}, {"apartmnets", "apartments"}, {"mapel", "maple"}, {"wil", "willow"}} {
+		shared := 0
+		for _, a := range Trigrams(pair[0]) {
+			for _, b := range Trigrams(pair[1]) {
+				if a == b {
+					shared++
+				}
+			}
+		}
+		assert.GreaterOrEqual(t, shared, MinShared(pair[0]), pair[0])
+	}
+}
@@ -0,0 +1,97 @@
+package seed
+
+import (
+	"time"
+
+	"github.com/mojotx/apt-eval/db"
+	"github.com/mojotx/apt-eval/models"
+)
+
+// Demo returns the sample apartments shown in demo mode: a small, made-up
+// search in Denver at every stage of the pipeline. Visit dates are
+// relative to now, so the demo never looks stale.
+func Demo(now time.Time) []Apartment {
+	day := func(days int) models.CustomTime {
+		return models.CustomTime{Time: now.UTC().Truncate(24*time.Hour).AddDate(0, 0, days)}
+	}
+	num := func(v float64) *float64 { return &v }
+	stars := func(v int) *int { return &v }
+	level := func(v uint) *uint { return &v }
+	url := func(s string) *string { return &s }
+
+	apartments := []models.ApartmentRequest{
+		{
+			Address: "1420 Larimer St #5B, Denver, CO", Price: num(2150), Floor: level(5),
+			Latitude: num(39.7486), Longitude: num(-104.9996),
+			Status: models.StatusVisited, VisitDate: day(-6), HasLaundry: true, IsGated: true,
+			Notes: "Huge windows facing the mountains. Elevator was slow but the gym is nice.",
+			Ratings: &models.CategoryRatings{
+				Location: stars(5), Condition: stars(4), Kitchen: stars(4), Noise: stars(2), Value: stars(3),
+			},
+			Utilities:  &models.Utilities{Electricity: num(70), Internet: num(60), WaterIncluded: true, GasIncluded: true},
+			Parking:    &models.Parking{Type: models.ParkingGarage, MonthlyCost: num(150), EVCharging: true},
+			ListingURL: url("https://listings.example.com/demo/larimer-5b"),
+		},
+		{
+			Address: "2905 W 25th Ave, Denver, CO", Price: num(1795), Floor: level(2),
+			Latitude: num(39.7530), Longitude: num(-105.0233),
+			Status: models.StatusApplied, VisitDate: day(-10), HasLaundry: true,
+			Notes: "Quiet street near Sloan's Lake. Application fee $50, landlord replies within a day.",
+			Ratings: &models.CategoryRatings{
+				Location: stars(4), Condition: stars(4), Kitchen: stars(3), Noise: stars(5), Value: stars(4),
+			},
+			Utilities: &models.Utilities{Electricity: num(55), Gas: num(30), Water: num(40), Internet: num(50)},
+			Parking:   &models.Parking{Type: models.ParkingStreet},
+		},
+		{
+			Address: "700 N Washington St #12, Denver, CO", Price: num(1450), Floor: level(3),
+			Latitude: num(39.7275), Longitude: num(-104.9787),
+			Status: models.StatusScheduled, VisitDate: day(2),
+			Notes: "Vintage building in Capitol Hill. Ask about radiator heat and pets.",
+		},
+		{
+			Address: "3401 Blake St #210, Denver, CO", Price: num(1980), Floor: level(2),
+			Latitude: num(39.7668), Longitude: num(-104.9792),
+			Status: models.StatusConsidering, HasLaundry: true,
+			Notes:   "New construction in RiNo, two blocks from the light rail.",
+			Parking: &models.Parking{Type: models.ParkingAssigned, MonthlyCost: num(75)},
+		},
+		{
+			Address: "1875 S Pearl St, Denver, CO", Price: num(1625), Floor: level(1),
+			Latitude: num(39.6829), Longitude: num(-104.9806),
+			Status: models.StatusConsidering,
+			Notes:  "Garden level unit near Platt Park. Listing photos look dated.",
+		},
+		{
+			Address: "1050 Cherokee St #8, Denver, CO", Price: num(1350), Floor: level(4),
+			Latitude: num(39.7329), Longitude: num(-104.9911),
+			Status: models.StatusRejected, VisitDate: day(-15),
+			Notes: "Cheap, but the bedroom faces the bar patio next door.",
+			Ratings: &models.CategoryRatings{
+				Location: stars(4), Condition: stars(2), Kitchen: stars(2), Noise: stars(1), Value: stars(4),
+			},
+		},
+		{
+			Address: "4120 Tennyson St, Denver, CO", Price: num(2300), Floor: level(1),
+			Latitude: num(39.7735), Longitude: num(-105.0438),
+			Status: models.StatusDraft,
+			Notes:  "Captured from a listing alert; townhouse with a small yard.",
+		},
+	}
+
+	demo := make([]Apartment, len(apartments))
+	for i, req := range apartments {
+		demo[i] = Apartment{Request: req}
+	}
+	return demo
+}
+
+// LoadDemo adds the demo apartments to the database
+func LoadDemo(database *db.DB, now time.Time) error {
+	apartments := Demo(now)
+	requests := make([]models.ApartmentRequest, len(apartments))
+	for i, a := range apartments {
+		requests[i] = a.Request
+	}
+	return database.SeedApartments(requests, nil)
+}
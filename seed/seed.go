@@ -0,0 +1,175 @@
+// Package seed generates realistic fake apartments, for benchmarking
+// pagination, search, and filters against a database of 10,000 or more
+package seed
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/mojotx/apt-eval/db"
+	"github.com/mojotx/apt-eval/models"
+)
+
+// MaxCount is the most apartments one seeding run may generate
+const MaxCount = 100000
+
+// Apartment is a generated apartment and the placeholder photo for it
+type Apartment struct {
+	Request  models.ApartmentRequest
+	PhotoURL string
+}
+
+// city is a place apartments are generated in, around its center and its
+// typical rent
+type city struct {
+	name, state string
+	lat, lon    float64
+	rent        float64
+}
+
+var cities = []city{
+	{"Austin", "TX", 30.2672, -97.7431, 1650},
+	{"Denver", "CO", 39.7392, -104.9903, 1800},
+	{"Portland", "OR", 45.5152, -122.6784, 1700},
+	{"Raleigh", "NC", 35.7796, -78.6382, 1450},
+	{"Minneapolis", "MN", 44.9778, -93.2650, 1400},
+	{"Columbus", "OH", 39.9612, -82.9988, 1200},
+	{"Phoenix", "AZ", 33.4484, -112.0740, 1500},
+	{"Boston", "MA", 42.3601, -71.0589, 2900},
+}
+
+var (
+	streetSuffixes = []string{"St", "Ave", "Blvd", "Rd", "Ln", "Dr", "Ct", "Way", "Pl"}
+	unitLetters    = []string{"A", "B", "C", "D"}
+
+	// statusWeights favors the early pipeline, like a real search
+	statusWeights = []struct {
+		status string
+		weight int
+	}{
+		{models.StatusDraft, 5},
+		{models.StatusConsidering, 40},
+		{models.StatusScheduled, 10},
+		{models.StatusVisited, 20},
+		{models.StatusApplied, 5},
+		{models.StatusSigned, 1},
+		{models.StatusRejected, 15},
+		{models.StatusArchived, 4},
+	}
+
+	notes = []string{
+		"Great natural light in the living room.",
+		"Street noise from the bus line.",
+		"Landlord seemed responsive.",
+		"Kitchen needs updating.",
+		"Close to the park and a grocery store.",
+		"Small closets, but lots of storage in the basement.",
+		"Pet friendly with a deposit.",
+		"Parking is tight on weekends.",
+		"Recently renovated bathroom.",
+		"Laundry room shared with the whole floor.",
+	}
+)
+
+// Generate returns n apartments, the same ones for the same seed
+func Generate(n int, seed uint64) []Apartment {
+	r := rand.New(rand.NewPCG(seed, seed^0x9e3779b97f4a7c15))
+	faker := gofakeit.New(seed)
+	now := time.Now().UTC().Truncate(24 * time.Hour)
+
+	apartments := make([]Apartment, n)
+	for i := range apartments {
+		apartments[i] = generate(r, faker, i+1, now)
+	}
+	return apartments
+}
+
+// generate makes up the ith apartment, on a street faker makes up in one
+// of the cities
+func generate(r *rand.Rand, faker *gofakeit.Faker, i int, now time.Time) Apartment {
+	c := cities[r.IntN(len(cities))]
+	addr := faker.Street() + " " + pick(r, streetSuffixes)
+	floor := uint(1 + r.IntN(12))
+	if r.IntN(3) > 0 {
+		addr += fmt.Sprintf(" #%d%s", floor, pick(r, unitLetters))
+	}
+	addr += fmt.Sprintf(", %s, %s", c.name, c.state)
+
+	// Rent varies around the city's typical rent, to the nearest $5
+	price := math.Round(c.rent*(0.6+r.Float64()*0.9)/5) * 5
+
+	// Within about 10 km of the center
+	lat := c.lat + (r.Float64()-0.5)*0.18
+	lon := c.lon + (r.Float64()-0.5)*0.18/math.Cos(c.lat*math.Pi/180)
+
+	listingURL := fmt.Sprintf("https://listings.example.com/apartments/%d", i)
+	req := models.ApartmentRequest{
+		Address:    addr,
+		Price:      &price,
+		Floor:      &floor,
+		IsGated:    r.IntN(4) == 0,
+		HasGarage:  r.IntN(3) == 0,
+		HasLaundry: r.IntN(2) == 0,
+		Latitude:   &lat,
+		Longitude:  &lon,
+		Status:     status(r),
+		ListingURL: &listingURL,
+	}
+	if r.IntN(2) == 0 {
+		req.Notes = pick(r, notes)
+	}
+
+	switch req.Status {
+	case models.StatusDraft, models.StatusConsidering:
+	case models.StatusScheduled:
+		req.VisitDate = models.CustomTime{Time: now.AddDate(0, 0, 1+r.IntN(14))}
+	default:
+		req.VisitDate = models.CustomTime{Time: now.AddDate(0, 0, -1-r.IntN(90))}
+		rating := 1 + r.IntN(5)
+		req.Rating = &rating
+	}
+
+	return Apartment{
+		Request:  req,
+		PhotoURL: fmt.Sprintf("https://picsum.photos/seed/apt-eval-%d/800/600", i),
+	}
+}
+
+// status picks a status by its weight
+func status(r *rand.Rand) string {
+	total := 0
+	for _, s := range statusWeights {
+		total += s.weight
+	}
+	n := r.IntN(total)
+	for _, s := range statusWeights {
+		if n < s.weight {
+			return s.status
+		}
+		n -= s.weight
+	}
+	return models.StatusConsidering
+}
+
+func pick(r *rand.Rand, options []string) string {
+	return options[r.IntN(len(options))]
+}
+
+// Load generates n apartments and adds them to the database in one
+// transaction
+func Load(database *db.DB, n int, seed uint64) error {
+	if n < 1 || n > MaxCount {
+		return fmt.Errorf("count must be between 1 and %d", MaxCount)
+	}
+
+	apartments := Generate(n, seed)
+	requests := make([]models.ApartmentRequest, n)
+	photos := make([]string, n)
+	for i, a := range apartments {
+		requests[i], photos[i] = a.Request, a.PhotoURL
+	}
+	return database.SeedApartments(requests, photos)
+}
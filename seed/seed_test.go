@@ -0,0 +1,44 @@
+package seed
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mojotx/apt-eval/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerate(t *testing.T) {
+	apartments := Generate(500, 42)
+	assert.Len(t, apartments, 500)
+	assert.Equal(t, apartments, Generate(500, 42), "same seed, same apartments")
+	assert.NotEqual(t, apartments, Generate(500, 43))
+
+	for _, a := range apartments {
+		req := a.Request
+		assert.NotEmpty(t, req.Address)
+		if assert.NotNil(t, req.Price) {
+			assert.Greater(t, *req.Price, 0.0)
+			assert.InDelta(t, 0, float64(int(*req.Price)%5), 0, "rent rounds to $5")
+		}
+		assert.Contains(t, models.Statuses, req.Status)
+		if req.Rating != nil {
+			assert.True(t, *req.Rating >= 1 && *req.Rating <= 5)
+		}
+		assert.NotEmpty(t, a.PhotoURL)
+		if req.Status == models.StatusConsidering {
+			assert.True(t, req.VisitDate.IsZero())
+		}
+	}
+}
+
+func TestDemo(t *testing.T) {
+	now := time.Date(2026, 5, 10, 15, 0, 0, 0, time.UTC)
+	for _, a := range Demo(now) {
+		req := a.Request
+		assert.Contains(t, models.Statuses, req.Status, req.Address)
+		if req.Status == models.StatusScheduled {
+			assert.True(t, req.VisitDate.After(now), "scheduled visits are upcoming")
+		}
+	}
+}
@@ -0,0 +1,317 @@
+// Package sentry reports errors to Sentry, or a compatible error tracker
+// like GlitchTip, through its envelope API. Events are sent in the
+// background, so reporting never holds up the request that failed.
+package sentry
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// httpClient is used for calls to the error tracker
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// Client sends events to the project a DSN names. A nil Client reports
+// nothing.
+type Client struct {
+	endpoint    string
+	dsn         string
+	publicKey   string
+	release     string
+	environment string
+	serverName  string
+
+	wg sync.WaitGroup
+}
+
+// New creates a client for dsn, as shown in the project's settings:
+// https://<key>@<host>/<project>. An empty dsn returns a nil client. The
+// release defaults to the VCS revision the binary was built from.
+func New(dsn, release, environment string) (*Client, error) {
+	if dsn == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Sentry DSN: %w", err)
+	}
+	project := strings.Trim(u.Path, "/")
+	if (u.Scheme != "https" && u.Scheme != "http") || u.User == nil || u.User.Username() == "" || project == "" {
+		return nil, fmt.Errorf("invalid Sentry DSN, want https://<key>@<host>/<project>")
+	}
+
+	// A DSN may carry a path prefix before the project ID
+	prefix, projectID := "", project
+	if i := strings.LastIndex(project, "/"); i >= 0 {
+		prefix, projectID = "/"+project[:i], project[i+1:]
+	}
+
+	if release == "" {
+		release = buildRevision()
+	}
+	hostname, _ := os.Hostname()
+	return &Client{
+		endpoint:    fmt.Sprintf("%s://%s%s/api/%s/envelope/", u.Scheme, u.Host, prefix, projectID),
+		dsn:         dsn,
+		publicKey:   u.User.Username(),
+		release:     release,
+		environment: environment,
+		serverName:  hostname,
+	}, nil
+}
+
+// buildRevision returns the VCS revision in the binary's build info, if
+// it was built from a checkout
+func buildRevision() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	for _, s := range info.Settings {
+		if s.Key == "vcs.revision" {
+			return s.Value
+		}
+	}
+	return ""
+}
+
+// Event is an error report
+type Event struct {
+	EventID     string            `json:"event_id"`
+	Timestamp   time.Time         `json:"timestamp"`
+	Level       string            `json:"level"`
+	Platform    string            `json:"platform"`
+	Logger      string            `json:"logger,omitempty"`
+	Message     string            `json:"message,omitempty"`
+	Release     string            `json:"release,omitempty"`
+	Environment string            `json:"environment,omitempty"`
+	ServerName  string            `json:"server_name,omitempty"`
+	Transaction string            `json:"transaction,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
+	Request     *Request          `json:"request,omitempty"`
+	User        *User             `json:"user,omitempty"`
+	Exception   *Exceptions       `json:"exception,omitempty"`
+}
+
+// Request is the request an event happened during
+type Request struct {
+	Method      string            `json:"method"`
+	URL         string            `json:"url"`
+	QueryString string            `json:"query_string,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+}
+
+// User is who made the request
+type User struct {
+	ID string `json:"id"`
+}
+
+// Exceptions holds the error an event reports
+type Exceptions struct {
+	Values []Exception `json:"values"`
+}
+
+// Exception is an error or a panic, and where it happened
+type Exception struct {
+	Type       string      `json:"type"`
+	Value      string      `json:"value"`
+	Stacktrace *Stacktrace `json:"stacktrace,omitempty"`
+}
+
+// Stacktrace lists the calls that led to an exception, outermost first
+type Stacktrace struct {
+	Frames []Frame `json:"frames"`
+}
+
+// Frame is one call in a stack trace
+type Frame struct {
+	Function string `json:"function"`
+	Module   string `json:"module,omitempty"`
+	Filename string `json:"filename"`
+	AbsPath  string `json:"abs_path"`
+	Lineno   int    `json:"lineno"`
+	InApp    bool   `json:"in_app"`
+}
+
+// safeHeaders are the request headers sent with an event. Anything that
+// could carry a credential or personal details, like cookies, is left out.
+var safeHeaders = []string{"User-Agent", "Referer", "Content-Type", "Content-Length", "Accept", "X-Request-ID"}
+
+// NewRequest describes r for an event, without its credentials
+func NewRequest(r *http.Request) *Request {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	req := &Request{
+		Method:      r.Method,
+		URL:         scheme + "://" + r.Host + r.URL.Path,
+		QueryString: r.URL.RawQuery,
+		Headers:     make(map[string]string),
+	}
+	for _, h := range safeHeaders {
+		if v := r.Header.Get(h); v != "" {
+			req.Headers[h] = v
+		}
+	}
+	return req
+}
+
+// Stack returns the calls leading to the caller of Stack, skipping skip
+// more of the innermost ones, such as a recovery handler and the runtime's
+// panic machinery
+func Stack(skip int) *Stacktrace {
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(2+skip, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	var trace []Frame
+	for {
+		f, more := frames.Next()
+		module, function := splitFunction(f.Function)
+		trace = append(trace, Frame{
+			Function: function,
+			Module:   module,
+			Filename: shortFile(f.File),
+			AbsPath:  f.File,
+			Lineno:   f.Line,
+			InApp:    strings.HasPrefix(module, "github.com/mojotx/apt-eval"),
+		})
+		if !more {
+			break
+		}
+	}
+
+	// Sentry wants the outermost call first
+	for i, j := 0, len(trace)-1; i < j; i, j = i+1, j-1 {
+		trace[i], trace[j] = trace[j], trace[i]
+	}
+	return &Stacktrace{Frames: trace}
+}
+
+// splitFunction splits a qualified function name, like
+// github.com/mojotx/apt-eval/handlers.(*H).Get, into its package and the
+// function in it
+func splitFunction(name string) (module, function string) {
+	slash := strings.LastIndex(name, "/")
+	dot := strings.Index(name[slash+1:], ".")
+	if dot < 0 {
+		return "", name
+	}
+	return name[:slash+1+dot], name[slash+1+dot+1:]
+}
+
+// shortFile trims a source path to its package directory and file name
+func shortFile(path string) string {
+	parts := strings.Split(path, "/")
+	if len(parts) > 2 {
+		parts = parts[len(parts)-2:]
+	}
+	return strings.Join(parts, "/")
+}
+
+// Capture sends event in the background, filling in its ID, time, and the
+// client's release, environment, and server
+func (c *Client) Capture(event Event) {
+	if c == nil {
+		return
+	}
+	if event.EventID == "" {
+		event.EventID = newEventID()
+	}
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now().UTC()
+	}
+	if event.Level == "" {
+		event.Level = "error"
+	}
+	event.Platform = "go"
+	event.Release = c.release
+	event.Environment = c.environment
+	event.ServerName = c.serverName
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), httpClient.Timeout)
+		defer cancel()
+		if err := c.send(ctx, event); err != nil {
+			log.Warn().Err(err).Str("event_id", event.EventID).Msg("Failed to report error to Sentry")
+		}
+	}()
+}
+
+// Flush waits for events being sent, until ctx is done
+func (c *Client) Flush(ctx context.Context) {
+	if c == nil {
+		return
+	}
+	done := make(chan struct{})
+	go func() {
+		c.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+}
+
+// send posts event as an envelope: a header line, an item header line,
+// and the event
+func (c *Client) send(ctx context.Context, event Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+	header, _ := json.Marshal(map[string]any{
+		"event_id": event.EventID,
+		"dsn":      c.dsn,
+		"sent_at":  time.Now().UTC().Format(time.RFC3339Nano),
+	})
+	item, _ := json.Marshal(map[string]any{"type": "event", "length": len(payload)})
+
+	var body bytes.Buffer
+	for _, line := range [][]byte{header, item, payload} {
+		body.Write(line)
+		body.WriteByte('\n')
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-sentry-envelope")
+	req.Header.Set("X-Sentry-Auth", "Sentry sentry_version=7, sentry_client=apt-eval/1.0, sentry_key="+c.publicKey)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("sentry returned %s", resp.Status)
+	}
+	return nil
+}
+
+// newEventID returns a random event ID: 32 hex digits
+func newEventID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,99 @@
+package sentry
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	c, err := New("", "", "")
+	assert.NoError(t, err)
+	assert.Nil(t, c, "no DSN, no client")
+	c.Capture(Event{Message: "ignored"})
+
+	c, err = New("https://abc123@o1.ingest.sentry.io/42", "v1.2.0", "production")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://o1.ingest.sentry.io/api/42/envelope/", c.endpoint)
+	assert.Equal(t, "abc123", c.publicKey)
+
+	c, err = New("https://abc123@errors.example.com/glitchtip/7", "", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://errors.example.com/glitchtip/api/7/envelope/", c.endpoint)
+
+	for _, dsn := range []string{"https://errors.example.com/7", "https://abc@errors.example.com", "ftp://abc@errors.example.com/7"} {
+		_, err := New(dsn, "", "")
+		assert.Error(t, err, dsn)
+	}
+}
+
+func TestCapture(t *testing.T) {
+	var lines []string
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/42/envelope/", r.URL.Path)
+		auth = r.Header.Get("X-Sentry-Auth")
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	c, err := New(strings.Replace(srv.URL, "http://", "http://key@", 1)+"/42", "abc123", "staging")
+	assert.NoError(t, err)
+
+	r := httptest.NewRequest(http.MethodPost, "/api/apartments?sort=price", nil)
+	r.Header.Set("Authorization", "Bearer secret")
+	r.Header.Set("User-Agent", "test")
+	c.Capture(Event{
+		Message: "boom",
+		Request: NewRequest(r),
+		Tags:    map[string]string{"request_id": "req-1"},
+		Exception: &Exceptions{Values: []Exception{{
+			Type: "panic", Value: "boom", Stacktrace: Stack(0),
+		}}},
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c.Flush(ctx)
+
+	assert.Contains(t, auth, "sentry_key=key")
+	assert.Len(t, lines, 3, "envelope header, item header, event")
+	var event Event
+	assert.NoError(t, json.Unmarshal([]byte(lines[2]), &event))
+	assert.Len(t, event.EventID, 32)
+	assert.Equal(t, "abc123", event.Release)
+	assert.Equal(t, "staging", event.Environment)
+	assert.Equal(t, "error", event.Level)
+	assert.Equal(t, "req-1", event.Tags["request_id"])
+	assert.Equal(t, "sort=price", event.Request.QueryString)
+	assert.Equal(t, "test", event.Request.Headers["User-Agent"])
+	assert.NotContains(t, event.Request.Headers, "Authorization", "credentials aren't reported")
+
+	frames := event.Exception.Values[0].Stacktrace.Frames
+	last := frames[len(frames)-1]
+	assert.Equal(t, "TestCapture", last.Function, "innermost call last")
+	assert.Equal(t, "github.com/mojotx/apt-eval/sentry", last.Module)
+	assert.True(t, last.InApp)
+}
+
+func TestSendError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	c, err := New(strings.Replace(srv.URL, "http://", "http://key@", 1)+"/1", "", "")
+	assert.NoError(t, err)
+	err = c.send(context.Background(), Event{EventID: newEventID(), Exception: &Exceptions{Values: []Exception{{Type: "error", Value: "x"}}}})
+	assert.ErrorContains(t, err, "429")
+}
@@ -0,0 +1,328 @@
+package sso
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// keyRefreshInterval limits how often the provider's keys are fetched
+// again when a token is signed with one we don't know
+const keyRefreshInterval = time.Minute
+
+// OIDC is an OpenID Connect client using the authorization code flow
+type OIDC struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	AuthURL      string
+	TokenURL     string
+	JWKSURL      string
+
+	mu          sync.Mutex // Guards keys and keysFetched
+	keys        map[string]crypto.PublicKey
+	keysFetched time.Time
+}
+
+// DiscoverOIDC creates a client for the provider at issuer, reading its
+// endpoints from its discovery document
+func DiscoverOIDC(ctx context.Context, issuer, clientID, clientSecret string) (*OIDC, error) {
+	var doc struct {
+		Issuer                string `json:"issuer"`
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		JWKSURI               string `json:"jwks_uri"`
+	}
+	u := strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration"
+	if err := getJSON(ctx, u, &doc); err != nil {
+		return nil, fmt.Errorf("failed to discover OpenID provider: %w", err)
+	}
+	if doc.Issuer != issuer {
+		return nil, fmt.Errorf("OpenID provider says its issuer is %q, not %q", doc.Issuer, issuer)
+	}
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.JWKSURI == "" {
+		return nil, errors.New("OpenID provider discovery document is missing endpoints")
+	}
+	return &OIDC{
+		Issuer:       issuer,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		AuthURL:      doc.AuthorizationEndpoint,
+		TokenURL:     doc.TokenEndpoint,
+		JWKSURL:      doc.JWKSURI,
+	}, nil
+}
+
+// AuthCodeURL returns the provider's sign-in URL, which sends the user
+// back to redirectURL with a code and state. The ID token will carry
+// nonce.
+func (o *OIDC) AuthCodeURL(state, nonce, redirectURL string) string {
+	v := url.Values{
+		"client_id":     {o.ClientID},
+		"redirect_uri":  {redirectURL},
+		"response_type": {"code"},
+		"scope":         {"openid profile email"},
+		"state":         {state},
+		"nonce":         {nonce},
+	}
+	sep := "?"
+	if strings.Contains(o.AuthURL, "?") {
+		sep = "&"
+	}
+	return o.AuthURL + sep + v.Encode()
+}
+
+// Exchange trades the code from the provider for an ID token, checks it,
+// and returns who signed in. redirectURL must be the one the sign-in was
+// started with, and nonce the one given to AuthCodeURL.
+func (o *OIDC) Exchange(ctx context.Context, code, redirectURL, nonce string) (*Identity, error) {
+	form := url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {code},
+		"redirect_uri": {redirectURL},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(o.ClientID), url.QueryEscape(o.ClientSecret))
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to request token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err != nil {
+		return nil, fmt.Errorf("token endpoint returned %s", resp.Status)
+	}
+	if resp.StatusCode != http.StatusOK || body.IDToken == "" {
+		return nil, fmt.Errorf("token endpoint returned %s: %s %s", resp.Status, body.Error, body.ErrorDescription)
+	}
+	return o.VerifyIDToken(ctx, body.IDToken, nonce)
+}
+
+// audience is a token's aud claim, which may be a string or a list
+type audience []string
+
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var one string
+	if err := json.Unmarshal(b, &one); err == nil {
+		*a = audience{one}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(b, &many); err != nil {
+		return err
+	}
+	*a = many
+	return nil
+}
+
+// VerifyIDToken checks an ID token's signature, issuer, audience, expiry
+// and nonce, and returns who it's about
+func (o *OIDC) VerifyIDToken(ctx context.Context, token, nonce string) (*Identity, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed ID token")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("malformed ID token header: %w", err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed ID token signature: %w", err)
+	}
+	key, err := o.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if !verifyJWS(key, header.Alg, digest[:], sig) {
+		return nil, errors.New("ID token signature doesn't verify")
+	}
+
+	var claims struct {
+		Issuer            string   `json:"iss"`
+		Subject           string   `json:"sub"`
+		Audience          audience `json:"aud"`
+		AuthorizedParty   string   `json:"azp"`
+		Expires           int64    `json:"exp"`
+		NotBefore         int64    `json:"nbf"`
+		Nonce             string   `json:"nonce"`
+		Name              string   `json:"name"`
+		PreferredUsername string   `json:"preferred_username"`
+		Email             string   `json:"email"`
+	}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("malformed ID token claims: %w", err)
+	}
+
+	now := time.Now()
+	switch {
+	case claims.Issuer != o.Issuer:
+		return nil, errors.New("ID token isn't from the provider")
+	case !slices.Contains(claims.Audience, o.ClientID):
+		return nil, errors.New("ID token isn't for this app")
+	case len(claims.Audience) > 1 && claims.AuthorizedParty != o.ClientID:
+		return nil, errors.New("ID token wasn't issued to this app")
+	case now.Add(-clockSkew).Unix() >= claims.Expires:
+		return nil, errors.New("ID token has expired")
+	case claims.NotBefore != 0 && now.Add(clockSkew).Unix() < claims.NotBefore:
+		return nil, errors.New("ID token isn't valid yet")
+	case claims.Nonce != nonce:
+		return nil, errors.New("ID token nonce doesn't match")
+	case claims.Subject == "":
+		return nil, errors.New("ID token has no subject")
+	}
+
+	name := claims.Name
+	if name == "" {
+		name = claims.PreferredUsername
+	}
+	return &Identity{Subject: claims.Subject, Name: name, Email: claims.Email}, nil
+}
+
+// key returns the provider's signing key with ID kid, fetching the
+// provider's keys again if it's new
+func (o *OIDC) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if key, ok := o.keys[kid]; ok {
+		return key, nil
+	}
+	if time.Since(o.keysFetched) < keyRefreshInterval {
+		return nil, fmt.Errorf("unknown ID token signing key %q", kid)
+	}
+
+	keys, err := fetchJWKS(ctx, o.JWKSURL)
+	if err != nil {
+		return nil, err
+	}
+	o.keys, o.keysFetched = keys, time.Now()
+	key, ok := keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown ID token signing key %q", kid)
+	}
+	return key, nil
+}
+
+// fetchJWKS reads the signing keys in a JSON Web Key Set
+func fetchJWKS(ctx context.Context, u string) (map[string]crypto.PublicKey, error) {
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := getJSON(ctx, u, &set); err != nil {
+		return nil, fmt.Errorf("failed to fetch OpenID provider keys: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey)
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		switch k.Kty {
+		case "RSA":
+			n, errN := base64.RawURLEncoding.DecodeString(k.N)
+			e, errE := base64.RawURLEncoding.DecodeString(k.E)
+			if errN != nil || errE != nil || len(e) > 4 {
+				continue
+			}
+			keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		case "EC":
+			if k.Crv != "P-256" {
+				continue
+			}
+			x, errX := base64.RawURLEncoding.DecodeString(k.X)
+			y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+			if errX != nil || errY != nil {
+				continue
+			}
+			key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+			if !key.Curve.IsOnCurve(key.X, key.Y) {
+				continue
+			}
+			keys[k.Kid] = key
+		}
+	}
+	return keys, nil
+}
+
+// verifyJWS reports whether sig is a JWS signature with alg of a SHA-256
+// digest by key. Only RS256 and ES256 are accepted.
+func verifyJWS(key crypto.PublicKey, alg string, digest, sig []byte) bool {
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		return alg == "RS256" && rsa.VerifyPKCS1v15(key, crypto.SHA256, digest, sig) == nil
+	case *ecdsa.PublicKey:
+		if alg != "ES256" || len(sig) != 64 {
+			return false
+		}
+		r := new(big.Int).SetBytes(sig[:32])
+		s := new(big.Int).SetBytes(sig[32:])
+		return ecdsa.Verify(key, digest, r, s)
+	}
+	return false
+}
+
+// decodeSegment decodes a base64url JSON segment of a token
+func decodeSegment(s string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// getJSON fetches and decodes a JSON document
+func getJSON(ctx context.Context, u string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", u, resp.Status)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
@@ -0,0 +1,431 @@
+package sso
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SAML namespaces and bindings
+const (
+	samlProtocol   = "urn:oasis:names:tc:SAML:2.0:protocol"
+	samlAssertion  = "urn:oasis:names:tc:SAML:2.0:assertion"
+	bindingPOST    = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST"
+	bindingRedir   = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect"
+	statusSuccess  = "urn:oasis:names:tc:SAML:2.0:status:Success"
+	bearer         = "urn:oasis:names:tc:SAML:2.0:cm:bearer"
+	nameIDFormat   = "urn:oasis:names:tc:SAML:1.1:nameid-format:unspecified"
+	maxResponseLen = 1 << 20
+)
+
+// clockSkew is how far the identity provider's clock may be from ours
+const clockSkew = 3 * time.Minute
+
+// Attributes that commonly carry a user's display name and email, in the
+// order they're tried
+var (
+	nameAttributes = []string{
+		"displayName",
+		"urn:oid:2.16.840.1.113730.3.1.241",
+		"http://schemas.microsoft.com/identity/claims/displayname",
+		"name",
+		"http://schemas.xmlsoap.org/ws/2005/05/identity/claims/name",
+		"cn",
+		"urn:oid:2.5.4.3",
+	}
+	emailAttributes = []string{
+		"email",
+		"mail",
+		"urn:oid:0.9.2342.19200300.100.1.3",
+		"http://schemas.xmlsoap.org/ws/2005/05/identity/claims/emailaddress",
+	}
+)
+
+// SAML is a SAML 2.0 service provider. Users are sent to the identity
+// provider with the HTTP-Redirect binding, and come back to ACSURL with a
+// signed assertion through HTTP-POST. Encrypted assertions aren't
+// supported.
+type SAML struct {
+	EntityID    string // The app's entity ID, by convention its metadata URL
+	ACSURL      string
+	IdPEntityID string
+	IdPSSOURL   string
+	IdPCerts    []*x509.Certificate
+
+	mu   sync.Mutex // Guards seen
+	seen map[string]time.Time
+}
+
+// IdPMetadata is what the app needs to know about an identity provider
+type IdPMetadata struct {
+	EntityID string
+	SSOURL   string
+	Certs    []*x509.Certificate
+}
+
+// LoadIdPMetadata reads identity provider metadata from a URL or file
+func LoadIdPMetadata(ctx context.Context, location string) (*IdPMetadata, error) {
+	var data []byte
+	if strings.HasPrefix(location, "https://") || strings.HasPrefix(location, "http://") {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch IdP metadata: %w", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("IdP metadata returned %s", resp.Status)
+		}
+		if data, err = io.ReadAll(io.LimitReader(resp.Body, maxResponseLen)); err != nil {
+			return nil, fmt.Errorf("failed to read IdP metadata: %w", err)
+		}
+	} else {
+		var err error
+		if data, err = os.ReadFile(location); err != nil {
+			return nil, fmt.Errorf("failed to read IdP metadata: %w", err)
+		}
+	}
+	return ParseIdPMetadata(data)
+}
+
+// ParseIdPMetadata reads an identity provider's EntityDescriptor
+func ParseIdPMetadata(data []byte) (*IdPMetadata, error) {
+	var doc struct {
+		XMLName  xml.Name `xml:"urn:oasis:names:tc:SAML:2.0:metadata EntityDescriptor"`
+		EntityID string   `xml:"entityID,attr"`
+		IdP      struct {
+			Keys []struct {
+				Use   string   `xml:"use,attr"`
+				Certs []string `xml:"http://www.w3.org/2000/09/xmldsig# KeyInfo>X509Data>X509Certificate"`
+			} `xml:"urn:oasis:names:tc:SAML:2.0:metadata KeyDescriptor"`
+			SSO []struct {
+				Binding  string `xml:"Binding,attr"`
+				Location string `xml:"Location,attr"`
+			} `xml:"urn:oasis:names:tc:SAML:2.0:metadata SingleSignOnService"`
+		} `xml:"urn:oasis:names:tc:SAML:2.0:metadata IDPSSODescriptor"`
+	}
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid IdP metadata: %w", err)
+	}
+
+	md := &IdPMetadata{EntityID: doc.EntityID}
+	for _, sso := range doc.IdP.SSO {
+		if sso.Binding == bindingRedir {
+			md.SSOURL = sso.Location
+		}
+	}
+	for _, key := range doc.IdP.Keys {
+		if key.Use != "" && key.Use != "signing" {
+			continue
+		}
+		for _, s := range key.Certs {
+			der, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(s), ""))
+			if err != nil {
+				return nil, fmt.Errorf("invalid certificate in IdP metadata: %w", err)
+			}
+			cert, err := x509.ParseCertificate(der)
+			if err != nil {
+				return nil, fmt.Errorf("invalid certificate in IdP metadata: %w", err)
+			}
+			md.Certs = append(md.Certs, cert)
+		}
+	}
+
+	switch {
+	case md.EntityID == "":
+		return nil, errors.New("IdP metadata has no entity ID")
+	case md.SSOURL == "":
+		return nil, errors.New("IdP metadata has no HTTP-Redirect single sign-on service")
+	case len(md.Certs) == 0:
+		return nil, errors.New("IdP metadata has no signing certificate")
+	}
+	return md, nil
+}
+
+// NewSAML creates a service provider for the identity provider described
+// by md
+func NewSAML(entityID, acsURL string, md *IdPMetadata) *SAML {
+	return &SAML{
+		EntityID:    entityID,
+		ACSURL:      acsURL,
+		IdPEntityID: md.EntityID,
+		IdPSSOURL:   md.SSOURL,
+		IdPCerts:    md.Certs,
+		seen:        map[string]time.Time{},
+	}
+}
+
+// Metadata returns the app's metadata, for registering it with the
+// identity provider
+func (s *SAML) Metadata() []byte {
+	type acs struct {
+		Binding  string `xml:"Binding,attr"`
+		Location string `xml:"Location,attr"`
+		Index    int    `xml:"index,attr"`
+	}
+	doc := struct {
+		XMLName  xml.Name `xml:"urn:oasis:names:tc:SAML:2.0:metadata EntityDescriptor"`
+		EntityID string   `xml:"entityID,attr"`
+		SP       struct {
+			AuthnRequestsSigned  bool   `xml:"AuthnRequestsSigned,attr"`
+			WantAssertionsSigned bool   `xml:"WantAssertionsSigned,attr"`
+			Protocols            string `xml:"protocolSupportEnumeration,attr"`
+			NameIDFormat         string `xml:"NameIDFormat"`
+			ACS                  acs    `xml:"AssertionConsumerService"`
+		} `xml:"SPSSODescriptor"`
+	}{EntityID: s.EntityID}
+	doc.SP.WantAssertionsSigned = true
+	doc.SP.Protocols = samlProtocol
+	doc.SP.NameIDFormat = nameIDFormat
+	doc.SP.ACS = acs{Binding: bindingPOST, Location: s.ACSURL, Index: 1}
+
+	out, _ := xml.MarshalIndent(doc, "", "  ")
+	return append([]byte(xml.Header), out...)
+}
+
+// AuthnRequestURL returns where to send the user to sign in, and the ID of
+// the request, which the response must be in reply to
+func (s *SAML) AuthnRequestURL(relayState string) (string, string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	id := "_" + hex.EncodeToString(b)
+
+	var req bytes.Buffer
+	fmt.Fprintf(&req, `<samlp:AuthnRequest xmlns:samlp="%s" xmlns:saml="%s" ID="%s" Version="2.0" IssueInstant="%s"`,
+		samlProtocol, samlAssertion, id, time.Now().UTC().Format(time.RFC3339))
+	req.WriteString(` Destination="`)
+	xml.EscapeText(&req, []byte(s.IdPSSOURL))
+	req.WriteString(`" AssertionConsumerServiceURL="`)
+	xml.EscapeText(&req, []byte(s.ACSURL))
+	fmt.Fprintf(&req, `" ProtocolBinding="%s"><saml:Issuer>`, bindingPOST)
+	xml.EscapeText(&req, []byte(s.EntityID))
+	fmt.Fprintf(&req, `</saml:Issuer><samlp:NameIDPolicy Format="%s" AllowCreate="true"/></samlp:AuthnRequest>`, nameIDFormat)
+
+	var deflated bytes.Buffer
+	w, _ := flate.NewWriter(&deflated, flate.BestCompression)
+	w.Write(req.Bytes())
+	w.Close()
+
+	v := url.Values{"SAMLRequest": {base64.StdEncoding.EncodeToString(deflated.Bytes())}}
+	if relayState != "" {
+		v.Set("RelayState", relayState)
+	}
+	sep := "?"
+	if strings.Contains(s.IdPSSOURL, "?") {
+		sep = "&"
+	}
+	return s.IdPSSOURL + sep + v.Encode(), id, nil
+}
+
+// ParseResponse checks the base64 SAMLResponse posted to the ACS URL, in
+// reply to the request with requestID, and returns who signed in. Either
+// the response or its assertion must be signed by the identity provider,
+// and each assertion is only accepted once.
+func (s *SAML) ParseResponse(encoded, requestID string) (*Identity, error) {
+	data, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(encoded), ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid SAML response: %w", err)
+	}
+	if len(data) > maxResponseLen {
+		return nil, errors.New("SAML response is too large")
+	}
+	resp, err := parseXML(data)
+	if err != nil {
+		return nil, err
+	}
+	if !resp.is(samlProtocol, "Response") {
+		return nil, errors.New("not a SAML response")
+	}
+
+	if status := resp.element(samlProtocol, "Status"); status != nil {
+		code := status.element(samlProtocol, "StatusCode")
+		if code == nil || code.attr("Value") != statusSuccess {
+			msg := ""
+			if m := status.element(samlProtocol, "StatusMessage"); m != nil {
+				msg = m.text()
+			}
+			return nil, fmt.Errorf("identity provider refused sign-in: %s", msg)
+		}
+	} else {
+		return nil, errors.New("SAML response has no status")
+	}
+	if dest := resp.attr("Destination"); dest != "" && dest != s.ACSURL {
+		return nil, fmt.Errorf("SAML response is for %s", dest)
+	}
+	if irt := resp.attr("InResponseTo"); irt != "" && irt != requestID {
+		return nil, errors.New("SAML response isn't in reply to our request")
+	}
+
+	if len(resp.elements(samlAssertion, "EncryptedAssertion")) > 0 {
+		return nil, errors.New("encrypted assertions aren't supported")
+	}
+	assertion := resp.element(samlAssertion, "Assertion")
+	if assertion == nil {
+		return nil, errors.New("SAML response must hold exactly one assertion")
+	}
+
+	// A signed response covers its assertion; otherwise the assertion must
+	// be signed itself
+	err = verifySignature(resp, s.IdPCerts)
+	if errors.Is(err, errNotSigned) {
+		err = verifySignature(assertion, s.IdPCerts)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid SAML signature: %w", err)
+	}
+
+	return s.checkAssertion(assertion, requestID, time.Now())
+}
+
+// checkAssertion checks a verified assertion's issuer, audience, subject
+// confirmation and validity, and reads who it's about
+func (s *SAML) checkAssertion(a *element, requestID string, now time.Time) (*Identity, error) {
+	if issuer := a.element(samlAssertion, "Issuer"); issuer == nil || issuer.text() != s.IdPEntityID {
+		return nil, errors.New("assertion isn't from the identity provider")
+	}
+
+	conditions := a.element(samlAssertion, "Conditions")
+	if conditions == nil {
+		return nil, errors.New("assertion has no conditions")
+	}
+	if err := checkWindow(conditions, now); err != nil {
+		return nil, err
+	}
+	audienceOK := false
+	for _, r := range conditions.elements(samlAssertion, "AudienceRestriction") {
+		for _, aud := range r.elements(samlAssertion, "Audience") {
+			audienceOK = audienceOK || aud.text() == s.EntityID
+		}
+	}
+	if !audienceOK {
+		return nil, errors.New("assertion isn't for this app")
+	}
+
+	subject := a.element(samlAssertion, "Subject")
+	if subject == nil {
+		return nil, errors.New("assertion has no subject")
+	}
+	nameID := subject.element(samlAssertion, "NameID")
+	if nameID == nil || nameID.text() == "" {
+		return nil, errors.New("assertion has no NameID")
+	}
+	var expires time.Time
+	confirmed := false
+	for _, sc := range subject.elements(samlAssertion, "SubjectConfirmation") {
+		data := sc.element(samlAssertion, "SubjectConfirmationData")
+		if sc.attr("Method") != bearer || data == nil {
+			continue
+		}
+		if data.attr("Recipient") != s.ACSURL || data.attr("InResponseTo") != requestID {
+			continue
+		}
+		if err := checkWindow(data, now); err != nil {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339Nano, data.attr("NotOnOrAfter"))
+		if err != nil {
+			continue
+		}
+		confirmed, expires = true, t
+	}
+	if !confirmed {
+		return nil, errors.New("assertion has no valid bearer confirmation for this request")
+	}
+	if err := s.once(a.attr("ID"), expires.Add(clockSkew)); err != nil {
+		return nil, err
+	}
+
+	id := &Identity{Subject: nameID.text()}
+	attrs := map[string]string{}
+	for _, stmt := range a.elements(samlAssertion, "AttributeStatement") {
+		for _, at := range stmt.elements(samlAssertion, "Attribute") {
+			values := at.elements(samlAssertion, "AttributeValue")
+			if len(values) == 0 {
+				continue
+			}
+			attrs[at.attr("Name")] = values[0].text()
+			if friendly := at.attr("FriendlyName"); friendly != "" {
+				attrs[friendly] = values[0].text()
+			}
+		}
+	}
+	id.Name = firstAttribute(attrs, nameAttributes)
+	id.Email = firstAttribute(attrs, emailAttributes)
+	if id.Email == "" && strings.Contains(id.Subject, "@") {
+		id.Email = id.Subject
+	}
+	return id, nil
+}
+
+// checkWindow checks an element's NotBefore and NotOnOrAfter against now,
+// allowing for clock skew
+func checkWindow(el *element, now time.Time) error {
+	if s := el.attr("NotBefore"); s != "" {
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return fmt.Errorf("invalid NotBefore: %w", err)
+		}
+		if now.Add(clockSkew).Before(t) {
+			return errors.New("assertion isn't valid yet")
+		}
+	}
+	if s := el.attr("NotOnOrAfter"); s != "" {
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return fmt.Errorf("invalid NotOnOrAfter: %w", err)
+		}
+		if !now.Add(-clockSkew).Before(t) {
+			return errors.New("assertion has expired")
+		}
+	}
+	return nil
+}
+
+// once refuses an assertion ID seen before, remembering it until expires
+func (s *SAML) once(id string, expires time.Time) error {
+	if id == "" {
+		return errors.New("assertion has no ID")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for seen, until := range s.seen {
+		if now.After(until) {
+			delete(s.seen, seen)
+		}
+	}
+	if _, ok := s.seen[id]; ok {
+		return errors.New("assertion was already used")
+	}
+	s.seen[id] = expires
+	return nil
+}
+
+// firstAttribute returns the value of the first of names present
+func firstAttribute(attrs map[string]string, names []string) string {
+	for _, name := range names {
+		if v := attrs[name]; v != "" {
+			return v
+		}
+	}
+	return ""
+}
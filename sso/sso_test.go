@@ -0,0 +1,342 @@
+package sso
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSigner(t *testing.T) {
+	s := NewSigner("secret")
+	token := s.Sign("session", "42", time.Now().Add(time.Hour))
+
+	value, err := s.Open("session", token)
+	require.NoError(t, err)
+	assert.Equal(t, "42", value)
+
+	_, err = s.Open("oidc", token)
+	assert.ErrorIs(t, err, ErrInvalidToken, "bound to its purpose")
+	_, err = NewSigner("other").Open("session", token)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	_, err = s.Open("session", "NDM"+token[2:])
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	_, err = s.Open("session", s.Sign("session", "42", time.Now().Add(-time.Second)))
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestCanonicalize(t *testing.T) {
+	// Checked against xmllint --exc-c14n
+	doc := `<?xml version="1.0"?>
+<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" xmlns:unused="urn:x" ID="_r1">
+  <saml:Assertion xmlns:xs="http://www.w3.org/2001/XMLSchema" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" ID="_a1" Version="2.0">
+    <!-- dropped -->
+    <saml:Attribute Name="email"><saml:AttributeValue xsi:type="xs:string">a&lt;b&gt;&amp;"c" &#13;</saml:AttributeValue></saml:Attribute>
+    <foo xmlns="urn:default" b="2" a="1" xml:lang="en"><bar xmlns=""/><baz/></foo>
+  </saml:Assertion>
+</samlp:Response>`
+	want := `<saml:Assertion xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_a1" Version="2.0">` + "\n    \n    " +
+		`<saml:Attribute Name="email"><saml:AttributeValue xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:type="xs:string">a&lt;b&gt;&amp;"c" &#xD;</saml:AttributeValue></saml:Attribute>` + "\n    " +
+		`<foo xmlns="urn:default" a="1" b="2" xml:lang="en"><bar xmlns=""></bar><baz></baz></foo>` + "\n  " +
+		`</saml:Assertion>`
+
+	root, err := parseXML([]byte(doc))
+	require.NoError(t, err)
+	assertion := root.element(samlAssertion, "Assertion")
+	require.NotNil(t, assertion)
+	assert.Equal(t, want, string(canonicalize(assertion, nil, nil)))
+
+	// Inclusive prefixes are declared even when not visibly used
+	assert.Contains(t, string(canonicalize(assertion, nil, []string{"xs"})),
+		`<saml:Assertion xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" xmlns:xs="http://www.w3.org/2001/XMLSchema" ID="_a1"`)
+
+	_, err = parseXML([]byte(`<!DOCTYPE x [<!ENTITY e "boom">]><x>&e;</x>`))
+	assert.Error(t, err)
+}
+
+// testKey returns an RSA key and a self-signed certificate for it
+func testKey(t *testing.T) (*rsa.PrivateKey, *x509.Certificate) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "idp"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return key, cert
+}
+
+// signJWT returns an RS256 token with claims
+func signJWT(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]any) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": kid})
+	body, _ := json.Marshal(claims)
+	payload := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(body)
+	digest := sha256.Sum256([]byte(payload))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	require.NoError(t, err)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestOIDC(t *testing.T) {
+	key, _ := testKey(t)
+	var server *httptest.Server
+	var idToken string
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			json.NewEncoder(w).Encode(map[string]string{
+				"issuer":                 server.URL,
+				"authorization_endpoint": server.URL + "/authorize",
+				"token_endpoint":         server.URL + "/token",
+				"jwks_uri":               server.URL + "/jwks",
+			})
+		case "/jwks":
+			json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+				"kty": "RSA", "kid": "k1", "use": "sig",
+				"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}}})
+		case "/token":
+			id, secret, _ := r.BasicAuth()
+			require.NoError(t, r.ParseForm())
+			if id != "client" || secret != "secret" || r.PostForm.Get("code") != "code1" {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"error": "invalid_grant"}`))
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]string{"access_token": "a", "id_token": idToken})
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	o, err := DiscoverOIDC(ctx, server.URL, "client", "secret")
+	require.NoError(t, err)
+	assert.Contains(t, o.AuthCodeURL("s1", "n1", "https://app/cb"), server.URL+"/authorize?")
+
+	claims := func(change map[string]any) map[string]any {
+		c := map[string]any{
+			"iss": server.URL, "aud": "client", "sub": "u1", "nonce": "n1",
+			"exp": time.Now().Add(time.Hour).Unix(), "name": "Ann Lee", "email": "ann@example.com",
+		}
+		for k, v := range change {
+			c[k] = v
+		}
+		return c
+	}
+
+	idToken = signJWT(t, key, "k1", claims(nil))
+	id, err := o.Exchange(ctx, "code1", "https://app/cb", "n1")
+	require.NoError(t, err)
+	assert.Equal(t, &Identity{Subject: "u1", Name: "Ann Lee", Email: "ann@example.com"}, id)
+
+	_, err = o.Exchange(ctx, "wrong", "https://app/cb", "n1")
+	assert.ErrorContains(t, err, "invalid_grant")
+
+	for name, token := range map[string]string{
+		"wrong nonce":    signJWT(t, key, "k1", claims(map[string]any{"nonce": "n2"})),
+		"wrong audience": signJWT(t, key, "k1", claims(map[string]any{"aud": []string{"other"}})),
+		"wrong issuer":   signJWT(t, key, "k1", claims(map[string]any{"iss": "https://evil"})),
+		"expired":        signJWT(t, key, "k1", claims(map[string]any{"exp": time.Now().Add(-time.Hour).Unix()})),
+		"unknown key":    signJWT(t, key, "k2", claims(nil)),
+		"bad signature": strings.Join(strings.Split(signJWT(t, key, "k1", claims(nil)), ".")[:2], ".") + "." +
+			strings.Split(signJWT(t, key, "k1", claims(map[string]any{"sub": "u2"})), ".")[2],
+		"alg none": base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","kid":"k1"}`)) + "." +
+			strings.Split(signJWT(t, key, "k1", claims(nil)), ".")[1] + ".",
+	} {
+		_, err := o.VerifyIDToken(ctx, token, "n1")
+		assert.Error(t, err, name)
+	}
+}
+
+// samlFixture builds SAML responses signed with a test key
+type samlFixture struct {
+	key  *rsa.PrivateKey
+	sp   *SAML
+	now  time.Time
+	seq  int
+	cert *x509.Certificate
+}
+
+func newSAMLFixture(t *testing.T) *samlFixture {
+	key, cert := testKey(t)
+	sp := NewSAML("https://app/auth/saml/metadata", "https://app/auth/saml/acs",
+		&IdPMetadata{EntityID: "https://idp", SSOURL: "https://idp/sso", Certs: []*x509.Certificate{cert}})
+	return &samlFixture{key: key, sp: sp, now: time.Now().UTC(), cert: cert}
+}
+
+// assertion returns an unsigned assertion for nameID in reply to requestID
+func (f *samlFixture) assertion(nameID, requestID string) string {
+	f.seq++
+	ts := func(d time.Duration) string { return f.now.Add(d).Format(time.RFC3339) }
+	return fmt.Sprintf(`<saml:Assertion xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_a%d" Version="2.0" IssueInstant="%s">`+
+		`<saml:Issuer>https://idp</saml:Issuer>`+
+		`<saml:Subject><saml:NameID>%s</saml:NameID>`+
+		`<saml:SubjectConfirmation Method="urn:oasis:names:tc:SAML:2.0:cm:bearer">`+
+		`<saml:SubjectConfirmationData InResponseTo="%s" NotOnOrAfter="%s" Recipient="https://app/auth/saml/acs"/>`+
+		`</saml:SubjectConfirmation></saml:Subject>`+
+		`<saml:Conditions NotBefore="%s" NotOnOrAfter="%s"><saml:AudienceRestriction>`+
+		`<saml:Audience>https://app/auth/saml/metadata</saml:Audience></saml:AudienceRestriction></saml:Conditions>`+
+		`<saml:AttributeStatement><saml:Attribute Name="displayName"><saml:AttributeValue>Ann Lee</saml:AttributeValue></saml:Attribute>`+
+		`</saml:AttributeStatement></saml:Assertion>`,
+		f.seq, ts(0), nameID, requestID, ts(5*time.Minute), ts(-time.Minute), ts(5*time.Minute))
+}
+
+// sign returns the element in doc with ID id signed with key
+func (f *samlFixture) sign(t *testing.T, doc, id string, key *rsa.PrivateKey) string {
+	root, err := parseXML([]byte(doc))
+	require.NoError(t, err)
+	el := findID(root, id)
+	require.NotNil(t, el)
+	digest := sha256.Sum256(canonicalize(el, nil, nil))
+
+	signedInfo := `<ds:SignedInfo>` +
+		`<ds:CanonicalizationMethod Algorithm="http://www.w3.org/2001/10/xml-exc-c14n#"/>` +
+		`<ds:SignatureMethod Algorithm="http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"/>` +
+		`<ds:Reference URI="#` + id + `"><ds:Transforms>` +
+		`<ds:Transform Algorithm="http://www.w3.org/2000/09/xmldsig#enveloped-signature"/>` +
+		`<ds:Transform Algorithm="http://www.w3.org/2001/10/xml-exc-c14n#"/></ds:Transforms>` +
+		`<ds:DigestMethod Algorithm="http://www.w3.org/2001/04/xmlenc#sha256"/>` +
+		`<ds:DigestValue>` + base64.StdEncoding.EncodeToString(digest[:]) + `</ds:DigestValue></ds:Reference></ds:SignedInfo>`
+	sigInfo, err := parseXML([]byte(`<ds:Signature xmlns:ds="http://www.w3.org/2000/09/xmldsig#">` + signedInfo + `</ds:Signature>`))
+	require.NoError(t, err)
+	hashed := sha256.Sum256(canonicalize(sigInfo.element(dsigNamespace, "SignedInfo"), nil, nil))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hashed[:])
+	require.NoError(t, err)
+
+	signature := `<ds:Signature xmlns:ds="http://www.w3.org/2000/09/xmldsig#">` + signedInfo +
+		`<ds:SignatureValue>` + base64.StdEncoding.EncodeToString(sig) + `</ds:SignatureValue></ds:Signature>`
+	// The signature goes after the issuer, as the schema has it
+	marker := `ID="` + id + `"`
+	i := strings.Index(doc, marker)
+	j := i + strings.Index(doc[i:], "</saml:Issuer>") + len("</saml:Issuer>")
+	return doc[:j] + signature + doc[j:]
+}
+
+// findID returns the element with the given ID
+func findID(el *element, id string) *element {
+	if el.attr("ID") == id {
+		return el
+	}
+	for _, n := range el.Nodes {
+		if child, ok := n.(*element); ok {
+			if found := findID(child, id); found != nil {
+				return found
+			}
+		}
+	}
+	return nil
+}
+
+// response wraps assertions in a successful response to requestID
+func (f *samlFixture) response(requestID string, assertions ...string) string {
+	return `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion"` +
+		` ID="_r` + fmt.Sprint(f.seq) + `" Version="2.0" Destination="https://app/auth/saml/acs" InResponseTo="` + requestID + `">` +
+		`<saml:Issuer>https://idp</saml:Issuer>` +
+		`<samlp:Status><samlp:StatusCode Value="urn:oasis:names:tc:SAML:2.0:status:Success"/></samlp:Status>` +
+		strings.Join(assertions, "") + `</samlp:Response>`
+}
+
+func encode(s string) string {
+	return base64.StdEncoding.EncodeToString([]byte(s))
+}
+
+func TestSAMLResponse(t *testing.T) {
+	f := newSAMLFixture(t)
+
+	// A signed assertion
+	a := f.assertion("ann@example.com", "_req1")
+	doc := f.sign(t, f.response("_req1", a), "_a1", f.key)
+	id, err := f.sp.ParseResponse(encode(doc), "_req1")
+	require.NoError(t, err)
+	assert.Equal(t, &Identity{Subject: "ann@example.com", Name: "Ann Lee", Email: "ann@example.com"}, id)
+
+	// Assertions are only accepted once
+	_, err = f.sp.ParseResponse(encode(doc), "_req1")
+	assert.ErrorContains(t, err, "already used")
+
+	// A signed response covers its assertion
+	doc = f.response("_req2", f.assertion("bob", "_req2"))
+	doc = f.sign(t, doc, "_r2", f.key)
+	id, err = f.sp.ParseResponse(encode(doc), "_req2")
+	require.NoError(t, err)
+	assert.Equal(t, "bob", id.Subject)
+
+	otherKey, _ := testKey(t)
+	for name, tc := range map[string]struct {
+		doc, requestID string
+	}{
+		"unsigned": {f.response("_req3", f.assertion("ann", "_req3")), "_req3"},
+		"changed after signing": func() struct{ doc, requestID string } {
+			doc := f.sign(t, f.response("_req4", f.assertion("ann", "_req4")), "_a4", f.key)
+			return struct{ doc, requestID string }{strings.Replace(doc, ">ann<", ">admin<", 1), "_req4"}
+		}(),
+		"untrusted key": {f.sign(t, f.response("_req5", f.assertion("ann", "_req5")), "_a5", otherKey), "_req5"},
+		"other request": {f.sign(t, f.response("_req6", f.assertion("ann", "_req6")), "_a6", f.key), "_req7"},
+		"wrapped": func() struct{ doc, requestID string } {
+			// The signed assertion hidden away, and an unsigned one in its place
+			signed := f.sign(t, f.response("_req8", f.assertion("ann", "_req8")), "_a7", f.key)
+			start := strings.Index(signed, "<saml:Assertion")
+			hidden := `<samlp:Extensions>` + signed[start:strings.Index(signed, "</samlp:Response>")] + `</samlp:Extensions>`
+			evil := strings.Replace(f.assertion("admin", "_req8"), `ID="_a8"`, `ID="_a7"`, 1)
+			return struct{ doc, requestID string }{signed[:start] + hidden + evil + "</samlp:Response>", "_req8"}
+		}(),
+	} {
+		_, err := f.sp.ParseResponse(encode(tc.doc), tc.requestID)
+		assert.Error(t, err, name)
+	}
+
+	// Expired assertions are refused
+	f.now = time.Now().Add(-time.Hour)
+	f.seq = 100
+	doc = f.sign(t, f.response("_req9", f.assertion("ann", "_req9")), "_a101", f.key)
+	_, err = f.sp.ParseResponse(encode(doc), "_req9")
+	assert.ErrorContains(t, err, "expired")
+}
+
+func TestSAMLMetadata(t *testing.T) {
+	f := newSAMLFixture(t)
+	md := string(f.sp.Metadata())
+	assert.Contains(t, md, `entityID="https://app/auth/saml/metadata"`)
+	assert.Contains(t, md, `Location="https://app/auth/saml/acs"`)
+
+	idp := `<md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" xmlns:ds="http://www.w3.org/2000/09/xmldsig#" entityID="https://idp">` +
+		`<md:IDPSSODescriptor protocolSupportEnumeration="urn:oasis:names:tc:SAML:2.0:protocol">` +
+		`<md:KeyDescriptor use="signing"><ds:KeyInfo><ds:X509Data><ds:X509Certificate>` +
+		base64.StdEncoding.EncodeToString(f.cert.Raw) +
+		`</ds:X509Certificate></ds:X509Data></ds:KeyInfo></md:KeyDescriptor>` +
+		`<md:SingleSignOnService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST" Location="https://idp/post"/>` +
+		`<md:SingleSignOnService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect" Location="https://idp/sso"/>` +
+		`</md:IDPSSODescriptor></md:EntityDescriptor>`
+	parsed, err := ParseIdPMetadata([]byte(idp))
+	require.NoError(t, err)
+	assert.Equal(t, "https://idp", parsed.EntityID)
+	assert.Equal(t, "https://idp/sso", parsed.SSOURL)
+	require.Len(t, parsed.Certs, 1)
+	assert.True(t, parsed.Certs[0].Equal(f.cert))
+
+	u, requestID, err := f.sp.AuthnRequestURL("")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(u, "https://idp/sso?SAMLRequest="))
+	assert.True(t, strings.HasPrefix(requestID, "_"))
+}
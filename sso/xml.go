@@ -0,0 +1,335 @@
+package sso
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// xmlNamespace is the namespace bound to the xml prefix
+const xmlNamespace = "http://www.w3.org/XML/1998/namespace"
+
+// node is a child of an element: an element, text or processing
+// instruction. Comments are dropped while parsing, as canonicalization
+// without comments would drop them anyway.
+type node interface{}
+
+// element is an XML element, keeping the prefixes and namespace
+// declarations as written, which signature checking needs
+type element struct {
+	Prefix string
+	Local  string
+	Space  string // Namespace URI the prefix resolves to
+	Attrs  []attr
+	Decls  []attr            // Namespace declarations made on this element
+	Scope  map[string]string // Every prefix in scope, "" for the default
+	Nodes  []node
+	Parent *element
+}
+
+// attr is an attribute or namespace declaration. For declarations, Local
+// is the declared prefix ("" for the default) and Value its namespace.
+type attr struct {
+	Prefix string
+	Local  string
+	Space  string
+	Value  string
+}
+
+// procInst is a processing instruction
+type procInst struct {
+	Target string
+	Inst   string
+}
+
+// parseXML parses a document into its root element. Document type
+// declarations are refused, so there are no entities to expand.
+func parseXML(data []byte) (*element, error) {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	var root, current *element
+	for {
+		tok, err := d.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid XML: %w", err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if root != nil && current == nil {
+				return nil, errors.New("invalid XML: more than one root element")
+			}
+			el, err := newElement(t, current)
+			if err != nil {
+				return nil, err
+			}
+			if current == nil {
+				root = el
+			} else {
+				current.Nodes = append(current.Nodes, el)
+			}
+			current = el
+		case xml.EndElement:
+			if current == nil || t.Name.Space != current.Prefix || t.Name.Local != current.Local {
+				return nil, fmt.Errorf("invalid XML: unexpected end element %s", t.Name.Local)
+			}
+			current = current.Parent
+		case xml.CharData:
+			if current != nil {
+				current.Nodes = append(current.Nodes, string(t))
+			} else if len(bytes.TrimSpace(t)) > 0 {
+				return nil, errors.New("invalid XML: text outside the root element")
+			}
+		case xml.ProcInst:
+			if current != nil {
+				current.Nodes = append(current.Nodes, procInst{Target: t.Target, Inst: string(t.Inst)})
+			}
+		case xml.Directive:
+			return nil, errors.New("invalid XML: document type declarations aren't allowed")
+		}
+	}
+	if root == nil || current != nil {
+		return nil, errors.New("invalid XML: incomplete document")
+	}
+	return root, nil
+}
+
+// newElement builds an element from a start tag, resolving its prefixes
+// against those in scope from parent
+func newElement(t xml.StartElement, parent *element) (*element, error) {
+	el := &element{Prefix: t.Name.Space, Local: t.Name.Local, Parent: parent, Scope: map[string]string{}}
+	if parent != nil {
+		for p, ns := range parent.Scope {
+			el.Scope[p] = ns
+		}
+	}
+
+	for _, a := range t.Attr {
+		switch {
+		case a.Name.Space == "xmlns":
+			el.Decls = append(el.Decls, attr{Local: a.Name.Local, Value: a.Value})
+			el.Scope[a.Name.Local] = a.Value
+		case a.Name.Space == "" && a.Name.Local == "xmlns":
+			el.Decls = append(el.Decls, attr{Value: a.Value})
+			el.Scope[""] = a.Value
+		}
+	}
+
+	el.Space = el.Scope[el.Prefix]
+	if el.Prefix != "" && el.Space == "" {
+		return nil, fmt.Errorf("invalid XML: undeclared prefix %s", el.Prefix)
+	}
+	for _, a := range t.Attr {
+		if a.Name.Space == "xmlns" || (a.Name.Space == "" && a.Name.Local == "xmlns") {
+			continue
+		}
+		at := attr{Prefix: a.Name.Space, Local: a.Name.Local, Value: a.Value}
+		switch at.Prefix {
+		case "":
+		case "xml":
+			at.Space = xmlNamespace
+		default:
+			at.Space = el.Scope[at.Prefix]
+			if at.Space == "" {
+				return nil, fmt.Errorf("invalid XML: undeclared prefix %s", at.Prefix)
+			}
+		}
+		el.Attrs = append(el.Attrs, at)
+	}
+	return el, nil
+}
+
+// is reports whether the element has the given namespace and local name
+func (el *element) is(space, local string) bool {
+	return el.Space == space && el.Local == local
+}
+
+// elements returns the child elements with the given namespace and local
+// name
+func (el *element) elements(space, local string) []*element {
+	var found []*element
+	for _, n := range el.Nodes {
+		if child, ok := n.(*element); ok && child.is(space, local) {
+			found = append(found, child)
+		}
+	}
+	return found
+}
+
+// element returns the only child element with the given namespace and
+// local name, or nil when there isn't exactly one
+func (el *element) element(space, local string) *element {
+	found := el.elements(space, local)
+	if len(found) != 1 {
+		return nil
+	}
+	return found[0]
+}
+
+// attr returns the value of the unqualified attribute with the given name
+func (el *element) attr(local string) string {
+	for _, a := range el.Attrs {
+		if a.Prefix == "" && a.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// text returns the element's text, without surrounding whitespace
+func (el *element) text() string {
+	var b strings.Builder
+	for _, n := range el.Nodes {
+		if s, ok := n.(string); ok {
+			b.WriteString(s)
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// canonicalize serializes the element with Exclusive XML Canonicalization
+// without comments (https://www.w3.org/TR/xml-exc-c14n/), leaving out
+// skip, such as an enveloped signature. Prefixes in inclusive are treated
+// as the inclusive namespace prefix list ("#default" for the default).
+func canonicalize(el, skip *element, inclusive []string) []byte {
+	var b bytes.Buffer
+	writeCanonical(&b, el, skip, inclusive, map[string]string{})
+	return b.Bytes()
+}
+
+// writeCanonical writes one element, given the namespace declarations
+// already rendered by its output ancestors
+func writeCanonical(b *bytes.Buffer, el, skip *element, inclusive []string, rendered map[string]string) {
+	// Namespaces are declared where they're visibly used, or in scope and
+	// on the inclusive list, unless an output ancestor already did so
+	used := map[string]bool{el.Prefix: true}
+	for _, a := range el.Attrs {
+		if a.Prefix != "" {
+			used[a.Prefix] = true
+		}
+	}
+	for _, p := range inclusive {
+		if p == "#default" {
+			p = ""
+		}
+		if _, ok := el.Scope[p]; ok {
+			used[p] = true
+		}
+	}
+
+	var decls []attr
+	for p := range used {
+		if p == "xml" {
+			continue
+		}
+		ns := el.Scope[p]
+		prev, ok := rendered[p]
+		if p == "" && ns == "" && (!ok || prev == "") {
+			// The empty default needs no declaration unless an ancestor
+			// declared a different one
+			continue
+		}
+		if ok && prev == ns {
+			continue
+		}
+		decls = append(decls, attr{Local: p, Value: ns})
+	}
+	slices.SortFunc(decls, func(a, b attr) int { return strings.Compare(a.Local, b.Local) })
+	if len(decls) > 0 {
+		next := make(map[string]string, len(rendered)+len(decls))
+		for p, ns := range rendered {
+			next[p] = ns
+		}
+		for _, d := range decls {
+			next[d.Local] = d.Value
+		}
+		rendered = next
+	}
+
+	attrs := slices.Clone(el.Attrs)
+	slices.SortFunc(attrs, func(a, b attr) int {
+		if c := strings.Compare(a.Space, b.Space); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Local, b.Local)
+	})
+
+	b.WriteByte('<')
+	writeQName(b, el.Prefix, el.Local)
+	for _, d := range decls {
+		if d.Local == "" {
+			b.WriteString(` xmlns="`)
+		} else {
+			b.WriteString(` xmlns:` + d.Local + `="`)
+		}
+		writeEscaped(b, d.Value, true)
+		b.WriteByte('"')
+	}
+	for _, a := range attrs {
+		b.WriteByte(' ')
+		writeQName(b, a.Prefix, a.Local)
+		b.WriteString(`="`)
+		writeEscaped(b, a.Value, true)
+		b.WriteByte('"')
+	}
+	b.WriteByte('>')
+
+	for _, n := range el.Nodes {
+		switch n := n.(type) {
+		case *element:
+			if n != skip {
+				writeCanonical(b, n, skip, inclusive, rendered)
+			}
+		case string:
+			writeEscaped(b, n, false)
+		case procInst:
+			b.WriteString("<?" + n.Target)
+			if n.Inst != "" {
+				b.WriteString(" " + n.Inst)
+			}
+			b.WriteString("?>")
+		}
+	}
+
+	b.WriteString("</")
+	writeQName(b, el.Prefix, el.Local)
+	b.WriteByte('>')
+}
+
+// writeQName writes a name with its prefix, if any
+func writeQName(b *bytes.Buffer, prefix, local string) {
+	if prefix != "" {
+		b.WriteString(prefix + ":")
+	}
+	b.WriteString(local)
+}
+
+// writeEscaped writes text or an attribute value with canonical escaping
+func writeEscaped(b *bytes.Buffer, s string, inAttr bool) {
+	for _, r := range s {
+		switch {
+		case r == '&':
+			b.WriteString("&amp;")
+		case r == '<':
+			b.WriteString("&lt;")
+		case r == '>' && !inAttr:
+			b.WriteString("&gt;")
+		case r == '"' && inAttr:
+			b.WriteString("&quot;")
+		case r == '\t' && inAttr:
+			b.WriteString("&#x9;")
+		case r == '\n' && inAttr:
+			b.WriteString("&#xA;")
+		case r == '\r':
+			b.WriteString("&#xD;")
+		default:
+			b.WriteRune(r)
+		}
+	}
+}
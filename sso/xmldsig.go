@@ -0,0 +1,179 @@
+package sso
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// XML signature namespaces and algorithms
+const (
+	dsigNamespace      = "http://www.w3.org/2000/09/xmldsig#"
+	excC14N            = "http://www.w3.org/2001/10/xml-exc-c14n#"
+	envelopedSignature = dsigNamespace + "enveloped-signature"
+)
+
+// digestMethods are the digest algorithms accepted; SHA-1 isn't
+var digestMethods = map[string]crypto.Hash{
+	"http://www.w3.org/2001/04/xmlenc#sha256": crypto.SHA256,
+	"http://www.w3.org/2001/04/xmlenc#sha512": crypto.SHA512,
+}
+
+// signatureMethods are the signature algorithms accepted; SHA-1 isn't
+var signatureMethods = map[string]crypto.Hash{
+	"http://www.w3.org/2001/04/xmldsig-more#rsa-sha256":   crypto.SHA256,
+	"http://www.w3.org/2001/04/xmldsig-more#rsa-sha512":   crypto.SHA512,
+	"http://www.w3.org/2001/04/xmldsig-more#ecdsa-sha256": crypto.SHA256,
+}
+
+// errNotSigned is returned when an element carries no signature
+var errNotSigned = errors.New("not signed")
+
+// verifySignature checks the signature enveloped in el, which must be its
+// direct child and cover el itself, against the trusted certificates.
+// The certificate in the signature's KeyInfo is never trusted.
+func verifySignature(el *element, certs []*x509.Certificate) error {
+	sigs := el.elements(dsigNamespace, "Signature")
+	switch len(sigs) {
+	case 0:
+		return errNotSigned
+	case 1:
+	default:
+		return errors.New("more than one signature")
+	}
+	sig := sigs[0]
+	id := el.attr("ID")
+	if id == "" {
+		return errors.New("signed element has no ID")
+	}
+
+	signedInfo := sig.element(dsigNamespace, "SignedInfo")
+	if signedInfo == nil {
+		return errors.New("signature has no SignedInfo")
+	}
+	c14n := signedInfo.element(dsigNamespace, "CanonicalizationMethod")
+	if c14n == nil || c14n.attr("Algorithm") != excC14N {
+		return errors.New("unsupported canonicalization method")
+	}
+	method := signedInfo.element(dsigNamespace, "SignatureMethod")
+	if method == nil {
+		return errors.New("signature has no SignatureMethod")
+	}
+	hash, ok := signatureMethods[method.attr("Algorithm")]
+	if !ok {
+		return fmt.Errorf("unsupported signature method %s", method.attr("Algorithm"))
+	}
+
+	// The one reference must be to el, so what's verified is what's read
+	ref := signedInfo.element(dsigNamespace, "Reference")
+	if ref == nil || len(signedInfo.elements(dsigNamespace, "Reference")) != 1 {
+		return errors.New("signature must have exactly one reference")
+	}
+	if ref.attr("URI") != "#"+id {
+		return errors.New("signature doesn't reference the signed element")
+	}
+	if err := checkDigest(el, sig, ref); err != nil {
+		return err
+	}
+
+	value, err := decodeBase64(sig.element(dsigNamespace, "SignatureValue"))
+	if err != nil {
+		return fmt.Errorf("invalid signature value: %w", err)
+	}
+	h := hash.New()
+	h.Write(canonicalize(signedInfo, nil, inclusivePrefixes(c14n)))
+	digest := h.Sum(nil)
+	for _, cert := range certs {
+		if verifyWithKey(cert.PublicKey, hash, digest, value) {
+			return nil
+		}
+	}
+	return errors.New("signature doesn't verify with a trusted certificate")
+}
+
+// checkDigest checks the reference's digest of el, with the enveloped
+// signature sig left out
+func checkDigest(el, sig, ref *element) error {
+	var inclusive []string
+	transforms := ref.element(dsigNamespace, "Transforms")
+	if transforms == nil {
+		return errors.New("reference has no transforms")
+	}
+	enveloped, canonical := false, false
+	for _, t := range transforms.elements(dsigNamespace, "Transform") {
+		switch t.attr("Algorithm") {
+		case envelopedSignature:
+			enveloped = true
+		case excC14N:
+			canonical = true
+			inclusive = inclusivePrefixes(t)
+		default:
+			return fmt.Errorf("unsupported transform %s", t.attr("Algorithm"))
+		}
+	}
+	if !enveloped || !canonical {
+		return errors.New("reference must use the enveloped signature and exclusive canonicalization transforms")
+	}
+
+	method := ref.element(dsigNamespace, "DigestMethod")
+	if method == nil {
+		return errors.New("reference has no DigestMethod")
+	}
+	hash, ok := digestMethods[method.attr("Algorithm")]
+	if !ok {
+		return fmt.Errorf("unsupported digest method %s", method.attr("Algorithm"))
+	}
+	want, err := decodeBase64(ref.element(dsigNamespace, "DigestValue"))
+	if err != nil {
+		return fmt.Errorf("invalid digest value: %w", err)
+	}
+
+	h := hash.New()
+	h.Write(canonicalize(el, sig, inclusive))
+	if subtle.ConstantTimeCompare(h.Sum(nil), want) != 1 {
+		return errors.New("digest doesn't match: the signed element was changed")
+	}
+	return nil
+}
+
+// inclusivePrefixes returns the inclusive namespace prefix list given to
+// an exclusive canonicalization method or transform
+func inclusivePrefixes(method *element) []string {
+	if list := method.element(excC14N, "InclusiveNamespaces"); list != nil {
+		return strings.Fields(list.attr("PrefixList"))
+	}
+	return nil
+}
+
+// decodeBase64 decodes the base64 text of el, which may be wrapped
+func decodeBase64(el *element) ([]byte, error) {
+	if el == nil {
+		return nil, errors.New("missing")
+	}
+	s := strings.Join(strings.Fields(el.text()), "")
+	return base64.StdEncoding.DecodeString(s)
+}
+
+// verifyWithKey reports whether sig is a signature of digest by key
+func verifyWithKey(key crypto.PublicKey, hash crypto.Hash, digest, sig []byte) bool {
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(key, hash, digest, sig) == nil
+	case *ecdsa.PublicKey:
+		// XML signatures hold ECDSA signatures as r and s side by side
+		if len(sig)%2 != 0 {
+			return false
+		}
+		r := new(big.Int).SetBytes(sig[:len(sig)/2])
+		s := new(big.Int).SetBytes(sig[len(sig)/2:])
+		return ecdsa.Verify(key, digest, r, s)
+	}
+	return false
+}
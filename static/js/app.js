@@ -1,6 +1,8 @@
 // Global variables
 let currentApartmentId = null;
 let apartmentData = [];
+let suggestions = new Map(); // Typeahead completions by their text
+let suggestTimer = null;
 
 // DOM elements
 const apartmentsListEl = document.getElementById('apartmentsList');
@@ -13,9 +15,15 @@ const detailsModal = new bootstrap.Modal(document.getElementById('detailsModal')
 const deleteModal = new bootstrap.Modal(document.getElementById('deleteModal'));
 
 // Event listeners
-document.addEventListener('DOMContentLoaded', () => {
-    loadApartments();
+document.addEventListener('DOMContentLoaded', async () => {
     setupEventListeners();
+    await loadApartments();
+
+    // Links like /?apartment=12, printed on summary sheets, open that apartment
+    const linked = parseInt(new URLSearchParams(window.location.search).get('apartment'));
+    if (linked) {
+        showApartmentDetails(linked);
+    }
 });
 
 function setupEventListeners() {
@@ -68,15 +76,66 @@ function setupEventListeners() {
     document.getElementById('confirmDelete').addEventListener('click', () => {
         deleteApartment(currentApartmentId);
     });
+
+    // Search bar typeahead
+    const searchInput = document.getElementById('searchInput');
+    searchInput.addEventListener('input', () => {
+        clearTimeout(suggestTimer);
+        suggestTimer = setTimeout(() => loadSuggestions(searchInput.value), 150);
+    });
+    searchInput.addEventListener('change', () => search(searchInput.value));
+}
+
+// Fill the search bar's completions
+async function loadSuggestions(text) {
+    const listEl = document.getElementById('searchSuggestions');
+    if (text.trim() === '') {
+        listEl.innerHTML = '';
+        return;
+    }
+    try {
+        const response = await fetch(`/api/apartments/suggest?q=${encodeURIComponent(text)}`);
+        if (!response.ok) {
+            throw new Error(`HTTP error! status: ${response.status}`);
+        }
+        suggestions = new Map((await response.json()).map(s => [s.text, s]));
+        listEl.innerHTML = Array.from(suggestions.values()).map(s =>
+            `<option value="${escapeHtml(s.text)}">${escapeHtml(s.kind)}</option>`).join('');
+    } catch (error) {
+        console.error('Error loading suggestions:', error);
+    }
 }
 
-// Load apartments from API
-async function loadApartments() {
+// Narrow the list to what was searched for. Picking an address opens that
+// apartment; other completions filter the list by what they name.
+async function search(text) {
+    const s = suggestions.get(text);
+    const quoted = JSON.stringify(text);
+    if (text.trim() === '') {
+        loadApartments();
+    } else if (s && s.kind === 'address') {
+        if (!apartmentData.some(a => a.id === s.id)) {
+            await loadApartments();
+        }
+        showApartmentDetails(s.id);
+    } else if (s && s.kind === 'neighborhood') {
+        loadApartments(`q=${encodeURIComponent('neighborhood~' + quoted)}`);
+    } else if (s && s.kind === 'building') {
+        loadApartments(`q=${encodeURIComponent('building_id=' + s.id)}`);
+    } else if (s && s.kind === 'amenity') {
+        loadApartments(`amenities=${encodeURIComponent(s.key)}`);
+    } else {
+        loadApartments(`search=${encodeURIComponent(text)}`);
+    }
+}
+
+// Load apartments from API, optionally narrowed by list query parameters
+async function loadApartments(params = '') {
     loadingIndicatorEl.classList.remove('d-none');
     emptyStateEl.classList.add('d-none');
 
     try {
-        const response = await fetch('/api/apartments');
+        const response = await fetch(`/api/apartments?render=html${params ? '&' + params : ''}`);
         if (!response.ok) {
             throw new Error(`HTTP error! status: ${response.status}`);
         }
@@ -113,9 +172,10 @@ function renderApartmentsList() {
 
         col.innerHTML = `
             <div class="card apartment-card">
+                ${apartment.cover_photo_id ? `<img class="card-img-top" src="/api/apartments/${apartment.id}/attachments/${apartment.cover_photo_id}/thumbnail" alt="">` : ''}
                 <div class="card-body">
-                    <h5 class="card-title">${escapeHtml(apartment.address)}</h5>
-                    <h6 class="card-subtitle mb-2 text-muted">$${apartment.price.toFixed(2)} | Floor: ${apartment.floor || 1}</h6>
+                    <h5 class="card-title">${escapeHtml(fullAddress(apartment))}</h5>
+                    <h6 class="card-subtitle mb-2 text-muted">${formatPrice(apartment.price)} | Floor: ${apartment.floor ?? '—'}</h6>
                     <div class="mb-2">
                         ${renderStarRating(apartment.rating)}
                     </div>
@@ -124,6 +184,7 @@ function renderApartmentsList() {
                         <span class="badge ${apartment.has_garage ? 'bg-success' : 'bg-light text-dark border'}">Garage</span>
                         <span class="badge ${apartment.has_laundry ? 'bg-success' : 'bg-light text-dark border'}">Laundry</span>
                     </div>
+                    ${renderReactions(apartment.reactions)}
                     <p class="card-text">${apartment.notes ? escapeHtml(apartment.notes.substring(0, 100)) + (apartment.notes.length > 100 ? '...' : '') : 'No notes'}</p>
                     <div class="text-muted small mb-2">Visited: ${visitDate}</div>
                     <button class="btn btn-sm btn-outline-primary view-details" data-id="${apartment.id}">View Details</button>
@@ -153,11 +214,11 @@ function showApartmentDetails(id) {
     const detailsContent = document.getElementById('detailsContent');
     detailsContent.innerHTML = `
         <div class="apartment-details">
-            <h3>${escapeHtml(apartment.address)}</h3>
+            <h3>${escapeHtml(fullAddress(apartment))}</h3>
             <div class="mb-3">${renderStarRating(apartment.rating)}</div>
             <div class="row mb-3">
                 <div class="col-6">
-                    <strong>Price:</strong> $${apartment.price.toFixed(2)}
+                    <strong>Price:</strong> ${formatPrice(apartment.price)}
                 </div>
                 <div class="col-6">
                     <strong>Visit Date:</strong> ${visitDate}
@@ -165,7 +226,7 @@ function showApartmentDetails(id) {
             </div>
             <div class="row mb-3">
                 <div class="col-6">
-                    <strong>Floor:</strong> ${apartment.floor || 1}
+                    <strong>Floor:</strong> ${apartment.floor ?? 'Unknown'}
                 </div>
                 <div class="col-6">
                     <strong>Features:</strong>
@@ -187,8 +248,9 @@ function showApartmentDetails(id) {
             </div>
             <div class="mb-3">
                 <strong>Notes:</strong>
-                <p>${apartment.notes ? escapeHtml(apartment.notes) : 'No notes'}</p>
+                ${apartment.notes ? `<div class="notes">${apartment.notes_html}</div>` : '<p>No notes</p>'}
             </div>
+            <div id="mediaLinks" class="mb-3"></div>
             <div class="text-muted small">
                 <div>Created: ${new Date(apartment.created_at).toLocaleString()}</div>
                 <div>Updated: ${new Date(apartment.updated_at).toLocaleString()}</div>
@@ -197,6 +259,38 @@ function showApartmentDetails(id) {
     `;
 
     detailsModal.show();
+    loadMediaLinks(id);
+}
+
+// Show an apartment's video walkthroughs and 3D tours in the details modal
+async function loadMediaLinks(id) {
+    try {
+        const response = await fetch(`/api/apartments/${id}/media`);
+        if (!response.ok) {
+            throw new Error(`HTTP error! status: ${response.status}`);
+        }
+        const links = await response.json();
+
+        const el = document.getElementById('mediaLinks');
+        if (!el || currentApartmentId !== id || links.length === 0) return;
+
+        // embed_html is an iframe the server rebuilt from the provider's
+        // player URL, so it's safe to insert
+        el.innerHTML = `
+            <strong>Walkthroughs:</strong>
+            ${links.map(link => `
+                <div class="mt-2">
+                    ${link.embed_html
+                        ? `<div class="ratio ratio-16x9">${link.embed_html}</div>`
+                        : link.thumbnail_url ? `<img class="img-fluid" src="${escapeHtml(link.thumbnail_url)}" alt="">` : ''}
+                    <a href="${escapeHtml(link.url)}" target="_blank" rel="noopener">${escapeHtml(link.title || link.url)}</a>
+                    <span class="text-muted small">${escapeHtml(link.provider)}</span>
+                </div>
+            `).join('')}
+        `;
+    } catch (error) {
+        console.error('Error loading media links:', error);
+    }
 }
 
 // Save apartment (create or update)
@@ -220,11 +314,12 @@ async function saveApartment() {
 
     const apartmentData = {
         address: addressInput.value.trim(),
+        unit: document.getElementById('unit').value.trim(),
         visit_date: visitDateValue || null,
-        price: parseFloat(document.getElementById('price').value) || 0,
-        rating: parseInt(document.getElementById('rating').value) || 0,
+        price: optionalNumber('price', parseFloat),
+        rating: optionalNumber('rating', parseInt),
         notes: document.getElementById('notes').value.trim(),
-        floor: parseInt(document.getElementById('floor').value) || 1,
+        floor: optionalNumber('floor', parseInt),
         is_gated: document.getElementById('isGated').checked,
         has_garage: document.getElementById('hasGarage').checked,
         has_laundry: document.getElementById('hasLaundry').checked
@@ -268,13 +363,28 @@ async function saveApartment() {
     }
 }
 
-// Delete an apartment
+// Delete an apartment. One with visits, photos, comments, and the like is
+// only deleted with them once that's confirmed.
 async function deleteApartment(id) {
     try {
-        const response = await fetch(`/api/apartments/${id}`, {
+        let response = await fetch(`/api/apartments/${id}`, {
             method: 'DELETE'
         });
 
+        if (response.status === 409) {
+            const { dependents } = await response.json();
+            const list = Object.entries(dependents)
+                .map(([kind, n]) => `${n} ${kind.replaceAll('_', ' ')}`)
+                .join(', ');
+            if (!window.confirm(`This apartment has ${list}. Delete them too?`)) {
+                deleteModal.hide();
+                return;
+            }
+            response = await fetch(`/api/apartments/${id}?force=true`, {
+                method: 'DELETE'
+            });
+        }
+
         if (!response.ok) {
             throw new Error(`HTTP error! status: ${response.status}`);
         }
@@ -296,6 +406,7 @@ async function deleteApartment(id) {
 function resetForm() {
     document.getElementById('apartmentId').value = '';
     document.getElementById('address').value = '';
+    document.getElementById('unit').value = '';
     document.getElementById('visitDate').value = '';
     document.getElementById('price').value = '';
     document.getElementById('notes').value = '';
@@ -310,6 +421,7 @@ function resetForm() {
 function populateForm(apartment) {
     document.getElementById('apartmentId').value = apartment.id;
     document.getElementById('address').value = apartment.address;
+    document.getElementById('unit').value = apartment.unit || '';
 
     // Format date for datetime-local input
     if (apartment.visit_date) {
@@ -320,17 +432,45 @@ function populateForm(apartment) {
         document.getElementById('visitDate').value = '';
     }
 
-    document.getElementById('price').value = apartment.price;
+    document.getElementById('price').value = apartment.price ?? '';
     document.getElementById('notes').value = apartment.notes || '';
-    document.getElementById('floor').value = apartment.floor || 1;
+    document.getElementById('floor').value = apartment.floor ?? '';
     document.getElementById('isGated').checked = apartment.is_gated || false;
     document.getElementById('hasGarage').checked = apartment.has_garage || false;
     document.getElementById('hasLaundry').checked = apartment.has_laundry || false;
-    setRating(apartment.rating);
+    setRating(apartment.rating || 0);
 
     currentApartmentId = apartment.id;
 }
 
+// Read a number input, or null when it's empty, which the API takes as
+// unknown. A rating of 0 stars means unrated.
+function optionalNumber(id, parse) {
+    const value = parse(document.getElementById(id).value);
+    if (Number.isNaN(value) || (id === 'rating' && value === 0)) {
+        return null;
+    }
+    return value;
+}
+
+// The address with the unit in it, as in "123 Main St #4B, Springfield"
+function fullAddress(apartment) {
+    if (!apartment.unit) {
+        return apartment.address;
+    }
+    const unit = /^(suite|ste|floor|fl|room|rm|building|bldg)\b/i.test(apartment.unit) ? apartment.unit : `#${apartment.unit}`;
+    const comma = apartment.address.indexOf(',');
+    if (comma < 0) {
+        return `${apartment.address} ${unit}`;
+    }
+    return `${apartment.address.slice(0, comma)} ${unit}${apartment.address.slice(comma)}`;
+}
+
+// Format a rent, which is null when unknown
+function formatPrice(price) {
+    return price == null ? 'Price unknown' : `$${price.toFixed(2)}`;
+}
+
 function setRating(rating) {
     document.getElementById('rating').value = rating;
     highlightStars(rating);
@@ -347,6 +487,16 @@ function highlightStars(count) {
     });
 }
 
+// Render an apartment's reaction counts, leaving out the ones nobody left
+function renderReactions(reactions) {
+    const emoji = { thumbs_up: '👍', thumbs_down: '👎', heart: '❤️', thinking: '🤔' };
+    const shown = Object.entries(reactions || {}).filter(([, count]) => count > 0);
+    if (shown.length === 0) {
+        return '';
+    }
+    return `<div class="mb-2 small">${shown.map(([name, count]) => `${emoji[name]} ${count}`).join(' ')}</div>`;
+}
+
 function renderStarRating(rating) {
     let stars = '';
     for (let i = 1; i <= 5; i++) {
@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStore(t *testing.T) {
+	s, err := New(t.TempDir())
+	assert.NoError(t, err)
+
+	n, err := s.Put("floorplans/1", strings.NewReader("plan"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), n)
+
+	f, err := s.Open("floorplans/1")
+	if assert.NoError(t, err) {
+		data, _ := io.ReadAll(f)
+		f.Close()
+		assert.Equal(t, "plan", string(data))
+	}
+
+	assert.NoError(t, s.Delete("floorplans/1"))
+	assert.NoError(t, s.Delete("floorplans/1"))
+	_, err = s.Open("floorplans/1")
+	assert.Error(t, err)
+}
+
+func TestStoreRejectsEscapingKeys(t *testing.T) {
+	s, err := New(t.TempDir())
+	assert.NoError(t, err)
+
+	for _, key := range []string{"", "/etc/passwd", "../x", "a/../../x", "a//b"} {
+		_, err := s.Put(key, strings.NewReader("x"))
+		assert.ErrorIs(t, err, ErrInvalidKey, key)
+	}
+}
@@ -0,0 +1,204 @@
+// Package webhook verifies signed webhook requests and maps their JSON
+// payloads, such as a listing alert service's or Zapier's, onto listing
+// details so they can be saved as drafts
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// DefaultSignatureHeader carries the signature unless a source names
+// another header
+const DefaultSignatureHeader = "X-Signature"
+
+// signaturePrefix marks the algorithm in signatures like GitHub's
+const signaturePrefix = "sha256="
+
+// Fields a payload can be mapped onto
+const (
+	FieldAddress   = "address"
+	FieldUnit      = "unit"
+	FieldPrice     = "price"
+	FieldURL       = "listing_url"
+	FieldNotes     = "notes"
+	FieldLatitude  = "latitude"
+	FieldLongitude = "longitude"
+)
+
+var knownFields = []string{FieldAddress, FieldUnit, FieldPrice, FieldURL, FieldNotes, FieldLatitude, FieldLongitude}
+
+// namePattern limits source names to what fits in a URL path segment
+var namePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// Sign returns the signature of body: its HMAC-SHA256 under secret, in hex
+// with a "sha256=" prefix
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is body's HMAC-SHA256 under secret.
+// The signature may be hex, with or without a "sha256=" prefix, or base64,
+// as the common senders differ.
+func Verify(secret string, body []byte, signature string) bool {
+	if secret == "" || signature == "" {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	expected := mac.Sum(nil)
+
+	signature = strings.TrimSpace(signature)
+	if sig, err := hex.DecodeString(strings.TrimPrefix(signature, signaturePrefix)); err == nil {
+		return hmac.Equal(sig, expected)
+	}
+	if sig, err := base64.StdEncoding.DecodeString(signature); err == nil {
+		return hmac.Equal(sig, expected)
+	}
+	return false
+}
+
+// Listing holds the details mapped from a payload; fields that weren't
+// mapped or found are empty
+type Listing struct {
+	Address   string
+	Unit      string
+	Price     *float64 // Monthly rent
+	URL       string
+	Notes     string
+	Latitude  *float64
+	Longitude *float64
+}
+
+// Source configures one service that posts webhooks
+type Source struct {
+	// Name identifies the source in its URL, /api/inbound/webhooks/<name>
+	Name string `json:"name"`
+
+	// Secret signs the source's requests
+	Secret string `json:"secret"`
+
+	// SignatureHeader carries the signature (default: X-Signature)
+	SignatureHeader string `json:"signature_header"`
+
+	// Fields maps listing fields (address, unit, price, listing_url,
+	// notes, latitude, longitude) to paths in the payload, with dots
+	// between keys and array indexes, such as "listing.photos.0.url"
+	Fields map[string]string `json:"fields"`
+}
+
+// LoadSources reads webhook source configuration from a JSON file
+// containing an array of Source, keyed by name. An empty path yields no
+// sources.
+func LoadSources(path string) (map[string]*Source, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhook sources: %w", err)
+	}
+
+	var list []*Source
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("failed to parse webhook sources: %w", err)
+	}
+
+	sources := make(map[string]*Source, len(list))
+	for _, s := range list {
+		if !namePattern.MatchString(s.Name) {
+			return nil, fmt.Errorf("webhook source %q: names may only have lowercase letters, digits, dashes, and underscores", s.Name)
+		}
+		if _, ok := sources[s.Name]; ok {
+			return nil, fmt.Errorf("webhook source %s: configured twice", s.Name)
+		}
+		if s.Secret == "" {
+			return nil, fmt.Errorf("webhook source %s: secret is required", s.Name)
+		}
+		if s.SignatureHeader == "" {
+			s.SignatureHeader = DefaultSignatureHeader
+		}
+		if s.Fields[FieldAddress] == "" && s.Fields[FieldURL] == "" {
+			return nil, fmt.Errorf("webhook source %s: fields must map address or listing_url", s.Name)
+		}
+		for field := range s.Fields {
+			if !slices.Contains(knownFields, field) {
+				return nil, fmt.Errorf("webhook source %s: unknown field %q", s.Name, field)
+			}
+		}
+		sources[s.Name] = s
+	}
+	return sources, nil
+}
+
+// Map pulls the listing details out of a payload by the source's field
+// mappings. Values that are missing or of the wrong kind are skipped.
+func (s *Source) Map(payload any) Listing {
+	text := func(field string) string {
+		switch v := lookup(payload, s.Fields[field]).(type) {
+		case string:
+			return strings.TrimSpace(v)
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64)
+		}
+		return ""
+	}
+	number := func(field string) *float64 {
+		switch v := lookup(payload, s.Fields[field]).(type) {
+		case float64:
+			return &v
+		case string:
+			// Amounts like "$1,850"
+			v = strings.NewReplacer("$", "", ",", "").Replace(strings.TrimSpace(v))
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				return &f
+			}
+		}
+		return nil
+	}
+
+	return Listing{
+		Address:   text(FieldAddress),
+		Unit:      text(FieldUnit),
+		Price:     number(FieldPrice),
+		URL:       text(FieldURL),
+		Notes:     text(FieldNotes),
+		Latitude:  number(FieldLatitude),
+		Longitude: number(FieldLongitude),
+	}
+}
+
+// lookup follows a dotted path through decoded JSON, returning nil when
+// any step is missing
+func lookup(value any, path string) any {
+	if path == "" {
+		return nil
+	}
+	for _, key := range strings.Split(path, ".") {
+		switch v := value.(type) {
+		case map[string]any:
+			value = v[key]
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil
+			}
+			value = v[i]
+		default:
+			return nil
+		}
+	}
+	return value
+}
@@ -0,0 +1,99 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"address":"742 Evergreen Terrace"}`)
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write(body)
+	sum := mac.Sum(nil)
+
+	assert.True(t, Verify("s3cret", body, Sign("s3cret", body)))
+	assert.True(t, Verify("s3cret", body, hex.EncodeToString(sum)), "bare hex")
+	assert.True(t, Verify("s3cret", body, base64.StdEncoding.EncodeToString(sum)), "base64")
+
+	assert.False(t, Verify("other", body, Sign("s3cret", body)))
+	assert.False(t, Verify("s3cret", append(body, ' '), Sign("s3cret", body)))
+	assert.False(t, Verify("s3cret", body, ""))
+	assert.False(t, Verify("s3cret", body, "sha256=nothex"))
+	assert.False(t, Verify("", body, Sign("", body)), "an empty secret accepts nothing")
+}
+
+func TestMap(t *testing.T) {
+	s := &Source{Fields: map[string]string{
+		FieldAddress:  "listing.address",
+		FieldPrice:    "listing.rent",
+		FieldURL:      "links.0",
+		FieldNotes:    "listing.description",
+		FieldLatitude: "listing.geo.lat",
+		FieldUnit:     "listing.unit",
+	}}
+	var payload any
+	assert.NoError(t, json.Unmarshal([]byte(`{
+		"listing": {
+			"address": " 742 Evergreen Terrace ",
+			"rent": "$1,850",
+			"description": "Sunny 2BR",
+			"geo": {"lat": 39.78},
+			"unit": 3
+		},
+		"links": ["https://listings.example.com/l/98765"]
+	}`), &payload))
+
+	l := s.Map(payload)
+	assert.Equal(t, "742 Evergreen Terrace", l.Address)
+	if assert.NotNil(t, l.Price) {
+		assert.Equal(t, 1850.0, *l.Price)
+	}
+	assert.Equal(t, "https://listings.example.com/l/98765", l.URL)
+	assert.Equal(t, "Sunny 2BR", l.Notes)
+	if assert.NotNil(t, l.Latitude) {
+		assert.Equal(t, 39.78, *l.Latitude)
+	}
+	assert.Nil(t, l.Longitude, "unmapped")
+	assert.Equal(t, "3", l.Unit)
+
+	l = s.Map(map[string]any{"listing": "not an object", "links": []any{}})
+	assert.Equal(t, Listing{}, l)
+}
+
+func TestLoadSources(t *testing.T) {
+	sources, err := LoadSources("")
+	assert.NoError(t, err)
+	assert.Empty(t, sources)
+
+	load := func(config string) (map[string]*Source, error) {
+		path := filepath.Join(t.TempDir(), "webhooks.json")
+		assert.NoError(t, os.WriteFile(path, []byte(config), 0o600))
+		return LoadSources(path)
+	}
+
+	sources, err = load(`[{"name": "zapier", "secret": "s3cret", "fields": {"address": "address"}}]`)
+	assert.NoError(t, err)
+	if assert.Contains(t, sources, "zapier") {
+		assert.Equal(t, DefaultSignatureHeader, sources["zapier"].SignatureHeader)
+	}
+
+	for _, config := range []string{
+		`[{"name": "Zapier!", "secret": "s3cret", "fields": {"address": "address"}}]`,
+		`[{"name": "zapier", "fields": {"address": "address"}}]`,
+		`[{"name": "zapier", "secret": "s3cret", "fields": {"price": "rent"}}]`,
+		`[{"name": "zapier", "secret": "s3cret", "fields": {"address": "address", "bedrooms": "beds"}}]`,
+		`[{"name": "zapier", "secret": "a", "fields": {"address": "a"}}, {"name": "zapier", "secret": "b", "fields": {"address": "b"}}]`,
+		`{`,
+	} {
+		_, err := load(config)
+		assert.Error(t, err, config)
+	}
+}
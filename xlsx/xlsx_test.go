@@ -0,0 +1,61 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCellRef(t *testing.T) {
+	assert.Equal(t, "A1", CellRef(0, 0))
+	assert.Equal(t, "Z3", CellRef(25, 2))
+	assert.Equal(t, "AA1", CellRef(26, 0))
+	assert.Equal(t, "AZ1", CellRef(51, 0))
+	assert.Equal(t, "BA10", CellRef(52, 9))
+}
+
+func TestSheetName(t *testing.T) {
+	assert.Equal(t, "Sheet1", sheetName(""))
+	assert.Equal(t, "a_b", sheetName("a/b"))
+	assert.Len(t, sheetName("a very long sheet name that Excel would reject"), maxSheetName)
+}
+
+func TestWrite(t *testing.T) {
+	score := 4.25
+	var missing *float64
+	var buf bytes.Buffer
+	require.NoError(t, Write(&buf, "Matrix", [][]any{
+		{"Criterion", "Weight", "1 Main St & Co <4B>"},
+		{"location", 2, &score},
+		{"kitchen", 1.5, missing},
+	}))
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	parts := map[string][]byte{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		parts[f.Name], err = io.ReadAll(rc)
+		require.NoError(t, err)
+		rc.Close()
+	}
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/worksheets/sheet1.xml"} {
+		require.Contains(t, parts, name)
+		assert.NoError(t, xml.Unmarshal(parts[name], new(struct{})), name)
+	}
+
+	sheet := string(parts["xl/worksheets/sheet1.xml"])
+	assert.Contains(t, sheet, `<c r="C1" t="inlineStr"><is><t xml:space="preserve">1 Main St &amp; Co &lt;4B&gt;</t></is></c>`)
+	assert.Contains(t, sheet, `<c r="B2"><v>2</v></c>`)
+	assert.Contains(t, sheet, `<c r="C2"><v>4.25</v></c>`)
+	assert.Contains(t, sheet, `<c r="B3"><v>1.5</v></c>`)
+	assert.NotContains(t, sheet, `r="C3"`)
+
+	assert.Error(t, Write(io.Discard, "x", [][]any{{true}}))
+}